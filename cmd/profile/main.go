@@ -1,14 +1,25 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/mindmorass/shell-profile-manager/internal/cli"
+	"github.com/mindmorass/shell-profile-manager/internal/commands"
 	"github.com/mindmorass/shell-profile-manager/internal/config"
 )
 
 func main() {
+	// Move files from the locations used before the XDG base directories
+	notices, err := config.Migrate()
+	for _, notice := range notices {
+		fmt.Fprintln(os.Stderr, notice)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
 	// Load configuration (uses defaults if config file doesn't exist)
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -17,12 +28,21 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := commands.SetChangedExitCode(cfg.ChangedExitCode); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+		os.Exit(commands.ExitCode(err))
+	}
+
 	// Create CLI instance
-	app := cli.NewApp(cfg.ProfilesDir)
+	app := cli.NewApp(cfg)
 
 	// Run the CLI
 	if err := app.Run(os.Args[1:]); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		var changed *commands.ChangesApplied
+		var commandErr *commands.CommandExitError
+		if !errors.As(err, &changed) && !errors.As(err, &commandErr) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		os.Exit(commands.ExitCode(err))
 	}
 }
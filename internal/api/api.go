@@ -0,0 +1,359 @@
+// Package api serves the profiles over HTTP on a unix socket or a loopback
+// address, so editor extensions and status bar widgets can list profiles,
+// check them, resolve their environment, and switch between them without
+// running the profile command and parsing its output.
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mindmorass/shell-profile-manager/internal/atomicfile"
+	"github.com/mindmorass/shell-profile-manager/internal/commands"
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+	"github.com/mindmorass/shell-profile-manager/pkg/profile"
+)
+
+// Files in the state directory
+const (
+	// SocketFileName is the unix socket served by default
+	SocketFileName = "api.sock"
+	// TokenFileName holds the token clients of a TCP address send as a
+	// bearer token
+	TokenFileName = "api-token"
+)
+
+type Options struct {
+	// Socket is the unix socket to serve on (default: api.sock in the
+	// state directory)
+	Socket string
+	// Addr is a loopback host:port to serve on instead of a socket
+	Addr string
+	// Roots are the profile roots besides the profiles directory
+	Roots map[string]string
+	// Aliases are the profile aliases of the config file
+	Aliases map[string]string
+	// Within is how long before credentials expire they are reported by
+	// /v1/status (0: 1h)
+	Within time.Duration
+	// Stdio speaks JSON-RPC on stdin and stdout instead of serving HTTP
+	Stdio bool
+}
+
+// server handles the requests of the API
+type server struct {
+	client *profile.Client
+	within time.Duration
+}
+
+// Handler returns the handler of the API. With a token, requests must send
+// it as a bearer token and name a loopback host, so web pages cannot reach
+// the API through the browser.
+func Handler(client *profile.Client, token string, within time.Duration) http.Handler {
+	s := &server{client: client, within: within}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/version", s.handleVersion)
+	mux.HandleFunc("/v1/profiles", s.handleProfiles)
+	mux.HandleFunc("/v1/profiles/", s.handleProfile)
+	mux.HandleFunc("/v1/which", s.handleWhich)
+	mux.HandleFunc("/v1/env", s.handleEnv)
+	mux.HandleFunc("/v1/status", s.handleStatus)
+	mux.HandleFunc("/v1/diagnostics", s.handleDiagnostics)
+	mux.HandleFunc("/v1/switch", s.handleSwitch)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slog.Debug("api request", "method", r.Method, "path", r.URL.Path)
+		if token != "" {
+			if !loopbackHost(r.Host) {
+				writeError(w, http.StatusForbidden, errors.New("the API only answers requests for localhost"))
+				return
+			}
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+				writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or wrong bearer token (see %s in the state directory)", TokenFileName))
+				return
+			}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// GET /v1/version: the spm version, for clients to check compatibility
+func (s *server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"version": commands.SpmVersion()})
+}
+
+// GET /v1/profiles: every profile
+func (s *server) handleProfiles(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	profiles, err := s.client.List()
+	respond(w, profiles, err)
+}
+
+// GET /v1/profiles/<name>: a profile; names in a root are <root>/<name>
+func (s *server) handleProfile(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	p, err := s.client.Get(strings.TrimPrefix(r.URL.Path, "/v1/profiles/"))
+	respond(w, p, err)
+}
+
+// GET /v1/which?dir=<path>: the profile a directory is in
+func (s *server) handleWhich(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	dir := r.URL.Query().Get("dir")
+	if !filepath.IsAbs(dir) {
+		writeError(w, http.StatusBadRequest, errors.New("dir must be an absolute path"))
+		return
+	}
+	p, err := s.client.Containing(dir)
+	respond(w, p, err)
+}
+
+// GET /v1/env?profile=<name>: the environment a profile's .envrc loads
+func (s *server) handleEnv(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	env, err := s.client.Env(r.URL.Query().Get("profile"))
+	respond(w, env, err)
+}
+
+// GET /v1/status[?profile=<name>]: the alerts of every profile, or of one
+func (s *server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	alerts, err := s.alerts(r.URL.Query().Get("profile"))
+	respond(w, map[string][]profile.Alert{"alerts": alerts}, err)
+}
+
+// GET /v1/diagnostics[?profile=<name>]: what doctor and verify report for
+// every profile, or for one, located at the files they are about
+func (s *server) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	diagnostics, err := s.client.Diagnostics(r.URL.Query().Get("profile"))
+	respond(w, diagnostics, err)
+}
+
+// alerts returns the alerts of every profile, or of the one named
+func (s *server) alerts(name string) ([]profile.Alert, error) {
+	alerts, err := s.client.Alerts(s.within)
+	if err != nil || name == "" {
+		return alerts, err
+	}
+	p, err := s.client.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	matching := []profile.Alert{}
+	for _, alert := range alerts {
+		if alert.Profile == p.Name {
+			matching = append(matching, alert)
+		}
+	}
+	return matching, nil
+}
+
+// POST /v1/switch {"profile": "<name>"}: allows a profile with direnv and
+// returns it with the environment to apply
+func (s *server) handleSwitch(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	var request struct {
+		Profile string `json:"profile"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	activation, err := s.client.Switch(request.Profile)
+	respond(w, activation, err)
+}
+
+// allowMethod answers 405 to requests with another method
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s expects %s", r.URL.Path, method))
+	return false
+}
+
+// respond writes v, or the error with the status matching it
+func respond(w http.ResponseWriter, v any, err error) {
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, v)
+	case errors.Is(err, profile.ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, profile.ErrInvalid):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, profile.ErrLocked), errors.Is(err, profile.ErrExists):
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Debug("failed to write api response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// loopbackHost reports whether the Host of a request names this machine
+func loopbackHost(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// Serve serves the API until interrupted: on a unix socket only the user
+// can open, or on a loopback address with a bearer token kept in the
+// state directory. With Stdio it speaks JSON-RPC instead (see ServeStdio).
+func Serve(profilesDir string, opts Options) error {
+	if opts.Stdio {
+		return ServeStdio(profilesDir, opts)
+	}
+	client, err := profile.New(profile.Options{ProfilesDir: profilesDir, Roots: opts.Roots, Aliases: opts.Aliases})
+	if err != nil {
+		return err
+	}
+	stateDir, err := config.StateDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", stateDir, err)
+	}
+
+	var listener net.Listener
+	var token, tokenPath, where string
+	if opts.Addr != "" {
+		host, _, err := net.SplitHostPort(opts.Addr)
+		if err != nil || !loopbackHost(host) {
+			return commands.NewValidationError("--addr must be a loopback address such as 127.0.0.1:7878, got: %s", opts.Addr)
+		}
+		tokenPath = filepath.Join(stateDir, TokenFileName)
+		if token, err = loadToken(tokenPath); err != nil {
+			return err
+		}
+		if listener, err = net.Listen("tcp", opts.Addr); err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", opts.Addr, err)
+		}
+		where = "http://" + listener.Addr().String()
+	} else {
+		socket := opts.Socket
+		if socket == "" {
+			socket = filepath.Join(stateDir, SocketFileName)
+		}
+		if listener, err = listenSocket(socket); err != nil {
+			return err
+		}
+		where = "unix:" + socket
+	}
+
+	srv := &http.Server{
+		Handler:           Handler(client, token, opts.Within),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+
+	ui.PrintInfo(fmt.Sprintf("Serving the API on %s (Ctrl-C to stop)", where))
+	if tokenPath != "" {
+		fmt.Printf("  Bearer token: %s\n", tokenPath)
+	}
+	if err := srv.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// listenSocket listens on a unix socket only the user can open. A socket
+// left by a server that is gone is replaced.
+func listenSocket(path string) (net.Listener, error) {
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, commands.NewValidationError("the API is already served on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	listener, err := listenUnix(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict %s: %w", path, err)
+	}
+	return listener, nil
+}
+
+// loadToken returns the token in path, creating a random one the first
+// time
+func loadToken(path string) (string, error) {
+	if content, err := os.ReadFile(path); err == nil && len(strings.TrimSpace(string(content))) > 0 {
+		return strings.TrimSpace(string(content)), nil
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate the API token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	if err := atomicfile.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return token, nil
+}
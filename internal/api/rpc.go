@@ -0,0 +1,225 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/commands"
+	"github.com/mindmorass/shell-profile-manager/pkg/profile"
+)
+
+// maxMessageSize bounds the messages a client can send
+const maxMessageSize = 1 << 20
+
+// JSON-RPC error codes: the standard ones, and those of spm in the range
+// JSON-RPC leaves to applications
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+	codeNotFound       = 1
+	codeLocked         = 2
+)
+
+// rpcRequest is a JSON-RPC 2.0 request; without an ID it is a notification
+// and gets no response
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// rpcParams are the parameters of every method; each uses those it needs
+type rpcParams struct {
+	Profile string `json:"profile"`
+	Dir     string `json:"dir"`
+}
+
+// errExit ends the session when the client sends exit
+var errExit = errors.New("exit")
+
+// ServeStdio speaks JSON-RPC 2.0 on stdin and stdout until the client sends
+// exit or closes stdin. Messages are framed with a Content-Length header,
+// as in the Language Server Protocol, so editors can reuse their LSP
+// clients (vscode-jsonrpc, vim.lsp.rpc).
+func ServeStdio(profilesDir string, opts Options) error {
+	client, err := profile.New(profile.Options{ProfilesDir: profilesDir, Roots: opts.Roots, Aliases: opts.Aliases})
+	if err != nil {
+		return err
+	}
+	s := &server{client: client, within: opts.Within}
+	return s.serveRPC(os.Stdin, os.Stdout)
+}
+
+// serveRPC answers the requests read from r on w, one at a time
+func (s *server) serveRPC(r io.Reader, w io.Writer) error {
+	reader := bufio.NewReader(r)
+	for {
+		message, err := readMessage(reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read a JSON-RPC message: %w", err)
+		}
+
+		var request rpcRequest
+		var response *rpcResponse
+		switch {
+		case json.Unmarshal(message, &request) != nil:
+			response = &rpcResponse{Error: &rpcError{Code: codeParseError, Message: "invalid JSON"}}
+		case request.JSONRPC != "2.0" || request.Method == "":
+			response = &rpcResponse{ID: request.ID, Error: &rpcError{Code: codeInvalidRequest, Message: "not a JSON-RPC 2.0 request"}}
+		default:
+			slog.Debug("rpc request", "method", request.Method)
+			result, err := s.call(request.Method, request.Params)
+			if errors.Is(err, errExit) {
+				return nil
+			}
+			if request.ID == nil {
+				continue
+			}
+			response = &rpcResponse{ID: request.ID}
+			if err == nil {
+				response.Result, err = json.Marshal(result)
+			}
+			if err != nil {
+				response.Error = toRPCError(err)
+			}
+		}
+
+		response.JSONRPC = "2.0"
+		if response.ID == nil {
+			response.ID = json.RawMessage("null")
+		}
+		if err := writeMessage(w, response); err != nil {
+			return fmt.Errorf("failed to write a JSON-RPC response: %w", err)
+		}
+	}
+}
+
+// call runs a method and returns its result
+func (s *server) call(method string, raw json.RawMessage) (any, error) {
+	var params rpcParams
+	if len(raw) > 0 && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("invalid params: %v", err)}
+		}
+	}
+
+	switch method {
+	case "initialize":
+		return map[string]any{
+			"version": commands.SpmVersion(),
+			"methods": rpcMethods,
+		}, nil
+	case "shutdown":
+		return nil, nil
+	case "exit":
+		return nil, errExit
+	case "profiles/list":
+		return s.client.List()
+	case "profiles/get":
+		return s.client.Get(params.Profile)
+	case "profiles/which":
+		if !filepath.IsAbs(params.Dir) {
+			return nil, &rpcError{Code: codeInvalidParams, Message: "dir must be an absolute path"}
+		}
+		return s.client.Containing(params.Dir)
+	case "profiles/env":
+		return s.client.Env(params.Profile)
+	case "profiles/activate":
+		return s.client.Switch(params.Profile)
+	case "profiles/alerts":
+		return s.alerts(params.Profile)
+	case "profiles/diagnostics":
+		return s.client.Diagnostics(params.Profile)
+	default:
+		return nil, &rpcError{Code: codeMethodNotFound, Message: "unknown method: " + method}
+	}
+}
+
+// rpcMethods are the methods initialize reports
+var rpcMethods = []string{"initialize", "shutdown", "exit", "profiles/list", "profiles/get", "profiles/which", "profiles/env", "profiles/activate", "profiles/alerts", "profiles/diagnostics"}
+
+// toRPCError maps an error of the client to a JSON-RPC error
+func toRPCError(err error) *rpcError {
+	var rpcErr *rpcError
+	switch {
+	case errors.As(err, &rpcErr):
+		return rpcErr
+	case errors.Is(err, profile.ErrNotFound):
+		return &rpcError{Code: codeNotFound, Message: err.Error()}
+	case errors.Is(err, profile.ErrInvalid):
+		return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	case errors.Is(err, profile.ErrLocked):
+		return &rpcError{Code: codeLocked, Message: err.Error()}
+	default:
+		return &rpcError{Code: codeInternalError, Message: err.Error()}
+	}
+}
+
+// readMessage reads the body of the next message: headers up to an empty
+// line, of which Content-Length gives the size of the body that follows
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	headers, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(headers) == 0 {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(headers.Get("Content-Length")))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length header: %q", headers.Get("Content-Length"))
+	}
+	if length > maxMessageSize {
+		return nil, fmt.Errorf("message of %d bytes exceeds the limit of %d", length, maxMessageSize)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// writeMessage writes v as a message with its Content-Length header
+func writeMessage(w io.Writer, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
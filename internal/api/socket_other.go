@@ -0,0 +1,18 @@
+//go:build !windows
+
+package api
+
+import (
+	"net"
+	"syscall"
+)
+
+// listenUnix creates the socket at path with a umask that leaves it to the
+// user alone, so there is no moment where others could connect to it. The
+// umask is the process's; nothing else creates files while the server
+// starts.
+func listenUnix(path string) (net.Listener, error) {
+	mask := syscall.Umask(0077)
+	defer syscall.Umask(mask)
+	return net.Listen("unix", path)
+}
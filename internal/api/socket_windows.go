@@ -0,0 +1,9 @@
+package api
+
+import "net"
+
+// listenUnix creates the socket at path. Windows has no umask; the socket
+// takes the permissions of the directory it is created in.
+func listenUnix(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,100 @@
+// Package atomicfile writes files so a crash or a full disk never leaves
+// one truncated: the content goes to a temporary file in the same
+// directory, which is synced and then renamed over the original.
+package atomicfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// tempName matches the temporary files WriteFile creates, which a process
+// killed before the rename leaves behind
+var tempName = regexp.MustCompile(`^\..+\.tmp-[0-9]+$`)
+
+// IsTemp reports whether a file name is that of a temporary file of
+// WriteFile
+func IsTemp(name string) bool {
+	return tempName.MatchString(name)
+}
+
+// WriteFile writes data to the file at path like os.WriteFile, but
+// atomically. perm applies to new files; an existing file keeps its mode
+// bits and, where permitted, its owner. A symlink is kept and the file it
+// points to is replaced.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	info, err := os.Stat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return write(path, data, perm, info)
+}
+
+// ReplaceFile writes data to the file at path like WriteFile, but never
+// through a symlink: a link at path is replaced by a regular file with the
+// permissions perm, and the file it pointed to is left as it was
+func ReplaceFile(path string, data []byte, perm os.FileMode) error {
+	info, err := os.Lstat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if info != nil && info.Mode()&os.ModeSymlink != 0 {
+		info = nil
+	}
+	return write(path, data, perm, info)
+}
+
+// write writes data to a temporary file and renames it over path. info
+// describes the file replaced (nil for a new one), whose mode and owner
+// the new file keeps.
+func write(path string, data []byte, perm os.FileMode, info os.FileInfo) error {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	// Remove the temporary file unless it was renamed into place
+	renamed := false
+	defer func() {
+		if !renamed {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	mode := withUmask(perm)
+	if info != nil {
+		mode = info.Mode().Perm()
+		keepOwner(tmpPath, info)
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return fmt.Errorf("failed to set permissions on %s: %w", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	renamed = true
+	syncDir(dir)
+	return nil
+}
@@ -0,0 +1,42 @@
+//go:build !windows
+
+package atomicfile
+
+import (
+	"os"
+	"syscall"
+)
+
+// keepOwner gives the new file the owner of the file it replaces. Only
+// root can give files away, so failing to is not an error.
+func keepOwner(path string, info os.FileInfo) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		os.Lchown(path, int(stat.Uid), int(stat.Gid))
+	}
+}
+
+// umask is the umask of the process. Reading it means setting it, which
+// would change the permissions of files other goroutines create meanwhile,
+// so it is read once, when the package is initialized and before any
+// goroutine of the program runs.
+var umask = readUmask()
+
+func readUmask() os.FileMode {
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+	return os.FileMode(mask)
+}
+
+// withUmask applies the umask to the permissions of a new file, as
+// creating it directly would
+func withUmask(perm os.FileMode) os.FileMode {
+	return perm &^ umask
+}
+
+// syncDir makes the rename durable
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
@@ -0,0 +1,14 @@
+package atomicfile
+
+import "os"
+
+// keepOwner is a no-op: files on Windows get the owner of their creator
+func keepOwner(path string, info os.FileInfo) {}
+
+// withUmask returns perm: Windows has no umask
+func withUmask(perm os.FileMode) os.FileMode {
+	return perm
+}
+
+// syncDir is a no-op: directories cannot be synced on Windows
+func syncDir(dir string) {}
@@ -0,0 +1,161 @@
+// Package checksums records the checksums and permissions of the files spm
+// writes in a profile (.spm-checksums.json), so that changes made to them
+// outside spm can be told apart from its own.
+package checksums
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/mindmorass/shell-profile-manager/internal/atomicfile"
+)
+
+// FileName is the checksum file name inside a profile directory
+const FileName = ".spm-checksums.json"
+
+// Statuses of a file compared against its recorded checksum
+const (
+	StatusOK          = "ok"
+	StatusModified    = "modified"
+	StatusMissing     = "missing"
+	StatusPermissions = "permissions"
+)
+
+// Sum is the recorded state of a file
+type Sum struct {
+	SHA256 string `json:"sha256"`
+	// Mode is the permissions in octal, e.g. "0644"
+	Mode string `json:"mode"`
+}
+
+type document struct {
+	Files map[string]Sum `json:"files"`
+}
+
+// Result is a recorded file compared against its current state
+type Result struct {
+	Path   string `json:"path" yaml:"path"`
+	Status string `json:"status" yaml:"status"`
+	// Mode and ExpectedMode are set when the permissions changed
+	Mode         string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	ExpectedMode string `json:"expected_mode,omitempty" yaml:"expected_mode,omitempty"`
+}
+
+// Path returns the checksum file location for a profile directory
+func Path(profileDir string) string {
+	return filepath.Join(profileDir, FileName)
+}
+
+// Load returns the recorded files of a profile by path (empty when none
+// are recorded)
+func Load(profileDir string) (map[string]Sum, error) {
+	content, err := os.ReadFile(Path(profileDir))
+	if os.IsNotExist(err) {
+		return map[string]Sum{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", FileName, err)
+	}
+	var doc document
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", FileName, err)
+	}
+	if doc.Files == nil {
+		doc.Files = map[string]Sum{}
+	}
+	return doc.Files, nil
+}
+
+// Record stores the current checksum and permissions of files in the
+// profile, by path relative to it. Files that do not exist are dropped
+// from the record.
+func Record(profileDir string, paths ...string) error {
+	files, err := Load(profileDir)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		sum, err := current(filepath.Join(profileDir, path))
+		if os.IsNotExist(err) {
+			delete(files, path)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		files[path] = sum
+	}
+
+	content, err := json.MarshalIndent(document{Files: files}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", FileName, err)
+	}
+	if err := atomicfile.WriteFile(Path(profileDir), append(content, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", FileName, err)
+	}
+	return nil
+}
+
+// Verify compares the recorded files of a profile against their current
+// state, sorted by path. A file whose content changed is reported as
+// modified even if its permissions changed as well; permissions are only
+// reported when they grant more than those recorded.
+func Verify(profileDir string) ([]Result, error) {
+	files, err := Load(profileDir)
+	if err != nil {
+		return nil, err
+	}
+	results := []Result{}
+	for path, recorded := range files {
+		result := Result{Path: path, Status: StatusOK}
+		sum, err := current(filepath.Join(profileDir, path))
+		switch {
+		case os.IsNotExist(err):
+			result.Status = StatusMissing
+		case err != nil:
+			return nil, err
+		case sum.SHA256 != recorded.SHA256:
+			result.Status = StatusModified
+		case widerMode(sum.Mode, recorded.Mode):
+			result.Status = StatusPermissions
+			result.Mode, result.ExpectedMode = sum.Mode, recorded.Mode
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Path < results[j].Path })
+	return results, nil
+}
+
+// widerMode reports whether the octal permissions mode grant anything that
+// recorded does not
+func widerMode(mode, recorded string) bool {
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return false
+	}
+	r, err := strconv.ParseUint(recorded, 8, 32)
+	if err != nil {
+		return mode != recorded
+	}
+	return m&^r != 0
+}
+
+// current returns the checksum and permissions of a file, following links
+func current(path string) (Sum, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Sum{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Sum{}, err
+	}
+	hash := sha256.Sum256(content)
+	return Sum{SHA256: hex.EncodeToString(hash[:]), Mode: fmt.Sprintf("%04o", info.Mode().Perm())}, nil
+}
@@ -0,0 +1,158 @@
+// Package chezmoi converts between target paths and chezmoi source state
+// names (dot_, private_, executable_ prefixes and the .tmpl suffix).
+package chezmoi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HomeDirTemplate is the template expression for the user's home directory
+const HomeDirTemplate = "{{ .chezmoi.homeDir }}"
+
+// Attributes are the parts of a source name that chezmoi encodes as
+// prefixes and suffixes
+type Attributes struct {
+	Private    bool
+	Executable bool
+	Empty      bool
+	Template   bool
+}
+
+// unsupportedPrefixes mark source entries that are not plain files or
+// directories (scripts, symlinks, modify scripts, encrypted files)
+var unsupportedPrefixes = []string{"create_", "modify_", "remove_", "run_", "symlink_", "encrypted_"}
+
+// FileName returns the source state name for a file
+func FileName(name string, attrs Attributes) string {
+	var b strings.Builder
+	if attrs.Private {
+		b.WriteString("private_")
+	}
+	if attrs.Empty {
+		b.WriteString("empty_")
+	}
+	if attrs.Executable {
+		b.WriteString("executable_")
+	}
+	b.WriteString(encodeDot(name))
+	if attrs.Template {
+		b.WriteString(".tmpl")
+	}
+	return b.String()
+}
+
+// DirName returns the source state name for a directory
+func DirName(name string, private bool) string {
+	if private {
+		return "private_" + encodeDot(name)
+	}
+	return encodeDot(name)
+}
+
+// ParseFileName decodes a source state file name into the target name and
+// its attributes
+func ParseFileName(source string) (string, Attributes, error) {
+	for _, prefix := range unsupportedPrefixes {
+		if strings.HasPrefix(source, prefix) {
+			return "", Attributes{}, fmt.Errorf("unsupported chezmoi entry: %s", source)
+		}
+	}
+
+	var attrs Attributes
+	name := source
+	if trimmed, ok := strings.CutSuffix(name, ".tmpl"); ok {
+		attrs.Template = true
+		name = trimmed
+	}
+	for {
+		switch {
+		case strings.HasPrefix(name, "private_"):
+			attrs.Private = true
+			name = strings.TrimPrefix(name, "private_")
+		case strings.HasPrefix(name, "readonly_"):
+			name = strings.TrimPrefix(name, "readonly_")
+		case strings.HasPrefix(name, "empty_"):
+			attrs.Empty = true
+			name = strings.TrimPrefix(name, "empty_")
+		case strings.HasPrefix(name, "executable_"):
+			attrs.Executable = true
+			name = strings.TrimPrefix(name, "executable_")
+		default:
+			return decodeDot(name), attrs, nil
+		}
+	}
+}
+
+// ParseDirName decodes a source state directory name into the target name
+// and whether it is private
+func ParseDirName(source string) (string, bool, error) {
+	for _, prefix := range unsupportedPrefixes {
+		if strings.HasPrefix(source, prefix) {
+			return "", false, fmt.Errorf("unsupported chezmoi entry: %s", source)
+		}
+	}
+
+	private := false
+	name := source
+	for {
+		switch {
+		case strings.HasPrefix(name, "exact_"):
+			name = strings.TrimPrefix(name, "exact_")
+		case strings.HasPrefix(name, "private_"):
+			private = true
+			name = strings.TrimPrefix(name, "private_")
+		case strings.HasPrefix(name, "readonly_"):
+			name = strings.TrimPrefix(name, "readonly_")
+		default:
+			return decodeDot(name), private, nil
+		}
+	}
+}
+
+// TargetPath returns the source state path for a path relative to the home
+// directory whose parent directories are all public
+func TargetPath(relPath string) string {
+	parts := strings.Split(relPath, "/")
+	for i, part := range parts {
+		parts[i] = DirName(part, false)
+	}
+	return strings.Join(parts, "/")
+}
+
+// Templatize replaces the home directory in content with the chezmoi
+// template expression. It reports false when the content does not contain
+// the home directory and can be used as a plain file.
+func Templatize(content, homeDir string) (string, bool) {
+	if homeDir == "" || !strings.Contains(content, homeDir) {
+		return content, false
+	}
+	// Escape existing template delimiters so they are written literally
+	content = strings.ReplaceAll(content, "{{", `{{ "{{" }}`)
+	return strings.ReplaceAll(content, homeDir, HomeDirTemplate), true
+}
+
+// Untemplatize reverses Templatize. It reports false when the content uses
+// other template expressions that need chezmoi to render.
+func Untemplatize(content, homeDir string) (string, bool) {
+	content = strings.ReplaceAll(content, HomeDirTemplate, homeDir)
+	literal := strings.ReplaceAll(content, `{{ "{{" }}`, "")
+	if strings.Contains(literal, "{{") {
+		return content, false
+	}
+	return strings.ReplaceAll(content, `{{ "{{" }}`, "{{"), true
+}
+
+func encodeDot(name string) string {
+	if strings.HasPrefix(name, ".") {
+		return "dot_" + name[1:]
+	}
+	return name
+}
+
+func decodeDot(name string) string {
+	if strings.HasPrefix(name, "dot_") {
+		return "." + name[len("dot_"):]
+	}
+	return name
+}
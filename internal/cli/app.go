@@ -2,26 +2,61 @@ package cli
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
-	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/mindmorass/shell-profile-manager/internal/api"
 	"github.com/mindmorass/shell-profile-manager/internal/commands"
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+	"github.com/mindmorass/shell-profile-manager/internal/direnv"
+	"github.com/mindmorass/shell-profile-manager/internal/logging"
+	"github.com/mindmorass/shell-profile-manager/internal/notify"
+	"github.com/mindmorass/shell-profile-manager/internal/plugins"
 	"github.com/mindmorass/shell-profile-manager/internal/profile"
+	"github.com/mindmorass/shell-profile-manager/internal/redact"
 	"github.com/mindmorass/shell-profile-manager/internal/ui"
 )
 
 type App struct {
 	profilesDir string
+	config      *config.Config
+	// logLevel is the level of the messages logged to stderr
+	logLevel slog.Level
+	// noColor is set by --no-color
+	noColor bool
 }
 
-func NewApp(profilesDir string) *App {
+func NewApp(cfg *config.Config) *App {
+	commands.SetProfileRoots(cfg.Roots)
+	commands.SetProfileAliases(cfg.Aliases)
 	return &App{
-		profilesDir: profilesDir,
+		profilesDir: cfg.ProfilesDir,
+		config:      cfg,
+		logLevel:    slog.LevelWarn,
 	}
 }
 
 func (a *App) Run(args []string) error {
+	args, err := a.parseGlobalFlags(args)
+	if err != nil {
+		return err
+	}
+	if err := ui.ConfigureColor(a.config.Color, a.noColor, a.config.Theme); err != nil {
+		ui.PrintWarning(fmt.Sprintf("Ignoring color configuration: %v", err))
+	}
+
+	stateDir, _ := config.StateDir()
+	logFile, err := logging.Setup(a.logLevel, stateDir)
+	if err != nil {
+		slog.Debug("file logging disabled", "error", err)
+	}
+	defer logFile.Close()
+	slog.Debug("running", "args", redactArgs(args), "profiles_dir", a.profilesDir)
+
 	if len(args) == 0 {
 		a.showHelp()
 		return nil
@@ -30,6 +65,10 @@ func (a *App) Run(args []string) error {
 	command := args[0]
 	args = args[1:]
 
+	if ui.Structured() && !supportsStructuredOutput(command, args) {
+		return commands.NewValidationError("'%s' does not support --output (supported: %s)", command, strings.Join(structuredCommands, ", "))
+	}
+
 	switch command {
 	case "init":
 		return a.handleInit(args)
@@ -39,28 +78,277 @@ func (a *App) Run(args []string) error {
 		return a.handleUpdate(args)
 	case "list", "ls":
 		return a.handleList(args)
-	case "select", "use":
+	case "select", "use", "switch":
 		return a.handleSelect(args)
+	case "run":
+		return a.handleRun(args)
 	case "delete", "remove", "rm":
 		return a.handleDelete(args)
+	case "apply":
+		return a.handleApply(args)
 	case "restore":
 		return a.handleRestore(args)
 	case "info", "current", "show":
 		return a.handleInfo(args)
 	case "status":
 		return a.handleStatus(args)
+	case "which":
+		return a.handleWhich(args)
+	case "watch":
+		return a.handleWatch(args)
+	case "daemon":
+		return a.handleDaemon(args)
+	case "serve":
+		return a.handleServe(args)
 	case "sync":
 		return a.handleSync(args)
 	case "dotfiles":
 		return a.handleDotfiles(args)
+	case "doctor", "check":
+		return a.handleDoctor(args)
+	case "log", "history":
+		return a.handleLog(args)
+	case "verify":
+		return a.handleVerify(args)
+	case "guard":
+		return a.handleGuard(args)
+	case "perms", "permissions":
+		return a.handlePerms(args)
+	case "policy", "policies":
+		return a.handlePolicy(args)
+	case "clean":
+		return a.handleClean(args)
+	case "gc":
+		return a.handleGc(args)
+	case "prune":
+		return a.handlePrune(args)
+	case "secrets", "secret":
+		return a.handleSecrets(args)
+	case "dotenv":
+		return a.handleDotenv(args)
+	case "aws":
+		return a.handleAWS(args)
+	case "azure", "az":
+		return a.handleAzure(args)
+	case "gcloud", "gcp":
+		return a.handleGcloud(args)
+	case "kube", "k8s":
+		return a.handleKube(args)
+	case "terraform", "tf":
+		return a.handleTerraform(args)
+	case "feature", "features":
+		return a.handleFeature(args)
+	case "tag", "tags":
+		return a.handleTag(args)
+	case "root", "roots":
+		return a.handleRoot(args)
+	case "preset", "presets":
+		return a.handlePreset(args)
+	case "template", "templates":
+		return a.handleTemplate(args)
+	case "npm", "node":
+		return a.handleNpm(args)
+	case "maven", "mvn":
+		return a.handleMaven(args)
+	case "netrc":
+		return a.handleNetrc(args)
+	case "ansible":
+		return a.handleAnsible(args)
+	case "conda":
+		return a.handleConda(args)
+	case "go", "golang":
+		return a.handleGo(args)
+	case "brew":
+		return a.handleBrew(args)
+	case "direnv":
+		return a.handleDirenv(args)
+	case "gpg":
+		return a.handleGPG(args)
+	case "gh", "github":
+		return a.handleGH(args)
+	case "tmux":
+		return a.handleTmux(args)
+	case "prompt":
+		return a.handlePrompt(args)
+	case "open":
+		return a.handleOpen(args)
+	case "code", "vscode":
+		return a.handleCode(args)
+	case "nix":
+		return a.handleNix(args)
+	case "export":
+		return a.handleExport(args)
+	case "encrypt":
+		return a.handleEncrypt(args)
+	case "link":
+		return a.handleLink(args)
+	case "unlink":
+		return a.handleUnlink(args)
+	case "stow":
+		return a.handleStow(args, false)
+	case "unstow":
+		return a.handleStow(args, true)
+	case "import":
+		return a.handleImport(args)
+	case "env":
+		return a.handleEnv(args)
+	case "conflicts":
+		return a.handleConflicts(args)
+	case "hook":
+		return a.handleHook(args)
+	case "wsl":
+		return a.handleWSL(args)
+	case "completion":
+		return a.handleCompletion(args)
+	case "__complete":
+		return a.handleComplete(args)
+	case "__path":
+		return a.handlePath(args)
+	case "__link-env":
+		return a.handleLinkEnv(args)
 	case "help", "--help", "-h":
 		a.showHelp()
 		return nil
+	case "plugin", "plugins":
+		return a.handlePlugin(args)
 	default:
+		if handled, err := commands.RunPluginCommand(a.profilesDir, command, args); handled {
+			return err
+		}
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", command)
 		a.showHelp()
-		return fmt.Errorf("unknown command: %s", command)
+		return commands.NewValidationError("unknown command: %s", command)
+	}
+}
+
+// parseGlobalFlags applies flags that are valid for every command and
+// returns the remaining arguments. Global flags may appear anywhere before
+// a "--", after which arguments belong to the command (profile run).
+// commandFlags are options of commands that share a name with a global
+// option. After the command name they keep the command's meaning, so the
+// global option has to come before the command there.
+var commandFlags = map[string][]string{
+	"aws":    {"--output"},
+	"export": {"-o", "--output"},
+	"list":   {"--verbose"},
+	"ls":     {"--verbose"},
+}
+
+func commandOwnsFlag(command, flag string) bool {
+	for _, owned := range commandFlags[command] {
+		if owned == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// redactArgs hides the values of token and password options before the
+// arguments are logged
+func redactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		if i > 0 && strings.HasPrefix(args[i-1], "--") && (strings.Contains(args[i-1], "token") || strings.Contains(args[i-1], "password")) {
+			arg = "<redacted>"
+		}
+		redacted[i] = arg
+	}
+	return redacted
+}
+
+func (a *App) parseGlobalFlags(args []string) ([]string, error) {
+	var remaining []string
+	command := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			remaining = append(remaining, args[i:]...)
+			i = len(args)
+		case arg == "-y" || arg == "--yes":
+			ui.SetAssumeYes(true)
+		case arg == "--non-interactive":
+			ui.SetNonInteractive(true)
+		case arg == "--detailed-exitcode":
+			commands.EnableDetailedExitCodes(true)
+		case arg == "--verbose" && !commandOwnsFlag(command, arg):
+			a.logLevel = slog.LevelDebug
+		case arg == "--no-color":
+			a.noColor = true
+		case arg == "--wide":
+			ui.SetWide(true)
+		case arg == "--show-secrets":
+			redact.SetShowSecrets(true)
+		case arg == "--no-cache":
+			commands.UseProfileCache(false)
+		case arg == "-q" || arg == "--quiet":
+			a.logLevel = slog.LevelError
+			ui.SetQuiet(true)
+		case (arg == "-o" || arg == "--output") && !commandOwnsFlag(command, arg):
+			if i+1 >= len(args) {
+				return nil, commands.NewValidationError("%s requires a format (%s)", arg, strings.Join(ui.OutputFormats, ", "))
+			}
+			if err := ui.SetOutputFormat(args[i+1]); err != nil {
+				return nil, commands.NewValidationError("%v", err)
+			}
+			i++
+		case command == "run" && !strings.HasPrefix(arg, "-") && args[i-1] != "-p" && args[i-1] != "--profile":
+			// The command run in the profile starts here; as after "--",
+			// its flags are its own
+			remaining = append(remaining, args[i:]...)
+			i = len(args)
+		default:
+			if command == "" && !strings.HasPrefix(arg, "-") {
+				command = arg
+			}
+			remaining = append(remaining, arg)
+		}
+	}
+	return remaining, nil
+}
+
+// structuredCommands are the commands (and subcommands) whose results can
+// be printed with --output json|yaml
+var structuredCommands = []string{"list", "info", "status", "which", "doctor", "conflicts", "link", "prune", "log", "verify", "update", "direnv status", "daemon status", "guard scan", "perms check", "policy check", "dotenv list", "feature list", "tag list", "root list", "preset list", "template list", "plugin list"}
+
+// commandAliases maps command aliases to the names in structuredCommands
+var commandAliases = map[string]string{
+	"ls": "list", "current": "info", "show": "info", "check": "doctor", "history": "log", "upgrade": "update",
+}
+
+// structuredCommandsHelp lists structuredCommands for the help of
+// --output, wrapped and indented under its description
+func structuredCommandsHelp() string {
+	const indent, width = "                               ", 80
+	var lines []string
+	line := indent
+	for i, command := range structuredCommands {
+		item := command
+		if i < len(structuredCommands)-1 {
+			item += ","
+		}
+		if line != indent && len(line)+1+len(item) > width {
+			lines = append(lines, line)
+			line = indent
+		}
+		if line != indent {
+			line += " "
+		}
+		line += item
+	}
+	return strings.Join(append(lines, line), "\n")
+}
+
+func supportsStructuredOutput(command string, args []string) bool {
+	if name, ok := commandAliases[command]; ok {
+		command = name
+	}
+	for _, supported := range structuredCommands {
+		if supported == command || (len(args) > 0 && supported == command+" "+args[0]) {
+			return true
+		}
 	}
+	return false
 }
 
 func (a *App) handleInit(args []string) error {
@@ -90,7 +378,7 @@ func (a *App) handleInit(args []string) error {
 
 func (a *App) handleCreate(args []string) error {
 	opts := commands.CreateOptions{
-		Template: "basic",
+		Commit: a.config.AutoCommit,
 	}
 
 	// Track if any non-interactive flags are provided
@@ -106,12 +394,20 @@ func (a *App) handleCreate(args []string) error {
 		case "-f", "--force":
 			opts.Force = true
 			hasNonInteractiveFlags = true
+		case "--no-commit":
+			opts.Commit = false
 		case "-t", "--template":
 			if i+1 < len(args) {
 				opts.Template = args[i+1]
 				i++
 				hasNonInteractiveFlags = true
 			}
+		case "--preset":
+			if i+1 < len(args) {
+				opts.Preset = args[i+1]
+				i++
+				hasNonInteractiveFlags = true
+			}
 		case "--git-name":
 			if i+1 < len(args) {
 				opts.GitName = args[i+1]
@@ -132,6 +428,13 @@ func (a *App) handleCreate(args []string) error {
 		case "--dry-run":
 			opts.DryRun = true
 			hasNonInteractiveFlags = true
+		case "--out":
+			if i+1 < len(args) {
+				opts.Out = args[i+1]
+				opts.DryRun = true
+				i++
+				hasNonInteractiveFlags = true
+			}
 		case "--init-git":
 			opts.InitGit = true
 			hasNonInteractiveFlags = true
@@ -142,6 +445,67 @@ func (a *App) handleCreate(args []string) error {
 				i++
 				hasNonInteractiveFlags = true
 			}
+		case "--feature":
+			if i+1 < len(args) {
+				opts.Features = append(opts.Features, strings.Split(args[i+1], ",")...)
+				i++
+				hasNonInteractiveFlags = true
+			}
+		case "--description":
+			if i+1 < len(args) {
+				opts.Description = args[i+1]
+				i++
+				hasNonInteractiveFlags = true
+			}
+		case "--tag":
+			if i+1 < len(args) {
+				opts.Tags = append(opts.Tags, strings.Split(args[i+1], ",")...)
+				i++
+				hasNonInteractiveFlags = true
+			}
+		case "--disable-feature":
+			if i+1 < len(args) {
+				opts.DisableFeatures = append(opts.DisableFeatures, strings.Split(args[i+1], ",")...)
+				i++
+				hasNonInteractiveFlags = true
+			}
+		case "--secrets-provider":
+			if i+1 < len(args) {
+				opts.SecretsProvider = args[i+1]
+				i++
+				hasNonInteractiveFlags = true
+			}
+		case "--owner":
+			if i+1 < len(args) {
+				opts.Owner = args[i+1]
+				i++
+				hasNonInteractiveFlags = true
+			}
+		case "--extends":
+			if i+1 < len(args) {
+				opts.Extends = args[i+1]
+				i++
+				hasNonInteractiveFlags = true
+			}
+		case "--root":
+			if i+1 < len(args) {
+				opts.Root = args[i+1]
+				i++
+				hasNonInteractiveFlags = true
+			}
+		case "--var":
+			if i+1 < len(args) {
+				key, value, ok := strings.Cut(args[i+1], "=")
+				if !ok {
+					return commands.NewValidationError("--var expects key=value, got: %s", args[i+1])
+				}
+				if opts.Vars == nil {
+					opts.Vars = map[string]string{}
+				}
+				opts.Vars[key] = value
+				i++
+				hasNonInteractiveFlags = true
+			}
 		default:
 			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
 				opts.ProfileName = arg
@@ -149,20 +513,30 @@ func (a *App) handleCreate(args []string) error {
 		}
 	}
 
-	if opts.ProfileName == "" {
-		return fmt.Errorf("profile name is required")
-	}
-
 	// If no non-interactive flags provided, enable interactive mode
-	if !hasNonInteractiveFlags {
+	if !hasNonInteractiveFlags && ui.IsInteractive() {
 		opts.Interactive = true
 	}
 
+	// The wizard asks for the name
+	if opts.ProfileName == "" && !opts.Interactive {
+		return commands.NewValidationError("profile name is required")
+	}
+
+	if opts.Interactive && !ui.IsInteractive() {
+		return commands.NewValidationError("--interactive requires a terminal; pass configuration as flags instead")
+	}
+
 	return commands.CreateProfile(a.profilesDir, opts)
 }
 
 func (a *App) handleUpdate(args []string) error {
-	opts := commands.UpdateOptions{}
+	opts := commands.UpdateOptions{
+		AllowDirenv:   a.config.AutoAllowDirenv,
+		Commit:        a.config.AutoCommit,
+		EnforcePolicy: a.config.PolicyEnforce,
+		Jobs:          a.config.Jobs,
+	}
 
 	// Parse arguments
 	for i := 0; i < len(args); i++ {
@@ -175,8 +549,36 @@ func (a *App) handleUpdate(args []string) error {
 			opts.Force = true
 		case "--dry-run":
 			opts.DryRun = true
+		case "--out":
+			if i+1 < len(args) {
+				opts.Out = args[i+1]
+				opts.DryRun = true
+				i++
+			}
+		case "--all":
+			opts.All = true
+		case "--tag":
+			if i+1 < len(args) {
+				opts.Tags = append(opts.Tags, strings.Split(args[i+1], ",")...)
+				i++
+			}
+		case "-j", "--jobs":
+			if i+1 < len(args) {
+				jobs, err := strconv.Atoi(args[i+1])
+				if err != nil || jobs < 1 {
+					return commands.NewValidationError("--jobs expects a positive number, got: %s", args[i+1])
+				}
+				opts.Jobs = jobs
+				i++
+			}
 		case "--no-backup":
 			opts.NoBackup = true
+		case "--allow-direnv":
+			opts.AllowDirenv = true
+		case "--no-hooks":
+			opts.NoHooks = true
+		case "--no-commit":
+			opts.Commit = false
 		default:
 			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
 				opts.ProfileName = arg
@@ -194,7 +596,8 @@ func (a *App) handleList(args []string) error {
 	}
 
 	// Parse arguments
-	for _, arg := range args {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
 		switch arg {
 		case "-v", "--verbose":
 			opts.Verbose = true
@@ -206,6 +609,11 @@ func (a *App) handleList(args []string) error {
 			opts.Interactive = true
 		case "--no-interactive":
 			opts.Interactive = false
+		case "--tag":
+			if i+1 < len(args) {
+				opts.Tags = append(opts.Tags, strings.Split(args[i+1], ",")...)
+				i++
+			}
 		case "-h", "--help":
 			a.showListHelp()
 			return nil
@@ -229,6 +637,12 @@ func (a *App) handleDelete(args []string) error {
 			opts.Force = true
 		case "--dry-run":
 			opts.DryRun = true
+		case "--out":
+			if i+1 < len(args) {
+				opts.Out = args[i+1]
+				opts.DryRun = true
+				i++
+			}
 		case "--no-interactive":
 			// This is handled in DeleteProfile - if profile name is provided, interactive is skipped
 		default:
@@ -242,7 +656,37 @@ func (a *App) handleDelete(args []string) error {
 }
 
 func (a *App) handleRestore(args []string) error {
-	return fmt.Errorf("restore command is not yet implemented in Go")
+	opts := commands.RestoreOptions{}
+
+	// Parse arguments
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			a.showRestoreHelp()
+			return nil
+		case "-f", "--force":
+			opts.Force = true
+		case "--dry-run":
+			opts.DryRun = true
+		case "--file":
+			if i+1 < len(args) {
+				opts.File = args[i+1]
+				i++
+			}
+		case "--backup-date":
+			if i+1 < len(args) {
+				opts.BackupDate = args[i+1]
+				i++
+			}
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	return commands.RestoreBackup(a.profilesDir, opts)
 }
 
 func (a *App) handleSync(args []string) error {
@@ -260,7 +704,7 @@ func (a *App) handleSync(args []string) error {
 		return nil
 	}
 
-	opts := commands.GitOptions{}
+	opts := commands.GitOptions{Jobs: a.config.Jobs}
 
 	// Parse common options
 	for i := 0; i < len(args); i++ {
@@ -268,6 +712,22 @@ func (a *App) handleSync(args []string) error {
 		switch arg {
 		case "--force", "-f":
 			opts.Force = true
+		case "--all":
+			opts.All = true
+		case "--tag":
+			if i+1 < len(args) {
+				opts.Tags = append(opts.Tags, strings.Split(args[i+1], ",")...)
+				i++
+			}
+		case "-j", "--jobs":
+			if i+1 < len(args) {
+				jobs, err := strconv.Atoi(args[i+1])
+				if err != nil || jobs < 1 {
+					return commands.NewValidationError("--jobs expects a positive number, got: %s", args[i+1])
+				}
+				opts.Jobs = jobs
+				i++
+			}
 		case "--remote":
 			if i+1 < len(args) {
 				opts.Remote = args[i+1]
@@ -292,6 +752,10 @@ func (a *App) handleSync(args []string) error {
 		}
 	}
 
+	if opts.All || len(opts.Tags) > 0 {
+		return commands.SyncAllProfiles(a.profilesDir, syncCommand, opts)
+	}
+
 	// Status command can work without profile name (shows all profiles)
 	if syncCommand == "status" && opts.ProfileName == "" {
 		return commands.GetGitStatus(a.profilesDir, opts)
@@ -299,34 +763,17 @@ func (a *App) handleSync(args []string) error {
 
 	// For other commands, if no profile name provided and not --no-interactive, show interactive selection
 	if opts.ProfileName == "" && !noInteractive {
-		// Get list of profiles
-		entries, err := os.ReadDir(a.profilesDir)
-		if err != nil {
-			return fmt.Errorf("failed to read profiles directory: %w", err)
-		}
-
-		var profiles []string
-		for _, entry := range entries {
-			if entry.IsDir() && entry.Name() != ".git" {
-				profilePath := filepath.Join(a.profilesDir, entry.Name())
-				envrcPath := filepath.Join(profilePath, ".envrc")
-				if _, err := os.Stat(envrcPath); err == nil {
-					profiles = append(profiles, entry.Name())
-				}
-			}
-		}
-
-		if len(profiles) == 0 {
-			return fmt.Errorf("no profiles found")
-		}
-
-		selected, err := ui.SelectProfile(profiles, fmt.Sprintf("Select profile for sync %s:", syncCommand))
+		selected, err := commands.PromptForProfile(a.profilesDir, fmt.Sprintf("Select profile for sync %s:", syncCommand))
 		if err != nil {
 			return err
 		}
 		opts.ProfileName = selected
 	}
 
+	if opts.ProfileName == "" {
+		return commands.NewValidationError("profile name is required")
+	}
+
 	switch syncCommand {
 	case "init":
 		// Parse remote if provided
@@ -360,7 +807,7 @@ func (a *App) handleSync(args []string) error {
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown sync command: %s\n\n", syncCommand)
 		a.showSyncHelp()
-		return fmt.Errorf("unknown sync command: %s", syncCommand)
+		return commands.NewValidationError("unknown sync command: %s", syncCommand)
 	}
 }
 
@@ -370,8 +817,27 @@ func (a *App) handleInfo(_args []string) error {
 	return pm.ShowInfo()
 }
 
+func (a *App) handleWhich(args []string) error {
+	opts := commands.WhichOptions{}
+
+	// Parse arguments
+	for _, arg := range args {
+		switch arg {
+		case "-h", "--help":
+			a.showWhichHelp()
+			return nil
+		case "--name":
+			opts.Name = true
+		case "--path":
+			opts.Path = true
+		}
+	}
+
+	return commands.ShowActiveProfile(a.profilesDir, opts)
+}
+
 func (a *App) handleSelect(args []string) error {
-	opts := commands.SelectOptions{}
+	opts := commands.SelectOptions{Default: a.config.DefaultProfile}
 
 	// Parse arguments
 	for i := 0; i < len(args); i++ {
@@ -382,6 +848,8 @@ func (a *App) handleSelect(args []string) error {
 			return nil
 		case "--allow-direnv":
 			opts.AllowDirenv = true
+		case "--pick":
+			opts.Pick = true
 		default:
 			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
 				opts.ProfileName = arg
@@ -392,549 +860,5029 @@ func (a *App) handleSelect(args []string) error {
 	return commands.SelectProfile(a.profilesDir, opts)
 }
 
-func (a *App) handleStatus(_args []string) error {
-	// Check if direnv is installed and show status
-	return profile.ShowDirenvStatus()
-}
+func (a *App) handleRun(args []string) error {
+	opts := commands.RunOptions{Default: a.config.DefaultProfile}
 
-func (a *App) handleDotfiles(args []string) error {
-	if len(args) == 0 {
-		a.showDotfilesHelp()
-		return nil
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			opts.Command = args[i+1:]
+			i = len(args)
+		case arg == "--profile" || arg == "-p":
+			if i+1 < len(args) {
+				opts.ProfileName = args[i+1]
+				i++
+			}
+		case arg == "-h" || arg == "--help":
+			a.showRunHelp()
+			return nil
+		case !strings.HasPrefix(arg, "-"):
+			// Without "--" the command starts at the first argument that
+			// is not an option
+			opts.Command = args[i:]
+			i = len(args)
+		}
 	}
 
-	subcommand := args[0]
-	args = args[1:]
+	return commands.RunInProfile(a.profilesDir, opts)
+}
 
-	opts := commands.DotfilesOptions{}
+func (a *App) handleStatus(args []string) error {
+	opts := commands.AlertOptions{Within: a.config.ExpiryWarning, Notify: a.config.Notify}
 
-	// Parse common options
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
 		switch arg {
-		case "--profile", "-p":
+		case "-h", "--help":
+			a.showStatusHelp()
+			return nil
+		case "--notify":
+			if opts.Notify == "" {
+				opts.Notify = notify.Auto
+			}
+		case "--within":
 			if i+1 < len(args) {
-				opts.ProfileName = args[i+1]
+				within, err := time.ParseDuration(args[i+1])
+				if err != nil || within <= 0 {
+					return commands.NewValidationError("--within expects a duration such as 2h, got: %s", args[i+1])
+				}
+				opts.Within = within
 				i++
 			}
-		case "--file", "-f":
+		}
+	}
+	if opts.Notify != "" && !notify.Valid(opts.Notify) {
+		return commands.NewValidationError("unknown notifier: %s (use auto, terminal-notifier, osascript, or notify-send)", opts.Notify)
+	}
+
+	if ui.Structured() {
+		status := profile.CurrentDirenvStatus()
+		if status.Installed {
+			states, err := commands.DirenvStates(a.profilesDir)
+			if err != nil {
+				return err
+			}
+			status.Profiles = states
+		}
+		alerts, err := commands.ProfileAlerts(a.profilesDir, opts)
+		if err != nil {
+			return err
+		}
+		status.Alerts = alerts
+		if opts.Notify != "" {
+			commands.NotifyAlerts(alerts, opts.Notify)
+		}
+		return ui.Render(status)
+	}
+
+	// Check if direnv is installed and show status
+	if err := profile.ShowDirenvStatus(); err != nil {
+		return err
+	}
+	if direnv.Installed() {
+		fmt.Println()
+		fmt.Println("=== Profiles ===")
+		fmt.Println()
+		if err := commands.DirenvStatus(a.profilesDir, commands.DirenvOptions{All: true}); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("=== Alerts ===")
+	fmt.Println()
+	return commands.ShowAlerts(a.profilesDir, opts)
+}
+
+func (a *App) handleWatch(args []string) error {
+	opts := commands.WatchOptions{AlertOptions: commands.AlertOptions{Within: a.config.ExpiryWarning, Notify: a.config.Notify}}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			a.showWatchHelp()
+			return nil
+		case "--interval":
 			if i+1 < len(args) {
-				opts.FileName = args[i+1]
+				interval, err := time.ParseDuration(args[i+1])
+				if err != nil || interval < time.Second {
+					return commands.NewValidationError("--interval expects a duration such as 10m, got: %s", args[i+1])
+				}
+				opts.Interval = interval
 				i++
 			}
-		case "--editor", "-e":
+		case "--within":
 			if i+1 < len(args) {
-				opts.Editor = args[i+1]
+				within, err := time.ParseDuration(args[i+1])
+				if err != nil || within <= 0 {
+					return commands.NewValidationError("--within expects a duration such as 2h, got: %s", args[i+1])
+				}
+				opts.Within = within
 				i++
 			}
-		case "-h", "--help":
-			a.showDotfilesHelp()
-			return nil
-		default:
-			// First non-flag argument could be profile name
-			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
-				opts.ProfileName = arg
+		case "--notifier":
+			if i+1 < len(args) {
+				opts.Notify = args[i+1]
+				i++
 			}
+		case "--once":
+			opts.Once = true
 		}
 	}
-
-	switch subcommand {
-	case "list", "ls":
-		return commands.ListDotfiles(a.profilesDir, opts)
-	case "edit", "e":
-		return commands.EditDotfile(a.profilesDir, opts)
-	case "help", "-h", "--help":
-		a.showDotfilesHelp()
-		return nil
-	default:
-		fmt.Fprintf(os.Stderr, "Unknown dotfiles command: %s\n\n", subcommand)
-		a.showDotfilesHelp()
-		return fmt.Errorf("unknown dotfiles command: %s", subcommand)
+	if opts.Notify != "" && !notify.Valid(opts.Notify) {
+		return commands.NewValidationError("unknown notifier: %s (use auto, terminal-notifier, osascript, or notify-send)", opts.Notify)
 	}
+
+	return commands.WatchProfiles(a.profilesDir, opts)
 }
 
-func (a *App) showHelp() {
-	helpText := `Workspace Profile Manager
+func (a *App) handleServe(args []string) error {
+	opts := api.Options{Within: a.config.ExpiryWarning, Roots: a.config.Roots, Aliases: a.config.Aliases}
 
-Manage workspace profiles with direnv for environment-specific configurations.
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			a.showServeHelp()
+			return nil
+		case "--socket":
+			if i+1 < len(args) {
+				opts.Socket = config.ExpandPath(args[i+1])
+				i++
+			}
+		case "--addr":
+			if i+1 < len(args) {
+				opts.Addr = args[i+1]
+				i++
+			}
+		case "--stdio":
+			opts.Stdio = true
+		}
+	}
+	if opts.Socket != "" && opts.Addr != "" {
+		return commands.NewValidationError("--socket and --addr cannot be used together")
+	}
+	if opts.Stdio && (opts.Socket != "" || opts.Addr != "") {
+		return commands.NewValidationError("--stdio cannot be used with --socket or --addr")
+	}
+
+	return api.Serve(a.profilesDir, opts)
+}
+
+func (a *App) handleDaemon(args []string) error {
+	if len(args) == 0 {
+		a.showDaemonHelp()
+		return nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.DaemonOptions{}
+	// The config is read again by the daemon, so only options given here
+	// are passed on to it
+	if subcommand == "run" {
+		opts.AlertOptions = commands.AlertOptions{Within: a.config.ExpiryWarning, Notify: a.config.Notify}
+		opts.BackupKeep, opts.BackupMaxAge = a.config.BackupKeep, a.config.BackupMaxAge
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			a.showDaemonHelp()
+			return nil
+		case "--interval", "--maintenance", "--within":
+			if i+1 < len(args) {
+				duration, err := time.ParseDuration(args[i+1])
+				if err != nil || duration < time.Second {
+					return commands.NewValidationError("%s expects a duration such as 10m, got: %s", arg, args[i+1])
+				}
+				switch arg {
+				case "--interval":
+					opts.Interval = duration
+				case "--maintenance":
+					opts.Maintenance = duration
+				default:
+					opts.Within = duration
+				}
+				i++
+			}
+		case "--notifier":
+			if i+1 < len(args) {
+				opts.Notify = args[i+1]
+				i++
+			}
+		case "--print":
+			opts.Print = true
+		}
+	}
+	if opts.Notify != "" && !notify.Valid(opts.Notify) {
+		return commands.NewValidationError("unknown notifier: %s (use auto, terminal-notifier, osascript, or notify-send)", opts.Notify)
+	}
+
+	switch subcommand {
+	case "start":
+		return commands.StartDaemon(opts)
+	case "stop":
+		return commands.StopDaemon()
+	case "status":
+		return commands.DaemonStatus()
+	case "install":
+		return commands.InstallDaemon(opts)
+	case "run":
+		return commands.RunDaemon(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showDaemonHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown daemon command: %s\n\n", subcommand)
+		a.showDaemonHelp()
+		return commands.NewValidationError("unknown daemon command: %s", subcommand)
+	}
+}
+
+func (a *App) handleDoctor(args []string) error {
+	opts := commands.DoctorOptions{}
+
+	// Parse arguments
+	for _, arg := range args {
+		switch arg {
+		case "-h", "--help":
+			a.showDoctorHelp()
+			return nil
+		case "--fix":
+			opts.Fix = true
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	return commands.RunDoctor(a.profilesDir, opts)
+}
+
+func (a *App) handleLog(args []string) error {
+	opts := commands.LogOptions{}
+
+	// Parse arguments
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			a.showLogHelp()
+			return nil
+		case "-n", "--limit":
+			if i+1 < len(args) {
+				limit, err := strconv.Atoi(args[i+1])
+				if err != nil {
+					return commands.NewValidationError("--limit expects a number, got: %s", args[i+1])
+				}
+				opts.Limit = limit
+				i++
+			}
+		case "--git":
+			opts.Git = true
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	return commands.ShowLog(a.profilesDir, opts)
+}
+
+func (a *App) handleVerify(args []string) error {
+	opts := commands.VerifyOptions{}
+
+	// Parse arguments
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			a.showVerifyHelp()
+			return nil
+		case "--accept":
+			opts.Accept = true
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	return commands.VerifyProfile(a.profilesDir, opts)
+}
+
+func (a *App) handlePerms(args []string) error {
+	if len(args) == 0 {
+		args = []string{"check"}
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.PermsOptions{Jobs: a.config.Jobs}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--all", "-a":
+			opts.All = true
+		case "-j", "--jobs":
+			if i+1 < len(args) {
+				jobs, err := strconv.Atoi(args[i+1])
+				if err != nil || jobs < 1 {
+					return commands.NewValidationError("--jobs expects a positive number, got: %s", args[i+1])
+				}
+				opts.Jobs = jobs
+				i++
+			}
+		case "-h", "--help":
+			a.showPermsHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	switch subcommand {
+	case "check":
+		return commands.CheckPermissions(a.profilesDir, opts)
+	case "fix":
+		return commands.FixPermissions(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showPermsHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown perms command: %s\n\n", subcommand)
+		a.showPermsHelp()
+		return commands.NewValidationError("unknown perms command: %s", subcommand)
+	}
+}
+
+func (a *App) handleClean(args []string) error {
+	opts := commands.CleanOptions{Jobs: a.config.Jobs}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			a.showCleanHelp()
+			return nil
+		case "--all", "-a":
+			opts.All = true
+		case "-j", "--jobs":
+			if i+1 < len(args) {
+				jobs, err := strconv.Atoi(args[i+1])
+				if err != nil || jobs < 1 {
+					return commands.NewValidationError("--jobs expects a positive number, got: %s", args[i+1])
+				}
+				opts.Jobs = jobs
+				i++
+			}
+		case "--dry-run":
+			opts.DryRun = true
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	return commands.CleanProfile(a.profilesDir, opts)
+}
+
+func (a *App) handleGc(args []string) error {
+	opts := commands.GcOptions{BackupKeep: a.config.BackupKeep, BackupMaxAge: a.config.BackupMaxAge}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			a.showGcHelp()
+			return nil
+		case "--dry-run":
+			opts.DryRun = true
+		case "--keep":
+			if i+1 < len(args) {
+				keep, err := strconv.Atoi(args[i+1])
+				if err != nil || keep < 1 {
+					return commands.NewValidationError("--keep expects a positive number, got: %s", args[i+1])
+				}
+				opts.BackupKeep = keep
+				i++
+			}
+		case "--max-age":
+			if i+1 < len(args) {
+				days, err := strconv.Atoi(args[i+1])
+				if err != nil || days < 1 {
+					return commands.NewValidationError("--max-age expects a positive number of days, got: %s", args[i+1])
+				}
+				opts.BackupMaxAge = days
+				i++
+			}
+		}
+	}
+
+	return commands.CollectGarbage(a.profilesDir, opts)
+}
+
+func (a *App) handlePrune(args []string) error {
+	opts := commands.PruneOptions{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			a.showPruneHelp()
+			return nil
+		case "--orphans":
+			opts.Orphans = true
+		case "--adopt", "--archive", "--delete":
+			action := strings.TrimPrefix(arg, "--")
+			if opts.Action != "" && opts.Action != action {
+				return commands.NewValidationError("--%s and %s cannot be used together", opts.Action, arg)
+			}
+			opts.Action = action
+		case "-f", "--force":
+			opts.Force = true
+		case "--dry-run":
+			opts.DryRun = true
+		default:
+			if !strings.HasPrefix(arg, "-") {
+				opts.Names = append(opts.Names, arg)
+			}
+		}
+	}
+
+	return commands.PruneProfiles(a.profilesDir, opts)
+}
+
+func (a *App) handlePolicy(args []string) error {
+	if len(args) == 0 {
+		args = []string{"check"}
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.PolicyOptions{Jobs: a.config.Jobs}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--all", "-a":
+			opts.All = true
+		case "-j", "--jobs":
+			if i+1 < len(args) {
+				jobs, err := strconv.Atoi(args[i+1])
+				if err != nil || jobs < 1 {
+					return commands.NewValidationError("--jobs expects a positive number, got: %s", args[i+1])
+				}
+				opts.Jobs = jobs
+				i++
+			}
+		case "-h", "--help":
+			a.showPolicyHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	switch subcommand {
+	case "check":
+		return commands.CheckPolicy(a.profilesDir, opts)
+	case "update":
+		return commands.UpdatePolicy()
+	case "help", "-h", "--help":
+		a.showPolicyHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown policy command: %s\n\n", subcommand)
+		a.showPolicyHelp()
+		return commands.NewValidationError("unknown policy command: %s", subcommand)
+	}
+}
+
+func (a *App) handleGuard(args []string) error {
+	if len(args) == 0 {
+		a.showGuardHelp()
+		return nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.GuardOptions{}
+	scanOpts := commands.GuardScanOptions{Input: os.Stdin}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--hook":
+			if i+1 < len(args) {
+				opts.Hooks = append(opts.Hooks, strings.Split(args[i+1], ",")...)
+				i++
+			}
+		case "--force", "-f":
+			opts.Force = true
+		case "--pre-push":
+			scanOpts.PrePush = true
+		case "--pre-commit":
+			scanOpts.PrePush = false
+		case "--rules":
+			if i+1 < len(args) {
+				scanOpts.Rules = append(scanOpts.Rules, args[i+1])
+				i++
+			}
+		case "-h", "--help":
+			a.showGuardHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	switch subcommand {
+	case "install":
+		return commands.InstallGuard(a.profilesDir, opts)
+	case "uninstall", "remove":
+		return commands.UninstallGuard(a.profilesDir, opts)
+	case "scan":
+		return commands.ScanGuard(scanOpts)
+	case "help", "-h", "--help":
+		a.showGuardHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown guard command: %s\n\n", subcommand)
+		a.showGuardHelp()
+		return commands.NewValidationError("unknown guard command: %s", subcommand)
+	}
+}
+
+func (a *App) handleDotfiles(args []string) error {
+	if len(args) == 0 {
+		a.showDotfilesHelp()
+		return nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.DotfilesOptions{}
+
+	// Parse common options
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--profile", "-p":
+			if i+1 < len(args) {
+				opts.ProfileName = args[i+1]
+				i++
+			}
+		case "--file", "-f":
+			if i+1 < len(args) {
+				opts.FileName = args[i+1]
+				i++
+			}
+		case "--editor", "-e":
+			if i+1 < len(args) {
+				opts.Editor = args[i+1]
+				i++
+			}
+		case "-h", "--help":
+			a.showDotfilesHelp()
+			return nil
+		default:
+			// First non-flag argument could be profile name
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	switch subcommand {
+	case "list", "ls":
+		return commands.ListDotfiles(a.profilesDir, opts)
+	case "edit", "e":
+		return commands.EditDotfile(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showDotfilesHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown dotfiles command: %s\n\n", subcommand)
+		a.showDotfilesHelp()
+		return commands.NewValidationError("unknown dotfiles command: %s", subcommand)
+	}
+}
+
+func (a *App) handleSecrets(args []string) error {
+	if len(args) == 0 {
+		a.showSecretsHelp()
+		return nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.SecretsOptions{}
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--profile", "-p":
+			if i+1 < len(args) {
+				opts.ProfileName = args[i+1]
+				i++
+			}
+		case "--address":
+			if i+1 < len(args) {
+				opts.Address = args[i+1]
+				i++
+			}
+		case "--namespace":
+			if i+1 < len(args) {
+				opts.Namespace = args[i+1]
+				i++
+			}
+		case "--role":
+			if i+1 < len(args) {
+				opts.Role = args[i+1]
+				i++
+			}
+		case "--auth-method":
+			if i+1 < len(args) {
+				opts.AuthMethod = args[i+1]
+				i++
+			}
+		case "--server-url":
+			if i+1 < len(args) {
+				opts.ServerURL = args[i+1]
+				i++
+			}
+		case "--service":
+			if i+1 < len(args) {
+				opts.Service = args[i+1]
+				i++
+			}
+		case "-h", "--help":
+			a.showSecretsHelp()
+			return nil
+		default:
+			if !strings.HasPrefix(arg, "-") {
+				positional = append(positional, arg)
+			}
+		}
+	}
+
+	// The profile name comes first unless it was given with --profile
+	if opts.ProfileName == "" && len(positional) > 0 {
+		opts.ProfileName = positional[0]
+		positional = positional[1:]
+	}
+	if len(positional) > 0 {
+		opts.VarName = positional[0]
+	}
+	if len(positional) > 1 {
+		opts.Ref = positional[1]
+	}
+
+	switch subcommand {
+	case "vault":
+		return commands.ConfigureVault(a.profilesDir, opts)
+	case "bws", "bitwarden":
+		return commands.ConfigureBitwarden(a.profilesDir, opts)
+	case "keychain":
+		return commands.ConfigureKeychain(a.profilesDir, opts)
+	case "wincred":
+		return commands.ConfigureWinCred(a.profilesDir, opts)
+	case "read-credential":
+		// The credential name is the only positional argument
+		return commands.ReadCredential(opts.ProfileName)
+	case "store":
+		return commands.StoreSecret(a.profilesDir, opts)
+	case "set", "add":
+		return commands.SetSecret(a.profilesDir, opts)
+	case "remove", "rm", "unset":
+		return commands.RemoveSecret(a.profilesDir, opts)
+	case "list", "ls":
+		return commands.ListSecrets(a.profilesDir, opts)
+	case "check":
+		return commands.CheckSecrets(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showSecretsHelp()
+		return nil
+	default:
+		if _, ok := plugins.FindSecretsProvider(subcommand); ok {
+			return commands.ConfigurePluginProvider(a.profilesDir, subcommand, opts)
+		}
+		fmt.Fprintf(os.Stderr, "Unknown secrets command: %s\n\n", subcommand)
+		a.showSecretsHelp()
+		return commands.NewValidationError("unknown secrets command: %s", subcommand)
+	}
+}
+
+func (a *App) handleDotenv(args []string) error {
+	if len(args) == 0 {
+		a.showDotenvHelp()
+		return nil
+	}
+
+	// Both 'dotenv set <profile>' and 'dotenv <profile> set' are accepted
+	dotenvCommands := []string{"set", "add", "unset", "remove", "rm", "list", "ls"}
+	if len(args) > 1 && !slices.Contains(dotenvCommands, args[0]) && slices.Contains(dotenvCommands, args[1]) {
+		args = append([]string{args[1], "--profile", args[0]}, args[2:]...)
+	}
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.DotenvOptions{}
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--profile", "-p":
+			if i+1 < len(args) {
+				opts.ProfileName = args[i+1]
+				i++
+			}
+		case "--secret":
+			if i+1 < len(args) {
+				opts.SecretRef = args[i+1]
+				i++
+			}
+		case "-h", "--help":
+			a.showDotenvHelp()
+			return nil
+		default:
+			if !strings.HasPrefix(arg, "-") {
+				positional = append(positional, arg)
+			}
+		}
+	}
+
+	// The profile name comes first unless it was given with --profile;
+	// list takes nothing else
+	if opts.ProfileName == "" && len(positional) > 0 && !strings.Contains(positional[0], "=") &&
+		(len(positional) > 1 || subcommand == "list" || subcommand == "ls") {
+		opts.ProfileName = positional[0]
+		positional = positional[1:]
+	}
+	opts.Vars = positional
+
+	switch subcommand {
+	case "set", "add":
+		return commands.SetDotenv(a.profilesDir, opts)
+	case "unset", "remove", "rm":
+		return commands.UnsetDotenv(a.profilesDir, opts)
+	case "list", "ls":
+		return commands.ListDotenv(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showDotenvHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown dotenv command: %s\n\n", subcommand)
+		a.showDotenvHelp()
+		return commands.NewValidationError("unknown dotenv command: %s", subcommand)
+	}
+}
+
+func (a *App) handleAWS(args []string) error {
+	if len(args) == 0 {
+		a.showAWSHelp()
+		return nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.AWSOptions{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--aws-profile":
+			if i+1 < len(args) {
+				opts.AWSProfile = args[i+1]
+				i++
+			}
+		case "--region":
+			if i+1 < len(args) {
+				opts.Region = args[i+1]
+				i++
+			}
+		case "--output":
+			if i+1 < len(args) {
+				opts.Output = args[i+1]
+				i++
+			}
+		case "--sso-start-url":
+			if i+1 < len(args) {
+				opts.SSOStartURL = args[i+1]
+				i++
+			}
+		case "--sso-region":
+			if i+1 < len(args) {
+				opts.SSORegion = args[i+1]
+				i++
+			}
+		case "--sso-session":
+			if i+1 < len(args) {
+				opts.SSOSession = args[i+1]
+				i++
+			}
+		case "--account-id":
+			if i+1 < len(args) {
+				opts.SSOAccountID = args[i+1]
+				i++
+			}
+		case "--role-name":
+			if i+1 < len(args) {
+				opts.SSORoleName = args[i+1]
+				i++
+			}
+		case "--login":
+			opts.Login = true
+		case "--no-login":
+			opts.NoLogin = true
+		case "-h", "--help":
+			a.showAWSHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	switch subcommand {
+	case "setup":
+		return commands.SetupAWS(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showAWSHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown aws command: %s\n\n", subcommand)
+		a.showAWSHelp()
+		return commands.NewValidationError("unknown aws command: %s", subcommand)
+	}
+}
+
+func (a *App) handleAzure(args []string) error {
+	if len(args) == 0 {
+		a.showAzureHelp()
+		return nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.AzureOptions{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--tenant":
+			if i+1 < len(args) {
+				opts.Tenant = args[i+1]
+				i++
+			}
+		case "--subscription":
+			if i+1 < len(args) {
+				opts.Subscription = args[i+1]
+				i++
+			}
+		case "--location":
+			if i+1 < len(args) {
+				opts.Location = args[i+1]
+				i++
+			}
+		case "--group":
+			if i+1 < len(args) {
+				opts.Group = args[i+1]
+				i++
+			}
+		case "--login":
+			opts.Login = true
+		case "--no-login":
+			opts.NoLogin = true
+		case "-h", "--help":
+			a.showAzureHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	switch subcommand {
+	case "setup":
+		return commands.SetupAzure(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showAzureHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown azure command: %s\n\n", subcommand)
+		a.showAzureHelp()
+		return commands.NewValidationError("unknown azure command: %s", subcommand)
+	}
+}
+
+func (a *App) handleGcloud(args []string) error {
+	if len(args) == 0 {
+		a.showGcloudHelp()
+		return nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.GcloudOptions{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--config":
+			if i+1 < len(args) {
+				opts.ConfigName = args[i+1]
+				i++
+			}
+		case "--project":
+			if i+1 < len(args) {
+				opts.Project = args[i+1]
+				i++
+			}
+		case "--account":
+			if i+1 < len(args) {
+				opts.Account = args[i+1]
+				i++
+			}
+		case "--region":
+			if i+1 < len(args) {
+				opts.Region = args[i+1]
+				i++
+			}
+		case "--zone":
+			if i+1 < len(args) {
+				opts.Zone = args[i+1]
+				i++
+			}
+		case "-h", "--help":
+			a.showGcloudHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	switch subcommand {
+	case "setup":
+		return commands.SetupGcloud(a.profilesDir, opts)
+	case "check":
+		return commands.CheckGcloud(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showGcloudHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown gcloud command: %s\n\n", subcommand)
+		a.showGcloudHelp()
+		return commands.NewValidationError("unknown gcloud command: %s", subcommand)
+	}
+}
+
+func (a *App) handleKube(args []string) error {
+	if len(args) == 0 {
+		a.showKubeHelp()
+		return nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.KubeOptions{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--context":
+			if i+1 < len(args) {
+				opts.Contexts = append(opts.Contexts, strings.Split(args[i+1], ",")...)
+				i++
+			}
+		case "--from":
+			if i+1 < len(args) {
+				opts.Source = args[i+1]
+				i++
+			}
+		case "--set-current":
+			opts.SetCurrent = true
+		case "-h", "--help":
+			a.showKubeHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	switch subcommand {
+	case "import":
+		return commands.ImportKubeContexts(a.profilesDir, opts)
+	case "list", "ls":
+		return commands.ListKubeContexts(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showKubeHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown kube command: %s\n\n", subcommand)
+		a.showKubeHelp()
+		return commands.NewValidationError("unknown kube command: %s", subcommand)
+	}
+}
+
+func (a *App) handleTerraform(args []string) error {
+	if len(args) == 0 {
+		a.showTerraformHelp()
+		return nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.TerraformOptions{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--host":
+			if i+1 < len(args) {
+				opts.Host = args[i+1]
+				i++
+			}
+		case "--token":
+			if i+1 < len(args) {
+				opts.Token = args[i+1]
+				i++
+			}
+		case "--secret":
+			if i+1 < len(args) {
+				opts.SecretRef = args[i+1]
+				i++
+			}
+		case "-h", "--help":
+			a.showTerraformHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	switch subcommand {
+	case "setup":
+		return commands.SetupTerraform(a.profilesDir, opts)
+	case "credentials", "login":
+		return commands.SetTerraformCredentials(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showTerraformHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown terraform command: %s\n\n", subcommand)
+		a.showTerraformHelp()
+		return commands.NewValidationError("unknown terraform command: %s", subcommand)
+	}
+}
+
+func (a *App) handleRoot(args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	var positional []string
+	for _, arg := range args {
+		switch arg {
+		case "-h", "--help":
+			a.showRootHelp()
+			return nil
+		default:
+			if !strings.HasPrefix(arg, "-") {
+				positional = append(positional, arg)
+			}
+		}
+	}
+
+	opts := commands.RootOptions{}
+	if len(positional) > 0 {
+		opts.Name = positional[0]
+	}
+	if len(positional) > 1 {
+		opts.Path = positional[1]
+	}
+
+	switch subcommand {
+	case "list", "ls":
+		return commands.ListRoots(a.profilesDir)
+	case "add":
+		return commands.AddRoot(a.profilesDir, opts)
+	case "remove", "rm":
+		return commands.RemoveRoot(opts)
+	case "help", "-h", "--help":
+		a.showRootHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown root command: %s\n\n", subcommand)
+		a.showRootHelp()
+		return commands.NewValidationError("unknown root command: %s", subcommand)
+	}
+}
+
+func (a *App) handleTag(args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.TagOptions{}
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--profile", "-p":
+			if i+1 < len(args) {
+				opts.ProfileName = args[i+1]
+				i++
+			}
+		case "-h", "--help":
+			a.showTagHelp()
+			return nil
+		default:
+			if !strings.HasPrefix(arg, "-") {
+				positional = append(positional, arg)
+			}
+		}
+	}
+
+	// The profile name comes first unless it was given with --profile
+	if opts.ProfileName == "" && len(positional) > 0 {
+		opts.ProfileName = positional[0]
+		positional = positional[1:]
+	}
+	for _, tag := range positional {
+		opts.Tags = append(opts.Tags, strings.Split(tag, ",")...)
+	}
+
+	switch subcommand {
+	case "list", "ls":
+		return commands.ListTags(a.profilesDir)
+	case "add":
+		return commands.AddTags(a.profilesDir, opts)
+	case "remove", "rm":
+		return commands.RemoveTags(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showTagHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown tag command: %s\n\n", subcommand)
+		a.showTagHelp()
+		return commands.NewValidationError("unknown tag command: %s", subcommand)
+	}
+}
+
+func (a *App) handleFeature(args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.FeatureOptions{}
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--profile", "-p":
+			if i+1 < len(args) {
+				opts.ProfileName = args[i+1]
+				i++
+			}
+		case "-h", "--help":
+			a.showFeatureHelp()
+			return nil
+		default:
+			if !strings.HasPrefix(arg, "-") {
+				positional = append(positional, arg)
+			}
+		}
+	}
+
+	// The profile name comes first unless it was given with --profile
+	if opts.ProfileName == "" && len(positional) > 0 {
+		opts.ProfileName = positional[0]
+		positional = positional[1:]
+	}
+	opts.Names = positional
+
+	switch subcommand {
+	case "list", "ls":
+		return commands.ListFeatures(a.profilesDir, opts)
+	case "enable", "on":
+		return commands.EnableFeatures(a.profilesDir, opts)
+	case "disable", "off":
+		return commands.DisableFeatures(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showFeatureHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown feature command: %s\n\n", subcommand)
+		a.showFeatureHelp()
+		return commands.NewValidationError("unknown feature command: %s", subcommand)
+	}
+}
+
+func (a *App) handlePlugin(args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		return commands.ListPlugins()
+	case "help", "-h", "--help":
+		a.showPluginHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown plugin command: %s\n\n", args[0])
+		a.showPluginHelp()
+		return commands.NewValidationError("unknown plugin command: %s", args[0])
+	}
+}
+
+func (a *App) handlePreset(args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		return commands.ListPresets()
+	case "help", "-h", "--help":
+		a.showPresetHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown preset command: %s\n\n", args[0])
+		a.showPresetHelp()
+		return commands.NewValidationError("unknown preset command: %s", args[0])
+	}
+}
+
+func (a *App) handleTemplate(args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.TemplateOptions{}
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--name":
+			if i+1 < len(args) {
+				opts.Name = args[i+1]
+				i++
+			}
+		case "--ref":
+			if i+1 < len(args) {
+				opts.Ref = args[i+1]
+				i++
+			}
+		case "--locked":
+			opts.Locked = true
+		case "-h", "--help":
+			a.showTemplateHelp()
+			return nil
+		default:
+			if !strings.HasPrefix(arg, "-") {
+				positional = append(positional, arg)
+			}
+		}
+	}
+
+	switch subcommand {
+	case "list", "ls":
+		return commands.ListTemplates()
+	case "add":
+		if len(positional) > 0 {
+			opts.URL = positional[0]
+		}
+		return commands.AddTemplateRepo(opts)
+	case "update":
+		if len(positional) > 0 {
+			opts.Name = positional[0]
+		}
+		return commands.UpdateTemplateRepos(opts)
+	case "remove", "rm":
+		if len(positional) > 0 {
+			opts.Name = positional[0]
+		}
+		return commands.RemoveTemplateRepo(opts)
+	case "help", "-h", "--help":
+		a.showTemplateHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown template command: %s\n\n", subcommand)
+		a.showTemplateHelp()
+		return commands.NewValidationError("unknown template command: %s", subcommand)
+	}
+}
+
+func (a *App) handleNpm(args []string) error {
+	if len(args) == 0 {
+		a.showNpmHelp()
+		return nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.NpmOptions{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--registry":
+			if i+1 < len(args) {
+				opts.Registry = args[i+1]
+				i++
+			}
+		case "--scope":
+			if i+1 < len(args) {
+				opts.Scope = args[i+1]
+				i++
+			}
+		case "--token":
+			if i+1 < len(args) {
+				opts.Token = args[i+1]
+				i++
+			}
+		case "--token-env":
+			if i+1 < len(args) {
+				opts.TokenEnv = args[i+1]
+				i++
+			}
+		case "-h", "--help":
+			a.showNpmHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	switch subcommand {
+	case "registry":
+		return commands.SetNpmRegistry(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showNpmHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown npm command: %s\n\n", subcommand)
+		a.showNpmHelp()
+		return commands.NewValidationError("unknown npm command: %s", subcommand)
+	}
+}
+
+func (a *App) handleGo(args []string) error {
+	if len(args) == 0 {
+		a.showGoHelp()
+		return nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.GoOptions{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--module":
+			if i+1 < len(args) {
+				opts.Private = append(opts.Private, strings.Split(args[i+1], ",")...)
+				i++
+			}
+		case "--nosumdb":
+			if i+1 < len(args) {
+				opts.NoSumDB = append(opts.NoSumDB, strings.Split(args[i+1], ",")...)
+				i++
+			}
+		case "--clear":
+			opts.Clear = true
+		case "-h", "--help":
+			a.showGoHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	switch subcommand {
+	case "private":
+		return commands.SetGoPrivate(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showGoHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown go command: %s\n\n", subcommand)
+		a.showGoHelp()
+		return commands.NewValidationError("unknown go command: %s", subcommand)
+	}
+}
+
+func (a *App) handleBrew(args []string) error {
+	if len(args) == 0 {
+		a.showBrewHelp()
+		return nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.BrewOptions{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--no-upgrade":
+			opts.NoUpgrade = true
+		case "--force":
+			opts.Force = true
+		case "-h", "--help":
+			a.showBrewHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	switch subcommand {
+	case "sync", "install":
+		return commands.SyncBrew(a.profilesDir, opts)
+	case "dump":
+		return commands.DumpBrew(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showBrewHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown brew command: %s\n\n", subcommand)
+		a.showBrewHelp()
+		return commands.NewValidationError("unknown brew command: %s", subcommand)
+	}
+}
+
+func (a *App) handleNix(args []string) error {
+	if len(args) == 0 {
+		a.showNixHelp()
+		return nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.NixOptions{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--tool":
+			if i+1 < len(args) {
+				opts.Tools = append(opts.Tools, strings.Split(args[i+1], ",")...)
+				i++
+			}
+		case "--nixpkgs":
+			if i+1 < len(args) {
+				opts.Nixpkgs = args[i+1]
+				i++
+			}
+		case "--force":
+			opts.Force = true
+		case "-h", "--help":
+			a.showNixHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	switch subcommand {
+	case "export":
+		return commands.ExportNix(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showNixHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown nix command: %s\n\n", subcommand)
+		a.showNixHelp()
+		return commands.NewValidationError("unknown nix command: %s", subcommand)
+	}
+}
+
+func (a *App) handleDirenv(args []string) error {
+	if len(args) == 0 {
+		a.showDirenvHelp()
+		return nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.DirenvOptions{Jobs: a.config.Jobs}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--all":
+			opts.All = true
+		case "--tag":
+			if i+1 < len(args) {
+				opts.Tags = append(opts.Tags, strings.Split(args[i+1], ",")...)
+				i++
+			}
+		case "-j", "--jobs":
+			if i+1 < len(args) {
+				jobs, err := strconv.Atoi(args[i+1])
+				if err != nil || jobs < 1 {
+					return commands.NewValidationError("--jobs expects a positive number, got: %s", args[i+1])
+				}
+				opts.Jobs = jobs
+				i++
+			}
+		case "-h", "--help":
+			a.showDirenvHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	switch subcommand {
+	case "status":
+		return commands.DirenvStatus(a.profilesDir, opts)
+	case "allow":
+		return commands.AllowDirenvProfiles(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showDirenvHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown direnv command: %s\n\n", subcommand)
+		a.showDirenvHelp()
+		return commands.NewValidationError("unknown direnv command: %s", subcommand)
+	}
+}
+
+func (a *App) handleGPG(args []string) error {
+	if len(args) == 0 {
+		a.showGPGHelp()
+		return nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.GPGOptions{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--name":
+			if i+1 < len(args) {
+				opts.Name = args[i+1]
+				i++
+			}
+		case "--email":
+			if i+1 < len(args) {
+				opts.Email = args[i+1]
+				i++
+			}
+		case "--algo":
+			if i+1 < len(args) {
+				opts.Algo = args[i+1]
+				i++
+			}
+		case "--expire":
+			if i+1 < len(args) {
+				opts.Expire = args[i+1]
+				i++
+			}
+		case "--no-sign":
+			opts.NoSign = true
+		case "-h", "--help":
+			a.showGPGHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	switch subcommand {
+	case "keygen":
+		return commands.GenerateGPGKey(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showGPGHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown gpg command: %s\n\n", subcommand)
+		a.showGPGHelp()
+		return commands.NewValidationError("unknown gpg command: %s", subcommand)
+	}
+}
+
+func (a *App) handleGH(args []string) error {
+	if len(args) == 0 {
+		a.showGHHelp()
+		return nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.GHOptions{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--hostname":
+			if i+1 < len(args) {
+				opts.Hostname = args[i+1]
+				i++
+			}
+		case "--web":
+			opts.Web = true
+		case "-h", "--help":
+			a.showGHHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	switch subcommand {
+	case "login":
+		return commands.LoginGH(a.profilesDir, opts)
+	case "status":
+		return commands.GHStatus(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showGHHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown gh command: %s\n\n", subcommand)
+		a.showGHHelp()
+		return commands.NewValidationError("unknown gh command: %s", subcommand)
+	}
+}
+
+func (a *App) handleTmux(args []string) error {
+	opts := commands.TmuxOptions{}
+
+	for _, arg := range args {
+		switch arg {
+		case "-d", "--detach":
+			opts.Detach = true
+		case "-h", "--help":
+			a.showTmuxHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	return commands.OpenTmux(a.profilesDir, opts)
+}
+
+func (a *App) handlePrompt(args []string) error {
+	opts := commands.PromptOptions{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--color":
+			if i+1 < len(args) {
+				opts.Color = args[i+1]
+				i++
+			}
+		case "--force":
+			opts.Force = true
+		case "-h", "--help":
+			a.showPromptHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	return commands.GeneratePrompt(a.profilesDir, opts)
+}
+
+func (a *App) handleOpen(args []string) error {
+	opts := commands.OpenOptions{Launchers: a.config.Launchers}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--in":
+			if i+1 >= len(args) {
+				return commands.NewValidationError("--in requires a launcher (editor, finder, terminal)")
+			}
+			opts.In = args[i+1]
+			i++
+		case "-h", "--help":
+			a.showOpenHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	return commands.OpenProfile(a.profilesDir, opts)
+}
+
+func (a *App) handleCode(args []string) error {
+	opts := commands.CodeOptions{}
+
+	for _, arg := range args {
+		switch arg {
+		case "--force":
+			opts.Force = true
+		case "--no-open":
+			opts.NoOpen = true
+		case "-h", "--help":
+			a.showCodeHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	return commands.OpenCode(a.profilesDir, opts)
+}
+
+func (a *App) handleExport(args []string) error {
+	opts := commands.ExportOptions{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--format":
+			if i+1 < len(args) {
+				opts.Format = args[i+1]
+				i++
+			}
+		case "-o", "--output":
+			if i+1 < len(args) {
+				opts.Output = args[i+1]
+				i++
+			}
+		case "--force":
+			opts.Force = true
+		case "-h", "--help":
+			a.showExportHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	return commands.ExportProfile(a.profilesDir, opts)
+}
+
+func (a *App) handleImport(args []string) error {
+	opts := commands.ImportOptions{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--from":
+			if i+1 < len(args) {
+				opts.From = args[i+1]
+				i++
+			}
+		case "--force":
+			opts.Force = true
+		case "-h", "--help":
+			a.showImportHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	return commands.ImportProfile(a.profilesDir, opts)
+}
+
+func (a *App) handleStow(args []string, unstow bool) error {
+	opts := commands.StowOptions{}
+	var positional []string
+
+	for _, arg := range args {
+		switch arg {
+		case "--dry-run":
+			opts.DryRun = true
+		case "-h", "--help":
+			a.showStowHelp()
+			return nil
+		default:
+			if !strings.HasPrefix(arg, "-") {
+				positional = append(positional, arg)
+			}
+		}
+	}
+
+	// The profile name comes first, followed by optional package names
+	if len(positional) > 0 {
+		opts.ProfileName = positional[0]
+		opts.Packages = positional[1:]
+	}
+
+	if unstow {
+		return commands.Unstow(a.profilesDir, opts)
+	}
+	return commands.Stow(a.profilesDir, opts)
+}
+
+func (a *App) handleEncrypt(args []string) error {
+	opts := commands.EncryptOptions{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--file":
+			if i+1 < len(args) {
+				opts.Files = append(opts.Files, strings.Split(args[i+1], ",")...)
+				i++
+			}
+		case "--age":
+			if i+1 < len(args) {
+				opts.Age = append(opts.Age, strings.Split(args[i+1], ",")...)
+				i++
+			}
+		case "--keep":
+			opts.Keep = true
+		case "-h", "--help":
+			a.showEncryptHelp()
+			return nil
+		default:
+			if opts.ProfileName == "" && !strings.HasPrefix(arg, "-") {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	return commands.EncryptFiles(a.profilesDir, opts)
+}
+
+func (a *App) handleMaven(args []string) error {
+	if len(args) == 0 {
+		a.showMavenHelp()
+		return nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.MavenOptions{}
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--profile", "-p":
+			if i+1 < len(args) {
+				opts.ProfileName = args[i+1]
+				i++
+			}
+		case "--username", "-u":
+			if i+1 < len(args) {
+				opts.Username = args[i+1]
+				i++
+			}
+		case "--secret":
+			if i+1 < len(args) {
+				opts.SecretRef = args[i+1]
+				i++
+			}
+		case "-h", "--help":
+			a.showMavenHelp()
+			return nil
+		default:
+			if !strings.HasPrefix(arg, "-") {
+				positional = append(positional, arg)
+			}
+		}
+	}
+
+	// The profile name comes first unless it was given with --profile
+	if opts.ProfileName == "" && len(positional) > 1 {
+		opts.ProfileName = positional[0]
+		positional = positional[1:]
+	}
+	if len(positional) > 0 {
+		opts.ServerID = positional[0]
+	}
+
+	switch subcommand {
+	case "server":
+		return commands.SetMavenServer(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showMavenHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown maven command: %s\n\n", subcommand)
+		a.showMavenHelp()
+		return commands.NewValidationError("unknown maven command: %s", subcommand)
+	}
+}
+
+func (a *App) handleNetrc(args []string) error {
+	if len(args) == 0 {
+		a.showNetrcHelp()
+		return nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.NetrcOptions{}
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--profile", "-p":
+			if i+1 < len(args) {
+				opts.ProfileName = args[i+1]
+				i++
+			}
+		case "--login", "-l":
+			if i+1 < len(args) {
+				opts.Login = args[i+1]
+				i++
+			}
+		case "--secret":
+			if i+1 < len(args) {
+				opts.SecretRef = args[i+1]
+				i++
+			}
+		case "-h", "--help":
+			a.showNetrcHelp()
+			return nil
+		default:
+			if !strings.HasPrefix(arg, "-") {
+				positional = append(positional, arg)
+			}
+		}
+	}
+
+	// The profile name comes first unless it was given with --profile
+	if opts.ProfileName == "" && len(positional) > 1 {
+		opts.ProfileName = positional[0]
+		positional = positional[1:]
+	}
+	if len(positional) > 0 {
+		opts.Machine = positional[0]
+	}
+
+	switch subcommand {
+	case "set":
+		return commands.SetNetrc(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showNetrcHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown netrc command: %s\n\n", subcommand)
+		a.showNetrcHelp()
+		return commands.NewValidationError("unknown netrc command: %s", subcommand)
+	}
+}
+
+func (a *App) handleAnsible(args []string) error {
+	if len(args) == 0 {
+		a.showAnsibleHelp()
+		return nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.AnsibleOptions{}
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--profile", "-p":
+			if i+1 < len(args) {
+				opts.ProfileName = args[i+1]
+				i++
+			}
+		case "--secret":
+			if i+1 < len(args) {
+				opts.SecretRef = args[i+1]
+				i++
+			}
+		case "-h", "--help":
+			a.showAnsibleHelp()
+			return nil
+		default:
+			if !strings.HasPrefix(arg, "-") {
+				positional = append(positional, arg)
+			}
+		}
+	}
+	if opts.ProfileName == "" && len(positional) > 0 {
+		opts.ProfileName = positional[0]
+	}
+
+	switch subcommand {
+	case "vault-password":
+		return commands.SetAnsibleVaultPassword(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showAnsibleHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown ansible command: %s\n\n", subcommand)
+		a.showAnsibleHelp()
+		return commands.NewValidationError("unknown ansible command: %s", subcommand)
+	}
+}
+
+func (a *App) handleConda(args []string) error {
+	if len(args) == 0 {
+		a.showCondaHelp()
+		return nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.CondaOptions{}
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--profile", "-p":
+			if i+1 < len(args) {
+				opts.ProfileName = args[i+1]
+				i++
+			}
+		case "-h", "--help":
+			a.showCondaHelp()
+			return nil
+		default:
+			if !strings.HasPrefix(arg, "-") {
+				positional = append(positional, arg)
+			}
+		}
+	}
+
+	switch subcommand {
+	case "activate":
+		// The profile name comes first unless it was given with --profile
+		if opts.ProfileName == "" && len(positional) > 1 {
+			opts.ProfileName = positional[0]
+			positional = positional[1:]
+		}
+		if len(positional) > 0 {
+			opts.Env = positional[0]
+		}
+		return commands.ActivateConda(a.profilesDir, opts)
+	case "deactivate":
+		if opts.ProfileName == "" && len(positional) > 0 {
+			opts.ProfileName = positional[0]
+		}
+		return commands.DeactivateConda(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showCondaHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown conda command: %s\n\n", subcommand)
+		a.showCondaHelp()
+		return commands.NewValidationError("unknown conda command: %s", subcommand)
+	}
+}
+
+func (a *App) handleWSL(args []string) error {
+	if len(args) == 0 {
+		a.showWSLHelp()
+		return nil
+	}
+
+	subcommand := args[0]
+	args = args[1:]
+
+	opts := commands.WindowsEnvOptions{}
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--profile", "-p":
+			if i+1 < len(args) {
+				opts.ProfileName = args[i+1]
+				i++
+			}
+		case "--format", "-f":
+			if i+1 < len(args) {
+				opts.Format = args[i+1]
+				i++
+			}
+		case "-h", "--help":
+			a.showWSLHelp()
+			return nil
+		default:
+			if !strings.HasPrefix(arg, "-") {
+				positional = append(positional, arg)
+			}
+		}
+	}
+
+	switch subcommand {
+	case "env":
+		// The profile name comes first unless it was given with --profile
+		if opts.ProfileName == "" && len(positional) > 0 {
+			opts.ProfileName = positional[0]
+			positional = positional[1:]
+		}
+		opts.Vars = positional
+		return commands.PrintWindowsEnv(a.profilesDir, opts)
+	case "help", "-h", "--help":
+		a.showWSLHelp()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown wsl command: %s\n\n", subcommand)
+		a.showWSLHelp()
+		return commands.NewValidationError("unknown wsl command: %s", subcommand)
+	}
+}
+
+func (a *App) handleEnv(args []string) error {
+	opts := commands.EnvOptions{}
+	hook := ""
+	export := len(args) > 0 && args[0] == "export"
+	if export {
+		args = args[1:]
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--profile", "-p":
+			if i+1 < len(args) {
+				opts.ProfileName = args[i+1]
+				i++
+			}
+		case "--format", "-f":
+			if i+1 < len(args) {
+				opts.Format = args[i+1]
+				i++
+			}
+		case "--hook":
+			if i+1 < len(args) {
+				hook = args[i+1]
+				i++
+			}
+		case "-h", "--help":
+			if export {
+				a.showEnvExportHelp()
+			} else {
+				a.showEnvHelp()
+			}
+			return nil
+		default:
+			if !strings.HasPrefix(arg, "-") && opts.ProfileName == "" {
+				opts.ProfileName = arg
+			}
+		}
+	}
+
+	if export {
+		return commands.ExportEnv(a.profilesDir, opts)
+	}
+	if hook != "" {
+		if hook != "nu" {
+			return commands.NewValidationError("unsupported hook shell: %s (direnv has built-in hooks for the others: direnv hook <shell>)", hook)
+		}
+		commands.PrintNuHook()
+		return nil
+	}
+	return commands.PrintEnv(a.profilesDir, opts)
+}
+
+func (a *App) handleHook(args []string) error {
+	opts := commands.HookOptions{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--color":
+			if i+1 < len(args) {
+				opts.Color = args[i+1]
+				i++
+			}
+		case "--with-cd":
+			opts.WithCd = true
+		case "--cd-name":
+			if i+1 < len(args) {
+				opts.CdName = args[i+1]
+				opts.WithCd = true
+				i++
+			}
+		case "-h", "--help":
+			a.showHookHelp()
+			return nil
+		default:
+			if !strings.HasPrefix(arg, "-") && opts.Shell == "" {
+				opts.Shell = arg
+			}
+		}
+	}
+
+	return commands.PrintPromptHook(opts)
+}
+
+// handlePath prints the directory of a profile (or of a directory in it)
+// for the cd function of 'profile hook --with-cd'
+func (a *App) handlePath(args []string) error {
+	var positional []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) > 2 {
+		return commands.NewValidationError("expected a profile and a directory in it, got: %s", strings.Join(positional, " "))
+	}
+	positional = append(positional, "", "")
+	return commands.PrintProfileDir(a.profilesDir, positional[0], positional[1])
+}
+
+func (a *App) handleLink(args []string) error {
+	var positional []string
+	list := false
+	for _, arg := range args {
+		switch arg {
+		case "-h", "--help":
+			a.showLinkHelp()
+			return nil
+		case "--list", "-l":
+			list = true
+		default:
+			if !strings.HasPrefix(arg, "-") {
+				positional = append(positional, arg)
+			}
+		}
+	}
+
+	if list || len(positional) == 0 {
+		return commands.ListDirLinks(a.profilesDir)
+	}
+	if len(positional) > 2 {
+		return commands.NewValidationError("expected a directory and a profile, got: %s", strings.Join(positional, " "))
+	}
+	positional = append(positional, "")
+	return commands.LinkDir(a.profilesDir, commands.LinkOptions{Dir: positional[0], ProfileName: positional[1]})
+}
+
+func (a *App) handleUnlink(args []string) error {
+	dir := ""
+	for _, arg := range args {
+		switch arg {
+		case "-h", "--help":
+			a.showLinkHelp()
+			return nil
+		default:
+			if !strings.HasPrefix(arg, "-") && dir == "" {
+				dir = arg
+			}
+		}
+	}
+	return commands.UnlinkDir(dir)
+}
+
+// handleLinkEnv prints what changes in the environment of the shell in the
+// current directory, for the link hook of 'profile hook'
+func (a *App) handleLinkEnv(args []string) error {
+	shellName := ""
+	if len(args) > 0 {
+		shellName = args[0]
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	return commands.PrintLinkEnv(a.profilesDir, shellName, dir)
+}
+
+func (a *App) handleApply(args []string) error {
+	opts := commands.ApplyOptions{}
+
+	for _, arg := range args {
+		switch arg {
+		case "-h", "--help":
+			a.showApplyHelp()
+			return nil
+		default:
+			if !strings.HasPrefix(arg, "-") && opts.Path == "" {
+				opts.Path = arg
+			}
+		}
+	}
+
+	return commands.ApplyPlan(a.profilesDir, opts)
+}
+
+func (a *App) showHelp() {
+	helpText := `Workspace Profile Manager
+
+Manage workspace profiles with direnv for environment-specific configurations.
+
+Usage: profile [global options] <command> [arguments]
+
+Global Options:
+    -y, --yes                  Answer yes to all confirmation prompts
+    --non-interactive          Never prompt; fail if required input is missing
+                               (implied when stdin is not a terminal)
+    --detailed-exitcode        Exit with 2 when changes were applied
+    -o, --output <format>      Print results as text, json, or yaml, for:
+` + structuredCommandsHelp() + `
+    --verbose                  Log debug messages to stderr
+    -q, --quiet                Hide info and success messages
+    --no-color                 Disable colored output (also NO_COLOR=1)
+    --wide                     Do not truncate table columns to fit the terminal
+    --show-secrets             Print the values of tokens, passwords, and keys
+                               instead of masking them (not in the log file)
+    --no-cache                 Scan the profile directories and read every profile
+                               instead of using the profile cache
+
+Exit Codes:
+    0    Success, nothing changed
+    1    Unclassified failure
+    2    Changes applied (only with --detailed-exitcode; see changed_exit_code)
+    3    Invalid input (unknown profile, bad name, missing argument)
+    4    Filesystem or I/O error
+
+Colors:
+    Output is colored on terminals unless NO_COLOR is set. Set color=always
+    or color=never in ~/.config/spm/config to override, and theme the colors
+    in a [theme] section with success, warning, error, and accent set to a
+    color name (e.g. "bold magenta", "bright cyan") or SGR codes ("38;5;208").
+
+Logs:
+    Every run is logged at debug level to ~/.local/state/spm/spm.log
+    ($XDG_STATE_HOME/spm), rotated at 1 MiB. Attach it when reporting issues.
+
+Commands:
+    init [options]             Initialize the profile manager configuration
+        Options:
+            --profiles-dir <path>    Set profiles directory path
+            --interactive            Interactive setup
+            --force                  Overwrite existing configuration
+
+    create [name] [options]     Create a new workspace profile (a wizard on terminals)
+        Options:
+            --template <type>       Use template: personal, work, client, basic
+            --preset <name>         Start from an organization preset
+            --git-name <name>       Set git user name
+            --git-email <email>     Set git user email
+            --interactive           Interactive setup (default if no flags provided)
+            --no-interactive        Disable interactive mode
+            --force                 Overwrite existing profile
+            --feature <name>        Enable an optional feature (e.g. podman)
+            --description <text>    Describe the profile for the selector
+            --tag <tag>             Tag the profile for the selector
+            --dry-run               Show the plan without creating anything
+            --out <file>            Save the plan for 'profile apply' (implies --dry-run)
+
+    update [name] [options]     Update an existing profile with new features
+        Options:
+            --dry-run              Show the plan without applying it
+            --out <file>            Save the plan for 'profile apply' (implies --dry-run)
+            --all                   Update every profile, with progress and a failure report
+            -j, --jobs <count>      Update this many profiles at once with --all (default: 4)
+            --force                 Overwrite existing files
+            --no-backup            Skip creating backup
+            --no-commit             Do not commit the changes (with auto_commit)
+        Note: Interactive selection by default if name is omitted
+
+    select [name] [options]     Select and switch to a profile (default: the default or last used one)
+        Options:
+            --allow-direnv          Automatically allow direnv for selected profile
+        Note: Interactive selection if name is omitted
+
+    run [-p name] -- <cmd>      Run a command with a profile's environment
+
+    list [options]              List all workspace profiles
+        Options:
+            --verbose               Show detailed information (disables interactive)
+            --config                Show git configuration (disables interactive)
+            --no-interactive         Disable interactive mode
+        Note: Interactive by default unless flags are provided
+
+    delete [name] [options]     Delete a workspace profile
+        Options:
+            --force                 Skip confirmation prompt (disables interactive)
+            --dry-run              Preview deletion without deleting (disables interactive)
+            --out <file>            Save the plan for 'profile apply' (implies --dry-run)
+            --no-interactive        Disable interactive mode
+        Note: Interactive selection by default if name is omitted
+
+    apply <plan-file>           Apply a plan saved with create/update/delete --out
+
+    restore <name> [options]    Restore a profile from backup
+        Options:
+            --force                 Skip confirmation prompt
+            --dry-run              Preview restore without restoring
+            --file <file>           Restore only a specific file
+            --backup-date <date>    Restore from specific dated backup
+
+    info                        Show information about the current profile
+    status [--notify]           Show direnv status and alerts (drift, expiring credentials)
+    watch [--interval d]        Notify on the desktop when profiles drift or credentials expire
+    daemon <command>            Run watch and maintenance in the background (start, stop, status, install)
+    serve [--addr|--stdio]      Serve profiles to editors over a local HTTP API or JSON-RPC on stdio
+    which [--name|--path]       Print the active profile (exits 1 if there is none)
+    doctor [name]               Check profiles for missing directories, variables, and patterns
+    log [name] [options]        Show the changes spm applied to a profile
+    verify [name] [--accept]    Report managed files changed outside spm
+    guard <command> [name]      Block commits that add credentials (install, uninstall)
+    perms <command> [name]      Check or fix the permissions of keys and credentials
+    clean [name] [--all]        Empty tool caches in profiles (--dry-run shows the space freed)
+    gc [--dry-run]              Remove expired backups, stale locks, and leftover temporary files
+    prune --orphans [dir...]    Find directories without an .envrc; --adopt, --archive, or --delete them
+    policy <command> [name]     Check profiles against the team policy (check, update)
+        Options:
+            -n, --limit <count>     Show only the newest entries
+            --git                   Show the commits that changed managed files
+    dotfiles <command> [name]    Manage profile dotfiles
+        Commands:
+            list                    List all dotfiles in a profile
+            edit                    Edit a dotfile interactively
+        Options:
+            --profile, -p <name>    Profile name (interactive if omitted)
+            --file, -f <name>       File name (interactive if omitted)
+            --editor, -e <name>     Editor to use (default: $EDITOR or vim)
+        Note: Interactive by default if profile/file name is omitted
+    secrets <command> [name]    Load secret variables from a secret manager
+        Commands:
+            vault                   Use HashiCorp Vault (--address, --namespace, --role)
+            bws                     Use Bitwarden Secrets Manager (--server-url)
+            keychain                Use the macOS Keychain (--service)
+            wincred                 Use Windows Credential Manager (--service)
+            store <VAR>             Save a value in the Keychain/Credential Manager and load VAR from it
+            set <VAR> <ref>         Load VAR from a secret reference
+            remove <VAR>            Stop loading VAR
+            list                    List configured secrets
+            check                   Verify every secret resolves
+    dotenv <command> [name]     Manage the variables in the profile's .env
+        Commands:
+            list                    List the variables and secrets loaded with them
+            set NAME=value...       Set variables in .env
+            set NAME --secret <ref> Load NAME from the secrets provider instead
+            unset NAME...           Remove variables
+    aws setup [name]            Write an AWS (SSO) named profile and export AWS_PROFILE
+    azure setup [name]          Set the Azure tenant, subscription, and CLI defaults
+    gcloud <command> [name]     Manage the profile's gcloud configuration
+        Commands:
+            setup                   Create a named configuration (project, account, region)
+            check                   Verify gcloud picks up the configuration
+    kube <command> [name]       Manage the profile's kubeconfig
+        Commands:
+            import --context <ctx>  Copy contexts from the global kubeconfig
+            list                    Show contexts isolated in each profile
+    terraform <command> [name]  Manage the profile's Terraform CLI configuration
+        Commands:
+            setup                   Write .terraformrc with a profile-local plugin cache
+            credentials             Store a Terraform Cloud token (--token or --secret)
+    feature <command> [name]    Manage the features applied to a profile
+        Commands:
+            list                    List features (or a profile's features)
+            enable <feature>...     Turn features on and apply them
+            disable <feature>...    Turn features off
+    tag <command> [name]        Tag profiles to target them with --tag
+        Commands:
+            list                    List the tags in use and their profiles
+            add <tag>...            Tag a profile (e.g. client:acme, cloud:aws)
+            remove <tag>...         Remove tags from a profile
+    root <command>              Manage additional profile directories
+        Commands:
+            list                    List profiles_dir and the other roots
+            add <name> <path>       Add a root; its profiles are named <name>/<profile>
+            remove <name>           Remove a root (its profiles are left in place)
+    preset list                 List the presets profiles can be created from
+    template <command>          Manage templates and template repositories
+        Commands:
+            list                    List templates and template repositories
+            add <url>               Add a git repository of shared templates
+            update [repository]     Lock template repositories at their latest commit
+            remove <repository>     Remove a template repository
+    plugin list                 List plugins (spm-<name> executables) and what they register
+    npm registry [name]         Set a (scoped) npm registry and token in the profile's .npmrc
+    go private [name]           Set GOPRIVATE/GONOSUMDB module patterns for the profile
+    maven server [name] <id>    Add a Maven server to settings.xml with its password from the environment
+    netrc set [name] <machine>  Add a machine login to the profile's .netrc (NETRC)
+    ansible <command> [name]    Manage the profile's Ansible configuration
+        Commands:
+            vault-password          Load the vault password from the secrets provider
+    conda <command> [name]      Manage the conda environment activated with the profile
+        Commands:
+            activate <env>          Activate a conda environment when the profile loads
+            deactivate              Stop activating it
+    brew <command> [name]       Manage the profile's Brewfile
+        Commands:
+            sync                    Install the Brewfile with 'brew bundle'
+            dump                    Capture installed formulae into the Brewfile
+    nix export [name]           Generate a flake.nix devShell from the profile's tools
+    gh <command> [name]         Manage the profile's GitHub CLI login
+        Commands:
+            login                   Run 'gh auth login' into the profile's GH_CONFIG_DIR
+            status                  Run 'gh auth status' for the profile
+    tmux [name]                 Attach to (or create) a tmux session for the profile
+    prompt [name]               Generate a starship prompt config showing the profile name
+    code [name]                 Open the profile in VS Code with a matching VS Code profile
+    open [name] [--in launcher] Open the profile in an editor, file manager, or terminal
+    export [name]               Export the profile's files as a chezmoi source fragment
+    import [name]               Create a profile from its chezmoi source fragment
+    encrypt [name]              Encrypt sensitive profile files with sops/age so they can be committed
+    link <dir> <name>           Load a profile in a project directory outside the profile roots
+    unlink <dir>                Remove the link of a project directory
+    stow [name] [package...]    Link the profile's stow packages into $HOME
+    unstow [name] [package...]  Remove the links created by 'stow'
+    gpg keygen [name]           Create a signing key in the profile's GNUPGHOME and use it in .gitconfig
+    env [name] [--format fmt]   Print the profile's environment as sh, fish, nu, or json
+    env export [name] [--format fmt]
+                                Export the environment as dotenv, json, github-actions, or docker-args
+    conflicts [name]            Find variables your shell leaks into the profile or the profile shadows
+    wsl env [name] [VAR...]     Print the profile's path variables as Windows paths (WSL)
+    hook [shell] [--color c]    Print a prompt hook exposing the active profile in SPM_PROFILE
+                                (--with-cd adds pcd <profile> [dir] to change to a profile)
+    completion [shell]          Print the completion script for bash, zsh, or fish
+    direnv <command> [name]     Check and allow profiles in direnv
+        Commands:
+            status                  Show whether each .envrc is allowed, blocked, or stale
+            allow [--all]           Run 'direnv allow' for a profile (or all that need it)
+    sync <command> [name]       Sync operations for profiles
+        Commands:
+            init [--remote <url>]    Initialize repository
+            pull                     Pull changes from remote
+            push [--force]          Push changes to remote
+            sync                    Pull then push (sync)
+            remote <url>            Set or update remote URL
+            status                  Show sync status
+        Options:
+            --no-interactive         Disable interactive profile selection
+        Note: Interactive selection by default if name is omitted (except status)
+    help                        Show this help message
+
+Examples:
+    # Create interactively (default behavior)
+    profile create my-project
+
+    # Create with specific options (disables interactive)
+    profile create my-project --git-name "John Doe" --git-email "john@example.com"
+
+    # Force interactive even with some flags
+    profile create my-project --template work --interactive
+
+    # Interactive profile selection (default)
+    profile list
+
+    # List all profiles (non-interactive)
+    profile list --verbose
+
+    # Select a profile to switch to
+    profile select
+    profile select my-project
+
+    # Delete a profile
+    profile delete old-project
+
+    # Restore from backup (will list available backups)
+    profile restore my-project
+    profile restore my-project --backup-date 2024-11-29_14-30-45
+    profile restore my-project --file .envrc
+
+    # Show current profile info
+    profile info
+
+    # Manage dotfiles (interactive by default)
+    profile dotfiles list              # Interactive profile selection
+    profile dotfiles list my-project  # List dotfiles in specific profile
+    profile dotfiles edit              # Interactive profile and file selection
+    profile dotfiles edit my-project   # Interactive file selection
+    profile dotfiles edit my-project .gitconfig  # Edit specific file
+
+    # Sync operations (interactive selection if name omitted)
+    profile sync pull              # Interactive selection
+    profile sync push              # Interactive selection
+    profile sync init my-project --remote https://github.com/user/my-project.git
+    profile sync pull my-project
+    profile sync push my-project
+    profile sync sync my-project
+    profile sync status my-project
+
+Getting Started:
+    1. Initialize:          profile init (or profile init --interactive)
+    2. Create a profile:    profile create my-project --interactive
+    3. Navigate to it:      cd <profiles-dir>/my-project
+    4. Allow direnv:        direnv allow
+    5. Verify:              profile info
+
+For more information, see:
+    - docs/QUICKSTART.md - Quick start guide
+    - README.md          - Full documentation
+    - docs/examples/     - Example configurations
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showSyncHelp() {
+	helpText := `Sync Operations for Profiles
+
+Usage: profile sync <command> [profile-name] [options]
+
+Commands:
+    init [--remote <url>]    Initialize repository in profile directory
+        Options:
+            --remote <url>       Add remote URL during initialization
+        Note: If profile-name is omitted, interactive selection will be shown
+
+    pull [--all]             Pull changes from remote repository
+        Options:
+            --all                Pull every profile that is a git repository
+        Note: Requires remote to be configured
+        Note: If profile-name is omitted, interactive selection will be shown
+
+    push [--force] [--all]  Push local changes to remote repository
+        Options:
+            --force              Force push (use with caution)
+            --all                Push every profile that is a git repository
+        Note: Automatically commits uncommitted changes
+        Note: If profile-name is omitted, interactive selection will be shown
+
+    sync [--all]            Sync profile (pull then push)
+        Options:
+            --all                Sync every profile that is a git repository
+        Note: Handles cases where remote is not configured
+        Note: If profile-name is omitted, interactive selection will be shown
+
+    With --all, a progress line is shown per profile and the failures are
+    listed at the end. --tag <tag> works like --all for the profiles with a
+    tag (repeatable or comma-separated). -j, --jobs <count> sets how many
+    profiles are processed at once (default: 4, or jobs= in
+    ~/.config/spm/config); status lines keep the order of the profiles.
+    With one job the git output of each failure is listed as well; use an
+    ssh agent, since prompts cannot be answered while profiles run.
+
+    remote <url>            Set or update the remote URL
+        Arguments:
+            <url>                Remote URL (required)
+        Note: If profile-name is omitted, interactive selection will be shown
+
+    status                  Show sync status and remote information
+        Note: If profile-name is omitted, shows status for all profiles
+
+Examples:
+    # Initialize repository
+    profile sync init my-project
+
+    # Initialize with remote
+    profile sync init my-project --remote https://github.com/user/my-project.git
+
+    # Pull latest changes
+    profile sync pull my-project
+
+    # Push local changes
+    profile sync push my-project
+
+    # Sync (pull then push)
+    profile sync sync my-project
+
+    # Set remote URL
+    profile sync remote my-project https://github.com/user/my-project.git
+
+    # Check sync status
+    profile sync status my-project
+
+    # Pull the profiles tagged active
+    profile sync pull --tag active
+
+Notes:
+    - Profiles are assumed to be in private repositories
+    - Local files created by 'profile create' are not affected
+    - Uncommitted changes are automatically committed before push
+    - Sync will pull then push, handling missing remotes gracefully
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showCreateHelp() {
+	helpText := `Usage: profile create [profile-name] [options]
+
+Create a new workspace profile with direnv configuration.
+
+Without options on a terminal, a wizard asks for the profile name, template,
+git identity, which tool integrations (AWS, Azure, GCP, Kubernetes, ...) to
+enable, and the secrets provider, then renders the profile from templates.
+
+Arguments:
+    profile-name        Name of the profile to create (asked by the wizard
+                        when omitted; required otherwise)
+
+Options:
+    -h, --help          Show this help message
+    -f, --force         Overwrite existing profile if it exists
+    -t, --template      Use a specific template: personal, work, client, or a
+                        custom one (default: basic)
+    --preset <name>     Start from an organization preset (see 'profile preset
+                        --help'); other options override its values
+    --git-name NAME     Set git user.name in .gitconfig
+    --git-email EMAIL   Set git user.email in .gitconfig
+    --interactive       Prompt for all configuration values
+    --dry-run          Show the plan of files, directories, and variables to create
+    --out <file>       Save the plan to a file for 'profile apply' (implies --dry-run)
+    --init-git         Initialize git repository after creation
+    --git-remote <url> Initialize git repository with remote URL
+    --feature <name>   Enable an optional feature (repeatable or comma-separated)
+    --disable-feature <name>
+                       Leave out a default feature, e.g. aws (repeatable or comma-separated)
+    --secrets-provider <name>
+                       Load secrets from a provider (see 'profile secrets --help')
+    --description TEXT Describe the profile (shown and searched in the selector)
+    --tag <tag>        Tag the profile (repeatable or comma-separated; searched in the selector)
+    --owner <owner>    Record who owns the profile (default: the git email, or your user name)
+    --var <key=value>  Set a template variable, saved in profile.yaml (repeatable)
+    --extends <base>   Inherit features, vars, and the layer of another profile
+    --root <name>      Create the profile in another profile root (see
+                       'profile root --help'); same as naming it <root>/<name>
+    --no-commit        Do not commit a profile recreated with --force (see
+                       auto_commit in 'profile update --help')
+
+Examples:
+    # Create a basic profile
+    profile create my-project
+
+    # Create a work profile with git configuration
+    profile create acme-corp --template work \\
+        --git-name "John Doe" \\
+        --git-email "john.doe@acme.com"
+
+    # Interactive setup
+    profile create my-project --interactive
+
+    # Without cloud tooling, with secrets from Vault
+    profile create lab --disable-feature aws,azure,gcloud --secrets-provider vault
+
+    # Preview what would be created
+    profile create my-project --dry-run
+
+    # Save the plan, review it, then apply it
+    profile create my-project --feature podman --out plan.json
+    profile apply plan.json
+
+    # Create a workspace from the acme-aws preset in one step
+    profile create acme --preset acme-aws
+
+    # Share company settings through a base profile
+    profile create acme-api --extends acme-base
+
+    # Create the profile on the encrypted volume configured as root 'work'
+    profile create acme --root work
+
+    # Create with git initialization
+    profile create my-project --init-git
+    profile create my-project --git-remote https://github.com/user/my-project.git
+
+Templates:
+    personal    - Personal projects with minimal configuration
+    work        - Work projects with corporate settings
+    client      - Client projects with isolated credentials
+    basic       - Minimal configuration (default)
+
+Customizing templates:
+    .envrc, .gitconfig, and .gitignore are rendered from Go text/template
+    files. Put envrc.tmpl, gitconfig.tmpl, or gitignore.tmpl in
+    ~/.config/spm/templates/ to replace them for every template, or in
+    ~/.config/spm/templates/<template>/ for one template. A new directory
+    there adds a template with its name.
+
+    Templates can use .Profile, .Template, .Created, .GitName, .GitEmail,
+    .Owner, .Description, .Tags, .Vars (from --var), .EnvrcSections, and
+    .GitignoreSections, and the functions default, join, lower, and upper:
+
+        [url "git@{{ .Vars.host | default "github.com" }}:"]
+            insteadOf = https://{{ .Vars.host | default "github.com" }}/
+
+Base profiles:
+    A profile created with --extends (or with extends: in profile.yaml)
+    inherits the feature selection and vars of its base, and the layer: of
+    every profile up the chain. A layer lists items shared with the profiles
+    that extend it:
+
+        layer:
+          env:
+            - name: AWS_REGION
+              value: eu-west-1
+          gitignore: [".acme/cache/"]
+          dirs: [".acme"]
+
+    'profile update' applies the layers base first; a profile overrides
+    variables of its bases, and reports which profile each item came from.
+    Layer values are exported as they are, in single quotes, without
+    expanding variables or commands; directories must be inside the profile.
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showSelectHelp() {
+	helpText := `Usage: profile select [profile-name] [options]
+       profile switch [profile-name] [options]
+
+Select and switch to a workspace profile.
+
+This command helps you select a profile and provides instructions on how to
+activate it. The profile is activated by changing to its directory, which
+automatically loads the profile's environment via direnv.
+
+Without a name, the default profile (default_profile= in
+~/.config/spm/config) is selected, otherwise the profile last selected or
+run in; when neither exists you choose one interactively.
+
+Arguments:
+    profile-name        Name of the profile to select (optional)
+
+Options:
+    -h, --help          Show this help message
+    --allow-direnv      Automatically allow direnv for the selected profile
+    --pick              Choose the profile interactively even when there is a
+                        default or last used one
+
+Examples:
+    # Switch to the default or last used profile
+    profile switch
+
+    # Interactive selection
+    profile select --pick
+
+    # Select specific profile
+    profile select my-project
+
+    # Select and allow direnv automatically
+    profile select my-project --allow-direnv
+
+After selection, you'll see instructions to activate the profile:
+    cd <profile-path>
+    direnv allow  # (first time only)
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showRunHelp() {
+	helpText := `Usage: profile run [-p profile-name] [--] <command> [args...]
+
+Run a command with the environment of a profile, as direnv loads it, without
+changing to the profile's directory. Use it for one-off commands in another
+profile, in scripts, or from tools that do not run in a shell with direnv.
+The .envrc must be allowed.
+
+Without -p, the default profile (default_profile= in ~/.config/spm/config)
+is used, otherwise the profile last selected or run in. The command's exit
+status becomes that of profile.
+
+Options:
+    -p, --profile <name>  Profile to run the command in
+    -h, --help            Show this help message
+
+The command starts at the first argument that is not an option of run (or
+after "--"); it and its arguments are passed as they are, including those
+that look like options of profile, such as -q or -o.
+
+Examples:
+    profile run -p acme -- aws sts get-caller-identity
+    profile run -- terraform plan
+    profile run -p acme -- git -C ~/src/acme log --oneline -5
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showListHelp() {
+	helpText := `Usage: profile list [options]
+
+List all workspace profiles with their configurations as a table. Profile
+aliases (set in the [aliases] section of ~/.config/spm/config or in
+profile.yaml) are shown in parentheses after the name.
+
+Interactive mode is enabled by default. Use flags to disable it. The
+selected profile is shown with the metadata from its manifest (profile.yaml):
+aliases, description, tags, owner, template, enabled features, and git remotes.
+Long columns are truncated to fit the terminal; pass --wide to see them
+in full.
+
+Options:
+    -h, --help          Show this help message
+    -v, --verbose       Add owner, template, created, .env, and script columns (disables interactive)
+    -c, --config        Add the .gitconfig path column (disables interactive)
+    --tag <tag>         Only list the profiles with a tag (repeatable or
+                        comma-separated; patterns such as 'client:*' work)
+    --no-interactive    Disable interactive mode
+
+Examples:
+    profile list                # Interactive selection menu (default)
+    profile list --verbose      # Show detailed information for all profiles
+    profile list --config       # Show git configuration for all profiles
+    profile list --no-interactive  # List all profiles without interactive menu
+    profile list -v --wide      # Show every column without truncation
+    profile list --tag client:acme --no-interactive  # List one client's profiles
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showRestoreHelp() {
+	helpText := `Usage: profile restore [profile-name] [options]
+
+Restore the files of a profile from a backup made by 'profile update'.
+
+Backups are kept in .backups/ inside the profile. Without --backup-date
+the backup is selected interactively (the newest with --force). The
+current files are backed up before they are overwritten.
+
+Options:
+    -h, --help              Show this help message
+    -f, --force             Skip confirmation prompt (restores the newest backup)
+    --dry-run               Show what would be restored without restoring
+    --file <file>           Restore only a specific file, e.g. .envrc
+    --backup-date <date>    Restore from a specific backup, e.g. 2024-11-29_14-30-45
+
+Encrypted backups:
+    Backups are encrypted with age when recipients are configured, either
+    for all profiles in ~/.config/spm/config:
+
+        backup_age=age1...
+        backup_identity=~/.config/sops/age/keys.txt
+
+    or for one profile in its profile.yaml:
+
+        backup:
+          age: [age1...]
+
+    They are written as .backups/update_<timestamp>.tar.age and decrypted
+    transparently on restore with backup_identity (default: the sops age
+    key file).
+
+Examples:
+    profile restore my-project
+    profile restore my-project --backup-date 2024-11-29_14-30-45
+    profile restore my-project --file .envrc --dry-run
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showDeleteHelp() {
+	helpText := `Usage: profile delete [profile-name] [options]
+
+Delete a workspace profile and all its files.
+
+Interactive selection is enabled by default if profile name is omitted.
+
+Arguments:
+    profile-name        Name of the profile to delete (optional - interactive selection if omitted)
+
+Options:
+    -h, --help          Show this help message
+    -f, --force         Skip confirmation prompt (disables interactive)
+    --dry-run          Show what would be deleted without deleting (disables interactive)
+    --out <file>       Save the plan to a file for 'profile apply' (implies --dry-run)
+    --no-interactive    Disable interactive mode
+
+Examples:
+    # Interactive selection (default)
+    profile delete
+
+    # Delete a profile (with confirmation)
+    profile delete old-project
+
+    # Delete without confirmation
+    profile delete old-project --force
+
+    # Preview what would be deleted
+    profile delete old-project --dry-run
+
+Safety:
+    - You will be prompted for confirmation unless --force is used
+    - The profile directory and all its contents will be deleted
+    - This operation cannot be undone
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showDotfilesHelp() {
+	helpText := `Usage: profile dotfiles <command> [profile-name] [options]
+
+Manage dotfiles in workspace profiles.
+
+Commands:
+    list, ls              List all dotfiles in a profile
+    edit, e                Edit a dotfile interactively
+
+Options:
+    -h, --help            Show this help message
+    -p, --profile <name>  Profile name (interactive selection if omitted)
+    -f, --file <name>      File name (interactive selection if omitted)
+    -e, --editor <name>   Editor to use (default: $EDITOR, $VISUAL, or vim)
+
+Examples:
+    # Interactive profile and file selection
+    profile dotfiles list
+    profile dotfiles edit
+
+    # List dotfiles in specific profile
+    profile dotfiles list my-project
+
+    # Edit specific file (interactive profile selection)
+    profile dotfiles edit --file .gitconfig
+
+    # Edit specific file in specific profile
+    profile dotfiles edit my-project .gitconfig
+
+    # Use custom editor
+    profile dotfiles edit my-project .envrc --editor code
+
+Supported Dotfiles:
+    .envrc                    - direnv configuration
+    .gitconfig                - Git configuration
+    .gitignore                - Git ignore patterns
+    .ssh/config               - SSH client configuration
+    .aws/config               - AWS CLI configuration
+    .aws/credentials          - AWS credentials
+    .azure/config             - Azure CLI configuration
+    .azure/clouds.config      - Azure CLI cloud configuration
+    .gcloud/configurations   - Google Cloud SDK configurations
+    .gcloud/credentials      - Google Cloud SDK credentials
+    .config/claude          - Claude Code configuration
+    .config/gemini          - Gemini CLI configuration
+    .kube/config              - Kubernetes configuration
+    .terraformrc              - Terraform CLI configuration
+    .config/1Password/agent.toml - 1Password SSH agent config
+    .env                      - Environment variables (secrets)
+    .env.example              - Environment variables template
+    .envrc.local              - Local direnv overrides
+
+Note: Interactive mode is enabled by default if profile or file name is omitted.
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showUpdateHelp() {
+	helpText := `Usage: profile update [profile-name] [options]
+
+Update an existing profile with new features and configurations.
+
+This command adds missing directories, environment variables, and configuration
+files to existing profiles. Useful when new features are added to the profile
+manager (e.g., Azure CLI, Google Cloud SDK support).
+
+Arguments:
+    profile-name        Name of the profile to update (optional - interactive selection if omitted)
+
+Options:
+    -h, --help          Show this help message
+    -f, --force         Overwrite existing files without prompting
+    --dry-run          Show the plan of changes without applying them
+    --out <file>       Save the plan to a file for 'profile apply' (implies --dry-run)
+    --all              Update every profile; shows a progress line per profile
+                       and lists the failures at the end
+    --tag <tag>        Update the profiles with a tag, like --all (repeatable or
+                       comma-separated; see 'profile tag --help')
+    -j, --jobs <count> Update this many profiles at once with --all or --tag
+                       (default: 4, or jobs= in ~/.config/spm/config). Status
+                       lines keep the order of the profiles; with --jobs 1 the
+                       output of each failed profile is listed too
+    --no-backup        Skip creating backup before updating
+    --allow-direnv     Run 'direnv allow' after changes are written
+    --no-hooks         Skip the user post-update hook
+    --no-commit        Do not commit the changes (with auto_commit)
+
+Examples:
+    # Interactive selection
+    profile update
+
+    # Update specific profile
+    profile update my-project
+
+    # Preview changes without applying
+    profile update my-project --dry-run
+
+    # Save the plan and apply it later
+    profile update my-project --out plan.json
+    profile apply plan.json
+
+    # Update every profile
+    profile update --all
+
+    # Update the profiles tagged cloud:aws
+    profile update --tag cloud:aws
+
+    # Update without creating backup
+    profile update my-project --no-backup
+
+    # Update and re-allow direnv
+    profile update my-project --allow-direnv
+
+Commits:
+    With auto_commit=true in ~/.config/spm/config, the changes made to a
+    profile that is a git repository are committed, e.g.:
+
+        spm update: .azure, AZURE_CONFIG_DIR, CLOUDSDK_CONFIG
+
+    with one line per change in the message body. Only the files the update
+    changed are committed; files that already had uncommitted changes are
+    left for you. Pass --no-commit to skip it once.
+
+Policy:
+    With policy_enforce=true in ~/.config/spm/config, an update that leaves a
+    profile violating the team policy fails and lists the violations. See
+    'profile policy --help'.
+
+What gets updated:
+    - Schema migrations since the version recorded in profile.yaml
+    - Missing directories (.azure, .gcloud, etc.)
+    - Missing starter files provided by features
+    - Missing or broken links to shared files (features with strategy: link)
+    - Missing environment variables in .envrc
+    - Missing patterns in .gitignore
+    - SSH directory permissions
+    - Deprecated variables in .envrc (rename, remove, or comment out; asked per item)
+
+Features:
+    Directories, variables, and patterns come from the built-in feature
+    definitions plus any YAML files in ~/.config/spm/features.d/, e.g.:
+
+        features:
+          - name: pulumi
+            comment: Pulumi configuration
+            env:
+              - name: PULUMI_HOME
+                value: $WORKSPACE_HOME/.pulumi
+            dirs: [.pulumi]
+            gitignore:
+              - comment: Pulumi credentials
+                patterns: [.pulumi/credentials.json]
+
+    Files with a source are copied from a shared file. With strategy: link
+    they are symlinked to it instead, and update repairs the links it made
+    when they break or the source moves; files and links made by hand are
+    left alone:
+
+          - name: company-terraform
+            strategy: link
+            files:
+              - path: .terraformrc
+                source: ~/company/terraformrc
+
+Backup:
+    By default, a backup is created in .backups/update_<timestamp>/ before making changes.
+    Use --no-backup to skip this. With age recipients configured (see
+    'profile restore --help') the backup is encrypted instead.
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showDoctorHelp() {
+	helpText := `Usage: profile doctor [profile-name] [options]
+
+Check the environment and profiles for common problems.
+
+The checks use the same feature definitions as create and update, so a
+profile reported as healthy is one that 'profile update' would not change.
+
+Arguments:
+    profile-name        Check only this profile (default: all profiles)
+
+Options:
+    -h, --help          Show this help message
+    --fix               Restrict the permissions of keys and credentials first
+
+Checks:
+    - direnv is installed
+    - Feature definitions (built-in and ~/.config/spm/features.d/*.yaml) are valid
+    - Feature directories exist with the expected permissions
+    - Feature variables are exported in .envrc
+    - Feature patterns are present in .gitignore
+    - Keys and credentials are not readable by others (see 'profile perms')
+    - Managed files were not changed outside spm (see 'profile verify')
+
+Examples:
+    profile doctor
+    profile doctor my-project
+    profile doctor --fix
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showLogHelp() {
+	helpText := `Usage: profile log [profile-name] [options]
+
+Show the changes spm applied to a profile, newest first, with the spm
+version and the template version that made each change.
+
+create, update, and 'feature enable|disable' record what they change in
+.spm-history.jsonl in the profile. For profiles that are git repositories,
+--git lists the commits that changed the managed files (.envrc,
+.gitconfig, .gitignore, profile.yaml) instead, including those from before
+the history was recorded.
+
+Arguments:
+    profile-name        Name of the profile (interactive selection if omitted)
+
+Options:
+    -h, --help          Show this help message
+    -n, --limit <count> Show only the newest entries
+    --git               Show the commits that changed managed files
+    --output json|yaml  Print the entries as JSON or YAML
+
+Examples:
+    profile log my-project
+    profile log my-project -n 5
+    profile log my-project --git
+    profile log my-project --output json
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showWhichHelp() {
+	helpText := `Usage: profile which [options]
+
+Print the name and directory of the active profile, separated by a tab:
+the profile direnv loaded (WORKSPACE_HOME), or else the profile the
+current directory is in. Exits with 1 when there is none, so prompts and
+scripts can test for a profile.
+
+Options:
+    -h, --help          Show this help message
+    --name              Print only the profile name
+    --path              Print only the profile directory
+    --output json|yaml  Print the name, path, and how it was found
+
+Examples:
+    profile which
+    profile which --name
+    cd "$(profile which --path)"
+    profile which >/dev/null 2>&1 && echo "in a profile"
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showPermsHelp() {
+	helpText := `Usage: profile perms <command> [profile-name] [options]
+
+Check and restrict the permissions of the files holding credentials in a
+profile. ssh refuses keys that others can read, and gpg, kubectl, and
+others warn about them.
+
+Commands:
+    check [name]        Report files that grant more than they should (default)
+    fix [name]          Remove the permissions they should not grant
+
+Arguments:
+    profile-name        Name of the profile (interactive selection if omitted)
+
+Options:
+    -h, --help          Show this help message
+    -a, --all           Check or fix every profile
+    -j, --jobs <count>  Check this many profiles at once with --all (default:
+                        4, or jobs= in ~/.config/spm/config). Status lines
+                        keep the order of the profiles
+    --output json|yaml  Print the results of check as JSON or YAML
+
+Expected permissions (at most):
+    .ssh, .gnupg, .gnupg/private-keys-v1.d
+                                        700
+    .ssh/id_* (not *.pub), *.pem, *.key, .ssh/config
+                                        600
+    GnuPG private keys                  600
+    .aws/credentials, .kube/config, .netrc, .npmrc, .pypirc, .pgpass, .env,
+    .terraformrc, .terraform.d/credentials.tfrc.json,
+    .gcloud and .azure token caches, .config/docker/config.json,
+    .config/gh/hosts.yml                600
+
+Symbolic links are skipped. 'profile doctor' reports the same files,
+'profile doctor --fix' and 'profile update' fix them as well.
+
+Examples:
+    profile perms check my-project
+    profile perms fix my-project
+    profile perms check --all --output json
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showCleanHelp() {
+	helpText := `Usage: profile clean [profile-name] [options]
+
+Empty the caches tools keep in a profile and report the space freed. The
+caches are the directories declared by the profile's features (caches: in
+feature definitions); the directories themselves are kept, and a cache
+that leads outside the profile through a symbolic link is skipped.
+
+Arguments:
+    profile-name        Name of the profile (interactive selection if omitted)
+
+Options:
+    -h, --help          Show this help message
+    -a, --all           Clean every profile
+    -j, --jobs <count>  Clean this many profiles at once with --all (default:
+                        4, or jobs= in ~/.config/spm/config). Status lines
+                        keep the order of the profiles
+    --dry-run           Show what would be removed and its size
+
+Caches of the built-in features:
+    aws           .aws/cli/cache
+    kubernetes    .kube/cache, .kube/http-cache
+    helm          .cache/helm
+    terraform     .terraform.d/plugin-cache
+    gcloud        .gcloud/logs
+
+Examples:
+    profile clean my-project --dry-run
+    profile clean --all
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showGcHelp() {
+	helpText := `Usage: profile gc [options]
+
+Remove what spm leaves behind over time and report the space reclaimed:
+
+    - backups made by update past the retention policy: the newest
+      backup_keep backups of each profile are kept (default: 5), and the
+      others once they are older than backup_max_age days (default: 30)
+    - profile locks left by spm processes that are no longer running
+    - temporary files of writes that were interrupted, more than an hour
+      old, in profiles and the spm config and state directories
+    - clones of template repositories that are no longer registered
+    - the spm cache directory (~/.cache/spm), which is recreated on use
+
+Profiles another spm process is changing keep their backups.
+
+Options:
+    -h, --help          Show this help message
+    --dry-run           Show what would be removed and its size
+    --keep <count>      Keep this many backups of each profile (backup_keep)
+    --max-age <days>    Remove older backups beyond those (backup_max_age)
+
+Examples:
+    profile gc --dry-run
+    profile gc --keep 3 --max-age 7
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showStatusHelp() {
+	helpText := `Usage: profile status [options]
+
+Show whether direnv is installed and loaded, the allow state of each
+profile's .envrc, and alerts: managed files changed outside spm, and
+cached credentials about to expire (AWS SSO sessions, assumed-role
+credentials of the AWS CLI, Kubernetes client certificates).
+
+Options:
+    -h, --help          Show this help message
+    --notify            Send a desktop notification for new alerts (on with
+                        notify= in ~/.config/spm/config)
+    --within <duration> Report credentials expiring within this time
+                        (default: 1h, or expiry_warning= in the config)
+    --output json|yaml  Print the status as JSON or YAML
+
+Each alert is notified once, until it goes away and comes back. The
+notifier is terminal-notifier or osascript on macOS and notify-send on
+Linux; set notify= to one of them, or to auto.
+
+Examples:
+    profile status
+    profile status --notify --within 4h
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showWatchHelp() {
+	helpText := `Usage: profile watch [options]
+
+Check every profile for managed files changed outside spm and cached
+credentials about to expire at an interval, and send a desktop notification
+for each new alert, until interrupted.
+
+Options:
+    -h, --help              Show this help message
+    --interval <duration>   Time between checks (default: 5m)
+    --within <duration>     Report credentials expiring within this time
+                            (default: 1h, or expiry_warning= in the config)
+    --notifier <name>       auto, terminal-notifier, osascript, or notify-send
+                            (default: notify= in the config, or auto)
+    --once                  Check once and exit, e.g. from cron or launchd
+
+Examples:
+    profile watch
+    profile watch --interval 15m --within 2h
+    profile watch --once
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showServeHelp() {
+	helpText := `Usage: profile serve [options]
+
+Serve the profiles over HTTP so editor extensions and status bar widgets
+can list them, resolve their environment, and switch between them without
+running profile and parsing its output. Requests and responses are JSON.
+
+By default the API is served on a unix socket only you can open,
+~/.local/state/spm/api.sock. With --addr it is served on a loopback address
+instead, and every request must send the token in
+~/.local/state/spm/api-token as "Authorization: Bearer <token>".
+
+Endpoints:
+    GET  /v1/version                  The spm version
+    GET  /v1/profiles                 Every profile
+    GET  /v1/profiles/<name>          A profile (<root>/<name> in a root)
+    GET  /v1/which?dir=<path>         The profile a directory is in
+    GET  /v1/env?profile=<name>       The environment the profile loads
+                                      (null values are unset)
+    GET  /v1/status[?profile=<name>]  Drift and expiring credentials
+    GET  /v1/diagnostics[?profile=<name>]
+                                      What doctor and verify report, by file
+    POST /v1/switch                   Allow a profile with direnv and return
+                                      it with its environment; the body is
+                                      {"profile": "<name>"}
+
+Errors are {"error": "..."} with status 400 (invalid input), 404 (no such
+profile), 409 (another spm process is changing the profile), or 500.
+
+With --stdio, profile speaks JSON-RPC 2.0 on stdin and stdout instead, for
+editor extensions that start it as a child process. Messages are framed with
+a Content-Length header as in the Language Server Protocol, so LSP client
+libraries work as they are. Methods take {"profile": "<name>"} or
+{"dir": "<path>"} as params:
+
+    initialize              The spm version and the methods
+    profiles/list           Every profile
+    profiles/get            A profile
+    profiles/which          The profile a directory is in
+    profiles/env            The environment the profile loads
+    profiles/activate       Allow a profile with direnv and return it with
+                            its environment, to start a terminal with
+    profiles/alerts         Drift and expiring credentials
+    profiles/diagnostics    What doctor and verify report, by file
+    shutdown, exit          End the session
+
+Errors use the JSON-RPC codes, and 1 (no such profile) or 2 (another spm
+process is changing the profile).
+
+Options:
+    -h, --help              Show this help message
+    --socket <path>         Serve on this unix socket
+    --addr <host:port>      Serve on a loopback address, e.g. 127.0.0.1:7878
+    --stdio                 Speak JSON-RPC on stdin and stdout
+
+Examples:
+    profile serve
+    curl --unix-socket ~/.local/state/spm/api.sock http://spm/v1/profiles
+    profile serve --addr 127.0.0.1:7878
+    profile serve --stdio
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showDaemonHelp() {
+	helpText := `Usage: profile daemon <command> [options]
+
+Run in the background what watch and gc do by hand: check every profile for
+drift and expiring credentials and notify new alerts, and once a day prune
+backups past the retention of gc and update the template repositories.
+
+Commands:
+    start       Start the daemon in the background
+    stop        Stop the daemon
+    status      Show whether the daemon runs and when its tasks last ran
+    install     Write a launchd agent (macOS) or systemd user unit (Linux)
+                that starts the daemon at login
+    run         Run the daemon in the foreground (what start and the
+                service run)
+
+Options:
+    -h, --help                  Show this help message
+    --interval <duration>       Time between alert checks (default: 5m)
+    --maintenance <duration>    Time between backup pruning and template
+                                updates (default: 24h)
+    --within <duration>         Report credentials expiring within this time
+                                (default: 1h, or expiry_warning= in the config)
+    --notifier <name>           auto, terminal-notifier, osascript, or
+                                notify-send (default: notify= in the config)
+    --print                     Print the service file instead of writing it
+                                (install)
+    --output json|yaml          Print the status as JSON or YAML (status)
+
+The daemon logs to ~/.local/state/spm/daemon.log. Backups are pruned with
+backup_keep= and backup_max_age= from the config; without a notifier,
+alerts are only logged.
+
+Examples:
+    profile daemon start
+    profile daemon status
+    profile daemon install
+    profile daemon install --interval 15m --print
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showPruneHelp() {
+	helpText := `Usage: profile prune --orphans [directory...] [options]
+
+Find the directories in profiles_dir and the other roots that are not
+profiles, which every command skips because they have no .envrc (or it is
+a broken link), and explain why. Pass an action to adopt, archive, or
+delete them; without one, a terminal asks what to do with each.
+
+Arguments:
+    directory           Orphaned directories to act on (default: all of them)
+
+Options:
+    -h, --help          Show this help message
+    --orphans           Find directories without an .envrc
+    --adopt             Make them profiles: write the .envrc of their features
+                        (and profile.yaml if missing), keeping their files
+    --archive           Pack them into ~/.local/state/spm/archive/<name>_<date>.tar.gz
+                        and remove them
+    --delete            Delete them (asks for confirmation)
+    -f, --force         Delete without asking for confirmation
+    --dry-run           Show what would be done
+    --output json|yaml  Print the orphaned directories as JSON or YAML
+
+Hidden directories are never considered. After adopting a directory, run
+'profile update <name>' to add the directories and files of its features.
+
+Examples:
+    profile prune --orphans
+    profile prune --orphans acme --adopt
+    profile prune --orphans --archive --dry-run
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showPolicyHelp() {
+	helpText := `Usage: profile policy <command> [profile-name] [options]
+
+Check profiles against a team policy: the variables every .envrc must
+export, patterns no profile file may contain, patterns the .gitignore must
+ignore, and the features every profile must enable.
+
+Commands:
+    check [name]        Report the ways profiles violate the policy (default)
+    update              Fetch the latest policy from its repository
+
+Arguments:
+    profile-name        Name of the profile (interactive selection if omitted)
+
+Options:
+    -h, --help          Show this help message
+    -a, --all           Check every profile
+    -j, --jobs <count>  Check this many profiles at once with --all (default:
+                        4, or jobs= in ~/.config/spm/config). Status lines
+                        keep the order of the profiles
+    --output json|yaml  Print the results of check as JSON or YAML
+
+Configuration (~/.config/spm/config):
+    policy=<git URL or path>
+                        Where the policy is read from. A git repository is
+                        cloned on first use to ~/.local/state/spm/policy and
+                        must hold policy.yaml at its root; a path names the
+                        file or a directory holding policy.yaml.
+    policy_enforce=true Make 'profile update' fail while a profile it updated
+                        violates the policy
+
+Policy file (policy.yaml):
+    name: acme-platform
+    required_env:
+      - AWS_CONFIG_FILE
+      - KUBECONFIG
+    forbidden_patterns:
+      - pattern: 'AWS_SECRET_ACCESS_KEY=[^$]'
+        files: [.envrc, .env]          # globs in the profile (default: .envrc)
+        message: keep AWS keys in .aws/credentials
+    required_gitignore:
+      - .env
+      - .aws/credentials
+    required_features:
+      - ssh
+      - aws
+
+    Forbidden patterns are regular expressions matched against each line;
+    the matching text is not printed.
+
+Examples:
+    profile policy check my-project
+    profile policy check --all --output json
+    profile policy update
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showGuardHelp() {
+	helpText := `Usage: profile guard <command> [profile-name] [options]
+
+Install git hooks in a profile repository that block commits and pushes
+adding credentials the .gitignore failed to cover.
+
+The hooks scan the lines being added for well-known token formats (AWS and
+Google keys, GitHub, GitLab, Slack, npm, and Terraform Cloud tokens, private
+keys, passwords in URLs) and reject files that hold credentials by their
+name (.env, .netrc, .aws/credentials, .ssh/id_*, *.pem, *.key).
+
+Commands:
+    install [name]      Install the pre-commit and pre-push hooks
+    uninstall [name]    Remove the hooks guard installed
+    scan                Scan the staged changes of the repository in the
+                        current directory (run by the hooks)
+
+Arguments:
+    profile-name        Name of the profile (interactive selection if omitted)
+
+Options:
+    -h, --help          Show this help message
+    --hook <name>       Only install or remove pre-commit or pre-push
+    -f, --force         Replace existing hooks (kept as <hook>.backup)
+    --pre-push          With scan: scan the commits being pushed, as listed
+                        on stdin by git
+    --rules <file>      With scan: also load a gitleaks configuration file
+
+The profile has to be a git repository (see 'profile sync init'). Mark a
+false positive with a "spm:allow" (or "gitleaks:allow") comment on its line,
+add the fingerprint the hook prints to .gitleaksignore in the profile, or
+bypass the hooks once with 'git commit --no-verify' or 'git push --no-verify'.
+
+Rulesets:
+    The hooks read gitleaks configuration files, so existing rulesets can be
+    reused: those listed in ~/.config/spm/config, then .gitleaks.toml in the
+    profile repository.
+
+        guard_rules=~/security/gitleaks.toml,~/security/extra.toml
+
+    As with gitleaks, a file's rules replace the built-in ones unless it has
+
+        [extend]
+        useDefault = true
+
+    Rules (regex, path, keywords, secretGroup, entropy) and allowlists
+    (paths, regexes, stopwords, commits) are supported.
+
+Examples:
+    profile guard install my-project
+    profile guard install my-project --hook pre-push
+    profile guard uninstall my-project
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showVerifyHelp() {
+	helpText := `Usage: profile verify [profile-name] [options]
+
+Report the managed files of a profile that were modified, removed, or had
+their permissions changed outside spm.
+
+spm records the checksum and permissions of the files it generates (.envrc,
+.gitconfig, .gitignore, .ssh/config, bin/ssh) in .spm-checksums.json in the
+profile whenever it writes them. 'profile doctor' reports the same changes.
+Editing these files by hand is fine; --accept records their current state
+so they are no longer reported. For profiles created before checksums were
+recorded, --accept records the first ones.
+
+Arguments:
+    profile-name        Name of the profile (interactive selection if omitted)
+
+Options:
+    -h, --help          Show this help message
+    --accept            Record the current state of the managed files
+    --output json|yaml  Print the results as JSON or YAML
+
+Exit status is 1 when files changed outside spm.
+
+Examples:
+    profile verify my-project
+    profile verify my-project --accept
+    profile verify my-project --output json
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showInitHelp() {
+	helpText := `Usage: profile init [options]
+
+Initialize the profile manager configuration.
+
+This command creates a ~/.config/spm/config configuration file that stores
+the path to your profiles directory. If not initialized, the tool will use
+the default path: ~/workspaces/profiles
+
+Options:
+    -h, --help              Show this help message
+    -f, --force             Overwrite existing configuration
+    -i, --interactive       Interactive setup (prompt for paths)
+    --profiles-dir <path>   Set profiles directory path
+
+Examples:
+    # Initialize with default path
+    profile init
+
+    # Interactive initialization
+    profile init --interactive
+
+    # Initialize with custom path
+    profile init --profiles-dir ~/my-profiles
+
+    # Overwrite existing configuration
+    profile init --force
+
+Configuration:
+    The configuration is stored in ~/.config/spm/config with the following format:
+    
+    profiles_dir=<path>
+    
+    You can edit this file manually if needed. Paths can use ~ for home directory
+    and environment variables will be expanded.
+
+Files:
+    Following the XDG Base Directory specification, spm keeps its files in:
+
+    $XDG_CONFIG_HOME/spm (~/.config/spm)      config, features.d, templates,
+                                              hooks, plugins
+    $XDG_STATE_HOME/spm (~/.local/state/spm)  debug log, template repositories,
+                                              profile locks
+    $XDG_CACHE_HOME/spm (~/.cache/spm)        plugin handshakes
+
+    A ~/.profile-manager file from earlier versions is moved to
+    ~/.config/spm/config the next time profile runs.
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showDotenvHelp() {
+	helpText := `Usage: profile dotenv <command> [profile-name] [NAME=value...] [options]
+
+Manage the variables in the profile's .env, which its .envrc loads with
+dotenv_if_exists. The file is kept at 0600 and gitignored.
+
+A secret does not have to be written to .env: with --secret the variable is
+loaded from the profile's secrets provider each time the profile loads (see
+'profile secrets --help'), and only the reference is kept, in profile.yaml.
+Values in .env override secrets of the same name, so setting one removes
+the other.
+
+Commands:
+    list                      List the variables, with secret values masked,
+                              and those loaded from the secrets provider
+    set NAME=value...         Set variables in .env
+    set NAME --secret <ref>   Load NAME from the secrets provider instead
+    unset NAME...             Remove variables from .env or the secrets provider
+
+Options:
+    -p, --profile <name>  Profile name (alternative to the positional argument)
+    --secret <ref>        Secret reference for the profile's secrets provider
+    -h, --help            Show this help message
+
+Examples:
+    profile dotenv list acme
+    profile dotenv set acme LOG_LEVEL=debug API_URL=https://api.acme.dev
+    profile dotenv set acme DATABASE_PASSWORD --secret secret/acme/db#password
+    profile dotenv unset acme LOG_LEVEL
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showSecretsHelp() {
+	helpText := `Usage: profile secrets <command> [profile-name] [arguments] [options]
+
+Load secret environment variables from a secret manager when a profile is
+activated. Only references are stored in the profile (in profile.yaml); the
+values are fetched by direnv each time the profile loads.
+
+Commands:
+    vault [profile]                 Use HashiCorp Vault as the secrets provider
+    bws [profile]                   Use Bitwarden Secrets Manager as the secrets provider
+    keychain [profile]              Use the macOS Keychain as the secrets provider
+    wincred [profile]               Use Windows Credential Manager as the secrets provider
+    <provider> [profile]            Use a provider registered by a plugin (see 'profile plugin list')
+    store [profile] <VAR> [ref]     Prompt for a value, save it in the Keychain or
+                                    Credential Manager under ref (default: VAR),
+                                    and load VAR from it
+    set [profile] <VAR> <ref>       Load VAR from a secret reference
+    remove [profile] <VAR>          Stop loading VAR
+    list [profile]                  List configured secrets
+    check [profile]                 Resolve every secret now and report failures
+
+Vault Options:
+    --address <url>         Vault server address (VAULT_ADDR)
+    --namespace <ns>        Vault Enterprise namespace (VAULT_NAMESPACE)
+    --role <role>           Role used in the login hint (e.g. for OIDC)
+    --auth-method <method>  Auth method used in the login hint (default: oidc with --role)
+
+Bitwarden Options:
+    --server-url <url>      Self-hosted or EU server (BWS_SERVER_URL)
+
+Keychain and Credential Manager Options:
+    --service <name>        Namespace for the profile's items (default: profile:<name>)
+
+Options:
+    -p, --profile <name>    Profile name (alternative to the positional argument)
+    -h, --help              Show this help message
+
+Vault References:
+    <path>#<field>, resolved with 'vault kv get -field=<field> <path>'
+
+Bitwarden References:
+    <secret-id>, resolved with 'bws secret get <secret-id>' (requires jq)
+    The machine account token is read from BWS_ACCESS_TOKEN, which must be
+    exported before the profile loads (in your shell, or the global
+    exports.sh) so it is never stored in the profile
+
+Keychain References:
+    <account>, a generic password in the profile's service, resolved with
+    'security find-generic-password -s <service> -a <account> -w'
+
+Credential Manager References:
+    <name>, the generic credential <service>/<name>, stored with cmdkey and
+    resolved with 'profile secrets read-credential <service>/<name>' (Windows
+    has no built-in command that prints a stored secret)
+
+Examples:
+    profile secrets vault my-project --address https://vault.example.com --role dev
+    profile secrets set my-project DB_PASSWORD secret/myapp/db#password
+    profile secrets bws my-project
+    profile secrets set my-project API_KEY be8e0ad8-d545-4017-a55a-b02f014d4158
+    profile secrets keychain my-project
+    profile secrets store my-project GITHUB_TOKEN
+    profile secrets check my-project
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showAWSHelp() {
+	helpText := `Usage: profile aws setup [profile-name] [options]
+
+Configure the AWS CLI for a workspace profile. Writes a named profile (and an
+IAM Identity Center / SSO session if a start URL is given) to the profile's
+.aws/config and exports AWS_PROFILE from .envrc.
+
+Options:
+    --aws-profile <name>      AWS profile name (default: workspace profile name)
+    --region <region>         Default region (default: us-east-1)
+    --output <format>         Default output format (json, yaml, text, table)
+    --sso-start-url <url>     IAM Identity Center start URL (enables SSO)
+    --sso-region <region>     IAM Identity Center region (default: --region)
+    --sso-session <name>      SSO session name (default: AWS profile name)
+    --account-id <id>         AWS account ID for the SSO profile
+    --role-name <name>        Permission set / role name for the SSO profile
+    --login                   Run 'aws sso login' without asking
+    --no-login                Do not offer to run 'aws sso login'
+    -h, --help                Show this help message
+
+Missing values are prompted for interactively.
+
+Examples:
+    # SSO profile
+    profile aws setup acme --sso-start-url https://acme.awsapps.com/start \
+        --account-id 123456789012 --role-name AdministratorAccess
+
+    # Plain named profile (add keys with 'aws configure')
+    profile aws setup personal --region eu-west-1
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showAzureHelp() {
+	helpText := `Usage: profile azure setup [profile-name] [options]
+
+Configure the Azure CLI for a workspace profile. Writes CLI defaults to the
+profile's .azure/config, exports AZURE_TENANT_ID and AZURE_SUBSCRIPTION_ID
+from .envrc, and signs in with the profile's AZURE_CONFIG_DIR so that
+activating the profile uses the right Azure context.
+
+Options:
+    --tenant <id>             Tenant ID or domain
+    --subscription <id>       Subscription ID or name
+    --location <location>     Default location for new resources
+    --group <name>            Default resource group
+    --login                   Run 'az login' without asking
+    --no-login                Do not sign in or select the subscription
+    -h, --help                Show this help message
+
+Missing values are prompted for interactively.
+
+Examples:
+    profile azure setup acme --tenant acme.onmicrosoft.com --subscription "Acme Production"
+    profile azure setup acme --location westeurope --no-login
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showGcloudHelp() {
+	helpText := `Usage: profile gcloud <command> [profile-name] [options]
+
+Manage the Google Cloud SDK configuration stored in the profile's .gcloud
+directory (CLOUDSDK_CONFIG).
+
+Commands:
+    setup               Create or update a named configuration and activate it
+    check               Verify that gcloud uses the profile's configuration
+
+Options:
+    --config <name>     Configuration name (default: profile name)
+    --project <id>      Default project
+    --account <email>   Default account
+    --region <region>   Default compute region
+    --zone <zone>       Default compute zone
+    -h, --help          Show this help message
+
+Missing values are prompted for interactively.
+
+Examples:
+    profile gcloud setup acme --project acme-prod --account me@acme.com --region europe-west1
+    profile gcloud check acme
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showKubeHelp() {
+	helpText := `Usage: profile kube <command> [profile-name] [options]
+
+Manage the Kubernetes contexts isolated in a profile's .kube/config.
+
+Commands:
+    import              Copy contexts (with their clusters and users) from the
+                        global kubeconfig into the profile
+    list                Show which contexts each profile contains (* = current)
+
+Options:
+    --context <name>    Context to import (repeatable or comma-separated;
+                        interactive selection if omitted)
+    --from <path>       Kubeconfig to import from
+                        (default: first entry of $KUBECONFIG, or ~/.kube/config)
+    --set-current       Make the first imported context the current context
+    -h, --help          Show this help message
+
+Examples:
+    profile kube import acme --context acme-prod,acme-staging
+    profile kube list
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showTerraformHelp() {
+	helpText := `Usage: profile terraform <command> [profile-name] [options]
+
+Manage the Terraform CLI configuration (TF_CLI_CONFIG_FILE) of a profile.
+
+Commands:
+    setup               Write .terraformrc with plugin_cache_dir pointing at the
+                        profile's .terraform.d/plugin-cache
+    credentials         Store an API token for Terraform Cloud / Enterprise
+
+Options:
+    --host <hostname>   Terraform Cloud / Enterprise host (default: app.terraform.io)
+    --token <token>     Token written to a credentials block in .terraformrc
+    --secret <ref>      Load the token from the profile's secrets provider instead
+                        (exported as TF_TOKEN_<host>)
+    -h, --help          Show this help message
+
+Examples:
+    profile terraform setup acme
+    profile terraform credentials acme --host app.terraform.io --token "$TOKEN"
+    profile terraform credentials acme --secret secret/acme/tfc#token
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showFeatureHelp() {
+	helpText := `Usage: profile feature <command> [profile-name] [feature...]
+
+Manage which features a profile uses. Default features apply to every
+profile; optional features (e.g. podman) only apply once enabled. The
+selection is stored in the profile's profile.yaml.
+
+Commands:
+    list [profile]                  List all features, or the features of a profile
+    enable <profile> <feature>...   Enable features and add their directories,
+                                    variables, and .gitignore patterns
+    disable <profile> <feature>...  Disable features (existing lines are kept)
+
+Options:
+    -p, --profile <name>    Profile name (alternative to the positional argument)
+    -h, --help              Show this help message
+
+Examples:
+    profile feature list
+    profile feature enable my-project podman
+    profile create my-project --feature podman
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showRootHelp() {
+	helpText := `Usage: profile root <command> [name] [path]
+
+Keep profiles in more than one directory, e.g. work profiles on an
+encrypted volume and personal ones in ~/profiles. Besides profiles_dir,
+roots are listed in the [roots] section of ~/.config/spm/config:
+
+    profiles_dir=~/profiles
+
+    [roots]
+    work=/Volumes/Secure/profiles
+
+Profiles in a root are named <root>/<profile> (work/acme) in every
+command. An unqualified name also finds a profile in a root when
+profiles_dir has no profile of that name and only one root does. Roots
+that are not available, such as a volume that is not mounted, are left out
+of listings. Create a profile in a root with 'profile create work/acme' or
+'profile create acme --root work'.
+
+Commands:
+    list                    List profiles_dir (as 'default') and the roots, with
+                            the number of profiles in each
+    add <name> <path>       Add a root (the directory does not have to exist yet)
+    remove <name>           Remove a root; its profiles are left in place
+
+Options:
+    -h, --help              Show this help message
+
+Examples:
+    profile root add work /Volumes/Secure/profiles
+    profile create acme --root work
+    profile update work/acme
+    profile root list
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showTagHelp() {
+	helpText := `Usage: profile tag <command> [profile-name] [tag...]
+
+Manage the tags of profiles. Tags are stored in the profile's profile.yaml,
+are searched in the profile selector, and select the profiles bulk commands
+run for:
+
+    profile list --tag client:acme
+    profile update --tag cloud:aws
+    profile sync pull --tag active
+    profile direnv allow --tag client:acme
+
+A tag is made of letters, digits, and . _ : / - (use key:value tags such as
+client:acme to group profiles). --tag may be repeated or comma-separated; a
+profile must carry every tag given, and patterns such as 'client:*' match
+any tag of that form.
+
+Commands:
+    list                            List the tags in use and their profiles
+    add <profile> <tag>...          Add tags to a profile
+    remove <profile> <tag>...       Remove tags from a profile
+
+Options:
+    -p, --profile <name>    Profile name (alternative to the positional argument)
+    -h, --help              Show this help message
+
+Examples:
+    profile tag add acme-prod client:acme cloud:aws active
+    profile tag remove acme-prod active
+    profile tag list
+    profile create acme-dev --tag client:acme,cloud:aws
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showTemplateHelp() {
+	helpText := `Usage: profile template <command> [arguments] [options]
+
+Manage the templates profiles are created from. Besides the built-in
+templates, templates come from ~/.config/spm/templates/ (local) and from
+template repositories: git repositories where each top-level directory is
+a template holding envrc.tmpl, gitconfig.tmpl, and gitignore.tmpl files
+(see 'profile create --help').
+
+Files are resolved from local templates first, then from the repositories
+in the order they were added, then from the built-in templates. Each
+repository is locked at a commit, recorded in
+~/.config/spm/template-repos.yaml, until it is updated; profiles record the
+repository commit they were created from.
+
+Commands:
+    list                    List templates and template repositories
+    add <url>               Clone a template repository and lock its current commit
+    update [repository]     Fetch repositories and lock their latest commit
+    remove <repository>     Remove a template repository
+
+Options:
+    --name <name>           Repository name for add (default: from the URL)
+    --ref <branch|tag>      Pin add to a branch or tag (default: the default branch)
+    --locked                With update, only restore missing clones at their
+                            locked commits (e.g. on a new machine)
+    -h, --help              Show this help message
+
+Examples:
+    profile template add git@github.com:org/spm-templates.git --ref v1
+    profile create acme-api --template acme
+    profile template update
+    profile template update --locked
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showPluginHelp() {
+	helpText := `Usage: profile plugin <command> [options]
 
-Usage: profile <command> [arguments]
+Plugins extend profile without forking it. A plugin is an executable named
+spm-<name> in ~/.config/spm/plugins/ or on PATH (the plugin directory wins
+for duplicate names). When run with --spm-handshake it prints JSON
+describing what it adds:
+
+    {
+      "protocol": 1,
+      "description": "Acme tooling",
+      "commands": [{"name": "deploy", "description": "Deploy the workspace"}],
+      "features": [{"name": "acme", "env": [{"name": "ACME_HOME", "value": "$WORKSPACE_HOME/.acme"}]}],
+      "secrets_providers": [{"name": "acme-vault", "description": "Acme secret store"}]
+    }
+
+Commands:      'profile deploy args...' runs 'spm-<name> deploy args...' with
+               SPM_PROFILES_DIR and SPM_PROTOCOL set; its exit status is kept
+Features:      same fields as ~/.config/spm/features.d/*.yaml, merged after them
+Secrets:       'profile secrets acme-vault [profile]' selects the provider;
+               refs are checked with 'spm-<name> secrets validate <provider> <ref>'
+               and resolved with 'spm-<name> secrets resolve <provider> <ref>'
+
+Handshakes are cached in ~/.cache/spm/plugins.json until the
+executable changes. Built-in commands and providers cannot be overridden.
 
 Commands:
-    init [options]             Initialize the profile manager configuration
-        Options:
-            --profiles-dir <path>    Set profiles directory path
-            --interactive            Interactive setup
-            --force                  Overwrite existing configuration
+    list                    List plugins and what they register
 
-    create <name> [options]     Create a new workspace profile
-        Options:
-            --template <type>       Use template: personal, work, client, basic
-            --git-name <name>       Set git user name
-            --git-email <email>     Set git user email
-            --interactive           Interactive setup (default if no flags provided)
-            --no-interactive        Disable interactive mode
-            --force                 Overwrite existing profile
+Options:
+    -h, --help              Show this help message
+`
+	fmt.Print(helpText)
+}
 
-    update [name] [options]     Update an existing profile with new features
-        Options:
-            --dry-run              Preview changes without applying
-            --force                 Overwrite existing files
-            --no-backup            Skip creating backup
-        Note: Interactive selection by default if name is omitted
+func (a *App) showPresetHelp() {
+	helpText := `Usage: profile preset <command> [options]
+
+A preset bundles what an organization expects of a workspace, so
+'profile create <name> --preset <preset>' creates a compliant profile
+without the wizard: the template, the template repository commit it was
+written for, and default profile.yaml values.
+
+Presets are read from YAML files, the first preset with a name winning:
+
+    ~/.config/spm/presets.d/*.yaml
+    presets.yaml next to the team policy (see 'profile policy --help')
+    presets.yaml at the root of each template repository
+
+    presets:
+      - name: acme-aws
+        description: Acme engineering workspace on AWS
+        template: acme
+        template_repo:                # optional pin
+          name: acme-templates
+          url: git@github.com:acme/spm-templates.git
+          ref: main
+          commit: 3f2a9c1
+        manifest:                     # as in profile.yaml
+          tags: [org:acme, cloud:aws]
+          features:
+            enable: [terraform]
+            disable: [azure, gcloud]
+          secrets:
+            provider: 1password
+          vars:
+            AWS_REGION: eu-west-1
+          tools: [awscli2, terraform]
+
+A pinned template repository is registered when missing, and its locked
+commit is moved to the pinned one (as 'profile template' would), before the
+template is rendered; --dry-run only reports that it needs fetching.
+
+Options given to create override the preset's values; features, tags, and
+vars are merged. The preset name is recorded in profile.yaml (preset:).
 
-    select [name] [options]     Select and switch to a profile
-        Options:
-            --allow-direnv          Automatically allow direnv for selected profile
-        Note: Interactive selection if name is omitted
+Commands:
+    list                    List presets (default)
 
-    list [options]              List all workspace profiles
-        Options:
-            --verbose               Show detailed information (disables interactive)
-            --config                Show git configuration (disables interactive)
-            --no-interactive         Disable interactive mode
-        Note: Interactive by default unless flags are provided
+Options:
+    -h, --help              Show this help message
+    --output json|yaml      Print the presets as JSON or YAML
 
-    delete [name] [options]     Delete a workspace profile
-        Options:
-            --force                 Skip confirmation prompt (disables interactive)
-            --dry-run              Preview deletion without deleting (disables interactive)
-            --no-interactive        Disable interactive mode
-        Note: Interactive selection by default if name is omitted
+Examples:
+    profile preset list
+    profile create acme --preset acme-aws
+    profile create acme --preset acme-aws --feature podman --dry-run
+`
+	fmt.Print(helpText)
+}
 
-    restore <name> [options]    Restore a profile from backup
-        Options:
-            --force                 Skip confirmation prompt
-            --dry-run              Preview restore without restoring
-            --file <file>           Restore only a specific file
-            --backup-date <date>    Restore from specific dated backup
+func (a *App) showNpmHelp() {
+	helpText := `Usage: profile npm registry [profile-name] --registry <url> [options]
 
-    info                        Show information about the current profile
-    status                      Show direnv status
-    dotfiles <command> [name]    Manage profile dotfiles
-        Commands:
-            list                    List all dotfiles in a profile
-            edit                    Edit a dotfile interactively
-        Options:
-            --profile, -p <name>    Profile name (interactive if omitted)
-            --file, -f <name>       File name (interactive if omitted)
-            --editor, -e <name>     Editor to use (default: $EDITOR or vim)
-        Note: Interactive by default if profile/file name is omitted
-    sync <command> [name]       Sync operations for profiles
-        Commands:
-            init [--remote <url>]    Initialize repository
-            pull                     Pull changes from remote
-            push [--force]          Push changes to remote
-            sync                    Pull then push (sync)
-            remote <url>            Set or update remote URL
-            status                  Show sync status
-        Options:
-            --no-interactive         Disable interactive profile selection
-        Note: Interactive selection by default if name is omitted (except status)
-    help                        Show this help message
+Configure package registries in the profile's .npmrc (NPM_CONFIG_USERCONFIG),
+which npm, pnpm, and Yarn 1 use while the profile is active. Enables the
+optional node feature if needed.
+
+Options:
+    --registry <url>      Registry URL
+    --scope <@scope>      Only use the registry for this package scope
+    --token <token>       Auth token written to .npmrc (gitignored)
+    --token-env <VAR>     Reference an environment variable instead, e.g. one
+                          loaded from .env or 'profile secrets'
+    -h, --help            Show this help message
 
 Examples:
-    # Create interactively (default behavior)
-    profile create my-project
+    profile npm registry acme --scope @acme --registry https://npm.acme.com --token-env ACME_NPM_TOKEN
+    profile npm registry acme --registry https://registry.npmjs.org
+`
+	fmt.Print(helpText)
+}
 
-    # Create with specific options (disables interactive)
-    profile create my-project --git-name "John Doe" --git-email "john@example.com"
+func (a *App) showGoHelp() {
+	helpText := `Usage: profile go private [profile-name] --module <pattern> [options]
 
-    # Force interactive even with some flags
-    profile create my-project --template work --interactive
+Record private module path patterns in the profile manifest (profile.yaml)
+and export them from .envrc as GOPRIVATE and GONOSUMDB. Enables the optional
+go feature (GOPATH, GOMODCACHE, and GOENV inside the workspace) if needed.
 
-    # Interactive profile selection (default)
-    profile list
+Options:
+    --module <pattern>    Private module pattern (GOPRIVATE); repeatable or
+                          comma-separated, e.g. github.com/acme/*
+    --nosumdb <pattern>   Skip only the checksum database (GONOSUMDB)
+    --clear               Remove the existing patterns first
+    -h, --help            Show this help message
 
-    # List all profiles (non-interactive)
-    profile list --verbose
+Examples:
+    profile go private acme --module github.com/acme/*,go.acme.dev
+    profile go private acme --clear
+`
+	fmt.Print(helpText)
+}
 
-    # Select a profile to switch to
-    profile select
-    profile select my-project
+func (a *App) showBrewHelp() {
+	helpText := `Usage: profile brew <command> [profile-name] [options]
 
-    # Delete a profile
-    profile delete old-project
+Each profile can carry a Brewfile (in the profile root, committed with the
+profile) listing the Homebrew formulae, casks, and taps it needs.
 
-    # Restore from backup (will list available backups)
-    profile restore my-project
-    profile restore my-project --backup-date 2024-11-29_14-30-45
-    profile restore my-project --file .envrc
+Commands:
+    sync, install         Run 'brew bundle install' against the profile's Brewfile
+    dump                  Write currently installed packages to the Brewfile
 
-    # Show current profile info
-    profile info
+Options:
+    --no-upgrade          (sync) Install missing entries without upgrading others
+    --force               (dump) Overwrite an existing Brewfile without asking
+    -h, --help            Show this help message
 
-    # Manage dotfiles (interactive by default)
-    profile dotfiles list              # Interactive profile selection
-    profile dotfiles list my-project  # List dotfiles in specific profile
-    profile dotfiles edit              # Interactive profile and file selection
-    profile dotfiles edit my-project   # Interactive file selection
-    profile dotfiles edit my-project .gitconfig  # Edit specific file
+Examples:
+    profile brew sync acme
+    profile brew dump acme --force
+`
+	fmt.Print(helpText)
+}
 
-    # Sync operations (interactive selection if name omitted)
-    profile sync pull              # Interactive selection
-    profile sync push              # Interactive selection
-    profile sync init my-project --remote https://github.com/user/my-project.git
-    profile sync pull my-project
-    profile sync push my-project
-    profile sync sync my-project
-    profile sync status my-project
+func (a *App) showNixHelp() {
+	helpText := `Usage: profile nix export [profile-name] [options]
 
-Getting Started:
-    1. Initialize:          profile init (or profile init --interactive)
-    2. Create a profile:    profile create my-project --interactive
-    3. Navigate to it:      cd <profiles-dir>/my-project
-    4. Allow direnv:        direnv allow
-    5. Verify:              profile info
+Generate flake.nix in the profile from the tools declared in profile.yaml
+(nixpkgs attribute names) and add a 'use flake' block to .envrc, so direnv
+loads the devShell whenever Nix is installed.
 
-For more information, see:
-    - docs/QUICKSTART.md - Quick start guide
-    - README.md          - Full documentation
-    - docs/examples/     - Example configurations
+Options:
+    --tool <attr>         Add a nixpkgs attribute to the profile's tools;
+                          repeatable or comma-separated
+    --nixpkgs <ref>       nixpkgs flake reference (default: github:NixOS/nixpkgs/nixos-unstable)
+    --force               Overwrite a flake.nix not generated by profile
+    -h, --help            Show this help message
+
+Examples:
+    profile nix export acme --tool terraform,kubectl,awscli2
+    profile nix export acme --nixpkgs github:NixOS/nixpkgs/nixos-24.05
 `
 	fmt.Print(helpText)
 }
 
-func (a *App) showSyncHelp() {
-	helpText := `Sync Operations for Profiles
+func (a *App) showDirenvHelp() {
+	helpText := `Usage: profile direnv <command> [profile-name] [options]
 
-Usage: profile sync <command> [profile-name] [options]
+Check whether direnv will load each profile's .envrc and allow it on demand.
+
+States:
+    allowed               direnv loads the .envrc
+    not allowed           the .envrc has never been allowed
+    stale                 the .envrc changed since it was allowed
+    blocked               the .envrc was denied with 'direnv deny'
 
 Commands:
-    init [--remote <url>]    Initialize repository in profile directory
-        Options:
-            --remote <url>       Add remote URL during initialization
-        Note: If profile-name is omitted, interactive selection will be shown
+    status                Show the state of a profile (or all profiles)
+    allow                 Run 'direnv allow' for a profile
 
-    pull                     Pull changes from remote repository
-        Note: Requires remote to be configured
-        Note: If profile-name is omitted, interactive selection will be shown
+Options:
+    --all                 (allow) Allow every profile that is not allowed,
+                          except blocked ones
+    --tag <tag>           Limit status or allow to the profiles with a tag
+    -j, --jobs <count>    (allow) Allow this many profiles at once with --all
+                          or --tag (default: 4, or jobs= in
+                          ~/.config/spm/config)
+    -h, --help            Show this help message
 
-    push [--force]          Push local changes to remote repository
-        Options:
-            --force              Force push (use with caution)
-        Note: Automatically commits uncommitted changes
-        Note: If profile-name is omitted, interactive selection will be shown
+Examples:
+    profile direnv status
+    profile direnv allow my-project
+    profile direnv allow --all
+    profile direnv allow --tag client:acme
+`
+	fmt.Print(helpText)
+}
 
-    sync                    Sync profile (pull then push)
-        Note: Handles cases where remote is not configured
-        Note: If profile-name is omitted, interactive selection will be shown
+func (a *App) showGPGHelp() {
+	helpText := `Usage: profile gpg keygen [profile-name] [options]
 
-    remote <url>            Set or update the remote URL
-        Arguments:
-            <url>                Remote URL (required)
-        Note: If profile-name is omitted, interactive selection will be shown
+Create a per-client signing key in the profile's GNUPGHOME (.gnupg) and set
+it as user.signingkey in the profile's .gitconfig. Enables the optional gpg
+feature if needed. gpg asks for the key's passphrase.
 
-    status                  Show sync status and remote information
-        Note: If profile-name is omitted, shows status for all profiles
+Options:
+    --name <name>         Key owner name (default: user.name from .gitconfig)
+    --email <email>       Key email (default: user.email from .gitconfig)
+    --algo <algo>         Key algorithm (default: ed25519)
+    --expire <period>     Expiration, e.g. 1y or never (default: 2y)
+    --no-sign             Do not turn on commit.gpgsign and tag.gpgsign
+    -h, --help            Show this help message
 
 Examples:
-    # Initialize repository
-    profile sync init my-project
+    profile gpg keygen acme
+    profile gpg keygen acme --email me@acme.com --expire 1y
+`
+	fmt.Print(helpText)
+}
 
-    # Initialize with remote
-    profile sync init my-project --remote https://github.com/user/my-project.git
+func (a *App) showGHHelp() {
+	helpText := `Usage: profile gh <command> [profile-name] [options]
 
-    # Pull latest changes
-    profile sync pull my-project
+Run GitHub CLI authentication against the profile's GH_CONFIG_DIR
+(.config/gh), so gh always acts as the identity used for that client.
+The token in hosts.yml is gitignored.
+
+Commands:
+    login                 Run 'gh auth login' for the profile
+    status                Run 'gh auth status' for the profile
+
+Options:
+    --hostname <host>     GitHub Enterprise hostname (default: github.com)
+    --web                 (login) Authenticate in the browser
+    -h, --help            Show this help message
+
+Examples:
+    profile gh login acme --web
+    profile gh status acme --hostname github.acme.com
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showTmuxHelp() {
+	helpText := `Usage: profile tmux [profile-name] [options]
+
+Attach to the tmux session named after the profile, creating it first if
+needed. Panes start in the workspace with the profile environment loaded
+(through 'direnv exec' when direnv is installed). Inside tmux, the current
+client switches to the session instead of nesting.
+
+Windows are declared in the profile manifest (profile.yaml):
+
+    tmux:
+      windows:
+        - name: shell
+        - name: code
+          dir: code
+          command: nvim .
+        - name: ops
+          panes:
+            - k9s
+            - aws sso login
+          layout: even-horizontal
+
+Without a tmux section the session has a single "shell" window.
+
+Options:
+    -d, --detach          Create the session without attaching
+    -h, --help            Show this help message
+
+Examples:
+    profile tmux acme
+    profile tmux acme --detach
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showPromptHelp() {
+	helpText := `Usage: profile prompt [profile-name] [options]
+
+Write .config/starship.toml in the profile with the profile name at the
+start of the prompt, and enable the optional starship feature, which points
+STARSHIP_CONFIG at it.
+
+Options:
+    --color <style>       Starship style for the profile name
+                          (default: "bold purple"; e.g. "bold red", "#ff8800")
+    --force               Overwrite an existing config without asking
+    -h, --help            Show this help message
+
+Examples:
+    profile prompt acme --color "bold red"
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showCodeHelp() {
+	helpText := `Usage: profile code [profile-name] [options]
+
+Open the profile in VS Code. The first run writes <profile>.code-workspace
+in the profile with:
+    - the profile's code/ directory as the workspace folder
+    - WORKSPACE_HOME and WORKSPACE_PROFILE in the integrated terminal
+    - recommended extensions for the profile's features (plus direnv)
+
+VS Code is launched with '--profile <profile-name>', so extensions and
+settings are kept in a VS Code profile of the same name.
+
+Options:
+    --force               Regenerate an existing .code-workspace file
+    --no-open             Write the workspace file without launching VS Code
+    -h, --help            Show this help message
+
+Examples:
+    profile code acme
+    profile code acme --force --no-open
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showOpenHelp() {
+	helpText := `Usage: profile open [profile-name] [options]
+
+Open the profile with a launcher: in an editor (the default), the file
+manager, or a new terminal. The launcher runs in the profile directory
+with WORKSPACE_HOME and WORKSPACE_PROFILE set.
+
+Launchers are commands in the [open] section of ~/.config/spm/config,
+where {dir} is replaced with the profile directory and {name} with the
+profile name. They replace the built-in editor, finder, and terminal
+launchers, and can add others:
+
+    [open]
+    editor=idea {dir}
+    terminal=open -a iTerm {dir}
+    tmux=tmux new-session -A -s {name} -c {dir}
+
+Built-in launchers:
+    editor      code {dir}
+    finder      open {dir} (macOS), xdg-open {dir} (Linux), the Explorer (Windows)
+    terminal    Terminal (macOS), x-terminal-emulator (Linux), wt -d {dir} (Windows)
+
+Options:
+    --in <launcher>       Open with this launcher (default: editor)
+    -h, --help            Show this help message
+
+Examples:
+    profile open acme
+    profile open acme --in finder
+    profile open acme --in terminal
+`
+	fmt.Print(helpText)
+}
+
+func (a *App) showExportHelp() {
+	helpText := `Usage: profile export [profile-name] [options]
+
+Export the profile's files into a chezmoi source directory, at the path
+chezmoi maps to the profile directory (for example
+~/workspaces/profiles/acme -> <source>/workspaces/profiles/acme).
+
+Names are encoded the way chezmoi expects (dot_, private_, executable_,
+empty_). Files containing your home directory become .tmpl templates using
+{{ .chezmoi.homeDir }}, so they apply on machines with a different home.
+Gitignored paths (credentials, caches) and the code/ directory are skipped.
+
+Options:
+    --format <format>     Export format (default: chezmoi; the only format)
+    -o, --output <dir>    chezmoi source directory (default: 'chezmoi source-path')
+    --force               Overwrite files already in the source directory
+    -h, --help            Show this help message
+
+Examples:
+    profile export acme
+    profile export acme --output ~/dotfiles
+`
+	fmt.Print(helpText)
+}
 
-    # Push local changes
-    profile sync push my-project
+func (a *App) showImportHelp() {
+	helpText := `Usage: profile import [profile-name] [options]
 
-    # Sync (pull then push)
-    profile sync sync my-project
+Create a profile from its fragment in a chezmoi source directory, the
+reverse of 'profile export'. chezmoi names are decoded, permissions are
+restored from the private_/executable_ attributes, and home directory
+templates are rendered for this machine. Other templates are rendered with
+'chezmoi execute-template' when chezmoi is installed.
 
-    # Set remote URL
-    profile sync remote my-project https://github.com/user/my-project.git
+--from may point at the source directory (the fragment is found from the
+profile name) or at the fragment itself (the name defaults to its
+directory). Scripts, symlinks, and encrypted entries are skipped.
 
-    # Check sync status
-    profile sync status my-project
+Options:
+    --from <dir>          chezmoi source directory or profile fragment
+                          (default: 'chezmoi source-path')
+    --force               Import into an existing profile, overwriting its files
+    -h, --help            Show this help message
 
-Notes:
-    - Profiles are assumed to be in private repositories
-    - Local files created by 'profile create' are not affected
-    - Uncommitted changes are automatically committed before push
-    - Sync will pull then push, handling missing remotes gracefully
+Examples:
+    profile import acme
+    profile import --from ~/dotfiles/workspaces/profiles/acme
 `
 	fmt.Print(helpText)
 }
 
-func (a *App) showCreateHelp() {
-	helpText := `Usage: profile create <profile-name> [options]
+func (a *App) showStowHelp() {
+	helpText := `Usage: profile stow [profile-name] [package...] [options]
+       profile unstow [profile-name] [package...] [options]
 
-Create a new workspace profile with direnv configuration.
+For tools that only read config from a fixed path in $HOME, keep the files
+in GNU stow packages inside the profile and link them in while you work:
 
-Arguments:
-    profile-name        Name of the profile to create (required)
+    stow/<package>/<path relative to $HOME>
+
+'stow' enables the optional stow feature and symlinks every file of the
+packages (default: all) into $HOME, creating missing directories. If any
+target already exists and is not a link to the profile, nothing is linked.
+Created links and directories are recorded in .stowed (gitignored), and
+'unstow' removes exactly those; links changed since are left in place.
+
+The layout also works with GNU stow: stow -d stow -t ~ <package>
 
 Options:
-    -h, --help          Show this help message
-    -f, --force         Overwrite existing profile if it exists
-    -t, --template      Use a specific template: personal, work, or client
-                        (default: basic)
-    --git-name NAME     Set git user.name in .gitconfig
-    --git-email EMAIL   Set git user.email in .gitconfig
-    --interactive       Prompt for all configuration values
-    --dry-run          Show what would be created without creating it
-    --init-git         Initialize git repository after creation
-    --git-remote <url> Initialize git repository with remote URL
+    --dry-run             Show the links without changing anything
+    -h, --help            Show this help message
 
 Examples:
-    # Create a basic profile
-    profile create my-project
+    profile stow acme
+    profile stow acme cursor --dry-run
+    profile unstow acme
+`
+	fmt.Print(helpText)
+}
 
-    # Create a work profile with git configuration
-    profile create acme-corp --template work \\
-        --git-name "John Doe" \\
-        --git-email "john.doe@acme.com"
+func (a *App) showEncryptHelp() {
+	helpText := `Usage: profile encrypt [profile-name] [options]
 
-    # Interactive setup
-    profile create my-project --interactive
+Encrypt sensitive profile files with sops and age so they can be committed.
+Each file is written to <file>.sops and the plaintext is removed once the
+encrypted copy is verified to decrypt.
 
-    # Preview what would be created
-    profile create my-project --dry-run
+The files and age recipients are recorded in profile.yaml (encrypt:). With
+no --file, the existing ones of .aws/credentials, .env, and .npmrc are used.
+With no --age, the public key of your sops age key is used
+($SOPS_AGE_KEY_FILE or the sops default keys.txt).
 
-    # Create with git initialization
-    profile create my-project --init-git
-    profile create my-project --git-remote https://github.com/user/my-project.git
+When the profile loads, .envrc decrypts the files into a private runtime
+directory ($XDG_RUNTIME_DIR or $TMPDIR) and points tools at it:
+    .aws/credentials      AWS_SHARED_CREDENTIALS_FILE
+    .npmrc                NPM_CONFIG_USERCONFIG
+    .kube/config          KUBECONFIG
+    .terraformrc          TF_CLI_CONFIG_FILE
+    .env                  loaded with dotenv
+Edit an encrypted file with: sops <file>.sops
 
-Templates:
-    personal    - Personal projects with minimal configuration
-    work        - Work projects with corporate settings
-    client      - Client projects with isolated credentials
-    basic       - Minimal configuration (default)
+Options:
+    --file <path>         Add a profile-relative file to encrypt (comma-separated or repeated)
+    --age <recipient>     Add an age recipient (age1...; comma-separated or repeated)
+    --keep                Keep the plaintext files after encrypting
+    -h, --help            Show this help message
+
+Examples:
+    profile encrypt acme
+    profile encrypt acme --file .kube/config --age age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p
 `
 	fmt.Print(helpText)
 }
 
-func (a *App) showSelectHelp() {
-	helpText := `Usage: profile select [profile-name] [options]
+func (a *App) showMavenHelp() {
+	helpText := `Usage: profile maven server [profile-name] <server-id> [options]
 
-Select and switch to a workspace profile.
+Add (or replace) a <server> in the profile's .m2/settings.xml, enabling the
+optional jvm feature if needed. The jvm feature exports:
+    MAVEN_OPTS            -Dmaven.repo.local=<profile>/.m2/repository
+    MAVEN_ARGS            --settings <profile>/.m2/settings.xml (Maven 3.9+)
+    GRADLE_USER_HOME      <profile>/.gradle
 
-This command helps you select a profile and provides instructions on how to
-activate it. The profile is activated by changing to its directory, which
-automatically loads the profile's environment via direnv.
+The server password is written as ${env.MAVEN_<ID>_PASSWORD}, so
+settings.xml never contains a secret. With --secret the variable is loaded
+from the profile's secrets provider; otherwise set it in .env.
 
-Arguments:
-    profile-name        Name of the profile to select (optional - interactive selection if omitted)
+Gradle reads credentials from ORG_GRADLE_PROJECT_<property> variables:
+    profile secrets set <profile> ORG_GRADLE_PROJECT_nexusPassword <ref>
 
 Options:
-    -h, --help          Show this help message
-    --allow-direnv      Automatically allow direnv for the selected profile
+    -p, --profile <name>  Profile name (alternative to the positional argument)
+    -u, --username <user> Server username (prompted if omitted)
+    --secret <ref>        Load the password from the profile's secrets provider
+    -h, --help            Show this help message
 
 Examples:
-    # Interactive selection
-    profile select
+    profile maven server acme nexus-releases --username deploy
+    profile maven server acme nexus-releases --username deploy --secret secret/nexus#password
+`
+	fmt.Print(helpText)
+}
 
-    # Select specific profile
-    profile select my-project
+func (a *App) showNetrcHelp() {
+	helpText := `Usage: profile netrc set [profile-name] <machine> [options]
 
-    # Select and allow direnv automatically
-    profile select my-project --allow-direnv
+Add (or replace) a machine entry in the profile's .netrc, enabling the
+optional netrc feature if needed. The feature exports NETRC, which Go, pip,
+Python requests, and git credential-netrc read; curl needs --netrc-file "$NETRC".
 
-After selection, you'll see instructions to activate the profile:
-    cd <profile-path>
-    direnv allow  # (first time only)
+netrc readers cannot resolve references, so the file holds the password
+itself. It is kept at 0600 and gitignored; 'profile doctor' reports wider
+permissions and 'profile update' restores them. With --secret the password
+is read from the profile's secrets provider now; run the command again
+after rotating it. Otherwise it is prompted for without echo.
+
+Options:
+    -p, --profile <name>  Profile name (alternative to the positional argument)
+    -l, --login <user>    Login name (prompted if omitted)
+    --secret <ref>        Read the password from the profile's secrets provider
+    -h, --help            Show this help message
+
+Examples:
+    profile netrc set acme git.acme.internal --login deploy
+    profile netrc set acme artifacts.acme.internal --login ci --secret secret/artifacts#token
 `
 	fmt.Print(helpText)
 }
 
-func (a *App) showListHelp() {
-	helpText := `Usage: profile list [options]
+func (a *App) showAnsibleHelp() {
+	helpText := `Usage: profile ansible vault-password [profile-name] --secret <ref>
 
-List all workspace profiles with their configurations.
+Load the Ansible vault password from the profile's secrets provider,
+enabling the optional ansible feature if needed. The feature exports
+ANSIBLE_CONFIG for a workspace ansible.cfg that keeps Ansible's home, fact
+cache, and SSH control sockets in .ansible/.
 
-Interactive mode is enabled by default. Use flags to disable it.
+The password is exported as ANSIBLE_VAULT_PASSWORD when the profile loads,
+and ANSIBLE_VAULT_PASSWORD_FILE points at .ansible/vault-password, a client
+script that prints it, so no password file is ever written.
 
 Options:
-    -h, --help          Show this help message
-    -v, --verbose       Show detailed information (disables interactive)
-    -c, --config        Show git configuration (disables interactive)
-    --no-interactive    Disable interactive mode
+    -p, --profile <name>  Profile name (alternative to the positional argument)
+    --secret <ref>        Secret reference of the vault password (prompted if omitted)
+    -h, --help            Show this help message
 
 Examples:
-    profile list                # Interactive selection menu (default)
-    profile list --verbose      # Show detailed information for all profiles
-    profile list --config       # Show git configuration for all profiles
-    profile list --no-interactive  # List all profiles without interactive menu
+    profile ansible vault-password acme --secret secret/ansible#vault_password
+    profile ansible vault-password acme --secret ansible-vault   # keychain
 `
 	fmt.Print(helpText)
 }
 
-func (a *App) showDeleteHelp() {
-	helpText := `Usage: profile delete [profile-name] [options]
-
-Delete a workspace profile and all its files.
+func (a *App) showCondaHelp() {
+	helpText := `Usage: profile conda <command> [profile-name] [env] [options]
 
-Interactive selection is enabled by default if profile name is omitted.
+Activate a conda environment whenever the profile loads. The optional conda
+feature, enabled if needed, exports CONDARC for a workspace .condarc that
+keeps environments and the package cache in .conda/.
 
-Arguments:
-    profile-name        Name of the profile to delete (optional - interactive selection if omitted)
+Commands:
+    activate [profile] <env>   Activate <env> in the profile's .envrc
+    deactivate [profile]       Remove the activation
 
 Options:
-    -h, --help          Show this help message
-    -f, --force         Skip confirmation prompt (disables interactive)
-    --dry-run          Show what would be deleted without deleting (disables interactive)
-    --no-interactive    Disable interactive mode
+    -p, --profile <name>  Profile name (alternative to the positional argument)
+    -h, --help            Show this help message
 
 Examples:
-    # Interactive selection (default)
-    profile delete
+    profile conda activate acme analytics
+    conda create -n analytics python=3.12   # from the workspace
+    profile conda deactivate acme
+`
+	fmt.Print(helpText)
+}
 
-    # Delete a profile (with confirmation)
-    profile delete old-project
+func (a *App) showEnvHelp() {
+	helpText := `Usage: profile env [profile-name] [options]
 
-    # Delete without confirmation
-    profile delete old-project --force
+Print the environment the profile's .envrc sets, evaluated by direnv, in a
+shell's syntax. Use it to load a profile into a running shell, or in shells
+without a direnv hook. The .envrc must be allowed.
 
-    # Preview what would be deleted
-    profile delete old-project --dry-run
+Printed to a terminal, the values of secrets (*_TOKEN, *_SECRET, passwords,
+keys) are masked unless --show-secrets is passed; output piped to eval or a
+file is printed as is.
 
-Safety:
-    - You will be prompted for confirmation unless --force is used
-    - The profile directory and all its contents will be deleted
-    - This operation cannot be undone
+Formats:
+    sh      export NAME='value' (bash, zsh)
+    fish    set -gx NAME 'value'
+    nu      load-env { NAME: 'value' } with PATH as a list
+    json    {"NAME": "value"}; null means unset
+
+Options:
+    -p, --profile <name>   Profile name (alternative to the positional argument)
+    -f, --format <format>  Output format (default: sh)
+    --hook nu              Print a config.nu hook that loads direnv environments on every prompt
+    -h, --help             Show this help message
+
+To feed the environment to CI jobs or containers instead, see
+'profile env export --help'.
+
+Examples:
+    eval "$(profile env acme)"
+    profile env acme --format fish | source
+    profile env acme --format nu | save -f acme.nu; source acme.nu
+    profile env --hook nu >> $nu.config-path
 `
 	fmt.Print(helpText)
 }
 
-func (a *App) showDotfilesHelp() {
-	helpText := `Usage: profile dotfiles <command> [profile-name] [options]
+func (a *App) showEnvExportHelp() {
+	helpText := `Usage: profile env export [profile-name] [options]
 
-Manage dotfiles in workspace profiles.
+Print the environment the profile's .envrc sets, evaluated by direnv, for a
+CI job, a container, or any program that reads variables from a file. PATH
+is left out, since it only works on this machine, as are the variables the
+profile unsets. The .envrc must be allowed.
 
-Commands:
-    list, ls              List all dotfiles in a profile
-    edit, e                Edit a dotfile interactively
+Printed to a terminal, the values of secrets are masked unless
+--show-secrets is passed; output piped or redirected is printed as is.
+
+Formats:
+    dotenv           NAME=value lines without quotes, as docker run --env-file
+                     reads them (multi-line values are refused)
+    json             {"NAME": "value"}
+    github-actions   Lines for $GITHUB_ENV; multi-line values as NAME<<EOF
+    docker-args      -e 'NAME=value' arguments for docker run, shell-quoted
 
 Options:
+    -p, --profile <name>  Profile name (alternative to the positional argument)
+    -f, --format <format> Output format (default: dotenv)
     -h, --help            Show this help message
-    -p, --profile <name>  Profile name (interactive selection if omitted)
-    -f, --file <name>      File name (interactive selection if omitted)
-    -e, --editor <name>   Editor to use (default: $EDITOR, $VISUAL, or vim)
 
 Examples:
-    # Interactive profile and file selection
-    profile dotfiles list
-    profile dotfiles edit
+    profile env export acme > acme.env && docker run --env-file acme.env image
+    profile env export acme --format github-actions >> "$GITHUB_ENV"
+    eval "docker run $(profile env export acme --format docker-args) image"
+    profile env export acme --format json > acme-env.json
+`
+	fmt.Print(helpText)
+}
 
-    # List dotfiles in specific profile
-    profile dotfiles list my-project
+func (a *App) handleConflicts(args []string) error {
+	opts := commands.ConflictsOptions{}
 
-    # Edit specific file (interactive profile selection)
-    profile dotfiles edit --file .gitconfig
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--profile", "-p":
+			if i+1 < len(args) {
+				opts.ProfileName = args[i+1]
+				i++
+			}
+		case "-h", "--help":
+			a.showConflictsHelp()
+			return nil
+		default:
+			if !strings.HasPrefix(arg, "-") && opts.ProfileName == "" {
+				opts.ProfileName = arg
+			}
+		}
+	}
 
-    # Edit specific file in specific profile
-    profile dotfiles edit my-project .gitconfig
+	return commands.CheckConflicts(a.profilesDir, opts)
+}
 
-    # Use custom editor
-    profile dotfiles edit my-project .envrc --editor code
+func (a *App) showConflictsHelp() {
+	helpText := `Usage: profile conflicts [profile-name] [options]
 
-Supported Dotfiles:
-    .envrc                    - direnv configuration
-    .gitconfig                - Git configuration
-    .gitignore                - Git ignore patterns
-    .ssh/config               - SSH client configuration
-    .aws/config               - AWS CLI configuration
-    .aws/credentials          - AWS credentials
-    .azure/config             - Azure CLI configuration
-    .azure/clouds.config      - Azure CLI cloud configuration
-    .gcloud/configurations   - Google Cloud SDK configurations
-    .gcloud/credentials      - Google Cloud SDK credentials
-    .config/claude          - Claude Code configuration
-    .config/gemini          - Gemini CLI configuration
-    .kube/config              - Kubernetes configuration
-    .terraformrc              - Terraform CLI configuration
-    .config/1Password/agent.toml - 1Password SSH agent config
-    .env                      - Environment variables (secrets)
-    .env.example              - Environment variables template
-    .envrc.local              - Local direnv overrides
+Compare the variables the profile's .envrc sets with your shell outside of
+it: the current environment (without what direnv loaded) and the variables
+your startup files export (~/.zshrc, ~/.bashrc, ~/.profile, fish's
+config.fish, ...).
 
-Note: Interactive mode is enabled by default if profile or file name is omitted.
+Conflicts:
+    leak       The shell sets an account or tool setting (AWS_PROFILE,
+               GOOGLE_APPLICATION_CREDENTIALS, KUBECONFIG, a token, a
+               variable a feature of the profile sets, ...) that the profile
+               does not set, so the global value applies in the profile too
+    shadowed   The profile replaces or unsets a variable of the shell while
+               it is loaded; a profile extending it (PATH=bin:$PATH) does not
+               shadow it
+
+The source column shows the startup file line exporting the variable. Values
+of secrets are masked unless --show-secrets is passed. Exits 1 when
+variables leak into the profile.
+
+Without direnv (or when the .envrc is not allowed), profile values are taken
+from the .envrc as written.
+
+Options:
+    -p, --profile <name>   Profile name (alternative to the positional argument)
+    --output json|yaml     Print the conflicts as JSON or YAML
+    -h, --help             Show this help message
+
+Examples:
+    profile conflicts acme
+    profile conflicts acme --output json
 `
 	fmt.Print(helpText)
 }
 
-func (a *App) showUpdateHelp() {
-	helpText := `Usage: profile update [profile-name] [options]
+func (a *App) showHookHelp() {
+	helpText := `Usage: profile hook [bash|zsh|fish] [options]
 
-Update an existing profile with new features and configurations.
+Print a snippet for your shell's rc file that keeps SPM_PROFILE set to the
+name of the active profile (taken from WORKSPACE_HOME, which direnv sets
+when you enter a profile), so any prompt or script can show it. The shell
+defaults to the one in $SHELL. Add it after the direnv hook.
 
-This command adds missing directories, environment variables, and configuration
-files to existing profiles. Useful when new features are added to the profile
-manager (e.g., Azure CLI, Google Cloud SDK support).
+With --color the prompt is also prefixed with [profile-name]. Prompts
+managed by starship can use 'profile prompt' instead.
 
-Arguments:
-    profile-name        Name of the profile to update (optional - interactive selection if omitted)
+The snippet also loads the environment of the profile a directory is
+linked to with 'profile link' when you change to it, and restores yours
+when you leave. profile only runs on a directory change, and only when
+there are links or a linked profile is loaded.
+
+With --with-cd the snippet also defines pcd, which changes to a profile
+without typing its path: 'pcd acme' enters the profile, 'pcd acme code' a
+directory in it, and 'pcd' alone the active profile. Profile and directory
+names are completed.
 
 Options:
-    -h, --help          Show this help message
-    -f, --force         Overwrite existing files without prompting
-    --dry-run          Preview changes without applying them
-    --no-backup        Skip creating backup before updating
+    --color <color>  Prefix the prompt with the profile name (red, green, yellow, blue, magenta, cyan)
+    --with-cd        Also define the pcd function
+    --cd-name <name> Name the function <name> instead of pcd (implies --with-cd)
+    -h, --help       Show this help message
 
 Examples:
-    # Interactive selection
-    profile update
+    echo 'eval "$(profile hook bash --color cyan)"' >> ~/.bashrc
+    echo 'eval "$(profile hook zsh --with-cd)"' >> ~/.zshrc
+    echo 'profile hook fish --color magenta | source' >> ~/.config/fish/config.fish
+    profile hook bash --with-cd --cd-name wcd
+`
+	fmt.Print(helpText)
+}
 
-    # Update specific profile
-    profile update my-project
+func (a *App) showLinkHelp() {
+	helpText := `Usage: profile link <dir> <profile-name>
+       profile link [--list]
+       profile unlink <dir>
 
-    # Preview changes without applying
-    profile update my-project --dry-run
+Link a project directory outside the profile roots to a profile, e.g. a
+repository cloned to ~/src. The shell hook ('profile hook') loads the
+profile's environment, as direnv does inside the profile, whenever you
+change to the directory or one below it, and restores your environment
+when you leave. 'profile which' and editors using 'profile serve' report
+the profile there too.
 
-    # Update without creating backup
-    profile update my-project --no-backup
+The directory itself is not changed, so nothing has to be added to the
+repository. Links are kept in ~/.config/spm/links. A directory inside a
+profile cannot be linked; the nearest linked directory wins when links
+are nested.
 
-What gets updated:
-    - Missing directories (.azure, .gcloud, etc.)
-    - Missing environment variables in .envrc
-    - Missing patterns in .gitignore
-    - SSH directory permissions
+Without arguments (or with --list), the links are listed with whether the
+directory and profile still exist.
 
-Backup:
-    By default, a backup is created in .backups/update_<timestamp>/ before making changes.
-    Use --no-backup to skip this.
+Options:
+    -l, --list          List the linked directories
+    --output json|yaml  Print the links as JSON or YAML (with --list)
+    -h, --help          Show this help message
+
+Examples:
+    profile link ~/src/acme-api acme
+    profile link
+    profile unlink ~/src/acme-api
 `
 	fmt.Print(helpText)
 }
 
-func (a *App) showInitHelp() {
-	helpText := `Usage: profile init [options]
-
-Initialize the profile manager configuration.
+func (a *App) showApplyHelp() {
+	helpText := `Usage: profile apply <plan-file>
 
-This command creates a ~/.profile-manager configuration file that stores
-the path to your profiles directory. If not initialized, the tool will use
-the default path: ~/workspaces/profiles
+Apply a plan saved with 'profile create', 'update', or 'delete' and --out.
+The plan is made again against the profile as it is now; if anything
+changed since it was saved the plan is stale and nothing is applied.
+Plans for delete are applied without a confirmation prompt.
 
 Options:
-    -h, --help              Show this help message
-    -f, --force             Overwrite existing configuration
-    -i, --interactive       Interactive setup (prompt for paths)
-    --profiles-dir <path>   Set profiles directory path
+    -h, --help       Show this help message
 
 Examples:
-    # Initialize with default path
-    profile init
+    profile update acme --out acme.plan.json
+    profile apply acme.plan.json
+`
+	fmt.Print(helpText)
+}
 
-    # Interactive initialization
-    profile init --interactive
+func (a *App) showWSLHelp() {
+	helpText := `Usage: profile wsl env [profile-name] [VAR...] [options]
 
-    # Initialize with custom path
-    profile init --profiles-dir ~/my-profiles
+Print the profile's path variables (AWS_CONFIG_FILE,
+AWS_SHARED_CREDENTIALS_FILE, KUBECONFIG, ...) translated with wslpath to
+the paths Windows programs use, so tools running on the Windows side (IDEs,
+the AWS Toolkit, kubectl.exe) share the WSL workspace's files. Profiles in
+the Linux filesystem become \\wsl$\<distro>\... paths; profiles under
+/mnt/c become C:\... paths.
 
-    # Overwrite existing configuration
-    profile init --force
+Keep profiles in the Linux filesystem: Windows drives are slow for direnv
+and git and, unless mounted with metadata, cannot store the permissions ssh
+requires. 'profile doctor' checks both.
 
-Configuration:
-    The configuration is stored in ~/.profile-manager with the following format:
-    
-    profiles_dir=<path>
-    
-    You can edit this file manually if needed. Paths can use ~ for home directory
-    and environment variables will be expanded.
+Formats:
+    powershell  $env:NAME = 'value' (current session)
+    cmd         set "NAME=value" (current session)
+    setx        setx NAME "value" (persisted for new Windows processes)
+
+Options:
+    -p, --profile <name>   Profile name (alternative to the positional argument)
+    -f, --format <format>  Output format (default: powershell)
+    -h, --help             Show this help message
+
+Examples:
+    profile wsl env acme
+    profile wsl env acme AWS_CONFIG_FILE AWS_SHARED_CREDENTIALS_FILE --format setx > /mnt/c/Users/me/acme-env.cmd
 `
 	fmt.Print(helpText)
 }
@@ -4,15 +4,8 @@ import (
 	"github.com/mindmorass/shell-profile-manager/internal/ui"
 )
 
-// Re-export color constants and functions for convenience
-const (
-	ColorReset  = ui.ColorReset
-	ColorRed    = ui.ColorRed
-	ColorGreen  = ui.ColorGreen
-	ColorYellow = ui.ColorYellow
-	ColorBlue   = ui.ColorBlue
-	ColorCyan   = ui.ColorCyan
-)
+// Re-export the message functions for convenience. Colors are used from ui
+// directly, as ui.ConfigureColor changes them at startup.
 
 func PrintError(msg string) {
 	ui.PrintError(msg)
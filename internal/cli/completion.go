@@ -0,0 +1,265 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/commands"
+	"github.com/mindmorass/shell-profile-manager/internal/plugins"
+	"github.com/mindmorass/shell-profile-manager/internal/shell"
+)
+
+// completionCommand describes a command for shell completion
+type completionCommand struct {
+	name        string
+	description string
+	subcommands []string
+	// noProfile marks commands that do not take a profile name
+	noProfile bool
+}
+
+var completionCommands = []completionCommand{
+	{name: "init", description: "Initialize the profile manager configuration", noProfile: true},
+	{name: "create", description: "Create a new workspace profile", noProfile: true},
+	{name: "update", description: "Update a profile with new features"},
+	{name: "select", description: "Select and switch to a profile"},
+	{name: "switch", description: "Switch to a profile (default: the default or last used one)"},
+	{name: "run", description: "Run a command with a profile's environment", noProfile: true},
+	{name: "list", description: "List all workspace profiles", noProfile: true},
+	{name: "delete", description: "Delete a workspace profile"},
+	{name: "restore", description: "Restore a profile from backup"},
+	{name: "info", description: "Show information about the current profile", noProfile: true},
+	{name: "status", description: "Show direnv status", noProfile: true},
+	{name: "which", description: "Print the active profile", noProfile: true},
+	{name: "doctor", description: "Check profiles for problems"},
+	{name: "log", description: "Show the changes applied to a profile"},
+	{name: "verify", description: "Report managed files changed outside spm"},
+	{name: "guard", description: "Block commits that add credentials", subcommands: []string{"install", "uninstall"}},
+	{name: "perms", description: "Check or fix the permissions of keys and credentials", subcommands: []string{"check", "fix"}},
+	{name: "policy", description: "Check profiles against the team policy", subcommands: []string{"check", "update"}},
+	{name: "clean", description: "Empty tool caches in profiles"},
+	{name: "gc", description: "Remove expired backups, stale locks, and leftover temporary files"},
+	{name: "watch", description: "Notify on the desktop when profiles drift or credentials expire"},
+	{name: "daemon", description: "Run watch and maintenance in the background", subcommands: []string{"start", "stop", "status", "install", "run"}, noProfile: true},
+	{name: "serve", description: "Serve profiles over a local HTTP API"},
+	{name: "prune", description: "Find directories without an .envrc and adopt, archive, or delete them"},
+	{name: "dotfiles", description: "Manage profile dotfiles", subcommands: []string{"list", "edit"}},
+	{name: "secrets", description: "Load secret variables from a secret manager", subcommands: []string{"vault", "bws", "keychain", "wincred", "store", "set", "remove", "list", "check"}},
+	{name: "dotenv", description: "Manage the variables in the profile's .env", subcommands: []string{"list", "set", "unset"}},
+	{name: "aws", description: "Write an AWS named profile", subcommands: []string{"setup"}},
+	{name: "azure", description: "Set the Azure tenant and subscription", subcommands: []string{"setup"}},
+	{name: "gcloud", description: "Manage the gcloud configuration", subcommands: []string{"setup", "check"}},
+	{name: "kube", description: "Manage the profile's kubeconfig", subcommands: []string{"import", "list"}},
+	{name: "terraform", description: "Manage the Terraform CLI configuration", subcommands: []string{"setup", "credentials"}},
+	{name: "feature", description: "Manage the features applied to a profile", subcommands: []string{"list", "enable", "disable"}},
+	{name: "root", description: "Manage additional profile directories", subcommands: []string{"list", "add", "remove"}, noProfile: true},
+	{name: "tag", description: "Tag profiles to target them with --tag", subcommands: []string{"list", "add", "remove"}},
+	{name: "preset", description: "List the presets profiles can be created from", subcommands: []string{"list"}, noProfile: true},
+	{name: "template", description: "Manage templates and template repositories", subcommands: []string{"list", "add", "update", "remove"}, noProfile: true},
+	{name: "plugin", description: "List plugins and what they register", subcommands: []string{"list"}, noProfile: true},
+	{name: "npm", description: "Set an npm registry and token", subcommands: []string{"registry"}},
+	{name: "go", description: "Set private Go module patterns", subcommands: []string{"private"}},
+	{name: "maven", description: "Add a Maven server to settings.xml", subcommands: []string{"server"}},
+	{name: "netrc", description: "Add a machine login to the profile's .netrc", subcommands: []string{"set"}},
+	{name: "ansible", description: "Manage the Ansible configuration", subcommands: []string{"vault-password"}},
+	{name: "conda", description: "Manage the activated conda environment", subcommands: []string{"activate", "deactivate"}},
+	{name: "brew", description: "Manage the profile's Brewfile", subcommands: []string{"sync", "dump"}},
+	{name: "nix", description: "Generate a flake.nix devShell", subcommands: []string{"export"}},
+	{name: "gh", description: "Manage the GitHub CLI login", subcommands: []string{"login", "status"}},
+	{name: "tmux", description: "Attach to a tmux session for the profile"},
+	{name: "prompt", description: "Generate a starship prompt config"},
+	{name: "code", description: "Open the profile in VS Code"},
+	{name: "open", description: "Open the profile in an editor, file manager, or terminal"},
+	{name: "export", description: "Export the profile as a chezmoi source fragment"},
+	{name: "import", description: "Create a profile from a chezmoi source fragment"},
+	{name: "encrypt", description: "Encrypt sensitive profile files with sops/age"},
+	{name: "link", description: "Load a profile in a project directory", noProfile: true},
+	{name: "unlink", description: "Remove the link of a project directory", noProfile: true},
+	{name: "stow", description: "Link the profile's stow packages into $HOME"},
+	{name: "unstow", description: "Remove the links created by stow"},
+	{name: "gpg", description: "Create a signing key in the profile's GNUPGHOME", subcommands: []string{"keygen"}},
+	{name: "direnv", description: "Check and allow profiles in direnv", subcommands: []string{"status", "allow"}},
+	{name: "sync", description: "Sync profiles with a git remote", subcommands: []string{"init", "pull", "push", "sync", "status"}},
+	{name: "env", description: "Print the profile's environment for a shell"},
+	{name: "conflicts", description: "Find variables the shell leaks into the profile or it shadows"},
+	{name: "wsl", description: "Translate profile paths for Windows programs", subcommands: []string{"env"}},
+	{name: "apply", description: "Apply a saved plan", noProfile: true},
+	{name: "hook", description: "Print a prompt hook exposing the active profile", noProfile: true},
+	{name: "completion", description: "Print the shell completion script", noProfile: true},
+	{name: "help", description: "Show help", noProfile: true},
+}
+
+func (a *App) handleCompletion(args []string) error {
+	name := shell.Detect()
+	for _, arg := range args {
+		switch arg {
+		case "-h", "--help":
+			a.showCompletionHelp()
+			return nil
+		default:
+			if !strings.HasPrefix(arg, "-") {
+				name = arg
+			}
+		}
+	}
+	if name == "" {
+		a.showCompletionHelp()
+		return commands.NewValidationError("shell is required (%s)", strings.Join(shell.Names(), ", "))
+	}
+	if err := shell.Validate(name); err != nil {
+		return commands.NewValidationError("%v", err)
+	}
+
+	switch name {
+	case shell.Bash:
+		fmt.Print(bashCompletion)
+	case shell.Zsh:
+		fmt.Print(zshCompletion)
+	case shell.Fish:
+		fmt.Print(fishCompletion)
+	}
+	return nil
+}
+
+// handleComplete prints the candidates for the next word after the given
+// words, one per line as "<candidate>\t<description>". It backs the
+// completion scripts and never fails: errors just mean no candidates.
+func (a *App) handleComplete(words []string) error {
+	var positional []string
+	for _, word := range words {
+		if !strings.HasPrefix(word, "-") {
+			positional = append(positional, word)
+		}
+	}
+
+	if len(positional) == 0 {
+		for _, cmd := range completionCommands {
+			fmt.Printf("%s\t%s\n", cmd.name, cmd.description)
+		}
+		for _, plugin := range plugins.Load() {
+			for _, command := range plugin.Commands {
+				description := command.Description
+				if description == "" {
+					description = "Plugin " + plugin.Name
+				}
+				fmt.Printf("%s\t%s\n", command.Name, description)
+			}
+		}
+		return nil
+	}
+
+	// The cd function of 'profile hook --with-cd' completes a profile, then
+	// a directory in it
+	if positional[0] == "__path" {
+		switch len(positional) {
+		case 1:
+			profiles, _ := commands.ListProfileNames(a.profilesDir)
+			for _, name := range profiles {
+				fmt.Printf("%s\tprofile\n", name)
+			}
+			printAliasCompletions(a.profilesDir)
+		case 2:
+			for _, dir := range commands.ProfileSubdirs(a.profilesDir, positional[1]) {
+				fmt.Printf("%s\tdirectory\n", dir)
+			}
+		}
+		return nil
+	}
+
+	var cmd *completionCommand
+	for i := range completionCommands {
+		if completionCommands[i].name == positional[0] {
+			cmd = &completionCommands[i]
+		}
+	}
+	if cmd == nil {
+		return nil
+	}
+
+	args := positional[1:]
+	if len(cmd.subcommands) > 0 {
+		if len(args) == 0 {
+			for _, sub := range cmd.subcommands {
+				fmt.Printf("%s\t%s %s\n", sub, cmd.name, sub)
+			}
+			return nil
+		}
+		args = args[1:]
+	}
+
+	switch {
+	case (cmd.name == "completion" || cmd.name == "hook") && len(args) == 0:
+		for _, name := range shell.Names() {
+			fmt.Printf("%s\tshell\n", name)
+		}
+	case !cmd.noProfile && len(args) == 0:
+		profiles, _ := commands.ListProfileNames(a.profilesDir)
+		for _, name := range profiles {
+			fmt.Printf("%s\tprofile\n", name)
+		}
+		printAliasCompletions(a.profilesDir)
+	}
+	return nil
+}
+
+// printAliasCompletions completes the profile aliases, sorted
+func printAliasCompletions(profilesDir string) {
+	aliases := commands.ProfileAliases(profilesDir)
+	names := make([]string, 0, len(aliases))
+	for alias := range aliases {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+	for _, alias := range names {
+		fmt.Printf("%s\talias of %s\n", alias, aliases[alias])
+	}
+}
+
+func (a *App) showCompletionHelp() {
+	helpText := `Usage: profile completion [bash|zsh|fish]
+
+Print the completion script for a shell (default: the shell in $SHELL).
+Commands, subcommands, and profile names are completed.
+
+Setup:
+    bash    echo 'source <(profile completion bash)' >> ~/.bashrc
+    zsh     echo 'source <(profile completion zsh)' >> ~/.zshrc
+    fish    profile completion fish > ~/.config/fish/completions/profile.fish
+
+Options:
+    -h, --help    Show this help message
+`
+	fmt.Print(helpText)
+}
+
+const bashCompletion = `# bash completion for profile (profile completion bash)
+_profile() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local IFS=$'\n'
+    local candidates=($(profile __complete "${COMP_WORDS[@]:1:COMP_CWORD-1}" 2>/dev/null | cut -f1))
+    COMPREPLY=($(compgen -W "${candidates[*]}" -- "$cur"))
+}
+complete -F _profile profile
+`
+
+const zshCompletion = `#compdef profile
+# zsh completion for profile (profile completion zsh)
+_profile() {
+    local -a candidates
+    local line
+    for line in "${(@f)$(profile __complete "${(@)words[2,CURRENT-1]}" 2>/dev/null)}"; do
+        [[ -n $line ]] && candidates+=("${line%%$'\t'*}:${line#*$'\t'}")
+    done
+    _describe 'profile' candidates
+}
+compdef _profile profile
+`
+
+const fishCompletion = `# fish completion for profile (profile completion fish)
+function __profile_complete
+    set -l words (commandline -opc)
+    profile __complete $words[2..-1] 2>/dev/null
+end
+complete -c profile -f -a '(__profile_complete)'
+`
@@ -0,0 +1,315 @@
+package commands
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mindmorass/shell-profile-manager/internal/atomicfile"
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+	"github.com/mindmorass/shell-profile-manager/internal/notify"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// Kinds of alerts
+const (
+	// AlertDrift is a managed file changed outside spm
+	AlertDrift = "drift"
+	// AlertCredentials are credentials about to expire, or just expired
+	AlertCredentials = "credentials"
+)
+
+// defaultExpiryWarning is how long before credentials expire they are
+// reported (expiry_warning in the config)
+const defaultExpiryWarning = time.Hour
+
+// notifiedFileName records the alerts notifications were sent for, in the
+// state directory, so each is sent once
+const notifiedFileName = "notified.json"
+
+// Alert is something about a profile that needs attention, as shown by
+// 'profile status' and 'profile watch'
+type Alert struct {
+	Profile string `json:"profile" yaml:"profile"`
+	Kind    string `json:"kind" yaml:"kind"`
+	Message string `json:"message" yaml:"message"`
+	// Expires is when the credentials expire (credentials alerts only)
+	Expires *time.Time `json:"expires,omitempty" yaml:"expires,omitempty"`
+}
+
+func (a Alert) key() string {
+	return a.Profile + "\x00" + a.Kind + "\x00" + a.Message
+}
+
+type AlertOptions struct {
+	// Within is how long before credentials expire they are reported
+	// (0: 1h)
+	Within time.Duration
+	// Notify is the desktop notifier alerts are sent to the first time
+	// they are found ("" sends none)
+	Notify string
+}
+
+// credential is a credential cached in a profile and when it expires
+type credential struct {
+	Name    string
+	Expires time.Time
+}
+
+// ProfileAlerts returns the alerts of every profile
+func ProfileAlerts(profilesDir string, opts AlertOptions) ([]Alert, error) {
+	profiles, err := ListProfileNames(profilesDir)
+	if err != nil {
+		return nil, err
+	}
+	within := opts.Within
+	if within <= 0 {
+		within = defaultExpiryWarning
+	}
+	now := time.Now()
+
+	alerts := []Alert{}
+	for _, profileName := range profiles {
+		profileDir := profilePath(profilesDir, profileName)
+		issues, err := checksumIssues(profileDir)
+		if err != nil {
+			slog.Debug("skipping drift check", "profile", profileName, "error", err)
+		}
+		for _, issue := range issues {
+			alerts = append(alerts, Alert{Profile: profileName, Kind: AlertDrift, Message: issue})
+		}
+		for _, cred := range profileCredentials(profileDir) {
+			left := cred.Expires.Sub(now)
+			if left > within || left < -within {
+				continue
+			}
+			verb := "expires"
+			if left <= 0 {
+				verb = "expired"
+			}
+			expires := cred.Expires
+			alerts = append(alerts, Alert{
+				Profile: profileName,
+				Kind:    AlertCredentials,
+				Message: fmt.Sprintf("%s %s at %s", cred.Name, verb, expires.Local().Format("Jan 2 15:04")),
+				Expires: &expires,
+			})
+		}
+	}
+	return alerts, nil
+}
+
+// ShowAlerts prints the alerts of every profile, and sends notifications
+// for those not notified before when a notifier is set
+func ShowAlerts(profilesDir string, opts AlertOptions) error {
+	alerts, err := ProfileAlerts(profilesDir, opts)
+	if err != nil {
+		return err
+	}
+	if len(alerts) == 0 {
+		fmt.Printf("%s✓ No drift or expiring credentials%s\n", ui.ColorGreen, ui.ColorReset)
+	}
+	for _, alert := range alerts {
+		fmt.Printf("%s⚠ %s: %s%s\n", ui.ColorYellow, alert.Profile, alert.Message, ui.ColorReset)
+	}
+	if opts.Notify != "" {
+		NotifyAlerts(alerts, opts.Notify)
+	}
+	return nil
+}
+
+// NotifyAlerts sends a desktop notification for each alert not notified
+// before and returns those. Alerts that went away are forgotten, so they
+// are notified again if they come back. Failing to notify is a warning.
+func NotifyAlerts(alerts []Alert, backend string) []Alert {
+	fresh := unnotified(alerts)
+	for _, alert := range fresh {
+		if err := notify.Send(backend, "spm: "+alert.Profile, alert.Message); err != nil {
+			reporter.Warning(fmt.Sprintf("Failed to send notification: %v", err))
+			break
+		}
+	}
+	return fresh
+}
+
+// unnotified returns the alerts not notified before, and records all of
+// them as notified
+func unnotified(alerts []Alert) []Alert {
+	path, err := notifiedPath()
+	if err != nil {
+		return alerts
+	}
+	notified := map[string]time.Time{}
+	if content, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(content, &notified); err != nil {
+			slog.Debug("ignoring notified alerts", "path", path, "error", err)
+		}
+	}
+
+	current := make(map[string]time.Time, len(alerts))
+	var fresh []Alert
+	for _, alert := range alerts {
+		key := alert.key()
+		if at, ok := notified[key]; ok {
+			current[key] = at
+			continue
+		}
+		current[key] = time.Now().UTC()
+		fresh = append(fresh, alert)
+	}
+
+	content, err := json.Marshal(current)
+	if err == nil && os.MkdirAll(filepath.Dir(path), 0755) == nil {
+		if err := atomicfile.WriteFile(path, content, 0644); err != nil {
+			slog.Debug("not recording notified alerts", "error", err)
+		}
+	}
+	return fresh
+}
+
+func notifiedPath() (string, error) {
+	stateDir, err := config.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, notifiedFileName), nil
+}
+
+// profileCredentials returns the credentials cached in a profile that
+// record when they expire: AWS SSO sessions and assumed-role credentials
+// cached by the AWS CLI, and client certificates in the kubeconfig
+func profileCredentials(profileDir string) []credential {
+	var creds []credential
+	creds = append(creds, awsSSOCredentials(filepath.Join(profileDir, ".aws", "sso", "cache"))...)
+	creds = append(creds, awsRoleCredentials(filepath.Join(profileDir, ".aws", "cli", "cache"))...)
+	creds = append(creds, kubeCertificates(filepath.Join(profileDir, ".kube", "config"))...)
+	sort.Slice(creds, func(i, j int) bool { return creds[i].Expires.Before(creds[j].Expires) })
+	return creds
+}
+
+// awsSSOCredentials returns the SSO sessions in the AWS SSO token cache.
+// The cache also holds client registrations, which have no access token.
+func awsSSOCredentials(dir string) []credential {
+	var creds []credential
+	for _, path := range jsonFiles(dir) {
+		var token struct {
+			StartURL    string `json:"startUrl"`
+			AccessToken string `json:"accessToken"`
+			ExpiresAt   string `json:"expiresAt"`
+		}
+		if !readJSON(path, &token) || token.AccessToken == "" {
+			continue
+		}
+		if expires, ok := parseExpiry(token.ExpiresAt); ok {
+			creds = append(creds, credential{Name: fmt.Sprintf("AWS SSO session (%s)", valueOr(token.StartURL, filepath.Base(path))), Expires: expires})
+		}
+	}
+	return creds
+}
+
+// awsRoleCredentials returns the temporary credentials of assumed roles the
+// AWS CLI cached
+func awsRoleCredentials(dir string) []credential {
+	var creds []credential
+	for _, path := range jsonFiles(dir) {
+		var cached struct {
+			Credentials struct {
+				Expiration string `json:"Expiration"`
+			} `json:"Credentials"`
+			AssumedRoleUser struct {
+				Arn string `json:"Arn"`
+			} `json:"AssumedRoleUser"`
+		}
+		if !readJSON(path, &cached) {
+			continue
+		}
+		if expires, ok := parseExpiry(cached.Credentials.Expiration); ok {
+			creds = append(creds, credential{Name: fmt.Sprintf("AWS role session (%s)", valueOr(cached.AssumedRoleUser.Arn, filepath.Base(path))), Expires: expires})
+		}
+	}
+	return creds
+}
+
+// kubeCertificates returns the client certificates of the users in a
+// kubeconfig, embedded or in files
+func kubeCertificates(path string) []credential {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var kubeconfig struct {
+		Users []struct {
+			Name string `yaml:"name"`
+			User struct {
+				CertificateData string `yaml:"client-certificate-data"`
+				Certificate     string `yaml:"client-certificate"`
+			} `yaml:"user"`
+		} `yaml:"users"`
+	}
+	if err := yaml.Unmarshal(content, &kubeconfig); err != nil {
+		return nil
+	}
+
+	var creds []credential
+	for _, user := range kubeconfig.Users {
+		var certPEM []byte
+		switch {
+		case user.User.CertificateData != "":
+			certPEM, err = base64.StdEncoding.DecodeString(user.User.CertificateData)
+		case user.User.Certificate != "":
+			certFile := config.ExpandPath(user.User.Certificate)
+			if !filepath.IsAbs(certFile) {
+				certFile = filepath.Join(filepath.Dir(path), certFile)
+			}
+			certPEM, err = os.ReadFile(certFile)
+		default:
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		block, _ := pem.Decode(certPEM)
+		if block == nil {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		creds = append(creds, credential{Name: fmt.Sprintf("Kubernetes client certificate of %s", user.Name), Expires: cert.NotAfter})
+	}
+	return creds
+}
+
+// jsonFiles returns the .json files in dir
+func jsonFiles(dir string) []string {
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	return paths
+}
+
+// readJSON decodes a JSON file into v and reports whether it could
+func readJSON(path string, v any) bool {
+	content, err := os.ReadFile(path)
+	return err == nil && json.Unmarshal(content, v) == nil
+}
+
+// parseExpiry parses an expiry time as the AWS CLI writes it: RFC 3339,
+// or with a UTC suffix in older versions
+func parseExpiry(value string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05UTC", "2006-01-02T15:04:05Z0700"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
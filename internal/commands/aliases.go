@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"slices"
+	"strings"
+)
+
+// profileAliases are the short names of profiles set in the [aliases]
+// section of the config file, e.g. acme=client-acme-aws-prod-2024.
+// Profiles can also list their own aliases in their manifest (aliases: in
+// profile.yaml).
+var profileAliases map[string]string
+
+// SetProfileAliases sets the aliases from the config file and returns the
+// previous ones
+func SetProfileAliases(aliases map[string]string) map[string]string {
+	previous := profileAliases
+	profileAliases = aliases
+	return previous
+}
+
+// aliasTargets returns the qualified names of the profiles an alias names,
+// in the config file or in their manifests; more than one makes the alias
+// ambiguous. Aliases are only looked up for names that are not profiles.
+func aliasTargets(profilesDir, alias string) []string {
+	if alias == "" || strings.Contains(alias, "/") {
+		return nil
+	}
+	var targets []string
+	if target, ok := profileAliases[alias]; ok {
+		targets = append(targets, lookupName(profilesDir, target))
+	}
+	for profileName, aliases := range manifestAliases(profilesDir) {
+		if slices.Contains(aliases, alias) && !slices.Contains(targets, profileName) {
+			targets = append(targets, profileName)
+		}
+	}
+	slices.Sort(targets)
+	return targets
+}
+
+// manifestAliases returns the aliases each profile lists in its manifest,
+// by qualified name
+func manifestAliases(profilesDir string) map[string][]string {
+	profiles, err := ListProfileNames(profilesDir)
+	if err != nil {
+		return nil
+	}
+	aliases := map[string][]string{}
+	for _, profileName := range profiles {
+		if summary := cachedProfileSummary(newProfilePath(profilesDir, profileName)); len(summary.Aliases) > 0 {
+			aliases[profileName] = summary.Aliases
+		}
+	}
+	saveProfileCache()
+	return aliases
+}
+
+// aliasesOf returns the aliases of a profile: those its manifest lists and
+// those the config file sets for it
+func aliasesOf(profilesDir, profileName string, manifestAliases []string) []string {
+	aliases := slices.Clone(manifestAliases)
+	for alias, target := range profileAliases {
+		if lookupName(profilesDir, target) == profileName && !slices.Contains(aliases, alias) {
+			aliases = append(aliases, alias)
+		}
+	}
+	slices.Sort(aliases)
+	return aliases
+}
+
+// ProfileAliases returns the profile each alias names, for completion.
+// Aliases shadowed by a profile of the same name and ambiguous ones are
+// left out.
+func ProfileAliases(profilesDir string) map[string]string {
+	aliases := map[string]string{}
+	for alias := range profileAliases {
+		aliases[alias] = ""
+	}
+	for _, names := range manifestAliases(profilesDir) {
+		for _, alias := range names {
+			aliases[alias] = ""
+		}
+	}
+	for alias := range aliases {
+		target := qualifiedName(profilesDir, alias)
+		if target == alias || checkAmbiguous(profilesDir, alias) != nil {
+			delete(aliases, alias)
+			continue
+		}
+		aliases[alias] = target
+	}
+	return aliases
+}
@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// ansibleVaultPasswordVar is printed by the feature's vault password client
+const ansibleVaultPasswordVar = "ANSIBLE_VAULT_PASSWORD"
+
+type AnsibleOptions struct {
+	ProfileName string
+	// SecretRef is the vault password's reference in the profile's secrets
+	// provider
+	SecretRef string
+}
+
+// SetAnsibleVaultPassword loads the Ansible vault password from the
+// profile's secrets provider and points ANSIBLE_VAULT_PASSWORD_FILE at the
+// ansible feature's password client, so no password file is written
+func SetAnsibleVaultPassword(profilesDir string, opts AnsibleOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if opts.SecretRef == "" {
+		if opts.SecretRef, err = ui.Input("Secret reference of the vault password:", ""); err != nil {
+			return err
+		}
+		if opts.SecretRef == "" {
+			return NewValidationError("--secret is required")
+		}
+	}
+	if err := ensureFeature(profilesDir, profileName, profileDir, "ansible"); err != nil {
+		return err
+	}
+
+	err = SetSecret(profilesDir, SecretsOptions{ProfileName: profileName, VarName: ansibleVaultPasswordVar, Ref: opts.SecretRef})
+	var changed *ChangesApplied
+	if err != nil && !errors.As(err, &changed) {
+		return err
+	}
+
+	// Ansible runs the client on every invocation and fails without a
+	// password, so it is only exported once one is configured
+	block := "# Ansible vault password client (profile ansible vault-password)\n" +
+		`export ANSIBLE_VAULT_PASSWORD_FILE="$WORKSPACE_HOME/.ansible/vault-password"`
+	if _, err := setManagedBlock(profileDir, "ansible", block); err != nil {
+		return newIOError(err, "failed to update .envrc")
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Ansible vault password for %s loaded from %s", profileName, opts.SecretRef))
+	fmt.Println("  ANSIBLE_VAULT_PASSWORD_FILE points at .ansible/vault-password, which prints $" + ansibleVaultPasswordVar)
+	return changesApplied()
+}
@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxCommitSubject is the length automatic commit subjects are kept to
+const maxCommitSubject = 72
+
+// gitDirtyPaths returns the paths with uncommitted changes in a profile
+// that is a git repository, and false when it is not one
+func gitDirtyPaths(profileDir string) (map[string]bool, bool) {
+	if _, err := os.Stat(filepath.Join(profileDir, ".git")); err != nil {
+		return nil, false
+	}
+	// Not runGit: the status columns start with spaces
+	cmd := exec.Command("git", "status", "--porcelain", "-z", "--untracked-files=all")
+	cmd.Dir = profileDir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, false
+	}
+
+	dirty := map[string]bool{}
+	entries := strings.Split(string(output), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		dirty[entry[3:]] = true
+		// Renames and copies are followed by the original path
+		if entry[0] == 'R' || entry[0] == 'C' {
+			i++
+		}
+	}
+	return dirty, true
+}
+
+// commitManagedChanges commits the files a command changed in a profile
+// that is a git repository. Files that already had uncommitted changes
+// before (before, from gitDirtyPaths) and backups are left for the user.
+// It returns the commit subject, or "" when there was nothing to commit.
+func commitManagedChanges(profileDir string, before map[string]bool, subject string, changes []string) (string, error) {
+	after, ok := gitDirtyPaths(profileDir)
+	if !ok {
+		return "", nil
+	}
+	var paths []string
+	for path := range after {
+		if !before[path] && !strings.HasPrefix(path, ".backups/") {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return "", nil
+	}
+	sort.Strings(paths)
+
+	var body strings.Builder
+	for _, change := range changes {
+		body.WriteString("- " + change + "\n")
+	}
+	if _, err := runGit(profileDir, append([]string{"add", "--"}, paths...)...); err != nil {
+		return "", err
+	}
+	args := []string{"commit", "--quiet", "-m", subject}
+	if body.Len() > 0 {
+		args = append(args, "-m", strings.TrimSuffix(body.String(), "\n"))
+	}
+	if _, err := runGit(profileDir, append(append(args, "--"), paths...)...); err != nil {
+		return "", err
+	}
+	return subject, nil
+}
+
+// commitSubject summarizes changes described as "<what>: <item>, <item>"
+// in the subject of an automatic commit, e.g.
+// "spm update: .azure, AZURE_CONFIG_DIR, CLOUDSDK_CONFIG"
+func commitSubject(command string, changes []string) string {
+	var items []string
+	for _, change := range changes {
+		if _, list, ok := strings.Cut(change, ": "); ok {
+			items = append(items, strings.Split(list, ", ")...)
+		} else {
+			items = append(items, strings.ToLower(change[:1])+change[1:])
+		}
+	}
+
+	subject := "spm " + command + ": "
+	for i, item := range items {
+		more := ""
+		if i < len(items)-1 {
+			more = fmt.Sprintf(" and %d more", len(items)-i-1)
+		}
+		if i > 0 && len(subject)+len(item)+len(more)+2 > maxCommitSubject {
+			return subject + fmt.Sprintf(" and %d more", len(items)-i)
+		}
+		if i > 0 {
+			subject += ", "
+		}
+		subject += item
+	}
+	return subject
+}
@@ -0,0 +1,167 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type AWSOptions struct {
+	ProfileName string
+	// AWSProfile is the named profile written to .aws/config and exported
+	// as AWS_PROFILE (defaults to the workspace profile name)
+	AWSProfile   string
+	Region       string
+	Output       string
+	SSOStartURL  string
+	SSORegion    string
+	SSOSession   string
+	SSOAccountID string
+	SSORoleName  string
+	Login        bool
+	NoLogin      bool
+}
+
+// SetupAWS writes an AWS named profile (optionally backed by IAM Identity
+// Center / SSO) into the profile's .aws/config and exports AWS_PROFILE
+func SetupAWS(profilesDir string, opts AWSOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile to set up AWS for:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if opts.AWSProfile == "" {
+		opts.AWSProfile = baseProfileName(profileName)
+	}
+	if opts.Region, err = ui.Input("Default region:", valueOr(opts.Region, "us-east-1")); err != nil {
+		return err
+	}
+	if opts.SSOStartURL == "" {
+		if opts.SSOStartURL, err = ui.Input("SSO start URL (leave empty for a plain named profile):", ""); err != nil {
+			return err
+		}
+	}
+
+	useSSO := opts.SSOStartURL != ""
+	if useSSO {
+		if opts.SSOSession == "" {
+			opts.SSOSession = opts.AWSProfile
+		}
+		if opts.SSORegion, err = ui.Input("SSO region:", valueOr(opts.SSORegion, opts.Region)); err != nil {
+			return err
+		}
+		if opts.SSOAccountID == "" {
+			if opts.SSOAccountID, err = ui.Input("AWS account ID:", ""); err != nil {
+				return err
+			}
+		}
+		if opts.SSORoleName == "" {
+			if opts.SSORoleName, err = ui.Input("SSO role name:", ""); err != nil {
+				return err
+			}
+		}
+		if opts.SSOAccountID == "" || opts.SSORoleName == "" {
+			return NewValidationError("SSO profiles need --account-id and --role-name")
+		}
+	}
+
+	awsDir := filepath.Join(profileDir, ".aws")
+	if err := os.MkdirAll(awsDir, 0755); err != nil {
+		return newIOError(err, "failed to create .aws directory")
+	}
+
+	configPath := filepath.Join(awsDir, "config")
+	content, err := os.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return newIOError(err, "failed to read .aws/config")
+	}
+
+	profileLines := []string{fmt.Sprintf("region = %s", opts.Region)}
+	if opts.Output != "" {
+		profileLines = append(profileLines, fmt.Sprintf("output = %s", opts.Output))
+	}
+	config := string(content)
+	if useSSO {
+		profileLines = append(profileLines,
+			fmt.Sprintf("sso_session = %s", opts.SSOSession),
+			fmt.Sprintf("sso_account_id = %s", opts.SSOAccountID),
+			fmt.Sprintf("sso_role_name = %s", opts.SSORoleName),
+		)
+		config = mergeINISection(config, fmt.Sprintf("[sso-session %s]", opts.SSOSession), []string{
+			fmt.Sprintf("sso_start_url = %s", opts.SSOStartURL),
+			fmt.Sprintf("sso_region = %s", opts.SSORegion),
+			"sso_registration_scopes = sso:account:access",
+		})
+	}
+	config = mergeINISection(config, fmt.Sprintf("[profile %s]", opts.AWSProfile), profileLines)
+
+	if err := writeProfileFile(configPath, []byte(config), 0600); err != nil {
+		return newIOError(err, "failed to write .aws/config")
+	}
+	ui.PrintSuccess(fmt.Sprintf("Wrote [profile %s] to %s", opts.AWSProfile, configPath))
+
+	if _, err := setManagedBlock(profileDir, "aws", "# AWS named profile used in this workspace\n"+managedExport("AWS_PROFILE", opts.AWSProfile)); err != nil {
+		return newIOError(err, "failed to update .envrc")
+	}
+	ui.PrintSuccess(fmt.Sprintf("AWS_PROFILE=%s exported in .envrc", opts.AWSProfile))
+
+	if useSSO && !opts.NoLogin {
+		runAWSSSOLogin(awsDir, opts)
+	} else if !useSSO {
+		fmt.Println()
+		fmt.Printf("  Add credentials with: cd %s && aws configure --profile %s\n", profileDir, opts.AWSProfile)
+	}
+
+	return changesApplied()
+}
+
+// runAWSSSOLogin offers to start 'aws sso login' against the profile config
+func runAWSSSOLogin(awsDir string, opts AWSOptions) {
+	if _, err := exec.LookPath("aws"); err != nil {
+		ui.PrintWarning("aws CLI is not installed; run 'aws sso login' after installing it")
+		return
+	}
+
+	if !opts.Login && !offerToRun("Run 'aws sso login' now?") {
+		fmt.Printf("  Run 'aws sso login --profile %s' from the workspace to sign in\n", opts.AWSProfile)
+		return
+	}
+
+	cmd := exec.Command("aws", "sso", "login", "--profile", opts.AWSProfile)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"AWS_CONFIG_FILE="+filepath.Join(awsDir, "config"),
+		"AWS_SHARED_CREDENTIALS_FILE="+filepath.Join(awsDir, "credentials"),
+	)
+	if err := cmd.Run(); err != nil {
+		ui.PrintWarning(fmt.Sprintf("aws sso login failed: %v", err))
+	}
+}
+
+// offerToRun asks whether to run an optional follow-up command. Without a
+// terminal (and without --yes) the answer is no rather than an error.
+func offerToRun(question string) bool {
+	if !ui.IsInteractive() && !ui.AssumeYes() {
+		return false
+	}
+	confirmed, err := ui.Confirm(question, true)
+	return err == nil && confirmed
+}
+
+// valueOr returns value, or fallback when value is empty
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
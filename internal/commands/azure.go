@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type AzureOptions struct {
+	ProfileName  string
+	Tenant       string
+	Subscription string
+	Location     string
+	Group        string
+	Login        bool
+	NoLogin      bool
+}
+
+// SetupAzure writes Azure CLI defaults into the profile's .azure directory,
+// exports the tenant and subscription, and offers to sign in
+func SetupAzure(profilesDir string, opts AzureOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile to set up Azure for:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if opts.Tenant == "" {
+		if opts.Tenant, err = ui.Input("Tenant ID or domain:", ""); err != nil {
+			return err
+		}
+	}
+	if opts.Subscription == "" {
+		if opts.Subscription, err = ui.Input("Subscription ID or name:", ""); err != nil {
+			return err
+		}
+	}
+	if opts.Location == "" {
+		if opts.Location, err = ui.Input("Default location (optional):", ""); err != nil {
+			return err
+		}
+	}
+	if opts.Tenant == "" && opts.Subscription == "" {
+		return NewValidationError("--tenant or --subscription is required")
+	}
+
+	azureDir := filepath.Join(profileDir, ".azure")
+	if err := os.MkdirAll(azureDir, 0700); err != nil {
+		return newIOError(err, "failed to create .azure directory")
+	}
+
+	// CLI defaults live in .azure/config
+	var defaults []string
+	if opts.Location != "" {
+		defaults = append(defaults, fmt.Sprintf("location = %s", opts.Location))
+	}
+	if opts.Group != "" {
+		defaults = append(defaults, fmt.Sprintf("group = %s", opts.Group))
+	}
+	if len(defaults) > 0 {
+		configPath := filepath.Join(azureDir, "config")
+		content, err := os.ReadFile(configPath)
+		if err != nil && !os.IsNotExist(err) {
+			return newIOError(err, "failed to read .azure/config")
+		}
+		config := mergeINISection(string(content), "[defaults]", defaults)
+		if err := writeProfileFile(configPath, []byte(config), 0600); err != nil {
+			return newIOError(err, "failed to write .azure/config")
+		}
+		ui.PrintSuccess(fmt.Sprintf("Wrote defaults to %s", configPath))
+	}
+
+	var block []string
+	block = append(block, "# Azure tenant and subscription used in this workspace")
+	if opts.Tenant != "" {
+		block = append(block, managedExport("AZURE_TENANT_ID", opts.Tenant))
+	}
+	if opts.Subscription != "" {
+		block = append(block, managedExport("AZURE_SUBSCRIPTION_ID", opts.Subscription))
+	}
+	if _, err := setManagedBlock(profileDir, "azure", strings.Join(block, "\n")); err != nil {
+		return newIOError(err, "failed to update .envrc")
+	}
+	ui.PrintSuccess("Azure tenant and subscription exported in .envrc")
+
+	if !opts.NoLogin {
+		runAzureLogin(azureDir, opts)
+	}
+
+	fmt.Println()
+	fmt.Printf("  Azure CLI state for %s is stored in %s\n", profileName, azureDir)
+	return changesApplied()
+}
+
+// runAzureLogin signs in to the tenant (if needed) and selects the
+// subscription using the profile's AZURE_CONFIG_DIR
+func runAzureLogin(azureDir string, opts AzureOptions) {
+	if _, err := exec.LookPath("az"); err != nil {
+		ui.PrintWarning("Azure CLI (az) is not installed; sign in with 'az login' after installing it")
+		return
+	}
+
+	env := append(os.Environ(), "AZURE_CONFIG_DIR="+azureDir)
+	run := func(args ...string) error {
+		cmd := exec.Command("az", args...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Env = env
+		return cmd.Run()
+	}
+
+	// Sign in unless the profile already has an account
+	check := exec.Command("az", "account", "show", "--output", "none")
+	check.Env = env
+	if check.Run() != nil {
+		if !opts.Login && !offerToRun("Run 'az login' now?") {
+			fmt.Println("  Run 'az login' from the workspace to sign in")
+			return
+		}
+		args := []string{"login"}
+		if opts.Tenant != "" {
+			args = append(args, "--tenant", opts.Tenant)
+		}
+		if err := run(args...); err != nil {
+			ui.PrintWarning(fmt.Sprintf("az login failed: %v", err))
+			return
+		}
+	}
+
+	if opts.Subscription != "" {
+		if err := run("account", "set", "--subscription", opts.Subscription); err != nil {
+			ui.PrintWarning(fmt.Sprintf("Failed to select subscription: %v", err))
+			return
+		}
+		ui.PrintSuccess(fmt.Sprintf("Default subscription set: %s", opts.Subscription))
+	}
+}
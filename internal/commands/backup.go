@@ -0,0 +1,435 @@
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mindmorass/shell-profile-manager/internal/atomicfile"
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// backupsDir is the directory in a profile holding the backups update makes
+const backupsDir = ".backups"
+
+// backupPrefix starts the name of every backup set, followed by its date
+const backupPrefix = "update_"
+
+// encryptedBackupSuffix ends the name of a backup set encrypted with age: a
+// tar archive of the files
+const encryptedBackupSuffix = ".tar.age"
+
+// backupDateFormat is the date in the name of a backup set
+const backupDateFormat = "2006-01-02_15-04-05"
+
+// backupFiles are the files update rewrites, which it backs up first
+var backupFiles = []string{".envrc", ".gitconfig", ".gitignore"}
+
+// backupSet is one backup of a profile, a directory or an encrypted archive
+type backupSet struct {
+	Date      string
+	Path      string
+	Encrypted bool
+}
+
+func (s backupSet) String() string {
+	if s.Encrypted {
+		return s.Date + " (encrypted)"
+	}
+	return s.Date
+}
+
+// backupFile is a file in a backup set, by path relative to the profile
+type backupFile struct {
+	Path    string
+	Content []byte
+	Mode    os.FileMode
+}
+
+// CreateBackup copies the files update rewrites to .backups in the profile
+// and returns the backup made: a directory update_<timestamp>, or the
+// archive update_<timestamp>.tar.age when backups are encrypted for age
+// recipients (backup.age in the manifest, or backup_age in the config)
+func CreateBackup(profileDir string) (string, error) {
+	backupDir := filepath.Join(profileDir, backupsDir)
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	// Backups made within the same second (a restore backs up the files it
+	// overwrites) get a counter so that none replaces another
+	timestamp := time.Now().Format(backupDateFormat)
+	backupPath := filepath.Join(backupDir, backupPrefix+timestamp)
+	for n := 2; backupExists(backupPath); n++ {
+		backupPath = filepath.Join(backupDir, fmt.Sprintf("%s%s-%d", backupPrefix, timestamp, n))
+	}
+
+	recipients, err := backupRecipients(profileDir)
+	if err != nil {
+		return "", err
+	}
+	if len(recipients) > 0 {
+		return writeEncryptedBackup(profileDir, backupPath+encryptedBackupSuffix, recipients)
+	}
+
+	// Copy important files
+	for _, file := range backupFiles {
+		src := filepath.Join(profileDir, file)
+		if _, err := os.Stat(src); err == nil {
+			content, err := os.ReadFile(src)
+			if err != nil {
+				continue
+			}
+
+			backupFile := filepath.Join(backupPath, file)
+			if err := os.MkdirAll(filepath.Dir(backupFile), 0755); err != nil {
+				continue
+			}
+
+			if err := atomicfile.WriteFile(backupFile, content, 0644); err != nil {
+				continue
+			}
+		}
+	}
+
+	return backupPath, nil
+}
+
+// backupExists reports whether a backup set, plain or encrypted, is at path
+func backupExists(path string) bool {
+	for _, p := range []string{path, path + encryptedBackupSuffix} {
+		if _, err := os.Lstat(p); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// backupRecipients returns the age recipients backups of a profile are
+// encrypted for: those in its manifest, otherwise those in the config. None
+// means backups are plain copies.
+func backupRecipients(profileDir string) ([]string, error) {
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return nil, err
+	}
+	if m.Backup != nil && len(m.Backup.Age) > 0 {
+		return m.Backup.Age, nil
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return cfg.BackupAge, nil
+}
+
+// writeEncryptedBackup writes the files update rewrites to a tar archive
+// encrypted for the age recipients, which are public keys (age1..., ssh-...)
+// or files listing them
+func writeEncryptedBackup(profileDir, archivePath string, recipients []string) (string, error) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	for _, file := range backupFiles {
+		src := filepath.Join(profileDir, file)
+		info, err := os.Stat(src)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		content, err := os.ReadFile(src)
+		if err != nil {
+			return "", err
+		}
+		header := &tar.Header{
+			Name:    filepath.ToSlash(file),
+			Mode:    int64(info.Mode().Perm()),
+			Size:    int64(len(content)),
+			ModTime: info.ModTime(),
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return "", err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return "", err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+
+	args := []string{"--encrypt"}
+	for _, recipient := range recipients {
+		if strings.HasPrefix(recipient, "age1") || strings.HasPrefix(recipient, "ssh-") {
+			args = append(args, "--recipient", recipient)
+		} else {
+			args = append(args, "--recipients-file", config.ExpandPath(recipient))
+		}
+	}
+	ciphertext, err := runAge(archive.Bytes(), args...)
+	if err != nil {
+		return "", err
+	}
+	if err := atomicfile.WriteFile(archivePath, ciphertext, 0600); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+	return archivePath, nil
+}
+
+// runAge runs age with input on stdin and returns its output
+func runAge(input []byte, args ...string) ([]byte, error) {
+	if _, err := exec.LookPath("age"); err != nil {
+		return nil, NewValidationError("age is not installed (https://age-encryption.org), which encrypted backups need")
+	}
+	cmd := exec.Command("age", args...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("age %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// listBackups returns the backup sets of a profile, newest first
+func listBackups(profileDir string) ([]backupSet, error) {
+	entries, err := os.ReadDir(filepath.Join(profileDir, backupsDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sets []backupSet
+	for _, entry := range entries {
+		name, ok := strings.CutPrefix(entry.Name(), backupPrefix)
+		if !ok {
+			continue
+		}
+		set := backupSet{Date: name, Path: filepath.Join(profileDir, backupsDir, entry.Name())}
+		if date, ok := strings.CutSuffix(name, encryptedBackupSuffix); ok && !entry.IsDir() {
+			set.Date, set.Encrypted = date, true
+		} else if !entry.IsDir() {
+			continue
+		}
+		sets = append(sets, set)
+	}
+	sort.Slice(sets, func(i, j int) bool { return sets[i].Date > sets[j].Date })
+	return sets, nil
+}
+
+// readBackup returns the files of a backup set, decrypting an encrypted one
+// with the backup_identity of the config (default: the sops age key file)
+func readBackup(set backupSet) ([]backupFile, error) {
+	if !set.Encrypted {
+		var files []backupFile
+		err := filepath.WalkDir(set.Path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(set.Path, path)
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			files = append(files, backupFile{Path: filepath.ToSlash(rel), Content: content, Mode: info.Mode().Perm()})
+			return nil
+		})
+		return files, err
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	identity := cfg.BackupIdentity
+	if identity == "" {
+		_, identity = defaultAgeRecipient()
+	}
+	if _, err := os.Stat(identity); err != nil {
+		return nil, NewValidationError("no age identity to decrypt the backup: %s does not exist (set backup_identity in the config)", identity)
+	}
+	ciphertext, err := os.ReadFile(set.Path)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := runAge(ciphertext, "--decrypt", "--identity", identity)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []backupFile
+	tr := tar.NewReader(bytes.NewReader(archive))
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read backup %s: %w", set.Date, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read backup %s: %w", set.Date, err)
+		}
+		files = append(files, backupFile{Path: header.Name, Content: content, Mode: os.FileMode(header.Mode).Perm()})
+	}
+	return files, nil
+}
+
+type RestoreOptions struct {
+	ProfileName string
+	// BackupDate selects the backup set (default: the newest, or the one
+	// picked interactively)
+	BackupDate string
+	// File restores a single file of the backup set
+	File   string
+	Force  bool
+	DryRun bool
+}
+
+// RestoreBackup restores the files of a profile from a backup update made,
+// decrypting it when it is encrypted. The current files are backed up
+// first, so a restore can be undone the same way.
+func RestoreBackup(profilesDir string, opts RestoreOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile to restore:")
+	if err != nil {
+		return err
+	}
+	sets, err := listBackups(profileDir)
+	if err != nil {
+		return newIOError(err, "failed to list backups")
+	}
+	if len(sets) == 0 {
+		return NewValidationError("profile '%s' has no backups", profileName)
+	}
+
+	set := sets[0]
+	switch {
+	case opts.BackupDate != "":
+		found := false
+		for _, s := range sets {
+			if s.Date == opts.BackupDate {
+				set, found = s, true
+				break
+			}
+		}
+		if !found {
+			return NewValidationError("no backup from %s in profile '%s' (available: %s)", opts.BackupDate, profileName, backupDates(sets))
+		}
+	case !opts.Force && !opts.DryRun:
+		options := make([]string, len(sets))
+		for i, s := range sets {
+			options[i] = s.String()
+		}
+		selected, err := ui.Select("Select backup to restore:", options, options[0])
+		if errors.Is(err, ui.ErrInputRequired) {
+			return NewValidationError("select a backup with --backup-date (available: %s)", backupDates(sets))
+		}
+		if err != nil {
+			return err
+		}
+		for i, option := range options {
+			if option == selected {
+				set = sets[i]
+			}
+		}
+	}
+
+	files, err := readBackup(set)
+	if err != nil {
+		return err
+	}
+	var restore []backupFile
+	for _, file := range files {
+		if !filepath.IsLocal(filepath.FromSlash(file.Path)) {
+			return NewValidationError("backup %s has a file outside the profile: %s", set.Date, file.Path)
+		}
+		if opts.File == "" || file.Path == filepath.ToSlash(filepath.Clean(opts.File)) {
+			restore = append(restore, file)
+		}
+	}
+	if len(restore) == 0 {
+		if opts.File != "" {
+			return NewValidationError("backup %s does not have %s", set.Date, opts.File)
+		}
+		return NewValidationError("backup %s is empty", set.Date)
+	}
+	paths := make([]string, len(restore))
+	for i, file := range restore {
+		paths[i] = file.Path
+	}
+
+	if opts.DryRun {
+		ui.PrintInfo(fmt.Sprintf("Would restore from backup %s: %s", set, strings.Join(paths, ", ")))
+		return nil
+	}
+	if !opts.Force {
+		confirmed, err := ui.Confirm(fmt.Sprintf("Restore %s of '%s' from backup %s?", strings.Join(paths, ", "), profileName, set), false)
+		if err != nil {
+			return fmt.Errorf("failed to get confirmation: %w", err)
+		}
+		if !confirmed {
+			ui.PrintInfo("Restore cancelled")
+			return nil
+		}
+	}
+
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if backupPath, err := CreateBackup(profileDir); err == nil {
+		ui.PrintInfo(fmt.Sprintf("Backup created: %s", backupPath))
+	} else {
+		return fmt.Errorf("failed to back up the current files: %w", err)
+	}
+	for _, file := range restore {
+		target := filepath.Join(profileDir, filepath.FromSlash(file.Path))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return newIOError(err, "failed to restore %s", file.Path)
+		}
+		if err := writeProfileFile(target, file.Content, file.Mode); err != nil {
+			return newIOError(err, "failed to restore %s", file.Path)
+		}
+	}
+	recordChecksums(profileDir, paths...)
+	recordHistory(profileDir, "restore", []string{fmt.Sprintf("Restored from backup %s: %s", set.Date, strings.Join(paths, ", "))})
+
+	ui.PrintSuccess(fmt.Sprintf("Restored %s of profile %s from backup %s", strings.Join(paths, ", "), profileName, set))
+	return changesApplied()
+}
+
+// backupDates lists the dates of backup sets for messages
+func backupDates(sets []backupSet) string {
+	dates := make([]string, len(sets))
+	for i, set := range sets {
+		dates[i] = set.Date
+	}
+	return strings.Join(dates, ", ")
+}
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type BrewOptions struct {
+	ProfileName string
+	// NoUpgrade installs missing entries without upgrading outdated ones
+	NoUpgrade bool
+	// Force overwrites an existing Brewfile when dumping
+	Force bool
+}
+
+// brewfilePath returns the location of the profile's Brewfile
+func brewfilePath(profileDir string) string {
+	return filepath.Join(profileDir, "Brewfile")
+}
+
+// SyncBrew installs everything listed in the profile's Brewfile
+func SyncBrew(profilesDir string, opts BrewOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile to sync:")
+	if err != nil {
+		return err
+	}
+
+	brewfile := brewfilePath(profileDir)
+	if _, err := os.Stat(brewfile); os.IsNotExist(err) {
+		return NewValidationError("profile '%s' has no Brewfile (create one or run 'profile brew dump %s')", profileName, profileName)
+	}
+	if _, err := exec.LookPath("brew"); err != nil {
+		return NewValidationError("Homebrew is not installed (https://brew.sh)")
+	}
+
+	ui.PrintInfo(fmt.Sprintf("Installing Brewfile for profile: %s", profileName))
+
+	args := []string{"bundle", "install", "--file", brewfile}
+	if opts.NoUpgrade {
+		args = append(args, "--no-upgrade")
+	}
+	cmd := exec.Command("brew", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("brew bundle failed: %w", err)
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Brewfile installed for profile: %s", profileName))
+	return nil
+}
+
+// DumpBrew writes the currently installed formulae, casks, and taps to the
+// profile's Brewfile
+func DumpBrew(profilesDir string, opts BrewOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile to dump into:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	brewfile := brewfilePath(profileDir)
+	if _, err := os.Stat(brewfile); err == nil && !opts.Force {
+		confirmed, err := ui.Confirm(fmt.Sprintf("Overwrite %s?", brewfile), false)
+		if err != nil {
+			return NewValidationError("%s already exists (use --force to overwrite)", brewfile)
+		}
+		if !confirmed {
+			ui.PrintInfo("Dump cancelled")
+			return nil
+		}
+	}
+	if _, err := exec.LookPath("brew"); err != nil {
+		return NewValidationError("Homebrew is not installed (https://brew.sh)")
+	}
+
+	cmd := exec.Command("brew", "bundle", "dump", "--force", "--file", brewfile)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("brew bundle dump failed: %w", err)
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Wrote %s", brewfile))
+	fmt.Printf("  Trim it to the tools this profile needs, then install with 'profile brew sync %s'\n", profileName)
+	return changesApplied()
+}
@@ -0,0 +1,252 @@
+package commands
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mindmorass/shell-profile-manager/internal/atomicfile"
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+)
+
+// profileCacheFileName is the cache of the profiles found in each profiles
+// directory and of their summaries, in the state directory. It spares list,
+// select, and status from scanning every directory and reading every
+// profile, which is slow on network and encrypted volumes.
+const profileCacheFileName = "profiles-cache.json"
+
+// profileCacheVersion is bumped when what is cached changes, so older
+// caches are dropped
+const profileCacheVersion = 4
+
+// racyWindow is how recently a file can have changed and still be cached.
+// A change within the timestamp granularity of the file system would leave
+// its modification time as it was, so such entries are read again.
+const racyWindow = 2 * time.Second
+
+// summaryFiles are what a profile summary is read from
+var summaryFiles = []string{manifest.FileName, ".gitconfig", "README.md"}
+
+var (
+	profileCacheEnabled = true
+	profileCacheMu      sync.Mutex
+	profileCache        *profileCacheState
+)
+
+// UseProfileCache turns reading the profile cache on or off (--no-cache);
+// when off, profiles are scanned and read again and the cache is rewritten
+// with what was found
+func UseProfileCache(enabled bool) {
+	profileCacheEnabled = enabled
+}
+
+// fileStamp identifies the version of a file by its modification time and
+// size (zero when the file does not exist)
+type fileStamp struct {
+	ModTime int64 `json:"mtime"`
+	Size    int64 `json:"size"`
+}
+
+type cachedDir struct {
+	Stamp    fileStamp `json:"stamp"`
+	Profiles []string  `json:"profiles"`
+	// Envrcs are the stamps of the .envrc of each profile, which can be
+	// removed without the directory itself changing
+	Envrcs map[string]fileStamp `json:"envrcs"`
+}
+
+type cachedSummary struct {
+	Stamps  map[string]fileStamp `json:"stamps"`
+	Summary ProfileSummary       `json:"summary"`
+}
+
+type profileCacheState struct {
+	Version   int                      `json:"version"`
+	Dirs      map[string]cachedDir     `json:"dirs"`
+	Summaries map[string]cachedSummary `json:"summaries"`
+
+	dirty bool
+}
+
+func profileCachePath() (string, error) {
+	stateDir, err := config.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, profileCacheFileName), nil
+}
+
+// loadProfileCache returns the cache, reading it on first use; a missing
+// or unreadable cache is empty. profileCacheMu is held.
+func loadProfileCache() *profileCacheState {
+	if profileCache != nil {
+		return profileCache
+	}
+	profileCache = &profileCacheState{Version: profileCacheVersion, Dirs: map[string]cachedDir{}, Summaries: map[string]cachedSummary{}}
+	path, err := profileCachePath()
+	if err != nil {
+		return profileCache
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return profileCache
+	}
+	var cached profileCacheState
+	if err := json.Unmarshal(content, &cached); err != nil || cached.Version != profileCacheVersion {
+		slog.Debug("ignoring profile cache", "path", path, "error", err)
+		return profileCache
+	}
+	if cached.Dirs != nil {
+		profileCache.Dirs = cached.Dirs
+	}
+	if cached.Summaries != nil {
+		profileCache.Summaries = cached.Summaries
+	}
+	return profileCache
+}
+
+// saveProfileCache writes the cache when it changed; failing to is not an
+// error, the profiles are just read again next time
+func saveProfileCache() {
+	profileCacheMu.Lock()
+	defer profileCacheMu.Unlock()
+	if profileCache == nil || !profileCache.dirty {
+		return
+	}
+	path, err := profileCachePath()
+	if err != nil {
+		return
+	}
+	content, err := json.Marshal(profileCache)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		slog.Debug("not caching profiles", "error", err)
+		return
+	}
+	if err := atomicfile.WriteFile(path, content, 0644); err != nil {
+		slog.Debug("not caching profiles", "error", err)
+		return
+	}
+	profileCache.dirty = false
+}
+
+// stampOf returns the stamp of a file, and whether it changed long enough
+// ago to be cached
+func stampOf(path string) (fileStamp, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, true
+	}
+	return fileStamp{ModTime: info.ModTime().UnixNano(), Size: info.Size()}, time.Since(info.ModTime()) > racyWindow
+}
+
+// cachedProfileDirNames returns the profiles in dir from the cache while
+// the directory and the .envrc of its profiles are unchanged, scanning it
+// otherwise. Adding, removing, or renaming a profile changes the directory.
+// Adding an .envrc to another subdirectory does not; spm forgets the
+// directory when it does so, and --no-cache picks up one added by hand.
+func cachedProfileDirNames(dir string, scan func(string) ([]string, error)) ([]string, error) {
+	stamp, settled := stampOf(dir)
+
+	profileCacheMu.Lock()
+	cache := loadProfileCache()
+	entry, ok := cache.Dirs[dir]
+	profileCacheMu.Unlock()
+	if profileCacheEnabled && ok && entry.Stamp == stamp {
+		if envrcs, _ := envrcStamps(dir, entry.Profiles); sameStamps(envrcs, entry.Envrcs) {
+			return append([]string(nil), entry.Profiles...), nil
+		}
+	}
+
+	profiles, err := scan(dir)
+	if err != nil {
+		return nil, err
+	}
+	envrcs, envrcsSettled := envrcStamps(dir, profiles)
+	profileCacheMu.Lock()
+	defer profileCacheMu.Unlock()
+	if settled && envrcsSettled {
+		cache.Dirs[dir] = cachedDir{Stamp: stamp, Profiles: profiles, Envrcs: envrcs}
+		cache.dirty = true
+	} else if ok {
+		delete(cache.Dirs, dir)
+		cache.dirty = true
+	}
+	return profiles, nil
+}
+
+// envrcStamps returns the stamps of the .envrc of the profiles in dir, and
+// whether they all changed long enough ago to be cached
+func envrcStamps(dir string, profiles []string) (map[string]fileStamp, bool) {
+	stamps := make(map[string]fileStamp, len(profiles))
+	settled := true
+	for _, name := range profiles {
+		stamp, ok := stampOf(filepath.Join(dir, name, ".envrc"))
+		stamps[name], settled = stamp, settled && ok
+	}
+	return stamps, settled
+}
+
+// forgetProfileDir drops the cached profiles of dir, for changes that leave
+// the directory itself as it was, such as adding an .envrc to one of its
+// subdirectories
+func forgetProfileDir(dir string) {
+	profileCacheMu.Lock()
+	defer profileCacheMu.Unlock()
+	cache := loadProfileCache()
+	if _, ok := cache.Dirs[dir]; ok {
+		delete(cache.Dirs, dir)
+		cache.dirty = true
+	}
+}
+
+// cachedProfileSummary returns the summary of the profile in profileDir
+// from the cache while the files it is read from are unchanged, reading
+// it otherwise. The fields that describe the current environment (Name,
+// Path, Active, Direnv) are left for the caller.
+func cachedProfileSummary(profileDir string) ProfileSummary {
+	stamps := map[string]fileStamp{}
+	settled := true
+	for _, file := range summaryFiles {
+		stamp, ok := stampOf(filepath.Join(profileDir, file))
+		stamps[file], settled = stamp, settled && ok
+	}
+
+	profileCacheMu.Lock()
+	cache := loadProfileCache()
+	entry, ok := cache.Summaries[profileDir]
+	profileCacheMu.Unlock()
+	if profileCacheEnabled && ok && sameStamps(entry.Stamps, stamps) {
+		return entry.Summary
+	}
+
+	summary := readProfileSummary(profileDir)
+	profileCacheMu.Lock()
+	defer profileCacheMu.Unlock()
+	if settled {
+		cache.Summaries[profileDir] = cachedSummary{Stamps: stamps, Summary: summary}
+		cache.dirty = true
+	} else if ok {
+		delete(cache.Summaries, profileDir)
+		cache.dirty = true
+	}
+	return summary
+}
+
+func sameStamps(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for file, stamp := range a {
+		if b[file] != stamp {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,222 @@
+package commands
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/mindmorass/shell-profile-manager/internal/features"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type CleanOptions struct {
+	ProfileName string
+	// All cleans every profile
+	All bool
+	// DryRun reports what would be removed without removing it
+	DryRun bool
+	// Jobs is how many profiles --all cleans at once (0: the default)
+	Jobs int
+}
+
+// featureCache is a cache directory declared by a feature of a profile
+type featureCache struct {
+	Path    string
+	Feature string
+}
+
+// CleanProfile empties the cache directories the features of a profile
+// declare (Terraform providers, kubectl discovery and HTTP caches, helm
+// repository indexes, ...) and reports the space freed. The directories
+// themselves are kept, since the tools expect them to exist.
+func CleanProfile(profilesDir string, opts CleanOptions) error {
+	profiles, err := selectedProfiles(profilesDir, opts.ProfileName, opts.All)
+	if err != nil {
+		return err
+	}
+
+	var freed int64
+	removed := false
+	failed := 0
+	if opts.All {
+		// As update --all does, profiles are cleaned concurrently with a
+		// status line each, in their order
+		var mu sync.Mutex
+		progress := ui.NewProgress("Cleaning", len(profiles))
+		progress.RunEach(profiles, parallelJobs(opts.Jobs), func(profileName string) (string, error) {
+			size, cleaned, err := cleanProfileCaches(profilePath(profilesDir, profileName), profileName, opts.DryRun)
+			mu.Lock()
+			freed += size
+			removed = removed || cleaned
+			mu.Unlock()
+			switch {
+			case err != nil:
+				return "", err
+			case !cleaned:
+				return "nothing to clean", nil
+			case opts.DryRun:
+				return "would free " + formatFileSize(size), nil
+			default:
+				return "freed " + formatFileSize(size), nil
+			}
+		})
+		failed = progress.Finish()
+	} else {
+		for _, profileName := range profiles {
+			size, cleaned, err := cleanProfileCaches(profilePath(profilesDir, profileName), profileName, opts.DryRun)
+			if err != nil {
+				return err
+			}
+			freed += size
+			removed = removed || cleaned
+		}
+	}
+
+	switch {
+	case !removed:
+		ui.PrintInfo("No caches to clean")
+	case opts.DryRun:
+		ui.PrintInfo(fmt.Sprintf("Would free %s (dry run)", formatFileSize(freed)))
+	default:
+		ui.PrintSuccess(fmt.Sprintf("Freed %s", formatFileSize(freed)))
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to clean %d of %d profile(s)", failed, len(profiles))
+	}
+	if !removed || opts.DryRun {
+		return nil
+	}
+	return changesApplied()
+}
+
+// cleanProfileCaches empties the caches of a profile, listing each one
+// under the name of the profile, and returns the space freed and whether
+// anything was removed
+func cleanProfileCaches(profileDir, profileName string, dryRun bool) (int64, bool, error) {
+	if !dryRun {
+		unlock, err := lockProfile(profileDir)
+		if err != nil {
+			return 0, false, err
+		}
+		defer unlock()
+	}
+
+	feats, err := LoadProfileFeatures(profileDir)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var freed int64
+	cleaned := false
+	for _, cache := range profileCaches(feats) {
+		size, entries, err := cleanCache(profileDir, cache.Path, dryRun)
+		if err != nil {
+			return freed, cleaned, newIOError(err, "failed to clean %s in profile %s", cache.Path, profileName)
+		}
+		if entries == 0 {
+			continue
+		}
+		if !cleaned {
+			ui.PrintInfo(profileName + ":")
+			cleaned = true
+		}
+		verb := "Removed"
+		if dryRun {
+			verb = "Would remove"
+		}
+		fmt.Fprintf(ui.Stdout(), "  %s %s (%s, %s)\n", verb, cache.Path, formatFileSize(size), cache.Feature)
+		freed += size
+	}
+	return freed, cleaned, nil
+}
+
+// profileCaches returns the cache directories of features, each once
+func profileCaches(feats []features.Feature) []featureCache {
+	seen := make(map[string]bool)
+	var caches []featureCache
+	for _, feature := range feats {
+		for _, path := range feature.Caches {
+			path = filepath.ToSlash(filepath.Clean(filepath.FromSlash(path)))
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+			caches = append(caches, featureCache{Path: path, Feature: feature.Name})
+		}
+	}
+	return caches
+}
+
+// cleanCache removes what a cache directory of a profile holds and returns
+// its size and the number of entries removed. A cache that does not exist,
+// is not a directory, or leads outside the profile through a symbolic link
+// is left alone.
+func cleanCache(profileDir, cache string, dryRun bool) (int64, int, error) {
+	dir := filepath.Join(profileDir, filepath.FromSlash(cache))
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return 0, 0, nil
+	}
+	if !insideProfile(profileDir, dir) {
+		ui.PrintWarning(fmt.Sprintf("Skipping %s: it leads outside the profile", cache))
+		return 0, 0, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	var size int64
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		entrySize, err := diskUsage(path)
+		if err != nil {
+			return size, 0, err
+		}
+		size += entrySize
+		if dryRun {
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return size, 0, err
+		}
+	}
+	return size, len(entries), nil
+}
+
+// insideProfile reports whether dir, once symbolic links are followed, is
+// inside profileDir
+func insideProfile(profileDir, dir string) bool {
+	resolvedProfile, err := filepath.EvalSymlinks(profileDir)
+	if err != nil {
+		return false
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(resolvedProfile, resolved)
+	return err == nil && rel != "." && filepath.IsLocal(rel)
+}
+
+// diskUsage returns the size of the files under path, without following
+// symbolic links
+func diskUsage(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type CondaOptions struct {
+	ProfileName string
+	// Env is the conda environment activated when the profile loads
+	Env string
+}
+
+var condaEnvPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// ActivateConda activates a conda environment whenever the profile loads,
+// enabling the conda feature so the environment is found in the workspace
+func ActivateConda(profilesDir string, opts CondaOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if opts.Env == "" {
+		if opts.Env, err = ui.Input("Environment name:", ""); err != nil {
+			return err
+		}
+		if opts.Env == "" {
+			return NewValidationError("environment name is required")
+		}
+	}
+	if !condaEnvPattern.MatchString(opts.Env) {
+		return NewValidationError("invalid environment name: %s", opts.Env)
+	}
+	if err := ensureFeature(profilesDir, profileName, profileDir, "conda"); err != nil {
+		return err
+	}
+
+	if _, err := setManagedBlock(profileDir, "conda", condaEnvBlock(opts.Env)); err != nil {
+		return newIOError(err, "failed to update .envrc")
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Profile '%s' activates conda environment '%s'", profileName, opts.Env))
+	if _, err := os.Stat(filepath.Join(profileDir, ".conda", "envs", opts.Env)); os.IsNotExist(err) {
+		fmt.Printf("  Create it from the workspace with: conda create -n %s python\n", opts.Env)
+	}
+	return changesApplied()
+}
+
+// DeactivateConda stops activating a conda environment when the profile loads
+func DeactivateConda(profilesDir string, opts CondaOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	changed, err := setManagedBlock(profileDir, "conda", "")
+	if err != nil {
+		return newIOError(err, "failed to update .envrc")
+	}
+	if !changed {
+		ui.PrintInfo(fmt.Sprintf("Profile '%s' does not activate a conda environment", profileName))
+		return nil
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Profile '%s' no longer activates a conda environment", profileName))
+	return changesApplied()
+}
+
+// condaEnvBlock renders the managed .envrc block activating env. conda's
+// shell hook is needed for 'conda activate'; direnv keeps the variables it
+// sets (PATH, CONDA_PREFIX, ...) but not its shell functions.
+func condaEnvBlock(env string) string {
+	return fmt.Sprintf(`# conda environment activated in this workspace (profile conda activate)
+if has conda; then
+  eval "$(conda shell.bash hook)"
+  conda activate %s
+else
+  log_error "conda not found; environment %s was not activated"
+fi`, env, env)
+}
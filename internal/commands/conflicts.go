@@ -0,0 +1,286 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/direnv"
+	"github.com/mindmorass/shell-profile-manager/internal/redact"
+	"github.com/mindmorass/shell-profile-manager/internal/shell"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// Kinds of conflicts
+const (
+	// ConflictLeak is an account or tool setting of the shell that the
+	// profile leaves as it is, so it applies in every workspace
+	ConflictLeak = "leak"
+	// ConflictShadowed is a variable of the shell that the profile
+	// replaces or unsets while it is loaded
+	ConflictShadowed = "shadowed"
+)
+
+// leakingVars select an account, identity, or target: set globally, they
+// apply to every profile that does not set them itself. Variables with
+// secret names, those features set, and TF_VAR_ inputs count too.
+var leakingVars = []string{
+	"AWS_PROFILE", "AWS_DEFAULT_PROFILE", "AWS_REGION", "AWS_DEFAULT_REGION", "AWS_ACCESS_KEY_ID",
+	"GOOGLE_APPLICATION_CREDENTIALS", "CLOUDSDK_CORE_PROJECT", "CLOUDSDK_CORE_ACCOUNT",
+	"AZURE_SUBSCRIPTION_ID", "ARM_SUBSCRIPTION_ID", "VAULT_ADDR", "VAULT_NAMESPACE",
+	"GIT_AUTHOR_NAME", "GIT_AUTHOR_EMAIL", "GIT_COMMITTER_NAME", "GIT_COMMITTER_EMAIL", "GIT_SSH_COMMAND",
+	"KUBECONFIG", "NPM_CONFIG_REGISTRY", "DOCKER_HOST", "DOCKER_CONTEXT", "TF_WORKSPACE",
+}
+
+var (
+	// shellExport matches a variable exported in a bash or zsh startup file
+	shellExport = regexp.MustCompile(`^\s*(?:export|declare\s+-\w*x\w*|typeset\s+-\w*x\w*)\s+([A-Za-z_][A-Za-z0-9_]*)=(.*)$`)
+	// fishExport matches a variable exported in a fish startup file
+	fishExport = regexp.MustCompile(`^\s*set\s+((?:-[a-zA-Z]+\s+)+)([A-Za-z_][A-Za-z0-9_]*)\s+(.*)$`)
+)
+
+type ConflictsOptions struct {
+	ProfileName string
+}
+
+// Conflict is a variable the profile and the shell outside it disagree on
+type Conflict struct {
+	Variable string `json:"variable" yaml:"variable"`
+	// Kind is ConflictLeak or ConflictShadowed
+	Kind string `json:"kind" yaml:"kind"`
+	// ProfileValue is what the profile sets; nil when it leaves the
+	// variable as it is or unsets it
+	ProfileValue *string `json:"profile_value" yaml:"profile_value"`
+	ShellValue   string  `json:"shell_value" yaml:"shell_value"`
+	// Source is the startup file and line exporting the variable, if any
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+}
+
+// shellVar is a variable of the shell outside the profile
+type shellVar struct {
+	value  string
+	source string
+}
+
+// CheckConflicts compares the variables the profile's .envrc sets with the
+// shell outside of it: the current environment without what direnv loaded,
+// and the variables the shell's startup files export. It reports the
+// account and tool settings that leak into the profile because it does not
+// set them, and the variables the profile shadows.
+func CheckConflicts(profilesDir string, opts ConflictsOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+
+	doc, err := loadEnvrc(profileDir)
+	if err != nil {
+		return err
+	}
+	// Without direnv the values are as written in the .envrc, unexpanded
+	evaluated := direnv.Installed() && direnvIssue(profileDir, profileName) == ""
+	profileVars := map[string]*string{}
+	if evaluated {
+		if profileVars, err = direnv.Export(profileDir); err != nil {
+			return err
+		}
+	}
+	for _, name := range doc.Exports() {
+		if _, ok := profileVars[name]; !ok {
+			value := assignedValue(doc.Line(doc.Export(name)))
+			profileVars[name] = &value
+		}
+	}
+
+	feats, err := LoadProfileFeatures(profileDir)
+	if err != nil {
+		return err
+	}
+	managed := map[string]bool{}
+	for _, name := range leakingVars {
+		managed[name] = true
+	}
+	for _, feat := range feats {
+		for _, env := range feat.Env {
+			managed[env.Name] = true
+		}
+	}
+
+	var conflicts []Conflict
+	vars := shellVars()
+	for _, name := range sortedShellVars(vars) {
+		if strings.HasPrefix(name, "WORKSPACE_") {
+			continue
+		}
+		outside := vars[name]
+		value, set := profileVars[name]
+		switch {
+		case !set:
+			if managed[name] || redact.Sensitive(name) || strings.HasPrefix(name, "TF_VAR_") {
+				conflicts = append(conflicts, Conflict{Variable: name, Kind: ConflictLeak, ShellValue: outside.value, Source: outside.source})
+			}
+		case value == nil:
+			conflicts = append(conflicts, Conflict{Variable: name, Kind: ConflictShadowed, ShellValue: outside.value, Source: outside.source})
+		case !evaluated && strings.ContainsAny(*value, "$`"):
+			// Unexpanded values cannot be compared
+		case *value != outside.value && (outside.value == "" || !strings.Contains(*value, outside.value)):
+			// A profile extending a variable (PATH=bin:$PATH) does not
+			// shadow it
+			conflicts = append(conflicts, Conflict{Variable: name, Kind: ConflictShadowed, ProfileValue: value, ShellValue: outside.value, Source: outside.source})
+		}
+	}
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		return conflicts[i].Kind == ConflictLeak && conflicts[j].Kind != ConflictLeak
+	})
+	for i := range conflicts {
+		conflicts[i].ShellValue = redact.Value(conflicts[i].Variable, conflicts[i].ShellValue)
+		if v := conflicts[i].ProfileValue; v != nil {
+			masked := redact.Value(conflicts[i].Variable, *v)
+			conflicts[i].ProfileValue = &masked
+		}
+	}
+
+	leaks := 0
+	for _, c := range conflicts {
+		if c.Kind == ConflictLeak {
+			leaks++
+		}
+	}
+
+	if ui.Structured() {
+		if conflicts == nil {
+			conflicts = []Conflict{}
+		}
+		if err := ui.Render(conflicts); err != nil {
+			return err
+		}
+	} else if len(conflicts) == 0 {
+		ui.PrintSuccess(fmt.Sprintf("No conflicts between %s and the shell", profileName))
+	} else {
+		if !evaluated {
+			ui.PrintWarning("direnv cannot load the .envrc; profile values are as written in it")
+		}
+		table := ui.NewTable("VARIABLE", "CONFLICT", "PROFILE", "SHELL", "SOURCE").Truncate(2, 30).Truncate(3, 30)
+		for _, c := range conflicts {
+			profileValue := "-"
+			switch {
+			case c.ProfileValue != nil:
+				profileValue = *c.ProfileValue
+			case c.Kind == ConflictShadowed:
+				profileValue = "(unset)"
+			}
+			table.AddRow(c.Variable, c.Kind, profileValue, c.ShellValue, valueOr(c.Source, "environment"))
+		}
+		table.Render(os.Stdout)
+		if leaks > 0 {
+			fmt.Println()
+			fmt.Println("Leaked variables apply to every workspace that does not set them: set them in")
+			fmt.Println("the profile's .envrc (or .env), or move them out of your startup files.")
+		}
+	}
+
+	if leaks > 0 {
+		return fmt.Errorf("%d variable(s) leak into %s", leaks, profileName)
+	}
+	return nil
+}
+
+// shellVars returns the variables of the shell outside any profile: the
+// environment without what direnv loaded, and what the startup files of
+// the shells export. Each is located at the last startup file line
+// exporting it, preferring the user's own shell.
+func shellVars() map[string]shellVar {
+	vars := map[string]shellVar{}
+	for name, value := range direnv.BaseEnv() {
+		vars[name] = shellVar{value: value}
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return vars
+	}
+
+	shells := shell.Names()
+	if current := shell.Detect(); current != "" {
+		shells = append([]string{current}, withoutName(shells, current)...)
+	}
+	located := map[string]bool{}
+	seen := map[string]bool{}
+	for _, sh := range shells {
+		exports := map[string]shellVar{}
+		for _, file := range shell.StartupFiles(sh, homeDir) {
+			if seen[file] {
+				continue
+			}
+			seen[file] = true
+			for name, v := range startupExports(file, abbreviateHome(file, homeDir)) {
+				exports[name] = v
+			}
+		}
+		for name, v := range exports {
+			if located[name] {
+				continue
+			}
+			located[name] = true
+			if current, ok := vars[name]; ok {
+				v.value = current.value
+			}
+			vars[name] = v
+		}
+	}
+	return vars
+}
+
+// startupExports returns the variables a startup file exports, with the
+// value (as written) and location of the last line exporting each
+func startupExports(path, displayPath string) map[string]shellVar {
+	exports := map[string]shellVar{}
+	file, err := os.Open(path)
+	if err != nil {
+		return exports
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		source := fmt.Sprintf("%s:%d", displayPath, lineNo)
+		if m := shellExport.FindStringSubmatch(line); m != nil {
+			exports[m[1]] = shellVar{value: unquote(m[2]), source: source}
+		} else if m := fishExport.FindStringSubmatch(line); m != nil && strings.Contains(m[1], "x") {
+			exports[m[2]] = shellVar{value: unquote(m[3]), source: source}
+		}
+	}
+	return exports
+}
+
+// assignedValue returns the value an .envrc line assigns, as written
+func assignedValue(line string) string {
+	_, value, _ := strings.Cut(line, "=")
+	return unquote(value)
+}
+
+// unquote strips a trailing comment and the quotes around a value
+func unquote(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			return value[1 : end+1]
+		}
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
+
+func sortedShellVars(vars map[string]shellVar) []string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
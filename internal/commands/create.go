@@ -3,10 +3,17 @@ package commands
 import (
 	"fmt"
 	"os"
+	"os/user"
 	"path/filepath"
-	"regexp"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/mindmorass/shell-profile-manager/internal/features"
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+	"github.com/mindmorass/shell-profile-manager/internal/presets"
+	"github.com/mindmorass/shell-profile-manager/internal/secrets"
+	"github.com/mindmorass/shell-profile-manager/internal/templates"
 	"github.com/mindmorass/shell-profile-manager/internal/ui"
 )
 
@@ -18,100 +25,282 @@ type CreateOptions struct {
 	Force       bool
 	Interactive bool
 	DryRun      bool
-	InitGit     bool
-	GitRemote   string
+	// Out saves the dry-run plan to a file for 'profile apply'
+	Out       string
+	InitGit   bool
+	GitRemote string
+	// Features lists optional features to enable
+	Features []string
+	// Description and Tags are recorded in the manifest for the selector
+	Description string
+	Tags        []string
+	// DisableFeatures lists default features the profile goes without
+	DisableFeatures []string
+	// SecretsProvider selects where secret variables are loaded from
+	SecretsProvider string
+	// Owner is recorded in the manifest (default: the git email, or the
+	// current user)
+	Owner string
+	// Vars are custom key/values for templates, saved in the manifest
+	Vars map[string]string
+	// Extends is the base profile the new profile inherits from
+	Extends string
+	// Root is the profile root the profile is created in (default:
+	// profiles_dir)
+	Root string
+	// Commit commits the files of a profile recreated with --force when it
+	// is a git repository (auto_commit in the config; --no-commit turns it
+	// off)
+	Commit bool
+	// Preset names the preset the other options default to
+	Preset string
+
+	// preset is the applied preset (see applyPreset)
+	preset *presets.Preset
 }
 
-func CreateProfile(profilesDir string, opts CreateOptions) error {
-	profileDir := filepath.Join(profilesDir, opts.ProfileName)
+// placeholderEmail is the git email of profiles created without one
+const placeholderEmail = "your.email@example.com"
 
-	// Validate profile name
-	if opts.ProfileName == "" {
-		return fmt.Errorf("profile name is required")
-	}
+// TemplateVersion is the revision of the built-in templates recorded in new
+// profiles; bump it when the templates change what they render
+const TemplateVersion = 1
 
-	matched, err := regexp.MatchString(`^[a-zA-Z0-9_-]+$`, opts.ProfileName)
-	if err != nil {
-		return fmt.Errorf("failed to validate profile name: %w", err)
+// coreFeatures are the features every profile is built on; the wizard does
+// not offer to turn them off
+var coreFeatures = map[string]bool{"xdg": true, "git": true, "bin": true, "ssh": true}
+
+func CreateProfile(profilesDir string, opts CreateOptions) error {
+	// Validate profile name (the wizard asks for it when it is missing)
+	if opts.ProfileName == "" && !opts.Interactive {
+		return NewValidationError("profile name is required")
+	}
+	if err := applyPreset(&opts); err != nil {
+		return err
 	}
-	if !matched {
-		return fmt.Errorf("profile name can only contain letters, numbers, hyphens, and underscores")
+	if err := qualifyProfileName(&opts); err != nil {
+		return err
+	}
+	if opts.ProfileName != "" {
+		if err := checkNewProfileName(profilesDir, opts.ProfileName, opts.Force); err != nil {
+			return err
+		}
 	}
 
 	// Validate template
-	validTemplates := map[string]bool{
-		"basic": true, "personal": true, "work": true, "client": true,
-	}
-	if !validTemplates[opts.Template] {
-		return fmt.Errorf("invalid template: %s (must be: basic, personal, work, or client)", opts.Template)
+	if err := checkTemplate(opts.Template); err != nil {
+		return err
 	}
 
-	// Check if profile exists
-	if _, err := os.Stat(profileDir); err == nil && !opts.Force {
-		return fmt.Errorf("profile '%s' already exists at: %s (use --force to overwrite)", opts.ProfileName, profileDir)
+	allFeats, err := features.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load feature definitions: %w", err)
 	}
 
 	// Interactive mode
 	if opts.Interactive {
-		if err := interactiveSetup(&opts); err != nil {
+		if err := interactiveSetup(profilesDir, &opts, allFeats); err != nil {
 			return err
 		}
 	}
+	profileDir := newProfilePath(profilesDir, opts.ProfileName)
+
+	feats, rendered, err := prepareProfile(profilesDir, opts, allFeats)
+	if err != nil {
+		return err
+	}
 
 	// Dry run
 	if opts.DryRun {
-		ui.PrintInfo("DRY RUN - Nothing will be created")
-		fmt.Println()
-		fmt.Println("Would create:")
-		fmt.Printf("  Profile directory: %s\n", profileDir)
-		fmt.Printf("  .envrc file with WORKSPACE_PROFILE=%s\n", opts.ProfileName)
-		fmt.Printf("  .gitconfig with template: %s\n", opts.Template)
-		if opts.GitName != "" {
-			fmt.Printf("  Git user.name: %s\n", opts.GitName)
-		}
-		if opts.GitEmail != "" {
-			fmt.Printf("  Git user.email: %s\n", opts.GitEmail)
+		p, err := planCreate(profileDir, opts, feats)
+		if err != nil {
+			return err
 		}
-		return nil
+		return showPlan(p, opts.Out)
+	}
+
+	// Files of a recreated profile with uncommitted changes are not
+	// create's to commit
+	dirty, isRepo := map[string]bool(nil), false
+	if opts.Commit {
+		dirty, isRepo = gitDirtyPaths(profileDir)
 	}
 
 	// Create profile
 	ui.PrintInfo(fmt.Sprintf("Creating profile: %s (template: %s)", opts.ProfileName, opts.Template))
+	if err := writeProfile(profileDir, opts, feats, rendered); err != nil {
+		return err
+	}
+	if isRepo {
+		subject := fmt.Sprintf("spm create: %s from template %s", opts.ProfileName, opts.Template)
+		if subject, err := commitManagedChanges(profileDir, dirty, subject, nil); err != nil {
+			ui.PrintWarning(fmt.Sprintf("Failed to commit the changes: %v", err))
+		} else if subject != "" {
+			ui.PrintInfo(fmt.Sprintf("Committed: %s", subject))
+		}
+	}
 
-	// Create directories
-	dirs := []string{
-		".config/1Password",
-		".config/claude",
-		".config/gemini",
-		".ssh",
-		".aws",
-		".azure",
-		".gcloud",
-		".kube",
-		"bin",
-		"code",
+	// Initialize git if requested
+	if opts.InitGit {
+		gitOpts := GitOptions{
+			ProfileName: opts.ProfileName,
+			Remote:      opts.GitRemote,
+		}
+		if err := InitGit(profilesDir, gitOpts); err != nil {
+			ui.PrintWarning(fmt.Sprintf("Failed to initialize git: %v", err))
+		}
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Profile created successfully: %s", opts.ProfileName))
+	fmt.Println()
+	ui.PrintInfo("Next steps:")
+	fmt.Printf("  1. cd %s\n", profileDir)
+	fmt.Println("  2. direnv allow")
+	fmt.Println("  3. Edit .gitconfig as needed")
+	fmt.Printf("  4. echo $WORKSPACE_PROFILE to verify\n")
+	if opts.SecretsProvider != "" {
+		fmt.Printf("  5. Configure %s with: profile secrets %s %s\n", opts.SecretsProvider, opts.SecretsProvider, opts.ProfileName)
+	}
+	fmt.Println()
+	ui.PrintInfo(fmt.Sprintf("Profile location: %s", profileDir))
+
+	return changesApplied()
+}
+
+// BuildProfile creates a profile from opts without prompting or printing
+// (progress goes to the reporter). Interactive, DryRun, Out, InitGit, and
+// Commit are ignored.
+func BuildProfile(profilesDir string, opts CreateOptions) error {
+	if opts.ProfileName == "" {
+		return NewValidationError("profile name is required")
+	}
+	if err := applyPreset(&opts); err != nil {
+		return err
+	}
+	if err := qualifyProfileName(&opts); err != nil {
+		return err
+	}
+	if err := checkNewProfileName(profilesDir, opts.ProfileName, opts.Force); err != nil {
+		return err
+	}
+	if err := checkTemplate(opts.Template); err != nil {
+		return err
 	}
 
-	for _, dir := range dirs {
-		fullPath := filepath.Join(profileDir, dir)
-		if err := os.MkdirAll(fullPath, 0755); err != nil {
-			return fmt.Errorf("failed to create directory %s: %w", fullPath, err)
+	allFeats, err := features.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load feature definitions: %w", err)
+	}
+	feats, rendered, err := prepareProfile(profilesDir, opts, allFeats)
+	if err != nil {
+		return err
+	}
+	return writeProfile(newProfilePath(profilesDir, opts.ProfileName), opts, feats, rendered)
+}
+
+// prepareProfile validates the feature, secrets, and template options of a
+// new profile, and returns its features and rendered files. The templates
+// are rendered first so a broken template leaves nothing behind.
+func prepareProfile(profilesDir string, opts CreateOptions, allFeats []features.Feature) ([]features.Feature, map[string]string, error) {
+	for _, name := range append(append([]string{}, opts.Features...), opts.DisableFeatures...) {
+		if _, ok := features.Find(allFeats, name); !ok {
+			return nil, nil, NewValidationError("unknown feature: %s (see 'profile feature list')", name)
+		}
+	}
+	if opts.SecretsProvider != "" && !slices.Contains(secrets.Names(), opts.SecretsProvider) {
+		return nil, nil, NewValidationError("unknown secrets provider: %s (supported: %s)", opts.SecretsProvider, strings.Join(secrets.Names(), ", "))
+	}
+	for key := range opts.Vars {
+		if !envVarPattern.MatchString(key) {
+			return nil, nil, NewValidationError("invalid variable name: %s (use letters, digits, and underscores)", key)
+		}
+	}
+	if err := validateTags(opts.Tags); err != nil {
+		return nil, nil, err
+	}
+	feats, bases, err := newProfileFeatures(profilesDir, opts, allFeats)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Templates see the vars of the base profiles too
+	if vars := layeredVars(bases); vars != nil {
+		for key, value := range opts.Vars {
+			vars[key] = value
+		}
+		opts.Vars = vars
+	}
+	rendered, err := renderProfileFiles(opts, feats)
+	if err != nil {
+		return nil, nil, err
+	}
+	return feats, rendered, nil
+}
+
+// newProfileFeatures returns the features of a new profile and the layers
+// of the profile it extends (none if it extends nothing)
+func newProfileFeatures(profilesDir string, opts CreateOptions, allFeats []features.Feature) ([]features.Feature, []profileLayer, error) {
+	if opts.Extends == "" {
+		return features.Select(allFeats, opts.Features, opts.DisableFeatures), nil, nil
+	}
+	if opts.Extends == opts.ProfileName {
+		return nil, nil, NewValidationError("profile '%s' cannot extend itself", opts.ProfileName)
+	}
+	if !profileNamePattern.MatchString(opts.Extends) {
+		return nil, nil, NewValidationError("invalid base profile name: %s", opts.Extends)
+	}
+	// The base profile is in the same root as the new profile
+	baseDir := filepath.Join(filepath.Dir(newProfilePath(profilesDir, opts.ProfileName)), opts.Extends)
+	if _, err := os.Stat(filepath.Join(baseDir, ".envrc")); err != nil {
+		return nil, nil, NewValidationError("base profile '%s' does not exist", opts.Extends)
+	}
+	bases, err := profileLayers(baseDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	self := profileLayer{name: opts.ProfileName, dir: newProfilePath(profilesDir, opts.ProfileName), manifest: &manifest.Manifest{
+		Features: &manifest.FeatureSelection{Enable: opts.Features, Disable: opts.DisableFeatures},
+	}}
+	return layeredFeatures(allFeats, append(bases, self)), bases, nil
+}
+
+// writeProfile writes the directories, files, and manifest of a new profile
+func writeProfile(profileDir string, opts CreateOptions, feats []features.Feature, rendered map[string]string) error {
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Files inside the profile use its name without the root
+	opts.ProfileName = baseProfileName(opts.ProfileName)
+
+	// Create directories required by features
+	for _, feature := range feats {
+		for _, dir := range feature.Dirs {
+			fullPath := filepath.Join(profileDir, dir.Path)
+			if err := os.MkdirAll(fullPath, dir.FileMode()); err != nil {
+				return newIOError(err, "failed to create directory %s", fullPath)
+			}
+			if err := os.Chmod(fullPath, dir.FileMode()); err != nil {
+				return newIOError(err, "failed to set permissions on %s", fullPath)
+			}
 		}
 	}
 
-	// Set SSH directory permissions
-	sshDir := filepath.Join(profileDir, ".ssh")
-	if err := os.Chmod(sshDir, 0700); err != nil {
-		return fmt.Errorf("failed to set SSH directory permissions: %w", err)
+	// Create starter files provided by features
+	if _, err := updateFiles(profileDir, feats, false); err != nil {
+		return newIOError(err, "failed to create feature files")
 	}
 
 	// Create .envrc
-	if err := createEnvrc(profileDir, opts); err != nil {
+	if err := createEnvrc(profileDir, rendered["envrc"]); err != nil {
 		return fmt.Errorf("failed to create .envrc: %w", err)
 	}
 
 	// Create .gitconfig
-	if err := createGitconfig(profileDir, opts); err != nil {
+	if err := createGitconfig(profileDir, rendered["gitconfig"]); err != nil {
 		return fmt.Errorf("failed to create .gitconfig: %w", err)
 	}
 
@@ -123,14 +312,16 @@ func CreateProfile(profilesDir string, opts CreateOptions) error {
 	// Create known_hosts
 	knownHostsPath := filepath.Join(profileDir, ".ssh/known_hosts")
 	if _, err := os.Stat(knownHostsPath); os.IsNotExist(err) {
-		if err := os.WriteFile(knownHostsPath, []byte{}, 0600); err != nil {
+		if err := writeProfileFile(knownHostsPath, []byte{}, 0600); err != nil {
 			return fmt.Errorf("failed to create known_hosts: %w", err)
 		}
 	}
 
-	// Create 1Password config
-	if err := create1PasswordConfig(profileDir, opts); err != nil {
-		return fmt.Errorf("failed to create 1Password config: %w", err)
+	// Create 1Password config (unless another feature replaces the agent)
+	if _, ok := features.Find(feats, "1password"); ok {
+		if err := create1PasswordConfig(profileDir, opts); err != nil {
+			return fmt.Errorf("failed to create 1Password config: %w", err)
+		}
 	}
 
 	// Create SSH wrapper
@@ -139,7 +330,7 @@ func CreateProfile(profilesDir string, opts CreateOptions) error {
 	}
 
 	// Create .gitignore
-	if err := createGitignore(profileDir); err != nil {
+	if err := createGitignore(profileDir, rendered["gitignore"]); err != nil {
 		return fmt.Errorf("failed to create .gitignore: %w", err)
 	}
 
@@ -153,38 +344,180 @@ func CreateProfile(profilesDir string, opts CreateOptions) error {
 		return fmt.Errorf("failed to create .env.example: %w", err)
 	}
 
-	// Initialize git if requested
-	if opts.InitGit {
-		gitOpts := GitOptions{
-			ProfileName: opts.ProfileName,
-			Remote:      opts.GitRemote,
+	// Record the schema version this profile was created with
+	m := manifest.New(CurrentSchemaVersion())
+	m.Description, m.Tags = opts.Description, opts.Tags
+	m.Owner = valueOr(opts.Owner, valueOr(opts.GitEmail, currentUsername()))
+	m.Template, m.TemplateVersion = opts.Template, TemplateVersion
+	if repo, commit, err := templates.Resolve(opts.Template); err == nil && repo != "" {
+		m.TemplateRepo = repo + "@" + commit
+	}
+	m.Vars, m.Extends = opts.Vars, opts.Extends
+	if len(opts.Features) > 0 || len(opts.DisableFeatures) > 0 {
+		m.Features = &manifest.FeatureSelection{Enable: opts.Features, Disable: opts.DisableFeatures}
+	}
+	presetManifest(m, opts.preset, opts.SecretsProvider)
+	if opts.SecretsProvider != "" {
+		// Saving the secrets also adds their (empty) block to .envrc
+		if m.Secrets == nil {
+			m.Secrets = &manifest.Secrets{Provider: opts.SecretsProvider}
 		}
-		if err := InitGit(profilesDir, gitOpts); err != nil {
-			ui.PrintWarning(fmt.Sprintf("Failed to initialize git: %v", err))
+		if err := saveSecrets(profileDir, m); err != nil {
+			return err
 		}
+	} else if err := manifest.Save(profileDir, m); err != nil {
+		return newIOError(err, "failed to create profile manifest")
 	}
 
-	ui.PrintSuccess(fmt.Sprintf("Profile created successfully: %s", opts.ProfileName))
-	fmt.Println()
-	ui.PrintInfo("Next steps:")
-	fmt.Printf("  1. cd %s\n", profileDir)
-	fmt.Println("  2. direnv allow")
-	fmt.Println("  3. Edit .gitconfig as needed")
-	fmt.Printf("  4. echo $WORKSPACE_PROFILE to verify\n")
-	fmt.Println()
-	ui.PrintInfo(fmt.Sprintf("Profile location: %s", profileDir))
+	// Link shared files, which are recorded in the manifest
+	if _, _, err := updateLinks(profileDir, feats, false); err != nil {
+		return newIOError(err, "failed to link feature files")
+	}
 
+	changes := []string{fmt.Sprintf("Created from template %s", opts.Template)}
+	if opts.Preset != "" {
+		changes = append(changes, fmt.Sprintf("Preset %s", opts.Preset))
+	}
+	if len(opts.Features) > 0 {
+		changes = append(changes, fmt.Sprintf("Enabled features: %s", strings.Join(opts.Features, ", ")))
+	}
+	if len(opts.DisableFeatures) > 0 {
+		changes = append(changes, fmt.Sprintf("Disabled features: %s", strings.Join(opts.DisableFeatures, ", ")))
+	}
+	if opts.Extends != "" {
+		changes = append(changes, fmt.Sprintf("Extends %s", opts.Extends))
+	}
+	recordChecksums(profileDir, checksummedFiles...)
+	recordHistory(profileDir, "create", changes)
 	return nil
 }
 
-func interactiveSetup(opts *CreateOptions) error {
+// currentUsername returns the login name of the current user ("" if unknown)
+func currentUsername() string {
+	current, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	return current.Username
+}
+
+// checkTemplate validates a template name against the built-in and user
+// templates
+func checkTemplate(name string) error {
+	all, err := templates.List()
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, t := range all {
+		if t.Name == name {
+			return nil
+		}
+		names = append(names, t.Name)
+	}
+	return NewValidationError("invalid template: %s (must be one of: %s)", name, strings.Join(names, ", "))
+}
+
+// qualifyProfileName prefixes the profile name with opts.Root, so the rest
+// of create works with the name the profile is known by ("work/acme")
+func qualifyProfileName(opts *CreateOptions) error {
+	if opts.Root == "" || opts.Root == defaultRoot || opts.ProfileName == "" {
+		return nil
+	}
+	if root, _ := splitProfileName(opts.ProfileName); root != "" {
+		if root != opts.Root {
+			return NewValidationError("profile '%s' is qualified with root '%s', not '%s'", opts.ProfileName, root, opts.Root)
+		}
+		return nil
+	}
+	opts.ProfileName = opts.Root + "/" + opts.ProfileName
+	return nil
+}
+
+// checkNewProfileName validates the name of a profile about to be created
+func checkNewProfileName(profilesDir, profileName string, force bool) error {
+	if err := checkProfileRoot(profileName); err != nil {
+		return err
+	}
+	if !profileNamePattern.MatchString(baseProfileName(profileName)) {
+		return NewValidationError("profile name can only contain letters, numbers, hyphens, and underscores")
+	}
+	if root, _ := splitProfileName(profileName); root != "" {
+		if _, err := os.Stat(profileRoots[root]); err != nil {
+			return NewValidationError("profile root '%s' is not available: %s", root, profileRoots[root])
+		}
+	}
+	if targets := aliasTargets(profilesDir, profileName); len(targets) > 0 {
+		return NewValidationError("'%s' is an alias of %s; choose another name or remove the alias", profileName, strings.Join(targets, ", "))
+	}
+	profileDir := newProfilePath(profilesDir, profileName)
+	if _, err := os.Stat(profileDir); err == nil && !force {
+		return NewValidationError("profile '%s' already exists at: %s (use --force to overwrite)", profileName, profileDir)
+	}
+	return nil
+}
+
+// interactiveSetup is the create wizard. It asks for the profile name (if
+// not given), identity, which tool integrations to enable, and the secrets
+// provider; the answers become the same options the flags set.
+func interactiveSetup(profilesDir string, opts *CreateOptions, allFeats []features.Feature) error {
+	if opts.ProfileName == "" && opts.Root == "" && len(profileRoots) > 0 {
+		root, err := ui.Select("Profile root:", append([]string{defaultRoot}, rootNames()...), defaultRoot)
+		if err != nil {
+			return fmt.Errorf("failed to select profile root: %w", err)
+		}
+		opts.Root = root
+	}
+	for opts.ProfileName == "" {
+		name, err := ui.Input("Profile name:", "")
+		if err != nil {
+			return fmt.Errorf("failed to get profile name: %w", err)
+		}
+		named := *opts
+		named.ProfileName = name
+		if err := qualifyProfileName(&named); err != nil {
+			return err
+		}
+		if err := checkNewProfileName(profilesDir, named.ProfileName, opts.Force); err != nil {
+			ui.PrintWarning(err.Error())
+			continue
+		}
+		opts.ProfileName = named.ProfileName
+	}
+
 	// Template selection
-	template, err := ui.SelectTemplate()
+	all, err := templates.List()
+	if err != nil {
+		return err
+	}
+	var templateNames, templateDescriptions []string
+	for _, t := range all {
+		templateNames = append(templateNames, t.Name)
+		templateDescriptions = append(templateDescriptions, t.Description)
+	}
+	template, err := ui.SelectTemplate(templateNames, templateDescriptions, opts.Template)
 	if err != nil {
 		return fmt.Errorf("failed to select template: %w", err)
 	}
 	opts.Template = template
 
+	description, err := ui.Input("Description (press Enter to skip):", opts.Description)
+	if err != nil {
+		return fmt.Errorf("failed to get description: %w", err)
+	}
+	opts.Description = description
+
+	tags, err := ui.Input("Tags, comma-separated (press Enter to skip):", strings.Join(opts.Tags, ","))
+	if err != nil {
+		return fmt.Errorf("failed to get tags: %w", err)
+	}
+	opts.Tags = nil
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			opts.Tags = append(opts.Tags, tag)
+		}
+	}
+
 	// Git configuration
 	gitName, err := ui.Input("Git user name (press Enter to skip):", "")
 	if err != nil {
@@ -202,6 +535,47 @@ func interactiveSetup(opts *CreateOptions) error {
 		opts.GitEmail = gitEmail
 	}
 
+	// Tool integrations: default features start checked, optional ones
+	// unchecked (unless enabled with --feature)
+	var names, defaults, descriptions []string
+	for _, feature := range allFeats {
+		if coreFeatures[feature.Name] {
+			continue
+		}
+		names = append(names, feature.Name)
+		descriptions = append(descriptions, feature.Description)
+		enabled := !feature.Optional || slices.Contains(opts.Features, feature.Name)
+		if enabled && !slices.Contains(opts.DisableFeatures, feature.Name) {
+			defaults = append(defaults, feature.Name)
+		}
+	}
+	selected, err := ui.Checklist("Tool integrations:", names, defaults, descriptions)
+	if err != nil {
+		return fmt.Errorf("failed to select features: %w", err)
+	}
+	opts.Features, opts.DisableFeatures = nil, nil
+	for _, feature := range allFeats {
+		if coreFeatures[feature.Name] {
+			continue
+		}
+		chosen := slices.Contains(selected, feature.Name)
+		if feature.Optional && chosen {
+			opts.Features = append(opts.Features, feature.Name)
+		} else if !feature.Optional && !chosen {
+			opts.DisableFeatures = append(opts.DisableFeatures, feature.Name)
+		}
+	}
+
+	// Secrets provider
+	provider, err := ui.Select("Secrets provider:", append([]string{"none"}, secrets.Names()...), valueOr(opts.SecretsProvider, "none"))
+	if err != nil {
+		return fmt.Errorf("failed to select secrets provider: %w", err)
+	}
+	opts.SecretsProvider = ""
+	if provider != "none" {
+		opts.SecretsProvider = provider
+	}
+
 	// Ask about git initialization
 	initGit, err := ui.Confirm("Initialize git repository after creation?", false)
 	if err != nil {
@@ -222,196 +596,72 @@ func interactiveSetup(opts *CreateOptions) error {
 	return nil
 }
 
-func createEnvrc(profileDir string, opts CreateOptions) error {
-	ui.PrintInfo("Creating .envrc...")
-
-	created := time.Now().UTC().Format("2006-01-02 15:04:05 UTC")
-
-	envrcContent := fmt.Sprintf(`#!/usr/bin/env bash
-# Workspace profile: %s
-# Template: %s
-# Created: %s
-
-# Workspace identification
-export WORKSPACE_PROFILE="%s"
-export WORKSPACE_HOME="$PWD"
-
-# Load global profile settings (exports only)
-# Environment variables work with direnv, aliases and functions do not
-GLOBAL_DIR="$(cd "$(dirname "$PWD")/.global" 2>/dev/null && pwd)"
-if [[ -d "$GLOBAL_DIR" ]]; then
-    # Source exports (environment variables work with direnv)
-    if [[ -f "$GLOBAL_DIR/exports.sh" && -r "$GLOBAL_DIR/exports.sh" ]]; then
-        source "$GLOBAL_DIR/exports.sh"
-    fi
-fi
-
-# XDG Base Directory specification
-# Point all XDG-compliant tools to workspace-specific config
-export XDG_CONFIG_HOME="$WORKSPACE_HOME/.config"
-
-# 1Password SSH Agent
-# Point to 1Password SSH agent socket for SSH key management
-export SSH_AUTH_SOCK="$HOME/Library/Group Containers/2BUA8C4S2C.com.1password/t/agent.sock"
-
-# Git configuration
-export GIT_CONFIG_GLOBAL="$WORKSPACE_HOME/.gitconfig"
-
-# Add custom bin directory to PATH (before system paths)
-# The bin/ssh wrapper uses the profile-specific SSH config
-# Git will automatically use bin/ssh since it's first in PATH
-PATH_add bin
-
-# AWS configuration
-# Point AWS CLI and SDKs to workspace-specific config and credentials
-export AWS_CONFIG_FILE="$WORKSPACE_HOME/.aws/config"
-export AWS_SHARED_CREDENTIALS_FILE="$WORKSPACE_HOME/.aws/credentials"
-
-# Kubernetes configuration
-# Point kubectl to workspace-specific kubeconfig
-export KUBECONFIG="$WORKSPACE_HOME/.kube/config"
-
-# Terraform configuration
-# Use workspace-specific Terraform CLI config
-export TF_CLI_CONFIG_FILE="$WORKSPACE_HOME/.terraformrc"
-# Optionally set workspace-specific plugin cache
-# export TF_PLUGIN_CACHE_DIR="$WORKSPACE_HOME/.terraform.d/plugin-cache"
-
-# Azure CLI configuration
-# Point Azure CLI to workspace-specific config directory
-export AZURE_CONFIG_DIR="$WORKSPACE_HOME/.azure"
-
-# Google Cloud SDK configuration
-# Point gcloud CLI to workspace-specific config directory
-export CLOUDSDK_CONFIG="$WORKSPACE_HOME/.gcloud"
-
-# Claude Code configuration
-# Point Claude Code to workspace-specific config directory
-export CLAUDE_CONFIG_DIR="$WORKSPACE_HOME/.config/claude"
-
-# Gemini CLI configuration
-# Point Gemini CLI to workspace-specific config directory
-export GEMINI_CONFIG_DIR="$WORKSPACE_HOME/.config/gemini"
-
-# Load .env file if it exists (for secrets)
-dotenv_if_exists .env
+// renderProfileFiles renders the files create writes from templates,
+// keyed by template file name (see templates.Files)
+func renderProfileFiles(opts CreateOptions, feats []features.Feature) (map[string]string, error) {
+	data := templates.Data{
+		Profile:           baseProfileName(opts.ProfileName),
+		Template:          opts.Template,
+		Created:           time.Now().UTC().Format(readmeTimeFormat),
+		GitName:           valueOr(opts.GitName, "Your Name"),
+		GitEmail:          valueOr(opts.GitEmail, placeholderEmail),
+		Owner:             opts.Owner,
+		Description:       opts.Description,
+		Tags:              opts.Tags,
+		Vars:              opts.Vars,
+		EnvrcSections:     renderEnvrcSections(feats),
+		GitignoreSections: renderGitignoreSections(feats),
+	}
+
+	rendered := make(map[string]string)
+	for _, file := range templates.Files {
+		content, err := templates.Render(file, data)
+		if err != nil {
+			return nil, NewValidationError("%v", err)
+		}
+		rendered[file] = content
+	}
+	return rendered, nil
+}
 
-# Load local overrides
-dotenv_if_exists .envrc.local
+// renderForProfile renders a template file for an existing profile, with
+// the variables recorded in its manifest and .gitconfig
+func renderForProfile(profileDir, file string, feats []features.Feature) (string, error) {
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return "", err
+	}
+	data := templates.Data{
+		Profile:           filepath.Base(profileDir),
+		Template:          valueOr(m.Template, valueOr(readmeField(profileDir, "Template"), "basic")),
+		Created:           readmeField(profileDir, "Created"),
+		Owner:             m.Owner,
+		Description:       m.Description,
+		Tags:              m.Tags,
+		Vars:              m.Vars,
+		EnvrcSections:     renderEnvrcSections(feats),
+		GitignoreSections: renderGitignoreSections(feats),
+	}
+	gitconfigFile := filepath.Join(profileDir, ".gitconfig")
+	if _, err := os.Stat(gitconfigFile); err == nil {
+		data.GitName = getGitConfig(gitconfigFile, "user.name")
+		data.GitEmail = getGitConfig(gitconfigFile, "user.email")
+	}
+	return templates.Render(file, data)
+}
 
-# Welcome message
-log_status "Loaded workspace profile: $WORKSPACE_PROFILE"
-`, opts.ProfileName, opts.Template, created, opts.ProfileName)
+func createEnvrc(profileDir, content string) error {
+	reporter.Info("Creating .envrc...")
 
 	envrcPath := filepath.Join(profileDir, ".envrc")
-	return os.WriteFile(envrcPath, []byte(envrcContent), 0644)
+	return writeProfileFile(envrcPath, []byte(content), 0644)
 }
 
-func createGitconfig(profileDir string, opts CreateOptions) error {
-	ui.PrintInfo("Creating .gitconfig...")
-
-	gitName := opts.GitName
-	if gitName == "" {
-		gitName = "Your Name"
-	}
-
-	gitEmail := opts.GitEmail
-	if gitEmail == "" {
-		gitEmail = "your.email@example.com"
-	}
-
-	gitconfigContent := fmt.Sprintf(`# Git configuration for workspace profile: %s
-# Template: %s
-
-[user]
-    name = %s
-    email = %s
-
-[core]
-    editor = vim
-    autocrlf = input
-    whitespace = trailing-space,space-before-tab
-
-[init]
-    defaultBranch = main
-
-[push]
-    default = current
-    autoSetupRemote = true
-
-[pull]
-    rebase = false
-
-[fetch]
-    prune = true
-
-[merge]
-    conflictstyle = diff3
-
-[rebase]
-    autoStash = true
-    autoSquash = true
-
-[diff]
-    algorithm = histogram
-    colorMoved = default
-
-[log]
-    abbrevCommit = true
-    date = iso
-
-[color]
-    ui = auto
-
-[alias]
-    st = status -sb
-    lg = log --graph --pretty=format:'%%Cred%%h%%Creset -%%C(yellow)%%d%%Creset %%s %%Cgreen(%%cr) %%C(bold blue)<%%an>%%Creset' --abbrev-commit
-    br = branch -v
-    co = checkout
-    ci = commit
-    cm = commit -m
-    amend = commit --amend --no-edit
-    last = log -1 HEAD --stat
-    undo = reset HEAD~1 --mixed
-    aliases = config --get-regexp alias
-`, opts.ProfileName, opts.Template, gitName, gitEmail)
-
-	// Add template-specific configuration
-	switch opts.Template {
-	case "personal":
-		gitconfigContent += `
-# Personal project settings
-[commit]
-    verbose = true
-
-[credential]
-    helper = cache --timeout=3600
-`
-	case "work":
-		gitconfigContent += `
-# Work project settings
-[commit]
-    verbose = true
-    # Uncomment to enable GPG signing
-    # gpgsign = true
-
-[credential]
-    helper = cache --timeout=7200
-`
-	case "client":
-		gitconfigContent += `
-# Client project settings
-[commit]
-    verbose = true
-    # gpgsign = true
-
-[credential]
-    helper = cache --timeout=3600
-`
-	}
+func createGitconfig(profileDir, content string) error {
+	reporter.Info("Creating .gitconfig...")
 
 	gitconfigPath := filepath.Join(profileDir, ".gitconfig")
-	return os.WriteFile(gitconfigPath, []byte(gitconfigContent), 0644)
+	return writeProfileFile(gitconfigPath, []byte(content), 0644)
 }
 
 func createSSHConfig(profileDir string, opts CreateOptions) error {
@@ -419,11 +669,11 @@ func createSSHConfig(profileDir string, opts CreateOptions) error {
 
 	// Check if .ssh/config already exists - if so, skip creation
 	if _, err := os.Stat(sshConfigPath); err == nil {
-		ui.PrintWarning("SSH config already exists, skipping creation")
+		reporter.Warning("SSH config already exists, skipping creation")
 		return nil
 	}
 
-	ui.PrintInfo("Creating SSH config...")
+	reporter.Info("Creating SSH config...")
 	profileAbsPath, err := filepath.Abs(profileDir)
 	if err != nil {
 		return fmt.Errorf("failed to get absolute path: %w", err)
@@ -488,16 +738,16 @@ Host *
 #     IdentityFile %s/.ssh/id_ed25519_internal
 `, opts.ProfileName, profileAbsPath, profileAbsPath, profileAbsPath, profileAbsPath, profileAbsPath, profileAbsPath)
 
-	if err := os.WriteFile(sshConfigPath, []byte(sshConfigContent), 0600); err != nil {
+	if err := writeProfileFile(sshConfigPath, []byte(sshConfigContent), 0600); err != nil {
 		return err
 	}
 
-	ui.PrintSuccess("Created SSH config")
+	reporter.Success("Created SSH config")
 	return nil
 }
 
 func create1PasswordConfig(profileDir string, opts CreateOptions) error {
-	ui.PrintInfo("Creating 1Password agent configuration...")
+	reporter.Info("Creating 1Password agent configuration...")
 
 	configContent := fmt.Sprintf(`# 1Password SSH Agent configuration for workspace profile: %s
 # This config is used when this profile is active
@@ -527,11 +777,11 @@ func create1PasswordConfig(profileDir string, opts CreateOptions) error {
 `, opts.ProfileName)
 
 	configPath := filepath.Join(profileDir, ".config/1Password/agent.toml")
-	return os.WriteFile(configPath, []byte(configContent), 0600)
+	return writeProfileFile(configPath, []byte(configContent), 0600)
 }
 
 func createSSHWrapper(profileDir string) error {
-	ui.PrintInfo("Creating SSH wrapper script...")
+	reporter.Info("Creating SSH wrapper script...")
 
 	wrapperContent := `#!/usr/bin/env bash
 # SSH wrapper that uses workspace-specific SSH config
@@ -546,97 +796,61 @@ exec /usr/bin/ssh -F "$WORKSPACE_HOME/.ssh/config" "$@"
 `
 
 	wrapperPath := filepath.Join(profileDir, "bin/ssh")
-	if err := os.WriteFile(wrapperPath, []byte(wrapperContent), 0755); err != nil {
+	if err := writeProfileFile(wrapperPath, []byte(wrapperContent), 0755); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func createGitignore(profileDir string) error {
-	ui.PrintInfo("Creating .gitignore...")
-
-	gitignoreContent := `# Workspace profile gitignore
-
-# Environment files with secrets
-.env
-.envrc.local
-
-# SSH keys and sensitive files
-.ssh/id_*
-.ssh/*.pem
-.ssh/*.key
-.ssh/known_hosts
-
-# AWS credentials and sensitive config
-.aws/credentials
-.aws/cli/cache
-.aws/sso/cache
-
-# Azure CLI credentials and sensitive config
-.azure/config
-.azure/clouds.config
-.azure/accessTokens.json
-.azure/msal_token_cache.json
-.azure/azureProfile.json
-
-# Google Cloud SDK credentials and sensitive config
-.gcloud/configurations/
-.gcloud/credentials
-.gcloud/access_tokens.db
-.gcloud/legacy_credentials/
-.gcloud/logs/
-
-# Claude Code configuration (may contain API keys and sensitive data)
-.config/claude/
-
-# Gemini CLI configuration (may contain API keys and sensitive data)
-.config/gemini/
-
-# Terraform
-.terraform/
-.terraform.lock.hcl
-*.tfstate
-*.tfstate.*
-*.tfvars
-.terraform.d/plugin-cache/
-.terraform.d/checkpoint_cache
-.terraform.d/checkpoint_signature
-
-# Terragrunt
-.terragrunt-cache/
-*.tfplan
-
-# Kubernetes
-.kube/cache
-.kube/http-cache
-
-# OS files
-.DS_Store
-Thumbs.db
-
-# Editor files
-.vscode/
-.idea/
-*.swp
-*.swo
-*~
-
-# Build artifacts
-bin/
-dist/
-build/
-*.log
-`
+func createGitignore(profileDir, content string) error {
+	reporter.Info("Creating .gitignore...")
 
 	gitignorePath := filepath.Join(profileDir, ".gitignore")
-	return os.WriteFile(gitignorePath, []byte(gitignoreContent), 0644)
+	return writeProfileFile(gitignorePath, []byte(content), 0644)
 }
 
+// renderEnvrcSections renders the .envrc blocks of all features
+func renderEnvrcSections(feats []features.Feature) string {
+	var b strings.Builder
+	for _, feature := range feats {
+		if !feature.HasEnvrcSection() {
+			continue
+		}
+		b.WriteString(feature.CommentBlock())
+		for _, v := range feature.Env {
+			b.WriteString(envLine(feature, v) + "\n")
+		}
+		for _, line := range feature.Lines {
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// renderGitignoreSections renders the .gitignore blocks of all features
+func renderGitignoreSections(feats []features.Feature) string {
+	var b strings.Builder
+	for _, feature := range feats {
+		for _, group := range feature.Gitignore {
+			b.WriteString(group.CommentLine())
+			for _, pattern := range group.Patterns {
+				b.WriteString(pattern + "\n")
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// readmeTimeFormat is how README.md records the creation time
+const readmeTimeFormat = "2006-01-02 15:04:05 UTC"
+
 func createREADME(profileDir string, opts CreateOptions) error {
-	ui.PrintInfo("Creating README.md...")
+	reporter.Info("Creating README.md...")
 
-	created := time.Now().UTC().Format("2006-01-02 15:04:05 UTC")
+	created := time.Now().UTC().Format(readmeTimeFormat)
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		homeDir = "" // Fall back to not abbreviating path
@@ -750,11 +964,11 @@ func createREADME(profileDir string, opts CreateOptions) error {
 		"14. Add custom scripts to bin/ directory\n"
 
 	readmePath := filepath.Join(profileDir, "README.md")
-	return os.WriteFile(readmePath, []byte(readmeContent), 0644)
+	return writeProfileFile(readmePath, []byte(readmeContent), 0644)
 }
 
 func createEnvExample(profileDir string) error {
-	ui.PrintInfo("Creating .env.example...")
+	reporter.Info("Creating .env.example...")
 
 	envExampleContent := `# Example environment variables
 # Copy this to .env and fill in your secrets
@@ -793,5 +1007,5 @@ func createEnvExample(profileDir string) error {
 `
 
 	envExamplePath := filepath.Join(profileDir, ".env.example")
-	return os.WriteFile(envExamplePath, []byte(envExampleContent), 0644)
+	return writeProfileFile(envExamplePath, []byte(envExampleContent), 0644)
 }
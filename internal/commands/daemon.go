@@ -0,0 +1,626 @@
+package commands
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mindmorass/shell-profile-manager/internal/atomicfile"
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+	"github.com/mindmorass/shell-profile-manager/internal/notify"
+	"github.com/mindmorass/shell-profile-manager/internal/templates"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// Tasks the daemon runs
+const (
+	// daemonAlerts checks for drift and expiring credentials
+	daemonAlerts = "alerts"
+	// daemonBackups prunes backups past the retention of 'profile gc'
+	daemonBackups = "backups"
+	// daemonTemplates updates the template repositories
+	daemonTemplates = "templates"
+)
+
+// defaultMaintenanceInterval is how often the daemon prunes backups and
+// updates the template repositories
+const defaultMaintenanceInterval = 24 * time.Hour
+
+// Files of the daemon in the state directory
+const (
+	daemonStateFileName = "daemon.json"
+	daemonLogFileName   = "daemon.log"
+)
+
+// Service the daemon is installed as
+const (
+	launchdLabel    = "com.github.mindmorass.spm"
+	systemdUnitName = "spm-daemon.service"
+)
+
+// daemonStopTimeout is how long stop waits for the daemon to exit
+const daemonStopTimeout = 5 * time.Second
+
+type DaemonOptions struct {
+	AlertOptions
+	// Interval is the time between checks for drift and expiring
+	// credentials (0: 5m)
+	Interval time.Duration
+	// Maintenance is the time between backup pruning and template
+	// repository updates (0: 24h)
+	Maintenance time.Duration
+	// BackupKeep and BackupMaxAge are the backup retention (see GcOptions)
+	BackupKeep   int
+	BackupMaxAge int
+	// Print prints the service file install would write
+	Print bool
+}
+
+// daemonState is what the daemon records in the state directory: the
+// process running it and when each task last ran
+type daemonState struct {
+	PID     int                  `json:"pid,omitempty"`
+	Started time.Time            `json:"started,omitempty"`
+	LastRun map[string]time.Time `json:"last_run,omitempty"`
+	// Every holds the interval of each task the daemon runs with
+	Every map[string]string `json:"every,omitempty"`
+	// Errors holds the error of each task that failed the last time it ran
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// running reports whether the daemon process recorded is running
+func (s *daemonState) running() bool {
+	return s.PID != 0 && processRunning(s.PID)
+}
+
+// DaemonTask is a task of the daemon, as shown by 'profile daemon status'
+type DaemonTask struct {
+	Name    string     `json:"name" yaml:"name"`
+	Every   string     `json:"every" yaml:"every"`
+	LastRun *time.Time `json:"last_run,omitempty" yaml:"last_run,omitempty"`
+	Error   string     `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// DaemonInfo is the status of the daemon
+type DaemonInfo struct {
+	Running bool         `json:"running" yaml:"running"`
+	PID     int          `json:"pid,omitempty" yaml:"pid,omitempty"`
+	Started *time.Time   `json:"started,omitempty" yaml:"started,omitempty"`
+	Service string       `json:"service,omitempty" yaml:"service,omitempty"`
+	Log     string       `json:"log" yaml:"log"`
+	Tasks   []DaemonTask `json:"tasks" yaml:"tasks"`
+}
+
+// daemonTask is a task the daemon runs every interval
+type daemonTask struct {
+	name  string
+	every time.Duration
+	run   func() error
+}
+
+// RunDaemon runs the daemon in the foreground until it is stopped: it
+// checks every profile for drift and expiring credentials at an interval
+// and notifies new alerts, and prunes backups and updates the template
+// repositories daily. When each task last ran is kept in the state
+// directory, so a restart does not run the maintenance again. This is what
+// 'profile daemon start' and the service files run.
+func RunDaemon(profilesDir string, opts DaemonOptions) error {
+	state, err := loadDaemonState()
+	if err != nil {
+		return err
+	}
+	if state.PID != os.Getpid() && state.running() {
+		return NewValidationError("the daemon is already running (pid %d)", state.PID)
+	}
+
+	backend := valueOr(opts.Notify, notify.Auto)
+	if _, err := notify.Resolve(backend); err != nil {
+		daemonLog("Not sending notifications: %v", err)
+		backend = ""
+	}
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	maintenance := opts.Maintenance
+	if maintenance <= 0 {
+		maintenance = defaultMaintenanceInterval
+	}
+	tasks := []daemonTask{
+		{daemonAlerts, interval, func() error { return daemonCheckAlerts(profilesDir, opts.AlertOptions, backend) }},
+		{daemonBackups, maintenance, func() error { return daemonPruneBackups(profilesDir, opts) }},
+		{daemonTemplates, maintenance, daemonUpdateTemplates},
+	}
+
+	state.PID = os.Getpid()
+	state.Started = time.Now().UTC()
+	state.Every = make(map[string]string, len(tasks))
+	for _, task := range tasks {
+		state.Every[task.name] = task.every.String()
+	}
+	if err := saveDaemonState(state); err != nil {
+		return err
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	daemonLog("Daemon started (pid %d): checking profiles every %s, maintenance every %s", state.PID, interval, maintenance)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, task := range tasks {
+			if last, ok := state.LastRun[task.name]; ok && time.Since(last) < task.every {
+				continue
+			}
+			runDaemonTask(state, task)
+		}
+		if err := saveDaemonState(state); err != nil {
+			daemonLog("Failed to save the daemon state: %v", err)
+		}
+
+		select {
+		case sig := <-signals:
+			state.PID = 0
+			if err := saveDaemonState(state); err != nil {
+				daemonLog("Failed to save the daemon state: %v", err)
+			}
+			daemonLog("Daemon stopped (%s)", sig)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDaemonTask runs a task and records when it ran and how it failed. A
+// failed task is logged and retried at its next run.
+func runDaemonTask(state *daemonState, task daemonTask) {
+	err := task.run()
+	var changed *ChangesApplied
+	if errors.As(err, &changed) {
+		err = nil
+	}
+	if state.LastRun == nil {
+		state.LastRun = make(map[string]time.Time)
+	}
+	if state.Errors == nil {
+		state.Errors = make(map[string]string)
+	}
+	state.LastRun[task.name] = time.Now().UTC()
+	delete(state.Errors, task.name)
+	if err != nil {
+		state.Errors[task.name] = err.Error()
+		daemonLog("%s failed: %v", task.name, err)
+	}
+}
+
+// daemonCheckAlerts logs the new alerts of every profile and sends a
+// notification for each with the backend ("" sends none)
+func daemonCheckAlerts(profilesDir string, opts AlertOptions, backend string) error {
+	alerts, err := ProfileAlerts(profilesDir, opts)
+	if err != nil {
+		return err
+	}
+	var fresh []Alert
+	if backend == "" {
+		fresh = unnotified(alerts)
+	} else {
+		fresh = NotifyAlerts(alerts, backend)
+	}
+	for _, alert := range fresh {
+		daemonLog("⚠ %s: %s", alert.Profile, alert.Message)
+	}
+	return nil
+}
+
+// daemonPruneBackups removes the backups of every profile past the
+// retention of 'profile gc'
+func daemonPruneBackups(profilesDir string, opts DaemonOptions) error {
+	profiles, err := ListProfileNames(profilesDir)
+	if err != nil {
+		return err
+	}
+	keep, cutoff := backupRetention(GcOptions{BackupKeep: opts.BackupKeep, BackupMaxAge: opts.BackupMaxAge})
+	c := &collector{}
+	for _, profileName := range profiles {
+		if err := c.pruneBackups(profileName, profilePath(profilesDir, profileName), keep, cutoff); err != nil {
+			return err
+		}
+	}
+	if c.removed > 0 {
+		daemonLog("Pruned %d backup(s), reclaimed %s", c.removed, formatFileSize(c.freed))
+	}
+	return nil
+}
+
+// daemonUpdateTemplates updates the template repositories, if there are any
+func daemonUpdateTemplates() error {
+	repos, err := templates.LoadRepos()
+	if err != nil || len(repos) == 0 {
+		return err
+	}
+	daemonLog("Updating template repositories")
+	return UpdateTemplateRepos(TemplateOptions{})
+}
+
+// daemonLog writes a timestamped line to the output of the daemon, which
+// is its log file
+func daemonLog(format string, args ...any) {
+	fmt.Printf("%s %s\n", time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf(format, args...))
+}
+
+// StartDaemon runs the daemon in a background process that outlives the
+// terminal, logging to daemon.log in the state directory
+func StartDaemon(opts DaemonOptions) error {
+	state, err := loadDaemonState()
+	if err != nil {
+		return err
+	}
+	if state.running() {
+		ui.PrintInfo(fmt.Sprintf("The daemon is already running (pid %d)", state.PID))
+		return nil
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the profile executable: %w", err)
+	}
+	logPath, err := daemonLogPath()
+	if err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return newIOError(err, "failed to open %s", logPath)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(executable, daemonArgs(opts)...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = detachedProcess()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start the daemon: %w", err)
+	}
+	// Recorded before the daemon does, so a second start sees it
+	state.PID = cmd.Process.Pid
+	state.Started = time.Now().UTC()
+	if err := saveDaemonState(state); err != nil {
+		return err
+	}
+	cmd.Process.Release()
+
+	ui.PrintSuccess(fmt.Sprintf("Started the daemon (pid %d)", state.PID))
+	fmt.Printf("  Log: %s\n", logPath)
+	return changesApplied()
+}
+
+// daemonArgs returns the arguments that run the daemon with opts. Options
+// left at zero are not passed, so the daemon reads them from the config.
+func daemonArgs(opts DaemonOptions) []string {
+	args := []string{"daemon", "run"}
+	if opts.Interval > 0 {
+		args = append(args, "--interval", opts.Interval.String())
+	}
+	if opts.Maintenance > 0 {
+		args = append(args, "--maintenance", opts.Maintenance.String())
+	}
+	if opts.Within > 0 {
+		args = append(args, "--within", opts.Within.String())
+	}
+	if opts.Notify != "" {
+		args = append(args, "--notifier", opts.Notify)
+	}
+	return args
+}
+
+// StopDaemon stops the daemon started by 'profile daemon start' or a
+// service, and waits for it to exit
+func StopDaemon() error {
+	state, err := loadDaemonState()
+	if err != nil {
+		return err
+	}
+	if !state.running() {
+		if state.PID != 0 {
+			state.PID = 0
+			if err := saveDaemonState(state); err != nil {
+				return err
+			}
+		}
+		ui.PrintInfo("The daemon is not running")
+		return nil
+	}
+
+	pid := state.PID
+	if err := stopProcess(pid); err != nil {
+		return fmt.Errorf("failed to stop the daemon (pid %d): %w", pid, err)
+	}
+	deadline := time.Now().Add(daemonStopTimeout)
+	for processRunning(pid) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the daemon (pid %d) did not exit within %s", pid, daemonStopTimeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	ui.PrintSuccess(fmt.Sprintf("Stopped the daemon (pid %d)", pid))
+	if path, err := servicePath(); err == nil && serviceInstalled(path) {
+		fmt.Printf("  It is installed as a service and starts again at login (%s)\n", path)
+	}
+	return changesApplied()
+}
+
+// DaemonStatus shows whether the daemon is running, and how often its tasks
+// run and when they last ran
+func DaemonStatus() error {
+	state, err := loadDaemonState()
+	if err != nil {
+		return err
+	}
+	logPath, err := daemonLogPath()
+	if err != nil {
+		return err
+	}
+
+	info := DaemonInfo{Running: state.running(), Log: logPath}
+	if info.Running {
+		started := state.Started
+		info.PID, info.Started = state.PID, &started
+	}
+	if path, err := servicePath(); err == nil && serviceInstalled(path) {
+		info.Service = path
+	}
+	for _, task := range []struct {
+		name  string
+		every time.Duration
+	}{{daemonAlerts, defaultWatchInterval}, {daemonBackups, defaultMaintenanceInterval}, {daemonTemplates, defaultMaintenanceInterval}} {
+		t := DaemonTask{Name: task.name, Every: valueOr(state.Every[task.name], task.every.String()), Error: state.Errors[task.name]}
+		if last, ok := state.LastRun[task.name]; ok {
+			t.LastRun = &last
+		}
+		info.Tasks = append(info.Tasks, t)
+	}
+
+	if ui.Structured() {
+		return ui.Render(info)
+	}
+	if info.Running {
+		fmt.Printf("%s✓ Running%s (pid %d, since %s)\n", ui.ColorGreen, ui.ColorReset, info.PID, info.Started.Local().Format("Jan 2 15:04"))
+	} else {
+		fmt.Printf("%s✗ Not running%s (start it with 'profile daemon start')\n", ui.ColorYellow, ui.ColorReset)
+	}
+	fmt.Printf("Service: %s\n", valueOr(info.Service, "not installed"))
+	fmt.Printf("Log:     %s\n\n", info.Log)
+
+	table := ui.NewTable("TASK", "EVERY", "LAST RUN", "STATUS").Truncate(3, 60)
+	for _, t := range info.Tasks {
+		lastRun, status := "never", "ok"
+		if t.LastRun != nil {
+			lastRun = t.LastRun.Local().Format("Jan 2 15:04")
+		} else {
+			status = "-"
+		}
+		if t.Error != "" {
+			status = "failed: " + t.Error
+		}
+		table.AddRow(t.Name, t.Every, lastRun, status)
+	}
+	table.Render(os.Stdout)
+	return nil
+}
+
+// InstallDaemon writes a service file that runs the daemon at login: a
+// launchd agent on macOS, a systemd user unit elsewhere
+func InstallDaemon(opts DaemonOptions) error {
+	if runtime.GOOS == "windows" {
+		return NewValidationError("daemon install supports launchd and systemd; on Windows, run 'profile daemon start' at logon with Task Scheduler")
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the profile executable: %w", err)
+	}
+	logPath, err := daemonLogPath()
+	if err != nil {
+		return err
+	}
+	path, err := servicePath()
+	if err != nil {
+		return err
+	}
+
+	command := append([]string{executable}, daemonArgs(opts)...)
+	var content string
+	var next []string
+	if runtime.GOOS == "darwin" {
+		content = launchdPlist(command, logPath)
+		next = []string{"launchctl load -w " + path}
+	} else {
+		content = systemdUnit(command, logPath)
+		next = []string{"systemctl --user daemon-reload", "systemctl --user enable --now " + systemdUnitName}
+	}
+	if opts.Print {
+		fmt.Print(content)
+		return nil
+	}
+
+	if existing, err := os.ReadFile(path); err == nil && string(existing) == content {
+		ui.PrintInfo(fmt.Sprintf("The daemon service is up to date: %s", path))
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return newIOError(err, "failed to create %s", filepath.Dir(path))
+	}
+	if err := atomicfile.WriteFile(path, []byte(content), 0644); err != nil {
+		return newIOError(err, "failed to write %s", path)
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Installed the daemon service: %s", path))
+	if state, err := loadDaemonState(); err == nil && state.running() {
+		ui.PrintWarning(fmt.Sprintf("The daemon is already running (pid %d); stop it with 'profile daemon stop' first", state.PID))
+	}
+	fmt.Println("  Start it with:")
+	for _, line := range next {
+		fmt.Printf("    %s\n", line)
+	}
+	return changesApplied()
+}
+
+// servicePath returns where install writes the service file
+func servicePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	if runtime.GOOS == "darwin" {
+		return filepath.Join(homeDir, "Library", "LaunchAgents", launchdLabel+".plist"), nil
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if !filepath.IsAbs(configHome) {
+		configHome = filepath.Join(homeDir, ".config")
+	}
+	return filepath.Join(configHome, "systemd", "user", systemdUnitName), nil
+}
+
+// serviceEnv returns the environment the service runs the daemon with:
+// PATH, so the notifiers and git are found, and the XDG base directories
+// spm uses when they are set
+func serviceEnv() []envVar {
+	var env []envVar
+	for _, name := range []string{"PATH", "XDG_CONFIG_HOME", "XDG_STATE_HOME", "XDG_CACHE_HOME"} {
+		if value := os.Getenv(name); value != "" {
+			env = append(env, envVar{name, value})
+		}
+	}
+	return env
+}
+
+type envVar struct{ name, value string }
+
+// serviceInstalled reports whether install wrote the service file at path
+func serviceInstalled(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// launchdPlist returns a launchd agent that runs command at login and
+// restarts it when it fails
+func launchdPlist(command []string, logPath string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	fmt.Fprintf(&b, "\t<key>Label</key>\n\t<string>%s</string>\n", launchdLabel)
+	b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, arg := range command {
+		fmt.Fprintf(&b, "\t\t<string>%s</string>\n", xmlText(arg))
+	}
+	b.WriteString("\t</array>\n")
+	b.WriteString("\t<key>EnvironmentVariables</key>\n\t<dict>\n")
+	for _, kv := range serviceEnv() {
+		fmt.Fprintf(&b, "\t\t<key>%s</key>\n\t\t<string>%s</string>\n", kv.name, xmlText(kv.value))
+	}
+	b.WriteString("\t</dict>\n")
+	b.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	// Restarted when it fails, but not after 'profile daemon stop'
+	b.WriteString("\t<key>KeepAlive</key>\n\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+	fmt.Fprintf(&b, "\t<key>StandardOutPath</key>\n\t<string>%s</string>\n", xmlText(logPath))
+	fmt.Fprintf(&b, "\t<key>StandardErrorPath</key>\n\t<string>%s</string>\n", xmlText(logPath))
+	b.WriteString("</dict>\n</plist>\n")
+	return b.String()
+}
+
+// systemdUnit returns a systemd user unit that runs command at login and
+// restarts it when it fails
+func systemdUnit(command []string, logPath string) string {
+	quoted := make([]string, len(command))
+	for i, arg := range command {
+		quoted[i] = systemdQuote(arg)
+	}
+	var b strings.Builder
+	b.WriteString("[Unit]\nDescription=spm daemon: profile alerts and maintenance\n\n[Service]\n")
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(quoted, " "))
+	for _, kv := range serviceEnv() {
+		fmt.Fprintf(&b, "Environment=%s\n", systemdQuote(kv.name+"="+kv.value))
+	}
+	// Restarted when it fails, but not after 'profile daemon stop'
+	b.WriteString("Restart=on-failure\nRestartSec=30\n")
+	fmt.Fprintf(&b, "StandardOutput=append:%s\nStandardError=append:%s\n", logPath, logPath)
+	b.WriteString("\n[Install]\nWantedBy=default.target\n")
+	return b.String()
+}
+
+// xmlText escapes text for an XML element
+func xmlText(text string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
+
+// systemdQuote quotes a word of a systemd unit when it needs it, and
+// escapes the specifiers systemd expands
+func systemdQuote(word string) string {
+	word = strings.ReplaceAll(word, "%", "%%")
+	if !strings.ContainsAny(word, " \t\"'\\$") {
+		return word
+	}
+	word = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `$$`).Replace(word)
+	return `"` + word + `"`
+}
+
+// loadDaemonState reads the daemon state; a missing or unreadable state is
+// empty
+func loadDaemonState() (*daemonState, error) {
+	path, err := daemonStatePath()
+	if err != nil {
+		return nil, err
+	}
+	state := &daemonState{}
+	readJSON(path, state)
+	return state, nil
+}
+
+func saveDaemonState(state *daemonState) error {
+	path, err := daemonStatePath()
+	if err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return newIOError(err, "failed to create %s", filepath.Dir(path))
+	}
+	if err := atomicfile.WriteFile(path, content, 0644); err != nil {
+		return newIOError(err, "failed to save the daemon state")
+	}
+	return nil
+}
+
+func daemonStatePath() (string, error) {
+	stateDir, err := config.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, daemonStateFileName), nil
+}
+
+func daemonLogPath() (string, error) {
+	stateDir, err := config.StateDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		return "", newIOError(err, "failed to create %s", stateDir)
+	}
+	return filepath.Join(stateDir, daemonLogFileName), nil
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package commands
+
+import "syscall"
+
+// detachedProcess starts the daemon in a new session, so it is not
+// stopped with the terminal it was started from
+func detachedProcess() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// stopProcess asks a process to exit
+func stopProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
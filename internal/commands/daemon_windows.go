@@ -0,0 +1,24 @@
+package commands
+
+import (
+	"os"
+	"syscall"
+)
+
+// detachedProcessFlag is the DETACHED_PROCESS creation flag
+const detachedProcessFlag = 0x00000008
+
+// detachedProcess starts the daemon without a console, so it is not
+// stopped with the one it was started from
+func detachedProcess() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: detachedProcessFlag | syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// stopProcess ends a process; Windows has no signal to ask it to exit
+func stopProcess(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Kill()
+}
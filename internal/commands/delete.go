@@ -12,49 +12,34 @@ type DeleteOptions struct {
 	ProfileName string
 	Force       bool
 	DryRun      bool
+	// Out saves the dry-run plan to a file for 'profile apply'
+	Out string
 }
 
 func DeleteProfile(profilesDir string, opts DeleteOptions) error {
-	profileDir := filepath.Join(profilesDir, opts.ProfileName)
-
 	// If no profile name provided and not forced/dry-run, show interactive selection
 	if opts.ProfileName == "" && !opts.Force && !opts.DryRun {
-		// Get list of profiles
-		entries, err := os.ReadDir(profilesDir)
-		if err != nil {
-			return fmt.Errorf("failed to read profiles directory: %w", err)
-		}
-
-		var profiles []string
-		for _, entry := range entries {
-			if entry.IsDir() && entry.Name() != ".git" {
-				profilePath := filepath.Join(profilesDir, entry.Name())
-				envrcPath := filepath.Join(profilePath, ".envrc")
-				if _, err := os.Stat(envrcPath); err == nil {
-					profiles = append(profiles, entry.Name())
-				}
-			}
-		}
-
-		if len(profiles) == 0 {
-			return fmt.Errorf("no profiles found to delete")
-		}
-
-		selected, err := ui.SelectProfile(profiles, "Select profile to delete:")
+		selected, err := PromptForProfile(profilesDir, "Select profile to delete:")
 		if err != nil {
 			return err
 		}
 		opts.ProfileName = selected
 	}
 
+	if opts.ProfileName == "" {
+		return NewValidationError("profile name is required")
+	}
+
+	opts.ProfileName = qualifiedName(profilesDir, opts.ProfileName)
+	profileDir := profilePath(profilesDir, opts.ProfileName)
+
 	// Check if profile exists
 	if _, err := os.Stat(profileDir); os.IsNotExist(err) {
-		return fmt.Errorf("profile '%s' does not exist at: %s", opts.ProfileName, profileDir)
+		return NewValidationError("profile '%s' does not exist at: %s", opts.ProfileName, profileDir)
 	}
 
 	// Check if currently in this profile
-	currentProfile := os.Getenv("WORKSPACE_PROFILE")
-	if currentProfile == opts.ProfileName {
+	if isActiveProfile(opts.ProfileName, profileDir) {
 		ui.PrintWarning("You are currently in this profile!")
 		ui.PrintInfo("The profile will remain active until you leave the directory")
 	}
@@ -108,24 +93,12 @@ func DeleteProfile(profilesDir string, opts DeleteOptions) error {
 
 	// Dry run
 	if opts.DryRun {
-		ui.PrintInfo("DRY RUN - Nothing will be deleted")
 		fmt.Println()
-		fmt.Println("Would delete:")
-		count := 0
-		filepath.Walk(profileDir, func(path string, info os.FileInfo, err error) error { //nolint:errcheck // Listing files for preview, errors are not critical
-			if err != nil {
-				return nil
-			}
-			if !info.IsDir() && count < 20 {
-				fmt.Printf("  - %s\n", path)
-				count++
-			}
-			return nil
-		})
-		if fileCount > 20 {
-			fmt.Printf("  ... and %d more files\n", fileCount-20)
+		p, err := planDelete(profileDir, opts)
+		if err != nil {
+			return err
 		}
-		return nil
+		return showPlan(p, opts.Out)
 	}
 
 	// Confirmation
@@ -145,10 +118,16 @@ func DeleteProfile(profilesDir string, opts DeleteOptions) error {
 	}
 
 	// Delete profile
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	ui.PrintInfo(fmt.Sprintf("Deleting profile: %s", opts.ProfileName))
 
 	if err := os.RemoveAll(profileDir); err != nil {
-		return fmt.Errorf("failed to delete profile: %w", err)
+		return newIOError(err, "failed to delete profile")
 	}
 
 	ui.PrintSuccess(fmt.Sprintf("Profile deleted: %s", opts.ProfileName))
@@ -167,5 +146,5 @@ func DeleteProfile(profilesDir string, opts DeleteOptions) error {
 		}
 	}
 
-	return nil
+	return changesApplied()
 }
@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mindmorass/shell-profile-manager/internal/envrc"
+	"github.com/mindmorass/shell-profile-manager/internal/features"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+const (
+	migrationRename  = "Rename"
+	migrationRemove  = "Remove"
+	migrationComment = "Comment out"
+	migrationSkip    = "Skip"
+)
+
+// migrateDeprecated finds deprecated variables still exported from .envrc
+// and asks, one at a time, whether to rename, remove, or comment them out.
+// The migrations are applied to doc, which is left as it is in dry-run. It
+// returns a description of each migration applied (or planned in dry-run).
+func migrateDeprecated(doc *envrc.Document, feats []features.Feature, dryRun bool) ([]string, error) {
+	var migrated []string
+
+	for _, feature := range feats {
+		for _, dep := range feature.Deprecated {
+			idx := doc.Export(dep.Name)
+			if idx == -1 {
+				continue
+			}
+
+			canRename := dep.ReplacedBy != "" && doc.Export(dep.ReplacedBy) == -1
+			options := []string{migrationRemove, migrationComment, migrationSkip}
+			defaultAction := migrationComment
+			if canRename {
+				options = append([]string{migrationRename}, options...)
+				defaultAction = migrationRename
+			}
+
+			if dryRun {
+				migrated = append(migrated, describeMigration(dep, defaultAction))
+				continue
+			}
+
+			message := fmt.Sprintf("%s is deprecated", dep.Name)
+			if dep.Reason != "" {
+				message += " (" + dep.Reason + ")"
+			}
+			if canRename {
+				message += fmt.Sprintf("; replaced by %s", dep.ReplacedBy)
+			}
+			action, err := ui.Select(message+":", options, defaultAction)
+			if errors.Is(err, ui.ErrInputRequired) {
+				ui.PrintWarning(fmt.Sprintf("Skipping deprecated %s (pass --yes to migrate automatically)", dep.Name))
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			switch action {
+			case migrationRename:
+				doc.RenameExport(idx, dep.Name, dep.ReplacedBy)
+			case migrationRemove:
+				doc.Remove(idx, idx+1)
+			case migrationComment:
+				doc.CommentOut(idx)
+			default:
+				continue
+			}
+			migrated = append(migrated, describeMigration(dep, action))
+		}
+	}
+
+	return migrated, nil
+}
+
+func describeMigration(dep features.Deprecation, action string) string {
+	switch action {
+	case migrationRename:
+		return fmt.Sprintf("%s → %s", dep.Name, dep.ReplacedBy)
+	case migrationRemove:
+		return fmt.Sprintf("%s removed", dep.Name)
+	default:
+		return fmt.Sprintf("%s commented out", dep.Name)
+	}
+}
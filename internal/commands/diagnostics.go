@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/checksums"
+	"github.com/mindmorass/shell-profile-manager/internal/perms"
+)
+
+// Sources of diagnostics
+const (
+	// DiagnosticDoctor is what 'profile doctor' reports
+	DiagnosticDoctor = "doctor"
+	// DiagnosticDrift is a managed file changed outside spm
+	DiagnosticDrift = "drift"
+)
+
+// Diagnostic is a problem with a profile, located at the file it is about
+// so editors can show it inline. Problems with the profile as a whole are
+// located at its .envrc.
+type Diagnostic struct {
+	Profile string `json:"profile" yaml:"profile"`
+	// File is the absolute path of the file the problem is about
+	File string `json:"file" yaml:"file"`
+	// Severity is "error" or "warning"
+	Severity string `json:"severity" yaml:"severity"`
+	Source   string `json:"source" yaml:"source"`
+	Message  string `json:"message" yaml:"message"`
+}
+
+// ProfileDiagnostics returns what doctor and verify report for a profile,
+// or for every profile when profileName is ""
+func ProfileDiagnostics(profilesDir, profileName string) ([]Diagnostic, error) {
+	profiles := []string{qualifiedName(profilesDir, profileName)}
+	if profileName == "" {
+		var err error
+		if profiles, err = ListProfileNames(profilesDir); err != nil {
+			return nil, err
+		}
+	}
+
+	diagnostics := []Diagnostic{}
+	for _, name := range profiles {
+		profileDir := profilePath(profilesDir, name)
+		if !isProfileDir(profileDir) {
+			return nil, NewValidationError("profile '%s' does not exist at: %s", name, profileDir)
+		}
+		envrcPath := filepath.Join(profileDir, ".envrc")
+
+		feats, err := LoadProfileFeatures(profileDir)
+		if err != nil {
+			return nil, err
+		}
+		issues, err := profileIssues(profileDir, feats)
+		if err != nil {
+			return nil, newIOError(err, "failed to check profile %s", name)
+		}
+		if issue := direnvIssue(profileDir, name); issue != "" {
+			issues = append(issues, issue)
+		}
+		for _, issue := range issues {
+			diagnostics = append(diagnostics, Diagnostic{Profile: name, File: envrcPath, Severity: "warning", Source: DiagnosticDoctor, Message: issue})
+		}
+
+		drift, err := driftDiagnostics(name, profileDir)
+		if err != nil {
+			return nil, newIOError(err, "failed to verify profile %s", name)
+		}
+		diagnostics = append(diagnostics, drift...)
+	}
+	return diagnostics, nil
+}
+
+// driftDiagnostics returns a diagnostic for each recorded file of a
+// profile that changed outside spm, as checksumIssues describes them. A
+// removed file is reported at the .envrc.
+func driftDiagnostics(profileName, profileDir string) ([]Diagnostic, error) {
+	results, err := checksums.Verify(profileDir)
+	if err != nil {
+		return nil, err
+	}
+	accept := fmt.Sprintf("accept it with 'profile verify %s --accept'", profileName)
+	var diagnostics []Diagnostic
+	for _, result := range results {
+		d := Diagnostic{Profile: profileName, File: filepath.Join(profileDir, filepath.FromSlash(result.Path)), Severity: "warning", Source: DiagnosticDrift}
+		switch result.Status {
+		case checksums.StatusModified:
+			d.Message = "Modified outside spm; once reviewed, " + accept
+		case checksums.StatusMissing:
+			d.File = filepath.Join(profileDir, ".envrc")
+			d.Severity = "error"
+			d.Message = fmt.Sprintf("%s was removed outside spm; restore it with 'profile update %s'", result.Path, profileName)
+		case checksums.StatusPermissions:
+			if _, ok := perms.Expected(result.Path, false); ok {
+				continue
+			}
+			d.Message = fmt.Sprintf("Permissions changed outside spm: %s (spm wrote %s); %s", strings.TrimPrefix(result.Mode, "0"), strings.TrimPrefix(result.ExpectedMode, "0"), accept)
+		default:
+			continue
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	return diagnostics, nil
+}
@@ -0,0 +1,180 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mindmorass/shell-profile-manager/internal/direnv"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type DirenvOptions struct {
+	ProfileName string
+	// All applies the command to every profile
+	All bool
+	// Tags applies the command to the profiles tagged with every one of
+	// these
+	Tags []string
+	// Jobs is how many profiles --all (or --tag) allows at once (0: the
+	// default)
+	Jobs int
+}
+
+// DirenvStatus shows the direnv allow state of one or all profiles
+func DirenvStatus(profilesDir string, opts DirenvOptions) error {
+	if !direnv.Installed() {
+		return NewValidationError("direnv is not installed")
+	}
+
+	profiles, err := direnvProfiles(profilesDir, opts)
+	if err != nil {
+		return err
+	}
+
+	if ui.Structured() {
+		return ui.Render(direnvStates(profilesDir, profiles))
+	}
+
+	table := ui.NewTable("PROFILE", "STATE", "FIX").Truncate(1, 50)
+	for _, profileName := range profiles {
+		state, err := direnv.Status(profilePath(profilesDir, profileName))
+		switch {
+		case err != nil:
+			table.AddRow(profileName, fmt.Sprintf("%s✗ %v%s", ui.ColorRed, err, ui.ColorReset))
+		case state == direnv.Allowed:
+			table.AddRow(profileName, fmt.Sprintf("%s✓ %s%s", ui.ColorGreen, state, ui.ColorReset))
+		default:
+			table.AddRow(profileName, fmt.Sprintf("%s⚠ %s%s", ui.ColorYellow, state, ui.ColorReset), "profile direnv allow "+profileName)
+		}
+	}
+	table.Render(os.Stdout)
+	return nil
+}
+
+// DirenvStates returns the allow state of every profile for structured output
+func DirenvStates(profilesDir string) ([]direnv.ProfileState, error) {
+	profiles, err := ListProfileNames(profilesDir)
+	if err != nil {
+		return nil, err
+	}
+	return direnvStates(profilesDir, profiles), nil
+}
+
+func direnvStates(profilesDir string, profiles []string) []direnv.ProfileState {
+	states := []direnv.ProfileState{}
+	for _, profileName := range profiles {
+		state, err := direnv.Status(profilePath(profilesDir, profileName))
+		entry := direnv.ProfileState{Name: profileName, State: state.String()}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		states = append(states, entry)
+	}
+	return states
+}
+
+// AllowDirenvProfiles runs 'direnv allow' for one profile, or with --all
+// (or --tag) for every profile that is not currently allowed. Blocked profiles are
+// only allowed by name so a denied .envrc is never re-enabled in bulk.
+func AllowDirenvProfiles(profilesDir string, opts DirenvOptions) error {
+	if !direnv.Installed() {
+		return NewValidationError("direnv is not installed")
+	}
+
+	if !opts.All && len(opts.Tags) == 0 {
+		profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile to allow:")
+		if err != nil {
+			return err
+		}
+		if err := direnv.Allow(profileDir); err != nil {
+			return err
+		}
+		ui.PrintSuccess(fmt.Sprintf("direnv allowed for profile: %s", profileName))
+		return changesApplied()
+	}
+
+	profiles, err := taggedProfiles(profilesDir, opts.Tags)
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	for _, profileName := range profiles {
+		state, err := direnv.Status(profilePath(profilesDir, profileName))
+		if err != nil || state != direnv.Allowed {
+			pending = append(pending, profileName)
+		}
+	}
+	if len(pending) == 0 {
+		ui.PrintInfo("All profiles are already allowed")
+		return nil
+	}
+
+	progress := ui.NewProgress("Allowing", len(pending))
+	progress.RunEach(pending, parallelJobs(opts.Jobs), func(profileName string) (string, error) {
+		profileDir := profilePath(profilesDir, profileName)
+		if state, _ := direnv.Status(profileDir); state == direnv.Blocked {
+			return "", ui.Skipped("blocked; allow it by name after reviewing .envrc")
+		}
+		return "", direnv.Allow(profileDir)
+	})
+
+	failed := progress.Finish()
+	allowed := 0
+	for _, result := range progress.Results() {
+		if result.Status == ui.StatusOK {
+			allowed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("direnv allow failed for %d of %d profile(s)", failed, len(pending))
+	}
+	if allowed == 0 {
+		return nil
+	}
+	return changesApplied()
+}
+
+// direnvProfiles returns the named profile, or every profile (with the tags
+// in opts.Tags) when none was given
+func direnvProfiles(profilesDir string, opts DirenvOptions) ([]string, error) {
+	if opts.ProfileName != "" && !opts.All && len(opts.Tags) == 0 {
+		profileName, _, err := resolveProfile(profilesDir, opts.ProfileName, "")
+		if err != nil {
+			return nil, err
+		}
+		return []string{profileName}, nil
+	}
+	return taggedProfiles(profilesDir, opts.Tags)
+}
+
+// printDirenvState prints a profile's direnv allow state for list, with the
+// command that fixes it. Nothing is printed when direnv is not installed.
+func printDirenvState(profileDir, profileName string) {
+	if !direnv.Installed() {
+		return
+	}
+	state, err := direnv.Status(profileDir)
+	if err != nil || state == direnv.Unknown {
+		return
+	}
+
+	if state == direnv.Allowed {
+		fmt.Printf("  %s✓ direnv allowed%s\n", ui.ColorGreen, ui.ColorReset)
+		return
+	}
+	fmt.Printf("  %s⚠ direnv %s%s (run: profile direnv allow %s)\n", ui.ColorYellow, state, ui.ColorReset, profileName)
+}
+
+// direnvIssue describes a profile whose .envrc direnv will not load, or
+// returns "" when it is allowed or direnv is not installed
+func direnvIssue(profileDir, profileName string) string {
+	if !direnv.Installed() {
+		return ""
+	}
+	state, err := direnv.Status(profileDir)
+	if err != nil || state == direnv.Allowed || state == direnv.Unknown {
+		return ""
+	}
+	return fmt.Sprintf("direnv: .envrc is %s (run 'profile direnv allow %s')", state, profileName)
+}
@@ -0,0 +1,257 @@
+package commands
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+	"github.com/mindmorass/shell-profile-manager/internal/shell"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// linkStateVar holds what the shell hook loaded for a linked directory, so
+// it can be restored when the shell leaves it
+const linkStateVar = "SPM_LINK_STATE"
+
+type LinkOptions struct {
+	// Dir is the project directory; ProfileName the profile it maps to
+	Dir         string
+	ProfileName string
+}
+
+// DirLink is a project directory linked to a profile
+type DirLink struct {
+	Dir     string `json:"dir" yaml:"dir"`
+	Profile string `json:"profile" yaml:"profile"`
+	// Problem is why the link does not work ("" when it does)
+	Problem string `json:"problem,omitempty" yaml:"problem,omitempty"`
+}
+
+// linkState is what the shell hook loaded for a linked directory: the
+// profile and directory, and the values the variables it set had before
+// (nil: unset)
+type linkState struct {
+	Profile  string             `json:"profile"`
+	Dir      string             `json:"dir"`
+	Previous map[string]*string `json:"previous"`
+}
+
+// LinkDir links a project directory outside the profile roots to a
+// profile, so the shell hook loads the profile's environment in it and
+// which reports the profile there
+func LinkDir(profilesDir string, opts LinkOptions) error {
+	if opts.Dir == "" || opts.ProfileName == "" {
+		return NewValidationError("a directory and a profile are required (profile link <dir> <profile>)")
+	}
+	dir, err := filepath.Abs(config.ExpandPath(opts.Dir))
+	if err != nil {
+		return NewValidationError("invalid directory %s: %v", opts.Dir, err)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return NewValidationError("not a directory: %s", dir)
+	}
+	if name, _, ok := profileContainingDir(profilesDir, dir); ok {
+		return NewValidationError("%s is in profile %s already", dir, name)
+	}
+	profileName, _, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+
+	links, err := config.LoadLinks()
+	if err != nil {
+		return err
+	}
+	if links[dir] == profileName {
+		ui.PrintInfo(fmt.Sprintf("%s is linked to %s already", dir, profileName))
+		return nil
+	}
+	links[dir] = profileName
+	if err := config.SaveLinks(links); err != nil {
+		return err
+	}
+	ui.PrintSuccess(fmt.Sprintf("Linked %s to %s", dir, profileName))
+	fmt.Println("  The shell hook loads the profile when you change to the directory")
+	fmt.Println("  (see 'profile hook --help' to install it)")
+	return changesApplied()
+}
+
+// UnlinkDir removes the link of a project directory
+func UnlinkDir(dir string) error {
+	if dir == "" {
+		return NewValidationError("a directory is required (profile unlink <dir>)")
+	}
+	abs, err := filepath.Abs(config.ExpandPath(dir))
+	if err != nil {
+		return NewValidationError("invalid directory %s: %v", dir, err)
+	}
+	links, err := config.LoadLinks()
+	if err != nil {
+		return err
+	}
+	if _, ok := links[abs]; !ok {
+		return NewValidationError("%s is not linked to a profile", abs)
+	}
+	delete(links, abs)
+	if err := config.SaveLinks(links); err != nil {
+		return err
+	}
+	ui.PrintSuccess(fmt.Sprintf("Unlinked %s", abs))
+	return changesApplied()
+}
+
+// ListDirLinks prints the linked directories and whether their links work
+func ListDirLinks(profilesDir string) error {
+	links, err := config.LoadLinks()
+	if err != nil {
+		return err
+	}
+	result := []DirLink{}
+	for _, dir := range sortedLinkDirs(links) {
+		link := DirLink{Dir: dir, Profile: links[dir]}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			link.Problem = "directory does not exist"
+		} else if !isProfileDir(profilePath(profilesDir, link.Profile)) {
+			link.Problem = "profile does not exist"
+		}
+		result = append(result, link)
+	}
+
+	if ui.Structured() {
+		return ui.Render(result)
+	}
+	if len(result) == 0 {
+		ui.PrintInfo("No directories are linked to profiles")
+		fmt.Println("Link one with: profile link <dir> <profile>")
+		return nil
+	}
+	table := ui.NewTable("DIRECTORY", "PROFILE", "STATUS").Truncate(0, 60)
+	for _, link := range result {
+		table.AddRow(link.Dir, link.Profile, valueOr(link.Problem, "ok"))
+	}
+	table.Render(os.Stdout)
+	return nil
+}
+
+// linkedProfile returns the profile the nearest linked directory holding
+// dir maps to, and that directory
+func linkedProfile(dir string) (string, string, bool) {
+	links, err := config.LoadLinks()
+	if err != nil || len(links) == 0 {
+		return "", "", false
+	}
+	for dir = filepath.Clean(dir); ; dir = filepath.Dir(dir) {
+		if name, ok := links[dir]; ok {
+			return name, dir, true
+		}
+		if filepath.Dir(dir) == dir {
+			return "", "", false
+		}
+	}
+}
+
+// PrintLinkEnv prints, in the syntax of a shell, what changes when the
+// shell is in dir: the environment of the profile linked to it is loaded,
+// and what was loaded for a directory the shell left is restored. Nothing
+// is printed while the shell stays in the same linked directory. The shell
+// hook evaluates it when the directory changes.
+func PrintLinkEnv(profilesDir, shellName, dir string) error {
+	if shellName != shell.Fish {
+		shellName = "sh"
+	}
+	encoded := os.Getenv(linkStateVar)
+	var loaded *linkState
+	if encoded != "" {
+		loaded = decodeLinkState(encoded)
+	}
+
+	profileName, linkDir, linked := linkedProfile(dir)
+	// Profiles direnv loads take precedence over links in them
+	if _, _, ok := profileContainingDir(profilesDir, dir); ok {
+		linked = false
+	}
+	if linked && loaded != nil && loaded.Profile == profileName && loaded.Dir == linkDir {
+		return nil
+	}
+	if !linked && encoded == "" {
+		return nil
+	}
+
+	changes := map[string]*string{}
+	if encoded != "" {
+		changes[linkStateVar] = nil
+	}
+	if loaded != nil {
+		// The environment of the profile is evaluated without what was
+		// loaded for the directory left
+		for name, value := range loaded.Previous {
+			changes[name] = value
+			if value == nil {
+				os.Unsetenv(name)
+			} else {
+				os.Setenv(name, *value)
+			}
+		}
+	}
+
+	if linked {
+		profileDir := profilePath(profilesDir, profileName)
+		vars, err := ProfileEnv(profileDir, profileName)
+		if err != nil {
+			// The shell keeps working; the hook shows why nothing loaded
+			fmt.Fprintf(os.Stderr, "profile: %s is linked to %s: %v\n", linkDir, profileName, err)
+		} else {
+			state := linkState{Profile: profileName, Dir: linkDir, Previous: map[string]*string{}}
+			for name, value := range vars {
+				if previous, ok := os.LookupEnv(name); ok {
+					state.Previous[name] = &previous
+				} else {
+					state.Previous[name] = nil
+				}
+				changes[name] = value
+			}
+			encodedState, err := encodeLinkState(state)
+			if err != nil {
+				return err
+			}
+			changes[linkStateVar] = &encodedState
+		}
+	}
+	fmt.Print(shellExports(shellName, changes))
+	return nil
+}
+
+func encodeLinkState(state linkState) (string, error) {
+	content, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(content), nil
+}
+
+// decodeLinkState decodes the state the hook keeps; a state that cannot be
+// read is dropped
+func decodeLinkState(encoded string) *linkState {
+	content, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil
+	}
+	var state linkState
+	if json.Unmarshal(content, &state) != nil {
+		return nil
+	}
+	return &state
+}
+
+func sortedLinkDirs(links map[string]string) []string {
+	dirs := make([]string, 0, len(links))
+	for dir := range links {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
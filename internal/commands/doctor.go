@@ -0,0 +1,388 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/direnv"
+	"github.com/mindmorass/shell-profile-manager/internal/features"
+	"github.com/mindmorass/shell-profile-manager/internal/perms"
+	"github.com/mindmorass/shell-profile-manager/internal/shell"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+	"github.com/mindmorass/shell-profile-manager/internal/wsl"
+)
+
+type DoctorOptions struct {
+	ProfileName string
+	// Fix restricts the permissions of files holding credentials before
+	// checking
+	Fix bool
+}
+
+// DoctorCheck is a check of the environment
+type DoctorCheck struct {
+	// Status is "ok", "warning", or "error"
+	Status  string   `json:"status" yaml:"status"`
+	Message string   `json:"message" yaml:"message"`
+	Details []string `json:"details,omitempty" yaml:"details,omitempty"`
+}
+
+// DoctorProfile lists the problems found in a profile
+type DoctorProfile struct {
+	Name   string   `json:"name" yaml:"name"`
+	Issues []string `json:"issues" yaml:"issues"`
+	// Fixed lists the files whose permissions --fix restricted
+	Fixed []string `json:"fixed,omitempty" yaml:"fixed,omitempty"`
+}
+
+// DoctorReport is everything doctor checked, as shown with --output json|yaml
+type DoctorReport struct {
+	Checks   []DoctorCheck   `json:"checks" yaml:"checks"`
+	Profiles []DoctorProfile `json:"profiles" yaml:"profiles"`
+	Problems int             `json:"problems" yaml:"problems"`
+}
+
+// RunDoctor checks the environment and profiles for common problems
+func RunDoctor(profilesDir string, opts DoctorOptions) error {
+	report := DoctorReport{Profiles: []DoctorProfile{}}
+
+	// Check direnv
+	if !direnv.Installed() {
+		report.Checks = append(report.Checks, DoctorCheck{Status: "warning", Message: "direnv is not installed"})
+	} else {
+		report.Checks = append(report.Checks, DoctorCheck{Status: "ok", Message: "direnv is installed"})
+		report.Checks = append(report.Checks, checkDirenvHook()...)
+	}
+	report.Checks = append(report.Checks, checkWSL(profilesDir)...)
+	report.Checks = append(report.Checks, checkRoots()...)
+
+	// Check feature definitions
+	feats, err := features.Load()
+	if err != nil {
+		report.Checks = append(report.Checks, DoctorCheck{Status: "error", Message: fmt.Sprintf("Feature definitions are invalid: %v", err)})
+		if err := printDoctorReport(report); err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to load feature definitions: %w", err)
+	}
+	report.Checks = append(report.Checks, DoctorCheck{Status: "ok", Message: fmt.Sprintf("%d feature(s) loaded", len(feats))})
+	for _, check := range report.Checks {
+		if check.Status != "ok" {
+			report.Problems++
+		}
+	}
+
+	var profiles []string
+	if opts.ProfileName != "" {
+		profileDir := profilePath(profilesDir, opts.ProfileName)
+		if _, err := os.Stat(filepath.Join(profileDir, ".envrc")); os.IsNotExist(err) {
+			return NewValidationError("profile '%s' does not exist at: %s", opts.ProfileName, profileDir)
+		}
+		profiles = []string{qualifiedName(profilesDir, opts.ProfileName)}
+	} else {
+		profiles, err = ListProfileNames(profilesDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, profileName := range profiles {
+		profileDir := profilePath(profilesDir, profileName)
+		profileFeats, err := LoadProfileFeatures(profileDir)
+		if err != nil {
+			return err
+		}
+		var fixed []string
+		if opts.Fix {
+			if fixed, err = fixProfilePermissions(profileDir); err != nil {
+				return newIOError(err, "failed to fix permissions of profile %s", profileName)
+			}
+		}
+		issues, err := profileIssues(profileDir, profileFeats)
+		if err != nil {
+			return newIOError(err, "failed to check profile %s", profileName)
+		}
+		changed, err := checksumIssues(profileDir)
+		if err != nil {
+			return newIOError(err, "failed to verify profile %s", profileName)
+		}
+		issues = append(issues, changed...)
+		if issue := direnvIssue(profileDir, profileName); issue != "" {
+			issues = append(issues, issue)
+		}
+		if issue := wslSSHIssue(profilesDir, profileDir); issue != "" {
+			issues = append(issues, issue)
+		}
+
+		report.Profiles = append(report.Profiles, DoctorProfile{Name: profileName, Issues: append([]string{}, issues...), Fixed: fixed})
+		report.Problems += len(issues)
+	}
+
+	if err := printDoctorReport(report); err != nil {
+		return err
+	}
+	if report.Problems > 0 {
+		return fmt.Errorf("%d problem(s) found", report.Problems)
+	}
+	return nil
+}
+
+// fixProfilePermissions restricts the files holding credentials in a
+// profile for doctor --fix and returns their paths
+func fixProfilePermissions(profileDir string) ([]string, error) {
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	violations, err := updatePermissions(profileDir, false)
+	if err != nil || len(violations) == 0 {
+		return nil, err
+	}
+	paths := violationPaths(violations)
+	recordHistory(profileDir, "doctor --fix", []string{fmt.Sprintf("Restricted permissions: %s", strings.Join(paths, ", "))})
+	return paths, nil
+}
+
+// printDoctorReport prints the report as text or, with --output, as a
+// structured document
+func printDoctorReport(report DoctorReport) error {
+	if ui.Structured() {
+		return ui.Render(report)
+	}
+
+	fmt.Printf("%s=== Profile Doctor ===%s\n", ui.ColorBlue, ui.ColorReset)
+	fmt.Println()
+
+	for _, check := range report.Checks {
+		switch check.Status {
+		case "ok":
+			fmt.Printf("%s✓ %s%s\n", ui.ColorGreen, check.Message, ui.ColorReset)
+		case "warning":
+			fmt.Printf("%s⚠ %s%s\n", ui.ColorYellow, check.Message, ui.ColorReset)
+		default:
+			fmt.Printf("%s✗ %s%s\n", ui.ColorRed, check.Message, ui.ColorReset)
+		}
+		for _, detail := range check.Details {
+			fmt.Printf("    %s\n", detail)
+		}
+	}
+	fmt.Println()
+
+	profileProblems, changedOutside, tooOpen := 0, false, false
+	for _, p := range report.Profiles {
+		if len(p.Issues) == 0 {
+			fmt.Printf("%s✓ %s%s\n", ui.ColorGreen, p.Name, ui.ColorReset)
+			if len(p.Fixed) > 0 {
+				fmt.Printf("    Restricted permissions: %s\n", strings.Join(p.Fixed, ", "))
+			}
+			continue
+		}
+
+		fmt.Printf("%s⚠ %s%s\n", ui.ColorYellow, p.Name, ui.ColorReset)
+		if len(p.Fixed) > 0 {
+			fmt.Printf("    Restricted permissions: %s\n", strings.Join(p.Fixed, ", "))
+		}
+		for _, issue := range p.Issues {
+			fmt.Printf("    - %s\n", issue)
+			changedOutside = changedOutside || strings.Contains(issue, "outside spm")
+			tooOpen = tooOpen || strings.Contains(issue, " has permissions ")
+		}
+		profileProblems += len(p.Issues)
+	}
+
+	fmt.Println()
+	if report.Problems > 0 {
+		ui.PrintWarning(fmt.Sprintf("%d problem(s) found", report.Problems))
+		if profileProblems > 0 {
+			fmt.Println("  Run 'profile update <name>' to add missing directories, variables, and patterns and fix permissions")
+		}
+		if tooOpen {
+			fmt.Println("  Run 'profile doctor --fix' to only fix the permissions of keys and credentials")
+		}
+		if changedOutside {
+			fmt.Println("  Run 'profile verify <name>' to review files changed outside spm")
+		}
+		return nil
+	}
+
+	ui.PrintSuccess("No problems found")
+	return nil
+}
+
+// checkDirenvHook checks that direnv is hooked into a fish login shell.
+// bash and zsh are often hooked in through frameworks (oh-my-zsh plugins,
+// /etc/bash.bashrc) that cannot be checked reliably, so they are skipped.
+func checkDirenvHook() []DoctorCheck {
+	if shell.Detect() != shell.Fish {
+		return nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+
+	if shell.HasDirenvHook(shell.Fish, homeDir) {
+		return []DoctorCheck{{Status: "ok", Message: "direnv is hooked into fish"}}
+	}
+	return []DoctorCheck{{
+		Status:  "warning",
+		Message: "direnv is not hooked into fish",
+		Details: []string{fmt.Sprintf("Add to %s: %s", shell.RCFile(shell.Fish, homeDir), shell.DirenvHook(shell.Fish))},
+	}}
+}
+
+// checkWSL reports when WSL keeps profiles on a Windows drive that cannot
+// store Linux permissions
+func checkWSL(profilesDir string) []DoctorCheck {
+	if !wsl.Detected() {
+		return nil
+	}
+	checks := []DoctorCheck{{Status: "ok", Message: "Running in WSL"}}
+
+	mount, err := wsl.MountOf(profilesDir)
+	if err != nil || !mount.DrvFs() {
+		return checks
+	}
+	if mount.Metadata() {
+		return append(checks, DoctorCheck{Status: "ok", Message: fmt.Sprintf("Profiles are on a Windows drive (%s) mounted with metadata", mount.Point)})
+	}
+	return append(checks, DoctorCheck{
+		Status:  "warning",
+		Message: fmt.Sprintf("Profiles are on a Windows drive (%s) mounted without metadata", mount.Point),
+		Details: []string{
+			"File permissions cannot be set there, so ssh rejects keys and config as too open.",
+			"Move the profiles into the Linux filesystem, or add to /etc/wsl.conf and run 'wsl --shutdown':",
+			"    [automount]",
+			`    options = "metadata"`,
+		},
+	})
+}
+
+// wslSSHIssue describes a profile .ssh that resolves (e.g. through a symlink
+// to the Windows user's .ssh) to a Windows drive without metadata. Profiles
+// that are on such a drive themselves are reported once by checkWSL.
+func wslSSHIssue(profilesDir, profileDir string) string {
+	if !wsl.Detected() {
+		return ""
+	}
+	mount, err := wsl.MountOf(filepath.Join(profileDir, ".ssh"))
+	if err != nil || !mount.DrvFs() || mount.Metadata() {
+		return ""
+	}
+	if profilesMount, err := wsl.MountOf(profilesDir); err == nil && profilesMount.Point == mount.Point {
+		return ""
+	}
+	return fmt.Sprintf(".ssh is on a Windows drive (%s) without metadata, so ssh rejects its keys; keep keys in the Linux filesystem", mount.Point)
+}
+
+// profileIssues compares a profile against the feature definitions without
+// modifying it and describes everything that update would change
+func profileIssues(profileDir string, feats []features.Feature) ([]string, error) {
+	var issues []string
+
+	pending, err := runMigrations(profileDir, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) > 0 {
+		issues = append(issues, fmt.Sprintf("Pending migrations: %s", strings.Join(pending, "; ")))
+	}
+
+	missingDirs, err := updateDirectories(profileDir, feats, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(missingDirs) > 0 {
+		issues = append(issues, fmt.Sprintf("Missing directories: %s", strings.Join(missingDirs, ", ")))
+	}
+
+	// Paths whose permissions differ from those their feature sets
+	reported := map[string]bool{}
+	for _, feature := range feats {
+		for _, dir := range feature.Dirs {
+			if dir.Mode == "" {
+				continue
+			}
+			info, err := os.Stat(filepath.Join(profileDir, dir.Path))
+			if err != nil {
+				continue
+			}
+			if info.Mode().Perm() != dir.FileMode() {
+				reported[dir.Path] = true
+				issues = append(issues, fmt.Sprintf("%s has permissions %o (expected %o)", dir.Path, info.Mode().Perm(), dir.FileMode()))
+			}
+		}
+		for _, file := range feature.Files {
+			if file.Mode == "" || feature.Linked(file) {
+				continue
+			}
+			info, err := os.Stat(filepath.Join(profileDir, file.Path))
+			if err != nil {
+				continue
+			}
+			if info.Mode().Perm() != file.FileMode() {
+				reported[file.Path] = true
+				issues = append(issues, fmt.Sprintf("%s has permissions %o (expected %o)", file.Path, info.Mode().Perm(), file.FileMode()))
+			}
+		}
+	}
+
+	violations, err := perms.Check(profileDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range violations {
+		if !reported[v.Path] {
+			issues = append(issues, v.String())
+		}
+	}
+
+	doc, err := loadEnvrc(profileDir)
+	if err != nil {
+		return nil, err
+	}
+	deprecated, err := migrateDeprecated(doc, feats, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(deprecated) > 0 {
+		issues = append(issues, fmt.Sprintf("Deprecated in .envrc: %s", strings.Join(deprecated, ", ")))
+	}
+
+	missingFiles, err := updateFiles(profileDir, feats, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(missingFiles) > 0 {
+		issues = append(issues, fmt.Sprintf("Missing files: %s", strings.Join(missingFiles, ", ")))
+	}
+
+	missingLinks, brokenLinks, err := updateLinks(profileDir, feats, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(missingLinks) > 0 {
+		issues = append(issues, fmt.Sprintf("Missing links: %s", strings.Join(missingLinks, ", ")))
+	}
+	if len(brokenLinks) > 0 {
+		issues = append(issues, fmt.Sprintf("Broken links: %s", strings.Join(brokenLinks, ", ")))
+	}
+
+	// The document is not saved; what would be added is what is missing
+	if missingVars := updateEnvrc(doc, feats); len(missingVars) > 0 {
+		issues = append(issues, fmt.Sprintf("Missing in .envrc: %s", strings.Join(missingVars, ", ")))
+	}
+
+	missingPatterns, err := updateGitignore(profileDir, feats, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(missingPatterns) > 0 {
+		issues = append(issues, fmt.Sprintf("Missing in .gitignore: %s", strings.Join(missingPatterns, ", ")))
+	}
+
+	return issues, nil
+}
@@ -0,0 +1,370 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+	"github.com/mindmorass/shell-profile-manager/internal/redact"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// dotenvFile is loaded by the profile's .envrc with dotenv_if_exists, after
+// the secrets block, so a value in it overrides a secret of the same name
+const dotenvFile = ".env"
+
+var (
+	// dotenvLine matches a NAME=value line of a .env file
+	dotenvLine = regexp.MustCompile(`^\s*(?:export\s+)?([A-Za-z_][A-Za-z0-9_]*)\s*=(.*)$`)
+	// dotenvPlain matches values written without quotes; dotenv expands
+	// variables in unquoted values, so they cannot contain $
+	dotenvPlain = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]*$`)
+)
+
+type DotenvOptions struct {
+	ProfileName string
+	// Vars are NAME=value assignments to set, or the names to unset
+	Vars []string
+	// SecretRef loads the variable from the profile's secrets provider when
+	// the profile loads, instead of keeping its value in .env
+	SecretRef string
+}
+
+// DotenvVar is a variable set by the profile's .env, or loaded from its
+// secrets provider
+type DotenvVar struct {
+	Name string `json:"name" yaml:"name"`
+	// Value is the value in .env, masked for secrets
+	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+	// Secret is the reference the value is loaded from
+	Secret string `json:"secret,omitempty" yaml:"secret,omitempty"`
+	// Source is .env or the secrets provider
+	Source string `json:"source" yaml:"source"`
+}
+
+// SetDotenv sets variables in the profile's .env. With a secret reference
+// the variable is loaded from the profile's secrets provider when the
+// profile loads instead, so its value is never written to the profile.
+func SetDotenv(profilesDir string, opts DotenvOptions) error {
+	if len(opts.Vars) == 0 {
+		return NewValidationError("no variables given (NAME=value, or NAME --secret <ref>)")
+	}
+	if opts.SecretRef != "" {
+		if len(opts.Vars) != 1 || strings.Contains(opts.Vars[0], "=") {
+			return NewValidationError("--secret takes a single variable name without a value")
+		}
+		return setDotenvSecret(profilesDir, opts)
+	}
+
+	values := map[string]string{}
+	var names []string
+	for _, assignment := range opts.Vars {
+		name, value, ok := strings.Cut(assignment, "=")
+		if !ok {
+			return NewValidationError("%s has no value (use NAME=value, or NAME --secret <ref>)", assignment)
+		}
+		if !envNamePattern.MatchString(name) {
+			return NewValidationError("invalid variable name: %s", name)
+		}
+		if _, seen := values[name]; !seen {
+			names = append(names, name)
+		}
+		values[name] = value
+	}
+
+	_, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := checkDotenvWritable(profileDir); err != nil {
+		return err
+	}
+
+	path := filepath.Join(profileDir, dotenvFile)
+	content, err := readOptionalFile(path)
+	if err != nil {
+		return newIOError(err, "failed to read %s", path)
+	}
+	lines := dotenvLines(content)
+	for _, name := range names {
+		line := name + "=" + dotenvValue(values[name])
+		if i := dotenvIndex(lines, name); i != -1 {
+			lines[i] = line
+		} else {
+			lines = append(lines, line)
+		}
+	}
+	if err := writeDotenv(path, lines); err != nil {
+		return err
+	}
+
+	// A secret of the same name would be overridden by .env
+	removed, err := removeSecretVars(profileDir, names)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		ui.PrintSuccess(fmt.Sprintf("Set %s in %s", name, path))
+	}
+	for _, name := range removed {
+		ui.PrintInfo(fmt.Sprintf("%s is no longer loaded from the secrets provider", name))
+	}
+	return changesApplied()
+}
+
+// setDotenvSecret loads a variable from the secrets provider and removes
+// its value from .env, which would otherwise override it
+func setDotenvSecret(profilesDir string, opts DotenvOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	name := opts.Vars[0]
+	err = SetSecret(profilesDir, SecretsOptions{ProfileName: profileName, VarName: name, Ref: opts.SecretRef})
+	var changed *ChangesApplied
+	if err != nil && !errors.As(err, &changed) {
+		return err
+	}
+
+	removed, err := removeDotenvVars(profileDir, []string{name})
+	if err != nil {
+		return err
+	}
+	if len(removed) > 0 {
+		ui.PrintInfo(fmt.Sprintf("Removed the value of %s from %s", name, dotenvFile))
+	}
+	return changesApplied()
+}
+
+// UnsetDotenv removes variables from the profile's .env, and stops loading
+// them from its secrets provider
+func UnsetDotenv(profilesDir string, opts DotenvOptions) error {
+	if len(opts.Vars) == 0 {
+		return NewValidationError("no variable names given")
+	}
+	for _, name := range opts.Vars {
+		if !envNamePattern.MatchString(name) {
+			return NewValidationError("invalid variable name: %s", name)
+		}
+	}
+
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	fromDotenv, err := removeDotenvVars(profileDir, opts.Vars)
+	if err != nil {
+		return err
+	}
+	fromSecrets, err := removeSecretVars(profileDir, opts.Vars)
+	if err != nil {
+		return err
+	}
+
+	if len(fromDotenv) == 0 && len(fromSecrets) == 0 {
+		return NewValidationError("%s not set in profile '%s'", strings.Join(opts.Vars, ", "), profileName)
+	}
+	for _, name := range opts.Vars {
+		if slices.Contains(fromDotenv, name) || slices.Contains(fromSecrets, name) {
+			ui.PrintSuccess(fmt.Sprintf("Removed %s", name))
+		} else {
+			ui.PrintWarning(fmt.Sprintf("%s is not set in profile '%s'", name, profileName))
+		}
+	}
+	return changesApplied()
+}
+
+// ListDotenv shows the variables of the profile's .env, with secret values
+// masked, and those loaded from its secrets provider
+func ListDotenv(profilesDir string, opts DotenvOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return err
+	}
+
+	vars := []DotenvVar{}
+	content, err := readOptionalFile(filepath.Join(profileDir, dotenvFile))
+	if err != nil {
+		return newIOError(err, "failed to read %s", dotenvFile)
+	}
+	for _, line := range dotenvLines(content) {
+		if match := dotenvLine.FindStringSubmatch(line); match != nil {
+			value := redact.Value(match[1], dotenvUnquote(match[2]))
+			vars = append(vars, DotenvVar{Name: match[1], Value: value, Source: dotenvFile})
+		}
+	}
+	if m.Secrets != nil {
+		for _, v := range m.Secrets.Vars {
+			vars = append(vars, DotenvVar{Name: v.Name, Secret: v.Ref, Source: m.Secrets.Provider})
+		}
+	}
+
+	if ui.Structured() {
+		return ui.Render(vars)
+	}
+	if len(vars) == 0 {
+		ui.PrintInfo(fmt.Sprintf("No variables set in profile: %s", profileName))
+		fmt.Println("Set one with: profile dotenv set <profile> NAME=value")
+		return nil
+	}
+	table := ui.NewTable("VARIABLE", "VALUE", "SOURCE").Truncate(1, 60)
+	for _, v := range vars {
+		if v.Secret != "" {
+			table.AddRow(v.Name, v.Secret, v.Source)
+		} else {
+			table.AddRow(v.Name, v.Value, v.Source)
+		}
+	}
+	table.Render(os.Stdout)
+	return nil
+}
+
+// checkDotenvWritable refuses to write .env when it is kept encrypted, since
+// the plaintext written would not be loaded
+func checkDotenvWritable(profileDir string) error {
+	if _, err := os.Stat(filepath.Join(profileDir, dotenvFile+encryptedSuffix)); err == nil {
+		return NewValidationError("%s is encrypted (profile encrypt); edit it with: sops %s", dotenvFile, filepath.Join(profileDir, dotenvFile+encryptedSuffix))
+	}
+	return nil
+}
+
+// removeDotenvVars removes variables from .env and returns the names removed
+func removeDotenvVars(profileDir string, names []string) ([]string, error) {
+	path := filepath.Join(profileDir, dotenvFile)
+	content, err := readOptionalFile(path)
+	if err != nil {
+		return nil, newIOError(err, "failed to read %s", path)
+	}
+	var removed []string
+	var kept []string
+	for _, line := range dotenvLines(content) {
+		if match := dotenvLine.FindStringSubmatch(line); match != nil && slices.Contains(names, match[1]) {
+			removed = append(removed, match[1])
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	if err := checkDotenvWritable(profileDir); err != nil {
+		return nil, err
+	}
+	return removed, writeDotenv(path, kept)
+}
+
+// removeSecretVars stops loading variables from the secrets provider and
+// returns the names removed
+func removeSecretVars(profileDir string, names []string) ([]string, error) {
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return nil, err
+	}
+	if m.Secrets == nil {
+		return nil, nil
+	}
+	var removed []string
+	var kept []manifest.SecretVar
+	for _, v := range m.Secrets.Vars {
+		if slices.Contains(names, v.Name) {
+			removed = append(removed, v.Name)
+			continue
+		}
+		kept = append(kept, v)
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	m.Secrets.Vars = kept
+	return removed, saveSecrets(profileDir, m)
+}
+
+// dotenvLines splits .env content into lines, without the final newline
+func dotenvLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// dotenvIndex returns the line setting name, or -1
+func dotenvIndex(lines []string, name string) int {
+	for i, line := range lines {
+		if match := dotenvLine.FindStringSubmatch(line); match != nil && match[1] == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// writeDotenv writes .env, kept at 0600 since it holds secrets
+func writeDotenv(path string, lines []string) error {
+	content := ""
+	if len(lines) > 0 {
+		content = strings.Join(lines, "\n") + "\n"
+	}
+	if err := writeProfileFile(path, []byte(content), 0600); err != nil {
+		return newIOError(err, "failed to write %s", path)
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(path, 0600); err != nil {
+		return newIOError(err, "failed to set permissions on %s", path)
+	}
+	return nil
+}
+
+// dotenvValue quotes a value for .env: unquoted when it is plain, single
+// quotes (taken literally) when possible, otherwise double quotes with $,
+// quotes, backslashes, and newlines escaped
+func dotenvValue(value string) string {
+	if dotenvPlain.MatchString(value) {
+		return value
+	}
+	if !strings.ContainsAny(value, "'\n") {
+		return "'" + value + "'"
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`).Replace(value) + `"`
+}
+
+// dotenvUnquote returns the value of a .env assignment for display
+func dotenvUnquote(value string) string {
+	value = strings.TrimSpace(value)
+	switch {
+	case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+		return value[1 : len(value)-1]
+	case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+		return strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\$`, `$`, `\n`, "\n").Replace(value[1 : len(value)-1])
+	}
+	// Unquoted values end at a comment
+	if i := strings.Index(value, " #"); i != -1 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value
+}
@@ -20,38 +20,19 @@ type DotfilesOptions struct {
 func ListDotfiles(profilesDir string, opts DotfilesOptions) error {
 	// If no profile name provided, show interactive selection
 	if opts.ProfileName == "" {
-		entries, err := os.ReadDir(profilesDir)
-		if err != nil {
-			return fmt.Errorf("failed to read profiles directory: %w", err)
-		}
-
-		var profiles []string
-		for _, entry := range entries {
-			if entry.IsDir() && entry.Name() != ".git" {
-				profilePath := filepath.Join(profilesDir, entry.Name())
-				envrcPath := filepath.Join(profilePath, ".envrc")
-				if _, err := os.Stat(envrcPath); err == nil {
-					profiles = append(profiles, entry.Name())
-				}
-			}
-		}
-
-		if len(profiles) == 0 {
-			return fmt.Errorf("no profiles found")
-		}
-
-		selected, err := ui.SelectProfile(profiles, "Select profile to list dotfiles:")
+		selected, err := PromptForProfile(profilesDir, "Select profile to list dotfiles:")
 		if err != nil {
 			return err
 		}
 		opts.ProfileName = selected
 	}
 
-	profileDir := filepath.Join(profilesDir, opts.ProfileName)
+	opts.ProfileName = qualifiedName(profilesDir, opts.ProfileName)
+	profileDir := profilePath(profilesDir, opts.ProfileName)
 
 	// Check if profile exists
 	if _, err := os.Stat(profileDir); os.IsNotExist(err) {
-		return fmt.Errorf("profile '%s' does not exist at: %s", opts.ProfileName, profileDir)
+		return NewValidationError("profile '%s' does not exist at: %s", opts.ProfileName, profileDir)
 	}
 
 	// Find all dotfiles
@@ -103,45 +84,26 @@ func ListDotfiles(profilesDir string, opts DotfilesOptions) error {
 func EditDotfile(profilesDir string, opts DotfilesOptions) error {
 	// If no profile name provided, show interactive selection
 	if opts.ProfileName == "" {
-		entries, err := os.ReadDir(profilesDir)
-		if err != nil {
-			return fmt.Errorf("failed to read profiles directory: %w", err)
-		}
-
-		var profiles []string
-		for _, entry := range entries {
-			if entry.IsDir() && entry.Name() != ".git" {
-				profilePath := filepath.Join(profilesDir, entry.Name())
-				envrcPath := filepath.Join(profilePath, ".envrc")
-				if _, err := os.Stat(envrcPath); err == nil {
-					profiles = append(profiles, entry.Name())
-				}
-			}
-		}
-
-		if len(profiles) == 0 {
-			return fmt.Errorf("no profiles found")
-		}
-
-		selected, err := ui.SelectProfile(profiles, "Select profile:")
+		selected, err := PromptForProfile(profilesDir, "Select profile:")
 		if err != nil {
 			return err
 		}
 		opts.ProfileName = selected
 	}
 
-	profileDir := filepath.Join(profilesDir, opts.ProfileName)
+	opts.ProfileName = qualifiedName(profilesDir, opts.ProfileName)
+	profileDir := profilePath(profilesDir, opts.ProfileName)
 
 	// Check if profile exists
 	if _, err := os.Stat(profileDir); os.IsNotExist(err) {
-		return fmt.Errorf("profile '%s' does not exist at: %s", opts.ProfileName, profileDir)
+		return NewValidationError("profile '%s' does not exist at: %s", opts.ProfileName, profileDir)
 	}
 
 	// Find all dotfiles
 	dotfiles := findDotfiles(profileDir)
 
 	if len(dotfiles) == 0 {
-		return fmt.Errorf("no dotfiles found in profile '%s'", opts.ProfileName)
+		return NewValidationError("no dotfiles found in profile '%s'", opts.ProfileName)
 	}
 
 	// If no file name provided, show interactive selection
@@ -183,7 +145,7 @@ func EditDotfile(profilesDir string, opts DotfilesOptions) error {
 	}
 
 	if targetPath == "" {
-		return fmt.Errorf("dotfile '%s' not found in profile '%s'", opts.FileName, opts.ProfileName)
+		return NewValidationError("dotfile '%s' not found in profile '%s'", opts.FileName, opts.ProfileName)
 	}
 
 	// Determine editor
@@ -0,0 +1,284 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/gitignore"
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+const encryptedSuffix = ".sops"
+
+// defaultEncryptFiles are encrypted when neither the command line nor the
+// manifest names any files
+var defaultEncryptFiles = []string{".aws/credentials", ".env", ".npmrc"}
+
+// decryptedFileEnv points tools at the runtime copy of a decrypted file
+var decryptedFileEnv = map[string]string{
+	".aws/credentials": "AWS_SHARED_CREDENTIALS_FILE",
+	".npmrc":           "NPM_CONFIG_USERCONFIG",
+	".kube/config":     "KUBECONFIG",
+	".terraformrc":     "TF_CLI_CONFIG_FILE",
+}
+
+type EncryptOptions struct {
+	ProfileName string
+	// Files are profile-relative paths added to the encrypted files
+	Files []string
+	// Age recipients added to the profile's recipients
+	Age []string
+	// Keep leaves the plaintext files in place after encrypting them
+	Keep bool
+}
+
+// EncryptFiles encrypts the profile's designated files with sops and age
+// into <file>.sops, which can be committed, and adds an .envrc block that
+// decrypts them into a private runtime directory when the profile loads
+func EncryptFiles(profilesDir string, opts EncryptOptions) error {
+	for _, file := range opts.Files {
+		if filepath.IsAbs(file) || strings.HasPrefix(filepath.Clean(file), "..") {
+			return NewValidationError("file must be a path inside the profile: %s", file)
+		}
+	}
+	for _, recipient := range opts.Age {
+		if !strings.HasPrefix(recipient, "age1") {
+			return NewValidationError("invalid age recipient: %s (expected age1...)", recipient)
+		}
+	}
+
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile to encrypt:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := exec.LookPath("sops"); err != nil {
+		return NewValidationError("sops is not installed (https://getsops.io)")
+	}
+
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return err
+	}
+	if m.Encrypt == nil {
+		m.Encrypt = &manifest.EncryptConfig{}
+		for _, file := range defaultEncryptFiles {
+			if _, err := os.Stat(filepath.Join(profileDir, file)); err == nil {
+				m.Encrypt.Files = append(m.Encrypt.Files, file)
+			}
+		}
+	}
+	for _, file := range opts.Files {
+		file = filepath.ToSlash(filepath.Clean(file))
+		m.Encrypt.Files = append(withoutName(m.Encrypt.Files, file), file)
+	}
+	if len(m.Encrypt.Files) == 0 {
+		return NewValidationError("none of %s exist in profile '%s' (name files with --file)", strings.Join(defaultEncryptFiles, ", "), profileName)
+	}
+
+	for _, recipient := range opts.Age {
+		m.Encrypt.Age = append(withoutName(m.Encrypt.Age, recipient), recipient)
+	}
+	if len(m.Encrypt.Age) == 0 {
+		recipient, keyFile := defaultAgeRecipient()
+		if recipient == "" {
+			return NewValidationError("no age recipient: pass --age <recipient> or create a key with 'age-keygen -o %s'", keyFile)
+		}
+		ui.PrintInfo(fmt.Sprintf("Encrypting for the age key in %s", keyFile))
+		m.Encrypt.Age = []string{recipient}
+	}
+
+	var encrypted []string
+	for _, file := range m.Encrypt.Files {
+		plainPath := filepath.Join(profileDir, file)
+		plaintext, err := os.ReadFile(plainPath)
+		if os.IsNotExist(err) {
+			if _, err := os.Stat(plainPath + encryptedSuffix); err != nil {
+				ui.PrintWarning(fmt.Sprintf("Skipping %s: file does not exist", file))
+			}
+			continue
+		}
+		if err != nil {
+			return newIOError(err, "failed to read %s", file)
+		}
+
+		ciphertext, err := runSops(profileDir, "--encrypt", "--age", strings.Join(m.Encrypt.Age, ","),
+			"--input-type", "binary", "--output-type", "binary", file)
+		if err != nil {
+			return err
+		}
+		if err := writeProfileFile(plainPath+encryptedSuffix, ciphertext, 0644); err != nil {
+			return newIOError(err, "failed to write %s", file+encryptedSuffix)
+		}
+
+		// Only remove the plaintext once the encrypted copy is known to decrypt
+		if !opts.Keep {
+			decrypted, err := runSops(profileDir, "--decrypt", "--input-type", "binary", "--output-type", "binary", file+encryptedSuffix)
+			if err != nil {
+				return fmt.Errorf("failed to verify %s (plaintext kept): %w", file+encryptedSuffix, err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				return fmt.Errorf("%s does not decrypt to %s (plaintext kept)", file+encryptedSuffix, file)
+			}
+			if err := os.Remove(plainPath); err != nil {
+				return newIOError(err, "failed to remove %s", file)
+			}
+		}
+		encrypted = append(encrypted, file)
+	}
+
+	if err := manifest.Save(profileDir, m); err != nil {
+		return newIOError(err, "failed to save profile manifest")
+	}
+	added, err := ignorePlaintext(profileDir, m.Encrypt.Files)
+	if err != nil {
+		return newIOError(err, "failed to update .gitignore")
+	}
+	if _, err := setManagedBlock(profileDir, "sops", sopsEnvBlock(m.Encrypt.Files)); err != nil {
+		return newIOError(err, "failed to update .envrc")
+	}
+
+	if len(encrypted) == 0 {
+		ui.PrintInfo(fmt.Sprintf("No plaintext files to encrypt in profile '%s'", profileName))
+	} else {
+		ui.PrintSuccess(fmt.Sprintf("Encrypted for %s: %s", profileName, strings.Join(encrypted, ", ")))
+	}
+	fmt.Printf("  Commit the %s files; they are decrypted into a private runtime directory when the profile loads\n", encryptedSuffix)
+	if len(added) > 0 {
+		fmt.Printf("  %s✓%s Updated .gitignore: %s\n", ui.ColorGreen, ui.ColorReset, strings.Join(added, ", "))
+	}
+	if opts.Keep {
+		fmt.Println("  Plaintext files were kept; the runtime copies take precedence")
+	}
+	return changesApplied()
+}
+
+// runSops runs sops in the profile directory and returns its output
+func runSops(profileDir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("sops", args...)
+	cmd.Dir = profileDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("sops %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// defaultAgeRecipient returns the public key of the age identity sops uses
+// for decryption (SOPS_AGE_KEY_FILE or the sops default key file), read
+// from the "# public key:" comment age-keygen writes
+func defaultAgeRecipient() (recipient, keyFile string) {
+	keyFile = os.Getenv("SOPS_AGE_KEY_FILE")
+	if keyFile == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return "", "~/.config/sops/age/keys.txt"
+		}
+		keyFile = filepath.Join(configDir, "sops", "age", "keys.txt")
+	}
+
+	content, err := os.ReadFile(keyFile)
+	if err != nil {
+		return "", keyFile
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if key, ok := strings.CutPrefix(strings.TrimSpace(line), "# public key:"); ok {
+			return strings.TrimSpace(key), keyFile
+		}
+	}
+	return "", keyFile
+}
+
+// ignorePlaintext makes sure .gitignore ignores the plaintext files but not
+// their encrypted copies, returning the patterns it added
+func ignorePlaintext(profileDir string, files []string) ([]string, error) {
+	gitignorePath := filepath.Join(profileDir, ".gitignore")
+	content, err := readOptionalFile(gitignorePath)
+	if err != nil {
+		return nil, err
+	}
+
+	file := gitignore.Parse(content)
+	var missing []string
+	for _, f := range files {
+		if !file.Ignores(f, false) {
+			missing = append(missing, "/"+f)
+		}
+		if !file.Ignores(f+encryptedSuffix, false) {
+			continue
+		}
+		// git cannot re-include a file inside an ignored directory
+		if dir := ignoredParent(file, f); dir != "" {
+			ui.PrintWarning(fmt.Sprintf("%s cannot be committed: .gitignore ignores %s/", f+encryptedSuffix, dir))
+			continue
+		}
+		missing = append(missing, "!/"+f+encryptedSuffix)
+	}
+	if len(missing) == 0 {
+		return nil, nil
+	}
+
+	file.AddToSection("# sops-encrypted files: commit <file>.sops, never the plaintext", missing, "# OS files")
+	if err := writeProfileFile(gitignorePath, []byte(file.String()), 0644); err != nil {
+		return nil, err
+	}
+	recordChecksums(profileDir, ".gitignore")
+	return missing, nil
+}
+
+// ignoredParent returns the first parent directory of path that the file
+// ignores, or "" if none is
+func ignoredParent(file *gitignore.File, path string) string {
+	parts := strings.Split(path, "/")
+	for i := 1; i < len(parts); i++ {
+		dir := strings.Join(parts[:i], "/")
+		if file.Ignores(dir, true) {
+			return dir
+		}
+	}
+	return ""
+}
+
+// sopsEnvBlock renders the managed .envrc block that decrypts the files into
+// a private runtime directory outside the profile and points tools at them
+func sopsEnvBlock(files []string) string {
+	var b strings.Builder
+	b.WriteString("# Decrypt sops-encrypted files outside the profile (profile encrypt)\n")
+	b.WriteString("if has sops; then\n")
+	b.WriteString("  profile_secrets_dir=\"${XDG_RUNTIME_DIR:-${TMPDIR:-/tmp}}/profile-$WORKSPACE_PROFILE\"\n")
+	b.WriteString("  profile_decrypt() (\n")
+	b.WriteString("    [[ -f \"$1" + encryptedSuffix + "\" ]] || exit 1\n")
+	b.WriteString("    umask 077\n")
+	b.WriteString("    mkdir -p \"$(dirname \"$profile_secrets_dir/$1\")\"\n")
+	b.WriteString("    sops --decrypt --input-type binary --output-type binary \"$1" + encryptedSuffix + "\" > \"$profile_secrets_dir/$1\"\n")
+	b.WriteString("  )\n")
+	for _, file := range files {
+		fmt.Fprintf(&b, "  watch_file %s\n", shellQuote(file+encryptedSuffix))
+		decrypt := fmt.Sprintf("  profile_decrypt %s", shellQuote(file))
+		runtimePath := fmt.Sprintf("\"$profile_secrets_dir/%s\"", file)
+		switch {
+		case filepath.Base(file) == ".env":
+			fmt.Fprintf(&b, "%s && dotenv %s\n", decrypt, runtimePath)
+		case decryptedFileEnv[file] != "":
+			fmt.Fprintf(&b, "%s && export %s=%s\n", decrypt, decryptedFileEnv[file], runtimePath)
+		default:
+			fmt.Fprintf(&b, "%s\n", decrypt)
+		}
+	}
+	b.WriteString("else\n")
+	b.WriteString("  log_error \"sops is not installed; encrypted profile files were not decrypted\"\n")
+	b.WriteString("fi")
+	return b.String()
+}
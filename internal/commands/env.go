@@ -0,0 +1,245 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/direnv"
+	"github.com/mindmorass/shell-profile-manager/internal/redact"
+	"github.com/mindmorass/shell-profile-manager/internal/shell"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// EnvFormats are the output formats of PrintEnv
+var EnvFormats = []string{"sh", "fish", "nu", "json"}
+
+// EnvExportFormats are the output formats of ExportEnv
+var EnvExportFormats = []string{"dotenv", "json", "github-actions", "docker-args"}
+
+type EnvOptions struct {
+	ProfileName string
+	// Format is one of EnvFormats (default sh)
+	Format string
+}
+
+// PrintEnv prints the variables the profile's .envrc sets, in the syntax of
+// a shell, so shells direnv has no hook for (or a running shell outside the
+// profile) can load them. Only the environment is printed, so the output
+// can be eval'd or sourced directly.
+func PrintEnv(profilesDir string, opts EnvOptions) error {
+	format := valueOr(opts.Format, "sh")
+	if len(withoutName(EnvFormats, format)) == len(EnvFormats) {
+		return NewValidationError("unknown format: %s (supported: %s)", format, strings.Join(EnvFormats, ", "))
+	}
+
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	vars, err := ProfileEnv(profileDir, profileName)
+	if err != nil {
+		return err
+	}
+	// Output piped to eval or source is left intact
+	if ui.StdoutIsTerminal() {
+		vars = redactedVars(vars)
+	}
+
+	switch format {
+	case "json":
+		output, err := json.MarshalIndent(vars, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(output))
+	case "nu":
+		fmt.Print(nuLoadEnv(vars))
+	default:
+		fmt.Print(shellExports(format, vars))
+	}
+	return nil
+}
+
+// ExportEnv prints the variables the profile's .envrc sets for programs
+// other than shells: as a dotenv file (docker run --env-file), a JSON
+// object, lines for $GITHUB_ENV, or docker run arguments. Variables the
+// profile unsets and PATH, which only works on this machine, are left out.
+func ExportEnv(profilesDir string, opts EnvOptions) error {
+	format := valueOr(opts.Format, "dotenv")
+	if len(withoutName(EnvExportFormats, format)) == len(EnvExportFormats) {
+		return NewValidationError("unknown format: %s (supported: %s)", format, strings.Join(EnvExportFormats, ", "))
+	}
+
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	vars, err := ProfileEnv(profileDir, profileName)
+	if err != nil {
+		return err
+	}
+	for name, value := range vars {
+		if value == nil || name == "PATH" {
+			delete(vars, name)
+		}
+	}
+	if format == "dotenv" {
+		for _, name := range sortedNames(vars) {
+			if strings.Contains(*vars[name], "\n") {
+				return NewValidationError("%s has a multi-line value, which dotenv files cannot hold (use --format json or github-actions)", name)
+			}
+		}
+	}
+	if ui.StdoutIsTerminal() {
+		vars = redactedVars(vars)
+	}
+
+	switch format {
+	case "json":
+		output, err := json.MarshalIndent(vars, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(output))
+	case "github-actions":
+		for _, name := range sortedNames(vars) {
+			fmt.Print(githubEnvLine(name, *vars[name]))
+		}
+	case "docker-args":
+		var args []string
+		for _, name := range sortedNames(vars) {
+			args = append(args, "-e "+shellQuote(name+"="+*vars[name]))
+		}
+		fmt.Println(strings.Join(args, " "))
+	default:
+		// docker reads --env-file values as they are, without quotes
+		for _, name := range sortedNames(vars) {
+			fmt.Printf("%s=%s\n", name, *vars[name])
+		}
+	}
+	return nil
+}
+
+// githubEnvLine renders a variable for $GITHUB_ENV: NAME=value, or a
+// heredoc with a delimiter the value does not contain for multi-line values
+func githubEnvLine(name, value string) string {
+	if !strings.Contains(value, "\n") {
+		return name + "=" + value + "\n"
+	}
+	delimiter := "EOF"
+	for strings.Contains(value, delimiter) {
+		delimiter = "SPM_" + delimiter
+	}
+	return fmt.Sprintf("%s<<%s\n%s\n%s\n", name, delimiter, value, delimiter)
+}
+
+// ProfileEnv returns the variables the profile's .envrc sets, as direnv
+// exports them; a nil value is a variable it unsets. It fails when direnv
+// is not installed or will not load the .envrc.
+func ProfileEnv(profileDir, profileName string) (map[string]*string, error) {
+	if !direnv.Installed() {
+		return nil, NewValidationError("direnv is not installed")
+	}
+	if issue := direnvIssue(profileDir, profileName); issue != "" {
+		return nil, NewValidationError("%s", issue)
+	}
+	return direnv.Export(profileDir)
+}
+
+// PrintNuHook prints the config.nu snippet that loads direnv environments
+// (and so profiles) on every prompt, as direnv's own shell hooks do
+func PrintNuHook() {
+	fmt.Print(`# direnv for nushell (profile env --hook nu): add to config.nu
+$env.config = ($env.config | upsert hooks.pre_prompt (
+    ($env.config.hooks.pre_prompt? | default []) | append {||
+        if (which direnv | is-empty) { return }
+        direnv export json | from json | default {}
+            | if 'PATH' in $in { update PATH {|vars| $vars.PATH | split row (char esep) } } else { $in }
+            | load-env
+    }
+))
+`)
+}
+
+// shellExports renders vars as sh or fish statements
+func shellExports(format string, vars map[string]*string) string {
+	var b strings.Builder
+	for _, name := range sortedNames(vars) {
+		value := vars[name]
+		switch {
+		case format == "fish" && value == nil:
+			fmt.Fprintf(&b, "set -e %s\n", name)
+		case format == "fish" && strings.HasSuffix(name, "PATH"):
+			// fish keeps PATH-like variables as lists
+			fmt.Fprintf(&b, "set -gx %s (string split : -- %s)\n", name, shell.Quote(shell.Fish, *value))
+		case format == "fish":
+			fmt.Fprintf(&b, "set -gx %s %s\n", name, shell.Quote(shell.Fish, *value))
+		case value == nil:
+			fmt.Fprintf(&b, "unset %s\n", name)
+		default:
+			fmt.Fprintf(&b, "export %s=%s\n", name, shellQuote(*value))
+		}
+	}
+	return b.String()
+}
+
+// nuLoadEnv renders vars as a nushell load-env call, with PATH as a list
+func nuLoadEnv(vars map[string]*string) string {
+	var b strings.Builder
+	var unset []string
+	b.WriteString("load-env {\n")
+	for _, name := range sortedNames(vars) {
+		value := vars[name]
+		if value == nil {
+			unset = append(unset, name)
+			continue
+		}
+		if name == "PATH" {
+			fmt.Fprintf(&b, "    %s: (%s | split row (char esep))\n", nuQuote(name), nuQuote(*value))
+			continue
+		}
+		fmt.Fprintf(&b, "    %s: %s\n", nuQuote(name), nuQuote(*value))
+	}
+	b.WriteString("}\n")
+	for _, name := range unset {
+		fmt.Fprintf(&b, "hide-env --ignore-errors %s\n", name)
+	}
+	return b.String()
+}
+
+// nuQuote quotes a value as a nushell string literal without escapes: a
+// single-quoted string, or a raw string when the value contains quotes
+func nuQuote(value string) string {
+	if !strings.Contains(value, "'") {
+		return "'" + value + "'"
+	}
+	hashes := "#"
+	for strings.Contains(value, "'"+hashes) {
+		hashes += "#"
+	}
+	return "r" + hashes + "'" + value + "'" + hashes
+}
+
+// redactedVars returns vars with the values of secrets masked
+func redactedVars(vars map[string]*string) map[string]*string {
+	redacted := make(map[string]*string, len(vars))
+	for name, value := range vars {
+		if value != nil {
+			masked := redact.Value(name, *value)
+			value = &masked
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
+
+func sortedNames(vars map[string]*string) []string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/envrc"
+)
+
+// envSectionAnchor starts the .env loading section of .envrc; new sections
+// and managed blocks go before it
+const envSectionAnchor = "# Load .env file if it exists"
+
+// loadEnvrc parses the profile's .envrc so several steps can edit it and
+// write it once
+func loadEnvrc(profileDir string) (*envrc.Document, error) {
+	content, err := os.ReadFile(filepath.Join(profileDir, ".envrc"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .envrc: %w", err)
+	}
+	return envrc.Parse(string(content)), nil
+}
+
+// saveEnvrc writes doc to the profile's .envrc if it renders differently
+// from what was read. It reports whether the file changed.
+func saveEnvrc(profileDir string, doc *envrc.Document) (bool, error) {
+	if !doc.Changed() {
+		return false, nil
+	}
+	if err := writeProfileFile(filepath.Join(profileDir, ".envrc"), []byte(doc.String()), 0644); err != nil {
+		return false, fmt.Errorf("failed to write .envrc: %w", err)
+	}
+	doc.MarkSaved()
+	recordChecksums(profileDir, ".envrc")
+	return true, nil
+}
+
+// setManagedBlock replaces the named block in the profile's .envrc with
+// body, adding it before the .env loading section if it does not exist yet.
+// An empty body removes the block. It reports whether the file changed.
+func setManagedBlock(profileDir, name, body string) (bool, error) {
+	// Commands that change a profile hold its lock already; those that only
+	// set a block take it here, so the read and write are not interleaved
+	// with another process's
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	doc, err := loadEnvrc(profileDir)
+	if err != nil {
+		return false, err
+	}
+	slog.Debug("setting managed block", "block", name, "remove", body == "")
+	doc.SetBlock(name, body, envSectionAnchor)
+	return saveEnvrc(profileDir, doc)
+}
+
+// managedExport returns a single export line for a managed block
+func managedExport(name, value string) string {
+	return fmt.Sprintf("export %s=%s", name, shellQuote(value))
+}
+
+// shellQuote quotes a value for safe use in .envrc
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,152 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// Exit codes returned by the profile command
+const (
+	ExitOK         = 0 // Success, nothing changed
+	ExitFailure    = 1 // Unclassified failure
+	ExitChanged    = 2 // Success, changes were applied (with --detailed-exitcode)
+	ExitValidation = 3 // Invalid input: bad names, unknown profiles, missing arguments
+	ExitIO         = 4 // Filesystem or I/O failure
+)
+
+var (
+	detailedExitCodes bool
+	changedExitCode   = ExitChanged
+)
+
+// EnableDetailedExitCodes makes mutating commands report applied changes
+// through a ChangesApplied result instead of returning nil
+func EnableDetailedExitCodes(enabled bool) {
+	detailedExitCodes = enabled
+}
+
+// SetChangedExitCode overrides the exit code used for applied changes. The
+// codes of failures are refused, since a change would be taken for one.
+func SetChangedExitCode(code int) error {
+	switch {
+	case code == 0:
+		return nil
+	case code == ExitFailure || code == ExitValidation || code == ExitIO:
+		return NewValidationError("changed_exit_code %d is reserved for failures (%d, %d, %d); use %d or a code above %d", code, ExitFailure, ExitValidation, ExitIO, ExitChanged, ExitIO)
+	case code < 0 || code > 255:
+		return NewValidationError("changed_exit_code must be between 2 and 255, got: %d", code)
+	}
+	changedExitCode = code
+	return nil
+}
+
+// ValidationError indicates the command was given invalid input
+type ValidationError struct {
+	msg string
+}
+
+func (e *ValidationError) Error() string {
+	return e.msg
+}
+
+// NewValidationError creates a ValidationError with a formatted message
+func NewValidationError(format string, args ...interface{}) error {
+	return &ValidationError{msg: fmt.Sprintf(format, args...)}
+}
+
+// IOError indicates a failure reading or writing profile files
+type IOError struct {
+	msg string
+	err error
+}
+
+func (e *IOError) Error() string {
+	return fmt.Sprintf("%s: %v", e.msg, e.err)
+}
+
+func (e *IOError) Unwrap() error {
+	return e.err
+}
+
+// newIOError wraps err in an IOError with a formatted message
+func newIOError(err error, format string, args ...interface{}) error {
+	return &IOError{msg: fmt.Sprintf(format, args...), err: err}
+}
+
+// PluginExitError is returned when a plugin command exits with a non-zero
+// status, which becomes the exit code of profile
+type PluginExitError struct {
+	Plugin  string
+	Command string
+	Code    int
+}
+
+func (e *PluginExitError) Error() string {
+	return fmt.Sprintf("plugin %s: %s exited with status %d", e.Plugin, e.Command, e.Code)
+}
+
+// CommandExitError is returned when the command 'profile run' runs exits
+// with a non-zero status, which becomes the exit code of profile. The
+// command has reported the failure itself.
+type CommandExitError struct {
+	Command string
+	Code    int
+}
+
+func (e *CommandExitError) Error() string {
+	return fmt.Sprintf("%s exited with status %d", e.Command, e.Code)
+}
+
+// ChangesApplied is returned by mutating commands when detailed exit codes
+// are enabled and the command modified something. It is not a failure.
+type ChangesApplied struct{}
+
+func (e *ChangesApplied) Error() string {
+	return "changes applied"
+}
+
+// changesApplied reports a successful command that modified files
+func changesApplied() error {
+	if !detailedExitCodes {
+		return nil
+	}
+	return &ChangesApplied{}
+}
+
+// ExitCode maps an error returned by a command to the process exit code
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	var changed *ChangesApplied
+	if errors.As(err, &changed) {
+		return changedExitCode
+	}
+
+	var pluginErr *PluginExitError
+	if errors.As(err, &pluginErr) {
+		return pluginErr.Code
+	}
+
+	var commandErr *CommandExitError
+	if errors.As(err, &commandErr) {
+		return commandErr.Code
+	}
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) || errors.Is(err, ui.ErrInputRequired) {
+		return ExitValidation
+	}
+
+	var ioErr *IOError
+	var pathErr *fs.PathError
+	if errors.As(err, &ioErr) || errors.As(err, &pathErr) {
+		return ExitIO
+	}
+
+	return ExitFailure
+}
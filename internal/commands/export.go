@@ -0,0 +1,362 @@
+package commands
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/atomicfile"
+	"github.com/mindmorass/shell-profile-manager/internal/chezmoi"
+	"github.com/mindmorass/shell-profile-manager/internal/gitignore"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// profileNamePattern matches the names CreateProfile accepts
+var profileNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// exportSkipDirs are never exported: the profile's git metadata and the
+// code checked out into the workspace
+var exportSkipDirs = map[string]bool{".git": true, "code": true}
+
+// exportAlwaysDirs are exported even though the profile .gitignore ignores
+// them, because profile writes its wrapper scripts there
+var exportAlwaysDirs = map[string]bool{"bin": true}
+
+type ExportOptions struct {
+	ProfileName string
+	// Format is the export format; only "chezmoi" is supported
+	Format string
+	// Output is the chezmoi source directory (default: 'chezmoi source-path')
+	Output string
+	// Force overwrites files that already exist in the output
+	Force bool
+}
+
+type ImportOptions struct {
+	ProfileName string
+	// From is a chezmoi source directory or the profile's fragment in it
+	// (default: 'chezmoi source-path')
+	From string
+	// Force imports into an existing profile, overwriting its files
+	Force bool
+}
+
+// ExportProfile writes the profile's files to a chezmoi source directory,
+// at the path chezmoi maps to the profile directory. Files that contain the
+// home directory become templates so they apply on other machines.
+func ExportProfile(profilesDir string, opts ExportOptions) error {
+	if format := valueOr(opts.Format, "chezmoi"); format != "chezmoi" {
+		return NewValidationError("unsupported export format: %s (supported: chezmoi)", format)
+	}
+
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile to export:")
+	if err != nil {
+		return err
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to get home directory: %w", err)
+	}
+	relProfile, err := filepath.Rel(homeDir, profileDir)
+	if err != nil || relProfile == "." || strings.HasPrefix(relProfile, "..") {
+		return NewValidationError("profile directory %s is not under %s; chezmoi only manages files in the home directory", profileDir, homeDir)
+	}
+
+	sourceDir, err := chezmoiSourceDir(opts.Output)
+	if err != nil {
+		return err
+	}
+	fragmentDir := filepath.Join(sourceDir, filepath.FromSlash(chezmoi.TargetPath(filepath.ToSlash(relProfile))))
+	if entries, err := os.ReadDir(fragmentDir); err == nil && len(entries) > 0 && !opts.Force {
+		return NewValidationError("%s already exists (use --force to overwrite)", fragmentDir)
+	}
+
+	ignoreContent, err := readOptionalFile(filepath.Join(profileDir, ".gitignore"))
+	if err != nil {
+		return newIOError(err, "failed to read .gitignore")
+	}
+	ignores := gitignore.Parse(ignoreContent)
+
+	// sourceDirs maps profile-relative directories to their source paths
+	sourceDirs := map[string]string{".": fragmentDir}
+	var exported, templates []string
+
+	err = filepath.WalkDir(profileDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(profileDir, path)
+		if rel == "." {
+			return nil
+		}
+		slashRel := filepath.ToSlash(rel)
+		topLevel := strings.SplitN(slashRel, "/", 2)[0]
+
+		if d.IsDir() && exportSkipDirs[slashRel] {
+			return filepath.SkipDir
+		}
+		if !exportAlwaysDirs[topLevel] && ignores.Ignores(slashRel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		parent := sourceDirs[filepath.Dir(rel)]
+		private := info.Mode().Perm()&0077 == 0
+
+		if d.IsDir() {
+			sourceDirs[rel] = filepath.Join(parent, chezmoi.DirName(d.Name(), private))
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			ui.PrintWarning(fmt.Sprintf("Skipping %s: not a regular file", slashRel))
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rendered, isTemplate := chezmoi.Templatize(string(content), homeDir)
+		attrs := chezmoi.Attributes{
+			Private:    private,
+			Executable: info.Mode().Perm()&0111 != 0,
+			Empty:      len(content) == 0,
+			Template:   isTemplate,
+		}
+
+		target := filepath.Join(parent, chezmoi.FileName(d.Name(), attrs))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := atomicfile.WriteFile(target, []byte(rendered), 0644); err != nil {
+			return err
+		}
+
+		exported = append(exported, slashRel)
+		if isTemplate {
+			templates = append(templates, slashRel)
+		}
+		return nil
+	})
+	if err != nil {
+		return newIOError(err, "failed to export profile '%s'", profileName)
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Exported %d files of profile '%s' to %s", len(exported), profileName, fragmentDir))
+	if len(templates) > 0 {
+		fmt.Printf("  Templated (home directory): %s\n", strings.Join(templates, ", "))
+	}
+	fmt.Println("  Review with: chezmoi diff")
+	return nil
+}
+
+// ImportProfile creates a profile from its fragment in a chezmoi source
+// directory, reversing the source state names and home directory templates
+func ImportProfile(profilesDir string, opts ImportOptions) error {
+	fragmentDir, err := findChezmoiFragment(profilesDir, opts)
+	if err != nil {
+		return err
+	}
+
+	profileName := opts.ProfileName
+	if profileName == "" {
+		name, _, err := chezmoi.ParseDirName(filepath.Base(fragmentDir))
+		if err != nil {
+			return NewValidationError("%v", err)
+		}
+		profileName = name
+	}
+	if !profileNamePattern.MatchString(profileName) {
+		return NewValidationError("profile name can only contain letters, numbers, hyphens, and underscores")
+	}
+
+	profileDir := filepath.Join(profilesDir, profileName)
+	if _, err := os.Stat(profileDir); err == nil && !opts.Force {
+		return NewValidationError("profile '%s' already exists at: %s (use --force to overwrite its files)", profileName, profileDir)
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to get home directory: %w", err)
+	}
+	_, chezmoiErr := exec.LookPath("chezmoi")
+
+	// targetDirs maps fragment-relative source directories to target paths
+	targetDirs := map[string]string{".": profileDir}
+	var imported, skipped []string
+
+	err = filepath.WalkDir(fragmentDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(fragmentDir, path)
+		if rel == "." {
+			return os.MkdirAll(profileDir, 0755)
+		}
+		parent, ok := targetDirs[filepath.Dir(rel)]
+		if !ok {
+			return nil
+		}
+		// chezmoi's own files (.chezmoiignore, .chezmoi.toml.tmpl, ...)
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			name, private, err := chezmoi.ParseDirName(d.Name())
+			if err != nil {
+				skipped = append(skipped, filepath.ToSlash(rel))
+				return filepath.SkipDir
+			}
+			target := filepath.Join(parent, name)
+			perm := os.FileMode(0755)
+			if private {
+				perm = 0700
+			}
+			if err := os.MkdirAll(target, perm); err != nil {
+				return err
+			}
+			if err := os.Chmod(target, perm); err != nil {
+				return err
+			}
+			targetDirs[rel] = target
+			return nil
+		}
+
+		name, attrs, err := chezmoi.ParseFileName(d.Name())
+		if err != nil {
+			skipped = append(skipped, filepath.ToSlash(rel))
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		rendered := string(content)
+		if attrs.Template {
+			literal, ok := chezmoi.Untemplatize(rendered, homeDir)
+			switch {
+			case ok:
+				rendered = literal
+			case chezmoiErr == nil:
+				if rendered, err = executeChezmoiTemplate(content); err != nil {
+					return err
+				}
+			default:
+				skipped = append(skipped, filepath.ToSlash(rel)+" (template needs chezmoi)")
+				return nil
+			}
+		}
+		if rendered == "" && !attrs.Empty {
+			// chezmoi removes empty files without the empty_ attribute
+			return nil
+		}
+
+		perm := os.FileMode(0644)
+		switch {
+		case attrs.Private && attrs.Executable:
+			perm = 0700
+		case attrs.Private:
+			perm = 0600
+		case attrs.Executable:
+			perm = 0755
+		}
+		target := filepath.Join(parent, name)
+		if err := atomicfile.WriteFile(target, []byte(rendered), perm); err != nil {
+			return err
+		}
+		if err := os.Chmod(target, perm); err != nil {
+			return err
+		}
+		imported = append(imported, filepath.ToSlash(strings.TrimPrefix(target, profileDir+string(filepath.Separator))))
+		return nil
+	})
+	if err != nil {
+		return newIOError(err, "failed to import profile '%s'", profileName)
+	}
+
+	for _, entry := range skipped {
+		ui.PrintWarning(fmt.Sprintf("Skipped unsupported chezmoi entry: %s", entry))
+	}
+	if _, err := os.Stat(filepath.Join(profileDir, ".envrc")); os.IsNotExist(err) {
+		ui.PrintWarning(fmt.Sprintf("%s has no .envrc; it is not a complete profile", profileDir))
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Imported %d files into profile '%s' at %s", len(imported), profileName, profileDir))
+	fmt.Printf("  Apply features for this machine with: profile update %s\n", profileName)
+	return changesApplied()
+}
+
+// chezmoiSourceDir returns dir, or chezmoi's source directory when dir is
+// empty
+func chezmoiSourceDir(dir string) (string, error) {
+	if dir != "" {
+		return filepath.Abs(dir)
+	}
+	if _, err := exec.LookPath("chezmoi"); err != nil {
+		return "", NewValidationError("chezmoi is not installed; pass the source directory explicitly")
+	}
+	output, err := exec.Command("chezmoi", "source-path").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get chezmoi source path: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// findChezmoiFragment returns the directory holding the profile's source
+// state: opts.From itself when it contains dot_envrc, otherwise the path
+// chezmoi maps to the profile directory under the source directory
+func findChezmoiFragment(profilesDir string, opts ImportOptions) (string, error) {
+	sourceDir, err := chezmoiSourceDir(opts.From)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(filepath.Join(sourceDir, "dot_envrc")); err == nil {
+		return sourceDir, nil
+	}
+
+	if opts.ProfileName == "" {
+		return "", NewValidationError("%s has no dot_envrc; pass the profile name to find its fragment", sourceDir)
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	relProfile, err := filepath.Rel(homeDir, profilePath(profilesDir, opts.ProfileName))
+	if err != nil || strings.HasPrefix(relProfile, "..") {
+		return "", NewValidationError("profiles directory %s is not under %s; pass the profile's fragment directory", profilesDir, homeDir)
+	}
+
+	fragmentDir := filepath.Join(sourceDir, filepath.FromSlash(chezmoi.TargetPath(filepath.ToSlash(relProfile))))
+	if _, err := os.Stat(fragmentDir); err != nil {
+		return "", NewValidationError("no chezmoi source for profile '%s' at: %s", opts.ProfileName, fragmentDir)
+	}
+	return fragmentDir, nil
+}
+
+// executeChezmoiTemplate renders a template with chezmoi's data for this
+// machine
+func executeChezmoiTemplate(content []byte) (string, error) {
+	cmd := exec.Command("chezmoi", "execute-template")
+	cmd.Stdin = strings.NewReader(string(content))
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("chezmoi execute-template failed: %w", err)
+	}
+	return string(output), nil
+}
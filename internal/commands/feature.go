@@ -0,0 +1,274 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/envrc"
+	"github.com/mindmorass/shell-profile-manager/internal/features"
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type FeatureOptions struct {
+	ProfileName string
+	Names       []string
+}
+
+// FeatureSummary is a feature as listed with --output json|yaml
+type FeatureSummary struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description" yaml:"description"`
+	Optional    bool   `json:"optional" yaml:"optional"`
+	// Enabled is set when the features of a profile are listed
+	Enabled *bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+}
+
+// LoadProfileFeatures returns the features active for a profile according
+// to its manifest and those of the profiles it extends (defaults plus
+// enabled optional features), followed by the layers of those profiles
+func LoadProfileFeatures(profileDir string) ([]features.Feature, error) {
+	all, err := features.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load feature definitions: %w", err)
+	}
+
+	layers, err := profileLayers(profileDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return layeredFeatures(all, layers), nil
+}
+
+// ListFeatures shows all features, or which ones a profile uses
+func ListFeatures(profilesDir string, opts FeatureOptions) error {
+	all, err := features.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load feature definitions: %w", err)
+	}
+
+	active := make(map[string]bool)
+	if opts.ProfileName != "" {
+		_, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "")
+		if err != nil {
+			return err
+		}
+		feats, err := LoadProfileFeatures(profileDir)
+		if err != nil {
+			return err
+		}
+		for _, feature := range feats {
+			active[feature.Name] = true
+		}
+	}
+
+	if ui.Structured() {
+		summaries := []FeatureSummary{}
+		for _, feature := range all {
+			summary := FeatureSummary{Name: feature.Name, Description: feature.Description, Optional: feature.Optional}
+			if opts.ProfileName != "" {
+				enabled := active[feature.Name]
+				summary.Enabled = &enabled
+			}
+			summaries = append(summaries, summary)
+		}
+		return ui.Render(summaries)
+	}
+
+	if opts.ProfileName != "" {
+		fmt.Printf("%sFeatures for %s%s\n", ui.ColorBlue, opts.ProfileName, ui.ColorReset)
+	} else {
+		fmt.Printf("%sAvailable features%s\n", ui.ColorBlue, ui.ColorReset)
+	}
+	fmt.Println()
+
+	for _, feature := range all {
+		kind := "default"
+		if feature.Optional {
+			kind = "optional"
+		}
+		if opts.ProfileName == "" {
+			fmt.Printf("  %-14s %-9s %s\n", feature.Name, kind, feature.Description)
+			continue
+		}
+		if active[feature.Name] {
+			fmt.Printf("  %s✓%s %-14s %s\n", ui.ColorGreen, ui.ColorReset, feature.Name, feature.Description)
+		} else {
+			fmt.Printf("  %s-%s %-14s %s (%s, off)\n", ui.ColorYellow, ui.ColorReset, feature.Name, feature.Description, kind)
+		}
+	}
+	return nil
+}
+
+// EnableFeatures turns features on for a profile and applies them
+func EnableFeatures(profilesDir string, opts FeatureOptions) error {
+	return setFeatures(profilesDir, opts, true)
+}
+
+// DisableFeatures turns features off for a profile. Lines already written
+// to .envrc and .gitignore are left in place.
+func DisableFeatures(profilesDir string, opts FeatureOptions) error {
+	return setFeatures(profilesDir, opts, false)
+}
+
+func setFeatures(profilesDir string, opts FeatureOptions, enable bool) error {
+	if len(opts.Names) == 0 {
+		return NewValidationError("at least one feature name is required")
+	}
+
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+
+	updates, err := SetFeatures(profileDir, opts.Names, enable)
+	if err != nil {
+		return err
+	}
+
+	if !enable {
+		ui.PrintSuccess(fmt.Sprintf("Disabled for %s: %s", profileName, strings.Join(opts.Names, ", ")))
+		fmt.Println("  Existing lines in .envrc and .gitignore were left in place; remove them if no longer needed")
+		return changesApplied()
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Enabled for %s: %s", profileName, strings.Join(opts.Names, ", ")))
+	for _, update := range updates {
+		fmt.Printf("  ✓ %s\n", update)
+	}
+	return changesApplied()
+}
+
+// SetFeatures turns features on or off in a profile's manifest. Enabled
+// features are applied right away, returning a description of each change;
+// lines of disabled features are left in place.
+func SetFeatures(profileDir string, names []string, enable bool) ([]string, error) {
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	all, err := features.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load feature definitions: %w", err)
+	}
+
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return nil, err
+	}
+	if m.Features == nil {
+		m.Features = &manifest.FeatureSelection{}
+	}
+
+	// A feature is listed in the manifest when the profile differs from
+	// what it inherits: the defaults, or the selection of its bases
+	layers, err := profileLayers(profileDir)
+	if err != nil {
+		return nil, err
+	}
+	inherited := layeredFeatures(all, layers[:len(layers)-1])
+
+	var changed []features.Feature
+	for _, name := range names {
+		feature, ok := features.Find(all, name)
+		if !ok {
+			return nil, NewValidationError("unknown feature: %s (see 'profile feature list')", name)
+		}
+		m.Features.Enable = withoutName(m.Features.Enable, name)
+		m.Features.Disable = withoutName(m.Features.Disable, name)
+		_, on := features.Find(inherited, name)
+		if enable && !on {
+			m.Features.Enable = append(m.Features.Enable, name)
+		}
+		if !enable && on {
+			m.Features.Disable = append(m.Features.Disable, name)
+		}
+		changed = append(changed, feature)
+	}
+	if len(m.Features.Enable) == 0 && len(m.Features.Disable) == 0 {
+		m.Features = nil
+	}
+
+	if err := manifest.Save(profileDir, m); err != nil {
+		return nil, newIOError(err, "failed to save profile manifest")
+	}
+
+	if !enable {
+		recordHistory(profileDir, "feature disable", []string{fmt.Sprintf("Disabled features: %s", strings.Join(names, ", "))})
+		return nil, nil
+	}
+
+	// Apply just the enabled features
+	var updates []string
+	doc, err := loadEnvrc(profileDir)
+	if err != nil {
+		return nil, newIOError(err, "failed to update .envrc")
+	}
+	if replaced := commentOutReplaced(doc, changed, all); len(replaced) > 0 {
+		updates = append(updates, fmt.Sprintf("Commented out replaced variables: %s", strings.Join(replaced, ", ")))
+	}
+	if updated, err := updateDirectories(profileDir, changed, false); err != nil {
+		return nil, newIOError(err, "failed to update directories")
+	} else if len(updated) > 0 {
+		updates = append(updates, fmt.Sprintf("Created directories: %s", strings.Join(updated, ", ")))
+	}
+	if updated, err := updateFiles(profileDir, changed, false); err != nil {
+		return nil, newIOError(err, "failed to create files")
+	} else if len(updated) > 0 {
+		updates = append(updates, fmt.Sprintf("Created files: %s", strings.Join(updated, ", ")))
+	}
+	if linked, _, err := updateLinks(profileDir, changed, false); err != nil {
+		return nil, newIOError(err, "failed to link files")
+	} else if len(linked) > 0 {
+		updates = append(updates, fmt.Sprintf("Linked files: %s", strings.Join(linked, ", ")))
+	}
+	if updated := updateEnvrc(doc, changed); len(updated) > 0 {
+		updates = append(updates, fmt.Sprintf("Updated .envrc: %s", strings.Join(updated, ", ")))
+	}
+	if _, err := saveEnvrc(profileDir, doc); err != nil {
+		return nil, newIOError(err, "failed to update .envrc")
+	}
+	if updated, err := updateGitignore(profileDir, changed, false); err != nil {
+		return nil, newIOError(err, "failed to update .gitignore")
+	} else if len(updated) > 0 {
+		updates = append(updates, fmt.Sprintf("Updated .gitignore: %s", strings.Join(updated, ", ")))
+	}
+
+	recordHistory(profileDir, "feature enable", append([]string{fmt.Sprintf("Enabled features: %s", strings.Join(names, ", "))}, updates...))
+	return updates, nil
+}
+
+// commentOutReplaced comments out the exports of features replaced by the
+// enabled ones in doc so the new definitions take effect
+func commentOutReplaced(doc *envrc.Document, enabled, all []features.Feature) []string {
+	var replaced []string
+	for _, feature := range enabled {
+		for _, name := range feature.Replaces {
+			old, ok := features.Find(all, name)
+			if !ok {
+				continue
+			}
+			for _, v := range old.Env {
+				if idx := doc.Export(v.Name); idx != -1 {
+					doc.CommentOut(idx)
+					replaced = append(replaced, fmt.Sprintf("%s (%s)", v.Name, name))
+				}
+			}
+		}
+	}
+
+	return replaced
+}
+
+func withoutName(names []string, name string) []string {
+	var kept []string
+	for _, n := range names {
+		if n != name {
+			kept = append(kept, n)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,314 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mindmorass/shell-profile-manager/internal/atomicfile"
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+	"github.com/mindmorass/shell-profile-manager/internal/templates"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// Built-in backup retention of 'profile gc'
+const (
+	defaultBackupKeep   = 5
+	defaultBackupMaxAge = 30
+)
+
+// staleTempAge is how old a temporary file of an atomic write must be to
+// be removed; a younger one may belong to a write in progress
+const staleTempAge = time.Hour
+
+type GcOptions struct {
+	// DryRun reports what would be removed without removing it
+	DryRun bool
+	// BackupKeep is how many of the newest backups of each profile are
+	// kept whatever their age (0: 5)
+	BackupKeep int
+	// BackupMaxAge is the age in days after which the other backups are
+	// removed (0: 30)
+	BackupMaxAge int
+}
+
+// collector removes what gc finds and tallies the space reclaimed
+type collector struct {
+	dryRun  bool
+	removed int
+	freed   int64
+}
+
+// remove removes a file or directory, describing it as what
+func (c *collector) remove(path, what string) error {
+	size, err := diskUsage(path)
+	if err != nil && !os.IsNotExist(err) {
+		return newIOError(err, "failed to read %s", path)
+	}
+	verb := "Removed"
+	if c.dryRun {
+		verb = "Would remove"
+	} else if err := os.RemoveAll(path); err != nil {
+		return newIOError(err, "failed to remove %s", path)
+	}
+	fmt.Printf("  %s %s (%s)\n", verb, what, formatFileSize(size))
+	c.removed++
+	c.freed += size
+	return nil
+}
+
+// CollectGarbage removes what spm leaves behind over time: backups past
+// the retention policy, stale profile locks, temporary files of atomic
+// writes that were interrupted, clones of template repositories that are
+// no longer registered, and the cache directory. It reports the space
+// reclaimed.
+func CollectGarbage(profilesDir string, opts GcOptions) error {
+	profiles, err := ListProfileNames(profilesDir)
+	if err != nil {
+		return err
+	}
+	stateDir, err := config.StateDir()
+	if err != nil {
+		return err
+	}
+	spmDir, err := config.SpmDir()
+	if err != nil {
+		return err
+	}
+	cacheDir, err := config.CacheDir()
+	if err != nil {
+		return err
+	}
+
+	c := &collector{dryRun: opts.DryRun}
+	keep, cutoff := backupRetention(opts)
+
+	for _, profileName := range profiles {
+		profileDir := profilePath(profilesDir, profileName)
+		if err := c.pruneBackups(profileName, profileDir, keep, cutoff); err != nil {
+			return err
+		}
+		if err := c.removeTempFiles(profileDir, profileName); err != nil {
+			return err
+		}
+	}
+	for _, dir := range []string{spmDir, stateDir} {
+		if err := c.removeTempFiles(dir, dir); err != nil {
+			return err
+		}
+	}
+	if err := c.removeStaleLocks(filepath.Join(stateDir, "locks")); err != nil {
+		return err
+	}
+	if err := c.removeOrphanedClones(filepath.Join(stateDir, "template-repos")); err != nil {
+		return err
+	}
+	if err := c.clearDir(cacheDir, "cache"); err != nil {
+		return err
+	}
+
+	switch {
+	case c.removed == 0:
+		ui.PrintInfo("Nothing to collect")
+		return nil
+	case opts.DryRun:
+		ui.PrintInfo(fmt.Sprintf("Would reclaim %s (dry run)", formatFileSize(c.freed)))
+		return nil
+	default:
+		ui.PrintSuccess(fmt.Sprintf("Reclaimed %s", formatFileSize(c.freed)))
+		return changesApplied()
+	}
+}
+
+// backupRetention returns how many backups to keep whatever their age, and
+// the time before which the others are removed
+func backupRetention(opts GcOptions) (int, time.Time) {
+	keep := opts.BackupKeep
+	if keep <= 0 {
+		keep = defaultBackupKeep
+	}
+	maxAge := opts.BackupMaxAge
+	if maxAge <= 0 {
+		maxAge = defaultBackupMaxAge
+	}
+	return keep, time.Now().AddDate(0, 0, -maxAge)
+}
+
+// pruneBackups removes the backups of a profile beyond the keep newest
+// that were made before cutoff. A profile another process is changing is
+// skipped, since update may be backing it up.
+func (c *collector) pruneBackups(profileName, profileDir string, keep int, cutoff time.Time) error {
+	sets, err := listBackups(profileDir)
+	if err != nil {
+		return newIOError(err, "failed to list the backups of %s", profileName)
+	}
+	if len(sets) <= keep {
+		return nil
+	}
+	if !c.dryRun {
+		unlock, err := lockProfile(profileDir)
+		var lockErr *LockError
+		if errors.As(err, &lockErr) {
+			ui.PrintWarning(fmt.Sprintf("Skipping the backups of %s: another spm process is running on it", profileName))
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	for _, set := range sets[keep:] {
+		made, ok := backupTime(set)
+		if !ok || !made.Before(cutoff) {
+			continue
+		}
+		if err := c.remove(set.Path, fmt.Sprintf("backup %s of %s", set, profileName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backupTime returns when a backup set was made, from its name
+func backupTime(set backupSet) (time.Time, bool) {
+	if len(set.Date) < len(backupDateFormat) {
+		return time.Time{}, false
+	}
+	made, err := time.ParseInLocation(backupDateFormat, set.Date[:len(backupDateFormat)], time.Local)
+	return made, err == nil
+}
+
+// removeTempFiles removes the temporary files of atomic writes under dir
+// that are too old to belong to a write in progress. Git repositories and
+// symbolic links are not followed.
+func (c *collector) removeTempFiles(dir, name string) error {
+	var stale []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !atomicfile.IsTemp(d.Name()) {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && time.Since(info.ModTime()) > staleTempAge {
+			stale = append(stale, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return newIOError(err, "failed to scan %s", dir)
+	}
+	for _, path := range stale {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			rel = path
+		}
+		if err := c.remove(path, fmt.Sprintf("temporary file %s in %s", filepath.ToSlash(rel), name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeStaleLocks removes the lock files no process holds, such as those
+// of processes that ended without removing them. Each is locked while it
+// is removed, so a process taking it meanwhile is not left holding a lock
+// on a file that no longer exists.
+func (c *collector) removeStaleLocks(locksDir string) error {
+	entries, err := os.ReadDir(locksDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return newIOError(err, "failed to read %s", locksDir)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".lock") {
+			continue
+		}
+		path := filepath.Join(locksDir, entry.Name())
+		if err := c.removeStaleLock(path, entry.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeStaleLock removes a lock file unless a process holds it
+func (c *collector) removeStaleLock(path, name string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	if locked, err := lockFile(f); err != nil || !locked || !lockedFileAt(f, path) {
+		return nil
+	}
+
+	what := "stale lock " + name
+	if owner := readLockOwner(path); owner.PID != 0 {
+		what += fmt.Sprintf(" (pid %d)", owner.PID)
+	}
+	return c.remove(path, what)
+}
+
+// removeOrphanedClones removes the clones of template repositories that
+// are no longer registered, e.g. after template-repos.yaml was edited or
+// synced from another machine
+func (c *collector) removeOrphanedClones(reposDir string) error {
+	entries, err := os.ReadDir(reposDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return newIOError(err, "failed to read %s", reposDir)
+	}
+	repos, err := templates.LoadRepos()
+	if err != nil {
+		return err
+	}
+	registered := make(map[string]bool)
+	for _, repo := range repos {
+		registered[repo.Name] = true
+	}
+	for _, entry := range entries {
+		if registered[entry.Name()] {
+			continue
+		}
+		if err := c.remove(filepath.Join(reposDir, entry.Name()), "template repository clone "+entry.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearDir removes what dir holds, keeping the directory
+func (c *collector) clearDir(dir, name string) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return newIOError(err, "failed to read %s", dir)
+	}
+	for _, entry := range entries {
+		if err := c.remove(filepath.Join(dir, entry.Name()), fmt.Sprintf("%s %s", name, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
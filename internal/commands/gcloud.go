@@ -0,0 +1,156 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type GcloudOptions struct {
+	ProfileName string
+	// ConfigName is the gcloud named configuration (defaults to the
+	// workspace profile name)
+	ConfigName string
+	Project    string
+	Account    string
+	Region     string
+	Zone       string
+}
+
+// SetupGcloud creates a gcloud named configuration inside the profile's
+// CLOUDSDK_CONFIG directory and makes it the active configuration
+func SetupGcloud(profilesDir string, opts GcloudOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile to set up gcloud for:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if opts.ConfigName == "" {
+		opts.ConfigName = baseProfileName(profileName)
+	}
+	if opts.Project == "" {
+		if opts.Project, err = ui.Input("Project ID:", ""); err != nil {
+			return err
+		}
+	}
+	if opts.Account == "" {
+		if opts.Account, err = ui.Input("Account (email, optional):", ""); err != nil {
+			return err
+		}
+	}
+	if opts.Region == "" {
+		if opts.Region, err = ui.Input("Default compute region (optional):", ""); err != nil {
+			return err
+		}
+	}
+	if opts.Project == "" {
+		return NewValidationError("--project is required")
+	}
+
+	gcloudDir := filepath.Join(profileDir, ".gcloud")
+	configsDir := filepath.Join(gcloudDir, "configurations")
+	if err := os.MkdirAll(configsDir, 0700); err != nil {
+		return newIOError(err, "failed to create gcloud configurations directory")
+	}
+
+	configPath := filepath.Join(configsDir, "config_"+opts.ConfigName)
+	content, err := os.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return newIOError(err, "failed to read gcloud configuration")
+	}
+
+	core := []string{fmt.Sprintf("project = %s", opts.Project)}
+	if opts.Account != "" {
+		core = append(core, fmt.Sprintf("account = %s", opts.Account))
+	}
+	config := mergeINISection(string(content), "[core]", core)
+
+	var compute []string
+	if opts.Region != "" {
+		compute = append(compute, fmt.Sprintf("region = %s", opts.Region))
+	}
+	if opts.Zone != "" {
+		compute = append(compute, fmt.Sprintf("zone = %s", opts.Zone))
+	}
+	if len(compute) > 0 {
+		config = mergeINISection(config, "[compute]", compute)
+	}
+
+	if err := writeProfileFile(configPath, []byte(config), 0600); err != nil {
+		return newIOError(err, "failed to write gcloud configuration")
+	}
+	if err := writeProfileFile(filepath.Join(gcloudDir, "active_config"), []byte(opts.ConfigName), 0600); err != nil {
+		return newIOError(err, "failed to activate gcloud configuration")
+	}
+	ui.PrintSuccess(fmt.Sprintf("Created gcloud configuration '%s' in %s", opts.ConfigName, gcloudDir))
+
+	block := "# gcloud configuration used in this workspace\n" + managedExport("CLOUDSDK_ACTIVE_CONFIG_NAME", opts.ConfigName)
+	if _, err := setManagedBlock(profileDir, "gcloud", block); err != nil {
+		return newIOError(err, "failed to update .envrc")
+	}
+	ui.PrintSuccess(fmt.Sprintf("CLOUDSDK_ACTIVE_CONFIG_NAME=%s exported in .envrc", opts.ConfigName))
+
+	fmt.Println()
+	if err := verifyGcloud(gcloudDir, opts.ConfigName, opts.Project); err != nil {
+		ui.PrintWarning(err.Error())
+	}
+	if opts.Account != "" {
+		fmt.Printf("  Sign in from the workspace with: gcloud auth login %s\n", opts.Account)
+	}
+
+	return changesApplied()
+}
+
+// CheckGcloud verifies that gcloud resolves the profile's configuration
+func CheckGcloud(profilesDir string, opts GcloudOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile to check:")
+	if err != nil {
+		return err
+	}
+
+	gcloudDir := filepath.Join(profileDir, ".gcloud")
+	configName := opts.ConfigName
+	if configName == "" {
+		active, err := os.ReadFile(filepath.Join(gcloudDir, "active_config"))
+		if err != nil {
+			return NewValidationError("profile '%s' has no gcloud configuration; run 'profile gcloud setup %s'", profileName, profileName)
+		}
+		configName = strings.TrimSpace(string(active))
+	}
+
+	return verifyGcloud(gcloudDir, configName, "")
+}
+
+// verifyGcloud runs gcloud with the profile's environment and checks that
+// it reports the expected configuration (and project, if given)
+func verifyGcloud(gcloudDir, configName, project string) error {
+	if _, err := exec.LookPath("gcloud"); err != nil {
+		return fmt.Errorf("gcloud is not installed; cannot verify the configuration")
+	}
+
+	cmd := exec.Command("gcloud", "config", "list", "--format=value(core.project)")
+	cmd.Env = append(os.Environ(),
+		"CLOUDSDK_CONFIG="+gcloudDir,
+		"CLOUDSDK_ACTIVE_CONFIG_NAME="+configName,
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("gcloud could not read configuration '%s': %v", configName, err)
+	}
+
+	got := strings.TrimSpace(string(output))
+	if project != "" && got != project {
+		return fmt.Errorf("gcloud reports project %q, expected %q", got, project)
+	}
+	ui.PrintSuccess(fmt.Sprintf("gcloud uses configuration '%s' (project: %s)", configName, valueOr(got, "unset")))
+	return nil
+}
@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type GHOptions struct {
+	ProfileName string
+	// Hostname selects a GitHub Enterprise host instead of github.com
+	Hostname string
+	// Web authenticates through the browser instead of prompting
+	Web bool
+}
+
+// LoginGH runs 'gh auth login' against the profile's GH_CONFIG_DIR so the
+// token is stored in the profile
+func LoginGH(profilesDir string, opts GHOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	if _, err := exec.LookPath("gh"); err != nil {
+		return NewValidationError("GitHub CLI is not installed (https://cli.github.com)")
+	}
+	if err := ensureFeature(profilesDir, profileName, profileDir, "github"); err != nil {
+		return err
+	}
+
+	args := []string{"auth", "login"}
+	if opts.Hostname != "" {
+		args = append(args, "--hostname", opts.Hostname)
+	}
+	if opts.Web {
+		args = append(args, "--web")
+	}
+
+	ui.PrintInfo(fmt.Sprintf("Logging in to GitHub for profile: %s", profileName))
+	if err := runGH(profileDir, args...); err != nil {
+		return fmt.Errorf("gh auth login failed: %w", err)
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("gh is authenticated for profile: %s", profileName))
+	return changesApplied()
+}
+
+// GHStatus runs 'gh auth status' against the profile's GH_CONFIG_DIR
+func GHStatus(profilesDir string, opts GHOptions) error {
+	_, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	if _, err := exec.LookPath("gh"); err != nil {
+		return NewValidationError("GitHub CLI is not installed (https://cli.github.com)")
+	}
+
+	args := []string{"auth", "status"}
+	if opts.Hostname != "" {
+		args = append(args, "--hostname", opts.Hostname)
+	}
+	return runGH(profileDir, args...)
+}
+
+// runGH runs gh interactively with the profile's config directory
+func runGH(profileDir string, args ...string) error {
+	cmd := exec.Command("gh", args...)
+	cmd.Dir = profileDir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "GH_CONFIG_DIR="+filepath.Join(profileDir, ".config", "gh"))
+	return cmd.Run()
+}
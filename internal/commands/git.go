@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
 	"github.com/mindmorass/shell-profile-manager/internal/ui"
 )
 
@@ -14,15 +15,35 @@ type GitOptions struct {
 	ProfileName string
 	Remote      string
 	Force       bool
+	// All runs pull, push, or sync for every profile
+	All bool
+	// Tags runs pull, push, or sync for the profiles tagged with every one
+	// of these
+	Tags []string
+	// Jobs is how many profiles --all and --tag process at once (0: the
+	// default)
+	Jobs int
+}
+
+// gitActions are the sync commands that can run over every profile with
+// --all, and the verb shown while each profile is processed
+var gitActions = map[string]struct {
+	verb string
+	run  func(profilesDir string, opts GitOptions) error
+}{
+	"pull": {"Pulling", PullGit},
+	"push": {"Pushing", PushGit},
+	"sync": {"Syncing", SyncGit},
 }
 
 // InitGit initializes a git repository in the profile directory
 func InitGit(profilesDir string, opts GitOptions) error {
-	profileDir := filepath.Join(profilesDir, opts.ProfileName)
+	opts.ProfileName = qualifiedName(profilesDir, opts.ProfileName)
+	profileDir := profilePath(profilesDir, opts.ProfileName)
 
 	// Check if profile exists
 	if _, err := os.Stat(profileDir); os.IsNotExist(err) {
-		return fmt.Errorf("profile '%s' does not exist at: %s", opts.ProfileName, profileDir)
+		return NewValidationError("profile '%s' does not exist at: %s", opts.ProfileName, profileDir)
 	}
 
 	// Check if already a git repo
@@ -37,13 +58,20 @@ func InitGit(profilesDir string, opts GitOptions) error {
 	// Initialize git repository
 	cmd := exec.Command("git", "init")
 	cmd.Dir = profileDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = ui.Stdout()
+	cmd.Stderr = ui.Stderr()
 
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to initialize git repository: %w", err)
 	}
 
+	// Record the remote before the initial commit so it is committed too
+	if opts.Remote != "" {
+		if err := recordRemote(profileDir, "origin", opts.Remote); err != nil {
+			return err
+		}
+	}
+
 	// Create initial commit if there are files
 	cmd = exec.Command("git", "add", ".")
 	cmd.Dir = profileDir
@@ -75,17 +103,18 @@ func InitGit(profilesDir string, opts GitOptions) error {
 
 // PullGit pulls changes from the remote repository
 func PullGit(profilesDir string, opts GitOptions) error {
-	profileDir := filepath.Join(profilesDir, opts.ProfileName)
+	opts.ProfileName = qualifiedName(profilesDir, opts.ProfileName)
+	profileDir := profilePath(profilesDir, opts.ProfileName)
 
 	// Check if profile exists
 	if _, err := os.Stat(profileDir); os.IsNotExist(err) {
-		return fmt.Errorf("profile '%s' does not exist at: %s", opts.ProfileName, profileDir)
+		return NewValidationError("profile '%s' does not exist at: %s", opts.ProfileName, profileDir)
 	}
 
 	// Check if it's a git repo
 	gitDir := filepath.Join(profileDir, ".git")
 	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
-		return fmt.Errorf("profile '%s' is not a git repository (run 'profile git init %s' first)", opts.ProfileName, opts.ProfileName)
+		return NewValidationError("profile '%s' is not a git repository (run 'profile git init %s' first)", opts.ProfileName, opts.ProfileName)
 	}
 
 	ui.PrintInfo(fmt.Sprintf("Pulling changes for profile: %s", opts.ProfileName))
@@ -94,21 +123,21 @@ func PullGit(profilesDir string, opts GitOptions) error {
 	cmd := exec.Command("git", "remote", "get-url", "origin")
 	cmd.Dir = profileDir
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("no remote 'origin' configured (add with 'profile git remote %s <url>')", opts.ProfileName)
+		return NewValidationError("no remote 'origin' configured (add with 'profile git remote %s <url>')", opts.ProfileName)
 	}
 
 	// Pull changes
 	cmd = exec.Command("git", "pull", "origin", "main")
 	cmd.Dir = profileDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = ui.Stdout()
+	cmd.Stderr = ui.Stderr()
 
 	// Try main branch first, then master
 	if err := cmd.Run(); err != nil {
 		cmd = exec.Command("git", "pull", "origin", "master")
 		cmd.Dir = profileDir
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		cmd.Stdout = ui.Stdout()
+		cmd.Stderr = ui.Stderr()
 		if err := cmd.Run(); err != nil {
 			return fmt.Errorf("failed to pull changes: %w", err)
 		}
@@ -120,17 +149,18 @@ func PullGit(profilesDir string, opts GitOptions) error {
 
 // PushGit pushes local changes to the remote repository
 func PushGit(profilesDir string, opts GitOptions) error {
-	profileDir := filepath.Join(profilesDir, opts.ProfileName)
+	opts.ProfileName = qualifiedName(profilesDir, opts.ProfileName)
+	profileDir := profilePath(profilesDir, opts.ProfileName)
 
 	// Check if profile exists
 	if _, err := os.Stat(profileDir); os.IsNotExist(err) {
-		return fmt.Errorf("profile '%s' does not exist at: %s", opts.ProfileName, profileDir)
+		return NewValidationError("profile '%s' does not exist at: %s", opts.ProfileName, profileDir)
 	}
 
 	// Check if it's a git repo
 	gitDir := filepath.Join(profileDir, ".git")
 	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
-		return fmt.Errorf("profile '%s' is not a git repository (run 'profile git init %s' first)", opts.ProfileName, opts.ProfileName)
+		return NewValidationError("profile '%s' is not a git repository (run 'profile git init %s' first)", opts.ProfileName, opts.ProfileName)
 	}
 
 	ui.PrintInfo(fmt.Sprintf("Pushing changes for profile: %s", opts.ProfileName))
@@ -139,7 +169,7 @@ func PushGit(profilesDir string, opts GitOptions) error {
 	cmd := exec.Command("git", "remote", "get-url", "origin")
 	cmd.Dir = profileDir
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("no remote 'origin' configured (add with 'profile git remote %s <url>')", opts.ProfileName)
+		return NewValidationError("no remote 'origin' configured (add with 'profile git remote %s <url>')", opts.ProfileName)
 	}
 
 	// Check for uncommitted changes
@@ -188,8 +218,8 @@ func PushGit(profilesDir string, opts GitOptions) error {
 
 	cmd = exec.Command("git", pushArgs...)
 	cmd.Dir = profileDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = ui.Stdout()
+	cmd.Stderr = ui.Stderr()
 
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to push changes: %w", err)
@@ -225,24 +255,67 @@ func SyncGit(profilesDir string, opts GitOptions) error {
 	return nil
 }
 
+// SyncAllProfiles runs a sync command (pull, push, or sync) for every
+// profile that is a git repository, or those with the tags in opts.Tags,
+// with a progress line per profile and the failures reported at the end
+func SyncAllProfiles(profilesDir, command string, opts GitOptions) error {
+	action, ok := gitActions[command]
+	if !ok {
+		return NewValidationError("--all and --tag are supported by pull, push, and sync (not %s)", command)
+	}
+	if opts.ProfileName != "" {
+		return NewValidationError("--all and --tag run for %s; do not also name one", selectionTarget(opts.Tags))
+	}
+
+	profiles, err := taggedProfiles(profilesDir, opts.Tags)
+	if err != nil {
+		return err
+	}
+	if len(profiles) == 0 {
+		ui.PrintInfo("No profiles found")
+		return nil
+	}
+
+	progress := ui.NewProgress(action.verb, len(profiles))
+	progress.RunEach(profiles, parallelJobs(opts.Jobs), func(profileName string) (string, error) {
+		if _, err := os.Stat(filepath.Join(profilePath(profilesDir, profileName), ".git")); err != nil {
+			return "", ui.Skipped("not a git repository")
+		}
+		profileOpts := opts
+		profileOpts.ProfileName, profileOpts.All, profileOpts.Tags = profileName, false, nil
+		return "", action.run(profilesDir, profileOpts)
+	})
+
+	if failed := progress.Finish(); failed > 0 {
+		return fmt.Errorf("%d of %d profile(s) failed to %s", failed, len(profiles), command)
+	}
+	return nil
+}
+
 // SetRemote sets or updates the git remote for a profile
 func SetRemote(profilesDir string, opts GitOptions) error {
-	profileDir := filepath.Join(profilesDir, opts.ProfileName)
+	opts.ProfileName = qualifiedName(profilesDir, opts.ProfileName)
+	profileDir := profilePath(profilesDir, opts.ProfileName)
 
 	// Check if profile exists
 	if _, err := os.Stat(profileDir); os.IsNotExist(err) {
-		return fmt.Errorf("profile '%s' does not exist at: %s", opts.ProfileName, profileDir)
+		return NewValidationError("profile '%s' does not exist at: %s", opts.ProfileName, profileDir)
 	}
 
 	// Check if it's a git repo
 	gitDir := filepath.Join(profileDir, ".git")
 	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
-		return fmt.Errorf("profile '%s' is not a git repository (run 'profile git init %s' first)", opts.ProfileName, opts.ProfileName)
+		return NewValidationError("profile '%s' is not a git repository (run 'profile git init %s' first)", opts.ProfileName, opts.ProfileName)
 	}
 
 	if opts.Remote == "" {
-		return fmt.Errorf("remote URL is required")
+		return NewValidationError("remote URL is required")
 	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	ui.PrintInfo(fmt.Sprintf("Setting remote for profile: %s", opts.ProfileName))
 
@@ -267,72 +340,103 @@ func SetRemote(profilesDir string, opts GitOptions) error {
 		ui.PrintSuccess(fmt.Sprintf("Added remote: %s", opts.Remote))
 	}
 
+	return recordRemote(profileDir, "origin", opts.Remote)
+}
+
+// recordRemote saves the URL of a git remote in the profile manifest
+func recordRemote(profileDir, name, url string) error {
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return err
+	}
+	m.SetRemote(name, url)
+	if err := manifest.Save(profileDir, m); err != nil {
+		return newIOError(err, "failed to record remote in %s", manifest.FileName)
+	}
 	return nil
 }
 
+// gitRemotes returns the remotes configured in the profile repository
+func gitRemotes(profileDir string) []manifest.Remote {
+	cmd := exec.Command("git", "config", "--get-regexp", `^remote\..*\.url$`)
+	cmd.Dir = profileDir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+	var remotes []manifest.Remote
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		key, url, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(key, "remote."), ".url")
+		remotes = append(remotes, manifest.Remote{Name: name, URL: url})
+	}
+	return remotes
+}
+
 // GetGitStatus shows the git status of a profile (or all profiles if no name provided)
 func GetGitStatus(profilesDir string, opts GitOptions) error {
 	// If no profile name, show status for all profiles
 	if opts.ProfileName == "" {
-		entries, err := os.ReadDir(profilesDir)
+		profiles, err := ListProfileNames(profilesDir)
 		if err != nil {
-			return fmt.Errorf("failed to read profiles directory: %w", err)
+			return err
 		}
 
-		fmt.Printf("%s=== Git Status for All Profiles ===%s\n", ui.ColorBlue, ui.ColorReset)
-		fmt.Println()
-
-		foundAny := false
-		for _, entry := range entries {
-			if !entry.IsDir() || entry.Name() == ".git" {
-				continue
-			}
-
-			profileDir := filepath.Join(profilesDir, entry.Name())
+		table := ui.NewTable("PROFILE", "BRANCH", "CHANGES", "REMOTE").Truncate(3, 60)
+		for _, profileName := range profiles {
+			profileDir := profilePath(profilesDir, profileName)
 			gitDir := filepath.Join(profileDir, ".git")
 			if _, err := os.Stat(gitDir); os.IsNotExist(err) {
 				continue
 			}
 
-			foundAny = true
-			fmt.Printf("%s=== %s ===%s\n", ui.ColorBlue, entry.Name(), ui.ColorReset)
-
-			// Show git status for this profile
+			// Count changed files for this profile
+			changes := fmt.Sprintf("%s✗ error getting status%s", ui.ColorRed, ui.ColorReset)
 			cmd := exec.Command("git", "status", "--short")
 			cmd.Dir = profileDir
-			output, statusErr := cmd.Output()
-			if statusErr == nil {
-				if len(output) > 0 {
-					fmt.Print(string(output))
+			if output, err := cmd.Output(); err == nil {
+				if status := strings.TrimRight(string(output), "\n"); status == "" {
+					changes = fmt.Sprintf("%s✓ clean%s", ui.ColorGreen, ui.ColorReset)
 				} else {
-					fmt.Println("  (no changes)")
+					changes = fmt.Sprintf("%s⚠ %d changed%s", ui.ColorYellow, len(strings.Split(status, "\n")), ui.ColorReset)
 				}
-			} else {
-				fmt.Println("  (error getting status)")
 			}
 
-			// Show remote
+			branch := "-"
+			cmd = exec.Command("git", "branch", "--show-current")
+			cmd.Dir = profileDir
+			if output, err := cmd.Output(); err == nil {
+				branch = valueOr(strings.TrimSpace(string(output)), "-")
+			}
+
+			remote := "(none)"
 			cmd = exec.Command("git", "remote", "get-url", "origin")
 			cmd.Dir = profileDir
-			if remoteOutput, err := cmd.Output(); err == nil {
-				fmt.Printf("  Remote: %s", string(remoteOutput))
-			} else {
-				fmt.Println("  Remote: (none)")
+			if output, err := cmd.Output(); err == nil {
+				remote = strings.TrimSpace(string(output))
 			}
-			fmt.Println()
+			table.AddRow(profileName, branch, changes, remote)
 		}
 
-		if !foundAny {
+		if table.Len() == 0 {
 			fmt.Println("No profiles with git repositories found")
+			return nil
 		}
+		fmt.Printf("%s=== Git Status for All Profiles ===%s\n", ui.ColorBlue, ui.ColorReset)
+		fmt.Println()
+		table.Render(os.Stdout)
 		return nil
 	}
 
-	profileDir := filepath.Join(profilesDir, opts.ProfileName)
+	opts.ProfileName = qualifiedName(profilesDir, opts.ProfileName)
+	profileDir := profilePath(profilesDir, opts.ProfileName)
 
 	// Check if profile exists
 	if _, err := os.Stat(profileDir); os.IsNotExist(err) {
-		return fmt.Errorf("profile '%s' does not exist at: %s", opts.ProfileName, profileDir)
+		return NewValidationError("profile '%s' does not exist at: %s", opts.ProfileName, profileDir)
 	}
 
 	// Check if it's a git repo
@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type GoOptions struct {
+	ProfileName string
+	// Private module path patterns (GOPRIVATE), e.g. github.com/acme/*
+	Private []string
+	// NoSumDB patterns skip the checksum database only (GONOSUMDB)
+	NoSumDB []string
+	// Clear removes all patterns before adding the new ones
+	Clear bool
+}
+
+// SetGoPrivate records private module patterns in the profile manifest and
+// exports them from the profile's .envrc, enabling the go feature if needed
+func SetGoPrivate(profilesDir string, opts GoOptions) error {
+	if len(opts.Private) == 0 && len(opts.NoSumDB) == 0 && !opts.Clear {
+		return NewValidationError("at least one --module or --nosumdb pattern is required (or --clear)")
+	}
+
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := ensureFeature(profilesDir, profileName, profileDir, "go"); err != nil {
+		return err
+	}
+
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return err
+	}
+	if m.Go == nil || opts.Clear {
+		m.Go = &manifest.GoConfig{}
+	}
+	for _, pattern := range opts.Private {
+		m.Go.Private = append(withoutName(m.Go.Private, pattern), pattern)
+	}
+	for _, pattern := range opts.NoSumDB {
+		m.Go.NoSumDB = append(withoutName(m.Go.NoSumDB, pattern), pattern)
+	}
+	if len(m.Go.Private) == 0 && len(m.Go.NoSumDB) == 0 {
+		m.Go = nil
+	}
+
+	if err := manifest.Save(profileDir, m); err != nil {
+		return newIOError(err, "failed to save profile manifest")
+	}
+	if _, err := setManagedBlock(profileDir, "go", goEnvBlock(m.Go)); err != nil {
+		return newIOError(err, "failed to update .envrc")
+	}
+
+	if m.Go == nil {
+		ui.PrintSuccess(fmt.Sprintf("Cleared private Go modules for %s", profileName))
+		return changesApplied()
+	}
+	ui.PrintSuccess(fmt.Sprintf("Updated private Go modules for %s", profileName))
+	if len(m.Go.Private) > 0 {
+		fmt.Printf("  GOPRIVATE=%s\n", strings.Join(m.Go.Private, ","))
+	}
+	if len(m.Go.NoSumDB) > 0 {
+		fmt.Printf("  GONOSUMDB=%s\n", strings.Join(m.Go.NoSumDB, ","))
+	}
+	return changesApplied()
+}
+
+// goEnvBlock renders the managed .envrc block for the manifest's Go settings
+func goEnvBlock(cfg *manifest.GoConfig) string {
+	if cfg == nil {
+		return ""
+	}
+
+	lines := []string{"# Private Go modules for this workspace (profile go private)"}
+	if len(cfg.Private) > 0 {
+		lines = append(lines, managedExport("GOPRIVATE", strings.Join(cfg.Private, ",")))
+	}
+	if len(cfg.NoSumDB) > 0 {
+		lines = append(lines, managedExport("GONOSUMDB", strings.Join(cfg.NoSumDB, ",")))
+	}
+	return strings.Join(lines, "\n")
+}
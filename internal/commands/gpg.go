@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type GPGOptions struct {
+	ProfileName string
+	// Name and Email default to user.name and user.email in the profile's .gitconfig
+	Name  string
+	Email string
+	// Algo and Expire are passed to 'gpg --quick-generate-key'
+	Algo   string
+	Expire string
+	// NoSign skips turning on commit and tag signing
+	NoSign bool
+}
+
+// GenerateGPGKey creates a signing key in the profile's GNUPGHOME and sets
+// it as the signing key in the profile's .gitconfig
+func GenerateGPGKey(profilesDir string, opts GPGOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	gitconfigPath := filepath.Join(profileDir, ".gitconfig")
+	name := valueOr(opts.Name, getGitConfig(gitconfigPath, "user.name"))
+	email := valueOr(opts.Email, getGitConfig(gitconfigPath, "user.email"))
+	if name == "" || email == "" {
+		return NewValidationError("--name and --email are required (or set user.name and user.email in %s)", gitconfigPath)
+	}
+
+	if _, err := exec.LookPath("gpg"); err != nil {
+		return NewValidationError("gpg is not installed (macOS: brew install gnupg)")
+	}
+
+	if err := ensureFeature(profilesDir, profileName, profileDir, "gpg"); err != nil {
+		return err
+	}
+	gnupgHome := filepath.Join(profileDir, ".gnupg")
+	if err := os.MkdirAll(gnupgHome, 0700); err != nil {
+		return newIOError(err, "failed to create %s", gnupgHome)
+	}
+
+	uid := fmt.Sprintf("%s <%s>", name, email)
+	ui.PrintInfo(fmt.Sprintf("Generating signing key for %s in %s", uid, gnupgHome))
+
+	cmd := exec.Command("gpg", "--quick-generate-key", uid, valueOr(opts.Algo, "ed25519"), "sign", valueOr(opts.Expire, "2y"))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "GNUPGHOME="+gnupgHome)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("gpg key generation failed: %w", err)
+	}
+
+	fingerprint, err := newestSecretKey(gnupgHome, email)
+	if err != nil {
+		return err
+	}
+
+	settings := [][2]string{{"user.signingkey", fingerprint}}
+	if !opts.NoSign {
+		settings = append(settings, [2]string{"commit.gpgsign", "true"}, [2]string{"tag.gpgsign", "true"})
+	}
+	for _, setting := range settings {
+		cmd := exec.Command("git", "config", "--file", gitconfigPath, setting[0], setting[1])
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to set %s in .gitconfig: %w: %s", setting[0], err, strings.TrimSpace(string(output)))
+		}
+	}
+	recordChecksums(profileDir, ".gitconfig")
+
+	ui.PrintSuccess(fmt.Sprintf("Created signing key %s for profile: %s", fingerprint, profileName))
+	if !opts.NoSign {
+		fmt.Println("  Commits and tags in this profile are now signed")
+	}
+	fmt.Printf("  Export the public key for your git host: gpg --armor --export %s\n", fingerprint)
+	return changesApplied()
+}
+
+// newestSecretKey returns the fingerprint of the most recently listed
+// secret key matching email
+func newestSecretKey(gnupgHome, email string) (string, error) {
+	cmd := exec.Command("gpg", "--list-secret-keys", "--with-colons", "<"+email+">")
+	cmd.Env = append(os.Environ(), "GNUPGHOME="+gnupgHome)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to list gpg keys: %w", err)
+	}
+
+	// The fpr record following each sec record is the primary key's fingerprint
+	var fingerprint string
+	inPrimary := false
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		switch {
+		case fields[0] == "sec":
+			inPrimary = true
+		case fields[0] == "fpr" && inPrimary && len(fields) > 9:
+			fingerprint = fields[9]
+			inPrimary = false
+		}
+	}
+
+	if fingerprint == "" {
+		return "", fmt.Errorf("no secret key found for %s", email)
+	}
+	return fingerprint, nil
+}
@@ -0,0 +1,334 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/atomicfile"
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+	"github.com/mindmorass/shell-profile-manager/internal/secretscan"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// guardHooks are the git hooks 'guard install' adds by default
+var guardHooks = []string{"pre-commit", "pre-push"}
+
+// guardMarker identifies the hooks guard installed, which it may replace
+// and remove
+const guardMarker = "# Installed by 'profile guard install'"
+
+// zeroCommit is the object name git passes to pre-push for a ref that does
+// not exist on one side
+const zeroCommit = "0000000000000000000000000000000000000000"
+
+type GuardOptions struct {
+	ProfileName string
+	// Hooks are the hooks to install or remove (default: guardHooks)
+	Hooks []string
+	// Force replaces hooks that guard did not install
+	Force bool
+}
+
+// guardHookScript is the hook that runs the scanner of the spm binary that
+// installed it, or the one in PATH once that is gone
+func guardHookScript(hook, executable string) string {
+	args := "--pre-commit"
+	bypass := "git commit --no-verify"
+	if hook == "pre-push" {
+		args = `--pre-push "$@"`
+		bypass = "git push --no-verify"
+	}
+	return fmt.Sprintf(`#!/bin/sh
+%s: blocks changes that add credentials.
+# Bypass once with: %s
+spm=%s
+command -v "$spm" >/dev/null 2>&1 || spm=profile
+exec "$spm" guard scan %s
+`, guardMarker, bypass, shellQuote(executable), args)
+}
+
+// gitHooksDir returns the hooks directory of a profile that is a git
+// repository, honoring core.hooksPath
+func gitHooksDir(profileName, profileDir string) (string, error) {
+	if _, err := os.Stat(filepath.Join(profileDir, ".git")); err != nil {
+		return "", NewValidationError("profile '%s' is not a git repository (see 'profile sync init')", profileName)
+	}
+	dir, err := runGit(profileDir, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(profileDir, dir)
+	}
+	return dir, nil
+}
+
+// guardHookNames validates the hooks selected with --hook
+func guardHookNames(hooks []string) ([]string, error) {
+	if len(hooks) == 0 {
+		return guardHooks, nil
+	}
+	for _, hook := range hooks {
+		if hook != "pre-commit" && hook != "pre-push" {
+			return nil, NewValidationError("unknown hook: %s (use pre-commit or pre-push)", hook)
+		}
+	}
+	return hooks, nil
+}
+
+// isGuardHook reports whether the hook at path was installed by guard
+func isGuardHook(path string) bool {
+	content, err := os.ReadFile(path)
+	return err == nil && strings.Contains(string(content), guardMarker)
+}
+
+// InstallGuard adds git hooks to a profile repository that block commits
+// and pushes adding credentials
+func InstallGuard(profilesDir string, opts GuardOptions) error {
+	hooks, err := guardHookNames(opts.Hooks)
+	if err != nil {
+		return err
+	}
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	hooksDir, err := gitHooksDir(profileName, profileDir)
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range hooks {
+		path := filepath.Join(hooksDir, hook)
+		if _, err := os.Stat(path); err == nil && !opts.Force && !isGuardHook(path) {
+			return NewValidationError("%s already has a %s hook: %s (pass --force to replace it)", profileName, hook, path)
+		}
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		executable = "profile"
+	}
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		return newIOError(err, "failed to create %s", hooksDir)
+	}
+	for _, hook := range hooks {
+		path := filepath.Join(hooksDir, hook)
+		if _, err := os.Stat(path); err == nil && !isGuardHook(path) {
+			if err := os.Rename(path, path+".backup"); err != nil {
+				return newIOError(err, "failed to back up %s hook", hook)
+			}
+			ui.PrintWarning(fmt.Sprintf("Replaced the existing %s hook (kept as %s.backup)", hook, path))
+		}
+		if err := atomicfile.WriteFile(path, []byte(guardHookScript(hook, executable)), 0755); err != nil {
+			return newIOError(err, "failed to write %s hook", hook)
+		}
+		// An existing hook keeps its mode, which may not be executable
+		if err := os.Chmod(path, 0755); err != nil {
+			return newIOError(err, "failed to make %s hook executable", hook)
+		}
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Installed %s hook(s) for profile: %s", strings.Join(hooks, " and "), profileName))
+	fmt.Println("  Commits and pushes that add credentials are blocked")
+	fmt.Printf("  Mark a false positive with a %q comment on its line\n", secretscan.AllowMarker)
+	return changesApplied()
+}
+
+// UninstallGuard removes the hooks InstallGuard added; other hooks are left
+// in place
+func UninstallGuard(profilesDir string, opts GuardOptions) error {
+	hooks, err := guardHookNames(opts.Hooks)
+	if err != nil {
+		return err
+	}
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	hooksDir, err := gitHooksDir(profileName, profileDir)
+	if err != nil {
+		return err
+	}
+
+	var removed []string
+	for _, hook := range hooks {
+		path := filepath.Join(hooksDir, hook)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if !isGuardHook(path) {
+			ui.PrintWarning(fmt.Sprintf("Leaving %s in place: it was not installed by guard", path))
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return newIOError(err, "failed to remove %s hook", hook)
+		}
+		removed = append(removed, hook)
+	}
+
+	if len(removed) == 0 {
+		ui.PrintInfo(fmt.Sprintf("No guard hooks installed for profile: %s", profileName))
+		return nil
+	}
+	ui.PrintSuccess(fmt.Sprintf("Removed %s hook(s) from profile: %s", strings.Join(removed, " and "), profileName))
+	return changesApplied()
+}
+
+type GuardScanOptions struct {
+	// PrePush scans the commits being pushed, as listed on Input by git;
+	// otherwise the staged changes are scanned
+	PrePush bool
+	Input   io.Reader
+	// Rules are gitleaks configuration files loaded after those named by
+	// guard_rules in the config and the repository's .gitleaks.toml
+	Rules []string
+}
+
+// ScanGuard scans the repository in the current directory for credentials
+// about to be committed or pushed, as the hooks guard installs do. It
+// returns an error, failing the hook, when it finds any.
+func ScanGuard(opts GuardScanOptions) error {
+	scanner, err := guardScanner(opts.Rules)
+	if err != nil {
+		return err
+	}
+	findings := []secretscan.Finding{}
+	if opts.PrePush {
+		lines := bufio.NewScanner(opts.Input)
+		for lines.Scan() {
+			// <local ref> <local sha> <remote ref> <remote sha>
+			fields := strings.Fields(lines.Text())
+			if len(fields) != 4 || fields[1] == zeroCommit {
+				continue
+			}
+			found, err := scanCommits(scanner, fields[1], fields[3])
+			if err != nil {
+				return err
+			}
+			findings = append(findings, found...)
+		}
+		if err := lines.Err(); err != nil {
+			return fmt.Errorf("failed to read the refs being pushed: %w", err)
+		}
+	} else {
+		found, err := scanGitDiff(scanner, "diff", "--cached")
+		if err != nil {
+			return err
+		}
+		findings = append(findings, found...)
+	}
+
+	if ui.Structured() {
+		if err := ui.Render(findings); err != nil {
+			return err
+		}
+	}
+	if len(findings) == 0 {
+		return nil
+	}
+
+	if !ui.Structured() {
+		what := "Commit"
+		if opts.PrePush {
+			what = "Push"
+		}
+		ui.PrintError(fmt.Sprintf("%s blocked: possible credentials found", what))
+		for _, finding := range findings {
+			location := finding.Path
+			if finding.Line > 0 {
+				location = fmt.Sprintf("%s:%d", location, finding.Line)
+			}
+			if finding.Commit != "" {
+				location = fmt.Sprintf("%s (commit %.8s)", location, finding.Commit)
+			}
+			fmt.Fprintf(os.Stderr, "  %s: %s\n", location, finding.Rule)
+			fmt.Fprintf(os.Stderr, "      fingerprint: %s\n", finding.Fingerprint())
+		}
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Keep credentials out of the repository: gitignore the file, load the value with")
+		fmt.Fprintln(os.Stderr, "'profile secrets', or encrypt the file with 'profile encrypt'.")
+		fmt.Fprintf(os.Stderr, "Mark a false positive with a %q comment on its line or add its fingerprint to\n", secretscan.AllowMarker)
+		fmt.Fprintf(os.Stderr, "%s, or bypass the hook once with --no-verify.\n", secretscan.IgnoreFileName)
+	}
+	return fmt.Errorf("%d possible credential(s) found", len(findings))
+}
+
+// scanCommits scans the commits a push adds to remote, which is zeroCommit
+// for a new branch. Those are the commits not on any remote branch when the
+// remote commit is not known locally, e.g. when force-pushing.
+func scanCommits(scanner *secretscan.Scanner, local, remote string) ([]secretscan.Finding, error) {
+	revisions := []string{local, "--not", "--remotes"}
+	if remote != zeroCommit {
+		if _, err := runGit(".", "cat-file", "-e", remote+"^{commit}"); err == nil {
+			revisions = []string{remote + ".." + local}
+		}
+	}
+	return scanGitDiff(scanner, append([]string{"log", "-p", "--format=commit %H"}, revisions...)...)
+}
+
+// scanGitDiff runs a git command printing a diff of the repository in the
+// current directory and scans the lines it adds
+func scanGitDiff(scanner *secretscan.Scanner, args ...string) ([]secretscan.Finding, error) {
+	gitArgs := append([]string{"-c", "core.quotePath=false"}, args[0])
+	gitArgs = append(gitArgs, "--no-color", "--no-ext-diff", "--no-prefix", "-U0", "--diff-filter=d")
+	gitArgs = append(gitArgs, args[1:]...)
+	cmd := exec.Command("git", gitArgs...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return scanner.ScanDiff(bytes.NewReader(output))
+}
+
+// guardScanner returns the scanner for the repository in the current
+// directory: the built-in rules, or those of the gitleaks configurations
+// named by guard_rules in the config, the repository's .gitleaks.toml, and
+// ruleFiles, in that order. Findings listed in the repository's
+// .gitleaksignore are accepted.
+func guardScanner(ruleFiles []string) (*secretscan.Scanner, error) {
+	scanner := secretscan.New()
+	root, err := runGit(".", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	files := append([]string{}, cfg.GuardRules...)
+	if _, err := os.Stat(filepath.Join(root, secretscan.ConfigFileName)); err == nil {
+		files = append(files, filepath.Join(root, secretscan.ConfigFileName))
+	}
+	files = append(files, ruleFiles...)
+	for _, file := range files {
+		if err := scanner.LoadConfig(file); err != nil {
+			return nil, NewValidationError("%v", err)
+		}
+	}
+	if err := scanner.LoadIgnore(filepath.Join(root, secretscan.IgnoreFileName)); err != nil {
+		return nil, err
+	}
+	return scanner, nil
+}
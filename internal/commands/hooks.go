@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+	"github.com/mindmorass/shell-profile-manager/internal/direnv"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// PostUpdateHookPath returns the location of the user's post-update hook.
+// The hook is any executable file; it is run from the profile directory
+// with the profile name and path as arguments.
+func PostUpdateHookPath() (string, error) {
+	spmDir, err := config.SpmDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(spmDir, "hooks", "post-update"), nil
+}
+
+// runPostUpdateHooks runs the opt-in steps after update has written changes.
+// Hook failures are reported as warnings: the profile itself was updated.
+func runPostUpdateHooks(profileDir, profileName string, opts UpdateOptions) {
+	if opts.AllowDirenv {
+		allowDirenv(profileDir)
+	}
+
+	if opts.NoHooks {
+		return
+	}
+
+	hookPath, err := PostUpdateHookPath()
+	if err != nil {
+		ui.PrintWarning(fmt.Sprintf("Failed to locate post-update hook: %v", err))
+		return
+	}
+
+	info, err := os.Stat(hookPath)
+	if err != nil || info.IsDir() {
+		return
+	}
+	if info.Mode().Perm()&0111 == 0 {
+		ui.PrintWarning(fmt.Sprintf("Skipping post-update hook (not executable): %s", hookPath))
+		return
+	}
+
+	ui.PrintInfo(fmt.Sprintf("Running post-update hook: %s", hookPath))
+	cmd := exec.Command(hookPath, profileName, profileDir)
+	cmd.Dir = profileDir
+	cmd.Stdout = ui.MessageOutput()
+	cmd.Stderr = ui.Stderr()
+	cmd.Env = append(os.Environ(),
+		"PROFILE_NAME="+profileName,
+		"PROFILE_DIR="+profileDir,
+	)
+	if err := cmd.Run(); err != nil {
+		ui.PrintWarning(fmt.Sprintf("Post-update hook failed: %v", err))
+	}
+}
+
+// allowDirenv runs 'direnv allow' for the profile if direnv is installed
+func allowDirenv(profileDir string) {
+	if !direnv.Installed() {
+		ui.PrintWarning("direnv is not installed; skipping 'direnv allow'")
+		return
+	}
+
+	if err := direnv.Allow(profileDir); err != nil {
+		ui.PrintWarning(fmt.Sprintf("Failed to allow direnv: %v", err))
+		fmt.Fprintf(ui.MessageOutput(), "  Run 'direnv allow %s' manually\n", profileDir)
+		return
+	}
+	ui.PrintSuccess("direnv allowed")
+}
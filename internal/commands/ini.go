@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"slices"
+	"strings"
+)
+
+// mergeINISection sets the given "key = value" lines in the section with
+// the given header (e.g. "[profile dev]") in INI-style content, appending
+// the section if it is missing. A key already in the section is replaced
+// where it stands, and a new key is added at the end of the section; every
+// other line of the section, comments included, is kept.
+func mergeINISection(content, header string, lines []string) string {
+	existing := strings.Split(content, "\n")
+	start := -1
+	for i, line := range existing {
+		if strings.TrimSpace(line) == header {
+			start = i
+			break
+		}
+	}
+	if start == -1 {
+		content = strings.TrimRight(content, "\n")
+		if content != "" {
+			content += "\n\n"
+		}
+		return content + header + "\n" + strings.Join(lines, "\n") + "\n"
+	}
+
+	end := start + 1
+	for end < len(existing) && !strings.HasPrefix(strings.TrimSpace(existing[end]), "[") {
+		end++
+	}
+	// New keys go after the last line of the section, before the blank
+	// line(s) separating it from the next one
+	for end > start+1 && strings.TrimSpace(existing[end-1]) == "" {
+		end--
+	}
+
+	section := existing[start+1 : end]
+	for _, line := range lines {
+		key := iniKey(line)
+		replaced := false
+		var merged []string
+		for _, current := range section {
+			if key == "" || iniKey(current) != key {
+				merged = append(merged, current)
+				continue
+			}
+			// A key set more than once is left set once
+			if !replaced {
+				merged = append(merged, line)
+				replaced = true
+			}
+		}
+		if !replaced {
+			merged = append(merged, line)
+		}
+		section = merged
+	}
+
+	result := append(append(slices.Clone(existing[:start+1]), section...), existing[end:]...)
+	return strings.Join(result, "\n")
+}
+
+// iniKey returns the key of a "key = value" line, or "" for a comment, a
+// blank line, or a line that sets nothing
+func iniKey(line string) string {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' || line[0] == ';' {
+		return ""
+	}
+	key, _, ok := strings.Cut(line, "=")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(key)
+}
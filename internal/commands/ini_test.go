@@ -0,0 +1,113 @@
+package commands
+
+import "testing"
+
+func TestMergeINISection(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		header  string
+		lines   []string
+		want    string
+	}{
+		{
+			name:    "empty file",
+			content: "",
+			header:  "[profile dev]",
+			lines:   []string{"region = us-east-1"},
+			want:    "[profile dev]\nregion = us-east-1\n",
+		},
+		{
+			name:    "missing section is appended after a blank line",
+			content: "[default]\nregion = eu-west-1\n\n\n",
+			header:  "[profile dev]",
+			lines:   []string{"region = us-east-1", "output = json"},
+			want:    "[default]\nregion = eu-west-1\n\n[profile dev]\nregion = us-east-1\noutput = json\n",
+		},
+		{
+			name:    "existing key is replaced where it stands",
+			content: "[profile dev]\nregion = eu-west-1\noutput = text\n",
+			header:  "[profile dev]",
+			lines:   []string{"region = us-east-1"},
+			want:    "[profile dev]\nregion = us-east-1\noutput = text\n",
+		},
+		{
+			name:    "keys and comments set by hand are kept",
+			content: "[profile dev]\n# assumed from ops\nrole_arn = arn:aws:iam::1:role/ops\nregion = eu-west-1\n",
+			header:  "[profile dev]",
+			lines:   []string{"region = us-east-1", "output = json"},
+			want:    "[profile dev]\n# assumed from ops\nrole_arn = arn:aws:iam::1:role/ops\nregion = us-east-1\noutput = json\n",
+		},
+		{
+			name:    "new key goes before the blank line ending the section",
+			content: "[profile dev]\nregion = eu-west-1\n\n[profile prod]\nregion = us-west-2\n",
+			header:  "[profile dev]",
+			lines:   []string{"output = json"},
+			want:    "[profile dev]\nregion = eu-west-1\noutput = json\n\n[profile prod]\nregion = us-west-2\n",
+		},
+		{
+			name:    "other sections are left alone",
+			content: "[profile dev]\nregion = eu-west-1\n[profile prod]\nregion = us-west-2\n",
+			header:  "[profile prod]",
+			lines:   []string{"region = ap-south-1"},
+			want:    "[profile dev]\nregion = eu-west-1\n[profile prod]\nregion = ap-south-1\n",
+		},
+		{
+			name:    "key set more than once is left set once",
+			content: "[core]\naccount = a@example.com\nproject = one\naccount = b@example.com\n",
+			header:  "[core]",
+			lines:   []string{"account = c@example.com"},
+			want:    "[core]\naccount = c@example.com\nproject = one\n",
+		},
+		{
+			name:    "keys match regardless of spacing",
+			content: "[defaults]\ngroup=rg-old\n",
+			header:  "[defaults]",
+			lines:   []string{"group = rg-new"},
+			want:    "[defaults]\ngroup = rg-new\n",
+		},
+		{
+			name:    "commented-out key is not replaced",
+			content: "[defaults]\n; location = westus\n",
+			header:  "[defaults]",
+			lines:   []string{"location = eastus"},
+			want:    "[defaults]\n; location = westus\nlocation = eastus\n",
+		},
+		{
+			name:    "indented header is found",
+			content: "  [core]\n  project = one\n",
+			header:  "[core]",
+			lines:   []string{"project = two"},
+			want:    "  [core]\nproject = two\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeINISection(tt.content, tt.header, tt.lines); got != tt.want {
+				t.Errorf("mergeINISection() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestINIKey(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"region = us-east-1", "region"},
+		{"  output=json", "output"},
+		{"url = https://example.com/?a=b", "url"},
+		{"# region = us-east-1", ""},
+		{"; region = us-east-1", ""},
+		{"", ""},
+		{"[profile dev]", ""},
+	}
+
+	for _, tt := range tests {
+		if got := iniKey(tt.line); got != tt.want {
+			t.Errorf("iniKey(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
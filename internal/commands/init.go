@@ -25,7 +25,11 @@ func InitConfig(opts InitOptions) error {
 		return err
 	}
 
-	if _, err := os.Stat(configPath); err == nil && !opts.Force {
+	if _, err := os.Stat(configPath); err == nil && !opts.Force && !ui.AssumeYes() {
+		if !ui.IsInteractive() {
+			return NewValidationError("configuration file already exists at %s (use --force or --yes to overwrite)", configPath)
+		}
+
 		ui.PrintWarning("Configuration file already exists")
 		fmt.Printf("  Location: %s\n", configPath)
 		fmt.Println()
@@ -65,16 +69,18 @@ func InitConfig(opts InitOptions) error {
 
 	// Create directories if they don't exist
 	if err := os.MkdirAll(opts.ProfilesDir, 0755); err != nil {
-		return fmt.Errorf("failed to create profiles directory: %w", err)
+		return newIOError(err, "failed to create profiles directory")
 	}
 
-	// Save config
-	cfg := &config.Config{
-		ProfilesDir: opts.ProfilesDir,
+	// Save config, keeping any other settings from an existing file
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		cfg = &config.Config{}
 	}
+	cfg.ProfilesDir = opts.ProfilesDir
 
 	if err := config.SaveConfig(cfg); err != nil {
-		return fmt.Errorf("failed to save config: %w", err)
+		return newIOError(err, "failed to save config")
 	}
 
 	ui.PrintSuccess("Profile manager initialized successfully")
@@ -87,10 +93,14 @@ func InitConfig(opts InitOptions) error {
 	fmt.Println("  2. Navigate to it: cd <profiles-dir>/my-profile")
 	fmt.Println("  3. Allow direnv: direnv allow")
 
-	return nil
+	return changesApplied()
 }
 
 func interactiveInit(opts *InitOptions) error {
+	if !ui.IsInteractive() {
+		return NewValidationError("--interactive requires a terminal; use --profiles-dir instead")
+	}
+
 	fmt.Println("Profile Manager Initialization")
 	fmt.Println()
 
@@ -0,0 +1,257 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type KubeOptions struct {
+	ProfileName string
+	Contexts    []string
+	// Source is the kubeconfig to import from (default: $KUBECONFIG or ~/.kube/config)
+	Source     string
+	SetCurrent bool
+}
+
+// kubeConfig is the subset of the kubeconfig format needed to move
+// contexts between files; unknown fields are carried through unchanged
+type kubeConfig struct {
+	APIVersion     string                 `yaml:"apiVersion"`
+	Kind           string                 `yaml:"kind"`
+	Clusters       []kubeNamed            `yaml:"clusters"`
+	Contexts       []kubeContext          `yaml:"contexts"`
+	Users          []kubeNamed            `yaml:"users"`
+	CurrentContext string                 `yaml:"current-context"`
+	Extra          map[string]interface{} `yaml:",inline"`
+}
+
+type kubeNamed struct {
+	Name  string                 `yaml:"name"`
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+type kubeContext struct {
+	Name    string `yaml:"name"`
+	Context struct {
+		Cluster   string                 `yaml:"cluster"`
+		User      string                 `yaml:"user"`
+		Namespace string                 `yaml:"namespace,omitempty"`
+		Extra     map[string]interface{} `yaml:",inline"`
+	} `yaml:"context"`
+}
+
+// ImportKubeContexts copies contexts, with the clusters and users they
+// reference, from the global kubeconfig into the profile's .kube/config
+func ImportKubeContexts(profilesDir string, opts KubeOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile to import contexts into:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	source := opts.Source
+	if source == "" {
+		source, err = globalKubeconfigPath()
+		if err != nil {
+			return err
+		}
+	}
+	src, err := readKubeconfig(source)
+	if err != nil {
+		return err
+	}
+	if len(src.Contexts) == 0 {
+		return NewValidationError("no contexts found in %s", source)
+	}
+
+	if len(opts.Contexts) == 0 {
+		var names []string
+		for _, c := range src.Contexts {
+			names = append(names, c.Name)
+		}
+		opts.Contexts, err = ui.MultiSelect("Select contexts to import:", names)
+		if err != nil {
+			return err
+		}
+		if len(opts.Contexts) == 0 {
+			return NewValidationError("no contexts selected")
+		}
+	}
+
+	destPath := filepath.Join(profileDir, ".kube", "config")
+	dest, err := readKubeconfig(destPath)
+	if os.IsNotExist(err) {
+		dest = &kubeConfig{APIVersion: "v1", Kind: "Config"}
+	} else if err != nil {
+		return err
+	}
+
+	for _, name := range opts.Contexts {
+		ctx, ok := findKubeContext(src.Contexts, name)
+		if !ok {
+			return NewValidationError("context '%s' not found in %s", name, source)
+		}
+		cluster, ok := findKubeNamed(src.Clusters, ctx.Context.Cluster)
+		if !ok {
+			return NewValidationError("cluster '%s' used by context '%s' not found in %s", ctx.Context.Cluster, name, source)
+		}
+		user, ok := findKubeNamed(src.Users, ctx.Context.User)
+		if !ok {
+			return NewValidationError("user '%s' used by context '%s' not found in %s", ctx.Context.User, name, source)
+		}
+
+		dest.Contexts = upsertKubeContext(dest.Contexts, ctx)
+		dest.Clusters = upsertKubeNamed(dest.Clusters, cluster)
+		dest.Users = upsertKubeNamed(dest.Users, user)
+		fmt.Printf("  %s✓%s %s (cluster: %s, user: %s)\n", ui.ColorGreen, ui.ColorReset, name, cluster.Name, user.Name)
+	}
+
+	if dest.CurrentContext == "" || opts.SetCurrent {
+		dest.CurrentContext = opts.Contexts[0]
+	}
+
+	if err := writeKubeconfig(destPath, dest); err != nil {
+		return err
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Imported %d context(s) into %s", len(opts.Contexts), profileName))
+	fmt.Printf("  Current context: %s\n", dest.CurrentContext)
+	return changesApplied()
+}
+
+// ListKubeContexts shows the contexts isolated in each profile
+func ListKubeContexts(profilesDir string, opts KubeOptions) error {
+	profiles := []string{opts.ProfileName}
+	if opts.ProfileName == "" {
+		var err error
+		profiles, err = ListProfileNames(profilesDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, profileName := range profiles {
+		configPath := filepath.Join(profilePath(profilesDir, profileName), ".kube", "config")
+		cfg, err := readKubeconfig(configPath)
+		if os.IsNotExist(err) {
+			if opts.ProfileName != "" {
+				fmt.Printf("%s%s%s: no kubeconfig\n", ui.ColorBlue, profileName, ui.ColorReset)
+			}
+			continue
+		}
+		if err != nil {
+			ui.PrintWarning(err.Error())
+			continue
+		}
+
+		fmt.Printf("%s%s%s\n", ui.ColorBlue, profileName, ui.ColorReset)
+		if len(cfg.Contexts) == 0 {
+			fmt.Println("  (no contexts)")
+		}
+		for _, ctx := range cfg.Contexts {
+			marker := " "
+			if ctx.Name == cfg.CurrentContext {
+				marker = "*"
+			}
+			details := "cluster: " + ctx.Context.Cluster
+			if ctx.Context.Namespace != "" {
+				details += ", namespace: " + ctx.Context.Namespace
+			}
+			fmt.Printf("  %s %-30s %s\n", marker, ctx.Name, details)
+		}
+	}
+	return nil
+}
+
+// globalKubeconfigPath returns the kubeconfig kubectl would use outside a
+// profile: the first entry of $KUBECONFIG or ~/.kube/config
+func globalKubeconfigPath() (string, error) {
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		return strings.Split(env, string(os.PathListSeparator))[0], nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".kube", "config"), nil
+}
+
+func readKubeconfig(path string) (*kubeConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg kubeConfig
+	if err := yaml.Unmarshal(content, &cfg); err != nil {
+		return nil, NewValidationError("failed to parse kubeconfig %s: %v", path, err)
+	}
+	return &cfg, nil
+}
+
+func writeKubeconfig(path string, cfg *kubeConfig) error {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg); err != nil {
+		return fmt.Errorf("failed to encode kubeconfig: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to encode kubeconfig: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return newIOError(err, "failed to create .kube directory")
+	}
+	if err := writeProfileFile(path, buf.Bytes(), 0600); err != nil {
+		return newIOError(err, "failed to write %s", path)
+	}
+	return nil
+}
+
+func findKubeContext(contexts []kubeContext, name string) (kubeContext, bool) {
+	for _, c := range contexts {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return kubeContext{}, false
+}
+
+func findKubeNamed(entries []kubeNamed, name string) (kubeNamed, bool) {
+	for _, e := range entries {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return kubeNamed{}, false
+}
+
+func upsertKubeContext(contexts []kubeContext, ctx kubeContext) []kubeContext {
+	for i := range contexts {
+		if contexts[i].Name == ctx.Name {
+			contexts[i] = ctx
+			return contexts
+		}
+	}
+	return append(contexts, ctx)
+}
+
+func upsertKubeNamed(entries []kubeNamed, entry kubeNamed) []kubeNamed {
+	for i := range entries {
+		if entries[i].Name == entry.Name {
+			entries[i] = entry
+			return entries
+		}
+	}
+	return append(entries, entry)
+}
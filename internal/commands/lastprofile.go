@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/atomicfile"
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+)
+
+// lastProfileFileName records the profile last switched to or run in, in
+// the state directory
+const lastProfileFileName = "last-profile"
+
+// RecordLastProfile records the profile as the one last used, for commands
+// run without a profile name. Failing to record it is not an error of the
+// command that used the profile.
+func RecordLastProfile(profileName string) {
+	stateDir, err := config.StateDir()
+	if err == nil {
+		err = atomicfile.WriteFile(filepath.Join(stateDir, lastProfileFileName), []byte(profileName+"\n"), 0644)
+	}
+	if err != nil {
+		slog.Debug("failed to record the last used profile", "profile", profileName, "error", err)
+	}
+}
+
+// lastProfile returns the profile last used ("" if none was recorded)
+func lastProfile() string {
+	stateDir, err := config.StateDir()
+	if err != nil {
+		return ""
+	}
+	content, err := os.ReadFile(filepath.Join(stateDir, lastProfileFileName))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// fallbackProfile returns the profile to use when none is named: the
+// default profile of the config file, otherwise the one last used. It
+// returns "" when neither is set or exists any more, and describes where
+// the profile came from for the message telling which one is used.
+func fallbackProfile(profilesDir, defaultProfile string) (string, string) {
+	candidates := []struct{ name, source string }{
+		{defaultProfile, "default profile"},
+		{lastProfile(), "last used profile"},
+	}
+	for _, candidate := range candidates {
+		if candidate.name == "" || checkAmbiguous(profilesDir, candidate.name) != nil {
+			continue
+		}
+		if isProfileDir(profilePath(profilesDir, candidate.name)) {
+			return qualifiedName(profilesDir, candidate.name), candidate.source
+		}
+		slog.Debug("skipping missing profile", "profile", candidate.name, "source", candidate.source)
+	}
+	return "", ""
+}
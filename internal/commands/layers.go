@@ -0,0 +1,204 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/features"
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+)
+
+// layerFeaturePrefix starts the name of the feature a profile layer is
+// managed as ("profile base-company")
+const layerFeaturePrefix = "profile "
+
+// profileLayer is a profile in the extends chain of another profile
+type profileLayer struct {
+	name     string
+	dir      string
+	manifest *manifest.Manifest
+}
+
+// profileLayers returns the extends chain of a profile: its bases, root
+// first, followed by the profile itself
+func profileLayers(profileDir string) ([]profileLayer, error) {
+	var chain []profileLayer
+	seen := map[string]bool{}
+	for dir := profileDir; ; {
+		name := filepath.Base(dir)
+		if seen[name] {
+			var names []string
+			for i := len(chain) - 1; i >= 0; i-- {
+				names = append(names, chain[i].name)
+			}
+			return nil, NewValidationError("profile '%s' extends itself: %s -> %s", filepath.Base(profileDir), strings.Join(names, " -> "), name)
+		}
+		seen[name] = true
+
+		m, err := manifest.Load(dir)
+		if err != nil {
+			return nil, err
+		}
+		if err := validateLayer(name, m.Layer); err != nil {
+			return nil, err
+		}
+		chain = append([]profileLayer{{name: name, dir: dir, manifest: m}}, chain...)
+		if m.Extends == "" {
+			return chain, nil
+		}
+
+		base := filepath.Join(filepath.Dir(dir), m.Extends)
+		if !profileNamePattern.MatchString(m.Extends) {
+			return nil, NewValidationError("profile '%s' extends an invalid profile name: %s", name, m.Extends)
+		}
+		if _, err := os.Stat(filepath.Join(base, ".envrc")); err != nil {
+			return nil, NewValidationError("profile '%s' extends '%s', which does not exist", name, m.Extends)
+		}
+		dir = base
+	}
+}
+
+// validateLayer checks the items a profile shares with the profiles that
+// extend it before any reaches their .envrc or .gitignore: variable names
+// must be valid shell names, directories must be inside the profile, and
+// .gitignore patterns must be single lines
+func validateLayer(profileName string, l *manifest.Layer) error {
+	if l == nil {
+		return nil
+	}
+	for _, v := range l.Env {
+		if !envNamePattern.MatchString(v.Name) {
+			return NewValidationError("profile '%s' has an invalid variable name in its layer: %q", profileName, v.Name)
+		}
+	}
+	for _, dir := range l.Dirs {
+		if clean := filepath.Clean(filepath.FromSlash(dir)); !filepath.IsLocal(clean) || clean == "." {
+			return NewValidationError("profile '%s' has a layer directory that is not inside the profile: %q", profileName, dir)
+		}
+	}
+	for _, pattern := range l.Gitignore {
+		if strings.ContainsAny(pattern, "\r\n") {
+			return NewValidationError("profile '%s' has a layer .gitignore pattern that spans lines: %q", profileName, pattern)
+		}
+	}
+	return nil
+}
+
+// envLine returns the .envrc export of a variable of a feature. The values
+// of profile layers come from a manifest, not a feature definition, so they
+// are exported as they are, quoted like the managed blocks quote theirs.
+func envLine(feature features.Feature, v features.EnvVar) string {
+	if strings.HasPrefix(feature.Name, layerFeaturePrefix) {
+		return managedExport(v.Name, v.Value)
+	}
+	return v.Line()
+}
+
+// layeredFeatures returns the features of a profile made of layers: the
+// feature selections of the layers applied in order (a later layer undoes
+// what an earlier one selected), followed by each layer's own items as a
+// feature. A variable defined by a later layer replaces the one of an
+// earlier layer.
+func layeredFeatures(all []features.Feature, layers []profileLayer) []features.Feature {
+	var enable, disable []string
+	for _, layer := range layers {
+		for _, name := range layer.manifest.EnabledFeatures() {
+			disable = withoutName(disable, name)
+			enable = append(withoutName(enable, name), name)
+		}
+		for _, name := range layer.manifest.DisabledFeatures() {
+			enable = withoutName(enable, name)
+			disable = append(withoutName(disable, name), name)
+		}
+	}
+	feats := features.Select(all, enable, disable)
+
+	overridden := map[string]int{}
+	for i, layer := range layers {
+		if layer.manifest.Layer != nil {
+			for _, v := range layer.manifest.Layer.Env {
+				overridden[v.Name] = i
+			}
+		}
+	}
+	for i, layer := range layers {
+		l := layer.manifest.Layer
+		if l == nil {
+			continue
+		}
+		feature := features.Feature{
+			Name:    layerFeaturePrefix + layer.name,
+			Comment: "From profile " + layer.name,
+		}
+		for _, v := range l.Env {
+			if overridden[v.Name] == i {
+				feature.Env = append(feature.Env, features.EnvVar{Name: v.Name, Value: v.Value})
+			}
+		}
+		for _, dir := range l.Dirs {
+			feature.Dirs = append(feature.Dirs, features.Dir{Path: dir})
+		}
+		if len(l.Gitignore) > 0 {
+			feature.Gitignore = []features.GitignoreGroup{{Comment: feature.Comment, Patterns: l.Gitignore}}
+		}
+		feats = append(feats, feature)
+	}
+	return feats
+}
+
+// layeredVars returns the template vars of the layers, later layers
+// overriding earlier ones
+func layeredVars(layers []profileLayer) map[string]string {
+	var vars map[string]string
+	for _, layer := range layers {
+		for key, value := range layer.manifest.Vars {
+			if vars == nil {
+				vars = map[string]string{}
+			}
+			vars[key] = value
+		}
+	}
+	return vars
+}
+
+// itemSources maps each item the features manage (variables, directories,
+// files, .gitignore patterns) to the feature or profile layer it comes from
+func itemSources(feats []features.Feature) map[string]string {
+	sources := map[string]string{}
+	for _, feature := range feats {
+		source := feature.Name
+		for _, v := range feature.Env {
+			sources[v.Name] = source
+		}
+		for _, line := range feature.Lines {
+			sources[line] = source
+		}
+		for _, dir := range feature.Dirs {
+			sources[dir.Path] = source
+		}
+		for _, file := range feature.Files {
+			sources[file.Path] = source
+		}
+		for _, group := range feature.Gitignore {
+			for _, pattern := range group.Patterns {
+				sources[pattern] = source
+			}
+		}
+	}
+	return sources
+}
+
+// withLayerSources marks the items that come from a profile layer with the
+// layer, e.g. "AWS_REGION (from base-company)"
+func withLayerSources(items []string, sources map[string]string) []string {
+	marked := make([]string, len(items))
+	for i, item := range items {
+		marked[i] = item
+		if layer, ok := strings.CutPrefix(sources[item], layerFeaturePrefix); ok {
+			marked[i] = fmt.Sprintf("%s (from %s)", item, layer)
+		}
+	}
+	return marked
+}
@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/mindmorass/shell-profile-manager/internal/atomicfile"
+	"github.com/mindmorass/shell-profile-manager/internal/features"
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+)
+
+// linkTargets returns the shared files the link-strategy files of feats
+// point to, by path in the profile
+func linkTargets(feats []features.Feature) map[string]string {
+	targets := map[string]string{}
+	for _, feature := range feats {
+		for _, file := range feature.Files {
+			if feature.Linked(file) {
+				targets[file.Path] = file.SourcePath()
+			}
+		}
+	}
+	return targets
+}
+
+// writeProfileFile writes a file of a profile atomically. Unlike
+// atomicfile.WriteFile it does not write through a symlink: a file linked
+// to a shared source (the link strategy) is shared with other profiles, so
+// the link is replaced by a file of this profile's own rather than letting
+// one profile's settings or secrets reach the others.
+func writeProfileFile(path string, data []byte, perm os.FileMode) error {
+	if target, err := os.Readlink(path); err == nil {
+		reporter.Warning(fmt.Sprintf("%s was a link to %s; it is now a file of this profile's own", path, target))
+	}
+	return atomicfile.ReplaceFile(path, data, perm)
+}
+
+// updateLinks makes the symlinks of features with the link strategy and
+// records them in the manifest. Missing links are created; links update
+// made that point elsewhere (the source moved) or whose target is gone are
+// repaired. Files and links made by hand are left in place. It returns the
+// paths of the links created and repaired.
+func updateLinks(profileDir string, feats []features.Feature, dryRun bool) ([]string, []string, error) {
+	targets := linkTargets(feats)
+	if len(targets) == 0 {
+		return nil, nil, nil
+	}
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var linked, repaired []string
+	var managed []manifest.Link
+	for _, feature := range feats {
+		for _, file := range feature.Files {
+			if !feature.Linked(file) {
+				continue
+			}
+			target := targets[file.Path]
+			fullPath := filepath.Join(profileDir, file.Path)
+
+			current, err := os.Readlink(fullPath)
+			switch {
+			case os.IsNotExist(err):
+				linked = append(linked, file.Path)
+			case err != nil:
+				if !dryRun {
+					reporter.Warning(fmt.Sprintf("%s is not a link to %s; leaving it in place", file.Path, target))
+				}
+				continue
+			case current == target:
+				if _, err := os.Stat(target); err != nil && !dryRun {
+					reporter.Warning(fmt.Sprintf("Shared file %s linked from %s does not exist", target, file.Path))
+				}
+				managed = append(managed, manifest.Link{Path: file.Path, Target: target})
+				continue
+			default:
+				_, statErr := os.Stat(fullPath)
+				if current != m.LinkTarget(file.Path) && statErr == nil {
+					if !dryRun {
+						reporter.Warning(fmt.Sprintf("%s links to %s instead of %s; leaving it in place (remove it and run update to relink)", file.Path, current, target))
+					}
+					continue
+				}
+				repaired = append(repaired, file.Path)
+			}
+			managed = append(managed, manifest.Link{Path: file.Path, Target: target})
+			if dryRun {
+				continue
+			}
+
+			if _, err := os.Stat(target); err != nil {
+				reporter.Warning(fmt.Sprintf("Shared file %s linked from %s does not exist yet", target, file.Path))
+			}
+			if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+				return nil, nil, fmt.Errorf("failed to create directory for %s: %w", file.Path, err)
+			}
+			if current != "" {
+				if err := os.Remove(fullPath); err != nil {
+					return nil, nil, fmt.Errorf("failed to remove link %s: %w", file.Path, err)
+				}
+			}
+			if err := os.Symlink(target, fullPath); err != nil {
+				return nil, nil, fmt.Errorf("failed to link %s to %s: %w", file.Path, target, err)
+			}
+		}
+	}
+
+	if dryRun {
+		return linked, repaired, nil
+	}
+	// Links of features that were not part of this run stay recorded
+	links := slices.DeleteFunc(slices.Clone(m.Links), func(link manifest.Link) bool {
+		_, ok := targets[link.Path]
+		return ok
+	})
+	links = append(links, managed...)
+	if !slices.Equal(links, m.Links) {
+		m.Links = links
+		if err := manifest.Save(profileDir, m); err != nil {
+			return nil, nil, fmt.Errorf("failed to record links: %w", err)
+		}
+	}
+	return linked, repaired, nil
+}
@@ -6,7 +6,10 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/mindmorass/shell-profile-manager/internal/direnv"
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
 	"github.com/mindmorass/shell-profile-manager/internal/ui"
 )
 
@@ -14,9 +17,46 @@ type ListOptions struct {
 	Verbose     bool
 	ShowConfig  bool
 	Interactive bool
+	// Tags lists only the profiles tagged with every one of these
+	Tags []string
+}
+
+// ProfileSummary is a profile as listed with --output json|yaml
+type ProfileSummary struct {
+	Name   string `json:"name" yaml:"name"`
+	Path   string `json:"path" yaml:"path"`
+	Active bool   `json:"active" yaml:"active"`
+	// Direnv is the direnv allow state ("" when direnv is not installed)
+	Direnv   string `json:"direnv,omitempty" yaml:"direnv,omitempty"`
+	GitName  string `json:"git_name,omitempty" yaml:"git_name,omitempty"`
+	GitEmail string `json:"git_email,omitempty" yaml:"git_email,omitempty"`
+	Template string `json:"template,omitempty" yaml:"template,omitempty"`
+	Created  string `json:"created,omitempty" yaml:"created,omitempty"`
+	// Description and Tags come from the profile manifest
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	// Aliases are the short names of the profile, from its manifest and
+	// the config file
+	Aliases         []string `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	Owner           string   `json:"owner,omitempty" yaml:"owner,omitempty"`
+	TemplateVersion int      `json:"template_version,omitempty" yaml:"template_version,omitempty"`
+	// Features and DisabledFeatures are the optional features enabled and
+	// the default features disabled
+	Features         []string          `json:"features,omitempty" yaml:"features,omitempty"`
+	DisabledFeatures []string          `json:"disabled_features,omitempty" yaml:"disabled_features,omitempty"`
+	Remotes          []manifest.Remote `json:"remotes,omitempty" yaml:"remotes,omitempty"`
+	// Extends is the base profile the profile inherits from
+	Extends string `json:"extends,omitempty" yaml:"extends,omitempty"`
 }
 
 func ListProfiles(profilesDir string, opts ListOptions) error {
+	if ui.Structured() {
+		summaries, err := taggedSummaries(profilesDir, opts.Tags)
+		if err != nil {
+			return err
+		}
+		return ui.Render(summaries)
+	}
 
 	// Check if profiles directory exists
 	if _, err := os.Stat(profilesDir); os.IsNotExist(err) {
@@ -27,22 +67,16 @@ func ListProfiles(profilesDir string, opts ListOptions) error {
 	}
 
 	// Get all profile directories
-	entries, err := os.ReadDir(profilesDir)
+	profiles, err := taggedProfiles(profilesDir, opts.Tags)
 	if err != nil {
-		return fmt.Errorf("failed to read profiles directory: %w", err)
+		return err
 	}
 
-	var profiles []string
-	for _, entry := range entries {
-		if entry.IsDir() && entry.Name() != ".git" {
-			profilePath := filepath.Join(profilesDir, entry.Name())
-			envrcPath := filepath.Join(profilePath, ".envrc")
-			if _, err := os.Stat(envrcPath); err == nil {
-				profiles = append(profiles, entry.Name())
-			}
-		}
+	if len(profiles) == 0 && len(opts.Tags) > 0 {
+		fmt.Printf("%sNo %s%s\n", ui.ColorYellow, selectionTarget(opts.Tags), ui.ColorReset)
+		fmt.Println("See the tags in use with: profile tag list")
+		return nil
 	}
-
 	if len(profiles) == 0 {
 		fmt.Printf("%sNo profiles found%s\n", ui.ColorYellow, ui.ColorReset)
 		fmt.Println("Create your first profile with:")
@@ -50,16 +84,17 @@ func ListProfiles(profilesDir string, opts ListOptions) error {
 		return nil
 	}
 
-	// Interactive mode - show selection menu
-	if opts.Interactive {
-		selected, err := ui.SelectProfile(profiles, "Select a profile:")
+	// Interactive mode - show selection menu (falls back to the full
+	// listing when there is no terminal to prompt on)
+	if opts.Interactive && ui.IsInteractive() {
+		selected, err := ui.SelectProfileChoice(profileChoices(profilesDir, profiles), "Select a profile:")
 		if err != nil {
 			return err
 		}
 
 		// Show detailed info for selected profile
-		profileDir := filepath.Join(profilesDir, selected)
-		return showProfileDetails(profileDir, selected, opts)
+		profileDir := profilePath(profilesDir, selected)
+		return showProfileDetails(profilesDir, profileDir, selected, opts)
 	}
 
 	fmt.Printf("%s=== Workspace Profiles ===%s\n", ui.ColorBlue, ui.ColorReset)
@@ -74,120 +109,80 @@ func ListProfiles(profilesDir string, opts ListOptions) error {
 	}
 
 	// List profiles
-	for _, profileName := range profiles {
-		profileDir := filepath.Join(profilesDir, profileName)
-		envrcFile := filepath.Join(profileDir, ".envrc")
+	headers := []string{"NAME", "DIRENV", "GIT"}
+	if opts.Verbose {
+		headers = append(headers, "OWNER", "TEMPLATE", "CREATED", "ENV", "SCRIPTS")
+	}
+	headers = append(headers, "PATH")
+	if opts.ShowConfig {
+		headers = append(headers, "GITCONFIG")
+	}
+	table := ui.NewTable(headers...).Truncate(2, 40).Truncate(len(headers)-1, 50)
+	if opts.Verbose {
+		table.Truncate(3, 30)
+	}
+	if opts.ShowConfig {
+		table.Truncate(len(headers)-2, 50)
+	}
+
+	summaries, err := taggedSummaries(profilesDir, opts.Tags)
+	if err != nil {
+		return err
+	}
+	needsAllow := false
+	for _, summary := range summaries {
+		profileDir := summary.Path
 		gitconfigFile := filepath.Join(profileDir, ".gitconfig")
-		readmeFile := filepath.Join(profileDir, "README.md")
 
-		// Profile header
-		if currentProfile == profileName {
-			fmt.Printf("%s● %s%s %s(active)%s\n", ui.ColorGreen, profileName, ui.ColorReset, ui.ColorYellow, ui.ColorReset)
-		} else {
-			fmt.Printf("%s○ %s%s\n", ui.ColorCyan, profileName, ui.ColorReset)
+		name := fmt.Sprintf("%s○ %s%s", ui.ColorCyan, summary.Name, ui.ColorReset)
+		if summary.Active {
+			name = fmt.Sprintf("%s● %s%s", ui.ColorGreen, summary.Name, ui.ColorReset)
+		}
+		if len(summary.Aliases) > 0 {
+			name += fmt.Sprintf(" (%s)", strings.Join(summary.Aliases, ", "))
 		}
 
-		// Show path
-		fmt.Printf("  %sPath:%s %s\n", ui.ColorBlue, ui.ColorReset, profileDir)
-
-		// Check if .envrc exists and is allowed
-		if _, err := os.Stat(envrcFile); err == nil {
-			// Check direnv status
-			if cmd := exec.Command("which", "direnv"); cmd.Run() == nil {
-				statusCmd := exec.Command("direnv", "status")
-				statusCmd.Dir = profileDir
-				output, statusErr := statusCmd.Output()
-				if statusErr == nil {
-					if strings.Contains(string(output), "Found RC allowed true") {
-						fmt.Printf("  %s✓ direnv allowed%s\n", ui.ColorGreen, ui.ColorReset)
-					} else {
-						fmt.Printf("  %s⚠ direnv not allowed%s (run: cd %s && direnv allow)\n", ui.ColorYellow, ui.ColorReset, profileDir)
-					}
-				}
-			}
-		} else {
-			fmt.Printf("  %s⚠ Missing .envrc%s\n", ui.ColorYellow, ui.ColorReset)
+		direnvState := "-"
+		if _, err := os.Stat(filepath.Join(profileDir, ".envrc")); err != nil {
+			direnvState = fmt.Sprintf("%s⚠ missing .envrc%s", ui.ColorYellow, ui.ColorReset)
+		} else if summary.Direnv == direnv.Allowed.String() {
+			direnvState = fmt.Sprintf("%s✓ %s%s", ui.ColorGreen, summary.Direnv, ui.ColorReset)
+		} else if summary.Direnv != "" {
+			needsAllow = true
+			direnvState = fmt.Sprintf("%s⚠ %s%s", ui.ColorYellow, summary.Direnv, ui.ColorReset)
 		}
 
-		// Show git configuration
+		gitUser := fmt.Sprintf("%s⚠ missing .gitconfig%s", ui.ColorYellow, ui.ColorReset)
 		if _, err := os.Stat(gitconfigFile); err == nil {
-			gitName := getGitConfig(gitconfigFile, "user.name")
-			gitEmail := getGitConfig(gitconfigFile, "user.email")
-			if gitName == "" {
-				gitName = "Not set"
-			}
-			if gitEmail == "" {
-				gitEmail = "Not set"
-			}
-			fmt.Printf("  %sGit:%s %s <%s>\n", ui.ColorBlue, ui.ColorReset, gitName, gitEmail)
-
-			if opts.ShowConfig {
-				fmt.Printf("    %sConfig:%s %s\n", ui.ColorBlue, ui.ColorReset, gitconfigFile)
-			}
-		} else {
-			fmt.Printf("  %s⚠ Missing .gitconfig%s\n", ui.ColorYellow, ui.ColorReset)
+			gitUser = fmt.Sprintf("%s <%s>", valueOr(summary.GitName, "Not set"), valueOr(summary.GitEmail, "Not set"))
 		}
 
-		// Verbose mode
+		row := []string{name, direnvState, gitUser}
 		if opts.Verbose {
-			// Check for README
-			if _, err := os.Stat(readmeFile); err == nil {
-				readmeContent, readErr := os.ReadFile(readmeFile)
-				if readErr != nil {
-					continue
-				}
-				lines := strings.Split(string(readmeContent), "\n")
-				for _, line := range lines {
-					if strings.HasPrefix(line, "Template:") {
-						template := strings.TrimSpace(strings.TrimPrefix(line, "Template:"))
-						fmt.Printf("  %sTemplate:%s %s\n", ui.ColorBlue, ui.ColorReset, template)
-					}
-					if strings.HasPrefix(line, "Created:") {
-						created := strings.TrimSpace(strings.TrimPrefix(line, "Created:"))
-						fmt.Printf("  %sCreated:%s %s\n", ui.ColorBlue, ui.ColorReset, created)
-					}
-				}
+			envLines := "-"
+			if count, ok := envFileLines(profileDir); ok {
+				envLines = fmt.Sprintf("%d", count)
 			}
-
-			// Check for .env file
-			envFile := filepath.Join(profileDir, ".env")
-			if _, err := os.Stat(envFile); err == nil {
-				content, readErr := os.ReadFile(envFile)
-				if readErr != nil {
-					continue
-				}
-				lines := strings.Split(string(content), "\n")
-				nonEmptyLines := 0
-				for _, line := range lines {
-					if strings.TrimSpace(line) != "" && !strings.HasPrefix(strings.TrimSpace(line), "#") {
-						nonEmptyLines++
-					}
-				}
-				fmt.Printf("  %sEnvironment:%s .env file present (%d lines)\n", ui.ColorBlue, ui.ColorReset, nonEmptyLines)
+			scripts := "-"
+			if count := executableCount(filepath.Join(profileDir, "bin")); count > 0 {
+				scripts = fmt.Sprintf("%d", count)
 			}
-
-			// Count files in bin directory
-			binDir := filepath.Join(profileDir, "bin")
-			if entries, err := os.ReadDir(binDir); err == nil {
-				execCount := 0
-				for _, entry := range entries {
-					if !entry.IsDir() {
-						info, infoErr := entry.Info()
-						if infoErr != nil {
-							continue
-						}
-						if info.Mode()&0111 != 0 {
-							execCount++
-						}
-					}
-				}
-				if execCount > 0 {
-					fmt.Printf("  %sScripts:%s %d executable script(s) in bin/\n", ui.ColorBlue, ui.ColorReset, execCount)
-				}
+			template := valueOr(summary.Template, "-")
+			if summary.TemplateVersion > 0 {
+				template += fmt.Sprintf(" v%d", summary.TemplateVersion)
 			}
+			row = append(row, valueOr(summary.Owner, "-"), template, valueOr(summary.Created, "-"), envLines, scripts)
 		}
-
-		fmt.Println()
+		row = append(row, profileDir)
+		if opts.ShowConfig {
+			row = append(row, gitconfigFile)
+		}
+		table.AddRow(row...)
+	}
+	table.Render(os.Stdout)
+	fmt.Println()
+	if needsAllow {
+		fmt.Printf("%s⚠ Some profiles are not allowed in direnv (run: profile direnv allow --all)%s\n\n", ui.ColorYellow, ui.ColorReset)
 	}
 
 	// Summary
@@ -202,6 +197,131 @@ func ListProfiles(profilesDir string, opts ListOptions) error {
 	return nil
 }
 
+// envFileLines counts the variables set in the profile's .env file, and
+// reports whether the file exists
+func envFileLines(profileDir string) (int, bool) {
+	content, err := os.ReadFile(filepath.Join(profileDir, ".env"))
+	if err != nil {
+		return 0, false
+	}
+	count := 0
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.TrimSpace(line) != "" && !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			count++
+		}
+	}
+	return count, true
+}
+
+// executableCount counts the executable files in dir
+func executableCount(dir string) int {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+	count := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && info.Mode()&0111 != 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// ProfileSummaries describes every profile, for structured output and the
+// public API
+func ProfileSummaries(profilesDir string) ([]ProfileSummary, error) {
+	summaries := []ProfileSummary{}
+	if _, err := os.Stat(profilesDir); os.IsNotExist(err) {
+		return summaries, nil
+	}
+	profiles, err := ListProfileNames(profilesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, profileName := range profiles {
+		profileDir := newProfilePath(profilesDir, profileName)
+		summary := cachedProfileSummary(profileDir)
+		summary.Name, summary.Path = profileName, profileDir
+		summary.Aliases = aliasesOf(profilesDir, profileName, summary.Aliases)
+		summary.Active = isActiveProfile(profileName, profileDir)
+		if direnv.Installed() {
+			if state, err := direnv.Status(profileDir); err == nil && state != direnv.Unknown {
+				summary.Direnv = state.String()
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	saveProfileCache()
+	return summaries, nil
+}
+
+// readProfileSummary reads what describes the profile in profileDir from
+// its files; the fields describing the current environment are left empty
+func readProfileSummary(profileDir string) ProfileSummary {
+	var summary ProfileSummary
+	gitconfigFile := filepath.Join(profileDir, ".gitconfig")
+	if _, err := os.Stat(gitconfigFile); err == nil {
+		summary.GitName = getGitConfig(gitconfigFile, "user.name")
+		summary.GitEmail = getGitConfig(gitconfigFile, "user.email")
+	}
+
+	// Profiles not yet migrated only record these in README.md
+	summary.Template = readmeField(profileDir, "Template")
+	summary.Created = readmeField(profileDir, "Created")
+	if m, err := manifest.Load(profileDir); err == nil {
+		summary.Description, summary.Tags, summary.Owner = m.Description, m.Tags, m.Owner
+		summary.Aliases = m.Aliases
+		summary.Template = valueOr(m.Template, summary.Template)
+		summary.TemplateVersion = m.TemplateVersion
+		summary.Features, summary.DisabledFeatures = m.EnabledFeatures(), m.DisabledFeatures()
+		summary.Remotes = m.Remotes
+		summary.Extends = m.Extends
+		if created, err := time.Parse(time.RFC3339, m.CreatedAt); err == nil {
+			summary.Created = created.UTC().Format(readmeTimeFormat)
+		}
+	}
+	return summary
+}
+
+// taggedSummaries describes the profiles tagged with every selector, or
+// every profile when there are none
+func taggedSummaries(profilesDir string, selectors []string) ([]ProfileSummary, error) {
+	if err := checkTagSelectors(selectors); err != nil {
+		return nil, err
+	}
+	summaries, err := ProfileSummaries(profilesDir)
+	if err != nil || len(selectors) == 0 {
+		return summaries, err
+	}
+	tagged := []ProfileSummary{}
+	for _, summary := range summaries {
+		if hasTags(summary.Tags, selectors) {
+			tagged = append(tagged, summary)
+		}
+	}
+	return tagged, nil
+}
+
+// readmeField returns the value of a "Key: value" line in the profile's
+// README.md ("" if there is none)
+func readmeField(profileDir, key string) string {
+	content, err := os.ReadFile(filepath.Join(profileDir, "README.md"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if value, ok := strings.CutPrefix(line, key+":"); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
 func getGitConfig(configFile, key string) string {
 	cmd := exec.Command("git", "config", "--file", configFile, key)
 	output, err := cmd.Output()
@@ -212,13 +332,12 @@ func getGitConfig(configFile, key string) string {
 }
 
 // showProfileDetails shows detailed information for a single profile
-func showProfileDetails(profileDir, profileName string, opts ListOptions) error {
+func showProfileDetails(profilesDir, profileDir, profileName string, opts ListOptions) error {
 	fmt.Printf("%s=== Profile: %s ===%s\n", ui.ColorBlue, profileName, ui.ColorReset)
 	fmt.Println()
 
 	envrcFile := filepath.Join(profileDir, ".envrc")
 	gitconfigFile := filepath.Join(profileDir, ".gitconfig")
-	readmeFile := filepath.Join(profileDir, "README.md")
 
 	// Show path
 	fmt.Printf("  %sPath:%s %s\n", ui.ColorBlue, ui.ColorReset, profileDir)
@@ -226,18 +345,7 @@ func showProfileDetails(profileDir, profileName string, opts ListOptions) error
 	// Check if .envrc exists and is allowed
 	if _, err := os.Stat(envrcFile); err == nil {
 		// Check direnv status
-		if cmd := exec.Command("which", "direnv"); cmd.Run() == nil {
-			statusCmd := exec.Command("direnv", "status")
-			statusCmd.Dir = profileDir
-			output, statusErr := statusCmd.Output()
-			if statusErr == nil {
-				if strings.Contains(string(output), "Found RC allowed true") {
-					fmt.Printf("  %s✓ direnv allowed%s\n", ui.ColorGreen, ui.ColorReset)
-				} else {
-					fmt.Printf("  %s⚠ direnv not allowed%s (run: cd %s && direnv allow)\n", ui.ColorYellow, ui.ColorReset, profileDir)
-				}
-			}
-		}
+		printDirenvState(profileDir, profileName)
 	} else {
 		fmt.Printf("  %s⚠ Missing .envrc%s\n", ui.ColorYellow, ui.ColorReset)
 	}
@@ -263,23 +371,7 @@ func showProfileDetails(profileDir, profileName string, opts ListOptions) error
 
 	// Always show verbose info in interactive mode
 	if opts.Verbose || opts.Interactive {
-		// Check for README
-		if _, err := os.Stat(readmeFile); err == nil {
-			readmeContent, readErr := os.ReadFile(readmeFile)
-			if readErr == nil {
-				lines := strings.Split(string(readmeContent), "\n")
-				for _, line := range lines {
-					if strings.HasPrefix(line, "Template:") {
-						template := strings.TrimSpace(strings.TrimPrefix(line, "Template:"))
-						fmt.Printf("  %sTemplate:%s %s\n", ui.ColorBlue, ui.ColorReset, template)
-					}
-					if strings.HasPrefix(line, "Created:") {
-						created := strings.TrimSpace(strings.TrimPrefix(line, "Created:"))
-						fmt.Printf("  %sCreated:%s %s\n", ui.ColorBlue, ui.ColorReset, created)
-					}
-				}
-			}
-		}
+		printManifestDetails(profilesDir, profileDir, profileName)
 
 		// Check for .env file
 		envFile := filepath.Join(profileDir, ".env")
@@ -323,3 +415,64 @@ func showProfileDetails(profileDir, profileName string, opts ListOptions) error
 	fmt.Println()
 	return nil
 }
+
+// printManifestDetails prints the metadata recorded in the profile manifest,
+// falling back to README.md for the template and creation date of profiles
+// that have not been migrated
+func printManifestDetails(profilesDir, profileDir, profileName string) {
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		fmt.Printf("  %s⚠ %v%s\n", ui.ColorYellow, err, ui.ColorReset)
+		m = &manifest.Manifest{}
+	}
+	field := func(label, value string) {
+		if value != "" {
+			fmt.Printf("  %s%s:%s %s\n", ui.ColorBlue, label, ui.ColorReset, value)
+		}
+	}
+
+	field("Aliases", strings.Join(aliasesOf(profilesDir, profileName, m.Aliases), ", "))
+	field("Description", m.Description)
+	field("Tags", strings.Join(m.Tags, ", "))
+	field("Owner", m.Owner)
+	template := valueOr(m.Template, readmeField(profileDir, "Template"))
+	if template != "" && m.TemplateVersion > 0 {
+		template += fmt.Sprintf(" (v%d)", m.TemplateVersion)
+	}
+	if m.TemplateRepo != "" {
+		template += " from " + m.TemplateRepo
+	}
+	field("Template", template)
+	created := readmeField(profileDir, "Created")
+	if t, err := time.Parse(time.RFC3339, m.CreatedAt); err == nil {
+		created = t.UTC().Format(readmeTimeFormat)
+	}
+	field("Created", created)
+	if m.Extends != "" {
+		chain := m.Extends
+		if layers, err := profileLayers(profileDir); err != nil {
+			chain += fmt.Sprintf(" %s(%v)%s", ui.ColorYellow, err, ui.ColorReset)
+		} else {
+			for i := len(layers) - 3; i >= 0; i-- {
+				chain += " -> " + layers[i].name
+			}
+		}
+		field("Extends", chain)
+	}
+	if m.Layer != nil {
+		var items []string
+		for _, v := range m.Layer.Env {
+			items = append(items, v.Name)
+		}
+		items = append(append(items, m.Layer.Dirs...), m.Layer.Gitignore...)
+		field("Layer", strings.Join(items, ", "))
+	}
+	field("Features", strings.Join(m.EnabledFeatures(), ", "))
+	field("Disabled", strings.Join(m.DisabledFeatures(), ", "))
+	for _, remote := range m.Remotes {
+		field("Remote", fmt.Sprintf("%s %s", remote.Name, remote.URL))
+	}
+	if m.SchemaVersion < CurrentSchemaVersion() {
+		fmt.Printf("  %s⚠ Schema version %d is outdated (run: profile update %s)%s\n", ui.ColorYellow, m.SchemaVersion, filepath.Base(profileDir), ui.ColorReset)
+	}
+}
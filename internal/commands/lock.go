@@ -0,0 +1,180 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+	"github.com/mindmorass/shell-profile-manager/internal/goroutine"
+)
+
+// LockError is returned when another spm process is changing a profile
+type LockError struct {
+	Profile string
+	Path    string
+	Owner   lockOwner
+}
+
+func (e *LockError) Error() string {
+	if e.Owner.PID == 0 {
+		return fmt.Sprintf("another spm process is running on %s; wait for it to finish", e.Profile)
+	}
+	return fmt.Sprintf("another spm process is running on %s (pid %d on %s, since %s); wait for it to finish",
+		e.Profile, e.Owner.PID, e.Owner.Host, e.Owner.Since.Local().Format("15:04:05"))
+}
+
+// lockOwner is what a lock file records about the process holding it
+type lockOwner struct {
+	PID   int       `json:"pid"`
+	Host  string    `json:"host"`
+	Since time.Time `json:"since"`
+}
+
+// heldLock is a profile lock this process holds
+type heldLock struct {
+	// goroutine runs the call chain holding the lock
+	goroutine uint64
+	// count is how many times the call chain took the lock, as commands
+	// that change a profile call others that do
+	count int
+	file  *os.File
+}
+
+var (
+	// heldLocks are the locks this process holds, by lock file. The call
+	// chain holding a lock takes it again freely; other goroutines, such
+	// as the workers of a bulk command, wait until it is released.
+	heldLocks    = map[string]*heldLock{}
+	heldLocksMu  sync.Mutex
+	lockReleased = sync.NewCond(&heldLocksMu)
+)
+
+// lockPath returns the lock file of a profile. Locks are kept in the state
+// directory, out of the profile and its git repository.
+func lockPath(profileDir string) (string, error) {
+	stateDir, err := config.StateDir()
+	if err != nil {
+		return "", err
+	}
+	absDir, err := filepath.Abs(profileDir)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(absDir))
+	return filepath.Join(stateDir, "locks", filepath.Base(absDir)+"-"+hex.EncodeToString(sum[:6])+".lock"), nil
+}
+
+// lockProfile takes the lock of a profile for a command that changes it,
+// and returns the function that releases it. It fails at once when another
+// process holds the lock, and waits when another goroutine of this process
+// does.
+func lockProfile(profileDir string) (func(), error) {
+	path, err := lockPath(profileDir)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := goroutine.ID()
+	heldLocksMu.Lock()
+	defer heldLocksMu.Unlock()
+	for heldLocks[path] != nil && heldLocks[path].goroutine != chain {
+		lockReleased.Wait()
+	}
+	held := heldLocks[path]
+	if held == nil {
+		file, err := acquireLock(profileDir, path)
+		if err != nil {
+			return nil, err
+		}
+		held = &heldLock{goroutine: chain, file: file}
+		heldLocks[path] = held
+	}
+	held.count++
+
+	return func() {
+		heldLocksMu.Lock()
+		defer heldLocksMu.Unlock()
+		if held.count--; held.count == 0 {
+			delete(heldLocks, path)
+			// Removed while still locked: a process that opened the
+			// file meanwhile sees it is gone once it gets the lock
+			os.Remove(path)
+			held.file.Close()
+			lockReleased.Broadcast()
+		}
+	}, nil
+}
+
+// acquireLock locks the lock file of a profile with the file locks of the
+// operating system, which are released when the process ends: a process
+// that dies never leaves a profile locked. It records the owner in the
+// file for the errors of other processes, and returns the file, which
+// holds the lock until it is closed.
+func acquireLock(profileDir, path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, newIOError(err, "failed to create lock directory")
+	}
+	host, _ := os.Hostname()
+	content, err := json.Marshal(lockOwner{PID: os.Getpid(), Host: host, Since: time.Now().UTC()})
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, newIOError(err, "failed to create lock file %s", path)
+		}
+		locked, err := lockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, newIOError(err, "failed to lock %s", path)
+		}
+		if !locked {
+			f.Close()
+			return nil, &LockError{Profile: filepath.Base(profileDir), Path: path, Owner: readLockOwner(path)}
+		}
+		// The holder removes the file as it releases the lock; a lock on
+		// a file that was removed meanwhile excludes no one, so the
+		// new file is locked instead
+		if !lockedFileAt(f, path) {
+			f.Close()
+			continue
+		}
+
+		if err := f.Truncate(0); err == nil {
+			_, err = f.WriteAt(content, 0)
+		}
+		if err != nil {
+			f.Close()
+			return nil, newIOError(err, "failed to write lock file %s", path)
+		}
+		return f, nil
+	}
+}
+
+// lockedFileAt reports whether the locked file f is still the file at path
+func lockedFileAt(f *os.File, path string) bool {
+	locked, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(path)
+	return err == nil && os.SameFile(locked, current)
+}
+
+// readLockOwner returns the owner recorded in a lock file, or the zero
+// owner when it cannot be read
+func readLockOwner(path string) lockOwner {
+	var owner lockOwner
+	if content, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(content, &owner)
+	}
+	return owner
+}
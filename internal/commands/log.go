@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mindmorass/shell-profile-manager/internal/checksums"
+	"github.com/mindmorass/shell-profile-manager/internal/history"
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// managedFiles are the profile files spm rewrites, whose git history
+// 'profile log --git' shows
+var managedFiles = []string{".envrc", ".gitconfig", ".gitignore", manifest.FileName, history.FileName, checksums.FileName}
+
+type LogOptions struct {
+	ProfileName string
+	// Limit shows only the newest entries (0 shows all)
+	Limit int
+	// Git shows the commits that changed managed files instead of the
+	// history spm records
+	Git bool
+}
+
+// GitLogEntry is a commit as listed by 'profile log --git --output json|yaml'
+type GitLogEntry struct {
+	Commit  string `json:"commit" yaml:"commit"`
+	Date    string `json:"date" yaml:"date"`
+	Author  string `json:"author" yaml:"author"`
+	Subject string `json:"subject" yaml:"subject"`
+}
+
+// recordHistory appends the changes command made to the profile's
+// history, with the spm version and what the manifest records. Failing to
+// is only a warning, the changes were made.
+func recordHistory(profileDir, command string, changes []string) {
+	entry := history.Entry{
+		Time:       time.Now().UTC().Truncate(time.Second),
+		Command:    command,
+		SpmVersion: SpmVersion(),
+		Changes:    changes,
+	}
+	if m, err := manifest.Load(profileDir); err == nil {
+		entry.Template, entry.TemplateVersion, entry.SchemaVersion = m.Template, m.TemplateVersion, m.SchemaVersion
+	}
+	if err := history.Append(profileDir, entry); err != nil {
+		reporter.Warning(fmt.Sprintf("Failed to record history: %v", err))
+	}
+}
+
+// ShowLog shows the changes spm applied to a profile, newest first
+func ShowLog(profilesDir string, opts LogOptions) error {
+	if opts.Limit < 0 {
+		return NewValidationError("--limit must not be negative")
+	}
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	if opts.Git {
+		return showGitLog(profileName, profileDir, opts.Limit)
+	}
+
+	entries, err := history.Load(profileDir)
+	if err != nil {
+		return err
+	}
+	// Newest first
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if opts.Limit > 0 && len(entries) > opts.Limit {
+		entries = entries[:opts.Limit]
+	}
+
+	if ui.Structured() {
+		return ui.Render(entries)
+	}
+	if len(entries) == 0 {
+		ui.PrintInfo(fmt.Sprintf("No history recorded for %s", profileName))
+		if _, err := os.Stat(filepath.Join(profileDir, ".git")); err == nil {
+			fmt.Printf("Changes from before history was recorded: profile log %s --git\n", profileName)
+		}
+		return nil
+	}
+	for i, entry := range entries {
+		if i > 0 {
+			fmt.Println()
+		}
+		details := []string{"spm " + entry.SpmVersion}
+		if entry.Template != "" {
+			details = append(details, fmt.Sprintf("template %s v%d", entry.Template, entry.TemplateVersion))
+		}
+		if entry.SchemaVersion != 0 {
+			details = append(details, fmt.Sprintf("schema v%d", entry.SchemaVersion))
+		}
+		fmt.Printf("%s%s%s  %s%s%s  (%s)\n", ui.ColorBlue, entry.Time.Local().Format("2006-01-02 15:04"), ui.ColorReset,
+			ui.ColorCyan, entry.Command, ui.ColorReset, strings.Join(details, ", "))
+		for _, change := range entry.Changes {
+			fmt.Printf("  ✓ %s\n", change)
+		}
+	}
+	return nil
+}
+
+// showGitLog lists the commits that changed the managed files of a profile
+// that is a git repository
+func showGitLog(profileName, profileDir string, limit int) error {
+	if _, err := os.Stat(filepath.Join(profileDir, ".git")); err != nil {
+		return NewValidationError("profile '%s' is not a git repository (see 'profile sync init')", profileName)
+	}
+	args := []string{"log", "--date=short", "--format=%h%x09%ad%x09%an%x09%s"}
+	if limit > 0 {
+		args = append(args, fmt.Sprintf("--max-count=%d", limit))
+	}
+	output, err := runGit(profileDir, append(append(args, "--"), managedFiles...)...)
+	if err != nil {
+		return err
+	}
+
+	commits := []GitLogEntry{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(line, "\t", 4)
+		if len(fields) == 4 {
+			commits = append(commits, GitLogEntry{Commit: fields[0], Date: fields[1], Author: fields[2], Subject: fields[3]})
+		}
+	}
+
+	if ui.Structured() {
+		return ui.Render(commits)
+	}
+	if len(commits) == 0 {
+		ui.PrintInfo(fmt.Sprintf("No commits changed the managed files of %s", profileName))
+		return nil
+	}
+	table := ui.NewTable("COMMIT", "DATE", "AUTHOR", "SUBJECT").Truncate(3, 60)
+	for _, commit := range commits {
+		table.AddRow(commit.Commit, commit.Date, commit.Author, commit.Subject)
+	}
+	table.Render(os.Stdout)
+	return nil
+}
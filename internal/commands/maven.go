@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type MavenOptions struct {
+	ProfileName string
+	// ServerID matches <id> in the project's <repository> or
+	// <distributionManagement> entries
+	ServerID string
+	Username string
+	// SecretRef loads the password from the profile's secrets provider
+	SecretRef string
+}
+
+var (
+	mavenServerIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+	mavenServersEnd      = regexp.MustCompile(`(?m)^([ \t]*)</servers>`)
+	mavenSettingsEnd     = regexp.MustCompile(`(?m)^([ \t]*)</settings>`)
+	nonAlphanumeric      = regexp.MustCompile(`[^A-Za-z0-9]+`)
+)
+
+// SetMavenServer adds (or replaces) a <server> in the profile's Maven
+// settings.xml whose password is an environment variable reference, so the
+// file never holds a secret. With a secret reference the variable is loaded
+// from the profile's secrets provider.
+func SetMavenServer(profilesDir string, opts MavenOptions) error {
+	if opts.ServerID == "" {
+		return NewValidationError("server id is required")
+	}
+	if !mavenServerIDPattern.MatchString(opts.ServerID) {
+		return NewValidationError("invalid server id: %s", opts.ServerID)
+	}
+
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if opts.Username == "" {
+		if opts.Username, err = ui.Input(fmt.Sprintf("Username for %s:", opts.ServerID), ""); err != nil {
+			return err
+		}
+		if opts.Username == "" {
+			return NewValidationError("--username is required")
+		}
+	}
+	if err := ensureFeature(profilesDir, profileName, profileDir, "jvm"); err != nil {
+		return err
+	}
+
+	passwordVar := mavenPasswordVar(opts.ServerID)
+	if opts.SecretRef != "" {
+		err := SetSecret(profilesDir, SecretsOptions{ProfileName: profileName, VarName: passwordVar, Ref: opts.SecretRef})
+		var changed *ChangesApplied
+		if err != nil && !errors.As(err, &changed) {
+			return err
+		}
+	}
+
+	settingsPath := filepath.Join(profileDir, ".m2", "settings.xml")
+	content, err := os.ReadFile(settingsPath)
+	if err != nil {
+		return newIOError(err, "failed to read %s", settingsPath)
+	}
+	updated, err := upsertMavenServer(string(content), opts.ServerID, opts.Username, passwordVar)
+	if err != nil {
+		return NewValidationError("%s: %v", settingsPath, err)
+	}
+	if err := writeProfileFile(settingsPath, []byte(updated), 0644); err != nil {
+		return newIOError(err, "failed to write %s", settingsPath)
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Added Maven server '%s' to %s", opts.ServerID, settingsPath))
+	fmt.Printf("  Password: ${env.%s}\n", passwordVar)
+	if opts.SecretRef == "" {
+		fmt.Printf("  Set %s in .env, or load it with: profile secrets set %s %s <ref>\n", passwordVar, profileName, passwordVar)
+	}
+	return changesApplied()
+}
+
+// mavenPasswordVar returns the environment variable holding a server's
+// password, e.g. nexus-releases -> MAVEN_NEXUS_RELEASES_PASSWORD
+func mavenPasswordVar(serverID string) string {
+	name := strings.ToUpper(nonAlphanumeric.ReplaceAllString(serverID, "_"))
+	return "MAVEN_" + strings.Trim(name, "_") + "_PASSWORD"
+}
+
+// upsertMavenServer replaces the <server> with the given id, or adds it at
+// the end of <servers> (creating the section if needed)
+func upsertMavenServer(content, id, username, passwordVar string) (string, error) {
+	server := fmt.Sprintf("<server>\n  <id>%s</id>\n  <username>%s</username>\n  <password>${env.%s}</password>\n</server>",
+		html.EscapeString(id), html.EscapeString(username), passwordVar)
+
+	existing := regexp.MustCompile(`(?s)([ \t]*)<server>\s*<id>` + regexp.QuoteMeta(id) + `</id>.*?</server>`)
+	if m := existing.FindStringSubmatchIndex(content); m != nil {
+		indent := content[m[2]:m[3]]
+		return content[:m[0]] + indentLines(server, indent) + content[m[1]:], nil
+	}
+
+	if m := mavenServersEnd.FindStringSubmatchIndex(content); m != nil {
+		indent := content[m[2]:m[3]]
+		return content[:m[0]] + indentLines(server, indent+"  ") + "\n" + content[m[0]:], nil
+	}
+
+	// Without a <servers> section, add one at the end of <settings>
+	m := mavenSettingsEnd.FindStringSubmatchIndex(content)
+	if m == nil {
+		return "", fmt.Errorf("no </settings> element")
+	}
+	indent := content[m[2]:m[3]] + "  "
+	servers := indent + "<servers>\n" + indentLines(server, indent+"  ") + "\n" + indent + "</servers>\n"
+	return content[:m[0]] + servers + content[m[0]:], nil
+}
+
+// indentLines prefixes every line of text with indent
+func indentLines(text, indent string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = indent + line
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,192 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+)
+
+// migration upgrades a profile from version-1 to version
+type migration struct {
+	version     int
+	description string
+	apply       func(profileDir string) error
+}
+
+// migrations are applied in order to bring a profile up to the current
+// schema. Append new steps here; never reorder or renumber existing ones.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "Move files from dotfiles/ to the profile root",
+		apply:       flattenDotfilesDir,
+	},
+	{
+		version:     2,
+		description: "Replace the commented-out Terraform plugin cache example",
+		apply:       removeTerraformCacheExample,
+	},
+	{
+		version:     3,
+		description: "Record the template, creation date, owner, and git remotes in profile.yaml",
+		apply:       recordProfileMetadata,
+	},
+}
+
+// CurrentSchemaVersion is the schema version written to new profiles
+func CurrentSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// runMigrations applies every migration newer than the profile's recorded
+// schema version and records the new version in the manifest. It returns a
+// description of each migration that ran (or would run in dry-run).
+func runMigrations(profileDir string, dryRun bool) ([]string, error) {
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.SchemaVersion > CurrentSchemaVersion() {
+		return nil, NewValidationError("profile schema version %d is newer than this version of profile supports (%d); upgrade profile", m.SchemaVersion, CurrentSchemaVersion())
+	}
+
+	var ran []string
+	for _, step := range migrations {
+		if step.version <= m.SchemaVersion {
+			continue
+		}
+		if !dryRun {
+			if err := step.apply(profileDir); err != nil {
+				return ran, fmt.Errorf("migration %d (%s) failed: %w", step.version, step.description, err)
+			}
+			// The step may have written the manifest itself
+			if m, err = manifest.Load(profileDir); err != nil {
+				return ran, err
+			}
+			m.SchemaVersion = step.version
+			if err := manifest.Save(profileDir, m); err != nil {
+				return ran, err
+			}
+		}
+		ran = append(ran, fmt.Sprintf("%d: %s", step.version, step.description))
+	}
+
+	return ran, nil
+}
+
+// flattenDotfilesDir moves configuration from the old dotfiles/ layout to
+// the profile root and rewrites references to it in .envrc and .gitignore
+func flattenDotfilesDir(profileDir string) error {
+	dotfilesDir := filepath.Join(profileDir, "dotfiles")
+	entries, err := os.ReadDir(dotfilesDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read dotfiles directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		src := filepath.Join(dotfilesDir, entry.Name())
+		dest := filepath.Join(profileDir, entry.Name())
+		if _, err := os.Stat(dest); err == nil {
+			reporter.Warning(fmt.Sprintf("Leaving dotfiles/%s in place: %s already exists", entry.Name(), entry.Name()))
+			continue
+		}
+		if err := os.Rename(src, dest); err != nil {
+			return fmt.Errorf("failed to move dotfiles/%s: %w", entry.Name(), err)
+		}
+	}
+
+	// Only remove the directory if everything was moved
+	_ = os.Remove(dotfilesDir)
+
+	replacements := map[string][]string{
+		".envrc":      {"$WORKSPACE_HOME/dotfiles/", "$WORKSPACE_HOME/", "${WORKSPACE_HOME}/dotfiles/", "${WORKSPACE_HOME}/"},
+		".gitignore":  {"\ndotfiles/", "\n"},
+		"bin/ssh":     {"$WORKSPACE_HOME/dotfiles/", "$WORKSPACE_HOME/"},
+		".ssh/config": {"/dotfiles/.ssh/", "/.ssh/"},
+	}
+	for name, pairs := range replacements {
+		path := filepath.Join(profileDir, name)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		updated := string(content)
+		for i := 0; i < len(pairs); i += 2 {
+			updated = strings.ReplaceAll(updated, pairs[i], pairs[i+1])
+		}
+		if updated == string(content) {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if err := writeProfileFile(path, []byte(updated), info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to update %s: %w", name, err)
+		}
+		recordChecksums(profileDir, name)
+	}
+
+	return nil
+}
+
+// removeTerraformCacheExample drops the commented TF_PLUGIN_CACHE_DIR lines
+// from older templates; the terraform feature now exports the variable
+func removeTerraformCacheExample(profileDir string) error {
+	doc, err := loadEnvrc(profileDir)
+	if err != nil {
+		return err
+	}
+
+	obsolete := map[string]bool{
+		"# Optionally set workspace-specific plugin cache":                         true,
+		`# export TF_PLUGIN_CACHE_DIR="$WORKSPACE_HOME/.terraform.d/plugin-cache"`: true,
+	}
+
+	doc.RemoveLines(func(line string) bool { return obsolete[strings.TrimSpace(line)] })
+	_, err = saveEnvrc(profileDir, doc)
+	return err
+}
+
+// recordProfileMetadata fills in the manifest fields older profiles only
+// have elsewhere: the template and creation date from README.md, the owner
+// from .gitconfig, and the git remotes. The template version stays unset,
+// since the template revision those profiles were created from is unknown.
+func recordProfileMetadata(profileDir string) error {
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return err
+	}
+
+	if m.Template == "" {
+		m.Template = readmeField(profileDir, "Template")
+	}
+	if m.CreatedAt == "" {
+		if created, err := time.Parse(readmeTimeFormat, readmeField(profileDir, "Created")); err == nil {
+			m.CreatedAt = created.UTC().Format(time.RFC3339)
+		}
+	}
+	if m.Owner == "" {
+		gitconfigFile := filepath.Join(profileDir, ".gitconfig")
+		if _, err := os.Stat(gitconfigFile); err == nil {
+			if email := getGitConfig(gitconfigFile, "user.email"); email != placeholderEmail {
+				m.Owner = email
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(profileDir, ".git")); err == nil {
+		for _, remote := range gitRemotes(profileDir) {
+			m.SetRemote(remote.Name, remote.URL)
+		}
+	}
+
+	return manifest.Save(profileDir, m)
+}
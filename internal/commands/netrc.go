@@ -0,0 +1,203 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+	"github.com/mindmorass/shell-profile-manager/internal/secrets"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+const netrcFile = ".netrc"
+
+type NetrcOptions struct {
+	ProfileName string
+	// Machine is the host name the entry applies to
+	Machine string
+	Login   string
+	// SecretRef reads the password from the profile's secrets provider
+	// instead of prompting for it
+	SecretRef string
+}
+
+// netrcToken is a whitespace-separated word of a .netrc file and its offset
+type netrcToken struct {
+	text  string
+	start int
+}
+
+// SetNetrc adds (or replaces) a machine entry in the profile's .netrc. The
+// file holds the password itself, since netrc readers cannot resolve
+// references, so it is kept at 0600 and gitignored.
+func SetNetrc(profilesDir string, opts NetrcOptions) error {
+	if opts.Machine == "" {
+		return NewValidationError("machine is required")
+	}
+	if !isNetrcToken(opts.Machine) {
+		return NewValidationError("invalid machine name: %s", opts.Machine)
+	}
+
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if opts.Login == "" {
+		if opts.Login, err = ui.Input(fmt.Sprintf("Login for %s:", opts.Machine), ""); err != nil {
+			return err
+		}
+		if opts.Login == "" {
+			return NewValidationError("--login is required")
+		}
+	}
+	if !isNetrcToken(opts.Login) {
+		return NewValidationError("login cannot be empty or contain whitespace")
+	}
+
+	var password string
+	if opts.SecretRef != "" {
+		if password, err = resolveSecret(profileDir, profileName, opts.SecretRef); err != nil {
+			return err
+		}
+	} else if password, err = ui.Password(fmt.Sprintf("Password for %s@%s:", opts.Login, opts.Machine)); err != nil {
+		return fmt.Errorf("%w (or read it from the secrets provider with --secret <ref>)", err)
+	}
+	if !isNetrcToken(password) {
+		return NewValidationError("password cannot be empty or contain whitespace (netrc has no quoting)")
+	}
+
+	if err := ensureFeature(profilesDir, profileName, profileDir, "netrc"); err != nil {
+		return err
+	}
+
+	netrcPath := filepath.Join(profileDir, netrcFile)
+	content, err := readOptionalFile(netrcPath)
+	if err != nil {
+		return newIOError(err, "failed to read %s", netrcPath)
+	}
+	updated, replaced, err := upsertNetrcMachine(content, opts.Machine, opts.Login, password)
+	if err != nil {
+		return NewValidationError("%s: %v", netrcPath, err)
+	}
+	if err := writeProfileFile(netrcPath, []byte(updated), 0600); err != nil {
+		return newIOError(err, "failed to write %s", netrcPath)
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(netrcPath, 0600); err != nil {
+		return newIOError(err, "failed to set permissions on %s", netrcPath)
+	}
+
+	if replaced {
+		ui.PrintSuccess(fmt.Sprintf("Updated %s in %s", opts.Machine, netrcPath))
+	} else {
+		ui.PrintSuccess(fmt.Sprintf("Added %s to %s", opts.Machine, netrcPath))
+	}
+	fmt.Printf("  Login: %s\n", opts.Login)
+	if opts.SecretRef != "" {
+		fmt.Printf("  Password read from %s; run this again after rotating it\n", opts.SecretRef)
+	}
+	return changesApplied()
+}
+
+// resolveSecret reads a secret's current value from the profile's provider
+func resolveSecret(profileDir, profileName, ref string) (string, error) {
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return "", err
+	}
+	provider, err := secrets.ForConfig(m.Secrets)
+	if err != nil {
+		return "", NewValidationError("%v; run 'profile secrets <%s> %s' first", err, strings.Join(secrets.Names(), "|"), profileName)
+	}
+	resolver, ok := provider.(secrets.Resolver)
+	if !ok {
+		return "", NewValidationError("the %s provider cannot read secret values", provider.Name())
+	}
+	if err := provider.ValidateRef(ref); err != nil {
+		return "", NewValidationError("%v", err)
+	}
+
+	value, err := resolver.Resolve(ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s from %s: %w", ref, provider.Name(), err)
+	}
+	return value, nil
+}
+
+// upsertNetrcMachine replaces the entry for machine, or adds one before the
+// default entry (which must come last) or at the end of the file. It
+// reports whether an existing entry was replaced.
+func upsertNetrcMachine(content, machine, login, password string) (string, bool, error) {
+	entry := fmt.Sprintf("machine %s\n  login %s\n  password %s\n", machine, login, password)
+
+	tokens := tokenizeNetrc(content)
+	start, end := -1, len(content)
+	defaultStart := -1
+	for i, token := range tokens {
+		switch token.text {
+		case "macdef":
+			return "", false, fmt.Errorf("macdef entries are not supported; edit the file by hand")
+		case "default":
+			if start != -1 && end == len(content) {
+				end = token.start
+			}
+			defaultStart = token.start
+		case "machine":
+			if start != -1 && end == len(content) {
+				end = token.start
+			}
+			if start == -1 && i+1 < len(tokens) && tokens[i+1].text == machine {
+				start = token.start
+			}
+		}
+	}
+
+	if start != -1 {
+		return content[:start] + entry + strings.TrimLeft(content[end:], " \t\n"), true, nil
+	}
+	if defaultStart != -1 {
+		return content[:defaultStart] + entry + content[defaultStart:], false, nil
+	}
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return content + entry, false, nil
+}
+
+// tokenizeNetrc splits a .netrc file into words, skipping # comments
+func tokenizeNetrc(content string) []netrcToken {
+	var tokens []netrcToken
+	for i := 0; i < len(content); {
+		switch {
+		case unicode.IsSpace(rune(content[i])):
+			i++
+		case content[i] == '#':
+			if end := strings.IndexByte(content[i:], '\n'); end != -1 {
+				i += end
+			} else {
+				i = len(content)
+			}
+		default:
+			start := i
+			for i < len(content) && !unicode.IsSpace(rune(content[i])) {
+				i++
+			}
+			tokens = append(tokens, netrcToken{text: content[start:i], start: start})
+		}
+	}
+	return tokens
+}
+
+// isNetrcToken reports whether value can be written as a single netrc word
+func isNetrcToken(value string) bool {
+	return value != "" && strings.IndexFunc(value, unicode.IsSpace) == -1 && !strings.HasPrefix(value, "#")
+}
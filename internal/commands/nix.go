@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+const (
+	flakeHeader    = "# Generated by 'profile nix export' from profile.yaml; edit the tools there and re-run"
+	defaultNixpkgs = "github:NixOS/nixpkgs/nixos-unstable"
+)
+
+// nixAttrPattern matches nixpkgs attribute paths such as nodejs_20 or
+// python312Packages.pip
+var nixAttrPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_'+-]*(\.[A-Za-z_][A-Za-z0-9_'+-]*)*$`)
+
+type NixOptions struct {
+	ProfileName string
+	// Tools are added to the tool list in the profile manifest
+	Tools []string
+	// Nixpkgs overrides the nixpkgs flake reference
+	Nixpkgs string
+	// Force overwrites a flake.nix that was not generated by profile
+	Force bool
+}
+
+// ExportNix writes a flake.nix devShell with the profile's declared tools
+// and adds a 'use flake' block to .envrc
+func ExportNix(profilesDir string, opts NixOptions) error {
+	for _, tool := range opts.Tools {
+		if !nixAttrPattern.MatchString(tool) {
+			return NewValidationError("invalid nixpkgs attribute: %s", tool)
+		}
+	}
+
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile to export:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return err
+	}
+	for _, tool := range opts.Tools {
+		m.Tools = append(withoutName(m.Tools, tool), tool)
+	}
+	if len(m.Tools) == 0 {
+		return NewValidationError("profile '%s' declares no tools (add them with --tool <nixpkgs attribute>)", profileName)
+	}
+
+	flakePath := filepath.Join(profileDir, "flake.nix")
+	existing, err := readOptionalFile(flakePath)
+	if err != nil {
+		return newIOError(err, "failed to read flake.nix")
+	}
+	if existing != "" && !strings.HasPrefix(existing, flakeHeader) && !opts.Force {
+		return NewValidationError("%s was not generated by profile (use --force to overwrite)", flakePath)
+	}
+
+	if len(opts.Tools) > 0 {
+		if err := manifest.Save(profileDir, m); err != nil {
+			return newIOError(err, "failed to save profile manifest")
+		}
+	}
+
+	if err := writeProfileFile(flakePath, []byte(renderFlake(profileName, valueOr(opts.Nixpkgs, defaultNixpkgs), m.Tools)), 0644); err != nil {
+		return newIOError(err, "failed to write flake.nix")
+	}
+
+	block := "# Nix devShell generated from profile.yaml (profile nix export)\nif has nix; then\n  use flake\nfi"
+	if _, err := setManagedBlock(profileDir, "nix", block); err != nil {
+		return newIOError(err, "failed to update .envrc")
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Wrote %s with %d tool(s)", flakePath, len(m.Tools)))
+	fmt.Println("  Commit flake.nix and flake.lock: flakes in a git repository only see tracked files")
+	return changesApplied()
+}
+
+// renderFlake returns a flake.nix with a default devShell for the common
+// Linux and macOS systems
+func renderFlake(profileName, nixpkgs string, tools []string) string {
+	var b strings.Builder
+	b.WriteString(flakeHeader + "\n")
+	b.WriteString("{\n")
+	fmt.Fprintf(&b, "  description = \"Workspace profile %s development shell\";\n\n", profileName)
+	fmt.Fprintf(&b, "  inputs.nixpkgs.url = \"%s\";\n\n", nixpkgs)
+	b.WriteString("  outputs = { self, nixpkgs }:\n")
+	b.WriteString("    let\n")
+	b.WriteString("      systems = [ \"x86_64-linux\" \"aarch64-linux\" \"x86_64-darwin\" \"aarch64-darwin\" ];\n")
+	b.WriteString("      forAllSystems = f: nixpkgs.lib.genAttrs systems (system: f nixpkgs.legacyPackages.${system});\n")
+	b.WriteString("    in\n")
+	b.WriteString("    {\n")
+	b.WriteString("      devShells = forAllSystems (pkgs: {\n")
+	b.WriteString("        default = pkgs.mkShell {\n")
+	b.WriteString("          packages = with pkgs; [\n")
+	for _, tool := range tools {
+		fmt.Fprintf(&b, "            %s\n", tool)
+	}
+	b.WriteString("          ];\n")
+	b.WriteString("        };\n")
+	b.WriteString("      });\n")
+	b.WriteString("    };\n")
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type NpmOptions struct {
+	ProfileName string
+	Registry    string
+	// Scope limits the registry to a package scope such as @acme
+	Scope string
+	// Token is written to .npmrc as-is; TokenEnv references an environment
+	// variable instead so the token can come from .env or a secrets provider
+	Token    string
+	TokenEnv string
+}
+
+// SetNpmRegistry configures a (scoped) registry and its auth token in the
+// profile's .npmrc, enabling the node feature if needed
+func SetNpmRegistry(profilesDir string, opts NpmOptions) error {
+	if opts.Registry == "" {
+		return NewValidationError("--registry is required")
+	}
+	if opts.Token != "" && opts.TokenEnv != "" {
+		return NewValidationError("use either --token or --token-env, not both")
+	}
+	if opts.Scope != "" && !strings.HasPrefix(opts.Scope, "@") {
+		opts.Scope = "@" + opts.Scope
+	}
+
+	registry, err := url.Parse(opts.Registry)
+	if err != nil || registry.Host == "" {
+		return NewValidationError("invalid registry URL: %s", opts.Registry)
+	}
+	if !strings.HasSuffix(registry.Path, "/") {
+		registry.Path += "/"
+	}
+	opts.Registry = registry.String()
+
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := ensureFeature(profilesDir, profileName, profileDir, "node"); err != nil {
+		return err
+	}
+
+	npmrcPath := filepath.Join(profileDir, ".npmrc")
+	content, err := readOptionalFile(npmrcPath)
+	if err != nil {
+		return newIOError(err, "failed to read .npmrc")
+	}
+
+	registryKey := "registry"
+	if opts.Scope != "" {
+		registryKey = opts.Scope + ":registry"
+	}
+	content = upsertKeyValue(content, registryKey, opts.Registry)
+
+	// Auth settings are keyed by the registry URL without its scheme
+	authPrefix := "//" + registry.Host + registry.Path
+	switch {
+	case opts.TokenEnv != "":
+		content = upsertKeyValue(content, authPrefix+":_authToken", "${"+opts.TokenEnv+"}")
+	case opts.Token != "":
+		content = upsertKeyValue(content, authPrefix+":_authToken", opts.Token)
+	}
+
+	if err := writeProfileFile(npmrcPath, []byte(content), 0600); err != nil {
+		return newIOError(err, "failed to write .npmrc")
+	}
+
+	target := "default registry"
+	if opts.Scope != "" {
+		target = opts.Scope
+	}
+	ui.PrintSuccess(fmt.Sprintf("Set %s to %s in %s", target, opts.Registry, npmrcPath))
+	if opts.TokenEnv != "" {
+		fmt.Printf("  Provide %s via .env or 'profile secrets set %s %s <ref>'\n", opts.TokenEnv, profileName, opts.TokenEnv)
+	}
+	return changesApplied()
+}
+
+// ensureFeature enables a feature for the profile if it is not active yet
+func ensureFeature(profilesDir, profileName, profileDir, name string) error {
+	feats, err := LoadProfileFeatures(profileDir)
+	if err != nil {
+		return err
+	}
+	for _, feature := range feats {
+		if feature.Name == name {
+			return nil
+		}
+	}
+
+	err = EnableFeatures(profilesDir, FeatureOptions{ProfileName: profileName, Names: []string{name}})
+	var changed *ChangesApplied
+	if errors.As(err, &changed) {
+		return nil
+	}
+	return err
+}
+
+// upsertKeyValue sets key=value in an ini-style file without sections,
+// replacing an existing assignment of the same key
+func upsertKeyValue(content, key, value string) string {
+	line := key + "=" + value
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	for i, l := range lines {
+		if k, _, ok := strings.Cut(strings.TrimSpace(l), "="); ok && strings.TrimSpace(k) == key {
+			lines[i] = line
+			return strings.Join(lines, "\n") + "\n"
+		}
+	}
+	if content == "" {
+		return line + "\n"
+	}
+	return strings.Join(append(lines, line), "\n") + "\n"
+}
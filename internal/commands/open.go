@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// defaultLauncher opens profiles when no launcher is named
+const defaultLauncher = "editor"
+
+type OpenOptions struct {
+	ProfileName string
+	// In names the launcher to open the profile with (default: editor)
+	In string
+	// Launchers are those of the [open] section of the config file; they
+	// replace the built-in ones with the same name
+	Launchers map[string]string
+}
+
+// builtinLaunchers returns the commands that open a profile where the
+// config file does not set one. {dir} is replaced with the profile
+// directory and {name} with the profile name (without its root).
+func builtinLaunchers() map[string]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return map[string]string{
+			"editor":   "code {dir}",
+			"finder":   "open {dir}",
+			"terminal": "open -a Terminal {dir}",
+		}
+	case "windows":
+		return map[string]string{
+			"editor":   "code {dir}",
+			"finder":   "rundll32 url.dll,FileProtocolHandler {dir}",
+			"terminal": "wt -d {dir}",
+		}
+	default:
+		// Terminals do not return until closed, so it is started in a
+		// session of its own; it opens in the directory it runs in
+		return map[string]string{
+			"editor":   "code {dir}",
+			"finder":   "xdg-open {dir}",
+			"terminal": "setsid -f x-terminal-emulator",
+		}
+	}
+}
+
+// OpenProfile runs a launcher on a profile, e.g. to open it in an editor,
+// the file manager, or a new terminal. The launcher runs in the profile
+// directory with WORKSPACE_HOME and WORKSPACE_PROFILE set, as direnv
+// would set them.
+func OpenProfile(profilesDir string, opts OpenOptions) error {
+	launchers := builtinLaunchers()
+	for name, command := range opts.Launchers {
+		launchers[name] = command
+	}
+	target := valueOr(opts.In, defaultLauncher)
+	command, ok := launchers[target]
+	if !ok {
+		names := make([]string, 0, len(launchers))
+		for name := range launchers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return NewValidationError("unknown launcher: %s (available: %s; add others to the [open] section of the config file)", target, strings.Join(names, ", "))
+	}
+
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile to open:")
+	if err != nil {
+		return err
+	}
+
+	args := launcherArgs(command, profileName, profileDir)
+	if len(args) == 0 {
+		return NewValidationError("launcher %s has no command", target)
+	}
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		return NewValidationError("%s is not on PATH (set %s= in the [open] section of the config file)", args[0], target)
+	}
+
+	cmd := exec.Command(path, args[1:]...)
+	cmd.Dir = profileDir
+	cmd.Env = append(os.Environ(), "WORKSPACE_HOME="+profileDir, "WORKSPACE_PROFILE="+baseProfileName(profileName))
+	// Terminal editors such as vim need the terminal
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to open %s with %s: %w", profileName, target, err)
+	}
+	ui.PrintInfo(fmt.Sprintf("Opened %s (%s)", profileName, target))
+	return nil
+}
+
+// launcherArgs splits a launcher command into arguments and fills in the
+// profile directory and name. Each argument is replaced on its own, so a
+// directory with spaces stays one argument.
+func launcherArgs(command, profileName, profileDir string) []string {
+	placeholders := strings.NewReplacer("{dir}", profileDir, "{name}", baseProfileName(profileName))
+	args := splitCommand(command)
+	for i, arg := range args {
+		args[i] = placeholders.Replace(arg)
+	}
+	return args
+}
+
+// splitCommand splits a command line at spaces outside of single or double
+// quotes, which group words into one argument and are removed
+func splitCommand(command string) []string {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	for _, r := range command {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
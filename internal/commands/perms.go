@@ -0,0 +1,209 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mindmorass/shell-profile-manager/internal/perms"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type PermsOptions struct {
+	ProfileName string
+	// All checks or fixes every profile
+	All bool
+	// Jobs is how many profiles --all checks at once (0: the default)
+	Jobs int
+}
+
+// ProfilePermissions lists the files of a profile that grant more than they
+// should, as shown with --output json|yaml
+type ProfilePermissions struct {
+	Name       string            `json:"name" yaml:"name"`
+	Violations []perms.Violation `json:"violations" yaml:"violations"`
+}
+
+// updatePermissions restricts the files holding credentials in a profile
+// (SSH keys, cloud credentials, .netrc, .gnupg) that others can read. It
+// returns the files that were too open. On Windows drives mounted without
+// metadata (WSL) chmod has no effect, which is reported as a warning.
+func updatePermissions(profileDir string, dryRun bool) ([]perms.Violation, error) {
+	violations, err := perms.Check(profileDir)
+	if err != nil || dryRun || len(violations) == 0 {
+		return violations, err
+	}
+	unchanged, err := perms.Fix(profileDir, violations)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range unchanged {
+		reporter.Warning(fmt.Sprintf("Permissions of %s cannot be changed on this filesystem; run 'profile doctor' for details", path))
+	}
+	return violations, nil
+}
+
+// violationPaths returns the paths of violations
+func violationPaths(violations []perms.Violation) []string {
+	paths := make([]string, 0, len(violations))
+	for _, v := range violations {
+		paths = append(paths, v.Path)
+	}
+	return paths
+}
+
+// selectedProfiles returns the profiles a check works on: every profile
+// with all (--all), otherwise the one named (or selected)
+func selectedProfiles(profilesDir, profileName string, all bool) ([]string, error) {
+	if all {
+		return ListProfileNames(profilesDir)
+	}
+	profileName, _, err := resolveProfile(profilesDir, profileName, "Select profile:")
+	if err != nil {
+		return nil, err
+	}
+	return []string{profileName}, nil
+}
+
+// checkSelected runs check on the profiles a check works on. A single
+// profile is checked alone and an error stops the check. With --all, the
+// profiles are checked as update --all updates them: up to jobs at a time,
+// with a status line per profile in their order, and those with problems
+// or errors reported as failed. check returns the problems of the profile
+// at an index, as short messages. It reports whether the status lines were
+// printed, and how many profiles could not be checked.
+func checkSelected(action string, profiles []string, all bool, jobs int, check func(i int) ([]string, error)) (bool, int, error) {
+	if !all {
+		for i, profileName := range profiles {
+			if _, err := check(i); err != nil {
+				return false, 0, newIOError(err, "failed to check profile %s", profileName)
+			}
+		}
+		return false, 0, nil
+	}
+
+	index := make(map[string]int, len(profiles))
+	for i, profileName := range profiles {
+		index[profileName] = i
+	}
+	var mu sync.Mutex
+	unchecked := 0
+	progress := ui.NewProgress(action, len(profiles))
+	progress.RunEach(profiles, parallelJobs(jobs), func(profileName string) (string, error) {
+		problems, err := check(index[profileName])
+		if err != nil {
+			mu.Lock()
+			unchecked++
+			mu.Unlock()
+			return "", err
+		}
+		if len(problems) > 0 {
+			return "", errors.New(strings.Join(problems, "; "))
+		}
+		return "", nil
+	})
+	progress.Finish()
+	return true, unchecked, nil
+}
+
+// CheckPermissions reports the files holding credentials in profiles that
+// grant more than they should
+func CheckPermissions(profilesDir string, opts PermsOptions) error {
+	profiles, err := selectedProfiles(profilesDir, opts.ProfileName, opts.All)
+	if err != nil {
+		return err
+	}
+
+	checked := make([]*ProfilePermissions, len(profiles))
+	bulk, unchecked, err := checkSelected("Checking", profiles, opts.All, opts.Jobs, func(i int) ([]string, error) {
+		violations, err := perms.Check(profilePath(profilesDir, profiles[i]))
+		if err != nil {
+			return nil, err
+		}
+		checked[i] = &ProfilePermissions{Name: profiles[i], Violations: append([]perms.Violation{}, violations...)}
+		if len(violations) == 0 {
+			return nil, nil
+		}
+		return []string{"too open: " + strings.Join(violationPaths(violations), ", ")}, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	results := []ProfilePermissions{}
+	problems := 0
+	for _, result := range checked {
+		if result != nil {
+			results = append(results, *result)
+			problems += len(result.Violations)
+		}
+	}
+
+	if ui.Structured() {
+		if err := ui.Render(results); err != nil {
+			return err
+		}
+	} else {
+		// With --all, the status lines have reported each profile already
+		if !bulk {
+			for _, result := range results {
+				if len(result.Violations) == 0 {
+					fmt.Printf("%s✓ %s%s\n", ui.ColorGreen, result.Name, ui.ColorReset)
+					continue
+				}
+				fmt.Printf("%s⚠ %s%s\n", ui.ColorYellow, result.Name, ui.ColorReset)
+				for _, v := range result.Violations {
+					fmt.Printf("    - %s\n", v)
+				}
+			}
+		}
+		if problems > 0 {
+			fmt.Println()
+			fmt.Println("Restrict them with: profile perms fix <name> (or --all)")
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d file(s) with permissions that are too open", problems)
+	}
+	if unchecked > 0 {
+		return fmt.Errorf("%d of %d profile(s) could not be checked", unchecked, len(profiles))
+	}
+	return nil
+}
+
+// FixPermissions restricts the files holding credentials in profiles that
+// grant more than they should
+func FixPermissions(profilesDir string, opts PermsOptions) error {
+	profiles, err := selectedProfiles(profilesDir, opts.ProfileName, opts.All)
+	if err != nil {
+		return err
+	}
+
+	fixed := 0
+	for _, profileName := range profiles {
+		profileDir := profilePath(profilesDir, profileName)
+		unlock, err := lockProfile(profileDir)
+		if err != nil {
+			return err
+		}
+		violations, err := updatePermissions(profileDir, false)
+		unlock()
+		if err != nil {
+			return newIOError(err, "failed to fix permissions of profile %s", profileName)
+		}
+		if len(violations) == 0 {
+			continue
+		}
+		fixed += len(violations)
+		ui.PrintSuccess(fmt.Sprintf("Restricted permissions in %s: %s", profileName, strings.Join(violationPaths(violations), ", ")))
+		recordHistory(profileDir, "perms fix", []string{fmt.Sprintf("Restricted permissions: %s", strings.Join(violationPaths(violations), ", "))})
+	}
+
+	if fixed == 0 {
+		ui.PrintInfo("No permissions to fix")
+		return nil
+	}
+	return changesApplied()
+}
@@ -0,0 +1,381 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/checksums"
+	"github.com/mindmorass/shell-profile-manager/internal/envrc"
+	"github.com/mindmorass/shell-profile-manager/internal/features"
+	"github.com/mindmorass/shell-profile-manager/internal/history"
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+	"github.com/mindmorass/shell-profile-manager/internal/plan"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type ApplyOptions struct {
+	// Path is the plan file written with --out
+	Path string
+}
+
+var envVarPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ApplyPlan applies a plan saved with --out by running its command with the
+// saved options. The plan is made again first and must match: a profile
+// that changed since the plan was saved is not touched.
+func ApplyPlan(profilesDir string, opts ApplyOptions) error {
+	if opts.Path == "" {
+		return NewValidationError("plan file is required")
+	}
+	saved, err := plan.Load(opts.Path)
+	if err != nil {
+		return NewValidationError("%v", err)
+	}
+
+	current, err := replan(profilesDir, saved)
+	if err != nil {
+		return err
+	}
+	if !saved.Same(current) {
+		fmt.Println("The profile has changed since the plan was saved. Current plan:")
+		fmt.Println()
+		current.Render(os.Stdout)
+		return NewValidationError("plan %s is stale; run 'profile %s %s --out %s' again", opts.Path, saved.Command, saved.Profile, opts.Path)
+	}
+	if len(saved.Changes) == 0 {
+		ui.PrintInfo(fmt.Sprintf("No changes to apply to profile '%s'", saved.Profile))
+		return nil
+	}
+
+	ui.PrintInfo(fmt.Sprintf("Applying plan %s (%d change(s))", opts.Path, len(saved.Changes)))
+	switch saved.Command {
+	case "create":
+		var createOpts CreateOptions
+		if err := json.Unmarshal(saved.Options, &createOpts); err != nil {
+			return NewValidationError("invalid options in plan %s: %v", opts.Path, err)
+		}
+		createOpts.DryRun, createOpts.Out, createOpts.Interactive = false, "", false
+		return CreateProfile(profilesDir, createOpts)
+	case "update":
+		var updateOpts UpdateOptions
+		if err := json.Unmarshal(saved.Options, &updateOpts); err != nil {
+			return NewValidationError("invalid options in plan %s: %v", opts.Path, err)
+		}
+		updateOpts.DryRun, updateOpts.Out = false, ""
+		return UpdateProfile(profilesDir, updateOpts)
+	default:
+		var deleteOpts DeleteOptions
+		if err := json.Unmarshal(saved.Options, &deleteOpts); err != nil {
+			return NewValidationError("invalid options in plan %s: %v", opts.Path, err)
+		}
+		// The plan was reviewed when it was saved
+		deleteOpts.DryRun, deleteOpts.Out, deleteOpts.Force = false, "", true
+		return DeleteProfile(profilesDir, deleteOpts)
+	}
+}
+
+// replan makes a saved plan again from its options against the profile as
+// it is now
+func replan(profilesDir string, saved *plan.Plan) (*plan.Plan, error) {
+	profileDir := profilePath(profilesDir, saved.Profile)
+	switch saved.Command {
+	case "create":
+		var opts CreateOptions
+		if err := json.Unmarshal(saved.Options, &opts); err != nil {
+			return nil, NewValidationError("invalid options in plan: %v", err)
+		}
+		profileDir = newProfilePath(profilesDir, saved.Profile)
+		allFeats, err := features.Load()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load feature definitions: %w", err)
+		}
+		feats, _, err := newProfileFeatures(profilesDir, opts, allFeats)
+		if err != nil {
+			return nil, err
+		}
+		return planCreate(profileDir, opts, feats)
+	case "update":
+		var opts UpdateOptions
+		if err := json.Unmarshal(saved.Options, &opts); err != nil {
+			return nil, NewValidationError("invalid options in plan: %v", err)
+		}
+		feats, err := LoadProfileFeatures(profileDir)
+		if err != nil {
+			return nil, err
+		}
+		return planUpdate(profileDir, opts, feats)
+	case "delete":
+		var opts DeleteOptions
+		if err := json.Unmarshal(saved.Options, &opts); err != nil {
+			return nil, NewValidationError("invalid options in plan: %v", err)
+		}
+		return planDelete(profileDir, opts)
+	default:
+		return nil, NewValidationError("unknown command in plan: %s", saved.Command)
+	}
+}
+
+// showPlan prints a plan (as a document with --output) and saves it when
+// out is set
+func showPlan(p *plan.Plan, out string) error {
+	if ui.Structured() {
+		if err := ui.Render(p); err != nil {
+			return err
+		}
+	} else {
+		p.Render(os.Stdout)
+	}
+
+	if out == "" {
+		return nil
+	}
+	if err := p.Save(out); err != nil {
+		return newIOError(err, "failed to save plan")
+	}
+	fmt.Fprintln(ui.MessageOutput())
+	ui.PrintInfo(fmt.Sprintf("Saved plan to %s; apply it with: profile apply %s", out, out))
+	return nil
+}
+
+// planCreate describes what create writes for a new profile. With --force
+// over an existing profile, files that exist are updated.
+func planCreate(profileDir string, opts CreateOptions, feats []features.Feature) (*plan.Plan, error) {
+	p, err := plan.New("create", opts.ProfileName, opts)
+	if err != nil {
+		return nil, err
+	}
+	exists := func(path string) bool {
+		_, err := os.Stat(filepath.Join(profileDir, path))
+		return err == nil
+	}
+	write := func(path, detail string) {
+		if exists(path) {
+			p.Add(plan.Update, plan.File, path, detail)
+		} else {
+			p.Add(plan.Create, plan.File, path, detail)
+		}
+	}
+
+	if !exists(".") {
+		p.Add(plan.Create, plan.Dir, ".", profileDir)
+	}
+	for _, feature := range feats {
+		for _, dir := range feature.Dirs {
+			if !exists(dir.Path) {
+				p.Add(plan.Create, plan.Dir, dir.Path, modeDetail(dir.Mode))
+			}
+		}
+	}
+	for _, feature := range feats {
+		for _, file := range feature.Files {
+			if exists(file.Path) {
+				continue
+			}
+			if feature.Linked(file) {
+				p.Add(plan.Create, plan.File, file.Path, "link to "+file.SourcePath())
+			} else {
+				p.Add(plan.Create, plan.File, file.Path, modeDetail(file.Mode))
+			}
+		}
+	}
+
+	// .envrc is rewritten, so its variables are updated with --force
+	varAction := plan.Create
+	if exists(".envrc") {
+		varAction = plan.Update
+	}
+	write(".envrc", "template: "+opts.Template)
+	p.Add(varAction, plan.Var, "WORKSPACE_PROFILE", opts.ProfileName)
+	p.Add(varAction, plan.Var, "WORKSPACE_HOME", "")
+	for _, feature := range feats {
+		for _, v := range feature.Env {
+			p.Add(varAction, plan.Var, v.Name, "")
+		}
+	}
+
+	gitDetail := "template: " + opts.Template
+	if opts.GitName != "" || opts.GitEmail != "" {
+		gitDetail += fmt.Sprintf(", user: %s <%s>", valueOr(opts.GitName, "Your Name"), valueOr(opts.GitEmail, placeholderEmail))
+	}
+	write(".gitconfig", gitDetail)
+	if !exists(".ssh/config") {
+		p.Add(plan.Create, plan.File, ".ssh/config", "")
+	}
+	if !exists(".ssh/known_hosts") {
+		p.Add(plan.Create, plan.File, ".ssh/known_hosts", "")
+	}
+	if _, ok := features.Find(feats, "1password"); ok {
+		write(".config/1Password/agent.toml", "")
+	}
+	write("bin/ssh", "ssh wrapper")
+	write(".gitignore", "")
+	write("README.md", "")
+	write(".env.example", "")
+
+	manifestDetail := fmt.Sprintf("schema version %d, template %s v%d", CurrentSchemaVersion(), opts.Template, TemplateVersion)
+	if len(opts.Features) > 0 {
+		manifestDetail += ", features: " + strings.Join(opts.Features, ", ")
+	}
+	if len(opts.DisableFeatures) > 0 {
+		manifestDetail += ", without: " + strings.Join(opts.DisableFeatures, ", ")
+	}
+	if opts.SecretsProvider != "" {
+		manifestDetail += ", secrets: " + opts.SecretsProvider
+	}
+	write(manifest.FileName, manifestDetail)
+	write(history.FileName, "")
+	write(checksums.FileName, "")
+	if opts.InitGit {
+		p.Add(plan.Create, plan.Dir, ".git", valueOr(opts.GitRemote, "git init"))
+	}
+	return p, nil
+}
+
+// planUpdate describes what update would change, using the same checks
+// that update applies
+func planUpdate(profileDir string, opts UpdateOptions, feats []features.Feature) (*plan.Plan, error) {
+	p, err := plan.New("update", opts.ProfileName, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := runMigrations(profileDir, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, migration := range migrations {
+		p.Add(plan.Update, plan.Migration, migration, "")
+	}
+
+	sources := itemSources(feats)
+	dirs, err := updateDirectories(profileDir, feats, true)
+	if err != nil {
+		return nil, newIOError(err, "failed to check directories")
+	}
+	for _, dir := range dirs {
+		p.Add(plan.Create, plan.Dir, dir, sourceDetail(sources, dir))
+	}
+
+	files, err := updateFiles(profileDir, feats, true)
+	if err != nil {
+		return nil, newIOError(err, "failed to check files")
+	}
+	for _, file := range files {
+		p.Add(plan.Create, plan.File, file, sourceDetail(sources, file))
+	}
+
+	linked, repaired, err := updateLinks(profileDir, feats, true)
+	if err != nil {
+		return nil, newIOError(err, "failed to check links")
+	}
+	targets := linkTargets(feats)
+	for _, file := range linked {
+		p.Add(plan.Create, plan.File, file, "link to "+targets[file])
+	}
+	for _, file := range repaired {
+		p.Add(plan.Update, plan.File, file, "relink to "+targets[file])
+	}
+
+	restricted, err := updatePermissions(profileDir, true)
+	if err != nil {
+		return nil, newIOError(err, "failed to check permissions")
+	}
+	for _, v := range restricted {
+		kind := plan.File
+		if v.Dir {
+			kind = plan.Dir
+		}
+		p.Add(plan.Update, kind, v.Path, fmt.Sprintf("permissions %o", v.Mode&v.Expected))
+	}
+
+	doc, err := loadEnvrc(profileDir)
+	if err != nil {
+		return nil, newIOError(err, "failed to check .envrc")
+	}
+	deprecated, err := migrateDeprecated(doc, feats, true)
+	if err != nil {
+		return nil, newIOError(err, "failed to check deprecated variables")
+	}
+	for _, migration := range deprecated {
+		p.Add(plan.Update, plan.Var, migration, "deprecated")
+	}
+
+	// Variables already exported are rewritten layer variables
+	current := envrc.Parse(doc.String())
+	added := updateEnvrc(doc, feats)
+	var lines []string
+	for _, entry := range added {
+		if envVarPattern.MatchString(entry) && current.Export(entry) != -1 {
+			p.Add(plan.Update, plan.Var, entry, sourceDetail(sources, entry))
+		} else if envVarPattern.MatchString(entry) {
+			p.Add(plan.Create, plan.Var, entry, sourceDetail(sources, entry))
+		} else {
+			lines = append(lines, entry)
+		}
+	}
+	if len(lines) > 0 {
+		p.Add(plan.Update, plan.File, ".envrc", "add: "+strings.Join(withLayerSources(lines, sources), "; "))
+	}
+
+	patterns, err := updateGitignore(profileDir, feats, true)
+	if err != nil {
+		return nil, newIOError(err, "failed to check .gitignore")
+	}
+	if _, err := os.Stat(filepath.Join(profileDir, ".gitignore")); os.IsNotExist(err) {
+		p.Add(plan.Create, plan.File, ".gitignore", "")
+	} else if len(patterns) > 0 {
+		p.Add(plan.Update, plan.File, ".gitignore", "add: "+strings.Join(withLayerSources(patterns, sources), ", "))
+	}
+	return p, nil
+}
+
+// planDelete lists everything delete removes
+func planDelete(profileDir string, opts DeleteOptions) (*plan.Plan, error) {
+	p, err := plan.New("delete", opts.ProfileName, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = filepath.Walk(profileDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(profileDir, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			p.Add(plan.Delete, plan.Dir, rel, "")
+		} else {
+			p.Add(plan.Delete, plan.File, rel, "")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, newIOError(err, "failed to list profile files")
+	}
+	return p, nil
+}
+
+// sourceDetail describes where a managed item comes from: "feature aws"
+// or "from profile base-company"
+func sourceDetail(sources map[string]string, item string) string {
+	source, ok := sources[item]
+	if !ok {
+		return ""
+	}
+	if strings.HasPrefix(source, layerFeaturePrefix) {
+		return "from " + source
+	}
+	return "feature " + source
+}
+
+func modeDetail(mode string) string {
+	if mode == "" {
+		return ""
+	}
+	return "mode " + mode
+}
@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/plugins"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// PluginListing is a plugin in 'plugin list' with --output json|yaml
+type PluginListing struct {
+	Name             string   `json:"name" yaml:"name"`
+	Path             string   `json:"path" yaml:"path"`
+	Description      string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Commands         []string `json:"commands" yaml:"commands"`
+	Features         []string `json:"features" yaml:"features"`
+	SecretsProviders []string `json:"secrets_providers" yaml:"secrets_providers"`
+	Error            string   `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// ListPlugins shows the discovered plugins and what they registered
+func ListPlugins() error {
+	var listings []PluginListing
+	for _, plugin := range plugins.Load() {
+		listing := PluginListing{
+			Name:             plugin.Name,
+			Path:             plugin.Path,
+			Description:      plugin.Description,
+			Commands:         []string{},
+			Features:         []string{},
+			SecretsProviders: []string{},
+			Error:            plugin.Error,
+		}
+		for _, command := range plugin.Commands {
+			listing.Commands = append(listing.Commands, command.Name)
+		}
+		for _, provider := range plugin.SecretsProviders {
+			listing.SecretsProviders = append(listing.SecretsProviders, provider.Name)
+		}
+		listing.Features = pluginFeatureNames(plugin)
+		listings = append(listings, listing)
+	}
+
+	if ui.Structured() {
+		return ui.Render(append([]PluginListing{}, listings...))
+	}
+
+	if len(listings) == 0 {
+		dir, _ := plugins.Dir()
+		ui.PrintInfo(fmt.Sprintf("No plugins found (spm-<name> executables in %s or on PATH)", dir))
+		return nil
+	}
+
+	table := ui.NewTable("NAME", "PROVIDES", "PATH").Truncate(1, 60)
+	for _, listing := range listings {
+		if listing.Error != "" {
+			table.AddRow(listing.Name, fmt.Sprintf("%s(%s)%s", ui.ColorYellow, listing.Error, ui.ColorReset), listing.Path)
+			continue
+		}
+		var provides []string
+		for _, name := range listing.Commands {
+			provides = append(provides, "command "+name)
+		}
+		for _, name := range listing.Features {
+			provides = append(provides, "feature "+name)
+		}
+		for _, name := range listing.SecretsProviders {
+			provides = append(provides, "secrets "+name)
+		}
+		table.AddRow(listing.Name, valueOr(strings.Join(provides, ", "), "(nothing)"), listing.Path)
+	}
+	table.Render(os.Stdout)
+	return nil
+}
+
+// pluginFeatureNames returns the names of the features a plugin registers
+func pluginFeatureNames(plugin plugins.Plugin) []string {
+	names := []string{}
+	var defined []struct {
+		Name string `json:"name"`
+	}
+	if len(plugin.Features) == 0 || json.Unmarshal(plugin.Features, &defined) != nil {
+		return names
+	}
+	for _, feature := range defined {
+		names = append(names, feature.Name)
+	}
+	return names
+}
+
+// RunPluginCommand runs a subcommand registered by a plugin. It returns
+// false when no plugin registered the command.
+func RunPluginCommand(profilesDir, command string, args []string) (bool, error) {
+	plugin, ok := plugins.FindCommand(command)
+	if !ok {
+		return false, nil
+	}
+	code, err := plugins.Run(plugin, profilesDir, append([]string{command}, args...))
+	if err != nil {
+		return true, err
+	}
+	if code != 0 {
+		return true, &PluginExitError{Plugin: plugin.Name, Command: command, Code: code}
+	}
+	return true, nil
+}
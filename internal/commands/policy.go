@@ -0,0 +1,335 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+	"github.com/mindmorass/shell-profile-manager/internal/envrc"
+	"github.com/mindmorass/shell-profile-manager/internal/gitignore"
+	"github.com/mindmorass/shell-profile-manager/internal/policy"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type PolicyOptions struct {
+	ProfileName string
+	// All checks every profile
+	All bool
+	// Jobs is how many profiles --all checks at once (0: the default)
+	Jobs int
+}
+
+// ProfilePolicy lists the ways a profile violates the policy, as shown
+// with --output json|yaml
+type ProfilePolicy struct {
+	Name       string             `json:"name" yaml:"name"`
+	Violations []policy.Violation `json:"violations" yaml:"violations"`
+}
+
+// policyDir returns where a policy repository is cloned
+// ($XDG_STATE_HOME/spm/policy)
+func policyDir() (string, error) {
+	stateDir, err := config.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, "policy"), nil
+}
+
+// isLocalPolicy reports whether the policy source is a file or directory
+// rather than a git URL
+func isLocalPolicy(source string) bool {
+	if _, err := os.Stat(source); err == nil {
+		return true
+	}
+	return !strings.Contains(source, "://") && !strings.Contains(source, "@")
+}
+
+// policySource returns the policy file configured with policy= in the
+// config, cloning its repository on first use; "" when none is configured
+func policySource() (string, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return "", err
+	}
+	if cfg.Policy == "" {
+		return "", nil
+	}
+
+	path := cfg.Policy
+	if !isLocalPolicy(path) {
+		dir, err := policyDir()
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+			if err := clonePolicy(cfg.Policy, dir); err != nil {
+				return "", err
+			}
+		}
+		path = dir
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, policy.FileName)
+	}
+	return path, nil
+}
+
+// loadPolicy returns the policy configured with policy= in the config; nil
+// when none is configured
+func loadPolicy() (*policy.Policy, error) {
+	path, err := policySource()
+	if err != nil || path == "" {
+		return nil, err
+	}
+	return policy.Load(path)
+}
+
+// clonePolicy clones the policy repository at url into dir, replacing a
+// clone of another repository
+func clonePolicy(url, dir string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return newIOError(err, "failed to remove %s", dir)
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return newIOError(err, "failed to create %s", filepath.Dir(dir))
+	}
+	ui.PrintInfo(fmt.Sprintf("Cloning policy %s...", url))
+	if _, err := runGit("", "clone", "--quiet", "--depth", "1", url, dir); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdatePolicy fetches the latest policy from its repository
+func UpdatePolicy() error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.Policy == "" {
+		return NewValidationError("no policy configured (set policy=<git URL or path> in the config)")
+	}
+	if isLocalPolicy(cfg.Policy) {
+		ui.PrintInfo(fmt.Sprintf("Policy is read from %s; nothing to update", cfg.Policy))
+		return nil
+	}
+
+	dir, err := policyDir()
+	if err != nil {
+		return err
+	}
+	origin, _ := runGit(dir, "remote", "get-url", "origin")
+	if origin != cfg.Policy {
+		if err := clonePolicy(cfg.Policy, dir); err != nil {
+			return err
+		}
+	} else {
+		before, _ := runGit(dir, "rev-parse", "HEAD")
+		if _, err := runGit(dir, "fetch", "--quiet", "--depth", "1", "origin"); err != nil {
+			return err
+		}
+		if _, err := runGit(dir, "reset", "--quiet", "--hard", "FETCH_HEAD"); err != nil {
+			return err
+		}
+		if after, _ := runGit(dir, "rev-parse", "HEAD"); after == before {
+			ui.PrintInfo("Policy is up to date")
+			return nil
+		}
+	}
+	if _, err := policy.Load(filepath.Join(dir, policy.FileName)); err != nil {
+		return err
+	}
+	commit, _ := runGit(dir, "rev-parse", "--short", "HEAD")
+	ui.PrintSuccess(fmt.Sprintf("Updated policy to %s", commit))
+	return changesApplied()
+}
+
+// policyViolations returns the ways a profile does not comply with p
+func policyViolations(profileDir string, p *policy.Policy) ([]policy.Violation, error) {
+	var violations []policy.Violation
+
+	if len(p.RequiredEnv) > 0 {
+		content, err := readOptionalFile(filepath.Join(profileDir, ".envrc"))
+		if err != nil {
+			return nil, err
+		}
+		doc := envrc.Parse(content)
+		for _, name := range p.RequiredEnv {
+			if doc.Export(name) == -1 {
+				violations = append(violations, policy.Violation{Rule: policy.RuleRequiredEnv, Message: fmt.Sprintf("%s is not exported in .envrc", name)})
+			}
+		}
+	}
+
+	for _, fp := range p.ForbiddenPatterns {
+		for _, glob := range fp.Files {
+			matches, err := filepath.Glob(filepath.Join(profileDir, filepath.FromSlash(glob)))
+			if err != nil {
+				return nil, fmt.Errorf("invalid file glob %q in policy: %w", glob, err)
+			}
+			for _, match := range matches {
+				lines, err := forbiddenLines(match, fp)
+				if err != nil {
+					return nil, err
+				}
+				rel, _ := filepath.Rel(profileDir, match)
+				for _, line := range lines {
+					// The matching text is left out: it may be the secret the
+					// pattern forbids
+					message := fmt.Sprintf("%s:%d matches forbidden pattern %s", filepath.ToSlash(rel), line, fp.Pattern)
+					if fp.Message != "" {
+						message += ": " + fp.Message
+					}
+					violations = append(violations, policy.Violation{Rule: policy.RuleForbiddenPattern, Message: message})
+				}
+			}
+		}
+	}
+
+	if len(p.RequiredGitignore) > 0 {
+		content, err := readOptionalFile(filepath.Join(profileDir, ".gitignore"))
+		if err != nil {
+			return nil, err
+		}
+		file := gitignore.Parse(content)
+		for _, pattern := range p.RequiredGitignore {
+			if file.Status(pattern) != gitignore.Ignored {
+				violations = append(violations, policy.Violation{Rule: policy.RuleRequiredGitignore, Message: fmt.Sprintf(".gitignore does not ignore %s", pattern)})
+			}
+		}
+	}
+
+	if len(p.RequiredFeatures) > 0 {
+		feats, err := LoadProfileFeatures(profileDir)
+		if err != nil {
+			return nil, err
+		}
+		active := map[string]bool{}
+		for _, feature := range feats {
+			active[feature.Name] = true
+		}
+		for _, name := range p.RequiredFeatures {
+			if !active[name] {
+				violations = append(violations, policy.Violation{Rule: policy.RuleRequiredFeature, Message: fmt.Sprintf("feature %s is not enabled (profile feature enable %s)", name, name)})
+			}
+		}
+	}
+	return violations, nil
+}
+
+// forbiddenLines returns the numbers of the lines of a file that match a
+// forbidden pattern
+func forbiddenLines(path string, fp policy.ForbiddenPattern) ([]int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err != nil || info.IsDir() {
+		return nil, err
+	}
+
+	var lines []int
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if fp.Match(scanner.Text()) {
+			lines = append(lines, n)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// CheckPolicy reports the ways profiles violate the team policy
+func CheckPolicy(profilesDir string, opts PolicyOptions) error {
+	p, err := loadPolicy()
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return NewValidationError("no policy configured (set policy=<git URL or path> in the config)")
+	}
+	profiles, err := selectedProfiles(profilesDir, opts.ProfileName, opts.All)
+	if err != nil {
+		return err
+	}
+
+	if p.Name != "" && !ui.Structured() {
+		fmt.Printf("Policy: %s\n\n", p.Name)
+	}
+	checked := make([]*ProfilePolicy, len(profiles))
+	bulk, unchecked, err := checkSelected("Checking", profiles, opts.All, opts.Jobs, func(i int) ([]string, error) {
+		violations, err := policyViolations(profilePath(profilesDir, profiles[i]), p)
+		if err != nil {
+			return nil, err
+		}
+		checked[i] = &ProfilePolicy{Name: profiles[i], Violations: append([]policy.Violation{}, violations...)}
+		messages := make([]string, len(violations))
+		for j, v := range violations {
+			messages[j] = v.Message
+		}
+		return messages, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	results := []ProfilePolicy{}
+	problems := 0
+	for _, result := range checked {
+		if result != nil {
+			results = append(results, *result)
+			problems += len(result.Violations)
+		}
+	}
+
+	if ui.Structured() {
+		if err := ui.Render(results); err != nil {
+			return err
+		}
+	} else if !bulk {
+		for _, result := range results {
+			if len(result.Violations) == 0 {
+				fmt.Printf("%s✓ %s%s\n", ui.ColorGreen, result.Name, ui.ColorReset)
+				continue
+			}
+			fmt.Printf("%s✗ %s%s\n", ui.ColorRed, result.Name, ui.ColorReset)
+			for _, v := range result.Violations {
+				fmt.Printf("    - %s\n", v)
+			}
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d policy violation(s)", problems)
+	}
+	if unchecked > 0 {
+		return fmt.Errorf("%d of %d profile(s) could not be checked", unchecked, len(profiles))
+	}
+	return nil
+}
+
+// enforcePolicy fails an update that leaves a profile violating the team
+// policy (policy_enforce in the config), listing the violations
+func enforcePolicy(profileDir, profileName string) error {
+	p, err := loadPolicy()
+	if err != nil || p == nil {
+		return err
+	}
+	violations, err := policyViolations(profileDir, p)
+	if err != nil {
+		return err
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	messages := make([]string, len(violations))
+	for i, v := range violations {
+		messages[i] = v.Message
+	}
+	return fmt.Errorf("profile '%s' violates the team policy, so the update is not complete:\n  - %s", profileName, strings.Join(messages, "\n  - "))
+}
@@ -0,0 +1,249 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+	"github.com/mindmorass/shell-profile-manager/internal/presets"
+	"github.com/mindmorass/shell-profile-manager/internal/templates"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// defaultTemplate is the template of profiles created without one
+const defaultTemplate = "basic"
+
+// PresetListing is a preset as shown by 'preset list' with --output
+// json|yaml
+type PresetListing struct {
+	Name         string `json:"name" yaml:"name"`
+	Description  string `json:"description,omitempty" yaml:"description,omitempty"`
+	Template     string `json:"template,omitempty" yaml:"template,omitempty"`
+	TemplateRepo string `json:"template_repo,omitempty" yaml:"template_repo,omitempty"`
+	Source       string `json:"source" yaml:"source"`
+}
+
+// presetFiles returns the files presets are read from, in lookup order:
+// the user's, the one next to the team policy, then those of the template
+// repositories
+func presetFiles() ([]string, error) {
+	paths, err := presets.UserFiles()
+	if err != nil {
+		return nil, err
+	}
+	policyPath, err := policySource()
+	if err != nil {
+		return nil, err
+	}
+	if policyPath != "" {
+		paths = append(paths, filepath.Join(filepath.Dir(policyPath), presets.FileName))
+	}
+	repos, err := templates.LoadRepos()
+	if err != nil {
+		return nil, err
+	}
+	for _, repo := range repos {
+		dir, err := templates.RepoDir(repo.Name)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, filepath.Join(dir, presets.FileName))
+	}
+	return paths, nil
+}
+
+func loadPresets() ([]presets.Preset, error) {
+	paths, err := presetFiles()
+	if err != nil {
+		return nil, err
+	}
+	return presets.Load(paths)
+}
+
+// ListPresets shows the presets profiles can be created from
+func ListPresets() error {
+	all, err := loadPresets()
+	if err != nil {
+		return err
+	}
+
+	listing := []PresetListing{}
+	for _, preset := range all {
+		item := PresetListing{Name: preset.Name, Description: preset.Description, Template: preset.Template, Source: preset.Source}
+		if pin := preset.TemplateRepo; pin != nil {
+			item.TemplateRepo = pin.Name + "@" + valueOr(pin.Commit, valueOr(pin.Ref, "locked"))
+		}
+		listing = append(listing, item)
+	}
+	if ui.Structured() {
+		return ui.Render(listing)
+	}
+
+	if len(listing) == 0 {
+		dir, _ := presets.UserDir()
+		ui.PrintInfo(fmt.Sprintf("No presets (add them to %s, or to %s in the policy or a template repository)", dir, presets.FileName))
+		return nil
+	}
+	table := ui.NewTable("NAME", "TEMPLATE", "DESCRIPTION").Truncate(2, 60)
+	for _, item := range listing {
+		template := valueOr(item.Template, defaultTemplate)
+		if item.TemplateRepo != "" {
+			template += " (" + item.TemplateRepo + ")"
+		}
+		table.AddRow(item.Name, template, item.Description)
+	}
+	table.Render(os.Stdout)
+	return nil
+}
+
+// applyPreset fills the options of a new profile from opts.Preset. Options
+// given explicitly win over the preset's; features, tags, and vars are
+// merged. It is safe to apply a preset twice, as 'profile apply' does for
+// a saved plan.
+func applyPreset(opts *CreateOptions) error {
+	if opts.Preset == "" {
+		if opts.Template == "" {
+			opts.Template = defaultTemplate
+		}
+		return nil
+	}
+	all, err := loadPresets()
+	if err != nil {
+		return err
+	}
+	preset, ok := presets.Find(all, opts.Preset)
+	if !ok {
+		return NewValidationError("unknown preset: %s (see 'profile preset list')", opts.Preset)
+	}
+	if err := pinTemplateRepo(preset, opts.DryRun); err != nil {
+		return err
+	}
+
+	opts.Template = valueOr(opts.Template, valueOr(preset.Template, defaultTemplate))
+	if m := preset.Manifest; m != nil {
+		opts.Features, opts.DisableFeatures = mergeNames(without(m.EnabledFeatures(), opts.DisableFeatures), opts.Features), mergeNames(without(m.DisabledFeatures(), opts.Features), opts.DisableFeatures)
+		opts.Tags = mergeNames(m.Tags, opts.Tags)
+		opts.Description = valueOr(opts.Description, m.Description)
+		opts.Owner = valueOr(opts.Owner, m.Owner)
+		opts.Extends = valueOr(opts.Extends, m.Extends)
+		if m.Secrets != nil {
+			opts.SecretsProvider = valueOr(opts.SecretsProvider, m.Secrets.Provider)
+		}
+		if len(m.Vars) > 0 {
+			vars := map[string]string{}
+			for key, value := range m.Vars {
+				vars[key] = value
+			}
+			for key, value := range opts.Vars {
+				vars[key] = value
+			}
+			opts.Vars = vars
+		}
+	}
+	opts.preset = &preset
+	return nil
+}
+
+// presetManifest copies the profile.yaml values of a preset that have no
+// create option into the manifest of a new profile
+func presetManifest(m *manifest.Manifest, preset *presets.Preset, secretsProvider string) {
+	if preset == nil {
+		return
+	}
+	m.Preset = preset.Name
+	defaults := preset.Manifest
+	if defaults == nil {
+		return
+	}
+	m.Layer, m.Go, m.Tools, m.Tmux, m.Encrypt, m.Backup = defaults.Layer, defaults.Go, defaults.Tools, defaults.Tmux, defaults.Encrypt, defaults.Backup
+	// The provider settings only apply when the provider was not changed
+	if defaults.Secrets != nil && defaults.Secrets.Provider == secretsProvider {
+		secrets := *defaults.Secrets
+		m.Secrets = &secrets
+	}
+}
+
+// pinTemplateRepo registers the template repository a preset pins, or
+// moves its locked commit to the pinned one, so the template is rendered
+// as the preset was written for. A dry run only checks that it is.
+func pinTemplateRepo(preset presets.Preset, dryRun bool) error {
+	pin := preset.TemplateRepo
+	if pin == nil {
+		return nil
+	}
+	repos, err := templates.LoadRepos()
+	if err != nil {
+		return err
+	}
+	index := slices.IndexFunc(repos, func(repo templates.Repo) bool { return repo.Name == pin.Name })
+	repo := templates.Repo{Name: pin.Name, URL: pin.URL, Ref: pin.Ref}
+	if index >= 0 {
+		repo = repos[index]
+		if repo.URL != pin.URL {
+			return NewValidationError("preset %s pins template repository %s to %s, but it is registered with %s", preset.Name, pin.Name, pin.URL, repo.URL)
+		}
+	}
+
+	dir, err := templates.RepoDir(pin.Name)
+	if err != nil {
+		return err
+	}
+	_, statErr := os.Stat(filepath.Join(dir, ".git"))
+	if index >= 0 && statErr == nil && strings.HasPrefix(repo.Commit, pin.Commit) {
+		return nil
+	}
+	if dryRun {
+		return NewValidationError("preset %s needs template repository %s at %s; create the profile without --dry-run to fetch it", preset.Name, pin.Name, valueOr(pin.Commit, valueOr(pin.Ref, "its default branch")))
+	}
+
+	// Without a pinned commit a registered repository stays at its lock
+	target := pin.Commit
+	if target == "" {
+		target = repo.Commit
+	}
+	ui.PrintInfo(fmt.Sprintf("Fetching template repository %s for preset %s...", pin.URL, preset.Name))
+	if err := cloneTemplateRepo(&repo, target); err != nil {
+		return err
+	}
+	if commit, err := runGit(dir, "rev-parse", "HEAD"); err == nil {
+		repo.Commit = commit
+	}
+	if index >= 0 {
+		repos[index] = repo
+	} else {
+		repos = append(repos, repo)
+	}
+	if err := templates.SaveRepos(repos); err != nil {
+		return newIOError(err, "failed to save template repositories")
+	}
+	ui.PrintInfo(fmt.Sprintf("Template repository %s is at %s", repo.Name, templates.ShortCommit(repo.Commit)))
+	return nil
+}
+
+// mergeNames appends the names of extra missing from names
+func mergeNames(names, extra []string) []string {
+	merged := append([]string{}, names...)
+	for _, name := range extra {
+		if !slices.Contains(merged, name) {
+			merged = append(merged, name)
+		}
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// without returns names except those in exclude
+func without(names, exclude []string) []string {
+	var kept []string
+	for _, name := range names {
+		if !slices.Contains(exclude, name) {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// ListProfileNames returns the names of all profiles in profilesDir,
+// followed by those in the additional roots (named <root>/<profile>).
+// A profile is any directory (other than .git) containing an .envrc file.
+func ListProfileNames(profilesDir string) ([]string, error) {
+	profiles, err := profileDirNames(profilesDir)
+	if err != nil {
+		return nil, newIOError(err, "failed to read profiles directory")
+	}
+	profiles = append(profiles, rootProfileNames()...)
+	saveProfileCache()
+	return profiles, nil
+}
+
+// profileDirNames returns the names of the profiles in one directory,
+// from the profile cache while the directory is unchanged
+func profileDirNames(profilesDir string) ([]string, error) {
+	return cachedProfileDirNames(profilesDir, scanProfileDir)
+}
+
+// scanProfileDir returns the names of the directories of profilesDir that
+// hold an .envrc file
+func scanProfileDir(profilesDir string) ([]string, error) {
+	entries, err := os.ReadDir(profilesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []string
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != ".git" {
+			profilePath := filepath.Join(profilesDir, entry.Name())
+			envrcPath := filepath.Join(profilePath, ".envrc")
+			if _, err := os.Stat(envrcPath); err == nil {
+				profiles = append(profiles, entry.Name())
+			}
+		}
+	}
+
+	return profiles, nil
+}
+
+// PromptForProfile asks the user to pick one of the existing profiles.
+// It fails fast when prompts are unavailable so scripts get a clear error
+// instead of hanging on a selection menu.
+func PromptForProfile(profilesDir, message string) (string, error) {
+	profiles, err := ListProfileNames(profilesDir)
+	if err != nil {
+		return "", err
+	}
+
+	if len(profiles) == 0 {
+		return "", NewValidationError("no profiles found")
+	}
+
+	if !ui.IsInteractive() {
+		return "", NewValidationError("profile name is required when running non-interactively")
+	}
+
+	return ui.SelectProfileChoice(profileChoices(profilesDir, profiles), message)
+}
+
+// profileChoices describes profiles for the selector with their aliases
+// and the description and tags from their manifests
+func profileChoices(profilesDir string, profiles []string) []ui.ProfileChoice {
+	choices := make([]ui.ProfileChoice, len(profiles))
+	for i, profileName := range profiles {
+		choices[i] = ui.ProfileChoice{Name: profileName}
+		summary := cachedProfileSummary(newProfilePath(profilesDir, profileName))
+		choices[i].Description, choices[i].Tags = summary.Description, summary.Tags
+		choices[i].Aliases = aliasesOf(profilesDir, profileName, summary.Aliases)
+	}
+	saveProfileCache()
+	return choices
+}
+
+// resolveProfile returns the profile name and directory, prompting for the
+// name when it is empty, and checks that the profile exists
+func resolveProfile(profilesDir, profileName, message string) (string, string, error) {
+	if profileName == "" {
+		selected, err := PromptForProfile(profilesDir, message)
+		if err != nil {
+			return "", "", err
+		}
+		profileName = selected
+	}
+
+	if err := checkAmbiguous(profilesDir, profileName); err != nil {
+		return "", "", err
+	}
+	profileName = qualifiedName(profilesDir, profileName)
+	profileDir := profilePath(profilesDir, profileName)
+	if _, err := os.Stat(filepath.Join(profileDir, ".envrc")); os.IsNotExist(err) {
+		return "", "", NewValidationError("profile '%s' does not exist at: %s", profileName, profileDir)
+	}
+
+	return profileName, profileDir, nil
+}
@@ -0,0 +1,122 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+	"github.com/mindmorass/shell-profile-manager/internal/shell"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type PromptOptions struct {
+	ProfileName string
+	// Color is a starship style such as "bold red" or "#ff8800"
+	Color string
+	// Force overwrites an existing prompt config
+	Force bool
+}
+
+// GeneratePrompt writes a starter starship config that shows the profile
+// name in the prompt, enabling the starship feature if needed
+func GeneratePrompt(profilesDir string, opts PromptOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	configPath := filepath.Join(profileDir, ".config", "starship.toml")
+	if _, err := os.Stat(configPath); err == nil && !opts.Force {
+		confirmed, err := ui.Confirm(fmt.Sprintf("Overwrite %s?", configPath), false)
+		if err != nil {
+			return NewValidationError("%s already exists (use --force to overwrite)", configPath)
+		}
+		if !confirmed {
+			ui.PrintInfo("Prompt generation cancelled")
+			return nil
+		}
+	}
+
+	if err := ensureFeature(profilesDir, profileName, profileDir, "starship"); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return newIOError(err, "failed to create %s", filepath.Dir(configPath))
+	}
+	if err := writeProfileFile(configPath, []byte(renderStarshipConfig(profileName, valueOr(opts.Color, "bold purple"))), 0644); err != nil {
+		return newIOError(err, "failed to write %s", configPath)
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Wrote %s", configPath))
+	fmt.Println("  Starship picks it up through STARSHIP_CONFIG the next time the profile loads")
+	return changesApplied()
+}
+
+// renderStarshipConfig returns a starship.toml that prefixes the default
+// prompt with the workspace profile name
+func renderStarshipConfig(profileName, color string) string {
+	return fmt.Sprintf(`# Starship prompt for workspace profile: %s (STARSHIP_CONFIG)
+# Generated by 'profile prompt'; edit freely. See https://starship.rs/config/
+"$schema" = 'https://starship.rs/config-schema.json'
+
+# Show the workspace profile before the default modules
+format = "${env_var.WORKSPACE_PROFILE}$all"
+
+[env_var.WORKSPACE_PROFILE]
+format = '[\[$env_value\]]($style) '
+style = %q
+`, profileName, color)
+}
+
+type HookOptions struct {
+	// Shell is bash, zsh, or fish (default: the shell in $SHELL)
+	Shell string
+	// Color prefixes the prompt with the profile name in this color
+	Color string
+	// WithCd also defines a function that changes to a profile
+	WithCd bool
+	// CdName names that function (default: pcd)
+	CdName string
+}
+
+// PrintPromptHook prints the shell snippet that exposes the active profile
+// in SPM_PROFILE, for prompts not managed by starship, and loads the
+// profiles linked to directories; with --with-cd also the function that
+// changes to a profile
+func PrintPromptHook(opts HookOptions) error {
+	name := valueOr(opts.Shell, shell.Detect())
+	if name == "" {
+		return NewValidationError("shell is required (%s)", strings.Join(shell.Names(), ", "))
+	}
+
+	hook, err := shell.PromptHook(name, opts.Color)
+	if err != nil {
+		return NewValidationError("%v", err)
+	}
+	linksFile, err := config.LinksPath()
+	if err != nil {
+		return err
+	}
+	links, err := shell.LinkHook(name, linksFile)
+	if err != nil {
+		return NewValidationError("%v", err)
+	}
+	hook += links
+	if opts.WithCd {
+		cd, err := shell.CdFunction(name, valueOr(opts.CdName, "pcd"))
+		if err != nil {
+			return NewValidationError("%v", err)
+		}
+		hook += cd
+	}
+	fmt.Print(hook)
+	return nil
+}
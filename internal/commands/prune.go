@@ -0,0 +1,394 @@
+package commands
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// What prune does with an orphaned directory
+const (
+	OrphanAdopt   = "adopt"
+	OrphanArchive = "archive"
+	OrphanDelete  = "delete"
+	// orphanSkip leaves it as it is (interactive only)
+	orphanSkip = "skip"
+)
+
+// profileFiles are files create writes besides .envrc; a directory holding
+// any of them was a profile
+var profileFiles = []string{manifest.FileName, ".gitconfig", ".gitignore", "README.md"}
+
+type PruneOptions struct {
+	// Orphans prunes directories in the profile roots that are not
+	// profiles because they have no .envrc
+	Orphans bool
+	// Names limits pruning to these orphans (default: all of them)
+	Names []string
+	// Action is adopt, archive, or delete; without one the orphans are
+	// listed, and in a terminal prune asks what to do with each
+	Action string
+	// Force deletes without asking for confirmation
+	Force  bool
+	DryRun bool
+}
+
+// Orphan is a directory in a profile root that discovery skips, as shown
+// by 'profile prune --orphans'
+type Orphan struct {
+	// Name is what the profile would be named (qualified with its root)
+	Name   string `json:"name" yaml:"name"`
+	Path   string `json:"path" yaml:"path"`
+	Reason string `json:"reason" yaml:"reason"`
+}
+
+// PruneProfiles finds the directories in profiles_dir and the other roots
+// that are skipped as profiles, explains why, and adopts, archives, or
+// deletes them
+func PruneProfiles(profilesDir string, opts PruneOptions) error {
+	if !opts.Orphans {
+		return NewValidationError("nothing to prune: pass --orphans")
+	}
+	switch opts.Action {
+	case "", OrphanAdopt, OrphanArchive, OrphanDelete:
+	default:
+		return NewValidationError("unknown prune action: %s (use adopt, archive, or delete)", opts.Action)
+	}
+
+	orphans, err := findOrphans(profilesDir)
+	if err != nil {
+		return err
+	}
+	if len(opts.Names) > 0 {
+		if orphans, err = selectOrphans(profilesDir, orphans, opts.Names); err != nil {
+			return err
+		}
+	}
+
+	if opts.Action == "" {
+		if ui.Structured() {
+			return ui.Render(append([]Orphan{}, orphans...))
+		}
+		if len(orphans) == 0 {
+			ui.PrintSuccess("No orphaned directories")
+			return nil
+		}
+		table := ui.NewTable("DIRECTORY", "REASON").Truncate(1, 70)
+		for _, o := range orphans {
+			table.AddRow(o.Name, o.Reason)
+		}
+		table.Render(os.Stdout)
+		if opts.DryRun || !ui.IsInteractive() {
+			fmt.Println()
+			ui.PrintInfo("Adopt them as profiles, archive, or delete them with --adopt, --archive, or --delete")
+			return nil
+		}
+		fmt.Println()
+	} else if len(orphans) == 0 {
+		ui.PrintSuccess("No orphaned directories")
+		return nil
+	}
+
+	changed := 0
+	for _, o := range orphans {
+		action := opts.Action
+		if action == "" {
+			if action, err = ui.Select(fmt.Sprintf("What should be done with %s?", o.Name), []string{orphanSkip, OrphanAdopt, OrphanArchive, OrphanDelete}, orphanSkip); err != nil {
+				return err
+			}
+		}
+		done, err := pruneOrphan(o, action, opts)
+		if err != nil {
+			return err
+		}
+		if done {
+			changed++
+		}
+	}
+	saveProfileCache()
+
+	if changed == 0 || opts.DryRun {
+		return nil
+	}
+	return changesApplied()
+}
+
+// findOrphans returns the directories of the profile roots without a
+// usable .envrc. Hidden directories are not considered.
+func findOrphans(profilesDir string) ([]Orphan, error) {
+	type profileRoot struct{ name, dir string }
+	roots := []profileRoot{{"", profilesDir}}
+	for _, name := range rootNames() {
+		roots = append(roots, profileRoot{name, profileRoots[name]})
+	}
+
+	var orphans []Orphan
+	for _, root := range roots {
+		entries, err := os.ReadDir(root.dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, newIOError(err, "failed to read %s", root.dir)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			dir := filepath.Join(root.dir, entry.Name())
+			if isProfileDir(dir) {
+				continue
+			}
+			name := entry.Name()
+			if root.name != "" {
+				name = root.name + "/" + name
+			}
+			orphans = append(orphans, Orphan{Name: name, Path: dir, Reason: orphanReason(dir)})
+		}
+	}
+	return orphans, nil
+}
+
+// selectOrphans returns the orphans named
+func selectOrphans(profilesDir string, orphans []Orphan, names []string) ([]Orphan, error) {
+	var selected []Orphan
+	for _, name := range names {
+		found := false
+		for _, o := range orphans {
+			if o.Name == name {
+				selected = append(selected, o)
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		if isProfileDir(profilePath(profilesDir, name)) {
+			return nil, NewValidationError("%s is a profile, not an orphaned directory", name)
+		}
+		return nil, NewValidationError("no orphaned directory named %s", name)
+	}
+	return selected, nil
+}
+
+// orphanReason explains why a directory is not a profile
+func orphanReason(dir string) string {
+	if _, err := os.Lstat(filepath.Join(dir, ".envrc")); err == nil {
+		target, _ := os.Readlink(filepath.Join(dir, ".envrc"))
+		return fmt.Sprintf(".envrc is a broken symbolic link to %s", target)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Sprintf("no .envrc, and the directory cannot be read: %v", err)
+	}
+	if len(entries) == 0 {
+		return "empty directory"
+	}
+	var found []string
+	for _, file := range profileFiles {
+		if _, err := os.Stat(filepath.Join(dir, file)); err == nil {
+			found = append(found, file)
+		}
+	}
+	if len(found) > 0 {
+		return fmt.Sprintf("no .envrc, but has %s: its .envrc was removed", strings.Join(found, ", "))
+	}
+	return "no .envrc and no profile files: not created by spm"
+}
+
+// pruneOrphan applies an action to an orphan and reports whether it did
+func pruneOrphan(o Orphan, action string, opts PruneOptions) (bool, error) {
+	switch action {
+	case OrphanAdopt:
+		if !profileNamePattern.MatchString(baseProfileName(o.Name)) {
+			return false, NewValidationError("cannot adopt %s: profile names can only contain letters, numbers, hyphens, and underscores", o.Name)
+		}
+		if opts.DryRun {
+			fmt.Printf("  Would adopt %s (create .envrc)\n", o.Name)
+			return true, nil
+		}
+		return true, adoptOrphan(o)
+	case OrphanArchive:
+		if opts.DryRun {
+			fmt.Printf("  Would archive %s and remove it\n", o.Name)
+			return true, nil
+		}
+		return true, archiveOrphan(o)
+	case OrphanDelete:
+		if opts.DryRun {
+			fmt.Printf("  Would delete %s\n", o.Name)
+			return true, nil
+		}
+		if !opts.Force {
+			confirmed, err := ui.Confirm(fmt.Sprintf("Delete %s and all its files?", o.Path), false)
+			if err != nil {
+				return false, fmt.Errorf("failed to get confirmation: %w", err)
+			}
+			if !confirmed {
+				ui.PrintInfo(fmt.Sprintf("Kept %s", o.Name))
+				return false, nil
+			}
+		}
+		return true, removeOrphan(o)
+	default:
+		return false, nil
+	}
+}
+
+// adoptOrphan makes a directory a profile by writing the .envrc of its
+// features, and a manifest if it has none. The files it already has are
+// kept; 'profile update' adds the others.
+func adoptOrphan(o Orphan) error {
+	unlock, err := lockProfile(o.Path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	feats, err := LoadProfileFeatures(o.Path)
+	if err != nil {
+		return err
+	}
+	content, err := renderForProfile(o.Path, "envrc", feats)
+	if err != nil {
+		return NewValidationError("%v", err)
+	}
+	if err := createEnvrc(o.Path, content); err != nil {
+		return newIOError(err, "failed to create .envrc in %s", o.Path)
+	}
+	if _, err := os.Stat(manifest.Path(o.Path)); os.IsNotExist(err) {
+		if err := manifest.Save(o.Path, manifest.New(CurrentSchemaVersion())); err != nil {
+			return newIOError(err, "failed to create profile manifest")
+		}
+	}
+	recordChecksums(o.Path, ".envrc")
+	recordHistory(o.Path, "prune --orphans", []string{"Adopted: created .envrc"})
+	// Adding the .envrc leaves the root directory as it was
+	forgetProfileDir(filepath.Dir(o.Path))
+
+	ui.PrintSuccess(fmt.Sprintf("Adopted %s; run 'profile update %s' to add the directories and files of its features", o.Name, o.Name))
+	return nil
+}
+
+// archiveOrphan packs a directory into a tar.gz in the state directory
+// ($XDG_STATE_HOME/spm/archive) and removes it
+func archiveOrphan(o Orphan) error {
+	stateDir, err := config.StateDir()
+	if err != nil {
+		return err
+	}
+	archiveDir := filepath.Join(stateDir, "archive")
+	if err := os.MkdirAll(archiveDir, 0700); err != nil {
+		return newIOError(err, "failed to create %s", archiveDir)
+	}
+	archivePath := filepath.Join(archiveDir, fmt.Sprintf("%s_%s.tar.gz", strings.ReplaceAll(o.Name, "/", "-"), time.Now().Format(backupDateFormat)))
+
+	unlock, err := lockProfile(o.Path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := writeArchive(o.Path, archivePath); err != nil {
+		return newIOError(err, "failed to archive %s", o.Path)
+	}
+	if err := os.RemoveAll(o.Path); err != nil {
+		return newIOError(err, "failed to remove %s", o.Path)
+	}
+	ui.PrintSuccess(fmt.Sprintf("Archived %s to %s", o.Name, archivePath))
+	fmt.Printf("  Restore it with: tar -xzf %s -C %s\n", archivePath, filepath.Dir(o.Path))
+	return nil
+}
+
+// removeOrphan deletes a directory
+func removeOrphan(o Orphan) error {
+	unlock, err := lockProfile(o.Path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := os.RemoveAll(o.Path); err != nil {
+		return newIOError(err, "failed to delete %s", o.Path)
+	}
+	ui.PrintSuccess(fmt.Sprintf("Deleted %s", o.Name))
+	return nil
+}
+
+// writeArchive writes dir to a new gzipped tar archive at path, under its
+// base name. Symbolic links are stored as links. A failed archive is
+// removed.
+func writeArchive(dir, path string) (err error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	base := filepath.Base(dir)
+	err = filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		if d.Type()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(filepath.Join(base, rel))
+		if d.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		src, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
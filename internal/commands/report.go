@@ -0,0 +1,21 @@
+package commands
+
+import "github.com/mindmorass/shell-profile-manager/internal/ui"
+
+// Reporter receives the progress messages and non-fatal warnings of the
+// steps that write profiles. The CLI prints them; the public API in
+// pkg/profile hands them to its caller instead.
+type Reporter struct {
+	Info    func(msg string)
+	Success func(msg string)
+	Warning func(msg string)
+}
+
+var reporter = Reporter{Info: ui.PrintInfo, Success: ui.PrintSuccess, Warning: ui.PrintWarning}
+
+// SetReporter replaces the reporter and returns the previous one
+func SetReporter(r Reporter) Reporter {
+	previous := reporter
+	reporter = r
+	return previous
+}
@@ -0,0 +1,327 @@
+package commands
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// defaultRoot names profiles_dir where a root can be chosen
+const defaultRoot = "default"
+
+// profileRoots are the profile directories configured besides profiles_dir
+// ([roots] in the config file), by name. A profile in one of them is
+// named <root>/<profile>, e.g. "work/acme".
+var profileRoots map[string]string
+
+// SetProfileRoots sets the additional profile roots and returns the
+// previous ones
+func SetProfileRoots(roots map[string]string) map[string]string {
+	previous := profileRoots
+	profileRoots = roots
+	return previous
+}
+
+// rootNames returns the names of the additional roots, sorted
+func rootNames() []string {
+	names := make([]string, 0, len(profileRoots))
+	for name := range profileRoots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// splitProfileName splits a profile name qualified with a root ("work/acme")
+// into the root and the name of the profile within it. The root is empty
+// for profiles in profiles_dir.
+func splitProfileName(profileName string) (string, string) {
+	if root, name, ok := strings.Cut(profileName, "/"); ok {
+		return root, name
+	}
+	return "", profileName
+}
+
+// baseProfileName returns the name of a profile without its root, as used
+// inside the profile (WORKSPACE_PROFILE, README.md, key names)
+func baseProfileName(profileName string) string {
+	_, name := splitProfileName(profileName)
+	return name
+}
+
+// qualifiedName returns the name a profile is listed by. An unqualified
+// name that is not in profilesDir is qualified with the root holding it,
+// when exactly one does; a name no profile has is looked up as an alias.
+func qualifiedName(profilesDir, profileName string) string {
+	if strings.Contains(profileName, "/") {
+		return profileName
+	}
+	if _, err := os.Stat(filepath.Join(profilesDir, profileName)); err == nil {
+		return profileName
+	}
+	matches := rootMatches(profileName)
+	if len(matches) == 1 {
+		return matches[0]
+	}
+	if targets := aliasTargets(profilesDir, profileName); len(matches) == 0 && len(targets) == 1 {
+		return targets[0]
+	}
+	return profileName
+}
+
+// lookupName qualifies a profile name with its root, as qualifiedName does,
+// without looking up aliases
+func lookupName(profilesDir, profileName string) string {
+	if strings.Contains(profileName, "/") {
+		return profileName
+	}
+	if _, err := os.Stat(filepath.Join(profilesDir, profileName)); err == nil {
+		return profileName
+	}
+	if matches := rootMatches(profileName); len(matches) == 1 {
+		return matches[0]
+	}
+	return profileName
+}
+
+// profilePath returns the directory of a profile: qualified names are
+// looked up in their root, others in profilesDir and then in the roots
+func profilePath(profilesDir, profileName string) string {
+	return newProfilePath(profilesDir, qualifiedName(profilesDir, profileName))
+}
+
+// newProfilePath returns the directory a profile is created in: in its root
+// for a qualified name, in profilesDir otherwise
+func newProfilePath(profilesDir, profileName string) string {
+	root, name := splitProfileName(profileName)
+	if dir, ok := profileRoots[root]; ok {
+		return filepath.Join(dir, name)
+	}
+	return filepath.Join(profilesDir, profileName)
+}
+
+// isActiveProfile reports whether a profile is the one loaded in the
+// current environment. Profiles in different roots can share a name, so
+// for those in other roots WORKSPACE_HOME has to match as well.
+func isActiveProfile(profileName, profileDir string) bool {
+	if baseProfileName(profileName) != os.Getenv("WORKSPACE_PROFILE") {
+		return false
+	}
+	root, _ := splitProfileName(profileName)
+	return root == "" || os.Getenv("WORKSPACE_HOME") == profileDir
+}
+
+// ProfilePath returns the directory of a profile, resolving names of
+// profiles in other roots as profile commands do
+func ProfilePath(profilesDir, profileName string) string {
+	return profilePath(profilesDir, profileName)
+}
+
+// NewProfilePath returns the directory create makes a profile in
+func NewProfilePath(profilesDir, profileName string) string {
+	return newProfilePath(profilesDir, profileName)
+}
+
+// rootMatches returns the qualified names of the profiles called name in
+// the additional roots
+func rootMatches(name string) []string {
+	var matches []string
+	for _, root := range rootNames() {
+		if _, err := os.Stat(filepath.Join(profileRoots[root], name, ".envrc")); err == nil {
+			matches = append(matches, root+"/"+name)
+		}
+	}
+	return matches
+}
+
+// checkProfileRoot validates the root of a qualified profile name
+func checkProfileRoot(profileName string) error {
+	root, _ := splitProfileName(profileName)
+	if root == "" {
+		return nil
+	}
+	if _, ok := profileRoots[root]; !ok {
+		if len(profileRoots) == 0 {
+			return NewValidationError("unknown profile root: %s (no roots are configured; see 'profile root --help')", root)
+		}
+		return NewValidationError("unknown profile root: %s (configured: %s)", root, strings.Join(rootNames(), ", "))
+	}
+	return nil
+}
+
+// checkAmbiguous rejects an unqualified name that is not in profilesDir but
+// is in several roots
+func checkAmbiguous(profilesDir, profileName string) error {
+	if strings.Contains(profileName, "/") {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(profilesDir, profileName)); err == nil {
+		return nil
+	}
+	matches := rootMatches(profileName)
+	if len(matches) > 1 {
+		return NewValidationError("profile '%s' exists in several roots: %s (use the qualified name)", profileName, strings.Join(matches, ", "))
+	}
+	if targets := aliasTargets(profilesDir, profileName); len(matches) == 0 && len(targets) > 1 {
+		return NewValidationError("alias '%s' names several profiles: %s (use the profile name)", profileName, strings.Join(targets, ", "))
+	}
+	return nil
+}
+
+// rootProfileNames returns the qualified names of the profiles in the
+// additional roots. Roots that are not available, such as an encrypted
+// volume that is not mounted, are skipped.
+func rootProfileNames() []string {
+	var names []string
+	for _, root := range rootNames() {
+		profiles, err := profileDirNames(profileRoots[root])
+		if err != nil {
+			slog.Debug("skipping unavailable profile root", "root", root, "path", profileRoots[root], "error", err)
+			continue
+		}
+		for _, profileName := range profiles {
+			names = append(names, root+"/"+profileName)
+		}
+	}
+	return names
+}
+
+// checkRoots reports whether the additional profile roots are available
+func checkRoots() []DoctorCheck {
+	var checks []DoctorCheck
+	for _, root := range rootNames() {
+		path := profileRoots[root]
+		if _, err := os.Stat(path); err != nil {
+			checks = append(checks, DoctorCheck{
+				Status:  "warning",
+				Message: fmt.Sprintf("Profile root '%s' is not available: %s", root, path),
+				Details: []string{"Its profiles are left out until it is (mount it, or run: profile root remove " + root + ")"},
+			})
+			continue
+		}
+		checks = append(checks, DoctorCheck{Status: "ok", Message: fmt.Sprintf("Profile root '%s' is available", root)})
+	}
+	return checks
+}
+
+// RootSummary is a profile root as listed with --output json|yaml
+type RootSummary struct {
+	Name      string `json:"name" yaml:"name"`
+	Path      string `json:"path" yaml:"path"`
+	Available bool   `json:"available" yaml:"available"`
+	Profiles  int    `json:"profiles" yaml:"profiles"`
+}
+
+// RootSummaries describes profiles_dir (as the root named "default") and
+// the additional roots
+func RootSummaries(profilesDir string) []RootSummary {
+	summaries := []RootSummary{{Name: defaultRoot, Path: profilesDir}}
+	for _, root := range rootNames() {
+		summaries = append(summaries, RootSummary{Name: root, Path: profileRoots[root]})
+	}
+	for i := range summaries {
+		if profiles, err := profileDirNames(summaries[i].Path); err == nil {
+			summaries[i].Available, summaries[i].Profiles = true, len(profiles)
+		}
+	}
+	return summaries
+}
+
+type RootOptions struct {
+	Name string
+	Path string
+}
+
+// ListRoots shows profiles_dir and the additional profile roots, with
+// whether each is available and how many profiles it holds
+func ListRoots(profilesDir string) error {
+	summaries := RootSummaries(profilesDir)
+	if ui.Structured() {
+		return ui.Render(summaries)
+	}
+
+	table := ui.NewTable("ROOT", "PROFILES", "PATH").Truncate(2, 60)
+	for _, summary := range summaries {
+		profiles := fmt.Sprintf("%d", summary.Profiles)
+		if !summary.Available {
+			profiles = fmt.Sprintf("%s⚠ not available%s", ui.ColorYellow, ui.ColorReset)
+		}
+		table.AddRow(summary.Name, profiles, summary.Path)
+	}
+	table.Render(os.Stdout)
+	if len(summaries) == 1 {
+		fmt.Println()
+		fmt.Println("Add a root with: profile root add <name> <path>")
+	}
+	return nil
+}
+
+// AddRoot adds a profile root to the config file. The directory does not
+// have to exist yet, e.g. on a volume that is not mounted.
+func AddRoot(profilesDir string, opts RootOptions) error {
+	if opts.Name == "" || opts.Path == "" {
+		return NewValidationError("a root name and path are required")
+	}
+	if !profileNamePattern.MatchString(opts.Name) || opts.Name == defaultRoot {
+		return NewValidationError("invalid root name: %s (use letters, numbers, hyphens, and underscores; '%s' is profiles_dir)", opts.Name, defaultRoot)
+	}
+	path, err := filepath.Abs(expandPath(opts.Path))
+	if err != nil {
+		return NewValidationError("invalid root path %s: %v", opts.Path, err)
+	}
+	if path == filepath.Clean(profilesDir) {
+		return NewValidationError("%s is profiles_dir", path)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if existing, ok := cfg.Roots[opts.Name]; ok && existing != path {
+		return NewValidationError("root '%s' already points to %s (remove it first)", opts.Name, existing)
+	}
+	if cfg.Roots == nil {
+		cfg.Roots = map[string]string{}
+	}
+	cfg.Roots[opts.Name] = path
+	if err := config.SaveConfig(cfg); err != nil {
+		return newIOError(err, "failed to save config")
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Added profile root %s: %s", opts.Name, path))
+	if _, err := os.Stat(path); err != nil {
+		ui.PrintWarning(fmt.Sprintf("%s is not available yet; its profiles are listed once it is", path))
+	}
+	fmt.Printf("  Create profiles in it with: profile create %s/<name>\n", opts.Name)
+	return changesApplied()
+}
+
+// RemoveRoot removes a profile root from the config file. Its profiles
+// are left in place.
+func RemoveRoot(opts RootOptions) error {
+	if opts.Name == "" {
+		return NewValidationError("a root name is required")
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	path, ok := cfg.Roots[opts.Name]
+	if !ok {
+		return NewValidationError("unknown profile root: %s", opts.Name)
+	}
+	delete(cfg.Roots, opts.Name)
+	if err := config.SaveConfig(cfg); err != nil {
+		return newIOError(err, "failed to save config")
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Removed profile root %s", opts.Name))
+	fmt.Printf("  Its profiles were left in %s\n", path)
+	return changesApplied()
+}
@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+
+	"github.com/mindmorass/shell-profile-manager/internal/direnv"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type RunOptions struct {
+	ProfileName string
+	// Default is the default profile of the config file, used with the
+	// last used profile when no name is given
+	Default string
+	// Command is the program and its arguments
+	Command []string
+}
+
+// RunInProfile runs a command with the environment of a profile, as if it
+// were run in a shell where direnv loaded the profile, without changing
+// the working directory. Without a profile name the default profile of the
+// config file is used, then the one last used.
+func RunInProfile(profilesDir string, opts RunOptions) error {
+	if len(opts.Command) == 0 {
+		return NewValidationError("no command given (usage: profile run [-p name] -- <command> [args...])")
+	}
+
+	if opts.ProfileName == "" {
+		if name, source := fallbackProfile(profilesDir, opts.Default); name != "" {
+			// stdout belongs to the command
+			fmt.Fprintf(os.Stderr, "%sUsing the %s: %s%s\n", ui.ColorBlue, source, name, ui.ColorReset)
+			opts.ProfileName = name
+		}
+	}
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile to run in:")
+	if err != nil {
+		return err
+	}
+	vars, err := ProfileEnv(profileDir, profileName)
+	if err != nil {
+		return err
+	}
+	RecordLastProfile(profileName)
+
+	env := direnv.BaseEnv()
+	for name, value := range vars {
+		if value == nil {
+			delete(env, name)
+		} else {
+			env[name] = *value
+		}
+	}
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cmd := exec.Command(opts.Command[0], opts.Command[1:]...)
+	for _, name := range names {
+		cmd.Env = append(cmd.Env, name+"="+env[name])
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			return &CommandExitError{Command: opts.Command[0], Code: exitErr.ExitCode()}
+		}
+		return fmt.Errorf("failed to run %s: %w", opts.Command[0], err)
+	}
+	return nil
+}
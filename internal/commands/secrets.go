@@ -0,0 +1,502 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+	"github.com/mindmorass/shell-profile-manager/internal/plugins"
+	"github.com/mindmorass/shell-profile-manager/internal/secrets"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type SecretsOptions struct {
+	ProfileName string
+	VarName     string
+	Ref         string
+	// Vault settings
+	Address    string
+	Namespace  string
+	Role       string
+	AuthMethod string
+	// Bitwarden Secrets Manager settings
+	ServerURL string
+	// Service namespaces keychain items (default: profile:<name>)
+	Service string
+}
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ConfigureVault selects HashiCorp Vault as the profile's secrets provider
+func ConfigureVault(profilesDir string, opts SecretsOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile to configure Vault for:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return err
+	}
+	if m.Secrets == nil {
+		m.Secrets = &manifest.Secrets{}
+	}
+	if m.Secrets.Provider != "" && m.Secrets.Provider != "vault" && len(m.Secrets.Vars) > 0 {
+		return NewValidationError("profile '%s' already uses the %s provider for %d secret(s); remove them first", profileName, m.Secrets.Provider, len(m.Secrets.Vars))
+	}
+	m.Secrets.Provider = "vault"
+	if m.Secrets.Vault == nil {
+		m.Secrets.Vault = &manifest.VaultConfig{}
+	}
+
+	vault := m.Secrets.Vault
+	if opts.Address != "" {
+		vault.Address = opts.Address
+	}
+	if opts.Namespace != "" {
+		vault.Namespace = opts.Namespace
+	}
+	if opts.Role != "" {
+		vault.Role = opts.Role
+	}
+	if opts.AuthMethod != "" {
+		vault.AuthMethod = opts.AuthMethod
+	}
+	if vault.Address == "" {
+		address, err := ui.Input("Vault address:", "")
+		if err != nil {
+			return err
+		}
+		vault.Address = address
+	}
+
+	if err := saveSecrets(profileDir, m); err != nil {
+		return err
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Vault configured for profile: %s", profileName))
+	if vault.Address != "" {
+		fmt.Printf("  Address:   %s\n", vault.Address)
+	}
+	if vault.Namespace != "" {
+		fmt.Printf("  Namespace: %s\n", vault.Namespace)
+	}
+	if vault.Role != "" {
+		fmt.Printf("  Role:      %s\n", vault.Role)
+	}
+	fmt.Println()
+	fmt.Printf("  Add secrets with: profile secrets set %s <VAR> <path>#<field>\n", profileName)
+	return changesApplied()
+}
+
+// ConfigureBitwarden selects Bitwarden Secrets Manager (bws) as the
+// profile's secrets provider
+func ConfigureBitwarden(profilesDir string, opts SecretsOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile to configure Bitwarden for:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return err
+	}
+	if m.Secrets == nil {
+		m.Secrets = &manifest.Secrets{}
+	}
+	if m.Secrets.Provider != "" && m.Secrets.Provider != "bws" && len(m.Secrets.Vars) > 0 {
+		return NewValidationError("profile '%s' already uses the %s provider for %d secret(s); remove them first", profileName, m.Secrets.Provider, len(m.Secrets.Vars))
+	}
+	m.Secrets.Provider = "bws"
+	if opts.ServerURL != "" {
+		m.Secrets.Bitwarden = &manifest.BitwardenConfig{ServerURL: opts.ServerURL}
+	}
+
+	if err := saveSecrets(profileDir, m); err != nil {
+		return err
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Bitwarden Secrets Manager configured for profile: %s", profileName))
+	if m.Secrets.Bitwarden != nil && m.Secrets.Bitwarden.ServerURL != "" {
+		fmt.Printf("  Server:    %s\n", m.Secrets.Bitwarden.ServerURL)
+	}
+	fmt.Println()
+	fmt.Println("  Export the machine account token as BWS_ACCESS_TOKEN in your shell before the profile loads")
+	fmt.Printf("  Add secrets with: profile secrets set %s <VAR> <secret-id>\n", profileName)
+	return changesApplied()
+}
+
+// ConfigureKeychain selects the macOS Keychain as the profile's secrets
+// provider, with items namespaced by a per-profile service name
+func ConfigureKeychain(profilesDir string, opts SecretsOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile to configure the Keychain for:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return err
+	}
+	if m.Secrets == nil {
+		m.Secrets = &manifest.Secrets{}
+	}
+	if m.Secrets.Provider != "" && m.Secrets.Provider != "keychain" && len(m.Secrets.Vars) > 0 {
+		return NewValidationError("profile '%s' already uses the %s provider for %d secret(s); remove them first", profileName, m.Secrets.Provider, len(m.Secrets.Vars))
+	}
+	m.Secrets.Provider = "keychain"
+	if m.Secrets.Keychain == nil {
+		m.Secrets.Keychain = &manifest.KeychainConfig{}
+	}
+	if opts.Service != "" {
+		m.Secrets.Keychain.Service = opts.Service
+	}
+	if m.Secrets.Keychain.Service == "" {
+		m.Secrets.Keychain.Service = "profile:" + profileName
+	}
+
+	if err := saveSecrets(profileDir, m); err != nil {
+		return err
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("macOS Keychain configured for profile: %s", profileName))
+	fmt.Printf("  Service:   %s\n", m.Secrets.Keychain.Service)
+	fmt.Println()
+	fmt.Printf("  Store secrets with: profile secrets store %s <VAR>\n", profileName)
+	return changesApplied()
+}
+
+// ConfigurePluginProvider selects a secrets provider registered by a plugin
+// as the profile's secrets provider
+func ConfigurePluginProvider(profilesDir, provider string, opts SecretsOptions) error {
+	plugin, ok := plugins.FindSecretsProvider(provider)
+	if !ok {
+		return NewValidationError("unknown secrets provider: %s", provider)
+	}
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, fmt.Sprintf("Select profile to configure %s for:", provider))
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return err
+	}
+	if m.Secrets == nil {
+		m.Secrets = &manifest.Secrets{}
+	}
+	if m.Secrets.Provider != "" && m.Secrets.Provider != provider && len(m.Secrets.Vars) > 0 {
+		return NewValidationError("profile '%s' already uses the %s provider for %d secret(s); remove them first", profileName, m.Secrets.Provider, len(m.Secrets.Vars))
+	}
+	m.Secrets.Provider = provider
+
+	if err := saveSecrets(profileDir, m); err != nil {
+		return err
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("%s configured for profile: %s", provider, profileName))
+	fmt.Printf("  Plugin:    %s (%s)\n", plugin.Name, plugin.Path)
+	fmt.Println()
+	fmt.Printf("  Add secrets with: profile secrets set %s <VAR> <ref>\n", profileName)
+	return changesApplied()
+}
+
+// ConfigureWinCred selects the Windows Credential Manager as the profile's
+// secrets provider, with credentials namespaced by a per-profile service
+func ConfigureWinCred(profilesDir string, opts SecretsOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile to configure Credential Manager for:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return err
+	}
+	if m.Secrets == nil {
+		m.Secrets = &manifest.Secrets{}
+	}
+	if m.Secrets.Provider != "" && m.Secrets.Provider != "wincred" && len(m.Secrets.Vars) > 0 {
+		return NewValidationError("profile '%s' already uses the %s provider for %d secret(s); remove them first", profileName, m.Secrets.Provider, len(m.Secrets.Vars))
+	}
+	m.Secrets.Provider = "wincred"
+	if m.Secrets.WinCred == nil {
+		m.Secrets.WinCred = &manifest.KeychainConfig{}
+	}
+	if opts.Service != "" {
+		m.Secrets.WinCred.Service = opts.Service
+	}
+	if m.Secrets.WinCred.Service == "" {
+		m.Secrets.WinCred.Service = "profile:" + profileName
+	}
+
+	if err := saveSecrets(profileDir, m); err != nil {
+		return err
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Windows Credential Manager configured for profile: %s", profileName))
+	fmt.Printf("  Service:   %s\n", m.Secrets.WinCred.Service)
+	fmt.Println()
+	fmt.Printf("  Store secrets with: profile secrets store %s <VAR>\n", profileName)
+	return changesApplied()
+}
+
+// ReadCredential prints the secret of a Windows Credential Manager generic
+// credential; the wincred provider's .envrc block runs it at load time
+func ReadCredential(target string) error {
+	if target == "" {
+		return NewValidationError("credential name is required")
+	}
+	value, err := secrets.ReadCredential(target)
+	if err != nil {
+		return err
+	}
+	fmt.Print(value)
+	return nil
+}
+
+// StoreSecret saves a value in the profile's secrets provider (for
+// providers that hold values, such as the Keychain or Credential Manager) and loads VAR from it.
+// The reference defaults to the variable name.
+func StoreSecret(profilesDir string, opts SecretsOptions) error {
+	if opts.VarName == "" {
+		return NewValidationError("variable name is required")
+	}
+	if !envNamePattern.MatchString(opts.VarName) {
+		return NewValidationError("invalid variable name: %s", opts.VarName)
+	}
+	opts.Ref = valueOr(opts.Ref, opts.VarName)
+
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	opts.ProfileName = profileName
+
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return err
+	}
+	provider, err := secrets.ForConfig(m.Secrets)
+	if err != nil {
+		return NewValidationError("%v; run 'profile secrets <%s> %s' first", err, strings.Join(secrets.Names(), "|"), profileName)
+	}
+	storer, ok := provider.(secrets.Storer)
+	if !ok {
+		return NewValidationError("the %s provider does not store values; store them in %s and use 'profile secrets set'", provider.Name(), provider.Name())
+	}
+	if err := provider.ValidateRef(opts.Ref); err != nil {
+		return NewValidationError("%v", err)
+	}
+	if !ui.IsInteractive() {
+		return fmt.Errorf("secret value for %s: %w", opts.VarName, ui.ErrInputRequired)
+	}
+
+	if err := storer.Store(opts.Ref); err != nil {
+		return err
+	}
+	return SetSecret(profilesDir, opts)
+}
+
+// SetSecret maps an environment variable to a secret reference
+func SetSecret(profilesDir string, opts SecretsOptions) error {
+	if opts.VarName == "" || opts.Ref == "" {
+		return NewValidationError("variable name and secret reference are required")
+	}
+	if !envNamePattern.MatchString(opts.VarName) {
+		return NewValidationError("invalid variable name: %s", opts.VarName)
+	}
+
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return err
+	}
+	provider, err := secrets.ForConfig(m.Secrets)
+	if err != nil {
+		return NewValidationError("%v; run 'profile secrets <%s> %s' first", err, strings.Join(secrets.Names(), "|"), profileName)
+	}
+	if err := provider.ValidateRef(opts.Ref); err != nil {
+		return NewValidationError("%v", err)
+	}
+
+	replaced := false
+	for i, v := range m.Secrets.Vars {
+		if v.Name == opts.VarName {
+			m.Secrets.Vars[i].Ref = opts.Ref
+			replaced = true
+		}
+	}
+	if !replaced {
+		m.Secrets.Vars = append(m.Secrets.Vars, manifest.SecretVar{Name: opts.VarName, Ref: opts.Ref})
+	}
+
+	if err := saveSecrets(profileDir, m); err != nil {
+		return err
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("%s will be loaded from %s: %s", opts.VarName, provider.Name(), opts.Ref))
+	return changesApplied()
+}
+
+// RemoveSecret stops loading a secret variable in the profile
+func RemoveSecret(profilesDir string, opts SecretsOptions) error {
+	if opts.VarName == "" {
+		return NewValidationError("variable name is required")
+	}
+
+	_, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return err
+	}
+	if m.Secrets == nil {
+		return NewValidationError("secret %s is not configured", opts.VarName)
+	}
+
+	var kept []manifest.SecretVar
+	for _, v := range m.Secrets.Vars {
+		if v.Name != opts.VarName {
+			kept = append(kept, v)
+		}
+	}
+	if len(kept) == len(m.Secrets.Vars) {
+		return NewValidationError("secret %s is not configured", opts.VarName)
+	}
+	m.Secrets.Vars = kept
+
+	if err := saveSecrets(profileDir, m); err != nil {
+		return err
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Removed secret: %s", opts.VarName))
+	return changesApplied()
+}
+
+// ListSecrets shows the secret variables configured for a profile
+func ListSecrets(profilesDir string, opts SecretsOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return err
+	}
+	if m.Secrets == nil || m.Secrets.Provider == "" {
+		ui.PrintInfo(fmt.Sprintf("No secrets provider configured for profile: %s", profileName))
+		return nil
+	}
+
+	fmt.Printf("%sSecrets for %s%s (provider: %s)\n", ui.ColorBlue, profileName, ui.ColorReset, m.Secrets.Provider)
+	if len(m.Secrets.Vars) == 0 {
+		fmt.Println("  (none)")
+		return nil
+	}
+	table := ui.NewTable("VARIABLE", "REFERENCE").Truncate(1, 60)
+	for _, v := range m.Secrets.Vars {
+		table.AddRow(v.Name, v.Ref)
+	}
+	table.Render(os.Stdout)
+	return nil
+}
+
+// CheckSecrets resolves every configured secret now and reports failures
+func CheckSecrets(profilesDir string, opts SecretsOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return err
+	}
+	provider, err := secrets.ForConfig(m.Secrets)
+	if err != nil {
+		return NewValidationError("%v for profile '%s'", err, profileName)
+	}
+
+	failed := 0
+	for _, v := range m.Secrets.Vars {
+		if err := provider.Check(v.Ref); err != nil {
+			fmt.Printf("%s✗ %s%s: %v\n", ui.ColorRed, v.Name, ui.ColorReset, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s✓ %s%s\n", ui.ColorGreen, v.Name, ui.ColorReset)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d secret(s) could not be resolved", failed)
+	}
+	return nil
+}
+
+// saveSecrets writes the manifest and regenerates the secrets block in .envrc
+func saveSecrets(profileDir string, m *manifest.Manifest) error {
+	if err := manifest.Save(profileDir, m); err != nil {
+		return newIOError(err, "failed to save profile manifest")
+	}
+
+	provider, err := secrets.ForConfig(m.Secrets)
+	if err != nil {
+		return NewValidationError("%v", err)
+	}
+	if _, err := setManagedBlock(profileDir, "secrets", provider.Render(m.Secrets.Vars)); err != nil {
+		return newIOError(err, "failed to update .envrc")
+	}
+	return nil
+}
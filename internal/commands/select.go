@@ -3,65 +3,75 @@ package commands
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
 
+	"github.com/mindmorass/shell-profile-manager/internal/direnv"
 	"github.com/mindmorass/shell-profile-manager/internal/ui"
 )
 
 type SelectOptions struct {
 	ProfileName string
 	AllowDirenv bool
+	// Default is the default profile of the config file, used with the
+	// last used profile when no name is given
+	Default string
+	// Pick prompts for the profile even when there is a default or last
+	// used one
+	Pick bool
 }
 
 // SelectProfile allows the user to interactively select and switch to a profile
 func SelectProfile(profilesDir string, opts SelectOptions) error {
 	// Get list of profiles
-	entries, err := os.ReadDir(profilesDir)
+	profiles, err := ListProfileNames(profilesDir)
 	if err != nil {
-		return fmt.Errorf("failed to read profiles directory: %w", err)
+		return err
 	}
 
-	var profiles []string
 	profileDetails := make(map[string]string) // name -> path
-
-	for _, entry := range entries {
-		if entry.IsDir() && entry.Name() != ".git" {
-			profilePath := filepath.Join(profilesDir, entry.Name())
-			envrcPath := filepath.Join(profilePath, ".envrc")
-			if _, err := os.Stat(envrcPath); err == nil {
-				profiles = append(profiles, entry.Name())
-				profileDetails[entry.Name()] = profilePath
-			}
-		}
+	for _, name := range profiles {
+		profileDetails[name] = newProfilePath(profilesDir, name)
 	}
 
 	if len(profiles) == 0 {
-		return fmt.Errorf("no profiles found")
+		return NewValidationError("no profiles found")
 	}
 
-	// If profile name provided, use it directly
+	// Without a name, the default or last used profile is selected
 	var selected string
+	if opts.ProfileName == "" && !opts.Pick {
+		if name, source := fallbackProfile(profilesDir, opts.Default); name != "" {
+			reporter.Info(fmt.Sprintf("Using the %s: %s (pass a name or --pick to choose another)", source, name))
+			opts.ProfileName = name
+		}
+	}
+
+	// If profile name provided, use it directly
 	if opts.ProfileName != "" {
-		selected = opts.ProfileName
+		if err := checkAmbiguous(profilesDir, opts.ProfileName); err != nil {
+			return err
+		}
+		selected = qualifiedName(profilesDir, opts.ProfileName)
 		// Verify it exists
 		if _, exists := profileDetails[selected]; !exists {
-			return fmt.Errorf("profile '%s' does not exist", selected)
+			return NewValidationError("profile '%s' does not exist", selected)
 		}
 	} else {
 		// Interactive selection
-		selected, err = ui.SelectProfile(profiles, "Select a profile to activate:")
+		if !ui.IsInteractive() {
+			return NewValidationError("profile name is required when running non-interactively")
+		}
+		selected, err = ui.SelectProfileChoice(profileChoices(profilesDir, profiles), "Select a profile to activate:")
 		if err != nil {
 			return err
 		}
 	}
 
 	profilePath := profileDetails[selected]
+	RecordLastProfile(selected)
 
 	// Check if currently in this profile
-	currentProfile := os.Getenv("WORKSPACE_PROFILE")
-	if currentProfile == selected {
+	if isActiveProfile(selected, profilePath) {
 		ui.PrintInfo(fmt.Sprintf("You are already in profile '%s'", selected))
 		fmt.Printf("  Location: %s\n", profilePath)
 		return nil
@@ -76,27 +86,22 @@ func SelectProfile(profilesDir string, opts SelectOptions) error {
 	envrcPath := filepath.Join(profilePath, ".envrc")
 	if _, err := os.Stat(envrcPath); err == nil {
 		// Check if direnv is installed
-		if cmd := exec.Command("which", "direnv"); cmd.Run() == nil {
+		if direnv.Installed() {
 			// Check if direnv is allowed
-			statusCmd := exec.Command("direnv", "status")
-			statusCmd.Dir = profilePath
-			output, statusErr := statusCmd.Output()
+			state, statusErr := direnv.Status(profilePath)
 			if statusErr != nil {
 				// Direnv is installed but status check failed, continue anyway
 				return nil
 			}
-			needsAllow := !strings.Contains(string(output), "Found RC allowed true")
 
-			if needsAllow {
+			if state != direnv.Allowed && state != direnv.Unknown {
 				fmt.Println()
-				ui.PrintWarning("direnv needs to be allowed for this profile")
-				if opts.AllowDirenv {
+				ui.PrintWarning(fmt.Sprintf("direnv needs to be allowed for this profile (.envrc is %s)", state))
+				if state == direnv.Blocked {
+					fmt.Println("  The .envrc was denied; review it before allowing")
+				} else if opts.AllowDirenv {
 					// Try to allow direnv
-					allowCmd := exec.Command("direnv", "allow")
-					allowCmd.Dir = profilePath
-					allowCmd.Stdout = os.Stdout
-					allowCmd.Stderr = os.Stderr
-					if err := allowCmd.Run(); err != nil {
+					if err := direnv.Allow(profilePath); err != nil {
 						ui.PrintWarning(fmt.Sprintf("Failed to allow direnv: %v", err))
 						fmt.Println("  You may need to run 'direnv allow' manually")
 					} else {
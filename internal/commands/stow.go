@@ -0,0 +1,350 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mindmorass/shell-profile-manager/internal/atomicfile"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+const (
+	stowDir       = "stow"
+	stowStateFile = ".stowed"
+)
+
+type StowOptions struct {
+	ProfileName string
+	// Packages limits the command to these packages (default: all)
+	Packages []string
+	// DryRun shows the links without changing anything
+	DryRun bool
+}
+
+// stowState records the links and directories created by Stow so Unstow
+// removes exactly those, even after packages change
+type stowState struct {
+	Links []stowLink `yaml:"links"`
+	// Dirs are directories in $HOME created to hold links
+	Dirs []string `yaml:"dirs,omitempty"`
+}
+
+type stowLink struct {
+	Package string `yaml:"package"`
+	// Target is the link path in $HOME
+	Target string `yaml:"target"`
+	// Source is the file in the profile the link points to
+	Source string `yaml:"source"`
+}
+
+// Stow links the files of the profile's stow packages into $HOME. Nothing
+// is changed if any target already exists and is not a link to the profile.
+func Stow(profilesDir string, opts StowOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile to stow:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := ensureFeature(profilesDir, profileName, profileDir, "stow"); err != nil {
+		return err
+	}
+
+	packages, err := stowPackages(profileDir, opts.Packages)
+	if err != nil {
+		return err
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to get home directory: %w", err)
+	}
+	state, err := loadStowState(profileDir)
+	if err != nil {
+		return err
+	}
+
+	var planned []stowLink
+	var conflicts []string
+	for _, pkg := range packages {
+		pkgDir := filepath.Join(profileDir, stowDir, pkg)
+		err := filepath.WalkDir(pkgDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, _ := filepath.Rel(pkgDir, path)
+			link := stowLink{Package: pkg, Target: filepath.Join(homeDir, rel), Source: path}
+
+			switch linked, err := linksTo(link.Target, link.Source); {
+			case err != nil:
+				return err
+			case linked:
+				state.Links = append(withoutLink(state.Links, link.Target), link)
+			default:
+				if _, err := os.Lstat(link.Target); err == nil {
+					conflicts = append(conflicts, link.Target)
+					return nil
+				}
+				planned = append(planned, link)
+			}
+			return nil
+		})
+		if err != nil {
+			return newIOError(err, "failed to read stow package '%s'", pkg)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		for _, target := range conflicts {
+			fmt.Printf("  %s✗%s %s already exists\n", ui.ColorRed, ui.ColorReset, target)
+		}
+		return NewValidationError("%d stow target(s) already exist; move them into the package (or unstow the profile that owns them) and retry", len(conflicts))
+	}
+	if len(planned) == 0 {
+		ui.PrintInfo(fmt.Sprintf("Profile '%s' is already stowed", profileName))
+		return nil
+	}
+
+	for _, link := range planned {
+		fmt.Printf("  %s→%s %s\n", ui.ColorGreen, ui.ColorReset, abbreviateHome(link.Target, homeDir))
+	}
+	if opts.DryRun {
+		ui.PrintInfo(fmt.Sprintf("Dry run: %d links would be created", len(planned)))
+		return nil
+	}
+
+	// On failure, save the links created so far so they can still be
+	// unstowed
+	for _, link := range planned {
+		created, err := mkdirAllTracked(filepath.Dir(link.Target))
+		state.Dirs = append(state.Dirs, created...)
+		if err != nil {
+			saveStowState(profileDir, state)
+			return newIOError(err, "failed to create %s", filepath.Dir(link.Target))
+		}
+
+		relSource, err := filepath.Rel(filepath.Dir(link.Target), link.Source)
+		if err != nil {
+			relSource = link.Source
+		}
+		if err := os.Symlink(relSource, link.Target); err != nil {
+			saveStowState(profileDir, state)
+			return newIOError(err, "failed to link %s", link.Target)
+		}
+		state.Links = append(state.Links, link)
+	}
+	if err := saveStowState(profileDir, state); err != nil {
+		return newIOError(err, "failed to write %s", stowStateFile)
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Stowed %d files of profile '%s' into %s", len(planned), profileName, homeDir))
+	fmt.Printf("  Remove them with: profile unstow %s\n", profileName)
+	return changesApplied()
+}
+
+// Unstow removes the links recorded by Stow. Links that were replaced or
+// now point elsewhere are left in place.
+func Unstow(profilesDir string, opts StowOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile to unstow:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state, err := loadStowState(profileDir)
+	if err != nil {
+		return err
+	}
+	if len(state.Links) == 0 {
+		ui.PrintInfo(fmt.Sprintf("Profile '%s' has no stowed links", profileName))
+		return nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	var kept []stowLink
+	removed := 0
+	for _, link := range state.Links {
+		if len(opts.Packages) > 0 && len(withoutName(opts.Packages, link.Package)) == len(opts.Packages) {
+			kept = append(kept, link)
+			continue
+		}
+
+		linked, err := linksTo(link.Target, link.Source)
+		if err != nil {
+			return newIOError(err, "failed to read %s", link.Target)
+		}
+		if !linked {
+			if _, err := os.Lstat(link.Target); err == nil {
+				ui.PrintWarning(fmt.Sprintf("Leaving %s: it no longer links to the profile", link.Target))
+			}
+			continue
+		}
+
+		fmt.Printf("  %s✗%s %s\n", ui.ColorRed, ui.ColorReset, abbreviateHome(link.Target, homeDir))
+		if opts.DryRun {
+			kept = append(kept, link)
+			continue
+		}
+		if err := os.Remove(link.Target); err != nil {
+			return newIOError(err, "failed to remove %s", link.Target)
+		}
+		removed++
+	}
+
+	if opts.DryRun {
+		ui.PrintInfo("Dry run: no links removed")
+		return nil
+	}
+
+	// Remove the directories Stow created once they are empty, deepest first
+	sort.Sort(sort.Reverse(sort.StringSlice(state.Dirs)))
+	var keptDirs []string
+	for _, dir := range state.Dirs {
+		if err := os.Remove(dir); err != nil && !os.IsNotExist(err) {
+			keptDirs = append(keptDirs, dir)
+		}
+	}
+
+	state.Links = kept
+	state.Dirs = keptDirs
+	if len(state.Links) == 0 {
+		if err := os.Remove(filepath.Join(profileDir, stowStateFile)); err != nil && !os.IsNotExist(err) {
+			return newIOError(err, "failed to remove %s", stowStateFile)
+		}
+	} else if err := saveStowState(profileDir, state); err != nil {
+		return newIOError(err, "failed to write %s", stowStateFile)
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Removed %d stowed links of profile '%s'", removed, profileName))
+	return changesApplied()
+}
+
+// stowPackages returns the requested packages, or every package directory
+// under stow/ when none are requested
+func stowPackages(profileDir string, requested []string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(profileDir, stowDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, newIOError(err, "failed to read %s", stowDir)
+	}
+
+	var available []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			available = append(available, entry.Name())
+		}
+	}
+
+	if len(requested) == 0 {
+		if len(available) == 0 {
+			return nil, NewValidationError("no stow packages in %s (create %s/<package>/ mirroring $HOME)", filepath.Join(profileDir, stowDir), stowDir)
+		}
+		return available, nil
+	}
+	for _, pkg := range requested {
+		if len(withoutName(available, pkg)) == len(available) {
+			return nil, NewValidationError("unknown stow package: %s (available: %s)", pkg, strings.Join(available, ", "))
+		}
+	}
+	return requested, nil
+}
+
+// linksTo reports whether target is a symlink resolving to source
+func linksTo(target, source string) (bool, error) {
+	dest, err := os.Readlink(target)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		// Not a symlink
+		if info, statErr := os.Lstat(target); statErr == nil && info.Mode()&os.ModeSymlink == 0 {
+			return false, nil
+		}
+		return false, err
+	}
+	if !filepath.IsAbs(dest) {
+		dest = filepath.Join(filepath.Dir(target), dest)
+	}
+	return filepath.Clean(dest) == filepath.Clean(source), nil
+}
+
+// mkdirAllTracked creates dir and its missing parents, returning the
+// directories it created
+func mkdirAllTracked(dir string) ([]string, error) {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Lstat(d); err == nil || d == filepath.Dir(d) {
+			break
+		}
+		missing = append(missing, d)
+	}
+
+	var created []string
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := os.Mkdir(missing[i], 0755); err != nil {
+			return created, err
+		}
+		created = append(created, missing[i])
+	}
+	return created, nil
+}
+
+func withoutLink(links []stowLink, target string) []stowLink {
+	var result []stowLink
+	for _, link := range links {
+		if link.Target != target {
+			result = append(result, link)
+		}
+	}
+	return result
+}
+
+func loadStowState(profileDir string) (*stowState, error) {
+	content, err := readOptionalFile(filepath.Join(profileDir, stowStateFile))
+	if err != nil {
+		return nil, newIOError(err, "failed to read %s", stowStateFile)
+	}
+	var state stowState
+	if err := yaml.Unmarshal([]byte(content), &state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", stowStateFile, err)
+	}
+	return &state, nil
+}
+
+func saveStowState(profileDir string, state *stowState) error {
+	var buf bytes.Buffer
+	buf.WriteString("# Links created by 'profile stow' on this machine; 'profile unstow' removes them\n")
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(state); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(filepath.Join(profileDir, stowStateFile), buf.Bytes(), 0644)
+}
+
+// abbreviateHome shows paths under the home directory as ~/...
+func abbreviateHome(path, homeDir string) string {
+	if rel, err := filepath.Rel(homeDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.Join("~", rel)
+	}
+	return path
+}
@@ -0,0 +1,214 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// tagPattern matches the tags a profile can carry, e.g. "active" or
+// "client:acme"
+var tagPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.:/-]*$`)
+
+type TagOptions struct {
+	ProfileName string
+	Tags        []string
+}
+
+// TagSummary is a tag as listed with --output json|yaml
+type TagSummary struct {
+	Tag      string   `json:"tag" yaml:"tag"`
+	Profiles []string `json:"profiles" yaml:"profiles"`
+}
+
+// validateTags checks the tags given to create or 'profile tag add'
+func validateTags(tags []string) error {
+	for _, tag := range tags {
+		if !tagPattern.MatchString(tag) {
+			return NewValidationError("invalid tag: %s (use letters, digits, and . _ : / -)", tag)
+		}
+	}
+	return nil
+}
+
+// hasTags reports whether a profile's tags match every selector. A selector
+// is a tag or a pattern such as "client:*".
+func hasTags(tags, selectors []string) bool {
+	for _, selector := range selectors {
+		if !slices.ContainsFunc(tags, func(tag string) bool {
+			matched, _ := path.Match(selector, tag)
+			return matched
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkTagSelectors rejects malformed tag patterns, which would otherwise
+// match nothing
+func checkTagSelectors(selectors []string) error {
+	for _, selector := range selectors {
+		if _, err := path.Match(selector, ""); err != nil {
+			return NewValidationError("invalid tag pattern: %s", selector)
+		}
+	}
+	return nil
+}
+
+// taggedProfiles returns the names of the profiles tagged with every
+// selector, or of all profiles when there are none
+func taggedProfiles(profilesDir string, selectors []string) ([]string, error) {
+	if err := checkTagSelectors(selectors); err != nil {
+		return nil, err
+	}
+	profiles, err := ListProfileNames(profilesDir)
+	if err != nil || len(selectors) == 0 {
+		return profiles, err
+	}
+
+	var tagged []string
+	for _, profileName := range profiles {
+		if hasTags(cachedProfileSummary(newProfilePath(profilesDir, profileName)).Tags, selectors) {
+			tagged = append(tagged, profileName)
+		}
+	}
+	saveProfileCache()
+	return tagged, nil
+}
+
+// selectionTarget describes the profiles a bulk command runs for, for its
+// messages: "every profile" or "profiles tagged client:acme"
+func selectionTarget(selectors []string) string {
+	if len(selectors) == 0 {
+		return "every profile"
+	}
+	return "profiles tagged " + strings.Join(selectors, ", ")
+}
+
+// defaultJobs is how many profiles --all and --tag process at once unless
+// --jobs or jobs= in the config says otherwise
+const defaultJobs = 4
+
+// parallelJobs returns how many profiles a bulk command processes at once
+func parallelJobs(jobs int) int {
+	if jobs > 0 {
+		return jobs
+	}
+	return defaultJobs
+}
+
+// ListTags shows the tags in use and the profiles carrying each
+func ListTags(profilesDir string) error {
+	profiles, err := ListProfileNames(profilesDir)
+	if err != nil {
+		return err
+	}
+
+	byTag := map[string][]string{}
+	for _, profileName := range profiles {
+		m, err := manifest.Load(profilePath(profilesDir, profileName))
+		if err != nil {
+			continue
+		}
+		for _, tag := range m.Tags {
+			byTag[tag] = append(byTag[tag], profileName)
+		}
+	}
+	summaries := []TagSummary{}
+	for tag, tagged := range byTag {
+		summaries = append(summaries, TagSummary{Tag: tag, Profiles: tagged})
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Tag < summaries[j].Tag })
+
+	if ui.Structured() {
+		return ui.Render(summaries)
+	}
+	if len(summaries) == 0 {
+		ui.PrintInfo("No profiles are tagged")
+		fmt.Println("Tag one with: profile tag add <profile> <tag>...")
+		return nil
+	}
+	table := ui.NewTable("TAG", "PROFILES").Truncate(1, 60)
+	for _, summary := range summaries {
+		table.AddRow(summary.Tag, strings.Join(summary.Profiles, ", "))
+	}
+	table.Render(os.Stdout)
+	return nil
+}
+
+// AddTags tags a profile
+func AddTags(profilesDir string, opts TagOptions) error {
+	if len(opts.Tags) == 0 {
+		return NewValidationError("at least one tag is required")
+	}
+	if err := validateTags(opts.Tags); err != nil {
+		return err
+	}
+	return editTags(profilesDir, opts, func(tags []string) ([]string, []string) {
+		var added []string
+		for _, tag := range opts.Tags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+				added = append(added, tag)
+			}
+		}
+		return tags, added
+	}, "Tagged")
+}
+
+// RemoveTags removes tags from a profile
+func RemoveTags(profilesDir string, opts TagOptions) error {
+	if len(opts.Tags) == 0 {
+		return NewValidationError("at least one tag is required")
+	}
+	return editTags(profilesDir, opts, func(tags []string) ([]string, []string) {
+		var kept, removed []string
+		for _, tag := range tags {
+			if slices.Contains(opts.Tags, tag) {
+				removed = append(removed, tag)
+			} else {
+				kept = append(kept, tag)
+			}
+		}
+		return kept, removed
+	}, "Untagged")
+}
+
+// editTags applies edit to the tags in a profile's manifest and saves the
+// manifest when they changed
+func editTags(profilesDir string, opts TagOptions, edit func(tags []string) ([]string, []string), verb string) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	m, err := manifest.Load(profileDir)
+	if err != nil {
+		return err
+	}
+
+	tags, changed := edit(m.Tags)
+	if len(changed) == 0 {
+		ui.PrintInfo(fmt.Sprintf("No tags changed for %s", profileName))
+		return nil
+	}
+	m.Tags = tags
+	if err := manifest.Save(profileDir, m); err != nil {
+		return newIOError(err, "failed to save profile manifest")
+	}
+	ui.PrintSuccess(fmt.Sprintf("%s %s: %s", verb, profileName, strings.Join(changed, ", ")))
+	return changesApplied()
+}
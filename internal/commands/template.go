@@ -0,0 +1,264 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/templates"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type TemplateOptions struct {
+	// Name is the template repository (by default the last part of URL)
+	Name string
+	URL  string
+	// Ref pins a branch or tag of the repository
+	Ref string
+	// Locked restores clones at their locked commits instead of updating
+	Locked bool
+}
+
+// TemplateListing is 'template list' with --output json|yaml
+type TemplateListing struct {
+	Templates []templates.Template `json:"templates" yaml:"templates"`
+	Repos     []templates.Repo     `json:"repos" yaml:"repos"`
+}
+
+// ListTemplates shows the available templates and template repositories
+func ListTemplates() error {
+	all, err := templates.List()
+	if err != nil {
+		return err
+	}
+	repos, err := templates.LoadRepos()
+	if err != nil {
+		return err
+	}
+
+	if ui.Structured() {
+		return ui.Render(TemplateListing{Templates: all, Repos: append([]templates.Repo{}, repos...)})
+	}
+
+	table := ui.NewTable("NAME", "ORIGIN", "DESCRIPTION").Truncate(2, 60)
+	for _, t := range all {
+		table.AddRow(t.Name, t.Origin, t.Description)
+	}
+	table.Render(os.Stdout)
+
+	if len(repos) == 0 {
+		return nil
+	}
+	fmt.Println()
+	table = ui.NewTable("REPOSITORY", "URL", "REF", "COMMIT").Truncate(1, 60)
+	for _, repo := range repos {
+		commit := templates.ShortCommit(repo.Commit)
+		if dir, err := templates.RepoDir(repo.Name); err == nil {
+			if _, err := os.Stat(dir); os.IsNotExist(err) {
+				commit += fmt.Sprintf(" %s(not cloned)%s", ui.ColorYellow, ui.ColorReset)
+			}
+		}
+		table.AddRow(repo.Name, repo.URL, valueOr(repo.Ref, "(default branch)"), commit)
+	}
+	table.Render(os.Stdout)
+	return nil
+}
+
+// AddTemplateRepo clones a template repository and locks it at the current
+// commit of its ref
+func AddTemplateRepo(opts TemplateOptions) error {
+	if opts.URL == "" {
+		return NewValidationError("repository URL is required")
+	}
+	if opts.Name == "" {
+		opts.Name = strings.TrimSuffix(filepath.Base(opts.URL), ".git")
+	}
+	if !profileNamePattern.MatchString(opts.Name) {
+		return NewValidationError("invalid repository name: %s (use --name)", opts.Name)
+	}
+
+	repos, err := templates.LoadRepos()
+	if err != nil {
+		return err
+	}
+	for _, repo := range repos {
+		if repo.Name == opts.Name {
+			return NewValidationError("template repository '%s' already exists (%s)", repo.Name, repo.URL)
+		}
+	}
+
+	// A clone left behind by a removed repository is replaced
+	dir, err := templates.RepoDir(opts.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return newIOError(err, "failed to remove %s", dir)
+	}
+
+	repo := templates.Repo{Name: opts.Name, URL: opts.URL, Ref: opts.Ref}
+	ui.PrintInfo(fmt.Sprintf("Cloning %s...", opts.URL))
+	if err := cloneTemplateRepo(&repo, ""); err != nil {
+		_ = os.RemoveAll(dir)
+		return err
+	}
+
+	if err := templates.SaveRepos(append(repos, repo)); err != nil {
+		return newIOError(err, "failed to save template repositories")
+	}
+	ui.PrintSuccess(fmt.Sprintf("Added template repository %s at %s", repo.Name, templates.ShortCommit(repo.Commit)))
+
+	all, err := templates.List()
+	if err != nil {
+		return err
+	}
+	for _, t := range all {
+		if t.Repo == repo.Name {
+			fmt.Printf("  %s✓%s %s\n", ui.ColorGreen, ui.ColorReset, t.Name)
+		}
+	}
+	return changesApplied()
+}
+
+// UpdateTemplateRepos fetches template repositories and moves their locked
+// commits to the latest commit of their ref. With Locked, missing clones
+// are restored at the locked commits and nothing is updated.
+func UpdateTemplateRepos(opts TemplateOptions) error {
+	repos, err := templates.LoadRepos()
+	if err != nil {
+		return err
+	}
+	if len(repos) == 0 {
+		ui.PrintInfo("No template repositories (add one with 'profile template add <url>')")
+		return nil
+	}
+
+	found, changed := false, false
+	for i := range repos {
+		repo := &repos[i]
+		if opts.Name != "" && repo.Name != opts.Name {
+			continue
+		}
+		found = true
+
+		previous := repo.Commit
+		target := ""
+		if opts.Locked {
+			target = previous
+		}
+		if err := cloneTemplateRepo(repo, target); err != nil {
+			return err
+		}
+		if repo.Commit == previous {
+			fmt.Printf("  %s✓%s %s: %s (up to date)\n", ui.ColorGreen, ui.ColorReset, repo.Name, templates.ShortCommit(repo.Commit))
+			continue
+		}
+		changed = true
+		fmt.Printf("  %s✓%s %s: %s → %s\n", ui.ColorGreen, ui.ColorReset, repo.Name, valueOr(templates.ShortCommit(previous), "(none)"), templates.ShortCommit(repo.Commit))
+	}
+	if !found {
+		return NewValidationError("unknown template repository: %s", opts.Name)
+	}
+	if !changed {
+		return nil
+	}
+
+	if err := templates.SaveRepos(repos); err != nil {
+		return newIOError(err, "failed to save template repositories")
+	}
+	return changesApplied()
+}
+
+// RemoveTemplateRepo removes a template repository and its clone
+func RemoveTemplateRepo(opts TemplateOptions) error {
+	if opts.Name == "" {
+		return NewValidationError("repository name is required")
+	}
+	repos, err := templates.LoadRepos()
+	if err != nil {
+		return err
+	}
+
+	var kept []templates.Repo
+	for _, repo := range repos {
+		if repo.Name != opts.Name {
+			kept = append(kept, repo)
+		}
+	}
+	if len(kept) == len(repos) {
+		return NewValidationError("unknown template repository: %s", opts.Name)
+	}
+
+	dir, err := templates.RepoDir(opts.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return newIOError(err, "failed to remove %s", dir)
+	}
+	if err := templates.SaveRepos(kept); err != nil {
+		return newIOError(err, "failed to save template repositories")
+	}
+	ui.PrintSuccess(fmt.Sprintf("Removed template repository %s", opts.Name))
+	return changesApplied()
+}
+
+// cloneTemplateRepo clones the repository if needed, fetches it, and checks
+// out commit, or the latest commit of its ref when commit is empty. The
+// checked out commit is recorded in repo.
+func cloneTemplateRepo(repo *templates.Repo, commit string) error {
+	dir, err := templates.RepoDir(repo.Name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return newIOError(err, "failed to create %s", filepath.Dir(dir))
+		}
+		if _, err := runGit("", "clone", "--quiet", repo.URL, dir); err != nil {
+			return err
+		}
+	} else if _, err := runGit(dir, "fetch", "--quiet", "--tags", "--force", "origin"); err != nil {
+		return err
+	}
+
+	if commit == "" {
+		if commit, err = resolveTemplateRef(dir, repo.Ref); err != nil {
+			return NewValidationError("ref '%s' not found in %s", repo.Ref, repo.URL)
+		}
+	}
+	if _, err := runGit(dir, "checkout", "--quiet", "--detach", commit); err != nil {
+		return err
+	}
+	repo.Commit = commit
+	return nil
+}
+
+// resolveTemplateRef returns the commit of a branch or tag of the cloned
+// repository, or of its default branch when ref is empty
+func resolveTemplateRef(dir, ref string) (string, error) {
+	if ref == "" {
+		return runGit(dir, "rev-parse", "origin/HEAD^{commit}")
+	}
+	if commit, err := runGit(dir, "rev-parse", "--verify", "--quiet", "origin/"+ref+"^{commit}"); err == nil {
+		return commit, nil
+	}
+	return runGit(dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+}
+
+// runGit runs git in dir and returns its trimmed output
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
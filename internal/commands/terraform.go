@@ -0,0 +1,183 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type TerraformOptions struct {
+	ProfileName string
+	// Host is the Terraform Cloud / Enterprise hostname for credentials
+	Host  string
+	Token string
+	// SecretRef loads the token from the profile's secrets provider instead
+	// of storing it in .terraformrc
+	SecretRef string
+}
+
+var pluginCacheDirPattern = regexp.MustCompile(`(?m)^\s*plugin_cache_dir\s*=.*$`)
+
+// SetupTerraform writes the profile's .terraformrc with a profile-local
+// plugin cache directory
+func SetupTerraform(profilesDir string, opts TerraformOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile to set up Terraform for:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cacheDir := filepath.Join(profileDir, ".terraform.d", "plugin-cache")
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return newIOError(err, "failed to create plugin cache directory")
+	}
+
+	rcPath := filepath.Join(profileDir, ".terraformrc")
+	content, err := readOptionalFile(rcPath)
+	if err != nil {
+		return newIOError(err, "failed to read .terraformrc")
+	}
+
+	setting := fmt.Sprintf("plugin_cache_dir = %q", cacheDir)
+	if pluginCacheDirPattern.MatchString(content) {
+		content = pluginCacheDirPattern.ReplaceAllString(content, setting)
+	} else {
+		content = "# Terraform CLI configuration for this workspace (TF_CLI_CONFIG_FILE)\n" + setting + "\n" + content
+	}
+
+	if err := writeProfileFile(rcPath, []byte(content), 0600); err != nil {
+		return newIOError(err, "failed to write .terraformrc")
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Wrote %s", rcPath))
+	fmt.Printf("  Plugin cache: %s\n", cacheDir)
+	fmt.Println()
+	fmt.Printf("  Add Terraform Cloud credentials with: profile terraform credentials %s --host app.terraform.io --token <token>\n", profileName)
+	return changesApplied()
+}
+
+// SetTerraformCredentials stores a Terraform Cloud / Enterprise API token
+// for the profile, either in .terraformrc or through the secrets provider
+func SetTerraformCredentials(profilesDir string, opts TerraformOptions) error {
+	if opts.Host == "" {
+		opts.Host = "app.terraform.io"
+	}
+	if opts.Token != "" && opts.SecretRef != "" {
+		return NewValidationError("use either --token or --secret, not both")
+	}
+
+	// Tokens from the secrets provider are exported as TF_TOKEN_<host>,
+	// which Terraform reads instead of the credentials block
+	if opts.SecretRef != "" {
+		return SetSecret(profilesDir, SecretsOptions{
+			ProfileName: opts.ProfileName,
+			VarName:     terraformTokenVar(opts.Host),
+			Ref:         opts.SecretRef,
+		})
+	}
+
+	_, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if opts.Token == "" {
+		if !ui.IsInteractive() {
+			return NewValidationError("--token or --secret is required")
+		}
+		if opts.Token, err = ui.Input(fmt.Sprintf("API token for %s:", opts.Host), ""); err != nil {
+			return err
+		}
+		if opts.Token == "" {
+			return NewValidationError("token is required")
+		}
+	}
+
+	rcPath := filepath.Join(profileDir, ".terraformrc")
+	content, err := readOptionalFile(rcPath)
+	if err != nil {
+		return newIOError(err, "failed to read .terraformrc")
+	}
+
+	header := fmt.Sprintf("credentials %q", opts.Host)
+	body := fmt.Sprintf("  token = %q", opts.Token)
+	content = upsertHCLBlock(content, header, body)
+
+	if err := writeProfileFile(rcPath, []byte(content), 0600); err != nil {
+		return newIOError(err, "failed to write .terraformrc")
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Stored credentials for %s in %s", opts.Host, rcPath))
+	fmt.Println("  .terraformrc is gitignored; consider --secret to keep the token out of the profile")
+	return changesApplied()
+}
+
+// terraformTokenVar returns the environment variable Terraform reads for a
+// host's API token (dots become underscores, dashes become double underscores)
+func terraformTokenVar(host string) string {
+	name := strings.ReplaceAll(host, "-", "__")
+	name = strings.ReplaceAll(name, ".", "_")
+	return "TF_TOKEN_" + name
+}
+
+// upsertHCLBlock replaces a top-level block such as `credentials "host" {...}`
+// or appends it when missing
+func upsertHCLBlock(content, header, body string) string {
+	block := header + " {\n" + body + "\n}\n"
+
+	start := strings.Index(content, header+" {")
+	if start == -1 {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		if content != "" {
+			content += "\n"
+		}
+		return content + block
+	}
+
+	depth := 0
+	for i := start; i < len(content); i++ {
+		switch content[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				end := i + 1
+				if end < len(content) && content[end] == '\n' {
+					end++
+				}
+				return content[:start] + block + content[end:]
+			}
+		}
+	}
+
+	// Unterminated block: replace everything after the header
+	return content[:start] + block
+}
+
+// readOptionalFile returns the file's content, or "" if it does not exist
+func readOptionalFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/direnv"
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type TmuxOptions struct {
+	ProfileName string
+	// Detach creates the session without attaching to it
+	Detach bool
+}
+
+// defaultTmuxWindows is used when the manifest does not declare any windows
+var defaultTmuxWindows = []manifest.TmuxWindow{{Name: "shell"}}
+
+// OpenTmux attaches to the tmux session for a profile, creating it first
+// (with the windows declared in the profile manifest) if needed
+func OpenTmux(profilesDir string, opts TmuxOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	if _, err := exec.LookPath("tmux"); err != nil {
+		return NewValidationError("tmux is not installed")
+	}
+
+	session := tmuxSessionName(profileName)
+	if exec.Command("tmux", "has-session", "-t", "="+session).Run() != nil {
+		m, err := manifest.Load(profileDir)
+		if err != nil {
+			return err
+		}
+		windows := defaultTmuxWindows
+		if m.Tmux != nil && len(m.Tmux.Windows) > 0 {
+			windows = m.Tmux.Windows
+		}
+		if err := createTmuxSession(session, profileDir, windows); err != nil {
+			return err
+		}
+		ui.PrintSuccess(fmt.Sprintf("Created tmux session: %s", session))
+	}
+
+	if opts.Detach {
+		fmt.Printf("  Attach with: tmux attach -t %s\n", session)
+		return nil
+	}
+
+	// Inside tmux, switch the current client instead of nesting sessions
+	action := "attach-session"
+	if os.Getenv("TMUX") != "" {
+		action = "switch-client"
+	}
+	cmd := exec.Command("tmux", action, "-t", "="+session)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// createTmuxSession creates a detached session with one tmux window per
+// manifest window, each pane started in the workspace with its environment
+func createTmuxSession(session, profileDir string, windows []manifest.TmuxWindow) error {
+	shell := tmuxShell(profileDir)
+
+	for i, window := range windows {
+		dir := profileDir
+		if window.Dir != "" {
+			dir = filepath.Join(profileDir, window.Dir)
+		}
+		name := valueOr(window.Name, fmt.Sprintf("%d", i+1))
+
+		args := []string{"new-window", "-d", "-t", session + ":", "-n", name, "-c", dir}
+		if i == 0 {
+			args = []string{"new-session", "-d", "-s", session, "-n", name, "-c", dir}
+		}
+		if err := runTmux(append(args, shell)...); err != nil {
+			return err
+		}
+
+		target := session + ":" + name
+		paneCommands := append([]string{window.Command}, window.Panes...)
+		for j, command := range paneCommands {
+			if j > 0 {
+				if err := runTmux("split-window", "-t", target, "-c", dir, shell); err != nil {
+					return err
+				}
+			}
+			if command != "" {
+				if err := runTmux("send-keys", "-t", target, command, "Enter"); err != nil {
+					return err
+				}
+			}
+		}
+		if len(window.Panes) > 0 {
+			if err := runTmux("select-layout", "-t", target, valueOr(window.Layout, "tiled")); err != nil {
+				return err
+			}
+		}
+	}
+
+	return runTmux("select-window", "-t", session+":"+valueOr(windows[0].Name, "1"))
+}
+
+// tmuxShell returns the command started in each pane: the user's shell,
+// run through 'direnv exec' so the profile environment is loaded even when
+// the shell has no direnv hook
+func tmuxShell(profileDir string) string {
+	shell := valueOr(os.Getenv("SHELL"), "/bin/sh")
+	if !direnv.Installed() {
+		return shell
+	}
+	return fmt.Sprintf("direnv exec %s %s", shellQuote(profileDir), shell)
+}
+
+func runTmux(args ...string) error {
+	cmd := exec.Command("tmux", args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("tmux %s failed: %w: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// tmuxSessionName returns the profile name with characters tmux does not
+// allow in session names replaced
+func tmuxSessionName(profileName string) string {
+	return strings.NewReplacer(".", "_", ":", "_").Replace(profileName)
+}
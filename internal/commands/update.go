@@ -2,11 +2,16 @@ package commands
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
+	"sync"
 
+	"github.com/mindmorass/shell-profile-manager/internal/envrc"
+	"github.com/mindmorass/shell-profile-manager/internal/features"
+	"github.com/mindmorass/shell-profile-manager/internal/gitignore"
+	"github.com/mindmorass/shell-profile-manager/internal/plan"
 	"github.com/mindmorass/shell-profile-manager/internal/ui"
 )
 
@@ -14,542 +19,592 @@ type UpdateOptions struct {
 	ProfileName string
 	Force       bool
 	DryRun      bool
+	// Out saves the dry-run plan to a file for 'profile apply'
+	Out         string
 	NoBackup    bool
+	AllowDirenv bool
+	NoHooks     bool
+	// Commit commits the changes when the profile is a git repository
+	// (auto_commit in the config; --no-commit turns it off)
+	Commit bool
+	// EnforcePolicy fails the update while the profile violates the team
+	// policy (policy_enforce in the config)
+	EnforcePolicy bool
+	// All updates every profile
+	All bool
+	// Tags updates the profiles tagged with every one of these
+	Tags []string
+	// Jobs is how many profiles --all and --tag update at once (0: the
+	// default)
+	Jobs int
+}
+
+// UpdateResult is what update changed, as shown with --output json|yaml.
+// With --dry-run the plan is shown instead.
+type UpdateResult struct {
+	Profile string   `json:"profile" yaml:"profile"`
+	Changes []string `json:"changes" yaml:"changes"`
 }
 
 // UpdateProfile updates an existing profile with new features
 func UpdateProfile(profilesDir string, opts UpdateOptions) error {
+	if opts.All || len(opts.Tags) > 0 {
+		return updateAllProfiles(profilesDir, opts)
+	}
+
 	// If no profile name provided, show interactive selection
 	if opts.ProfileName == "" {
-		entries, err := os.ReadDir(profilesDir)
-		if err != nil {
-			return fmt.Errorf("failed to read profiles directory: %w", err)
-		}
-
-		var profiles []string
-		for _, entry := range entries {
-			if entry.IsDir() && entry.Name() != ".git" {
-				profilePath := filepath.Join(profilesDir, entry.Name())
-				envrcPath := filepath.Join(profilePath, ".envrc")
-				if _, err := os.Stat(envrcPath); err == nil {
-					profiles = append(profiles, entry.Name())
-				}
-			}
-		}
-
-		if len(profiles) == 0 {
-			return fmt.Errorf("no profiles found")
-		}
-
-		selected, err := ui.SelectProfile(profiles, "Select profile to update:")
+		selected, err := PromptForProfile(profilesDir, "Select profile to update:")
 		if err != nil {
 			return err
 		}
 		opts.ProfileName = selected
 	}
 
-	profileDir := filepath.Join(profilesDir, opts.ProfileName)
+	opts.ProfileName = qualifiedName(profilesDir, opts.ProfileName)
+	profileDir := profilePath(profilesDir, opts.ProfileName)
 
 	// Check if profile exists
 	if _, err := os.Stat(profileDir); os.IsNotExist(err) {
-		return fmt.Errorf("profile '%s' does not exist at: %s", opts.ProfileName, profileDir)
+		return NewValidationError("profile '%s' does not exist at: %s", opts.ProfileName, profileDir)
 	}
 
 	envrcPath := filepath.Join(profileDir, ".envrc")
 	if _, err := os.Stat(envrcPath); os.IsNotExist(err) {
-		return fmt.Errorf("profile '%s' does not appear to be a valid profile (missing .envrc)", opts.ProfileName)
+		return NewValidationError("profile '%s' does not appear to be a valid profile (missing .envrc)", opts.ProfileName)
 	}
 
-	ui.PrintInfo(fmt.Sprintf("Updating profile: %s", opts.ProfileName))
-	fmt.Printf("  Location: %s\n", profileDir)
-	fmt.Println()
-
-	// Create backup unless --no-backup is specified
-	if !opts.NoBackup && !opts.DryRun {
-		if err := createBackup(profileDir, opts.ProfileName); err != nil {
-			ui.PrintWarning(fmt.Sprintf("Failed to create backup: %v", err))
-			if !opts.Force {
-				confirmed, err := ui.Confirm("Continue without backup?", false)
-				if err != nil || !confirmed {
-					return fmt.Errorf("update cancelled")
-				}
-			}
-		}
+	feats, err := LoadProfileFeatures(profileDir)
+	if err != nil {
+		return err
 	}
 
-	// Track what was updated
-	updates := []string{}
+	ui.PrintInfo(fmt.Sprintf("Updating profile: %s", opts.ProfileName))
+	fmt.Fprintf(ui.MessageOutput(), "  Location: %s\n\n", profileDir)
 
-	// Update directories
-	if updated, err := updateDirectories(profileDir, opts.DryRun); err != nil {
-		return fmt.Errorf("failed to update directories: %w", err)
-	} else if len(updated) > 0 {
-		updates = append(updates, fmt.Sprintf("Created directories: %s", strings.Join(updated, ", ")))
+	// Dry run
+	if opts.DryRun {
+		p, err := planUpdate(profileDir, opts, feats)
+		if err != nil {
+			return err
+		}
+		return showPlan(p, opts.Out)
 	}
 
-	// Update .envrc
-	if updated, err := updateEnvrc(profileDir, opts.ProfileName, opts.DryRun, opts.Force); err != nil {
-		return fmt.Errorf("failed to update .envrc: %w", err)
-	} else if updated {
-		updates = append(updates, "Updated .envrc with new environment variables")
+	updates, err := applyUpdate(profileDir, opts, feats)
+	if err != nil {
+		return err
 	}
-
-	// Update .gitignore
-	if updated, err := updateGitignore(profileDir, opts.DryRun, opts.Force); err != nil {
-		return fmt.Errorf("failed to update .gitignore: %w", err)
-	} else if updated {
-		updates = append(updates, "Updated .gitignore with new patterns")
+	if opts.EnforcePolicy {
+		if err := enforcePolicy(profileDir, opts.ProfileName); err != nil {
+			return err
+		}
 	}
 
 	// Summary
-	if opts.DryRun {
-		ui.PrintInfo("DRY RUN - No changes were made")
-		if len(updates) > 0 {
-			fmt.Println()
-			fmt.Println("Would update:")
-			for _, update := range updates {
-				fmt.Printf("  - %s\n", update)
-			}
-		} else {
-			fmt.Println("  Profile is already up to date")
+	if ui.Structured() {
+		if err := ui.Render(UpdateResult{Profile: opts.ProfileName, Changes: updates}); err != nil {
+			return err
 		}
-	} else {
-		if len(updates) > 0 {
-			ui.PrintSuccess("Profile updated successfully")
-			fmt.Println()
-			fmt.Println("Updates applied:")
-			for _, update := range updates {
-				fmt.Printf("  ✓ %s\n", update)
-			}
-		} else {
-			ui.PrintInfo("Profile is already up to date")
+		if len(updates) == 0 {
+			return nil
+		}
+		runPostUpdateHooks(profileDir, opts.ProfileName, opts)
+		return changesApplied()
+	}
+
+	if len(updates) > 0 {
+		ui.PrintSuccess("Profile updated successfully")
+		fmt.Println()
+		fmt.Println("Updates applied:")
+		for _, update := range updates {
+			fmt.Printf("  ✓ %s\n", update)
 		}
+		fmt.Println()
+		runPostUpdateHooks(profileDir, opts.ProfileName, opts)
+		return changesApplied()
 	}
+	ui.PrintInfo("Profile is already up to date")
 
 	return nil
 }
 
-func createBackup(profileDir, _profileName string) error {
-	backupDir := filepath.Join(profileDir, ".backups")
-	if err := os.MkdirAll(backupDir, 0755); err != nil {
-		return fmt.Errorf("failed to create backup directory: %w", err)
+// updateAllProfiles updates every profile, or those with the tags in
+// opts.Tags, with a progress line per profile and reports the failures at
+// the end. With --dry-run the plan of each profile is shown instead.
+func updateAllProfiles(profilesDir string, opts UpdateOptions) error {
+	if opts.ProfileName != "" {
+		return NewValidationError("--all and --tag update %s; do not also name one", selectionTarget(opts.Tags))
+	}
+	if opts.Out != "" {
+		return NewValidationError("--out saves the plan of a single profile and cannot be used with --all or --tag")
 	}
 
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	backupPath := filepath.Join(backupDir, fmt.Sprintf("update_%s", timestamp))
-
-	// Copy important files
-	filesToBackup := []string{
-		".envrc",
-		".gitconfig",
-		".gitignore",
+	profiles, err := taggedProfiles(profilesDir, opts.Tags)
+	if err != nil {
+		return err
+	}
+	if len(profiles) == 0 {
+		ui.PrintInfo("No profiles found")
+		return nil
 	}
 
-	for _, file := range filesToBackup {
-		src := filepath.Join(profileDir, file)
-		if _, err := os.Stat(src); err == nil {
-			content, err := os.ReadFile(src)
+	if opts.DryRun {
+		plans := []*plan.Plan{}
+		for _, profileName := range profiles {
+			profileDir := profilePath(profilesDir, profileName)
+			feats, err := LoadProfileFeatures(profileDir)
 			if err != nil {
+				ui.PrintWarning(fmt.Sprintf("%s: %v", profileName, err))
 				continue
 			}
-
-			backupFile := filepath.Join(backupPath, file)
-			if err := os.MkdirAll(filepath.Dir(backupFile), 0755); err != nil {
+			profileOpts := opts
+			profileOpts.ProfileName, profileOpts.All, profileOpts.Tags = profileName, false, nil
+			p, err := planUpdate(profileDir, profileOpts, feats)
+			if err != nil {
+				ui.PrintWarning(fmt.Sprintf("%s: %v", profileName, err))
 				continue
 			}
-
-			if err := os.WriteFile(backupFile, content, 0644); err != nil {
-				continue
+			plans = append(plans, p)
+		}
+		if ui.Structured() {
+			return ui.Render(plans)
+		}
+		for i, p := range plans {
+			if i > 0 {
+				fmt.Println()
 			}
+			p.Render(os.Stdout)
+		}
+		return nil
+	}
+
+	// Plugins are asked for their features and the policy repository is
+	// cloned once, before the profiles are updated concurrently
+	if _, err := features.Load(); err != nil {
+		return fmt.Errorf("failed to load feature definitions: %w", err)
+	}
+	if opts.EnforcePolicy {
+		if _, err := loadPolicy(); err != nil {
+			return err
 		}
 	}
 
-	ui.PrintInfo(fmt.Sprintf("Backup created: %s", backupPath))
+	progress := ui.NewProgress("Updating", len(profiles))
+	var mu sync.Mutex
+	updated := map[string][]string{}
+	progress.RunEach(profiles, parallelJobs(opts.Jobs), func(profileName string) (string, error) {
+		profileDir := profilePath(profilesDir, profileName)
+		if _, err := os.Stat(filepath.Join(profileDir, ".envrc")); err != nil {
+			return "", ui.Skipped("missing .envrc")
+		}
+
+		profileOpts := opts
+		profileOpts.ProfileName, profileOpts.All, profileOpts.Tags = profileName, false, nil
+		feats, err := LoadProfileFeatures(profileDir)
+		if err != nil {
+			return "", err
+		}
+		updates, err := applyUpdate(profileDir, profileOpts, feats)
+		if err != nil {
+			return "", err
+		}
+		if profileOpts.EnforcePolicy {
+			if err := enforcePolicy(profileDir, profileName); err != nil {
+				return "", err
+			}
+		}
+		mu.Lock()
+		updated[profileName] = updates
+		mu.Unlock()
+		if len(updates) == 0 {
+			return "up to date", nil
+		}
+		runPostUpdateHooks(profileDir, profileName, profileOpts)
+		return fmt.Sprintf("%d change(s)", len(updates)), nil
+	})
+
+	failed := progress.Finish()
+	results := []UpdateResult{}
+	changed := 0
+	for _, profileName := range profiles {
+		updates, ok := updated[profileName]
+		if !ok {
+			continue
+		}
+		results = append(results, UpdateResult{Profile: profileName, Changes: updates})
+		if len(updates) > 0 {
+			changed++
+		}
+	}
+	if ui.Structured() {
+		if err := ui.Render(results); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d profile(s) failed to update", failed, len(profiles))
+	}
+	if changed > 0 {
+		return changesApplied()
+	}
 	return nil
 }
 
-func updateDirectories(profileDir string, dryRun bool) ([]string, error) {
-	requiredDirs := []string{
-		".config/1Password",
-		".config/claude",
-		".config/gemini",
-		".ssh",
-		".aws",
-		".azure",
-		".gcloud",
-		".kube",
-		"bin",
-		"code",
+// applyUpdate backs up the profile and applies every update step,
+// returning a description of each change. With opts.Commit the changes are
+// committed when the profile is a git repository.
+func applyUpdate(profileDir string, opts UpdateOptions, feats []features.Feature) ([]string, error) {
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return nil, err
 	}
+	defer unlock()
 
-	var created []string
-	for _, dir := range requiredDirs {
-		fullPath := filepath.Join(profileDir, dir)
-		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-			if !dryRun {
-				if err := os.MkdirAll(fullPath, 0755); err != nil {
-					return nil, fmt.Errorf("failed to create directory %s: %w", dir, err)
+	// Files with uncommitted changes are not the update's to commit
+	dirty, isRepo := map[string]bool(nil), false
+	if opts.Commit {
+		dirty, isRepo = gitDirtyPaths(profileDir)
+	}
+
+	// Create backup unless --no-backup is specified
+	if !opts.NoBackup {
+		if backupPath, err := CreateBackup(profileDir); err == nil {
+			ui.PrintInfo(fmt.Sprintf("Backup created: %s", backupPath))
+		} else {
+			ui.PrintWarning(fmt.Sprintf("Failed to create backup: %v", err))
+			if !opts.Force {
+				confirmed, err := ui.Confirm("Continue without backup?", false)
+				if err != nil {
+					return nil, fmt.Errorf("update cancelled: %w", err)
+				}
+				if !confirmed {
+					return nil, fmt.Errorf("update cancelled")
 				}
 			}
-			created = append(created, dir)
 		}
 	}
 
-	// Set SSH directory permissions
-	sshDir := filepath.Join(profileDir, ".ssh")
-	if _, err := os.Stat(sshDir); err == nil && !dryRun {
-		if err := os.Chmod(sshDir, 0700); err != nil {
-			// Non-fatal, just warn
-			ui.PrintWarning(fmt.Sprintf("Failed to set SSH directory permissions: %v", err))
-		}
+	updates, err := ApplyUpdateSteps(profileDir, feats)
+	if err != nil || !isRepo || len(updates) == 0 {
+		return updates, err
 	}
-
-	return created, nil
+	if subject, err := commitManagedChanges(profileDir, dirty, commitSubject("update", updates), updates); err != nil {
+		ui.PrintWarning(fmt.Sprintf("Failed to commit the changes: %v", err))
+	} else if subject != "" {
+		ui.PrintInfo(fmt.Sprintf("Committed: %s", subject))
+	}
+	return updates, nil
 }
 
-func updateEnvrc(profileDir, _profileName string, dryRun, _force bool) (bool, error) {
-	envrcPath := filepath.Join(profileDir, ".envrc")
-	content, err := os.ReadFile(envrcPath)
+// ApplyUpdateSteps brings a profile up to date with feats without backing
+// it up first, returning a description of each change
+func ApplyUpdateSteps(profileDir string, feats []features.Feature) ([]string, error) {
+	unlock, err := lockProfile(profileDir)
 	if err != nil {
-		return false, fmt.Errorf("failed to read .envrc: %w", err)
-	}
-
-	envrcContent := string(content)
-	updated := false
-
-	// Define sections with their variables
-	sections := []struct {
-		comment string
-		vars    []struct {
-			name string
-			line string
-		}
-	}{
-		{
-			comment: "# XDG Base Directory specification\n# Point all XDG-compliant tools to workspace-specific config\n",
-			vars: []struct {
-				name string
-				line string
-			}{
-				{"XDG_CONFIG_HOME", `export XDG_CONFIG_HOME="$WORKSPACE_HOME/.config"`},
-			},
-		},
-		{
-			comment: "# Git configuration\n",
-			vars: []struct {
-				name string
-				line string
-			}{
-				{"GIT_CONFIG_GLOBAL", `export GIT_CONFIG_GLOBAL="$WORKSPACE_HOME/.gitconfig"`},
-			},
-		},
-		{
-			comment: "# AWS configuration\n# Point AWS CLI and SDKs to workspace-specific config and credentials\n",
-			vars: []struct {
-				name string
-				line string
-			}{
-				{"AWS_CONFIG_FILE", `export AWS_CONFIG_FILE="$WORKSPACE_HOME/.aws/config"`},
-				{"AWS_SHARED_CREDENTIALS_FILE", `export AWS_SHARED_CREDENTIALS_FILE="$WORKSPACE_HOME/.aws/credentials"`},
-			},
-		},
-		{
-			comment: "# Kubernetes configuration\n# Point kubectl to workspace-specific kubeconfig\n",
-			vars: []struct {
-				name string
-				line string
-			}{
-				{"KUBECONFIG", `export KUBECONFIG="$WORKSPACE_HOME/.kube/config"`},
-			},
-		},
-		{
-			comment: "# Terraform configuration\n# Use workspace-specific Terraform CLI config\n",
-			vars: []struct {
-				name string
-				line string
-			}{
-				{"TF_CLI_CONFIG_FILE", `export TF_CLI_CONFIG_FILE="$WORKSPACE_HOME/.terraformrc"`},
-			},
-		},
-		{
-			comment: "# Azure CLI configuration\n# Point Azure CLI to workspace-specific config directory\n",
-			vars: []struct {
-				name string
-				line string
-			}{
-				{"AZURE_CONFIG_DIR", `export AZURE_CONFIG_DIR="$WORKSPACE_HOME/.azure"`},
-			},
-		},
-		{
-			comment: "# Google Cloud SDK configuration\n# Point gcloud CLI to workspace-specific config directory\n",
-			vars: []struct {
-				name string
-				line string
-			}{
-				{"CLOUDSDK_CONFIG", `export CLOUDSDK_CONFIG="$WORKSPACE_HOME/.gcloud"`},
-			},
-		},
-		{
-			comment: "# Claude Code configuration\n# Point Claude Code to workspace-specific config directory\n",
-			vars: []struct {
-				name string
-				line string
-			}{
-				{"CLAUDE_CONFIG_DIR", `export CLAUDE_CONFIG_DIR="$WORKSPACE_HOME/.config/claude"`},
-			},
-		},
-		{
-			comment: "# Gemini CLI configuration\n# Point Gemini CLI to workspace-specific config directory\n",
-			vars: []struct {
-				name string
-				line string
-			}{
-				{"GEMINI_CONFIG_DIR", `export GEMINI_CONFIG_DIR="$WORKSPACE_HOME/.config/gemini"`},
-			},
-		},
+		return nil, err
 	}
+	defer unlock()
 
-	// Find insertion point (before "# Load .env file")
-	insertPoint := strings.Index(envrcContent, "# Load .env file if it exists")
-	if insertPoint == -1 {
-		insertPoint = strings.Index(envrcContent, "dotenv_if_exists .env")
-		if insertPoint == -1 {
-			// Append at end before welcome message
-			insertPoint = strings.LastIndex(envrcContent, "# Welcome message")
-			if insertPoint == -1 {
-				insertPoint = len(envrcContent)
-			}
-		}
+	// Track what was updated, and where managed items come from
+	updates := []string{}
+	sources := itemSources(feats)
+
+	// Run schema migrations before reconciling features
+	if ran, err := runMigrations(profileDir, false); err != nil {
+		return nil, err
+	} else if len(ran) > 0 {
+		updates = append(updates, fmt.Sprintf("Ran migrations: %s", strings.Join(ran, "; ")))
+	}
+
+	// Update directories
+	if updated, err := updateDirectories(profileDir, feats, false); err != nil {
+		return nil, newIOError(err, "failed to update directories")
+	} else if len(updated) > 0 {
+		updates = append(updates, fmt.Sprintf("Created directories: %s", strings.Join(withLayerSources(updated, sources), ", ")))
+	}
+
+	// Create missing starter files
+	if updated, err := updateFiles(profileDir, feats, false); err != nil {
+		return nil, newIOError(err, "failed to create files")
+	} else if len(updated) > 0 {
+		updates = append(updates, fmt.Sprintf("Created files: %s", strings.Join(withLayerSources(updated, sources), ", ")))
+	}
+
+	// Link shared files
+	linked, repaired, err := updateLinks(profileDir, feats, false)
+	if err != nil {
+		return nil, newIOError(err, "failed to update links")
+	}
+	if len(linked) > 0 {
+		updates = append(updates, fmt.Sprintf("Linked files: %s", strings.Join(withLayerSources(linked, sources), ", ")))
+	}
+	if len(repaired) > 0 {
+		updates = append(updates, fmt.Sprintf("Repaired links: %s", strings.Join(withLayerSources(repaired, sources), ", ")))
+	}
+
+	// Restrict SSH keys and credentials, which tools refuse or warn about
+	// when others can read them
+	if fixed, err := updatePermissions(profileDir, false); err != nil {
+		return nil, newIOError(err, "failed to fix permissions")
+	} else if len(fixed) > 0 {
+		updates = append(updates, fmt.Sprintf("Restricted permissions: %s", strings.Join(violationPaths(fixed), ", ")))
+	}
+
+	// Update .envrc, migrating deprecated variables before adding their
+	// replacements. It is written once, and only if its content changed.
+	doc, err := loadEnvrc(profileDir)
+	if err != nil {
+		return nil, newIOError(err, "failed to update .envrc")
 	}
+	migrated, err := migrateDeprecated(doc, feats, false)
+	if err != nil {
+		return nil, newIOError(err, "failed to migrate deprecated variables")
+	}
+	if len(migrated) > 0 {
+		updates = append(updates, fmt.Sprintf("Migrated deprecated variables: %s", strings.Join(migrated, ", ")))
+	}
+	if updated := updateEnvrc(doc, feats); len(updated) > 0 {
+		updates = append(updates, fmt.Sprintf("Updated .envrc: %s", strings.Join(withLayerSources(updated, sources), ", ")))
+	}
+	if _, err := saveEnvrc(profileDir, doc); err != nil {
+		return nil, newIOError(err, "failed to update .envrc")
+	}
+
+	// Update .gitignore
+	if updated, err := updateGitignore(profileDir, feats, false); err != nil {
+		return nil, newIOError(err, "failed to update .gitignore")
+	} else if len(updated) > 0 {
+		updates = append(updates, fmt.Sprintf("Updated .gitignore: %s", strings.Join(withLayerSources(updated, sources), ", ")))
+	}
+
+	if len(updates) > 0 {
+		recordHistory(profileDir, "update", updates)
+	}
+	return updates, nil
+}
 
-	before := envrcContent[:insertPoint]
-	after := envrcContent[insertPoint:]
+func updateDirectories(profileDir string, feats []features.Feature, dryRun bool) ([]string, error) {
+	var created []string
+	for _, feature := range feats {
+		for _, dir := range feature.Dirs {
+			fullPath := filepath.Join(profileDir, dir.Path)
+			if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+				if !dryRun {
+					if err := os.MkdirAll(fullPath, dir.FileMode()); err != nil {
+						return nil, fmt.Errorf("failed to create directory %s: %w", dir.Path, err)
+					}
+				}
+				created = append(created, dir.Path)
+			}
 
-	// Process each section
-	for _, section := range sections {
-		// Check which variables in this section are missing
-		var missingVars []string
-		for _, v := range section.vars {
-			if !strings.Contains(envrcContent, v.name) {
-				missingVars = append(missingVars, v.line)
+			// Enforce restricted permissions (e.g. .ssh)
+			if dir.Mode != "" && !dryRun {
+				if err := os.Chmod(fullPath, dir.FileMode()); err != nil {
+					// Non-fatal, just warn
+					reporter.Warning(fmt.Sprintf("Failed to set permissions on %s: %v", dir.Path, err))
+				}
 			}
 		}
+	}
 
-		if len(missingVars) > 0 {
-			// Check if section comment already exists
-			sectionExists := strings.Contains(before, section.comment)
+	return created, nil
+}
 
-			var newContent string
-			if !sectionExists {
-				// Add section comment and all missing variables
-				newContent = section.comment
-				for _, varLine := range missingVars {
-					newContent += varLine + "\n"
+// updateFiles creates the starter files of any feature that are missing
+// from the profile, copying those with a source. Files of features with
+// the link strategy are left to updateLinks. It returns the paths of the
+// created files.
+func updateFiles(profileDir string, feats []features.Feature, dryRun bool) ([]string, error) {
+	var created []string
+	for _, feature := range feats {
+		for _, file := range feature.Files {
+			if feature.Linked(file) {
+				continue
+			}
+			fullPath := filepath.Join(profileDir, file.Path)
+			if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+				content := file.Render(profileDir)
+				if file.Source != "" {
+					source, err := os.ReadFile(file.SourcePath())
+					if err != nil {
+						if !dryRun {
+							reporter.Warning(fmt.Sprintf("Not creating %s: failed to read %s: %v", file.Path, file.SourcePath(), err))
+						}
+						continue
+					}
+					content = string(source)
 				}
-				newContent += "\n"
-			} else {
-				// Section exists, find where to insert variables
-				// Insert after the section comment
-				sectionStart := strings.Index(before, section.comment)
-				if sectionStart != -1 {
-					sectionEnd := sectionStart + len(section.comment)
-					// Find next section or end
-					nextSection := strings.Index(before[sectionEnd:], "\n# ")
-					if nextSection == -1 {
-						nextSection = len(before) - sectionEnd
+				if !dryRun {
+					if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+						return nil, fmt.Errorf("failed to create directory for %s: %w", file.Path, err)
+					}
+					if err := writeProfileFile(fullPath, []byte(content), file.FileMode()); err != nil {
+						return nil, fmt.Errorf("failed to create %s: %w", file.Path, err)
 					}
-					// Insert variables before next section
-					insertPos := sectionEnd + nextSection
-					before = before[:insertPos] + strings.Join(missingVars, "\n") + "\n" + before[insertPos:]
-					updated = true
-					continue
 				}
-				// Fallback: just add variables
-				newContent = strings.Join(missingVars, "\n") + "\n\n"
+				created = append(created, file.Path)
 			}
 
-			before += newContent
-			updated = true
+			// Enforce restricted permissions (e.g. .netrc), also on existing files
+			if file.Mode != "" && !dryRun {
+				if err := os.Chmod(fullPath, file.FileMode()); err != nil {
+					// Non-fatal, just warn
+					reporter.Warning(fmt.Sprintf("Failed to set permissions on %s: %v", file.Path, err))
+				}
+			}
 		}
 	}
 
-	if updated && !dryRun {
-		envrcContent = before + after
-		if err := os.WriteFile(envrcPath, []byte(envrcContent), 0644); err != nil {
-			return false, fmt.Errorf("failed to write .envrc: %w", err)
+	return created, nil
+}
+
+// updateEnvrc adds the .envrc sections and variables of any feature that is
+// missing from doc. It returns the names of the added variables.
+func updateEnvrc(doc *envrc.Document, feats []features.Feature) []string {
+	var added []string
+
+	// Variables of profile layers are managed: an export whose value no
+	// longer matches the layer that wins is rewritten
+	for _, feature := range feats {
+		if !strings.HasPrefix(feature.Name, layerFeaturePrefix) {
+			continue
+		}
+		for _, v := range feature.Env {
+			if idx := doc.Export(v.Name); idx != -1 && strings.TrimSpace(doc.Line(idx)) != envLine(feature, v) {
+				slog.Debug("rewriting layer variable", "name", v.Name, "layer", feature.Name)
+				doc.SetExport(idx, envLine(feature, v))
+				added = append(added, v.Name)
+			}
 		}
 	}
 
-	return updated, nil
-}
-
-func updateGitignore(profileDir string, dryRun, _force bool) (bool, error) {
-	gitignorePath := filepath.Join(profileDir, ".gitignore")
-	content, err := os.ReadFile(gitignorePath)
-	if err != nil {
-		// .gitignore doesn't exist, create it using the same function from create.go
-		// We'll create a basic one inline
-		if !dryRun {
-			gitignoreContent := `# Workspace profile gitignore
-
-# Environment files with secrets
-.env
-.envrc.local
-
-# SSH keys and sensitive files
-.ssh/id_*
-.ssh/*.pem
-.ssh/*.key
-.ssh/known_hosts
-
-# AWS credentials and sensitive config
-.aws/credentials
-.aws/cli/cache
-.aws/sso/cache
-
-# Azure CLI credentials and sensitive config
-.azure/config
-.azure/clouds.config
-.azure/accessTokens.json
-.azure/msal_token_cache.json
-.azure/azureProfile.json
-
-# Google Cloud SDK credentials and sensitive config
-.gcloud/configurations/
-.gcloud/credentials
-.gcloud/access_tokens.db
-.gcloud/legacy_credentials/
-.gcloud/logs/
-
-# Claude Code configuration (may contain API keys and sensitive data)
-.config/claude/
-
-# Gemini CLI configuration (may contain API keys and sensitive data)
-.config/gemini/
-
-# Terraform
-.terraform/
-.terraform.lock.hcl
-*.tfstate
-*.tfstate.*
-*.tfvars
-.terraform.d/plugin-cache/
-.terraform.d/checkpoint_cache
-.terraform.d/checkpoint_signature
-
-# Terragrunt
-.terragrunt-cache/
-*.tfplan
-
-# Kubernetes
-.kube/cache
-.kube/http-cache
-
-# OS files
-.DS_Store
-Thumbs.db
-
-# Editor files
-.vscode/
-.idea/
-*.swp
-*.swo
-*~
-
-# Build artifacts
-bin/
-dist/
-build/
-*.log
-`
-			if err := os.WriteFile(gitignorePath, []byte(gitignoreContent), 0644); err != nil {
-				return false, fmt.Errorf("failed to create .gitignore: %w", err)
+	// Find insertion point (before "# Load .env file")
+	anchor := envSectionAnchor
+	insertAt := doc.Index(anchor, 0)
+	if insertAt == -1 {
+		anchor = "dotenv_if_exists .env"
+		insertAt = doc.Index(anchor, 0)
+		if insertAt == -1 {
+			// Append at end before welcome message
+			anchor = "# Welcome message"
+			insertAt = doc.LastIndex(anchor)
+			if insertAt == -1 {
+				anchor = "end of file"
+				insertAt = doc.Len()
+				if doc.Line(insertAt-1) == "" {
+					insertAt--
+				}
 			}
 		}
-		return true, nil
 	}
+	slog.Debug("new .envrc sections go before anchor", "anchor", anchor, "line", insertAt+1)
 
-	gitignoreContent := string(content)
-	updated := false
+	// Process each feature section
+	for _, feature := range feats {
+		if !feature.HasEnvrcSection() {
+			continue
+		}
 
-	// Check and add missing patterns
-	requiredPatterns := map[string]string{
-		".azure/config":              "# Azure CLI credentials and sensitive config",
-		".gcloud/configurations":     "# Google Cloud SDK credentials and sensitive config",
-		".gcloud/credentials":        "",
-		".gcloud/access_tokens.db":   "",
-		".gcloud/legacy_credentials": "",
-		".gcloud/logs":               "",
-		".config/claude/":            "# Claude Code configuration (may contain API keys and sensitive data)",
-		".config/gemini/":            "# Gemini CLI configuration (may contain API keys and sensitive data)",
-	}
+		// Check which variables and lines in this section are missing
+		// (a commented-out export does not count)
+		var missingLines []string
+		for _, v := range feature.Env {
+			if doc.Export(v.Name) == -1 {
+				missingLines = append(missingLines, envLine(feature, v))
+				added = append(added, v.Name)
+			}
+		}
+		for _, line := range feature.Lines {
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+			if !doc.Contains(line) {
+				missingLines = append(missingLines, line)
+				added = append(added, line)
+			}
+		}
+
+		if len(missingLines) == 0 {
+			continue
+		}
 
-	// Group patterns by comment
-	patternsByComment := make(map[string][]string)
-	currentComment := ""
-	for pattern, comment := range requiredPatterns {
-		if comment != "" {
-			currentComment = comment
+		// Check if section comment already exists
+		var comment []string
+		if block := feature.CommentBlock(); block != "" {
+			comment = strings.Split(strings.TrimSuffix(block, "\n"), "\n")
 		}
-		if patternsByComment[currentComment] == nil {
-			patternsByComment[currentComment] = []string{}
+		sectionStart := doc.IndexSection(comment, insertAt)
+
+		if sectionStart == -1 {
+			// Add section comment and all missing lines
+			slog.Debug("adding .envrc section", "feature", feature.Name, "lines", missingLines)
+			section := append(append(append([]string{}, comment...), missingLines...), "")
+			doc.Insert(insertAt, section...)
+			insertAt += len(section)
+			continue
 		}
-		patternsByComment[currentComment] = append(patternsByComment[currentComment], pattern)
-	}
 
-	for comment, patterns := range patternsByComment {
-		// Check if any pattern from this group is missing
-		hasAny := false
-		for _, pattern := range patterns {
-			if strings.Contains(gitignoreContent, pattern) {
-				hasAny = true
-				break
-			}
+		// Section exists, append variables after its last line (sections
+		// are separated by blank lines)
+		sectionEnd := sectionStart + len(comment)
+		for sectionEnd < insertAt && doc.Line(sectionEnd) != "" {
+			sectionEnd++
 		}
+		slog.Debug("appending to existing .envrc section", "feature", feature.Name, "lines", missingLines, "line", sectionEnd+1)
+		doc.Insert(sectionEnd, missingLines...)
+		insertAt += len(missingLines)
+	}
 
-		if !hasAny {
-			// Find insertion point (after Azure section or at end)
-			insertPoint := strings.Index(gitignoreContent, "# Azure CLI credentials")
-			if insertPoint == -1 {
-				insertPoint = strings.Index(gitignoreContent, "# Terraform")
-				if insertPoint == -1 {
-					insertPoint = len(gitignoreContent)
-				}
-			} else {
-				// Find end of Azure section
-				insertPoint = strings.Index(gitignoreContent[insertPoint:], "\n\n#")
-				if insertPoint != -1 {
-					insertPoint += insertPoint
-				} else {
-					insertPoint = strings.Index(gitignoreContent, "# Terraform")
-					if insertPoint == -1 {
-						insertPoint = len(gitignoreContent)
-					}
-				}
+	return added
+}
+
+// updateGitignore adds the .gitignore patterns of any feature that are
+// missing from the profile. It returns the added patterns.
+func updateGitignore(profileDir string, feats []features.Feature, dryRun bool) ([]string, error) {
+	gitignorePath := filepath.Join(profileDir, ".gitignore")
+	content, err := os.ReadFile(gitignorePath)
+	if err != nil {
+		// .gitignore doesn't exist, create it from scratch
+		if !dryRun {
+			content, err := renderForProfile(profileDir, "gitignore", feats)
+			if err != nil {
+				return nil, err
+			}
+			if err := writeProfileFile(gitignorePath, []byte(content), 0644); err != nil {
+				return nil, fmt.Errorf("failed to create .gitignore: %w", err)
 			}
+			recordChecksums(profileDir, ".gitignore")
+		}
+		return []string{".gitignore"}, nil
+	}
 
-			before := gitignoreContent[:insertPoint]
-			after := gitignoreContent[insertPoint:]
+	file := gitignore.Parse(string(content))
+	var added []string
 
-			newSection := ""
-			if comment != "" {
-				newSection = comment + "\n"
+	for _, feature := range feats {
+		for _, group := range feature.Gitignore {
+			var missing []string
+			for _, pattern := range group.Patterns {
+				// Patterns the user explicitly re-included with "!" are left alone
+				if file.Status(pattern) == gitignore.Missing {
+					missing = append(missing, pattern)
+				}
 			}
-			for _, pattern := range patterns {
-				newSection += pattern + "\n"
+			if len(missing) == 0 {
+				continue
 			}
-			newSection += "\n"
-
-			gitignoreContent = before + newSection + after
-			updated = true
+			added = append(added, missing...)
+			file.AddToSection(group.CommentLine(), missing, "# OS files")
 		}
 	}
 
-	if updated && !dryRun {
-		if err := os.WriteFile(gitignorePath, []byte(gitignoreContent), 0644); err != nil {
-			return false, fmt.Errorf("failed to write .gitignore: %w", err)
+	if len(added) > 0 && !dryRun {
+		if err := writeProfileFile(gitignorePath, []byte(file.String()), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write .gitignore: %w", err)
 		}
+		recordChecksums(profileDir, ".gitignore")
 	}
 
-	return updated, nil
+	return added, nil
 }
@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/checksums"
+	"github.com/mindmorass/shell-profile-manager/internal/perms"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// checksummedFiles are the profile files spm generates, whose checksums it
+// records when it writes them. Starter files of features are the user's to
+// edit and are left out.
+var checksummedFiles = []string{".envrc", ".gitconfig", ".gitignore", ".ssh/config", "bin/ssh"}
+
+type VerifyOptions struct {
+	ProfileName string
+	// Accept records the current state of the files, accepting the changes
+	// made outside spm
+	Accept bool
+}
+
+// recordChecksums records the checksums of files spm just wrote. Failing to
+// is only a warning, the files were written.
+func recordChecksums(profileDir string, paths ...string) {
+	if err := checksums.Record(profileDir, paths...); err != nil {
+		reporter.Warning(fmt.Sprintf("Failed to record checksums: %v", err))
+	}
+}
+
+// checksumIssues describes the recorded files of a profile that changed
+// outside spm, as doctor lists them. The permissions of files holding
+// credentials are left to the check doctor makes for them.
+func checksumIssues(profileDir string) ([]string, error) {
+	results, err := checksums.Verify(profileDir)
+	if err != nil {
+		return nil, err
+	}
+	var issues, modified, missing []string
+	for _, result := range results {
+		switch result.Status {
+		case checksums.StatusModified:
+			modified = append(modified, result.Path)
+		case checksums.StatusMissing:
+			missing = append(missing, result.Path)
+		case checksums.StatusPermissions:
+			if _, ok := perms.Expected(result.Path, false); ok {
+				continue
+			}
+			issues = append(issues, fmt.Sprintf("Permissions of %s changed outside spm: %s (spm wrote %s)", result.Path, strings.TrimPrefix(result.Mode, "0"), strings.TrimPrefix(result.ExpectedMode, "0")))
+		}
+	}
+	if len(modified) > 0 {
+		issues = append([]string{fmt.Sprintf("Modified outside spm: %s", strings.Join(modified, ", "))}, issues...)
+	}
+	if len(missing) > 0 {
+		issues = append(issues, fmt.Sprintf("Removed outside spm: %s", strings.Join(missing, ", ")))
+	}
+	return issues, nil
+}
+
+// VerifyProfile compares the files spm wrote in a profile against the
+// checksums and permissions it recorded, or with Accept records their
+// current state
+func VerifyProfile(profilesDir string, opts VerifyOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+
+	if opts.Accept {
+		unlock, err := lockProfile(profileDir)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		if err := checksums.Record(profileDir, checksummedFiles...); err != nil {
+			return newIOError(err, "failed to record checksums")
+		}
+		ui.PrintSuccess(fmt.Sprintf("Recorded checksums of the managed files of %s", profileName))
+		return changesApplied()
+	}
+
+	results, err := checksums.Verify(profileDir)
+	if err != nil {
+		return err
+	}
+	problems := 0
+	for _, result := range results {
+		if result.Status != checksums.StatusOK {
+			problems++
+		}
+	}
+
+	if ui.Structured() {
+		if err := ui.Render(results); err != nil {
+			return err
+		}
+	} else {
+		if len(results) == 0 {
+			ui.PrintInfo(fmt.Sprintf("No checksums recorded for %s", profileName))
+			fmt.Printf("Record the current files with: profile verify %s --accept\n", profileName)
+			return nil
+		}
+		for _, result := range results {
+			switch result.Status {
+			case checksums.StatusOK:
+				fmt.Printf("%s✓ %s%s\n", ui.ColorGreen, result.Path, ui.ColorReset)
+			case checksums.StatusModified:
+				fmt.Printf("%s⚠ %s modified outside spm%s\n", ui.ColorYellow, result.Path, ui.ColorReset)
+			case checksums.StatusMissing:
+				fmt.Printf("%s✗ %s is missing%s\n", ui.ColorRed, result.Path, ui.ColorReset)
+			case checksums.StatusPermissions:
+				fmt.Printf("%s⚠ %s has permissions %s (spm wrote %s)%s\n", ui.ColorYellow, result.Path,
+					strings.TrimPrefix(result.Mode, "0"), strings.TrimPrefix(result.ExpectedMode, "0"), ui.ColorReset)
+			}
+		}
+		if problems > 0 {
+			fmt.Println()
+			fmt.Printf("Once reviewed, accept the changes with: profile verify %s --accept\n", profileName)
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d file(s) changed outside spm", problems)
+	}
+	return nil
+}
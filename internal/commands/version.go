@@ -0,0 +1,19 @@
+package commands
+
+import "runtime/debug"
+
+// Version is the spm version, set when building a release with
+// -ldflags "-X github.com/mindmorass/shell-profile-manager/internal/commands.Version=v1.3.0"
+var Version = ""
+
+// SpmVersion returns Version, or the module version of binaries built with
+// 'go install' ("dev" when neither is known)
+func SpmVersion() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
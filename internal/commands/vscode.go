@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/mindmorass/shell-profile-manager/internal/features"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+type CodeOptions struct {
+	ProfileName string
+	// Force regenerates an existing .code-workspace file
+	Force bool
+	// NoOpen writes the workspace file without launching VS Code
+	NoOpen bool
+}
+
+type codeWorkspace struct {
+	Folders    []codeFolder           `json:"folders"`
+	Settings   map[string]interface{} `json:"settings"`
+	Extensions codeRecommendations    `json:"extensions"`
+}
+
+type codeFolder struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type codeRecommendations struct {
+	Recommendations []string `json:"recommendations"`
+}
+
+// OpenCode writes <profile>.code-workspace (if needed) and opens it in
+// VS Code using a VS Code profile with the same name
+func OpenCode(profilesDir string, opts CodeOptions) error {
+	profileName, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProfile(profileDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// The workspace file and VS Code profile use the name without the root
+	profileName = baseProfileName(profileName)
+
+	workspacePath := filepath.Join(profileDir, profileName+".code-workspace")
+	_, statErr := os.Stat(workspacePath)
+	if os.IsNotExist(statErr) || opts.Force {
+		feats, err := LoadProfileFeatures(profileDir)
+		if err != nil {
+			return err
+		}
+		content, err := renderCodeWorkspace(profileName, profileDir, extensionsFor(feats))
+		if err != nil {
+			return err
+		}
+		if err := writeProfileFile(workspacePath, content, 0644); err != nil {
+			return newIOError(err, "failed to write %s", workspacePath)
+		}
+		ui.PrintSuccess(fmt.Sprintf("Wrote %s", workspacePath))
+	}
+
+	if opts.NoOpen {
+		return nil
+	}
+	if _, err := exec.LookPath("code"); err != nil {
+		return NewValidationError("VS Code 'code' command is not on PATH (Command Palette: Shell Command: Install 'code' command)")
+	}
+
+	cmd := exec.Command("code", "--profile", profileName, workspacePath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to launch VS Code: %w: %s", err, output)
+	}
+	ui.PrintInfo(fmt.Sprintf("Opened %s in VS Code (profile: %s)", filepath.Base(workspacePath), profileName))
+	return nil
+}
+
+// renderCodeWorkspace returns the .code-workspace JSON for a profile. The
+// integrated terminal gets WORKSPACE_HOME and WORKSPACE_PROFILE so tools
+// behave as in a direnv-loaded shell.
+func renderCodeWorkspace(profileName, profileDir string, extensions []string) ([]byte, error) {
+	folder := "code"
+	if _, err := os.Stat(filepath.Join(profileDir, folder)); err != nil {
+		folder = "."
+	}
+
+	env := map[string]string{
+		"WORKSPACE_HOME":    profileDir,
+		"WORKSPACE_PROFILE": profileName,
+	}
+	workspace := codeWorkspace{
+		Folders: []codeFolder{{Name: profileName, Path: folder}},
+		Settings: map[string]interface{}{
+			"terminal.integrated.env.osx":   env,
+			"terminal.integrated.env.linux": env,
+			"window.title":                  fmt.Sprintf("[%s] ${activeEditorShort}${separator}${rootName}", profileName),
+		},
+		Extensions: codeRecommendations{Recommendations: extensions},
+	}
+
+	content, err := json.MarshalIndent(workspace, "", "\t")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode workspace: %w", err)
+	}
+	return append(content, '\n'), nil
+}
+
+// extensionsFor returns the recommended extensions of the active features,
+// always starting with direnv support
+func extensionsFor(feats []features.Feature) []string {
+	extensions := []string{"mkhl.direnv"}
+	for _, feature := range feats {
+		for _, extension := range feature.VSCodeExtensions {
+			extensions = append(withoutName(extensions, extension), extension)
+		}
+	}
+	return extensions
+}
@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mindmorass/shell-profile-manager/internal/notify"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// defaultWatchInterval is how often watch checks the profiles
+const defaultWatchInterval = 5 * time.Minute
+
+type WatchOptions struct {
+	AlertOptions
+	// Interval is the time between checks (0: 5m)
+	Interval time.Duration
+	// Once checks once and exits, e.g. from cron or launchd
+	Once bool
+}
+
+// WatchProfiles checks every profile for drift and expiring credentials at
+// an interval, printing each new alert and sending a desktop notification
+// for it, until interrupted
+func WatchProfiles(profilesDir string, opts WatchOptions) error {
+	backend := valueOr(opts.Notify, notify.Auto)
+	if _, err := notify.Resolve(backend); err != nil {
+		return NewValidationError("%v; set notify= in the config to choose one", err)
+	}
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	if !opts.Once {
+		ui.PrintInfo(fmt.Sprintf("Watching profiles every %s (Ctrl-C to stop)", interval))
+	}
+
+	for {
+		alerts, err := ProfileAlerts(profilesDir, opts.AlertOptions)
+		if err != nil {
+			if opts.Once {
+				return err
+			}
+			ui.PrintWarning(fmt.Sprintf("Failed to check profiles: %v", err))
+		}
+		for _, alert := range NotifyAlerts(alerts, backend) {
+			fmt.Printf("%s %s⚠ %s: %s%s\n", time.Now().Format("15:04:05"), ui.ColorYellow, alert.Profile, alert.Message, ui.ColorReset)
+		}
+		if opts.Once {
+			return nil
+		}
+		time.Sleep(interval)
+	}
+}
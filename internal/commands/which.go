@@ -0,0 +1,201 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// Sources of the active profile
+const (
+	// activeFromEnv is a profile loaded by direnv (WORKSPACE_HOME)
+	activeFromEnv = "environment"
+	// activeFromCwd is the profile the current directory is in
+	activeFromCwd = "directory"
+)
+
+type WhichOptions struct {
+	// Name prints only the profile name
+	Name bool
+	// Path prints only the profile directory
+	Path bool
+}
+
+// ActiveProfile is the profile the current shell is working in, as shown
+// by 'profile which'
+type ActiveProfile struct {
+	Name string `json:"name" yaml:"name"`
+	Path string `json:"path" yaml:"path"`
+	// Source is how it was found: "environment" when direnv loaded it,
+	// "directory" when the current directory is inside it or in a
+	// directory linked to it
+	Source string `json:"source" yaml:"source"`
+}
+
+// errNoActiveProfile is returned by 'profile which' outside of a profile,
+// so prompts and scripts can test for one by the exit code
+var errNoActiveProfile = errors.New("no workspace profile is active")
+
+// ShowActiveProfile prints the name and directory of the active profile:
+// the one WORKSPACE_HOME points to, or else the one containing the current
+// directory. It fails when there is none.
+func ShowActiveProfile(profilesDir string, opts WhichOptions) error {
+	if opts.Name && opts.Path {
+		return NewValidationError("--name and --path cannot be used together")
+	}
+	active, ok := findActiveProfile(profilesDir)
+	if !ok {
+		return errNoActiveProfile
+	}
+
+	switch {
+	case ui.Structured():
+		return ui.Render(active)
+	case opts.Name:
+		fmt.Println(active.Name)
+	case opts.Path:
+		fmt.Println(active.Path)
+	default:
+		fmt.Printf("%s\t%s\n", active.Name, active.Path)
+	}
+	return nil
+}
+
+// findActiveProfile returns the profile loaded in the environment, or the
+// one the current directory is in
+func findActiveProfile(profilesDir string) (ActiveProfile, bool) {
+	if home := os.Getenv("WORKSPACE_HOME"); home != "" {
+		if name, ok := profileInDir(profilesDir, home); ok {
+			return ActiveProfile{Name: name, Path: home, Source: activeFromEnv}, true
+		}
+		// A profile outside the configured directories still names itself
+		if name := os.Getenv("WORKSPACE_PROFILE"); name != "" && isProfileDir(home) {
+			return ActiveProfile{Name: name, Path: home, Source: activeFromEnv}, true
+		}
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return ActiveProfile{}, false
+	}
+	if name, dir, ok := ProfileContaining(profilesDir, cwd); ok {
+		return ActiveProfile{Name: name, Path: dir, Source: activeFromCwd}, true
+	}
+	return ActiveProfile{}, false
+}
+
+// ProfileContaining returns the name and directory of the profile dir is
+// in, e.g. the folder open in an editor, or of the profile a directory
+// holding it is linked to with 'profile link'
+func ProfileContaining(profilesDir, dir string) (string, string, bool) {
+	if name, profileDir, ok := profileContainingDir(profilesDir, dir); ok {
+		return name, profileDir, true
+	}
+	if name, _, ok := linkedProfile(dir); ok {
+		if profileDir := profilePath(profilesDir, name); isProfileDir(profileDir) {
+			return qualifiedName(profilesDir, name), profileDir, true
+		}
+	}
+	return "", "", false
+}
+
+// profileContainingDir returns the profile dir is in, without following
+// links
+func profileContainingDir(profilesDir, dir string) (string, string, bool) {
+	for dir = filepath.Clean(dir); ; dir = filepath.Dir(dir) {
+		if name, ok := profileInDir(profilesDir, dir); ok {
+			return name, dir, true
+		}
+		if filepath.Dir(dir) == dir {
+			return "", "", false
+		}
+	}
+}
+
+// profileInDir returns the name of the profile in dir when it is one in
+// profilesDir or in one of the roots
+func profileInDir(profilesDir, dir string) (string, bool) {
+	if !isProfileDir(dir) {
+		return "", false
+	}
+	parent, name := filepath.Dir(dir), filepath.Base(dir)
+	if samePath(parent, profilesDir) {
+		return name, true
+	}
+	for _, root := range rootNames() {
+		if samePath(parent, profileRoots[root]) {
+			return root + "/" + name, true
+		}
+	}
+	return "", false
+}
+
+// isProfileDir reports whether dir holds an .envrc, as every profile does
+func isProfileDir(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".envrc"))
+	return err == nil
+}
+
+// samePath reports whether two paths name the same directory, following
+// symbolic links
+func samePath(a, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	resolvedA, err := filepath.EvalSymlinks(a)
+	if err != nil {
+		return false
+	}
+	resolvedB, err := filepath.EvalSymlinks(b)
+	return err == nil && resolvedA == resolvedB
+}
+
+// PrintProfileDir prints the directory of a profile, or of dir inside it,
+// for the cd function of 'profile hook --with-cd'. Without a name it is
+// the directory of the active profile.
+func PrintProfileDir(profilesDir, profileName, dir string) error {
+	var profileDir string
+	if profileName == "" {
+		active, ok := findActiveProfile(profilesDir)
+		if !ok {
+			return errNoActiveProfile
+		}
+		profileDir = active.Path
+	} else {
+		var err error
+		profileName, profileDir, err = resolveProfile(profilesDir, profileName, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	if dir != "" {
+		target := filepath.Join(profileDir, filepath.FromSlash(dir))
+		if info, err := os.Stat(target); err != nil || !info.IsDir() {
+			return NewValidationError("%s has no directory %s", valueOr(profileName, "the active profile"), dir)
+		}
+		profileDir = target
+	}
+	fmt.Println(profileDir)
+	return nil
+}
+
+// ProfileSubdirs returns the directories at the top of a profile, except
+// hidden ones, for completion
+func ProfileSubdirs(profilesDir, profileName string) []string {
+	entries, err := os.ReadDir(profilePath(profilesDir, profileName))
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			dirs = append(dirs, entry.Name())
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/wsl"
+)
+
+// WindowsEnvFormats are the output formats of PrintWindowsEnv
+var WindowsEnvFormats = []string{"powershell", "cmd", "setx"}
+
+type WindowsEnvOptions struct {
+	ProfileName string
+	// Vars limits the output to these variables (default: every path)
+	Vars []string
+	// Format is one of WindowsEnvFormats (default powershell)
+	Format string
+}
+
+// PrintWindowsEnv prints the profile's path variables (AWS_CONFIG_FILE,
+// KUBECONFIG, ...) translated to Windows paths, so Windows programs such
+// as IDEs and the AWS Toolkit use the same files as the WSL workspace
+func PrintWindowsEnv(profilesDir string, opts WindowsEnvOptions) error {
+	format := valueOr(opts.Format, "powershell")
+	if len(withoutName(WindowsEnvFormats, format)) == len(WindowsEnvFormats) {
+		return NewValidationError("unknown format: %s (supported: %s)", format, strings.Join(WindowsEnvFormats, ", "))
+	}
+	if !wsl.Detected() {
+		return NewValidationError("not running in WSL")
+	}
+
+	_, profileDir, err := resolveProfile(profilesDir, opts.ProfileName, "Select profile:")
+	if err != nil {
+		return err
+	}
+	feats, err := LoadProfileFeatures(profileDir)
+	if err != nil {
+		return err
+	}
+
+	paths := map[string]string{}
+	var names []string
+	for _, feature := range feats {
+		for _, v := range feature.Env {
+			path, ok := workspacePath(profileDir, v.Value)
+			if !ok || paths[v.Name] != "" {
+				continue
+			}
+			paths[v.Name] = path
+			names = append(names, v.Name)
+		}
+	}
+
+	if len(opts.Vars) > 0 {
+		for _, name := range opts.Vars {
+			if paths[name] == "" {
+				return NewValidationError("%s is not a path variable of the profile", name)
+			}
+		}
+		names = opts.Vars
+	}
+
+	for _, name := range names {
+		windowsPath, err := wsl.WindowsPath(paths[name])
+		if err != nil {
+			return err
+		}
+		switch format {
+		case "cmd":
+			fmt.Printf("set \"%s=%s\"\n", name, windowsPath)
+		case "setx":
+			fmt.Printf("setx %s \"%s\"\n", name, windowsPath)
+		default:
+			fmt.Printf("$env:%s = '%s'\n", name, strings.ReplaceAll(windowsPath, "'", "''"))
+		}
+	}
+	return nil
+}
+
+// workspacePath returns the path a feature variable points to when its
+// value is a single path inside the workspace ($WORKSPACE_HOME/...)
+func workspacePath(profileDir, value string) (string, bool) {
+	rest, ok := strings.CutPrefix(value, "$WORKSPACE_HOME")
+	if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) || strings.ContainsAny(rest, ": $") {
+		return "", false
+	}
+	return filepath.Join(profileDir, rest), true
+}
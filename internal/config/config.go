@@ -4,34 +4,153 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/mindmorass/shell-profile-manager/internal/atomicfile"
 )
 
 const (
-	configFileName = ".profile-manager"
+	// configFileName is the name of the config file in SpmDir
+	configFileName = "config"
+	// legacyConfigFileName is the config file in the home directory used
+	// before the XDG base directories; Migrate moves it
+	legacyConfigFileName = ".profile-manager"
 )
 
 // Config holds the profile manager configuration
 type Config struct {
 	ProfilesDir string `json:"profiles_dir"`
+	// ChangedExitCode is the exit code used for applied changes when
+	// --detailed-exitcode is passed (0 keeps the built-in default)
+	ChangedExitCode int `json:"changed_exit_code"`
+	// AutoAllowDirenv runs 'direnv allow' after update rewrites a profile
+	AutoAllowDirenv bool `json:"auto_allow_direnv"`
+	// AutoCommit commits what create and update change in profiles that
+	// are git repositories
+	AutoCommit bool `json:"auto_commit"`
+	// BackupAge are the age recipients the backups update makes are
+	// encrypted for, unless a profile names its own
+	BackupAge []string `json:"backup_age"`
+	// BackupIdentity is the age identity file restore decrypts backups
+	// with (default: the sops age key file)
+	BackupIdentity string `json:"backup_identity"`
+	// BackupKeep is how many of the newest backups of a profile 'profile
+	// gc' always keeps (0 keeps the built-in default)
+	BackupKeep int `json:"backup_keep"`
+	// BackupMaxAge is the age in days after which 'profile gc' removes the
+	// other backups (0 keeps the built-in default)
+	BackupMaxAge int `json:"backup_max_age"`
+	// GuardRules are gitleaks configuration files whose rules the guard
+	// hooks scan with
+	GuardRules []string `json:"guard_rules"`
+	// Policy is the team policy profiles are checked against: a git URL of
+	// a repository holding policy.yaml, or a local file or directory
+	Policy string `json:"policy"`
+	// PolicyEnforce makes update fail while a profile violates the policy
+	PolicyEnforce bool `json:"policy_enforce"`
+	// Jobs is how many profiles update and sync process at once with --all
+	// or --tag (0 keeps the built-in default)
+	Jobs int `json:"jobs"`
+	// Color is when output is colored: auto (default), always, or never
+	Color string `json:"color"`
+	// Notify is the desktop notifier status and watch alert with: auto,
+	// terminal-notifier, osascript, or notify-send ("" turns status
+	// notifications off)
+	Notify string `json:"notify"`
+	// DefaultProfile is the profile select and run use when no name is
+	// given, before the last used one
+	DefaultProfile string `json:"default_profile"`
+	// ExpiryWarning is how long before credentials expire they are
+	// reported (0 keeps the built-in default)
+	ExpiryWarning time.Duration `json:"expiry_warning"`
+	// Theme overrides the success, warning, error, and accent colors; it
+	// is read from the [theme] section
+	Theme map[string]string `json:"theme"`
+	// Roots are profile directories besides ProfilesDir, by name; they are
+	// read from the [roots] section
+	Roots map[string]string `json:"roots"`
+	// Aliases are short names of profiles, e.g.
+	// acme=client-acme-aws-prod-2024; they are read from the [aliases]
+	// section
+	Aliases map[string]string `json:"aliases"`
+	// Launchers are the commands 'profile open' runs, by name (editor,
+	// finder, terminal, or any other); they are read from the [open]
+	// section
+	Launchers map[string]string `json:"launchers"`
+}
+
+// SpmDir returns the directory for the config file and user extensions
+// such as feature definitions and hooks ($XDG_CONFIG_HOME/spm, default
+// ~/.config/spm)
+func SpmDir() (string, error) {
+	return xdgDir("XDG_CONFIG_HOME", ".config")
+}
+
+// StateDir returns the directory for state such as the debug log and the
+// template repository clones ($XDG_STATE_HOME/spm, default
+// ~/.local/state/spm)
+func StateDir() (string, error) {
+	return xdgDir("XDG_STATE_HOME", ".local", "state")
+}
+
+// CacheDir returns the directory for data that can be recreated, such as
+// plugin handshakes ($XDG_CACHE_HOME/spm, default ~/.cache/spm)
+func CacheDir() (string, error) {
+	return xdgDir("XDG_CACHE_HOME", ".cache")
+}
+
+// xdgDir returns the spm directory in the base directory named by env, or
+// in the default under the home directory. As the XDG Base Directory
+// specification requires, a relative path in env is ignored.
+func xdgDir(env string, defaultDir ...string) (string, error) {
+	base := os.Getenv(env)
+	if !filepath.IsAbs(base) {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		base = filepath.Join(append([]string{homeDir}, defaultDir...)...)
+	}
+	return filepath.Join(base, "spm"), nil
 }
 
 // GetConfigPath returns the path to the config file
+// ($XDG_CONFIG_HOME/spm/config)
 func GetConfigPath() (string, error) {
+	spmDir, err := SpmDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(spmDir, configFileName), nil
+}
+
+// legacyConfigPath returns the path to ~/.profile-manager
+func legacyConfigPath() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("failed to get home directory: %w", err)
 	}
-	return filepath.Join(homeDir, configFileName), nil
+	return filepath.Join(homeDir, legacyConfigFileName), nil
 }
 
-// LoadConfig loads the configuration from ~/.profile-manager
+// LoadConfig loads the configuration from $XDG_CONFIG_HOME/spm/config, or
+// from ~/.profile-manager when it has not been migrated yet.
 // Returns default config if file doesn't exist
 func LoadConfig() (*Config, error) {
 	configPath, err := GetConfigPath()
 	if err != nil {
 		return nil, err
 	}
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		if legacyPath, err := legacyConfigPath(); err == nil {
+			if _, err := os.Stat(legacyPath); err == nil {
+				configPath = legacyPath
+			}
+		}
+	}
 
 	// If config file doesn't exist, return default
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
@@ -44,14 +163,20 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	// Parse simple key=value format
+	// Parse simple key=value format, with [theme], [roots], [aliases], and
+	// [open] sections
 	config := &Config{}
+	section := ""
 	lines := strings.Split(string(content), "\n")
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
 
 		parts := strings.SplitN(line, "=", 2)
 		if len(parts) != 2 {
@@ -61,10 +186,105 @@ func LoadConfig() (*Config, error) {
 		key := strings.TrimSpace(parts[0])
 		value := strings.TrimSpace(parts[1])
 
+		if section == "theme" {
+			if config.Theme == nil {
+				config.Theme = map[string]string{}
+			}
+			config.Theme[key] = value
+			continue
+		}
+		if section == "roots" {
+			if config.Roots == nil {
+				config.Roots = map[string]string{}
+			}
+			config.Roots[key] = ExpandPath(value)
+			continue
+		}
+		if section == "aliases" {
+			if config.Aliases == nil {
+				config.Aliases = map[string]string{}
+			}
+			config.Aliases[key] = value
+			continue
+		}
+		if section == "open" {
+			if config.Launchers == nil {
+				config.Launchers = map[string]string{}
+			}
+			config.Launchers[key] = value
+			continue
+		}
+		if section != "" {
+			continue
+		}
+
 		switch key {
 		case "profiles_dir":
 			// Expand ~ in path
-			config.ProfilesDir = expandPath(value)
+			config.ProfilesDir = ExpandPath(value)
+		case "changed_exit_code":
+			code, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid changed_exit_code %q: %w", value, err)
+			}
+			config.ChangedExitCode = code
+		case "auto_allow_direnv":
+			config.AutoAllowDirenv = value == "true" || value == "yes" || value == "1"
+		case "auto_commit":
+			config.AutoCommit = value == "true" || value == "yes" || value == "1"
+		case "color":
+			config.Color = value
+		case "notify":
+			config.Notify = value
+		case "default_profile":
+			config.DefaultProfile = value
+		case "expiry_warning":
+			warning, err := time.ParseDuration(value)
+			if err != nil || warning <= 0 {
+				return nil, fmt.Errorf("invalid expiry_warning %q: must be a duration such as 2h", value)
+			}
+			config.ExpiryWarning = warning
+		case "backup_age":
+			config.BackupAge = nil
+			for _, recipient := range strings.Split(value, ",") {
+				if recipient = strings.TrimSpace(recipient); recipient != "" {
+					config.BackupAge = append(config.BackupAge, recipient)
+				}
+			}
+		case "backup_identity":
+			config.BackupIdentity = ExpandPath(value)
+		case "backup_keep":
+			keep, err := strconv.Atoi(value)
+			if err != nil || keep < 1 {
+				return nil, fmt.Errorf("invalid backup_keep %q: must be a positive number", value)
+			}
+			config.BackupKeep = keep
+		case "backup_max_age":
+			days, err := strconv.Atoi(value)
+			if err != nil || days < 1 {
+				return nil, fmt.Errorf("invalid backup_max_age %q: must be a positive number of days", value)
+			}
+			config.BackupMaxAge = days
+		case "policy":
+			config.Policy = value
+			if !strings.Contains(value, "://") && !strings.Contains(value, "@") {
+				config.Policy = ExpandPath(value)
+			}
+		case "policy_enforce":
+			config.PolicyEnforce = value == "true" || value == "yes" || value == "1"
+		case "jobs":
+			jobs, err := strconv.Atoi(value)
+			if err != nil || jobs < 1 {
+				return nil, fmt.Errorf("invalid jobs %q: must be a positive number", value)
+			}
+			config.Jobs = jobs
+		case "guard_rules":
+			config.GuardRules = nil
+			for _, path := range strings.Split(value, ",") {
+				if path = strings.TrimSpace(path); path != "" {
+					config.GuardRules = append(config.GuardRules, ExpandPath(path))
+				}
+			}
 		}
 	}
 
@@ -80,12 +300,15 @@ func LoadConfig() (*Config, error) {
 	return config, nil
 }
 
-// SaveConfig saves the configuration to ~/.profile-manager
+// SaveConfig saves the configuration to $XDG_CONFIG_HOME/spm/config
 func SaveConfig(config *Config) error {
 	configPath, err := GetConfigPath()
 	if err != nil {
 		return err
 	}
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
 
 	// Expand paths to use ~ notation if in home directory
 	homeDir, err := os.UserHomeDir()
@@ -93,10 +316,7 @@ func SaveConfig(config *Config) error {
 		return fmt.Errorf("failed to get home directory: %w", err)
 	}
 
-	profilesDir := config.ProfilesDir
-	if strings.HasPrefix(profilesDir, homeDir) {
-		profilesDir = "~" + profilesDir[len(homeDir):]
-	}
+	profilesDir := collapsePath(config.ProfilesDir, homeDir)
 
 	// Write config file
 	content := fmt.Sprintf(`# Profile Manager Configuration
@@ -106,7 +326,98 @@ func SaveConfig(config *Config) error {
 profiles_dir=%s
 `, profilesDir)
 
-	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+	if config.ChangedExitCode != 0 {
+		content += fmt.Sprintf("changed_exit_code=%d\n", config.ChangedExitCode)
+	}
+	if config.AutoAllowDirenv {
+		content += "auto_allow_direnv=true\n"
+	}
+	if config.AutoCommit {
+		content += "auto_commit=true\n"
+	}
+	if config.Color != "" {
+		content += fmt.Sprintf("color=%s\n", config.Color)
+	}
+	if config.Notify != "" {
+		content += fmt.Sprintf("notify=%s\n", config.Notify)
+	}
+	if config.DefaultProfile != "" {
+		content += fmt.Sprintf("default_profile=%s\n", config.DefaultProfile)
+	}
+	if config.ExpiryWarning != 0 {
+		content += fmt.Sprintf("expiry_warning=%s\n", config.ExpiryWarning)
+	}
+	if len(config.BackupAge) > 0 {
+		content += fmt.Sprintf("backup_age=%s\n", strings.Join(config.BackupAge, ","))
+	}
+	if config.BackupIdentity != "" {
+		content += fmt.Sprintf("backup_identity=%s\n", collapsePath(config.BackupIdentity, homeDir))
+	}
+	if config.BackupKeep != 0 {
+		content += fmt.Sprintf("backup_keep=%d\n", config.BackupKeep)
+	}
+	if config.BackupMaxAge != 0 {
+		content += fmt.Sprintf("backup_max_age=%d\n", config.BackupMaxAge)
+	}
+	if config.Policy != "" {
+		content += fmt.Sprintf("policy=%s\n", collapsePath(config.Policy, homeDir))
+	}
+	if config.PolicyEnforce {
+		content += "policy_enforce=true\n"
+	}
+	if config.Jobs != 0 {
+		content += fmt.Sprintf("jobs=%d\n", config.Jobs)
+	}
+	if len(config.GuardRules) > 0 {
+		paths := make([]string, len(config.GuardRules))
+		for i, path := range config.GuardRules {
+			paths[i] = collapsePath(path, homeDir)
+		}
+		content += fmt.Sprintf("guard_rules=%s\n", strings.Join(paths, ","))
+	}
+	if len(config.Theme) > 0 {
+		content += "\n[theme]\n"
+		for _, role := range []string{"success", "warning", "error", "accent"} {
+			if value, ok := config.Theme[role]; ok {
+				content += fmt.Sprintf("%s=%s\n", role, value)
+			}
+		}
+	}
+	if len(config.Roots) > 0 {
+		content += "\n[roots]\n"
+		names := make([]string, 0, len(config.Roots))
+		for name := range config.Roots {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			content += fmt.Sprintf("%s=%s\n", name, collapsePath(config.Roots[name], homeDir))
+		}
+	}
+	if len(config.Aliases) > 0 {
+		content += "\n[aliases]\n"
+		names := make([]string, 0, len(config.Aliases))
+		for name := range config.Aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			content += fmt.Sprintf("%s=%s\n", name, config.Aliases[name])
+		}
+	}
+	if len(config.Launchers) > 0 {
+		content += "\n[open]\n"
+		names := make([]string, 0, len(config.Launchers))
+		for name := range config.Launchers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			content += fmt.Sprintf("%s=%s\n", name, config.Launchers[name])
+		}
+	}
+
+	if err := atomicfile.WriteFile(configPath, []byte(content), 0644); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
@@ -125,8 +436,8 @@ func GetDefaultConfig() (*Config, error) {
 	}, nil
 }
 
-// expandPath expands ~ and environment variables in a path
-func expandPath(path string) string {
+// ExpandPath expands ~ and environment variables in a path
+func ExpandPath(path string) string {
 	// Expand ~
 	if strings.HasPrefix(path, "~") {
 		homeDir, err := os.UserHomeDir()
@@ -141,3 +452,11 @@ func expandPath(path string) string {
 	// Clean the path
 	return filepath.Clean(path)
 }
+
+// collapsePath writes a path under the home directory with ~ notation
+func collapsePath(path, homeDir string) string {
+	if strings.HasPrefix(path, homeDir) {
+		return "~" + path[len(homeDir):]
+	}
+	return path
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/atomicfile"
+)
+
+// LinksFileName is the file in SpmDir mapping project directories outside
+// the profile roots to profiles, one dir=profile per line
+const LinksFileName = "links"
+
+// LinksPath returns the path of the links file
+func LinksPath() (string, error) {
+	dir, err := SpmDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, LinksFileName), nil
+}
+
+// LoadLinks returns the profile each linked directory maps to, by absolute
+// path. A missing file has no links.
+func LoadLinks() (map[string]string, error) {
+	path, err := LinksPath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read links file: %w", err)
+	}
+	defer file.Close()
+
+	links := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Profile names cannot contain '=', directories can
+		i := strings.LastIndex(line, "=")
+		if i < 0 {
+			continue
+		}
+		links[ExpandPath(strings.TrimSpace(line[:i]))] = strings.TrimSpace(line[i+1:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read links file: %w", err)
+	}
+	return links, nil
+}
+
+// SaveLinks writes the links file; without links it is removed, so shell
+// hooks can tell there is nothing to look up
+func SaveLinks(links map[string]string) error {
+	path, err := LinksPath()
+	if err != nil {
+		return err
+	}
+	if len(links) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove links file: %w", err)
+		}
+		return nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	dirs := make([]string, 0, len(links))
+	for dir := range links {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	content := "# Directories linked to profiles with 'profile link'\n"
+	for _, dir := range dirs {
+		content += fmt.Sprintf("%s=%s\n", collapsePath(dir, homeDir), links[dir])
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := atomicfile.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write links file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/mindmorass/shell-profile-manager/internal/atomicfile"
+)
+
+// legacyNoticeFileName records, in the state directory, that the user was
+// told the legacy config file is ignored
+const legacyNoticeFileName = "legacy-config-notice"
+
+// Migrate moves files from the locations used before the XDG base
+// directories: ~/.profile-manager to $XDG_CONFIG_HOME/spm/config, and the
+// plugin handshake cache from the state directory to the cache directory.
+// It returns a notice for each change the user should know about. That the
+// legacy config file is ignored while both exist is told once.
+func Migrate() ([]string, error) {
+	var notices []string
+
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	legacyPath, err := legacyConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(legacyPath); err != nil {
+		forgetLegacyNotice()
+	} else if _, err := os.Stat(configPath); err == nil {
+		if given, err := legacyNoticeGiven(); !given {
+			notice := fmt.Sprintf("%s is ignored, %s is used instead (remove one of them)", legacyPath, configPath)
+			if err != nil {
+				notice += fmt.Sprintf("; this is repeated on every run, since recording it failed: %v", err)
+			}
+			notices = append(notices, notice)
+		}
+	} else if err := moveFile(legacyPath, configPath); err != nil {
+		return notices, fmt.Errorf("failed to move %s to %s: %w", legacyPath, configPath, err)
+	} else {
+		notices = append(notices, fmt.Sprintf("Moved configuration from %s to %s", legacyPath, configPath))
+	}
+
+	// The plugin cache is recreated when missing, so failing to move it
+	// is not an error
+	stateDir, err := StateDir()
+	if err != nil {
+		return notices, nil
+	}
+	cacheDir, err := CacheDir()
+	if err != nil {
+		return notices, nil
+	}
+	oldCache := filepath.Join(stateDir, "plugins.json")
+	if _, err := os.Stat(oldCache); err == nil {
+		newCache := filepath.Join(cacheDir, "plugins.json")
+		if _, err := os.Stat(newCache); err == nil {
+			os.Remove(oldCache)
+		} else {
+			moveFile(oldCache, newCache)
+		}
+	}
+	return notices, nil
+}
+
+// legacyNoticeGiven reports whether the user was told the legacy config
+// file is ignored, and records that they are being told now. It returns
+// the error of recording it, in which case the notice is given every time.
+func legacyNoticeGiven() (bool, error) {
+	stateDir, err := StateDir()
+	if err != nil {
+		return false, err
+	}
+	path := filepath.Join(stateDir, legacyNoticeFileName)
+	if _, err := os.Stat(path); err == nil {
+		return true, nil
+	}
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return false, err
+	}
+	return false, atomicfile.WriteFile(path, nil, 0644)
+}
+
+// forgetLegacyNotice lets the notice be given again should both config
+// files exist again
+func forgetLegacyNotice() {
+	stateDir, err := StateDir()
+	if err != nil {
+		return
+	}
+	path := filepath.Join(stateDir, legacyNoticeFileName)
+	if _, err := os.Lstat(path); err == nil {
+		os.Remove(path)
+	}
+}
+
+// moveFile moves a file, copying it when it is on another file system
+func moveFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,270 @@
+// Package direnv inspects and changes the direnv allow state of profiles.
+package direnv
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// State is the direnv allow state of a profile's .envrc
+type State int
+
+const (
+	// Unknown means the state could not be determined (e.g. no .envrc)
+	Unknown State = iota
+	// Allowed means direnv loads the .envrc
+	Allowed
+	// NotAllowed means the .envrc has never been allowed
+	NotAllowed
+	// Stale means the .envrc was allowed but has changed since
+	Stale
+	// Blocked means the .envrc was explicitly denied with 'direnv deny'
+	Blocked
+)
+
+func (s State) String() string {
+	switch s {
+	case Allowed:
+		return "allowed"
+	case NotAllowed:
+		return "not allowed"
+	case Stale:
+		return "stale (changed since allowed)"
+	case Blocked:
+		return "blocked"
+	default:
+		return "unknown"
+	}
+}
+
+// ProfileState is the allow state of a profile for structured output
+type ProfileState struct {
+	Name  string `json:"name" yaml:"name"`
+	State string `json:"state" yaml:"state"`
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Installed reports whether direnv is on the PATH
+func Installed() bool {
+	_, err := exec.LookPath("direnv")
+	return err == nil
+}
+
+// Status returns the allow state of the .envrc in dir
+func Status(dir string) (State, error) {
+	cmd := exec.Command("direnv", "status")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return Unknown, fmt.Errorf("direnv status failed: %w", err)
+	}
+
+	rcPath, allowed := parseStatus(output)
+	if rcPath == "" {
+		return Unknown, nil
+	}
+
+	// direnv 2.33+ reports 0 (allowed), 1 (not allowed), or 2 (denied);
+	// older versions print true or false
+	switch allowed {
+	case "true", "0":
+		return Allowed, nil
+	case "2":
+		return Blocked, nil
+	}
+
+	// An .envrc that is neither allowed nor denied by its current content
+	// may still appear in the allow or deny lists under an older hash
+	dataDir, err := dataDir()
+	if err != nil {
+		return NotAllowed, nil
+	}
+	if listed(filepath.Join(dataDir, "deny"), rcPath) {
+		return Blocked, nil
+	}
+	if listed(filepath.Join(dataDir, "allow"), rcPath) {
+		return Stale, nil
+	}
+	return NotAllowed, nil
+}
+
+// Allow runs 'direnv allow' for the .envrc in dir
+func Allow(dir string) error {
+	cmd := exec.Command("direnv", "allow", dir)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("direnv allow failed: %w: %s", err, msg)
+		}
+		return fmt.Errorf("direnv allow failed: %w", err)
+	}
+	return nil
+}
+
+// Export evaluates the .envrc in dir and returns the variables it changes
+// in the shell environment, as 'direnv export json' reports them: a nil
+// value means the variable is unset. It is evaluated in BaseEnv, so the
+// profile is evaluated even when it is already loaded, and direnv's
+// bookkeeping variables are left out.
+func Export(dir string) (map[string]*string, error) {
+	cmd := exec.Command("direnv", "export", "json")
+	cmd.Dir = dir
+	base := BaseEnv()
+	for _, name := range sortedKeys(base) {
+		cmd.Env = append(cmd.Env, name+"="+base[name])
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if msg := lastLine(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("direnv export failed: %s", msg)
+		}
+		return nil, fmt.Errorf("direnv export failed: %w", err)
+	}
+
+	vars := map[string]*string{}
+	if len(bytes.TrimSpace(output)) > 0 {
+		if err := json.Unmarshal(output, &vars); err != nil {
+			return nil, fmt.Errorf("unexpected direnv export output: %w", err)
+		}
+	}
+	for name := range vars {
+		if strings.HasPrefix(name, "DIRENV_") {
+			delete(vars, name)
+		}
+	}
+	return vars, nil
+}
+
+// BaseEnv returns the environment of the shell without what direnv loaded
+// into it: the changes recorded in DIRENV_DIFF are reverted and direnv's
+// own variables dropped. An unreadable diff leaves the environment as it
+// is.
+func BaseEnv() map[string]string {
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		if name, value, ok := strings.Cut(kv, "="); ok && !strings.HasPrefix(name, "DIRENV_") {
+			env[name] = value
+		}
+	}
+	diff, err := decodeDiff(os.Getenv("DIRENV_DIFF"))
+	if err != nil {
+		return env
+	}
+	for name := range diff.Next {
+		delete(env, name)
+	}
+	for name, value := range diff.Previous {
+		env[name] = value
+	}
+	return env
+}
+
+// envDiff is the DIRENV_DIFF direnv keeps to revert what it loaded: the
+// values the variables it changed had before and have now
+type envDiff struct {
+	Previous map[string]string `json:"p"`
+	Next     map[string]string `json:"n"`
+}
+
+// decodeDiff decodes DIRENV_DIFF: JSON compressed with zlib, in URL-safe
+// base64
+func decodeDiff(value string) (envDiff, error) {
+	var diff envDiff
+	if value == "" {
+		return diff, fmt.Errorf("DIRENV_DIFF is not set")
+	}
+	compressed, err := base64.URLEncoding.DecodeString(value)
+	if err != nil {
+		if compressed, err = base64.RawURLEncoding.DecodeString(value); err != nil {
+			return diff, err
+		}
+	}
+	r, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return diff, err
+	}
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return diff, err
+	}
+	return diff, json.Unmarshal(content, &diff)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// lastLine returns the last non-empty line of direnv's log output
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// parseStatus extracts the found RC path and its allowed value from the
+// output of 'direnv status'
+func parseStatus(output []byte) (string, string) {
+	var rcPath, allowed string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if value, ok := strings.CutPrefix(line, "Found RC path "); ok {
+			rcPath = value
+		}
+		if value, ok := strings.CutPrefix(line, "Found RC allowed "); ok {
+			allowed = value
+		}
+	}
+	return rcPath, allowed
+}
+
+// dataDir returns the directory holding direnv's allow and deny lists
+func dataDir() (string, error) {
+	if xdg := os.Getenv("XDG_DATA_HOME"); xdg != "" {
+		return filepath.Join(xdg, "direnv"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "direnv"), nil
+}
+
+// listed reports whether any entry in an allow or deny list directory
+// refers to rcPath. Each entry is named by a hash and contains the path.
+func listed(listDir, rcPath string) bool {
+	entries, err := os.ReadDir(listDir)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(listDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(string(content)) == rcPath {
+			return true
+		}
+	}
+	return false
+}
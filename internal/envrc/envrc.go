@@ -0,0 +1,272 @@
+// Package envrc parses .envrc files into documents that know which lines
+// export which variables and where the managed blocks are, so a profile can
+// be edited line by line: everything that is not edited, including custom
+// functions, comments, and blank lines, renders exactly as it was read.
+package envrc
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// assignment matches a line assigning a variable, exported or not
+var assignment = regexp.MustCompile(`^\s*(?:export\s+)?([A-Za-z_][A-Za-z0-9_]*)=`)
+
+// Document is a parsed .envrc
+type Document struct {
+	lines []string
+	// saved is the content the document was read from or last saved as
+	saved string
+	// exports maps each assigned variable to its first line; it is built
+	// on first use and dropped when lines are inserted or removed
+	exports map[string]int
+}
+
+// Parse splits .envrc content into lines
+func Parse(content string) *Document {
+	return &Document{lines: strings.Split(content, "\n"), saved: content}
+}
+
+// String renders the document
+func (d *Document) String() string {
+	return strings.Join(d.lines, "\n")
+}
+
+// Changed reports whether the document renders differently from the
+// content it was read from or last saved as
+func (d *Document) Changed() bool {
+	return d.String() != d.saved
+}
+
+// MarkSaved records that the document was written as it renders now
+func (d *Document) MarkSaved() {
+	d.saved = d.String()
+}
+
+// Line returns line i
+func (d *Document) Line(i int) string {
+	return d.lines[i]
+}
+
+// Export returns the index of the first line assigning name (commented-out
+// assignments do not count), or -1
+func (d *Document) Export(name string) int {
+	if d.exports == nil {
+		d.exports = map[string]int{}
+		for i, line := range d.lines {
+			if m := assignment.FindStringSubmatch(line); m != nil {
+				if _, ok := d.exports[m[1]]; !ok {
+					d.exports[m[1]] = i
+				}
+			}
+		}
+	}
+	if i, ok := d.exports[name]; ok {
+		return i
+	}
+	return -1
+}
+
+// Exports returns the variables the document assigns, in the order of
+// their first assignment
+func (d *Document) Exports() []string {
+	d.Export("")
+	names := make([]string, 0, len(d.exports))
+	for name := range d.exports {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int { return d.exports[a] - d.exports[b] })
+	return names
+}
+
+// SetLine replaces line i
+func (d *Document) SetLine(i int, line string) {
+	if d.lines[i] == line {
+		return
+	}
+	d.lines[i] = line
+	d.exports = nil
+}
+
+// SetExport rewrites the assignment on line i as line, keeping its
+// indentation
+func (d *Document) SetExport(i int, line string) {
+	current := d.lines[i]
+	indent := current[:len(current)-len(strings.TrimLeft(current, " \t"))]
+	d.SetLine(i, indent+line)
+}
+
+// RenameExport renames the variable assigned on line i from oldName to
+// newName, keeping the rest of the line
+func (d *Document) RenameExport(i int, oldName, newName string) {
+	line := d.lines[i]
+	m := assignment.FindStringSubmatchIndex(line)
+	if m == nil || line[m[2]:m[3]] != oldName {
+		return
+	}
+	d.SetLine(i, line[:m[2]]+newName+line[m[3]:])
+}
+
+// CommentOut turns line i into a comment
+func (d *Document) CommentOut(i int) {
+	d.SetLine(i, "# "+d.lines[i])
+}
+
+// Insert inserts lines before line i (len of the document appends them)
+func (d *Document) Insert(i int, lines ...string) {
+	if len(lines) == 0 {
+		return
+	}
+	rest := append([]string{}, d.lines[i:]...)
+	d.lines = append(append(d.lines[:i], lines...), rest...)
+	d.exports = nil
+}
+
+// Remove removes the lines from start up to end (exclusive)
+func (d *Document) Remove(start, end int) {
+	if start >= end {
+		return
+	}
+	d.lines = append(d.lines[:start], d.lines[end:]...)
+	d.exports = nil
+}
+
+// RemoveLines removes every line for which drop is true and returns how
+// many were removed
+func (d *Document) RemoveLines(drop func(line string) bool) int {
+	kept := d.lines[:0:0]
+	for _, line := range d.lines {
+		if !drop(line) {
+			kept = append(kept, line)
+		}
+	}
+	removed := len(d.lines) - len(kept)
+	if removed > 0 {
+		d.lines = kept
+		d.exports = nil
+	}
+	return removed
+}
+
+// Len returns the number of lines
+func (d *Document) Len() int {
+	return len(d.lines)
+}
+
+// Index returns the first line at or after from that starts with text,
+// ignoring indentation, or -1
+func (d *Document) Index(text string, from int) int {
+	for i := from; i < len(d.lines); i++ {
+		if strings.HasPrefix(strings.TrimLeft(d.lines[i], " \t"), text) {
+			return i
+		}
+	}
+	return -1
+}
+
+// LastIndex returns the last line that starts with text, ignoring
+// indentation, or -1
+func (d *Document) LastIndex(text string) int {
+	for i := len(d.lines) - 1; i >= 0; i-- {
+		if strings.HasPrefix(strings.TrimLeft(d.lines[i], " \t"), text) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Contains reports whether any line contains text
+func (d *Document) Contains(text string) bool {
+	for _, line := range d.lines {
+		if strings.Contains(line, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// IndexSection returns the line starting the consecutive lines of comment
+// before line limit, or -1
+func (d *Document) IndexSection(comment []string, limit int) int {
+	if len(comment) == 0 {
+		return -1
+	}
+	for i := 0; i+len(comment) <= limit; i++ {
+		match := true
+		for j, line := range comment {
+			if d.lines[i+j] != line {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
+
+// BlockMarkers returns the comments delimiting a managed block: a section
+// profile regenerates as a whole so the rest of the file can be edited
+// freely
+func BlockMarkers(name string) (string, string) {
+	return fmt.Sprintf("# >>> profile:%s (managed, do not edit) >>>", name),
+		fmt.Sprintf("# <<< profile:%s <<<", name)
+}
+
+// Block returns the lines of the named managed block, markers included,
+// as start and end (exclusive); ok is false when there is no such block
+func (d *Document) Block(name string) (start, end int, ok bool) {
+	begin, stop := BlockMarkers(name)
+	start = d.Index(begin, 0)
+	if start == -1 {
+		return 0, 0, false
+	}
+	end = d.Index(stop, start+1)
+	if end == -1 {
+		return 0, 0, false
+	}
+	return start, end + 1, true
+}
+
+// SetBlock replaces the named managed block with body, adding it before
+// the line starting with before (or at the end of the file) if it does not
+// exist yet. An empty body removes the block along with the blank line
+// that separated it.
+func (d *Document) SetBlock(name, body, before string) {
+	begin, stop := BlockMarkers(name)
+	var block []string
+	if body != "" {
+		block = append(append([]string{begin}, strings.Split(strings.TrimRight(body, "\n"), "\n")...), stop)
+	}
+
+	if start, end, ok := d.Block(name); ok {
+		// The file ends at the end marker without a newline
+		if end == len(d.lines) && block != nil {
+			block = append(block, "")
+		}
+		if block == nil && end < len(d.lines)-1 && d.lines[end] == "" {
+			end++
+		}
+		if !slices.Equal(d.lines[start:end], block) {
+			d.Remove(start, end)
+			d.Insert(start, block...)
+		}
+		return
+	}
+	if block == nil {
+		return
+	}
+
+	if at := d.Index(before, 0); before != "" && at != -1 {
+		d.Insert(at, append(block, "")...)
+		return
+	}
+	// Separate the block from the end of the file with a blank line
+	if d.lines[len(d.lines)-1] != "" {
+		d.lines = append(d.lines, "")
+	}
+	d.Insert(len(d.lines), append(block, "")...)
+}
@@ -0,0 +1,193 @@
+package envrc
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseRendersUnchanged(t *testing.T) {
+	tests := []string{
+		"",
+		"export A=1\n",
+		"export A=1",
+		"# comment\n\nexport A=1\n\nmyfunc() {\n  echo hi\n}\n",
+	}
+
+	for _, content := range tests {
+		doc := Parse(content)
+		if got := doc.String(); got != content {
+			t.Errorf("Parse(%q).String() = %q", content, got)
+		}
+		if doc.Changed() {
+			t.Errorf("Parse(%q).Changed() = true before any edit", content)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	doc := Parse("export A=1\n# export B=2\n  C=3\nexport A=4\nexport D\n")
+	tests := []struct {
+		name string
+		want int
+	}{
+		{"A", 0},
+		{"B", -1},
+		{"C", 2},
+		{"D", -1},
+		{"E", -1},
+	}
+
+	for _, tt := range tests {
+		if got := doc.Export(tt.name); got != tt.want {
+			t.Errorf("Export(%q) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+	if got, want := doc.Exports(), []string{"A", "C"}; !slices.Equal(got, want) {
+		t.Errorf("Exports() = %q, want %q", got, want)
+	}
+}
+
+func TestEdits(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		edit    func(d *Document)
+		want    string
+	}{
+		{
+			name:    "set export keeps indentation",
+			content: "if true; then\n  export A=1\nfi\n",
+			edit:    func(d *Document) { d.SetExport(d.Export("A"), "export A=2") },
+			want:    "if true; then\n  export A=2\nfi\n",
+		},
+		{
+			name:    "rename export keeps the value",
+			content: "export OLD=\"x y\" # note\n",
+			edit:    func(d *Document) { d.RenameExport(d.Export("OLD"), "OLD", "NEW") },
+			want:    "export NEW=\"x y\" # note\n",
+		},
+		{
+			name:    "rename export of another variable does nothing",
+			content: "export A=1\n",
+			edit:    func(d *Document) { d.RenameExport(0, "B", "C") },
+			want:    "export A=1\n",
+		},
+		{
+			name:    "comment out",
+			content: "export A=1\n",
+			edit:    func(d *Document) { d.CommentOut(0) },
+			want:    "# export A=1\n",
+		},
+		{
+			name:    "insert and remove",
+			content: "a\nd\n",
+			edit: func(d *Document) {
+				d.Insert(1, "b", "c")
+				d.Remove(0, 1)
+			},
+			want: "b\nc\nd\n",
+		},
+		{
+			name:    "remove lines",
+			content: "keep\ndrop 1\nkeep\ndrop 2\n",
+			edit: func(d *Document) {
+				d.RemoveLines(func(line string) bool { return strings.HasPrefix(line, "drop") })
+			},
+			want: "keep\nkeep\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := Parse(tt.content)
+			tt.edit(doc)
+			if got := doc.String(); got != tt.want {
+				t.Errorf("got\n%q\nwant\n%q", got, tt.want)
+			}
+			if doc.Changed() != (tt.content != tt.want) {
+				t.Errorf("Changed() = %v", doc.Changed())
+			}
+		})
+	}
+}
+
+func TestExportAfterEdit(t *testing.T) {
+	doc := Parse("export A=1\nexport B=2\n")
+	doc.Insert(0, "export C=3")
+	if got := doc.Export("B"); got != 2 {
+		t.Errorf("Export(B) after insert = %d, want 2", got)
+	}
+	doc.Remove(0, 2)
+	if got := doc.Export("A"); got != -1 {
+		t.Errorf("Export(A) after remove = %d, want -1", got)
+	}
+}
+
+func TestSetBlock(t *testing.T) {
+	begin, end := BlockMarkers("nix")
+	tests := []struct {
+		name    string
+		content string
+		body    string
+		before  string
+		want    string
+	}{
+		{
+			name:    "added at the end after a blank line",
+			content: "export A=1\n",
+			body:    "use flake",
+			want:    "export A=1\n\n" + begin + "\nuse flake\n" + end + "\n",
+		},
+		{
+			name:    "added before a line",
+			content: "export A=1\n# Custom\n",
+			body:    "use flake",
+			before:  "# Custom",
+			want:    "export A=1\n" + begin + "\nuse flake\n" + end + "\n\n# Custom\n",
+		},
+		{
+			name:    "replaced in place",
+			content: "export A=1\n" + begin + "\nuse flake\n" + end + "\n\n# Custom\n",
+			body:    "use flake .#dev\n",
+			want:    "export A=1\n" + begin + "\nuse flake .#dev\n" + end + "\n\n# Custom\n",
+		},
+		{
+			name:    "replaced at the end of a file without a newline",
+			content: "export A=1\n" + begin + "\nuse flake\n" + end,
+			body:    "use flake .#dev",
+			want:    "export A=1\n" + begin + "\nuse flake .#dev\n" + end + "\n",
+		},
+		{
+			name:    "removed with its blank line",
+			content: "export A=1\n" + begin + "\nuse flake\n" + end + "\n\n# Custom\n",
+			body:    "",
+			want:    "export A=1\n# Custom\n",
+		},
+		{
+			name:    "removing a missing block does nothing",
+			content: "export A=1\n",
+			body:    "",
+			want:    "export A=1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := Parse(tt.content)
+			doc.SetBlock("nix", tt.body, tt.before)
+			if got := doc.String(); got != tt.want {
+				t.Errorf("got\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetBlockUnchanged(t *testing.T) {
+	begin, end := BlockMarkers("nix")
+	doc := Parse("export A=1\n" + begin + "\nuse flake\n" + end + "\n")
+	doc.SetBlock("nix", "use flake", "")
+	if doc.Changed() {
+		t.Errorf("setting a block to its body changed the document:\n%q", doc.String())
+	}
+}
@@ -0,0 +1,364 @@
+package features
+
+import (
+	_ "embed"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+	"github.com/mindmorass/shell-profile-manager/internal/plugins"
+)
+
+//go:embed features.yaml
+var builtinFeatures []byte
+
+// Feature describes a tool integration managed in every profile
+type Feature struct {
+	Name        string           `yaml:"name"`
+	Description string           `yaml:"description"`
+	Optional    bool             `yaml:"optional"`
+	Replaces    []string         `yaml:"replaces"`
+	Comment     string           `yaml:"comment"`
+	Env         []EnvVar         `yaml:"env"`
+	Lines       []string         `yaml:"lines"`
+	Dirs        []Dir            `yaml:"dirs"`
+	Files       []File           `yaml:"files"`
+	Gitignore   []GitignoreGroup `yaml:"gitignore"`
+	Deprecated  []Deprecation    `yaml:"deprecated"`
+	// Strategy is how files with a source get into the profile: "copy"
+	// (the default) or "link" for a symlink to the shared source
+	Strategy string `yaml:"strategy"`
+	// VSCodeExtensions are recommended in generated .code-workspace files
+	VSCodeExtensions []string `yaml:"vscode_extensions"`
+	// Caches are directories inside the profile holding what the tools
+	// download or rebuild on demand, emptied by 'profile clean'
+	Caches []string `yaml:"caches"`
+}
+
+// EnvVar is an environment variable exported from .envrc
+type EnvVar struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+// Dir is a directory created inside the profile
+type Dir struct {
+	Path string `yaml:"path"`
+	Mode string `yaml:"mode"`
+}
+
+// Strategies for the files of a feature
+const (
+	StrategyCopy = "copy"
+	StrategyLink = "link"
+)
+
+// File is a starter file created inside the profile if it does not exist.
+// Existing files are never overwritten.
+type File struct {
+	Path    string `yaml:"path"`
+	Mode    string `yaml:"mode"`
+	Content string `yaml:"content"`
+	// Source is a shared file outside the profile (~ and environment
+	// variables are expanded) the file is copied from, or linked to with
+	// the link strategy
+	Source string `yaml:"source"`
+	// Expand replaces $WORKSPACE_HOME and $WORKSPACE_PROFILE in the content
+	// with the profile's directory and name when the file is created
+	Expand bool `yaml:"expand"`
+}
+
+// GitignoreGroup is a commented block of .gitignore patterns
+type GitignoreGroup struct {
+	Comment  string   `yaml:"comment"`
+	Patterns []string `yaml:"patterns"`
+}
+
+// Deprecation marks a variable that older profiles may still export
+type Deprecation struct {
+	Name       string `yaml:"name"`
+	ReplacedBy string `yaml:"replaced_by"`
+	Reason     string `yaml:"reason"`
+}
+
+type file struct {
+	Features []Feature `yaml:"features"`
+}
+
+// UnmarshalYAML accepts either a plain path or a {path, mode} mapping
+func (d *Dir) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		d.Path = value.Value
+		return nil
+	}
+
+	type plain Dir
+	return value.Decode((*plain)(d))
+}
+
+// FileMode returns the directory permissions (0755 unless overridden)
+func (d Dir) FileMode() os.FileMode {
+	if d.Mode == "" {
+		return 0755
+	}
+	mode, err := strconv.ParseUint(d.Mode, 8, 32)
+	if err != nil {
+		return 0755
+	}
+	return os.FileMode(mode)
+}
+
+// FileMode returns the file permissions (0644 unless overridden)
+func (f File) FileMode() os.FileMode {
+	if f.Mode == "" {
+		return 0644
+	}
+	mode, err := strconv.ParseUint(f.Mode, 8, 32)
+	if err != nil {
+		return 0644
+	}
+	return os.FileMode(mode)
+}
+
+// SourcePath returns the source with ~ and environment variables expanded
+// ("" without a source)
+func (f File) SourcePath() string {
+	if f.Source == "" {
+		return ""
+	}
+	return config.ExpandPath(f.Source)
+}
+
+// Linked reports whether the feature's file is a symlink to its source
+// rather than a file of its own
+func (f Feature) Linked(file File) bool {
+	return f.Strategy == StrategyLink && file.Source != ""
+}
+
+// Render returns the file content for a profile, expanding the workspace
+// variables if requested. Other ${...} references are left as they are.
+func (f File) Render(profileDir string) string {
+	if !f.Expand {
+		return f.Content
+	}
+	return os.Expand(f.Content, func(name string) string {
+		switch name {
+		case "WORKSPACE_HOME":
+			return profileDir
+		case "WORKSPACE_PROFILE":
+			return filepath.Base(profileDir)
+		default:
+			return "${" + name + "}"
+		}
+	})
+}
+
+// Line returns the .envrc export statement for the variable
+func (v EnvVar) Line() string {
+	return fmt.Sprintf(`export %s="%s"`, v.Name, v.Value)
+}
+
+// CommentBlock returns the feature's comment as "# "-prefixed lines
+func (f Feature) CommentBlock() string {
+	return commentLines(f.Comment)
+}
+
+// HasEnvrcSection reports whether the feature contributes to .envrc
+func (f Feature) HasEnvrcSection() bool {
+	return len(f.Env) > 0 || len(f.Lines) > 0
+}
+
+// CommentLine returns the group's comment as a "# " line
+func (g GitignoreGroup) CommentLine() string {
+	return commentLines(g.Comment)
+}
+
+func commentLines(text string) string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString("# " + strings.TrimSpace(line) + "\n")
+	}
+	return b.String()
+}
+
+// UserFeaturesDir returns the directory holding user feature definitions
+func UserFeaturesDir() (string, error) {
+	spmDir, err := config.SpmDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(spmDir, "features.d"), nil
+}
+
+// Load returns the built-in features merged with user definitions and
+// those registered by plugins. A user or plugin feature with the same name
+// as an earlier one replaces it; new features are appended in file name
+// order, then in plugin order.
+func Load() ([]Feature, error) {
+	all, err := parse(builtinFeatures, "built-in features")
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := UserFeaturesDir()
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		userFeatures, err := parse(content, path)
+		if err != nil {
+			return nil, err
+		}
+		slog.Debug("merging user feature definitions", "path", path, "features", len(userFeatures))
+		all = merge(all, userFeatures)
+	}
+
+	for _, plugin := range plugins.Load() {
+		if plugin.Error != "" || len(plugin.Features) == 0 {
+			continue
+		}
+		content := append(append([]byte(`{"features": `), plugin.Features...), '}')
+		pluginFeatures, err := parse(content, "plugin "+plugin.Name)
+		if err != nil {
+			return nil, err
+		}
+		slog.Debug("merging plugin feature definitions", "plugin", plugin.Name, "features", len(pluginFeatures))
+		all = merge(all, pluginFeatures)
+	}
+
+	return all, nil
+}
+
+func parse(content []byte, source string) ([]Feature, error) {
+	var f file
+	if err := yaml.Unmarshal(content, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", source, err)
+	}
+	for _, feature := range f.Features {
+		if feature.Name == "" {
+			return nil, fmt.Errorf("invalid feature in %s: name is required", source)
+		}
+		for _, v := range feature.Env {
+			if v.Name == "" {
+				return nil, fmt.Errorf("invalid feature %q in %s: env entries need a name", feature.Name, source)
+			}
+		}
+		switch feature.Strategy {
+		case "", StrategyCopy, StrategyLink:
+		default:
+			return nil, fmt.Errorf("invalid feature %q in %s: unknown strategy %q (use copy or link)", feature.Name, source, feature.Strategy)
+		}
+		for _, file := range feature.Files {
+			if file.Path == "" {
+				return nil, fmt.Errorf("invalid feature %q in %s: files need a path", feature.Name, source)
+			}
+			if file.Source != "" && file.Content != "" {
+				return nil, fmt.Errorf("invalid feature %q in %s: file %s has both a source and content", feature.Name, source, file.Path)
+			}
+			if feature.Strategy == StrategyLink && file.Source == "" {
+				return nil, fmt.Errorf("invalid feature %q in %s: file %s needs a source to be linked", feature.Name, source, file.Path)
+			}
+		}
+		for _, cache := range feature.Caches {
+			// Never the profile itself or anything outside it
+			if dir := filepath.Clean(filepath.FromSlash(cache)); !filepath.IsLocal(dir) || dir == "." {
+				return nil, fmt.Errorf("invalid feature %q in %s: cache %q must be a directory inside the profile", feature.Name, source, cache)
+			}
+		}
+		for _, d := range feature.Deprecated {
+			if d.Name == "" {
+				return nil, fmt.Errorf("invalid feature %q in %s: deprecated entries need a name", feature.Name, source)
+			}
+		}
+	}
+	return f.Features, nil
+}
+
+// Select returns the features active for a profile: every default feature
+// plus the optional ones in enable, minus anything in disable or replaced
+// by another active feature
+func Select(all []Feature, enable, disable []string) []Feature {
+	enabled := make(map[string]bool)
+	for _, name := range enable {
+		enabled[name] = true
+	}
+	disabled := make(map[string]bool)
+	for _, name := range disable {
+		disabled[name] = true
+	}
+
+	var selected []Feature
+	for _, feature := range all {
+		if disabled[feature.Name] || (feature.Optional && !enabled[feature.Name]) {
+			continue
+		}
+		selected = append(selected, feature)
+	}
+
+	replaced := make(map[string]bool)
+	for _, feature := range selected {
+		for _, name := range feature.Replaces {
+			replaced[name] = true
+		}
+	}
+	if len(replaced) == 0 {
+		return selected
+	}
+
+	var kept []Feature
+	for _, feature := range selected {
+		if !replaced[feature.Name] {
+			kept = append(kept, feature)
+		}
+	}
+	return kept
+}
+
+// Find returns the feature with the given name
+func Find(all []Feature, name string) (Feature, bool) {
+	for _, feature := range all {
+		if feature.Name == name {
+			return feature, true
+		}
+	}
+	return Feature{}, false
+}
+
+func merge(base, overrides []Feature) []Feature {
+	for _, override := range overrides {
+		replaced := false
+		for i := range base {
+			if base[i].Name == override.Name {
+				base[i] = override
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			base = append(base, override)
+		}
+	}
+	return base
+}
@@ -0,0 +1,278 @@
+// Package gitignore parses .gitignore files into rules so patterns can be
+// compared by what they match rather than by their text.
+package gitignore
+
+import (
+	"path"
+	"strings"
+)
+
+// Status describes how a file treats a pattern
+type Status int
+
+const (
+	// Missing means no rule ignores the pattern
+	Missing Status = iota
+	// Ignored means an existing rule already ignores the pattern
+	Ignored
+	// Negated means a later "!" rule explicitly re-includes the pattern
+	Negated
+)
+
+// Rule is a single pattern line from a .gitignore file
+type Rule struct {
+	// Pattern is the normalized pattern without "!", leading "/" or trailing "/"
+	Pattern string
+	// Negate is set for "!" rules that re-include matching paths
+	Negate bool
+	// DirOnly is set for patterns ending in "/" that only match directories
+	DirOnly bool
+	// Anchored is set when the pattern matches relative to the .gitignore
+	// location instead of at any depth
+	Anchored bool
+}
+
+// ParseRule parses one line; ok is false for blank lines and comments
+func ParseRule(line string) (rule Rule, ok bool) {
+	line = trimTrailingSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return Rule{}, false
+	}
+
+	if strings.HasPrefix(line, "!") {
+		rule.Negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.DirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	// A slash at the beginning or middle anchors the pattern
+	if strings.Contains(line, "/") {
+		rule.Anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	if line == "" {
+		return Rule{}, false
+	}
+	rule.Pattern = line
+	return rule, true
+}
+
+// String renders the rule in .gitignore syntax
+func (r Rule) String() string {
+	s := r.Pattern
+	if r.Anchored && !strings.Contains(s, "/") {
+		s = "/" + s
+	}
+	if r.DirOnly {
+		s += "/"
+	}
+	if r.Negate {
+		s = "!" + s
+	}
+	return s
+}
+
+// covers reports whether the rule matches everything the wanted rule matches
+func (r Rule) covers(want Rule) bool {
+	if r.Pattern == want.Pattern && r.Anchored == want.Anchored {
+		return !r.DirOnly || want.DirOnly
+	}
+
+	segments := strings.Split(want.Pattern, "/")
+	for i := range segments {
+		// Only literal path prefixes can be checked against the rule
+		if hasGlob(segments[i]) {
+			break
+		}
+		isFull := i == len(segments)-1
+		if r.DirOnly && isFull && !want.DirOnly {
+			continue
+		}
+		prefix := strings.Join(segments[:i+1], "/")
+		if r.matches(prefix, want.Anchored) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the rule matches the path p. An unanchored wanted
+// path can appear at any depth, so only unanchored rules can match it.
+func (r Rule) matches(p string, anchored bool) bool {
+	if !r.Anchored {
+		return matchSegments([]string{r.Pattern}, []string{lastSegment(p)})
+	}
+	if !anchored {
+		return false
+	}
+	return matchSegments(strings.Split(r.Pattern, "/"), strings.Split(p, "/"))
+}
+
+// File is a parsed .gitignore that keeps its original lines so edits do
+// not disturb comments, ordering, or formatting
+type File struct {
+	lines []string
+}
+
+// Parse splits .gitignore content into lines
+func Parse(content string) *File {
+	content = strings.TrimSuffix(content, "\n")
+	if content == "" {
+		return &File{}
+	}
+	return &File{lines: strings.Split(content, "\n")}
+}
+
+// Rules returns the pattern rules in file order
+func (f *File) Rules() []Rule {
+	var rules []Rule
+	for _, line := range f.lines {
+		if rule, ok := ParseRule(line); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// Status reports whether the pattern is already ignored by the file.
+// Rules are evaluated in order and the last matching rule wins, as in git.
+func (f *File) Status(pattern string) Status {
+	want, ok := ParseRule(pattern)
+	if !ok {
+		return Ignored
+	}
+
+	status := Missing
+	for _, rule := range f.Rules() {
+		if !rule.covers(want) {
+			continue
+		}
+		if rule.Negate {
+			status = Negated
+		} else if !want.Negate {
+			status = Ignored
+		}
+	}
+	return status
+}
+
+// Ignores reports whether the file ignores the path, given relative to the
+// .gitignore location with "/" separators
+func (f *File) Ignores(relPath string, isDir bool) bool {
+	pattern := "/" + strings.TrimPrefix(relPath, "/")
+	if isDir {
+		pattern += "/"
+	}
+	return f.Status(pattern) == Ignored
+}
+
+// AddToSection appends patterns to the section headed by comment. If the
+// section does not exist it is created before the line equal to before,
+// or at the end of the file when that line is not found.
+func (f *File) AddToSection(comment string, patterns []string, before string) {
+	commentLines := splitLines(comment)
+
+	if len(commentLines) > 0 {
+		if start := f.indexOf(commentLines[0]); start != -1 {
+			end := start + 1
+			for end < len(f.lines) && strings.TrimSpace(f.lines[end]) != "" {
+				end++
+			}
+			f.insert(end, patterns)
+			return
+		}
+	}
+
+	section := append(append([]string{}, commentLines...), patterns...)
+	if idx := f.indexOf(before); before != "" && idx != -1 {
+		f.insert(idx, append(section, ""))
+		return
+	}
+
+	if len(f.lines) > 0 && strings.TrimSpace(f.lines[len(f.lines)-1]) != "" {
+		section = append([]string{""}, section...)
+	}
+	f.lines = append(f.lines, section...)
+}
+
+// String renders the file with a trailing newline
+func (f *File) String() string {
+	if len(f.lines) == 0 {
+		return ""
+	}
+	return strings.Join(f.lines, "\n") + "\n"
+}
+
+func (f *File) indexOf(line string) int {
+	for i, l := range f.lines {
+		if strings.TrimSpace(l) == line {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *File) insert(at int, lines []string) {
+	rest := append([]string{}, f.lines[at:]...)
+	f.lines = append(append(f.lines[:at], lines...), rest...)
+}
+
+func splitLines(text string) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	return lines
+}
+
+// matchSegments matches path segments against pattern segments where "**"
+// matches zero or more whole segments
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], name[1:])
+}
+
+func hasGlob(s string) bool {
+	return strings.ContainsAny(s, `*?[\`)
+}
+
+func lastSegment(p string) string {
+	if i := strings.LastIndex(p, "/"); i != -1 {
+		return p[i+1:]
+	}
+	return p
+}
+
+func trimTrailingSpace(line string) string {
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	return strings.TrimRight(line, "\r\t")
+}
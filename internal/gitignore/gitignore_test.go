@@ -0,0 +1,159 @@
+package gitignore
+
+import "testing"
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		line string
+		want Rule
+		ok   bool
+	}{
+		{"", Rule{}, false},
+		{"# comment", Rule{}, false},
+		{"/", Rule{}, false},
+		{"*.log", Rule{Pattern: "*.log"}, true},
+		{"*.log  ", Rule{Pattern: "*.log"}, true},
+		{".terraform/", Rule{Pattern: ".terraform", DirOnly: true}, true},
+		{"/secrets", Rule{Pattern: "secrets", Anchored: true}, true},
+		{".aws/credentials", Rule{Pattern: ".aws/credentials", Anchored: true}, true},
+		{"!keep.log", Rule{Pattern: "keep.log", Negate: true}, true},
+		{`\#file`, Rule{Pattern: "#file"}, true},
+		{`\!file`, Rule{Pattern: "!file"}, true},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseRule(tt.line)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseRule(%q) = %+v, %v; want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRuleString(t *testing.T) {
+	for _, line := range []string{"*.log", "/secrets", ".terraform/", ".aws/credentials", "!keep.log", "!/build/"} {
+		rule, _ := ParseRule(line)
+		if got := rule.String(); got != line {
+			t.Errorf("ParseRule(%q).String() = %q", line, got)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		pattern string
+		want    Status
+	}{
+		{"same pattern", ".env\n", ".env", Ignored},
+		{"no rule", "*.log\n", ".env", Missing},
+		{"glob covers file", "*.log\n", "debug.log", Ignored},
+		{"glob covers nested file", "*.log\n", "logs/debug.log", Ignored},
+		{"directory covers its files", ".aws/\n", ".aws/credentials", Ignored},
+		{"directory rule does not cover a file", ".aws/\n", ".aws", Missing},
+		{"file rule covers the directory", ".aws\n", ".aws/", Ignored},
+		{"anchored rule does not cover any depth", "/.env\n", ".env", Missing},
+		{"unanchored rule covers anchored", ".env\n", "/.env", Ignored},
+		{"double star", "**/cache\n", "/a/b/cache", Ignored},
+		{"negated later", ".env*\n!.env.example\n", ".env.example", Negated},
+		{"ignored again after negation", "!.env\n.env\n", ".env", Ignored},
+		{"comments are not rules", "# .env\n", ".env", Missing},
+		{"blank pattern", "", "", Ignored},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.content).Status(tt.pattern); got != tt.want {
+				t.Errorf("Status(%q) = %v, want %v", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnores(t *testing.T) {
+	file := Parse(".terraform/\n*.tfstate\n/secrets.env\n")
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{".terraform", true, true},
+		{"modules/.terraform", true, true},
+		{"terraform.tfstate", false, true},
+		{"secrets.env", false, true},
+		{"sub/secrets.env", false, false},
+		{"main.tf", false, false},
+	}
+
+	for _, tt := range tests {
+		if got := file.Ignores(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Ignores(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestAddToSection(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		comment  string
+		patterns []string
+		before   string
+		want     string
+	}{
+		{
+			name:     "empty file",
+			content:  "",
+			comment:  "# Terraform",
+			patterns: []string{".terraform/"},
+			want:     "# Terraform\n.terraform/\n",
+		},
+		{
+			name:     "appended to the existing section",
+			content:  "# Terraform\n.terraform/\n\n# OS files\n.DS_Store\n",
+			comment:  "# Terraform",
+			patterns: []string{"*.tfstate"},
+			before:   "# OS files",
+			want:     "# Terraform\n.terraform/\n*.tfstate\n\n# OS files\n.DS_Store\n",
+		},
+		{
+			name:     "new section before a line",
+			content:  "# Custom\nnotes/\n\n# OS files\n.DS_Store\n",
+			comment:  "# Kubernetes",
+			patterns: []string{".kube/cache/"},
+			before:   "# OS files",
+			want:     "# Custom\nnotes/\n\n# Kubernetes\n.kube/cache/\n\n# OS files\n.DS_Store\n",
+		},
+		{
+			name:     "new section at the end when the line is missing",
+			content:  "notes/\n",
+			comment:  "# Kubernetes",
+			patterns: []string{".kube/cache/"},
+			before:   "# OS files",
+			want:     "notes/\n\n# Kubernetes\n.kube/cache/\n",
+		},
+		{
+			name:     "multi-line comment",
+			content:  "notes/\n",
+			comment:  "# Python\n# virtualenvs",
+			patterns: []string{".venv/"},
+			want:     "notes/\n\n# Python\n# virtualenvs\n.venv/\n",
+		},
+		{
+			name:     "without a comment",
+			content:  "notes/\n\n",
+			patterns: []string{".env"},
+			want:     "notes/\n\n.env\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := Parse(tt.content)
+			file.AddToSection(tt.comment, tt.patterns, tt.before)
+			if got := file.String(); got != tt.want {
+				t.Errorf("got\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+// Package goroutine tells goroutines apart, for state that belongs to the
+// call chain running in one, such as the profile locks it holds or where
+// the profile it processes prints
+package goroutine
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+)
+
+// ID returns the id of the running goroutine, which Go only shows in stack
+// traces ("goroutine 7 [running]: ...")
+func ID() uint64 {
+	buf := make([]byte, 64)
+	buf = bytes.TrimPrefix(buf[:runtime.Stack(buf, false)], []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i != -1 {
+		buf = buf[:i]
+	}
+	id, _ := strconv.ParseUint(string(buf), 10, 64)
+	return id
+}
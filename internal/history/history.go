@@ -0,0 +1,81 @@
+// Package history reads and writes the per-profile log of the changes spm
+// applied (.spm-history.jsonl), one JSON entry per line, oldest first.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileName is the history file name inside a profile directory
+const FileName = ".spm-history.jsonl"
+
+// Entry is one operation that changed a profile
+type Entry struct {
+	Time time.Time `json:"time" yaml:"time"`
+	// Command is the operation, e.g. "create", "update", "feature enable"
+	Command string `json:"command" yaml:"command"`
+	// SpmVersion is the version of spm that applied the changes
+	SpmVersion string `json:"spm_version" yaml:"spm_version"`
+	// Template, TemplateVersion, and SchemaVersion are what the profile's
+	// manifest recorded after the changes
+	Template        string   `json:"template,omitempty" yaml:"template,omitempty"`
+	TemplateVersion int      `json:"template_version,omitempty" yaml:"template_version,omitempty"`
+	SchemaVersion   int      `json:"schema_version,omitempty" yaml:"schema_version,omitempty"`
+	Changes         []string `json:"changes" yaml:"changes"`
+}
+
+// Path returns the history location for a profile directory
+func Path(profileDir string) string {
+	return filepath.Join(profileDir, FileName)
+}
+
+// Append adds an entry to the profile's history
+func Append(profileDir string, entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode history entry: %w", err)
+	}
+	f, err := os.OpenFile(Path(profileDir), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", FileName, err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", FileName, err)
+	}
+	return f.Close()
+}
+
+// Load returns the profile's history, oldest first (empty when it has
+// none). Lines that cannot be parsed, e.g. after a merge conflict, are
+// skipped.
+func Load(profileDir string) ([]Entry, error) {
+	f, err := os.Open(Path(profileDir))
+	if os.IsNotExist(err) {
+		return []Entry{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", FileName, err)
+	}
+	defer f.Close()
+
+	entries := []Entry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", FileName, err)
+	}
+	return entries, nil
+}
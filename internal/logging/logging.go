@@ -0,0 +1,140 @@
+// Package logging sets up the process-wide slog logger: messages at the
+// chosen verbosity on stderr, and everything at debug level in a rotating
+// log file under the spm state directory for attaching to bug reports.
+// Secrets are masked in both; --show-secrets only unmasks them on stderr.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/mindmorass/shell-profile-manager/internal/redact"
+)
+
+const (
+	// FileName is the log file in the state directory
+	FileName = "spm.log"
+	// maxSize is the size at which the log file is rotated
+	maxSize = 1 << 20
+	// keep is the number of rotated log files kept (spm.log.1 ...)
+	keep = 3
+)
+
+// Setup installs the default logger. Messages at level or above are
+// written to stderr; all messages are appended to the log file in
+// stateDir. A log file that cannot be opened only disables file logging,
+// so a read-only home never breaks a command.
+func Setup(level slog.Level, stateDir string) (io.Closer, error) {
+	handlers := []slog.Handler{
+		slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr(redact.Text)}),
+	}
+
+	var closer io.Closer = io.NopCloser(nil)
+	file, err := openLogFile(stateDir)
+	if err == nil {
+		handlers = append(handlers, slog.NewTextHandler(file, &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: redactAttr(redact.Always)}))
+		closer = file
+	}
+
+	slog.SetDefault(slog.New(teeHandler(handlers)))
+	return closer, err
+}
+
+// redactAttr masks the secrets in the message and string attributes of a
+// record, including lists of lines
+func redactAttr(mask func(string) string) func([]string, slog.Attr) slog.Attr {
+	return func(_ []string, attr slog.Attr) slog.Attr {
+		value := attr.Value.Resolve()
+		switch value.Kind() {
+		case slog.KindString:
+			return slog.String(attr.Key, mask(value.String()))
+		case slog.KindAny:
+			if lines, ok := value.Any().([]string); ok {
+				masked := make([]string, len(lines))
+				for i, line := range lines {
+					masked[i] = mask(line)
+				}
+				return slog.Any(attr.Key, masked)
+			}
+		}
+		return attr
+	}
+}
+
+// Path returns the log file in stateDir
+func Path(stateDir string) string {
+	return filepath.Join(stateDir, FileName)
+}
+
+// openLogFile opens the log file for appending, rotating it first when it
+// has grown past maxSize
+func openLogFile(stateDir string) (*os.File, error) {
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	path := Path(stateDir)
+	if info, err := os.Stat(path); err == nil && info.Size() >= maxSize {
+		if err := rotate(path); err != nil {
+			return nil, err
+		}
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+}
+
+// rotate shifts path to path.1, path.1 to path.2, ... dropping the oldest
+func rotate(path string) error {
+	for i := keep - 1; i > 0; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to rotate log: %w", err)
+		}
+	}
+	if err := os.Rename(path, path+".1"); err != nil {
+		return fmt.Errorf("failed to rotate log: %w", err)
+	}
+	return nil
+}
+
+// teeHandler sends every record to all handlers that accept its level
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, h := range t {
+		if h.Enabled(ctx, record.Level) {
+			errs = append(errs, h.Handle(ctx, record.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(teeHandler, len(t))
+	for i, h := range t {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	handlers := make(teeHandler, len(t))
+	for i, h := range t {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
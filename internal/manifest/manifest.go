@@ -0,0 +1,276 @@
+// Package manifest reads and writes the per-profile profile.yaml file that
+// records metadata spm needs to manage a profile over time.
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mindmorass/shell-profile-manager/internal/atomicfile"
+)
+
+// FileName is the manifest file name inside a profile directory
+const FileName = "profile.yaml"
+
+// Manifest is the metadata stored in a profile's profile.yaml
+type Manifest struct {
+	// SchemaVersion is the profile layout version; profiles without a
+	// manifest predate versioning and are treated as version 0
+	SchemaVersion int    `yaml:"schema_version"`
+	CreatedAt     string `yaml:"created_at,omitempty"`
+	// Description and Tags help find the profile in the selector
+	Description string   `yaml:"description,omitempty"`
+	Tags        []string `yaml:"tags,omitempty"`
+	// Aliases are short names every command accepts for the profile
+	Aliases []string `yaml:"aliases,omitempty"`
+	// Owner is who the profile belongs to (by default the git email)
+	Owner string `yaml:"owner,omitempty"`
+	// Template and TemplateVersion record what the profile was rendered
+	// from, so later template changes can be told apart
+	Template        string `yaml:"template,omitempty"`
+	TemplateVersion int    `yaml:"template_version,omitempty"`
+	// TemplateRepo is the template repository and commit
+	// ("<repository>@<commit>") of templates that come from one
+	TemplateRepo string `yaml:"template_repo,omitempty"`
+	// Preset is the preset the profile was created from
+	Preset string `yaml:"preset,omitempty"`
+	// Vars are custom key/values available to templates as .Vars
+	Vars map[string]string `yaml:"vars,omitempty"`
+	// Extends names the base profile (in the same profiles directory)
+	// whose feature selection, vars, and layer this profile inherits
+	Extends string `yaml:"extends,omitempty"`
+	// Layer holds the items managed in this profile and in every profile
+	// that extends it
+	Layer *Layer `yaml:"layer,omitempty"`
+	// Remotes are the git remotes the profile repository syncs with
+	Remotes  []Remote          `yaml:"remotes,omitempty"`
+	Features *FeatureSelection `yaml:"features,omitempty"`
+	Secrets  *Secrets          `yaml:"secrets,omitempty"`
+	Go       *GoConfig         `yaml:"go,omitempty"`
+	// Tools lists nixpkgs attributes for the generated flake.nix devShell
+	Tools   []string       `yaml:"tools,omitempty"`
+	Tmux    *TmuxConfig    `yaml:"tmux,omitempty"`
+	Encrypt *EncryptConfig `yaml:"encrypt,omitempty"`
+	Backup  *BackupConfig  `yaml:"backup,omitempty"`
+	// Links are the symlinks to shared files made for features with the
+	// link strategy, so update can tell them from links made by hand
+	Links []Link `yaml:"links,omitempty"`
+}
+
+// Remote is a git remote of the profile repository
+type Remote struct {
+	Name string `json:"name" yaml:"name"`
+	URL  string `json:"url" yaml:"url"`
+}
+
+// SetRemote records the URL of a git remote, replacing an existing entry
+// with the same name
+func (m *Manifest) SetRemote(name, url string) {
+	for i := range m.Remotes {
+		if m.Remotes[i].Name == name {
+			m.Remotes[i].URL = url
+			return
+		}
+	}
+	m.Remotes = append(m.Remotes, Remote{Name: name, URL: url})
+}
+
+// RemoteURL returns the recorded URL of a git remote ("" if not recorded)
+func (m *Manifest) RemoteURL(name string) string {
+	for _, remote := range m.Remotes {
+		if remote.Name == name {
+			return remote.URL
+		}
+	}
+	return ""
+}
+
+// Layer is what a profile shares with the profiles that extend it:
+// environment variables exported from .envrc, .gitignore patterns, and
+// directories. A profile's own layer overrides variables of the same name
+// from its bases.
+type Layer struct {
+	Env       []LayerVar `yaml:"env,omitempty"`
+	Gitignore []string   `yaml:"gitignore,omitempty"`
+	Dirs      []string   `yaml:"dirs,omitempty"`
+}
+
+// LayerVar is an environment variable of a layer
+type LayerVar struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+// FeatureSelection turns optional features on and default features off
+type FeatureSelection struct {
+	Enable  []string `yaml:"enable,omitempty"`
+	Disable []string `yaml:"disable,omitempty"`
+}
+
+// EnabledFeatures returns the optional features enabled for the profile
+func (m *Manifest) EnabledFeatures() []string {
+	if m.Features == nil {
+		return nil
+	}
+	return m.Features.Enable
+}
+
+// DisabledFeatures returns the default features disabled for the profile
+func (m *Manifest) DisabledFeatures() []string {
+	if m.Features == nil {
+		return nil
+	}
+	return m.Features.Disable
+}
+
+// Secrets configures where the profile's secret variables come from
+type Secrets struct {
+	Provider  string           `yaml:"provider"`
+	Vault     *VaultConfig     `yaml:"vault,omitempty"`
+	Bitwarden *BitwardenConfig `yaml:"bitwarden,omitempty"`
+	Keychain  *KeychainConfig  `yaml:"keychain,omitempty"`
+	WinCred   *KeychainConfig  `yaml:"wincred,omitempty"`
+	Vars      []SecretVar      `yaml:"vars,omitempty"`
+}
+
+// VaultConfig holds the HashiCorp Vault connection settings for a profile
+type VaultConfig struct {
+	Address    string `yaml:"address,omitempty"`
+	Namespace  string `yaml:"namespace,omitempty"`
+	Role       string `yaml:"role,omitempty"`
+	AuthMethod string `yaml:"auth_method,omitempty"`
+}
+
+// BitwardenConfig holds the Bitwarden Secrets Manager settings for a
+// profile. The access token is read from BWS_ACCESS_TOKEN, never stored.
+type BitwardenConfig struct {
+	// ServerURL selects a self-hosted or EU server (BWS_SERVER_URL)
+	ServerURL string `yaml:"server_url,omitempty"`
+}
+
+// KeychainConfig holds the macOS Keychain (or Windows Credential Manager)
+// settings for a profile. Keychain secrets are generic passwords with this
+// service and the reference as account; Credential Manager secrets are
+// generic credentials named <service>/<reference>.
+type KeychainConfig struct {
+	Service string `yaml:"service,omitempty"`
+}
+
+// SecretVar maps an environment variable to a provider-specific reference
+type SecretVar struct {
+	Name string `yaml:"name"`
+	Ref  string `yaml:"ref"`
+}
+
+// GoConfig lists the module path patterns exported for the go feature
+type GoConfig struct {
+	Private []string `yaml:"private,omitempty"`
+	NoSumDB []string `yaml:"nosumdb,omitempty"`
+}
+
+// TmuxConfig declares the windows of the profile's tmux session
+type TmuxConfig struct {
+	Windows []TmuxWindow `yaml:"windows,omitempty"`
+}
+
+// TmuxWindow is a tmux window started in the workspace. Command runs in the
+// first pane; each entry in Panes adds a pane running that command.
+type TmuxWindow struct {
+	Name    string   `yaml:"name"`
+	Dir     string   `yaml:"dir,omitempty"`
+	Command string   `yaml:"command,omitempty"`
+	Panes   []string `yaml:"panes,omitempty"`
+	Layout  string   `yaml:"layout,omitempty"`
+}
+
+// EncryptConfig lists the profile files kept sops-encrypted (as <file>.sops)
+// and the age recipients they are encrypted for
+type EncryptConfig struct {
+	Files []string `yaml:"files,omitempty"`
+	Age   []string `yaml:"age,omitempty"`
+}
+
+// BackupConfig lists the age recipients the backups update makes are
+// encrypted for, overriding the global backup_age setting
+type BackupConfig struct {
+	Age []string `yaml:"age,omitempty"`
+}
+
+// Link is a symlink in the profile (Path, relative to the profile) to a
+// shared file (Target)
+type Link struct {
+	Path   string `yaml:"path"`
+	Target string `yaml:"target"`
+}
+
+// LinkTarget returns the recorded target of a link ("" if not recorded)
+func (m *Manifest) LinkTarget(path string) string {
+	for _, link := range m.Links {
+		if link.Path == path {
+			return link.Target
+		}
+	}
+	return ""
+}
+
+// New returns a manifest for a profile created now at the given version
+func New(schemaVersion int) *Manifest {
+	return &Manifest{
+		SchemaVersion: schemaVersion,
+		CreatedAt:     time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// Path returns the manifest location for a profile directory
+func Path(profileDir string) string {
+	return filepath.Join(profileDir, FileName)
+}
+
+// Exists reports whether the profile has a manifest
+func Exists(profileDir string) bool {
+	_, err := os.Stat(Path(profileDir))
+	return err == nil
+}
+
+// Load reads a profile's manifest. A missing file yields an empty manifest
+// (schema version 0) so older profiles can be migrated.
+func Load(profileDir string) (*Manifest, error) {
+	content, err := os.ReadFile(Path(profileDir))
+	if os.IsNotExist(err) {
+		return &Manifest{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", FileName, err)
+	}
+
+	var m Manifest
+	if err := yaml.Unmarshal(content, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", Path(profileDir), err)
+	}
+	return &m, nil
+}
+
+// Save writes the manifest to the profile directory
+func Save(profileDir string, m *Manifest) error {
+	var buf bytes.Buffer
+	buf.WriteString("# Workspace profile manifest (managed by profile; safe to edit)\n")
+
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(m); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", FileName, err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", FileName, err)
+	}
+
+	if err := atomicfile.WriteFile(Path(profileDir), buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", FileName, err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Package notify shows desktop notifications with the notifier of the
+// platform: terminal-notifier or osascript on macOS, notify-send on Linux.
+package notify
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Backends
+const (
+	// Auto picks terminal-notifier, then osascript on macOS, and
+	// notify-send elsewhere
+	Auto             = "auto"
+	TerminalNotifier = "terminal-notifier"
+	Osascript        = "osascript"
+	NotifySend       = "notify-send"
+)
+
+// appName groups the notifications of spm
+const appName = "spm"
+
+// Valid reports whether backend names a backend
+func Valid(backend string) bool {
+	switch backend {
+	case Auto, TerminalNotifier, Osascript, NotifySend:
+		return true
+	}
+	return false
+}
+
+// Resolve returns the backend auto stands for on this system, or the
+// backend named when it is installed
+func Resolve(backend string) (string, error) {
+	if backend != Auto {
+		if _, err := exec.LookPath(backend); err != nil {
+			return "", fmt.Errorf("%s is not installed", backend)
+		}
+		return backend, nil
+	}
+	candidates := []string{NotifySend}
+	if runtime.GOOS == "darwin" {
+		candidates = []string{TerminalNotifier, Osascript}
+	}
+	for _, candidate := range candidates {
+		if _, err := exec.LookPath(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no desktop notifier found (install %s)", strings.Join(candidates, " or "))
+}
+
+// Send shows a notification with the backend
+func Send(backend, title, message string) error {
+	backend, err := Resolve(backend)
+	if err != nil {
+		return err
+	}
+	var cmd *exec.Cmd
+	switch backend {
+	case TerminalNotifier:
+		cmd = exec.Command(TerminalNotifier, "-title", title, "-message", message, "-group", appName+":"+title)
+	case Osascript:
+		cmd = exec.Command(Osascript, "-e", fmt.Sprintf("display notification %s with title %s", appleScriptString(message), appleScriptString(title)))
+	default:
+		cmd = exec.Command(NotifySend, "--app-name="+appName, title, message)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", backend, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// appleScriptString quotes text as an AppleScript string literal
+func appleScriptString(text string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text) + `"`
+}
@@ -0,0 +1,188 @@
+// Package perms knows the permissions the files holding credentials in a
+// profile should have (SSH keys, cloud credentials, token files, the GnuPG
+// home) and finds and restricts those that grant more. Many of the tools
+// reading them refuse to, or warn, when others can.
+package perms
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Rule is the most a file may grant
+type Rule struct {
+	// Pattern matches paths relative to the profile (path.Match syntax); a
+	// pattern ending in "/**" matches everything below a directory
+	Pattern string
+	// Except matches paths the rule leaves out, e.g. public keys
+	Except string
+	// Mode is the most a matching file may grant; a matching directory may
+	// grant the execute bits that go with the read bits as well
+	Mode os.FileMode
+}
+
+// Rules are the files holding credentials in a profile
+var Rules = []Rule{
+	{Pattern: ".ssh", Mode: 0700},
+	{Pattern: ".ssh/id_*", Except: "*.pub", Mode: 0600},
+	{Pattern: ".ssh/*.pem", Mode: 0600},
+	{Pattern: ".ssh/*.key", Mode: 0600},
+	{Pattern: ".ssh/config", Mode: 0600},
+	{Pattern: ".gnupg", Mode: 0700},
+	{Pattern: ".gnupg/private-keys-v1.d", Mode: 0700},
+	{Pattern: ".gnupg/private-keys-v1.d/**", Mode: 0600},
+	{Pattern: ".aws/credentials", Mode: 0600},
+	{Pattern: ".azure/msal_token_cache.*", Mode: 0600},
+	{Pattern: ".azure/accessTokens.json", Mode: 0600},
+	{Pattern: ".gcloud/*.db", Mode: 0600},
+	{Pattern: ".gcloud/application_default_credentials.json", Mode: 0600},
+	{Pattern: ".gcloud/legacy_credentials/**", Mode: 0600},
+	{Pattern: ".kube/config", Mode: 0600},
+	{Pattern: ".terraformrc", Mode: 0600},
+	{Pattern: ".terraform.d/credentials.tfrc.json", Mode: 0600},
+	{Pattern: ".config/docker/config.json", Mode: 0600},
+	{Pattern: ".config/gh/hosts.yml", Mode: 0600},
+	{Pattern: ".netrc", Mode: 0600},
+	{Pattern: ".npmrc", Mode: 0600},
+	{Pattern: ".pypirc", Mode: 0600},
+	{Pattern: ".pgpass", Mode: 0600},
+	{Pattern: ".env", Mode: 0600},
+}
+
+// Violation is a file that grants more than its rule allows
+type Violation struct {
+	Path     string      `json:"path" yaml:"path"`
+	Dir      bool        `json:"-" yaml:"-"`
+	Mode     os.FileMode `json:"-" yaml:"-"`
+	Expected os.FileMode `json:"-" yaml:"-"`
+	// Current and Allowed are the modes in octal, for --output json|yaml
+	Current string `json:"mode" yaml:"mode"`
+	Allowed string `json:"expected" yaml:"expected"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s has permissions %o (expected %o)", v.Path, v.Mode, v.Expected)
+}
+
+// Expected returns the most a file or directory at path (relative to the
+// profile) may grant, and false when no rule covers it
+func Expected(relPath string, dir bool) (os.FileMode, bool) {
+	relPath = filepath.ToSlash(relPath)
+	for _, rule := range Rules {
+		if !rule.matches(relPath) {
+			continue
+		}
+		mode := rule.Mode
+		if dir {
+			mode |= (mode & 0444) >> 2
+		}
+		return mode, true
+	}
+	return 0, false
+}
+
+func (r Rule) matches(relPath string) bool {
+	if r.Except != "" {
+		if ok, _ := path.Match(r.Except, path.Base(relPath)); ok {
+			return false
+		}
+	}
+	if dir, ok := strings.CutSuffix(r.Pattern, "/**"); ok {
+		return strings.HasPrefix(relPath, dir+"/")
+	}
+	ok, _ := path.Match(r.Pattern, relPath)
+	return ok
+}
+
+// Check returns the files in a profile that grant more than their rule
+// allows, sorted by path. Symbolic links are skipped: they point to shared
+// files the profile does not own.
+func Check(profileDir string) ([]Violation, error) {
+	var violations []Violation
+	seen := map[string]bool{}
+	check := func(relPath string) error {
+		if seen[relPath] {
+			return nil
+		}
+		seen[relPath] = true
+		info, err := os.Lstat(filepath.Join(profileDir, relPath))
+		if err != nil || info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+		expected, ok := Expected(relPath, info.IsDir())
+		if !ok {
+			return nil
+		}
+		if mode := info.Mode().Perm(); mode&^expected != 0 {
+			violations = append(violations, Violation{
+				Path: relPath, Dir: info.IsDir(), Mode: mode, Expected: expected,
+				Current: fmt.Sprintf("%04o", mode), Allowed: fmt.Sprintf("%04o", expected),
+			})
+		}
+		return nil
+	}
+
+	for _, rule := range Rules {
+		if dir, ok := strings.CutSuffix(rule.Pattern, "/**"); ok {
+			root := filepath.Join(profileDir, filepath.FromSlash(dir))
+			err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					if os.IsNotExist(err) {
+						return nil
+					}
+					return err
+				}
+				if p == root {
+					return nil
+				}
+				rel, err := filepath.Rel(profileDir, p)
+				if err != nil {
+					return err
+				}
+				return check(filepath.ToSlash(rel))
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to check permissions in %s: %w", dir, err)
+			}
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(profileDir, filepath.FromSlash(rule.Pattern)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid permission rule %s: %w", rule.Pattern, err)
+		}
+		for _, match := range matches {
+			rel, err := filepath.Rel(profileDir, match)
+			if err != nil {
+				return nil, err
+			}
+			if err := check(filepath.ToSlash(rel)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sort.Slice(violations, func(i, j int) bool { return violations[i].Path < violations[j].Path })
+	return violations, nil
+}
+
+// Fix removes the permissions the violations grant beyond their rules. It
+// returns the paths whose permissions did not change, as on Windows drives
+// mounted without metadata (WSL).
+func Fix(profileDir string, violations []Violation) ([]string, error) {
+	var unchanged []string
+	for _, v := range violations {
+		fullPath := filepath.Join(profileDir, filepath.FromSlash(v.Path))
+		if err := os.Chmod(fullPath, v.Mode&v.Expected); err != nil {
+			return unchanged, fmt.Errorf("failed to set permissions on %s: %w", v.Path, err)
+		}
+		if info, err := os.Stat(fullPath); err == nil && info.Mode().Perm()&^v.Expected != 0 {
+			unchanged = append(unchanged, v.Path)
+		}
+	}
+	return unchanged, nil
+}
@@ -0,0 +1,157 @@
+// Package plan describes the changes a command would make to a profile. A
+// plan is rendered like 'terraform plan' for --dry-run, and can be saved
+// with --out and applied later with 'profile apply'.
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/mindmorass/shell-profile-manager/internal/atomicfile"
+	"github.com/mindmorass/shell-profile-manager/internal/redact"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
+)
+
+// Version is the format version of saved plans
+const Version = 1
+
+// Action is what happens to a resource
+type Action string
+
+const (
+	Create Action = "create"
+	Update Action = "update"
+	Delete Action = "delete"
+)
+
+// Kind is the type of resource a change applies to
+type Kind string
+
+const (
+	File Kind = "file"
+	Dir  Kind = "dir"
+	Var  Kind = "var"
+	// Migration is a schema migration of the profile
+	Migration Kind = "migration"
+)
+
+// Change is a single planned change
+type Change struct {
+	Action Action `json:"action" yaml:"action"`
+	Kind   Kind   `json:"kind" yaml:"kind"`
+	// Path is relative to the profile, or the variable or migration name
+	Path   string `json:"path" yaml:"path"`
+	Detail string `json:"detail,omitempty" yaml:"detail,omitempty"`
+}
+
+// Plan is the set of changes a command would make to one profile
+type Plan struct {
+	Version int `json:"version" yaml:"version"`
+	// Command is the command that applies the plan (create, update, delete)
+	Command string    `json:"command" yaml:"command"`
+	Profile string    `json:"profile" yaml:"profile"`
+	Created time.Time `json:"created" yaml:"created"`
+	// Options are the command's options, replayed by 'profile apply'
+	Options json.RawMessage `json:"options,omitempty" yaml:"-"`
+	Changes []Change        `json:"changes" yaml:"changes"`
+}
+
+// New returns an empty plan for command on profile, recording options
+func New(command, profile string, options any) (*Plan, error) {
+	encoded, err := json.Marshal(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode plan options: %w", err)
+	}
+	return &Plan{
+		Version: Version,
+		Command: command,
+		Profile: profile,
+		Created: time.Now().UTC().Truncate(time.Second),
+		Options: encoded,
+		Changes: []Change{},
+	}, nil
+}
+
+// Add appends a change
+func (p *Plan) Add(action Action, kind Kind, path, detail string) {
+	p.Changes = append(p.Changes, Change{Action: action, Kind: kind, Path: path, Detail: detail})
+}
+
+// Count returns the number of changes with the action
+func (p *Plan) Count(action Action) int {
+	count := 0
+	for _, change := range p.Changes {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// Same reports whether other plans exactly the same changes
+func (p *Plan) Same(other *Plan) bool {
+	return p.Command == other.Command && p.Profile == other.Profile && reflect.DeepEqual(p.Changes, other.Changes)
+}
+
+// Render writes the plan like 'terraform plan': one line per change marked
+// + (create), ~ (update), or - (delete), followed by a summary
+func (p *Plan) Render(w io.Writer) {
+	if len(p.Changes) == 0 {
+		fmt.Fprintf(w, "No changes. Profile %q is up to date.\n", p.Profile)
+		return
+	}
+
+	fmt.Fprintf(w, "'profile %s' will perform the following actions on profile %q:\n\n", p.Command, p.Profile)
+	kindWidth := 0
+	for _, change := range p.Changes {
+		kindWidth = max(kindWidth, len(change.Kind))
+	}
+	for _, change := range p.Changes {
+		symbol, color := "~", ui.ColorYellow
+		switch change.Action {
+		case Create:
+			symbol, color = "+", ui.ColorGreen
+		case Delete:
+			symbol, color = "-", ui.ColorRed
+		}
+		line := fmt.Sprintf("  %s%s%s %-*s %s", color, symbol, ui.ColorReset, kindWidth, change.Kind, change.Path)
+		if change.Detail != "" {
+			line += fmt.Sprintf("  (%s)", redact.Text(change.Detail))
+		}
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintf(w, "\nPlan: %d to create, %d to update, %d to delete.\n", p.Count(Create), p.Count(Update), p.Count(Delete))
+}
+
+// Save writes the plan as JSON
+func (p *Plan) Save(path string) error {
+	content, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, append(content, '\n'), 0644)
+}
+
+// Load reads a plan saved with Save
+func Load(path string) (*Plan, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p Plan
+	if err := json.Unmarshal(content, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse plan %s: %w", path, err)
+	}
+	if p.Version != Version {
+		return nil, fmt.Errorf("plan %s has version %d (supported: %d)", path, p.Version, Version)
+	}
+	if strings.TrimSpace(p.Command) == "" || p.Profile == "" {
+		return nil, fmt.Errorf("plan %s is missing its command or profile", path)
+	}
+	return &p, nil
+}
@@ -0,0 +1,286 @@
+// Package plugins discovers exec-based plugins: executables named
+// spm-<name> in the plugin directory or on PATH. Each plugin describes what
+// it adds (subcommands, feature definitions, secrets providers) in a JSON
+// handshake, printed when it is run with the --spm-handshake argument:
+//
+//	{
+//	  "protocol": 1,
+//	  "description": "Acme tooling",
+//	  "commands": [{"name": "deploy", "description": "Deploy the workspace"}],
+//	  "features": [{"name": "acme", "env": [{"name": "ACME_HOME", "value": "$WORKSPACE_HOME/.acme"}]}],
+//	  "secrets_providers": [{"name": "acme-vault", "description": "Acme secret store"}]
+//	}
+//
+// Features use the same fields as features.yaml. Handshakes are cached
+// until the executable changes.
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mindmorass/shell-profile-manager/internal/atomicfile"
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+)
+
+// Protocol is the plugin protocol version spm speaks
+const Protocol = 1
+
+// Prefix is the executable name prefix of plugins
+const Prefix = "spm-"
+
+// HandshakeArg is the argument plugins are run with to describe themselves
+const HandshakeArg = "--spm-handshake"
+
+// handshakeTimeout bounds how long a plugin may take to describe itself
+const handshakeTimeout = 5 * time.Second
+
+// Plugin is a discovered plugin and what it registered
+type Plugin struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Handshake
+	// Error is why the plugin could not be loaded ("" when it loaded)
+	Error string `json:"error,omitempty"`
+}
+
+// Handshake is what a plugin prints when run with HandshakeArg
+type Handshake struct {
+	Protocol         int              `json:"protocol"`
+	Description      string           `json:"description,omitempty"`
+	Commands         []Command        `json:"commands,omitempty"`
+	Features         json.RawMessage  `json:"features,omitempty"`
+	SecretsProviders []SecretProvider `json:"secrets_providers,omitempty"`
+}
+
+// Command is a subcommand a plugin handles: 'profile <name> args...' runs
+// 'spm-<plugin> <name> args...'
+type Command struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// SecretProvider is a secrets provider a plugin implements with
+// 'spm-<plugin> secrets resolve|validate <provider> <ref>'
+type SecretProvider struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// cacheEntry is a handshake cached for an executable
+type cacheEntry struct {
+	ModTime   time.Time `json:"mod_time"`
+	Size      int64     `json:"size"`
+	Handshake Handshake `json:"handshake"`
+}
+
+var loaded []Plugin
+
+// Dir returns the plugin directory ($XDG_CONFIG_HOME/spm/plugins)
+func Dir() (string, error) {
+	spmDir, err := config.SpmDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(spmDir, "plugins"), nil
+}
+
+// Load discovers the plugins and performs their handshakes. The result is
+// kept for the rest of the run. Plugins in the plugin directory take
+// precedence over plugins of the same name on PATH.
+func Load() []Plugin {
+	if loaded != nil {
+		return loaded
+	}
+	loaded = []Plugin{}
+
+	cache := readCache()
+	seen := map[string]bool{}
+	for _, path := range discover() {
+		name := strings.TrimPrefix(filepath.Base(path), Prefix)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		plugin := Plugin{Name: name, Path: path}
+		handshake, err := handshakeOf(path, cache)
+		if err != nil {
+			slog.Debug("skipping plugin", "path", path, "error", err)
+			plugin.Error = err.Error()
+		} else {
+			plugin.Handshake = handshake
+		}
+		loaded = append(loaded, plugin)
+	}
+	writeCache(cache)
+	return loaded
+}
+
+// FindCommand returns the plugin that handles a subcommand
+func FindCommand(name string) (Plugin, bool) {
+	for _, plugin := range Load() {
+		if plugin.Error != "" {
+			continue
+		}
+		for _, command := range plugin.Commands {
+			if command.Name == name {
+				return plugin, true
+			}
+		}
+	}
+	return Plugin{}, false
+}
+
+// FindSecretsProvider returns the plugin that implements a secrets provider
+func FindSecretsProvider(name string) (Plugin, bool) {
+	for _, plugin := range Load() {
+		if plugin.Error != "" {
+			continue
+		}
+		for _, provider := range plugin.SecretsProviders {
+			if provider.Name == name {
+				return plugin, true
+			}
+		}
+	}
+	return Plugin{}, false
+}
+
+// Run runs a plugin command with the terminal attached and returns its
+// exit code. The plugin gets SPM_PROTOCOL and SPM_PROFILES_DIR in its
+// environment.
+func Run(plugin Plugin, profilesDir string, args []string) (int, error) {
+	cmd := exec.Command(plugin.Path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = Env(profilesDir)
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 1, fmt.Errorf("failed to run plugin %s: %w", plugin.Name, err)
+	}
+	return 0, nil
+}
+
+// Env returns the environment plugins run with
+func Env(profilesDir string) []string {
+	return append(os.Environ(),
+		fmt.Sprintf("SPM_PROTOCOL=%d", Protocol),
+		"SPM_PROFILES_DIR="+profilesDir,
+	)
+}
+
+// discover returns the plugin executables, plugin directory first
+func discover() []string {
+	var dirs []string
+	if dir, err := Dir(); err == nil {
+		dirs = append(dirs, dir)
+	}
+	dirs = append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+
+	var paths []string
+	for _, dir := range dirs {
+		matches, err := filepath.Glob(filepath.Join(dir, Prefix+"*"))
+		if err != nil {
+			continue
+		}
+		sort.Strings(matches)
+		for _, path := range matches {
+			info, err := os.Stat(path)
+			if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+				continue
+			}
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// handshakeOf returns the plugin's handshake from the cache, or runs the
+// plugin to get it
+func handshakeOf(path string, cache map[string]cacheEntry) (Handshake, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Handshake{}, err
+	}
+	if entry, ok := cache[path]; ok && entry.ModTime.Equal(info.ModTime()) && entry.Size == info.Size() {
+		return entry.Handshake, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path, HandshakeArg)
+	cmd.Env = Env("")
+	output, err := cmd.Output()
+	if err != nil {
+		return Handshake{}, fmt.Errorf("handshake failed: %w", err)
+	}
+
+	var handshake Handshake
+	if err := json.Unmarshal(output, &handshake); err != nil {
+		return Handshake{}, fmt.Errorf("invalid handshake: %w", err)
+	}
+	if handshake.Protocol != Protocol {
+		return Handshake{}, fmt.Errorf("unsupported protocol %d (expected %d)", handshake.Protocol, Protocol)
+	}
+	cache[path] = cacheEntry{ModTime: info.ModTime(), Size: info.Size(), Handshake: handshake}
+	return handshake, nil
+}
+
+func cachePath() (string, error) {
+	cacheDir, err := config.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "plugins.json"), nil
+}
+
+// readCache returns the cached handshakes (empty if there are none)
+func readCache() map[string]cacheEntry {
+	cache := map[string]cacheEntry{}
+	path, err := cachePath()
+	if err != nil {
+		return cache
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(content, &cache); err != nil {
+		slog.Debug("ignoring plugin cache", "path", path, "error", err)
+		return map[string]cacheEntry{}
+	}
+	return cache
+}
+
+// writeCache saves the handshakes; failing to is not an error, plugins are
+// just asked again next time
+func writeCache(cache map[string]cacheEntry) {
+	path, err := cachePath()
+	if err != nil {
+		return
+	}
+	content, err := json.Marshal(cache)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		slog.Debug("not caching plugin handshakes", "error", err)
+		return
+	}
+	if err := atomicfile.WriteFile(path, content, 0644); err != nil {
+		slog.Debug("not caching plugin handshakes", "error", err)
+	}
+}
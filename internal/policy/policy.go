@@ -0,0 +1,92 @@
+// Package policy reads the team policy profiles are checked against: a
+// YAML file, usually shared through a git repository named by policy= in
+// the config, declaring what every profile must and must not contain.
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the policy file at the root of a policy repository
+const FileName = "policy.yaml"
+
+// Rules of a policy, as reported in violations
+const (
+	RuleRequiredEnv       = "required_env"
+	RuleForbiddenPattern  = "forbidden_pattern"
+	RuleRequiredGitignore = "required_gitignore"
+	RuleRequiredFeature   = "required_feature"
+)
+
+// Policy declares what every profile must and must not contain
+type Policy struct {
+	// Name identifies the policy in reports
+	Name string `yaml:"name,omitempty"`
+	// RequiredEnv are variables the .envrc must export
+	RequiredEnv []string `yaml:"required_env,omitempty"`
+	// ForbiddenPatterns must not match any line of the files they name
+	ForbiddenPatterns []ForbiddenPattern `yaml:"forbidden_patterns,omitempty"`
+	// RequiredGitignore are patterns the .gitignore must ignore
+	RequiredGitignore []string `yaml:"required_gitignore,omitempty"`
+	// RequiredFeatures must be active in every profile
+	RequiredFeatures []string `yaml:"required_features,omitempty"`
+}
+
+// ForbiddenPattern is a regular expression no line of a profile file may
+// match
+type ForbiddenPattern struct {
+	Pattern string `yaml:"pattern"`
+	// Files are globs relative to the profile (default: .envrc)
+	Files []string `yaml:"files,omitempty"`
+	// Message explains the rule in reports
+	Message string `yaml:"message,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// Violation is a way a profile does not comply with a policy
+type Violation struct {
+	Rule    string `json:"rule" yaml:"rule"`
+	Message string `json:"message" yaml:"message"`
+}
+
+func (v Violation) String() string {
+	return v.Message
+}
+
+// Load reads a policy file
+func Load(path string) (*Policy, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy: %w", err)
+	}
+	var p Policy
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy %s: %w", path, err)
+	}
+	for i := range p.ForbiddenPatterns {
+		fp := &p.ForbiddenPatterns[i]
+		if fp.Pattern == "" {
+			return nil, fmt.Errorf("policy %s: forbidden pattern without a pattern", path)
+		}
+		if fp.re, err = regexp.Compile(fp.Pattern); err != nil {
+			return nil, fmt.Errorf("policy %s: invalid forbidden pattern %q: %w", path, fp.Pattern, err)
+		}
+		if len(fp.Files) == 0 {
+			fp.Files = []string{".envrc"}
+		}
+	}
+	return &p, nil
+}
+
+// Match reports whether a line matches the forbidden pattern
+func (fp ForbiddenPattern) Match(line string) bool {
+	return fp.re != nil && fp.re.MatchString(line)
+}
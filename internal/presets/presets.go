@@ -0,0 +1,142 @@
+// Package presets reads the named bundles an organization publishes so a
+// profile can be created in one step (profile create --preset acme-aws):
+// a template, the template repository commit it is pinned to, and default
+// profile.yaml values such as the feature selection, tags, and secrets
+// provider.
+//
+// Presets are read from YAML files holding a presets: list, in order from:
+//
+//	$XDG_CONFIG_HOME/spm/presets.d/*.yaml
+//	presets.yaml next to the team policy (policy= in the config)
+//	presets.yaml at the root of each template repository
+//
+// The first preset with a name wins, so a local file can replace one the
+// organization publishes.
+package presets
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+)
+
+// FileName is the presets file of a policy or template repository
+const FileName = "presets.yaml"
+
+// Preset is a named bundle of create options
+type Preset struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	// Template is the template profiles are rendered from
+	Template string `yaml:"template,omitempty" json:"template,omitempty"`
+	// TemplateRepo pins the template repository the template comes from
+	TemplateRepo *RepoPin `yaml:"template_repo,omitempty" json:"template_repo,omitempty"`
+	// Manifest holds the default profile.yaml values (features, tags,
+	// owner, vars, extends, secrets, tools, ...) of new profiles
+	Manifest *manifest.Manifest `yaml:"manifest,omitempty" json:"manifest,omitempty"`
+	// Source is the file the preset was read from
+	Source string `yaml:"-" json:"source"`
+}
+
+// RepoPin is the template repository and commit a preset was written for
+type RepoPin struct {
+	Name string `yaml:"name" json:"name"`
+	URL  string `yaml:"url" json:"url"`
+	// Ref is the branch or tag the repository is registered with
+	Ref string `yaml:"ref,omitempty" json:"ref,omitempty"`
+	// Commit is the (possibly abbreviated) commit the template is used at
+	// ("" uses the commit the repository is locked at)
+	Commit string `yaml:"commit,omitempty" json:"commit,omitempty"`
+}
+
+type file struct {
+	Presets []Preset `yaml:"presets"`
+}
+
+// UserDir returns the directory holding user presets
+// ($XDG_CONFIG_HOME/spm/presets.d)
+func UserDir() (string, error) {
+	spmDir, err := config.SpmDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(spmDir, "presets.d"), nil
+}
+
+// UserFiles returns the preset files of the user in file name order
+func UserFiles() ([]string, error) {
+	dir, err := UserDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Load reads the presets of files in lookup order. Files that do not exist
+// are skipped; a preset with the name of an earlier one is ignored.
+func Load(paths []string) ([]Preset, error) {
+	var all []Preset
+	seen := map[string]bool{}
+	for _, path := range paths {
+		presets, err := parse(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, preset := range presets {
+			if !seen[preset.Name] {
+				seen[preset.Name] = true
+				all = append(all, preset)
+			}
+		}
+	}
+	return all, nil
+}
+
+// Find returns the preset with a name
+func Find(all []Preset, name string) (Preset, bool) {
+	for _, preset := range all {
+		if preset.Name == name {
+			return preset, true
+		}
+	}
+	return Preset{}, false
+}
+
+func parse(path string) ([]Preset, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var f file
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for i := range f.Presets {
+		preset := &f.Presets[i]
+		if preset.Name == "" {
+			return nil, fmt.Errorf("invalid preset in %s: name is required", path)
+		}
+		if pin := preset.TemplateRepo; pin != nil && (pin.Name == "" || pin.URL == "") {
+			return nil, fmt.Errorf("invalid preset %q in %s: template_repo needs a name and url", preset.Name, path)
+		}
+		preset.Source = path
+	}
+	return f.Presets, nil
+}
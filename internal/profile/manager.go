@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/commands"
+	"github.com/mindmorass/shell-profile-manager/internal/direnv"
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+	"github.com/mindmorass/shell-profile-manager/internal/ui"
 )
 
 type Manager struct {
@@ -18,11 +22,36 @@ func NewManager(profilesDir string) *Manager {
 	}
 }
 
+// Info is the current workspace profile as shown with --output json|yaml
+type Info struct {
+	Active        bool              `json:"active" yaml:"active"`
+	Name          string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Home          string            `json:"home,omitempty" yaml:"home,omitempty"`
+	GitConfig     string            `json:"git_config,omitempty" yaml:"git_config,omitempty"`
+	GitName       string            `json:"git_name,omitempty" yaml:"git_name,omitempty"`
+	GitEmail      string            `json:"git_email,omitempty" yaml:"git_email,omitempty"`
+	DefaultBranch string            `json:"default_branch,omitempty" yaml:"default_branch,omitempty"`
+	Description   string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Tags          []string          `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Owner         string            `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Template      string            `json:"template,omitempty" yaml:"template,omitempty"`
+	Extends       string            `json:"extends,omitempty" yaml:"extends,omitempty"`
+	Remotes       []manifest.Remote `json:"remotes,omitempty" yaml:"remotes,omitempty"`
+	Env           map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	Path          []string          `json:"path,omitempty" yaml:"path,omitempty"`
+	// Profiles lists the available profiles when none is active
+	Profiles []string `json:"profiles,omitempty" yaml:"profiles,omitempty"`
+}
+
 // ShowInfo displays information about the current workspace profile
 func (m *Manager) ShowInfo() error {
 	profileName := os.Getenv("WORKSPACE_PROFILE")
 	profileHome := os.Getenv("WORKSPACE_HOME")
 
+	if ui.Structured() {
+		return ui.Render(m.info(profileName, profileHome))
+	}
+
 	if profileName == "" {
 		fmt.Println("No workspace profile active")
 		fmt.Println()
@@ -39,6 +68,26 @@ func (m *Manager) ShowInfo() error {
 	fmt.Println()
 	fmt.Printf("Profile Name:    %s\n", profileName)
 	fmt.Printf("Profile Home:    %s\n", profileHome)
+	if m, err := manifest.Load(profileHome); err == nil {
+		if m.Description != "" {
+			fmt.Printf("Description:     %s\n", m.Description)
+		}
+		if len(m.Tags) > 0 {
+			fmt.Printf("Tags:            %s\n", strings.Join(m.Tags, ", "))
+		}
+		if m.Owner != "" {
+			fmt.Printf("Owner:           %s\n", m.Owner)
+		}
+		if m.Template != "" {
+			fmt.Printf("Template:        %s\n", m.Template)
+		}
+		if m.Extends != "" {
+			fmt.Printf("Extends:         %s\n", m.Extends)
+		}
+		for _, remote := range m.Remotes {
+			fmt.Printf("Remote:          %s %s\n", remote.Name, remote.URL)
+		}
+	}
 	fmt.Println()
 
 	// Git Configuration
@@ -94,21 +143,59 @@ func (m *Manager) ShowInfo() error {
 	return nil
 }
 
+// info collects what ShowInfo prints for structured output
+func (m *Manager) info(profileName, profileHome string) Info {
+	if profileName == "" {
+		return Info{Profiles: m.profileNames()}
+	}
+
+	info := Info{
+		Active:    true,
+		Name:      profileName,
+		Home:      profileHome,
+		GitConfig: os.Getenv("GIT_CONFIG_GLOBAL"),
+		Env:       map[string]string{},
+	}
+	if info.GitConfig != "" {
+		if _, err := os.Stat(info.GitConfig); err == nil {
+			info.GitName = getGitConfig(info.GitConfig, "user.name")
+			info.GitEmail = getGitConfig(info.GitConfig, "user.email")
+			info.DefaultBranch = getGitConfig(info.GitConfig, "init.defaultBranch")
+		}
+	}
+	if m, err := manifest.Load(profileHome); err == nil {
+		info.Description, info.Tags, info.Owner = m.Description, m.Tags, m.Owner
+		info.Template, info.Extends, info.Remotes = m.Template, m.Extends, m.Remotes
+	}
+	for _, env := range os.Environ() {
+		if name, value, ok := strings.Cut(env, "="); ok && strings.HasPrefix(name, "WORKSPACE_") {
+			info.Env[name] = value
+		}
+	}
+	for _, p := range strings.Split(os.Getenv("PATH"), ":") {
+		if strings.Contains(p, profileHome) {
+			info.Path = append(info.Path, p)
+		}
+	}
+	return info
+}
+
+// profileNames returns the available profiles, including those in other
+// profile roots (empty if none can be read)
+func (m *Manager) profileNames() []string {
+	names, _ := commands.ListProfileNames(m.profilesDir)
+	return names
+}
+
 // listProfiles lists all available profiles
 func (m *Manager) listProfiles() error {
-	entries, err := os.ReadDir(m.profilesDir)
+	names, err := commands.ListProfileNames(m.profilesDir)
 	if err != nil {
-		return fmt.Errorf("failed to read profiles directory: %w", err)
+		return err
 	}
 
-	for _, entry := range entries {
-		if entry.IsDir() && entry.Name() != ".git" {
-			profilePath := filepath.Join(m.profilesDir, entry.Name())
-			envrcPath := filepath.Join(profilePath, ".envrc")
-			if _, err := os.Stat(envrcPath); err == nil {
-				fmt.Printf("  - %s\n", entry.Name())
-			}
-		}
+	for _, name := range names {
+		fmt.Printf("  - %s\n", name)
 	}
 
 	return nil
@@ -124,6 +211,31 @@ func getGitConfig(configFile, key string) string {
 	return strings.TrimSpace(string(output))
 }
 
+// DirenvStatus is the state of direnv as shown with --output json|yaml
+type DirenvStatus struct {
+	Installed bool   `json:"installed" yaml:"installed"`
+	Version   string `json:"version,omitempty" yaml:"version,omitempty"`
+	// LoadedRC is the .envrc direnv has loaded into the current shell
+	LoadedRC      string `json:"loaded_rc,omitempty" yaml:"loaded_rc,omitempty"`
+	ActiveProfile string `json:"active_profile,omitempty" yaml:"active_profile,omitempty"`
+	// Profiles and Alerts are filled in by the status command
+	Profiles []direnv.ProfileState `json:"profiles,omitempty" yaml:"profiles,omitempty"`
+	Alerts   []commands.Alert      `json:"alerts" yaml:"alerts"`
+}
+
+// CurrentDirenvStatus returns the state of direnv for structured output
+func CurrentDirenvStatus() DirenvStatus {
+	status := DirenvStatus{
+		LoadedRC:      os.Getenv("DIRENV_FILE"),
+		ActiveProfile: os.Getenv("WORKSPACE_PROFILE"),
+	}
+	if version, err := exec.Command("direnv", "version").Output(); err == nil {
+		status.Installed = true
+		status.Version = strings.TrimSpace(string(version))
+	}
+	return status
+}
+
 // ShowDirenvStatus shows the status of direnv
 func ShowDirenvStatus() error {
 	// Check if direnv is installed
@@ -138,6 +250,7 @@ func ShowDirenvStatus() error {
 		fmt.Println("Then hook it to your shell:")
 		fmt.Println("  bash:   eval \"$(direnv hook bash)\"")
 		fmt.Println("  zsh:    eval \"$(direnv hook zsh)\"")
+		fmt.Println("  fish:   direnv hook fish | source")
 		return nil
 	}
 
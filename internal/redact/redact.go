@@ -0,0 +1,111 @@
+// Package redact masks secrets in what spm prints and logs: the values of
+// variables whose names mark them as sensitive (*_TOKEN, *_SECRET,
+// AWS_SECRET_ACCESS_KEY, ...) and key material such as private keys and
+// well-known token formats. Masking is on by default; the global
+// --show-secrets flag turns it off for terminal output.
+package redact
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/secretscan"
+)
+
+// Mask replaces a secret value
+const Mask = "********"
+
+var showSecrets bool
+
+// SetShowSecrets turns masking off (--show-secrets)
+func SetShowSecrets(value bool) {
+	showSecrets = value
+}
+
+// ShowSecrets reports whether secrets are printed as they are
+func ShowSecrets() bool {
+	return showSecrets
+}
+
+// sensitiveWords in a variable name mark its value as a secret
+var sensitiveWords = []string{"TOKEN", "SECRET", "PASSWORD", "PASSWD", "PASSPHRASE", "PRIVATE_KEY", "API_KEY", "APIKEY"}
+
+// pathSuffixes end the names of variables holding the location of a
+// secret (AWS_SHARED_CREDENTIALS_FILE, VAULT_TOKEN_PATH), not the secret
+var pathSuffixes = []string{"_FILE", "_PATH", "_DIR", "_HELPER"}
+
+var (
+	// assignment matches NAME=value, as in .envrc lines and sh exports
+	assignment = regexp.MustCompile(`\b([A-Za-z_][A-Za-z0-9_]*)=("(?:[^"\\]|\\.)*"|'[^']*'|[^\s;,\])]+)`)
+	// fishSet matches set -gx NAME value, as printed for fish
+	fishSet = regexp.MustCompile(`(\bset\s+(?:-[a-zA-Z]+\s+)*)([A-Za-z_][A-Za-z0-9_]*)\s+('[^']*'|"[^"]*"|\S+)`)
+	// privateKey matches a PEM private key block
+	privateKey = regexp.MustCompile(`(?s)-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----.*?-----END (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----`)
+)
+
+// Sensitive reports whether the value of a variable is a secret by its
+// name
+func Sensitive(name string) bool {
+	name = strings.ToUpper(name)
+	for _, suffix := range pathSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return false
+		}
+	}
+	for _, word := range sensitiveWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// Value returns the value of a variable for printing: masked when its name
+// marks it as a secret, otherwise with any key material masked. Values that
+// only reference other variables or commands ($TOKEN, $(pass show ...)) are
+// no secrets themselves.
+func Value(name, value string) string {
+	if showSecrets || value == "" || isReference(value) {
+		return value
+	}
+	if Sensitive(name) {
+		return Mask
+	}
+	return Always(value)
+}
+
+// Text masks the secrets in text for printing: the values assigned to
+// sensitive variables and key material
+func Text(text string) string {
+	if showSecrets {
+		return text
+	}
+	return Always(text)
+}
+
+// Always masks the secrets in text regardless of --show-secrets, for
+// output that outlives the command such as the log file
+func Always(text string) string {
+	text = privateKey.ReplaceAllString(text, Mask)
+	text = secretscan.Redact(text, Mask)
+	text = assignment.ReplaceAllStringFunc(text, func(match string) string {
+		parts := assignment.FindStringSubmatch(match)
+		if !Sensitive(parts[1]) || isReference(parts[2]) {
+			return match
+		}
+		return parts[1] + "=" + Mask
+	})
+	return fishSet.ReplaceAllStringFunc(text, func(match string) string {
+		parts := fishSet.FindStringSubmatch(match)
+		if !Sensitive(parts[2]) || isReference(parts[3]) {
+			return match
+		}
+		return parts[1] + parts[2] + " " + Mask
+	})
+}
+
+// isReference reports whether a (possibly quoted) value starts with a
+// variable or command reference
+func isReference(value string) bool {
+	return strings.HasPrefix(strings.TrimLeft(value, `"`), "$")
+}
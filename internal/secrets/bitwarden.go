@@ -0,0 +1,108 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+)
+
+// secretIDPattern matches Bitwarden Secrets Manager secret IDs (UUIDs)
+var secretIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// bitwarden resolves secrets with 'bws secret get'. References are secret
+// IDs, e.g. be8e0ad8-d545-4017-a55a-b02f014d4158. The machine account
+// token comes from BWS_ACCESS_TOKEN.
+type bitwarden struct {
+	cfg manifest.BitwardenConfig
+}
+
+func newBitwarden(cfg *manifest.Secrets) Provider {
+	b := &bitwarden{}
+	if cfg.Bitwarden != nil {
+		b.cfg = *cfg.Bitwarden
+	}
+	return b
+}
+
+func (b *bitwarden) Name() string {
+	return "bws"
+}
+
+func (b *bitwarden) ValidateRef(ref string) error {
+	if !secretIDPattern.MatchString(ref) {
+		return fmt.Errorf("invalid bws reference %q (expected a secret ID such as be8e0ad8-d545-4017-a55a-b02f014d4158)", ref)
+	}
+	return nil
+}
+
+func (b *bitwarden) Render(vars []manifest.SecretVar) string {
+	var s strings.Builder
+	s.WriteString("# Secrets resolved from Bitwarden Secrets Manager when the profile loads\n")
+	if b.cfg.ServerURL != "" {
+		fmt.Fprintf(&s, "export BWS_SERVER_URL=%s\n", shellQuote(b.cfg.ServerURL))
+	}
+	if len(vars) == 0 {
+		return s.String()
+	}
+
+	s.WriteString("if ! has bws || ! has jq; then\n")
+	s.WriteString("  log_error \"bws and jq are required; secrets were not loaded\"\n")
+	s.WriteString("elif [[ -z \"${BWS_ACCESS_TOKEN:-}\" ]]; then\n")
+	s.WriteString("  log_error \"BWS_ACCESS_TOKEN is not set (export it in your shell); secrets were not loaded\"\n")
+	s.WriteString("else\n")
+	for _, sv := range vars {
+		if err := b.ValidateRef(sv.Ref); err != nil {
+			fmt.Fprintf(&s, "  # %s: %v\n", sv.Name, err)
+			continue
+		}
+		fmt.Fprintf(&s, "  export %s=\"$(bws secret get %s --output json | jq -r .value)\"\n", sv.Name, shellQuote(sv.Ref))
+	}
+	s.WriteString("fi\n")
+	return s.String()
+}
+
+func (b *bitwarden) Check(ref string) error {
+	_, err := b.Resolve(ref)
+	return err
+}
+
+func (b *bitwarden) Resolve(ref string) (string, error) {
+	if err := b.ValidateRef(ref); err != nil {
+		return "", err
+	}
+	if _, err := exec.LookPath("bws"); err != nil {
+		return "", fmt.Errorf("bws CLI not found")
+	}
+	if os.Getenv("BWS_ACCESS_TOKEN") == "" {
+		return "", fmt.Errorf("BWS_ACCESS_TOKEN is not set")
+	}
+
+	cmd := exec.Command("bws", "secret", "get", ref, "--output", "json")
+	cmd.Env = os.Environ()
+	if b.cfg.ServerURL != "" {
+		cmd.Env = append(cmd.Env, "BWS_SERVER_URL="+b.cfg.ServerURL)
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+
+	var secret struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(output, &secret); err != nil {
+		return "", fmt.Errorf("unexpected bws output: %w", err)
+	}
+	if secret.Value == "" {
+		return "", fmt.Errorf("secret has an empty value")
+	}
+	return secret.Value, nil
+}
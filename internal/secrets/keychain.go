@@ -0,0 +1,102 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode"
+
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+)
+
+// DefaultKeychainService is used when the manifest does not set a service
+const DefaultKeychainService = "profile"
+
+// keychain resolves secrets with 'security find-generic-password' on macOS.
+// References are account names within the profile's service, so values of
+// different profiles never collide.
+type keychain struct {
+	service string
+}
+
+func newKeychain(cfg *manifest.Secrets) Provider {
+	k := &keychain{service: DefaultKeychainService}
+	if cfg.Keychain != nil && cfg.Keychain.Service != "" {
+		k.service = cfg.Keychain.Service
+	}
+	return k
+}
+
+func (k *keychain) Name() string {
+	return "keychain"
+}
+
+func (k *keychain) ValidateRef(ref string) error {
+	if ref == "" || strings.IndexFunc(ref, unicode.IsControl) != -1 {
+		return fmt.Errorf("invalid keychain reference %q (expected an account name)", ref)
+	}
+	return nil
+}
+
+func (k *keychain) Render(vars []manifest.SecretVar) string {
+	var b strings.Builder
+	b.WriteString("# Secrets resolved from the macOS Keychain when the profile loads\n")
+	if len(vars) == 0 {
+		return b.String()
+	}
+
+	b.WriteString("if ! has security; then\n")
+	b.WriteString("  log_error \"macOS 'security' command not found; secrets were not loaded\"\n")
+	b.WriteString("else\n")
+	for _, sv := range vars {
+		fmt.Fprintf(&b, "  export %s=\"$(security find-generic-password -s %s -a %s -w)\"\n", sv.Name, shellQuote(k.service), shellQuote(sv.Ref))
+	}
+	b.WriteString("fi\n")
+	return b.String()
+}
+
+func (k *keychain) Check(ref string) error {
+	if _, err := exec.LookPath("security"); err != nil {
+		return fmt.Errorf("macOS 'security' command not found")
+	}
+
+	// Without -w only the item attributes are printed, not the value
+	cmd := exec.Command("security", "find-generic-password", "-s", k.service, "-a", ref)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func (k *keychain) Resolve(ref string) (string, error) {
+	if _, err := exec.LookPath("security"); err != nil {
+		return "", fmt.Errorf("macOS 'security' command not found")
+	}
+
+	output, err := exec.Command("security", "find-generic-password", "-s", k.service, "-a", ref, "-w").Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSuffix(string(output), "\n"), nil
+}
+
+// Store adds or updates the item. With -w as the last argument security
+// prompts for the value itself, so it never appears in arguments or files.
+func (k *keychain) Store(ref string) error {
+	if _, err := exec.LookPath("security"); err != nil {
+		return fmt.Errorf("macOS 'security' command not found")
+	}
+
+	cmd := exec.Command("security", "add-generic-password", "-U", "-s", k.service, "-a", ref, "-l", k.service+" "+ref, "-w")
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("security add-generic-password failed: %w", err)
+	}
+	return nil
+}
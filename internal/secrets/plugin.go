@@ -0,0 +1,87 @@
+package secrets
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+	"github.com/mindmorass/shell-profile-manager/internal/plugins"
+)
+
+// plugin resolves secrets through a plugin executable that registered the
+// provider in its handshake:
+//
+//	spm-<plugin> secrets validate <provider> <ref>   exits non-zero for a bad ref
+//	spm-<plugin> secrets resolve <provider> <ref>    prints the value
+type plugin struct {
+	name string
+	path string
+}
+
+// pluginProvider returns the plugin provider with the given name
+func pluginProvider(name string) (Provider, bool) {
+	p, ok := plugins.FindSecretsProvider(name)
+	if !ok {
+		return nil, false
+	}
+	return &plugin{name: name, path: p.Path}, true
+}
+
+func (p *plugin) Name() string {
+	return p.name
+}
+
+func (p *plugin) ValidateRef(ref string) error {
+	if ref == "" {
+		return fmt.Errorf("invalid %s reference: empty", p.name)
+	}
+	if _, err := p.run("validate", ref); err != nil {
+		return fmt.Errorf("invalid %s reference %q: %w", p.name, ref, err)
+	}
+	return nil
+}
+
+func (p *plugin) Render(vars []manifest.SecretVar) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Secrets resolved by the %s plugin provider when the profile loads\n", p.name)
+	if len(vars) == 0 {
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "if [ ! -x %s ]; then\n", shellQuote(p.path))
+	fmt.Fprintf(&b, "  log_error \"plugin %s not found; secrets were not loaded\"\n", p.path)
+	b.WriteString("else\n")
+	for _, sv := range vars {
+		fmt.Fprintf(&b, "  export %s=\"$(%s secrets resolve %s %s)\"\n", sv.Name, shellQuote(p.path), shellQuote(p.name), shellQuote(sv.Ref))
+	}
+	b.WriteString("fi\n")
+	return b.String()
+}
+
+func (p *plugin) Check(ref string) error {
+	_, err := p.Resolve(ref)
+	return err
+}
+
+func (p *plugin) Resolve(ref string) (string, error) {
+	output, err := p.run("resolve", ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(output, "\n"), nil
+}
+
+// run runs a secrets action of the plugin and returns its output
+func (p *plugin) run(action, ref string) (string, error) {
+	cmd := exec.Command(p.path, "secrets", action, p.name, ref)
+	cmd.Env = plugins.Env("")
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return string(output), nil
+}
@@ -0,0 +1,86 @@
+// Package secrets renders .envrc snippets that resolve secret environment
+// variables from external secret managers when a profile loads, so secret
+// values never have to be written into the profile itself.
+package secrets
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+	"github.com/mindmorass/shell-profile-manager/internal/plugins"
+)
+
+// Provider resolves secret references for one secret manager
+type Provider interface {
+	// Name is the provider identifier used in profile.yaml
+	Name() string
+	// ValidateRef reports whether ref is a valid reference for the provider
+	ValidateRef(ref string) error
+	// Render returns the .envrc lines that export vars at load time
+	Render(vars []manifest.SecretVar) string
+	// Check resolves a reference now and reports an error if it fails
+	Check(ref string) error
+}
+
+// Storer is implemented by providers that keep secret values themselves,
+// so 'profile secrets store' can save a value under a reference
+type Storer interface {
+	// Store prompts for the value on the terminal and saves it under ref
+	Store(ref string) error
+}
+
+// Resolver is implemented by providers that can return a secret's value,
+// for files that must hold the value itself (e.g. .netrc)
+type Resolver interface {
+	// Resolve returns the current value of ref
+	Resolve(ref string) (string, error)
+}
+
+// constructors maps provider names to their constructors
+var constructors = map[string]func(cfg *manifest.Secrets) Provider{
+	"vault":    newVault,
+	"bws":      newBitwarden,
+	"keychain": newKeychain,
+	"wincred":  newWinCred,
+}
+
+// Names returns the supported provider names: the built-in providers and
+// those registered by plugins
+func Names() []string {
+	var names []string
+	for name := range constructors {
+		names = append(names, name)
+	}
+	for _, p := range plugins.Load() {
+		for _, provider := range p.SecretsProviders {
+			if _, builtin := constructors[provider.Name]; !builtin && p.Error == "" {
+				names = append(names, provider.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return slices.Compact(names)
+}
+
+// ForConfig returns the provider configured for a profile
+func ForConfig(cfg *manifest.Secrets) (Provider, error) {
+	if cfg == nil || cfg.Provider == "" {
+		return nil, fmt.Errorf("no secrets provider configured")
+	}
+	newProvider, ok := constructors[cfg.Provider]
+	if !ok {
+		if provider, ok := pluginProvider(cfg.Provider); ok {
+			return provider, nil
+		}
+		return nil, fmt.Errorf("unknown secrets provider %q (supported: %s)", cfg.Provider, strings.Join(Names(), ", "))
+	}
+	return newProvider(cfg), nil
+}
+
+// shellQuote quotes a value for safe use in .envrc
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,119 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+)
+
+// vault resolves secrets with 'vault kv get'. References have the form
+// <path>#<field>, e.g. secret/myapp/db#password.
+type vault struct {
+	cfg manifest.VaultConfig
+}
+
+func newVault(cfg *manifest.Secrets) Provider {
+	v := &vault{}
+	if cfg.Vault != nil {
+		v.cfg = *cfg.Vault
+	}
+	return v
+}
+
+func (v *vault) Name() string {
+	return "vault"
+}
+
+func (v *vault) ValidateRef(ref string) error {
+	_, _, err := splitVaultRef(ref)
+	return err
+}
+
+func (v *vault) Render(vars []manifest.SecretVar) string {
+	var b strings.Builder
+	b.WriteString("# Secrets resolved from HashiCorp Vault when the profile loads\n")
+	if v.cfg.Address != "" {
+		fmt.Fprintf(&b, "export VAULT_ADDR=%s\n", shellQuote(v.cfg.Address))
+	}
+	if v.cfg.Namespace != "" {
+		fmt.Fprintf(&b, "export VAULT_NAMESPACE=%s\n", shellQuote(v.cfg.Namespace))
+	}
+	if len(vars) == 0 {
+		return b.String()
+	}
+
+	b.WriteString("if ! has vault; then\n")
+	b.WriteString("  log_error \"vault CLI not found; secrets were not loaded\"\n")
+	b.WriteString("elif ! vault token lookup >/dev/null 2>&1; then\n")
+	fmt.Fprintf(&b, "  log_error %s\n", shellQuote("Vault login required; run: "+v.loginCommand()))
+	b.WriteString("else\n")
+	for _, sv := range vars {
+		path, field, err := splitVaultRef(sv.Ref)
+		if err != nil {
+			fmt.Fprintf(&b, "  # %s: %v\n", sv.Name, err)
+			continue
+		}
+		fmt.Fprintf(&b, "  export %s=\"$(vault kv get -field=%s %s)\"\n", sv.Name, shellQuote(field), shellQuote(path))
+	}
+	b.WriteString("fi\n")
+	return b.String()
+}
+
+func (v *vault) Check(ref string) error {
+	_, err := v.Resolve(ref)
+	return err
+}
+
+func (v *vault) Resolve(ref string) (string, error) {
+	path, field, err := splitVaultRef(ref)
+	if err != nil {
+		return "", err
+	}
+	if _, err := exec.LookPath("vault"); err != nil {
+		return "", fmt.Errorf("vault CLI not found")
+	}
+
+	cmd := exec.Command("vault", "kv", "get", "-field="+field, path)
+	cmd.Env = os.Environ()
+	if v.cfg.Address != "" {
+		cmd.Env = append(cmd.Env, "VAULT_ADDR="+v.cfg.Address)
+	}
+	if v.cfg.Namespace != "" {
+		cmd.Env = append(cmd.Env, "VAULT_NAMESPACE="+v.cfg.Namespace)
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSuffix(string(output), "\n"), nil
+}
+
+// loginCommand returns the command users should run to authenticate
+func (v *vault) loginCommand() string {
+	method := v.cfg.AuthMethod
+	if method == "" && v.cfg.Role != "" {
+		method = "oidc"
+	}
+	cmd := "vault login"
+	if method != "" {
+		cmd += " -method=" + method
+	}
+	if v.cfg.Role != "" {
+		cmd += " role=" + v.cfg.Role
+	}
+	return cmd
+}
+
+func splitVaultRef(ref string) (string, string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || field == "" {
+		return "", "", fmt.Errorf("invalid vault reference %q (expected <path>#<field>)", ref)
+	}
+	return path, field, nil
+}
@@ -0,0 +1,100 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/mindmorass/shell-profile-manager/internal/manifest"
+)
+
+// winCred resolves secrets from the Windows Credential Manager. References
+// are names within the profile's service; each is stored as the generic
+// credential <service>/<ref>. Windows has no command that prints a stored
+// secret, so .envrc reads them with 'profile secrets read-credential'.
+type winCred struct {
+	service string
+}
+
+func newWinCred(cfg *manifest.Secrets) Provider {
+	w := &winCred{service: DefaultKeychainService}
+	if cfg.WinCred != nil && cfg.WinCred.Service != "" {
+		w.service = cfg.WinCred.Service
+	}
+	return w
+}
+
+func (w *winCred) Name() string {
+	return "wincred"
+}
+
+func (w *winCred) ValidateRef(ref string) error {
+	if ref == "" || strings.IndexFunc(ref, unicode.IsControl) != -1 {
+		return fmt.Errorf("invalid wincred reference %q (expected a credential name)", ref)
+	}
+	return nil
+}
+
+func (w *winCred) Render(vars []manifest.SecretVar) string {
+	var b strings.Builder
+	b.WriteString("# Secrets resolved from Windows Credential Manager when the profile loads\n")
+	if len(vars) == 0 {
+		return b.String()
+	}
+
+	b.WriteString("if ! has profile; then\n")
+	b.WriteString("  log_error \"profile command not found; secrets were not loaded\"\n")
+	b.WriteString("else\n")
+	for _, sv := range vars {
+		fmt.Fprintf(&b, "  export %s=\"$(profile secrets read-credential %s)\"\n", sv.Name, shellQuote(w.target(sv.Ref)))
+	}
+	b.WriteString("fi\n")
+	return b.String()
+}
+
+func (w *winCred) Check(ref string) error {
+	_, err := w.Resolve(ref)
+	return err
+}
+
+func (w *winCred) Resolve(ref string) (string, error) {
+	return ReadCredential(w.target(ref))
+}
+
+// Store adds or updates the generic credential. cmdkey prompts for the
+// value itself when /pass is given without one.
+func (w *winCred) Store(ref string) error {
+	if _, err := exec.LookPath("cmdkey"); err != nil {
+		return fmt.Errorf("cmdkey not found (Windows Credential Manager is only available on Windows)")
+	}
+
+	cmd := exec.Command("cmdkey", "/generic:"+w.target(ref), "/user:"+ref, "/pass")
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("cmdkey failed: %w", err)
+	}
+	return nil
+}
+
+// target returns the credential name for a reference
+func (w *winCred) target(ref string) string {
+	return w.service + "/" + ref
+}
+
+// decodeCredentialBlob returns the secret stored in a credential. cmdkey
+// and most Windows tools store UTF-16LE; others store UTF-8 bytes.
+func decodeCredentialBlob(blob []byte) string {
+	if len(blob)%2 != 0 || !strings.ContainsRune(string(blob), 0) {
+		return string(blob)
+	}
+	units := make([]uint16, len(blob)/2)
+	for i := range units {
+		units[i] = uint16(blob[2*i]) | uint16(blob[2*i+1])<<8
+	}
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package secrets
+
+import "fmt"
+
+// ReadCredential returns the secret of the generic credential target
+func ReadCredential(target string) (string, error) {
+	return "", fmt.Errorf("Windows Credential Manager is only available on Windows")
+}
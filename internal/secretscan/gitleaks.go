@@ -0,0 +1,203 @@
+package secretscan
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ConfigFileName is the gitleaks configuration a repository keeps at its
+// root, which gitleaks loads by default
+const ConfigFileName = ".gitleaks.toml"
+
+// IgnoreFileName lists the fingerprints of accepted findings at the root of
+// a repository, as gitleaks reads them
+const IgnoreFileName = ".gitleaksignore"
+
+// gitleaksConfig is the part of the gitleaks configuration format spm
+// understands (https://github.com/gitleaks/gitleaks#configuration)
+type gitleaksConfig struct {
+	Extend struct {
+		Path          string   `toml:"path"`
+		UseDefault    bool     `toml:"useDefault"`
+		DisabledRules []string `toml:"disabledRules"`
+	} `toml:"extend"`
+	Rules []struct {
+		ID          string              `toml:"id"`
+		Description string              `toml:"description"`
+		Regex       string              `toml:"regex"`
+		SecretGroup int                 `toml:"secretGroup"`
+		Entropy     float64             `toml:"entropy"`
+		Path        string              `toml:"path"`
+		Keywords    []string            `toml:"keywords"`
+		Allowlist   *gitleaksAllowlist  `toml:"allowlist"`
+		Allowlists  []gitleaksAllowlist `toml:"allowlists"`
+	} `toml:"rules"`
+	Allowlist  *gitleaksAllowlist  `toml:"allowlist"`
+	Allowlists []gitleaksAllowlist `toml:"allowlists"`
+}
+
+type gitleaksAllowlist struct {
+	Condition   string   `toml:"condition"`
+	Commits     []string `toml:"commits"`
+	Paths       []string `toml:"paths"`
+	Regexes     []string `toml:"regexes"`
+	RegexTarget string   `toml:"regexTarget"`
+	StopWords   []string `toml:"stopwords"`
+}
+
+// LoadConfig reads a gitleaks configuration file into the scanner. As with
+// gitleaks, its rules replace the built-in ones unless it extends the
+// default configuration ([extend] useDefault = true); rules with the ID of
+// a rule already loaded replace it. A configuration extending another file
+// ([extend] path) loads that first.
+func (s *Scanner) LoadConfig(path string) error {
+	return s.loadConfig(path, 0)
+}
+
+func (s *Scanner) loadConfig(path string, depth int) error {
+	if depth > 2 {
+		return fmt.Errorf("%s: too many nested [extend] paths", path)
+	}
+	var cfg gitleaksConfig
+	if _, err := toml.DecodeFile(path, &cfg); err != nil {
+		return fmt.Errorf("failed to read gitleaks config %s: %w", path, err)
+	}
+
+	if !s.custom {
+		// The first file decides whether the built-in rules stay
+		s.custom = true
+		if !cfg.Extend.UseDefault {
+			s.Rules = nil
+		}
+	}
+	if cfg.Extend.Path != "" {
+		base := cfg.Extend.Path
+		if !filepath.IsAbs(base) {
+			base = filepath.Join(filepath.Dir(path), base)
+		}
+		if err := s.loadConfig(base, depth+1); err != nil {
+			return err
+		}
+	}
+	for _, id := range cfg.Extend.DisabledRules {
+		s.removeRule(id)
+	}
+
+	for _, r := range cfg.Rules {
+		if r.ID == "" {
+			return fmt.Errorf("%s: rule without an id", path)
+		}
+		if r.Regex == "" && r.Path == "" {
+			return fmt.Errorf("%s: rule %s has neither regex nor path", path, r.ID)
+		}
+		rule := Rule{ID: r.ID, Description: r.Description, SecretGroup: r.SecretGroup, Entropy: r.Entropy}
+		if rule.Description == "" {
+			rule.Description = r.ID
+		}
+		var err error
+		if rule.Pattern, err = compileOptional(r.Regex); err != nil {
+			return fmt.Errorf("%s: rule %s: %w", path, r.ID, err)
+		}
+		if rule.Path, err = compileOptional(r.Path); err != nil {
+			return fmt.Errorf("%s: rule %s: %w", path, r.ID, err)
+		}
+		for _, keyword := range r.Keywords {
+			rule.Keywords = append(rule.Keywords, strings.ToLower(keyword))
+		}
+		lists := r.Allowlists
+		if r.Allowlist != nil {
+			lists = append(lists, *r.Allowlist)
+		}
+		if rule.Allowlists, err = compileAllowlists(lists); err != nil {
+			return fmt.Errorf("%s: rule %s: %w", path, r.ID, err)
+		}
+		s.removeRule(rule.ID)
+		s.Rules = append(s.Rules, rule)
+	}
+
+	lists := cfg.Allowlists
+	if cfg.Allowlist != nil {
+		lists = append(lists, *cfg.Allowlist)
+	}
+	allowlists, err := compileAllowlists(lists)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	s.Allowlists = append(s.Allowlists, allowlists...)
+	return nil
+}
+
+// LoadIgnore reads the fingerprints of accepted findings from a
+// .gitleaksignore file, one per line; a file that does not exist is empty
+func (s *Scanner) LoadIgnore(path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			s.Ignore[line] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return nil
+}
+
+func (s *Scanner) removeRule(id string) {
+	rules := s.Rules[:0]
+	for _, rule := range s.Rules {
+		if rule.ID != id {
+			rules = append(rules, rule)
+		}
+	}
+	s.Rules = rules
+}
+
+func compileOptional(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+func compileAllowlists(lists []gitleaksAllowlist) ([]Allowlist, error) {
+	var compiled []Allowlist
+	for _, list := range lists {
+		allowlist := Allowlist{
+			RegexTarget: list.RegexTarget,
+			StopWords:   list.StopWords,
+			Commits:     list.Commits,
+			And:         strings.EqualFold(list.Condition, "AND"),
+		}
+		for _, pattern := range list.Paths {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("allowlist path: %w", err)
+			}
+			allowlist.Paths = append(allowlist.Paths, re)
+		}
+		for _, pattern := range list.Regexes {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("allowlist regex: %w", err)
+			}
+			allowlist.Regexes = append(allowlist.Regexes, re)
+		}
+		compiled = append(compiled, allowlist)
+	}
+	return compiled, nil
+}
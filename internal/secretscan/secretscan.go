@@ -0,0 +1,365 @@
+// Package secretscan finds credentials in the lines a git diff adds, for
+// the hooks 'profile guard install' puts in profile repositories. It looks
+// for well-known token formats and for files that hold credentials by
+// convention; it cannot find every secret, only the ones that are easy to
+// commit by accident. Rules and allowlists can be extended or replaced with
+// gitleaks configuration files (see LoadConfig).
+package secretscan
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AllowMarker on a line marks a false positive that is not reported
+const AllowMarker = "spm:allow"
+
+// gitleaksAllowMarker is the marker gitleaks honors, accepted as well so
+// lines already marked for gitleaks are not reported twice
+const gitleaksAllowMarker = "gitleaks:allow"
+
+// Finding is a possible credential in a diff
+type Finding struct {
+	// Commit is set for findings in a range of commits (pre-push)
+	Commit string `json:"commit,omitempty" yaml:"commit,omitempty"`
+	Path   string `json:"path" yaml:"path"`
+	// Line is 0 for files that hold credentials by their name
+	Line   int    `json:"line,omitempty" yaml:"line,omitempty"`
+	RuleID string `json:"rule_id" yaml:"rule_id"`
+	Rule   string `json:"rule" yaml:"rule"`
+}
+
+// Fingerprint identifies a finding in a .gitleaksignore file, as gitleaks
+// does: [<commit>:]<path>:<rule id>:<line>
+func (f Finding) Fingerprint() string {
+	fingerprint := fmt.Sprintf("%s:%s:%d", f.Path, f.RuleID, f.Line)
+	if f.Commit != "" {
+		fingerprint = f.Commit + ":" + fingerprint
+	}
+	return fingerprint
+}
+
+// Rule finds a kind of credential
+type Rule struct {
+	ID          string
+	Description string
+	// Pattern matches the credential in a line; a rule without one reports
+	// every file whose path matches Path
+	Pattern *regexp.Regexp
+	// SecretGroup is the group of Pattern holding the credential (0: the
+	// whole match)
+	SecretGroup int
+	// Entropy is the Shannon entropy below which a match is too regular to
+	// be a credential (0: not checked)
+	Entropy float64
+	// Path limits the rule to files whose path it matches
+	Path *regexp.Regexp
+	// Keywords, lowercase, of which a line must contain one for Pattern to
+	// be tried
+	Keywords   []string
+	Allowlists []Allowlist
+}
+
+// Allowlist describes matches that are not credentials
+type Allowlist struct {
+	Paths   []*regexp.Regexp
+	Regexes []*regexp.Regexp
+	// RegexTarget is what Regexes match: "secret" (default), "match", or
+	// "line"
+	RegexTarget string
+	StopWords   []string
+	Commits     []string
+	// And requires every criterion given to apply instead of any
+	And bool
+}
+
+// builtinRules match credentials by their format. Values that reference a
+// variable ($NPM_TOKEN, ${TOKEN}) are not credentials.
+var builtinRules = []Rule{
+	{ID: "aws-access-token", Description: "AWS access key ID", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{ID: "aws-secret-access-key", Description: "AWS secret access key", Pattern: regexp.MustCompile(`(?i)aws_secret_access_key\s*[=:]\s*["']?[A-Za-z0-9/+]{40}\b`)},
+	{ID: "private-key", Description: "private key", Pattern: regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----`)},
+	{ID: "github-token", Description: "GitHub token", Pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{ID: "gitlab-pat", Description: "GitLab token", Pattern: regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}`)},
+	{ID: "slack-token", Description: "Slack token", Pattern: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{ID: "gcp-api-key", Description: "Google API key", Pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{ID: "npm-access-token", Description: "npm token", Pattern: regexp.MustCompile(`\bnpm_[A-Za-z0-9]{36}\b`)},
+	{ID: "npm-auth-token", Description: "npm auth token", Pattern: regexp.MustCompile(`_auth(?:Token)?\s*=\s*["']?[^\s"'$]`)},
+	{ID: "terraform-cloud-token", Description: "Terraform Cloud token", Pattern: regexp.MustCompile(`\b[A-Za-z0-9]{14}\.atlasv1\.[A-Za-z0-9_-]{60,}`)},
+	{ID: "stripe-secret-key", Description: "Stripe secret key", Pattern: regexp.MustCompile(`\b[rs]k_live_[0-9A-Za-z]{24,}`)},
+	{ID: "password-in-url", Description: "password in URL", Pattern: regexp.MustCompile(`\b[a-z][a-z0-9+.-]*://[^/\s:@]+:[^/\s:@$]{3,}@`)},
+
+	// Files that hold credentials by their name
+	{ID: "env-file", Description: "environment file", Path: regexp.MustCompile(`(?:^|/)\.env(?:\.[^/]*)?$`),
+		Allowlists: []Allowlist{{Paths: []*regexp.Regexp{regexp.MustCompile(`(?:^|/)\.env\.example$`)}}}},
+	{ID: "netrc", Description: "netrc credentials", Path: regexp.MustCompile(`(?:^|/)\.netrc$`)},
+	{ID: "aws-credentials-file", Description: "AWS credentials file", Path: regexp.MustCompile(`(?:^|/)\.aws/credentials$`)},
+	{ID: "ssh-private-key-file", Description: "SSH private key", Path: regexp.MustCompile(`(?:^|/)\.ssh/id_[^/]*$`),
+		Allowlists: []Allowlist{{Paths: []*regexp.Regexp{regexp.MustCompile(`\.pub$`)}}}},
+	{ID: "key-file", Description: "key file", Path: regexp.MustCompile(`\.(?:pem|key|p12|pfx)$`)},
+}
+
+// Scanner finds credentials with a set of rules
+type Scanner struct {
+	Rules []Rule
+	// Allowlists apply to every rule
+	Allowlists []Allowlist
+	// Ignore holds the fingerprints of accepted findings (.gitleaksignore)
+	Ignore map[string]bool
+	// custom is set once a configuration file decided whether the built-in
+	// rules are kept
+	custom bool
+}
+
+// New returns a scanner with the built-in rules
+func New() *Scanner {
+	return &Scanner{Rules: append([]Rule{}, builtinRules...), Ignore: map[string]bool{}}
+}
+
+var defaultScanner = New()
+
+// Line returns the description of the built-in rule a line matches, or ""
+// when it holds no credential or is marked with AllowMarker
+func Line(line string) string {
+	if rule := defaultScanner.line("", "", line); rule != nil {
+		return rule.Description
+	}
+	return ""
+}
+
+// Redact replaces the credentials the built-in rules find in a line with
+// mask
+func Redact(line, mask string) string {
+	for _, r := range builtinRules {
+		if r.Pattern != nil {
+			line = r.Pattern.ReplaceAllString(line, mask)
+		}
+	}
+	return line
+}
+
+// ScanDiff reports the credentials the built-in rules find in a diff (see
+// Scanner.ScanDiff)
+func ScanDiff(r io.Reader) ([]Finding, error) {
+	return defaultScanner.ScanDiff(r)
+}
+
+// line returns the rule matching a line added to path, or nil
+func (s *Scanner) line(commit, path, line string) *Rule {
+	if strings.Contains(line, AllowMarker) || strings.Contains(line, gitleaksAllowMarker) {
+		return nil
+	}
+	lower := strings.ToLower(line)
+	for i := range s.Rules {
+		rule := &s.Rules[i]
+		if rule.Pattern == nil || rule.Path != nil && !rule.Path.MatchString(path) || !hasKeyword(lower, rule.Keywords) {
+			continue
+		}
+		for _, match := range rule.Pattern.FindAllStringSubmatch(line, -1) {
+			secret := match[0]
+			if rule.SecretGroup > 0 && rule.SecretGroup < len(match) {
+				secret = match[rule.SecretGroup]
+			}
+			if rule.Entropy > 0 && entropy(secret) < rule.Entropy {
+				continue
+			}
+			if s.allowed(rule, commit, path, line, match[0], secret) {
+				continue
+			}
+			return rule
+		}
+	}
+	return nil
+}
+
+// file returns the rule reporting a file by its path alone, or nil
+func (s *Scanner) file(commit, path string) *Rule {
+	for i := range s.Rules {
+		rule := &s.Rules[i]
+		if rule.Pattern == nil && rule.Path != nil && rule.Path.MatchString(path) && !s.allowed(rule, commit, path, "", "", "") {
+			return rule
+		}
+	}
+	return nil
+}
+
+// allowed reports whether an allowlist of the rule or of the scanner
+// accepts a match
+func (s *Scanner) allowed(rule *Rule, commit, path, line, match, secret string) bool {
+	for _, lists := range [][]Allowlist{rule.Allowlists, s.Allowlists} {
+		for _, list := range lists {
+			if list.allows(commit, path, line, match, secret) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allows reports whether a match is accepted by any criterion of the
+// allowlist, or by all of those it sets with And
+func (a Allowlist) allows(commit, path, line, match, secret string) bool {
+	target := secret
+	switch a.RegexTarget {
+	case "match":
+		target = match
+	case "line":
+		target = line
+	}
+	var results []bool
+	if len(a.Commits) > 0 {
+		results = append(results, commit != "" && containsString(a.Commits, commit))
+	}
+	if len(a.Paths) > 0 {
+		results = append(results, matchesAny(a.Paths, path))
+	}
+	if len(a.Regexes) > 0 {
+		results = append(results, target != "" && matchesAny(a.Regexes, target))
+	}
+	if len(a.StopWords) > 0 {
+		lower := strings.ToLower(secret)
+		found := false
+		for _, word := range a.StopWords {
+			if secret != "" && strings.Contains(lower, strings.ToLower(word)) {
+				found = true
+			}
+		}
+		results = append(results, found)
+	}
+	if len(results) == 0 {
+		return false
+	}
+	for _, result := range results {
+		if result != a.And {
+			return result
+		}
+	}
+	return a.And
+}
+
+// ScanDiff reports the credentials added by a unified diff, as printed by
+// git diff or git log -p with --no-prefix. Lines "commit <sha>" between the
+// diffs (git log --format="commit %H") attribute findings to a commit.
+// Findings whose fingerprint is in Ignore are left out.
+func (s *Scanner) ScanDiff(r io.Reader) ([]Finding, error) {
+	var findings []Finding
+	add := func(finding Finding) {
+		if !s.Ignore[finding.Fingerprint()] {
+			findings = append(findings, finding)
+		}
+	}
+	var commit, file string
+	// line is the next line on the new side of the hunk, of which remaining
+	// are left; lines within a hunk are content even if they look like
+	// headers
+	line, remaining := 0, 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		text := scanner.Text()
+		switch {
+		case remaining > 0 && (strings.HasPrefix(text, "+") || strings.HasPrefix(text, " ")):
+			if strings.HasPrefix(text, "+") && file != "" {
+				if rule := s.line(commit, file, text[1:]); rule != nil {
+					add(Finding{Commit: commit, Path: file, Line: line, RuleID: rule.ID, Rule: rule.Description})
+				}
+			}
+			line++
+			remaining--
+		case remaining > 0 && strings.HasPrefix(text, "-"), strings.HasPrefix(text, `\`):
+			// Removed lines and "\ No newline at end of file"
+		case strings.HasPrefix(text, "commit "):
+			commit, file = strings.TrimPrefix(text, "commit "), ""
+		case strings.HasPrefix(text, "diff --git "):
+			file = ""
+		case strings.HasPrefix(text, "+++ "):
+			file = strings.TrimPrefix(text, "+++ ")
+			if file == "/dev/null" {
+				file = ""
+				continue
+			}
+			if rule := s.file(commit, file); rule != nil {
+				add(Finding{Commit: commit, Path: file, RuleID: rule.ID, Rule: rule.Description})
+			}
+		case strings.HasPrefix(text, "Binary files ") && strings.HasSuffix(text, " differ"):
+			// "Binary files <old> and <new> differ"
+			names := strings.TrimSuffix(strings.TrimPrefix(text, "Binary files "), " differ")
+			if i := strings.LastIndex(names, " and "); i != -1 {
+				if name := names[i+len(" and "):]; name != "/dev/null" {
+					if rule := s.file(commit, name); rule != nil {
+						add(Finding{Commit: commit, Path: name, RuleID: rule.ID, Rule: rule.Description})
+					}
+				}
+			}
+		case strings.HasPrefix(text, "@@ "):
+			line, remaining = hunkRange(text)
+		}
+	}
+	return findings, scanner.Err()
+}
+
+// hunkRange returns the first line and the line count of the new side of
+// a hunk header, "@@ -<old> +<start>[,<count>] @@"
+func hunkRange(header string) (int, int) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+		return 0, 0
+	}
+	start, count, ok := strings.Cut(strings.TrimPrefix(fields[2], "+"), ",")
+	n, _ := strconv.Atoi(start)
+	if !ok {
+		return n, 1
+	}
+	c, _ := strconv.Atoi(count)
+	return n, c
+}
+
+// entropy returns the Shannon entropy of s in bits per character
+func entropy(s string) float64 {
+	counts := map[rune]int{}
+	for _, r := range s {
+		counts[r]++
+	}
+	var e float64
+	length := float64(len([]rune(s)))
+	for _, count := range counts {
+		p := float64(count) / length
+		e -= p * math.Log2(p)
+	}
+	return e
+}
+
+func hasKeyword(lower string, keywords []string) bool {
+	if len(keywords) == 0 {
+		return true
+	}
+	for _, keyword := range keywords {
+		if strings.Contains(lower, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(patterns []*regexp.Regexp, s string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,272 @@
+package shell
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// functionName matches the names the cd function can be given
+var functionName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// ansiColors maps the prompt colors every supported shell can name to
+// their ANSI codes (bash has no color names of its own)
+var ansiColors = map[string]string{
+	"red":     "31",
+	"green":   "32",
+	"yellow":  "33",
+	"blue":    "34",
+	"magenta": "35",
+	"cyan":    "36",
+}
+
+// Colors returns the supported prompt colors
+func Colors() []string {
+	var colors []string
+	for color := range ansiColors {
+		colors = append(colors, color)
+	}
+	sort.Strings(colors)
+	return colors
+}
+
+// PromptHook returns the snippet that keeps SPM_PROFILE set to the name of
+// the active profile (the last element of WORKSPACE_HOME, which direnv sets
+// and unsets). With a color the prompt is prefixed with the name.
+func PromptHook(name, color string) (string, error) {
+	if err := Validate(name); err != nil {
+		return "", err
+	}
+	if _, ok := ansiColors[color]; color != "" && !ok {
+		return "", fmt.Errorf("unsupported color %q (supported: %s)", color, strings.Join(Colors(), ", "))
+	}
+
+	switch name {
+	case Zsh:
+		return zshPromptHook(color), nil
+	case Fish:
+		return fishPromptHook(color), nil
+	default:
+		return bashPromptHook(color), nil
+	}
+}
+
+func bashPromptHook(color string) string {
+	prompt := ""
+	if color != "" {
+		// PS1 escapes such as \[ are decoded before variables are expanded,
+		// so the variable holds the bytes they stand for: \001 and \002
+		// around the color codes tell readline they take no space
+		prompt = fmt.Sprintf(`$'\001\e[%sm\002'"[$SPM_PROFILE]"$'\001\e[0m\002 '`, ansiColors[color])
+	}
+
+	var b strings.Builder
+	b.WriteString(`# Active workspace profile (profile hook bash); add to ~/.bashrc after the direnv hook
+_profile_hook() {
+  if [[ -n "${WORKSPACE_HOME:-}" ]]; then
+    export SPM_PROFILE="${WORKSPACE_HOME##*/}"
+`)
+	if prompt != "" {
+		fmt.Fprintf(&b, "    _profile_prompt=%s\n", prompt)
+	}
+	b.WriteString(`  else
+    unset SPM_PROFILE
+`)
+	if prompt != "" {
+		b.WriteString("    _profile_prompt=\n")
+	}
+	b.WriteString(`  fi
+}
+[[ $PROMPT_COMMAND == *_profile_hook* ]] || PROMPT_COMMAND="${PROMPT_COMMAND:+$PROMPT_COMMAND;}_profile_hook"
+`)
+	if prompt != "" {
+		b.WriteString(`[[ $PS1 == *_profile_prompt* ]] || PS1='${_profile_prompt}'"$PS1"
+`)
+	}
+	return b.String()
+}
+
+func zshPromptHook(color string) string {
+	prompt := ""
+	if color != "" {
+		prompt = fmt.Sprintf("%%F{%s}[$SPM_PROFILE]%%f ", color)
+	}
+
+	var b strings.Builder
+	b.WriteString(`# Active workspace profile (profile hook zsh); add to ~/.zshrc after the direnv hook
+_profile_hook() {
+  if [[ -n "${WORKSPACE_HOME:-}" ]]; then
+    export SPM_PROFILE="${WORKSPACE_HOME:t}"
+`)
+	if prompt != "" {
+		fmt.Fprintf(&b, "    _profile_prompt=\"%s\"\n", prompt)
+	}
+	b.WriteString(`  else
+    unset SPM_PROFILE
+`)
+	if prompt != "" {
+		b.WriteString("    _profile_prompt=\n")
+	}
+	b.WriteString(`  fi
+}
+typeset -ag precmd_functions
+(( ${precmd_functions[(I)_profile_hook]} )) || precmd_functions+=(_profile_hook)
+`)
+	if prompt != "" {
+		b.WriteString(`setopt prompt_subst
+[[ $PROMPT == *_profile_prompt* ]] || PROMPT='${_profile_prompt}'"$PROMPT"
+`)
+	}
+	return b.String()
+}
+
+// fishPromptHook follows WORKSPACE_HOME with a variable handler, and
+// prefixes fish_mode_prompt, which fish prints before fish_prompt, so the
+// user's prompt keeps seeing the last command's status
+func fishPromptHook(color string) string {
+	var b strings.Builder
+	b.WriteString(`# Active workspace profile (profile hook fish); add to ~/.config/fish/config.fish
+function _profile_hook --on-variable WORKSPACE_HOME
+    if test -n "$WORKSPACE_HOME"
+        set -gx SPM_PROFILE (string replace -r '.*/' '' -- $WORKSPACE_HOME)
+    else
+        set -e SPM_PROFILE
+    end
+end
+_profile_hook
+`)
+	if color != "" {
+		fmt.Fprintf(&b, `if not functions -q _profile_original_mode_prompt
+    functions -c fish_mode_prompt _profile_original_mode_prompt
+end
+function fish_mode_prompt
+    _profile_original_mode_prompt
+    if set -q SPM_PROFILE
+        set_color %s
+        printf '[%%s] ' $SPM_PROFILE
+        set_color normal
+    end
+end
+`, color)
+	}
+	return b.String()
+}
+
+// LinkHook returns the snippet that loads the environment of the profile a
+// directory is linked to ('profile link') when the shell changes to it, and
+// restores the environment when it leaves. profile is only run when the
+// directory changed and linksFile has links, or something was loaded.
+func LinkHook(name, linksFile string) (string, error) {
+	if err := Validate(name); err != nil {
+		return "", err
+	}
+
+	var snippet string
+	switch name {
+	case Zsh:
+		snippet = zshLinkHook
+	case Fish:
+		snippet = fishLinkHook
+	default:
+		snippet = bashLinkHook
+	}
+	return strings.NewReplacer("{{links}}", Quote(name, linksFile), "{{shell}}", name).Replace(snippet), nil
+}
+
+// The link hooks run before the other prompt hooks, so SPM_PROFILE follows
+// the profile they load in the same prompt
+const bashLinkHook = `# Profiles linked to directories (profile link): loaded when you change to them
+_profile_link_hook() {
+  [[ $PWD == "${_profile_link_pwd:-}" ]] && return
+  _profile_link_pwd=$PWD
+  if [[ -n "${SPM_LINK_STATE:-}" || -s {{links}} ]]; then
+    eval "$(profile __link-env {{shell}})"
+  fi
+}
+[[ $PROMPT_COMMAND == *_profile_link_hook* ]] || PROMPT_COMMAND="_profile_link_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+`
+
+const zshLinkHook = `# Profiles linked to directories (profile link): loaded when you change to them
+_profile_link_hook() {
+  [[ $PWD == "${_profile_link_pwd:-}" ]] && return
+  _profile_link_pwd=$PWD
+  if [[ -n "${SPM_LINK_STATE:-}" || -s {{links}} ]]; then
+    eval "$(profile __link-env {{shell}})"
+  fi
+}
+(( ${precmd_functions[(I)_profile_link_hook]} )) || precmd_functions=(_profile_link_hook $precmd_functions)
+`
+
+const fishLinkHook = `# Profiles linked to directories (profile link): loaded when you change to them
+function _profile_link_hook --on-variable PWD
+    if set -q SPM_LINK_STATE; or test -s {{links}}
+        profile __link-env {{shell}} | source
+    end
+end
+_profile_link_hook
+`
+
+// CdFunction returns the definition of a shell function, named function,
+// that changes to the directory of a profile, or of a directory in it
+// ("pcd acme code"), and to the active profile without arguments. The
+// directory is looked up with 'profile __path' and both arguments are
+// completed.
+func CdFunction(name, function string) (string, error) {
+	if err := Validate(name); err != nil {
+		return "", err
+	}
+	if !functionName.MatchString(function) {
+		return "", fmt.Errorf("invalid function name %q", function)
+	}
+
+	var snippet string
+	switch name {
+	case Zsh:
+		snippet = zshCdFunction
+	case Fish:
+		snippet = fishCdFunction
+	default:
+		snippet = bashCdFunction
+	}
+	return strings.NewReplacer("{{fn}}", function, "{{shell}}", name).Replace(snippet), nil
+}
+
+const bashCdFunction = `# cd into a workspace profile (profile hook {{shell}} --with-cd): {{fn}} <profile> [dir]
+{{fn}}() {
+  local dir
+  dir="$(profile __path "$@")" && cd "$dir"
+}
+_{{fn}}_complete() {
+  local cur="${COMP_WORDS[COMP_CWORD]}"
+  local IFS=$'\n'
+  local candidates=($(profile __complete __path "${COMP_WORDS[@]:1:COMP_CWORD-1}" 2>/dev/null | cut -f1))
+  COMPREPLY=($(compgen -W "${candidates[*]}" -- "$cur"))
+}
+complete -F _{{fn}}_complete {{fn}}
+`
+
+// zshCdFunction only registers the completion once compinit has loaded
+// compdef, so it can be added anywhere in ~/.zshrc
+const zshCdFunction = `# cd into a workspace profile (profile hook {{shell}} --with-cd): {{fn}} <profile> [dir]
+{{fn}}() {
+  local dir
+  dir="$(profile __path "$@")" && cd "$dir"
+}
+_{{fn}}_complete() {
+  local -a candidates
+  local line
+  for line in "${(@f)$(profile __complete __path "${(@)words[2,CURRENT-1]}" 2>/dev/null)}"; do
+    [[ -n $line ]] && candidates+=("${line%%$'\t'*}:${line#*$'\t'}")
+  done
+  _describe 'profile' candidates
+}
+(( $+functions[compdef] )) && compdef _{{fn}}_complete {{fn}}
+`
+
+const fishCdFunction = `# cd into a workspace profile (profile hook {{shell}} --with-cd): {{fn}} <profile> [dir]
+function {{fn}} --description 'cd into a workspace profile'
+    set -l dir (profile __path $argv); and cd $dir
+end
+complete -c {{fn}} -f -a '(profile __complete __path (commandline -opc)[2..-1])'
+`
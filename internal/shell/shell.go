@@ -0,0 +1,110 @@
+// Package shell covers the differences between the interactive shells
+// profile emits snippets for: how direnv is hooked in, where the hook is
+// configured, and how values are quoted.
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	Bash = "bash"
+	Zsh  = "zsh"
+	Fish = "fish"
+)
+
+// Names returns the supported shells
+func Names() []string {
+	return []string{Bash, Zsh, Fish}
+}
+
+// Validate reports an error for shells without support
+func Validate(name string) error {
+	for _, supported := range Names() {
+		if name == supported {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported shell %q (supported: %s)", name, strings.Join(Names(), ", "))
+}
+
+// Detect returns the user's login shell from $SHELL, or "" when it is not
+// a supported shell
+func Detect() string {
+	name := filepath.Base(os.Getenv("SHELL"))
+	if Validate(name) != nil {
+		return ""
+	}
+	return name
+}
+
+// DirenvHook returns the line that hooks direnv into the shell's rc file
+func DirenvHook(name string) string {
+	if name == Fish {
+		return "direnv hook fish | source"
+	}
+	return fmt.Sprintf(`eval "$(direnv hook %s)"`, name)
+}
+
+// RCFile returns the file the shell reads at interactive startup, where
+// hooks are added
+func RCFile(name, homeDir string) string {
+	switch name {
+	case Zsh:
+		if zdotdir := os.Getenv("ZDOTDIR"); zdotdir != "" {
+			return filepath.Join(zdotdir, ".zshrc")
+		}
+		return filepath.Join(homeDir, ".zshrc")
+	case Fish:
+		return filepath.Join(homeDir, ".config", "fish", "config.fish")
+	default:
+		return filepath.Join(homeDir, ".bashrc")
+	}
+}
+
+// StartupFiles returns the files the shell may read at login or
+// interactive startup, in the order it reads them. For fish, conf.d
+// snippets (e.g. installed by a plugin manager) come before config.fish.
+func StartupFiles(name, homeDir string) []string {
+	switch name {
+	case Zsh:
+		dir := homeDir
+		if zdotdir := os.Getenv("ZDOTDIR"); zdotdir != "" {
+			dir = zdotdir
+		}
+		return []string{filepath.Join(dir, ".zshenv"), filepath.Join(dir, ".zprofile"), RCFile(name, homeDir), filepath.Join(dir, ".zlogin")}
+	case Fish:
+		snippets, _ := filepath.Glob(filepath.Join(homeDir, ".config", "fish", "conf.d", "*.fish"))
+		return append(snippets, RCFile(name, homeDir))
+	default:
+		return []string{filepath.Join(homeDir, ".bash_profile"), filepath.Join(homeDir, ".profile"), RCFile(name, homeDir)}
+	}
+}
+
+// HasDirenvHook reports whether the shell's startup files hook direnv in
+func HasDirenvHook(name, homeDir string) bool {
+	for _, file := range StartupFiles(name, homeDir) {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		text := string(content)
+		if strings.Contains(text, "direnv hook "+name) || strings.Contains(text, "direnv export "+name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Quote quotes a value as a single word for the shell
+func Quote(name, value string) string {
+	if name == Fish {
+		// fish only treats \\ and \' as escapes inside single quotes
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,143 @@
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mindmorass/shell-profile-manager/internal/atomicfile"
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+)
+
+// reposFileName is the registry of template repositories in the spm
+// config directory
+const reposFileName = "template-repos.yaml"
+
+// Repo is a git repository of shared templates. Each top-level directory
+// of the repository is a template holding <file>.tmpl files, like a
+// directory under the user templates directory.
+type Repo struct {
+	Name string `yaml:"name" json:"name"`
+	URL  string `yaml:"url" json:"url"`
+	// Ref pins a branch or tag ("" follows the default branch)
+	Ref string `yaml:"ref,omitempty" json:"ref,omitempty"`
+	// Commit is the locked commit the clone is checked out at; 'template
+	// update' moves it to the latest commit of Ref
+	Commit string `yaml:"commit" json:"commit"`
+}
+
+type reposFile struct {
+	Repos []Repo `yaml:"repos"`
+}
+
+// ReposPath returns the location of the template repository registry
+func ReposPath() (string, error) {
+	spmDir, err := config.SpmDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(spmDir, reposFileName), nil
+}
+
+// RepoDir returns where a template repository is cloned
+// ($XDG_STATE_HOME/spm/template-repos/<name>)
+func RepoDir(name string) (string, error) {
+	stateDir, err := config.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, "template-repos", name), nil
+}
+
+// LoadRepos returns the registered template repositories in lookup order
+func LoadRepos() ([]Repo, error) {
+	path, err := ReposPath()
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var f reposFile
+	if err := yaml.Unmarshal(content, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return f.Repos, nil
+}
+
+// SaveRepos writes the template repository registry
+func SaveRepos(repos []Repo) error {
+	path, err := ReposPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# Template repositories (managed by 'profile template'; commits are locked\n")
+	buf.WriteString("# until 'profile template update')\n")
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(reposFile{Repos: repos}); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", reposFileName, err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", reposFileName, err)
+	}
+	if err := atomicfile.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// repoTemplateDirs returns the template directories of the cloned
+// repositories, in lookup order. Repositories that are not cloned yet are
+// skipped.
+func repoTemplateDirs() ([]Template, error) {
+	repos, err := LoadRepos()
+	if err != nil {
+		return nil, err
+	}
+	var all []Template
+	for _, repo := range repos {
+		dir, err := RepoDir(repo.Name)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || entry.Name()[0] == '.' {
+				continue
+			}
+			all = append(all, Template{
+				Name:        entry.Name(),
+				Description: "From " + repo.Name,
+				Origin:      repo.Name + "@" + ShortCommit(repo.Commit),
+				Repo:        repo.Name,
+				Commit:      repo.Commit,
+				dir:         filepath.Join(dir, entry.Name()),
+			})
+		}
+	}
+	return all, nil
+}
+
+// ShortCommit abbreviates a commit hash for display
+func ShortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
@@ -0,0 +1,213 @@
+// Package templates renders the files create writes into a profile (.envrc,
+// .gitconfig, .gitignore) from Go text/template files.
+//
+// Each file is looked up in order in:
+//
+//	$XDG_CONFIG_HOME/spm/templates/<template>/<file>.tmpl
+//	$XDG_CONFIG_HOME/spm/templates/<file>.tmpl
+//	<repository>/<template>/<file>.tmpl, for each template repository
+//	the built-in templates
+//
+// so a file can be customized for one template or for all of them. A
+// directory under templates/ or in a repository also adds a template with
+// its name.
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+)
+
+//go:embed files/*.tmpl
+var builtinFiles embed.FS
+
+// Files are the profile files rendered from templates
+var Files = []string{"envrc", "gitconfig", "gitignore"}
+
+// Builtin are the templates shipped with profile, with their descriptions
+var Builtin = []Template{
+	{Name: "basic", Description: "Minimal configuration"},
+	{Name: "personal", Description: "Personal projects"},
+	{Name: "work", Description: "Work projects"},
+	{Name: "client", Description: "Client projects"},
+}
+
+// Template is a named set of profile files
+type Template struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description" yaml:"description"`
+	// Origin is where the template comes from: "built-in", "local", or
+	// "<repository>@<commit>"
+	Origin string `json:"origin" yaml:"origin"`
+	// Repo and Commit identify the template repository it comes from
+	Repo   string `json:"repo,omitempty" yaml:"repo,omitempty"`
+	Commit string `json:"commit,omitempty" yaml:"commit,omitempty"`
+	// dir holds the files of a repository template
+	dir string
+}
+
+// Data is the context templates are rendered with
+type Data struct {
+	Profile  string
+	Template string
+	// Created is the creation time, as recorded in README.md
+	Created     string
+	GitName     string
+	GitEmail    string
+	Owner       string
+	Description string
+	Tags        []string
+	// Vars are the custom key/values from the profile manifest (vars:)
+	Vars map[string]string
+	// EnvrcSections and GitignoreSections are the blocks the profile's
+	// features add to .envrc and .gitignore
+	EnvrcSections     string
+	GitignoreSections string
+}
+
+var funcs = template.FuncMap{
+	// default returns value, or fallback when value is empty
+	"default": func(fallback, value string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	},
+	"join":  func(sep string, values []string) string { return strings.Join(values, sep) },
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// UserDir returns the directory of user templates
+// ($XDG_CONFIG_HOME/spm/templates)
+func UserDir() (string, error) {
+	spmDir, err := config.SpmDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(spmDir, "templates"), nil
+}
+
+// List returns the built-in templates followed by the local templates and
+// those of the template repositories
+func List() ([]Template, error) {
+	var all []Template
+	for _, t := range Builtin {
+		t.Origin = "built-in"
+		all = append(all, t)
+	}
+
+	dir, err := UserDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	for _, entry := range entries {
+		if entry.IsDir() && indexOf(all, entry.Name()) < 0 {
+			all = append(all, Template{Name: entry.Name(), Description: "Custom template", Origin: "local"})
+		}
+	}
+
+	repoTemplates, err := repoTemplateDirs()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range repoTemplates {
+		if indexOf(all, t.Name) < 0 {
+			all = append(all, t)
+		}
+	}
+	return all, nil
+}
+
+// Render renders a profile file (one of Files) for data.Template
+func Render(file string, data Data) (string, error) {
+	source, content, err := Source(data.Template, file)
+	if err != nil {
+		return "", err
+	}
+	slog.Debug("rendering template", "file", file, "source", source)
+
+	tmpl, err := template.New(file).Funcs(funcs).Option("missingkey=zero").Parse(string(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template %s: %w", source, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", source, err)
+	}
+	return buf.String(), nil
+}
+
+// Source returns where a profile file of a template is rendered from (a
+// path, or "built-in") and its content
+func Source(templateName, file string) (string, []byte, error) {
+	dir, err := UserDir()
+	if err != nil {
+		return "", nil, err
+	}
+	paths := []string{
+		filepath.Join(dir, templateName, file+".tmpl"),
+		filepath.Join(dir, file+".tmpl"),
+	}
+	repoTemplates, err := repoTemplateDirs()
+	if err != nil {
+		return "", nil, err
+	}
+	for _, t := range repoTemplates {
+		if t.Name == templateName {
+			paths = append(paths, filepath.Join(t.dir, file+".tmpl"))
+		}
+	}
+
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err == nil {
+			return path, content, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+
+	content, err := builtinFiles.ReadFile("files/" + file + ".tmpl")
+	if err != nil {
+		return "", nil, fmt.Errorf("unknown template file: %s", file)
+	}
+	return "built-in", content, nil
+}
+
+// Resolve returns the template repository and commit a template comes
+// from ("" for built-in and local templates)
+func Resolve(templateName string) (string, string, error) {
+	all, err := List()
+	if err != nil {
+		return "", "", err
+	}
+	if i := indexOf(all, templateName); i >= 0 {
+		return all[i].Repo, all[i].Commit, nil
+	}
+	return "", "", nil
+}
+
+func indexOf(all []Template, name string) int {
+	for i, t := range all {
+		if t.Name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -2,11 +2,14 @@ package ui
 
 import (
 	"fmt"
-	"os"
+
+	"github.com/mindmorass/shell-profile-manager/internal/redact"
 )
 
-// ANSI color codes
-const (
+// ANSI color codes. They are variables so ConfigureColor can theme them
+// or turn them off: green marks success, yellow warnings, red errors, and
+// blue headings and labels (the accent).
+var (
 	ColorReset  = "\033[0m"
 	ColorRed    = "\033[0;31m"
 	ColorGreen  = "\033[0;32m"
@@ -15,18 +18,32 @@ const (
 	ColorCyan   = "\033[0;36m"
 )
 
+var quiet bool
+
+// SetQuiet hides success and info messages; warnings and errors are still
+// shown
+func SetQuiet(value bool) {
+	quiet = value
+}
+
 func PrintError(msg string) {
-	fmt.Fprintf(os.Stderr, "%sERROR: %s%s\n", ColorRed, msg, ColorReset)
+	fmt.Fprintf(Stderr(), "%sERROR: %s%s\n", ColorRed, redact.Text(msg), ColorReset)
 }
 
 func PrintSuccess(msg string) {
-	fmt.Printf("%sSUCCESS: %s%s\n", ColorGreen, msg, ColorReset)
+	if quiet {
+		return
+	}
+	fmt.Fprintf(MessageOutput(), "%sSUCCESS: %s%s\n", ColorGreen, redact.Text(msg), ColorReset)
 }
 
 func PrintInfo(msg string) {
-	fmt.Printf("%sINFO: %s%s\n", ColorBlue, msg, ColorReset)
+	if quiet {
+		return
+	}
+	fmt.Fprintf(MessageOutput(), "%sINFO: %s%s\n", ColorBlue, redact.Text(msg), ColorReset)
 }
 
 func PrintWarning(msg string) {
-	fmt.Printf("%sWARNING: %s%s\n", ColorYellow, msg, ColorReset)
+	fmt.Fprintf(MessageOutput(), "%sWARNING: %s%s\n", ColorYellow, redact.Text(msg), ColorReset)
 }
@@ -0,0 +1,78 @@
+package ui
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ProfileChoice is a profile offered by SelectProfileChoice, with its
+// aliases and the description and tags from its manifest
+type ProfileChoice struct {
+	Name        string
+	Aliases     []string
+	Description string
+	Tags        []string
+}
+
+// Summary is the aliases, description, and tags shown next to the profile
+// name
+func (c ProfileChoice) Summary() string {
+	summary := c.Description
+	if len(c.Aliases) > 0 {
+		summary = strings.TrimSpace("(" + strings.Join(c.Aliases, ", ") + ") " + summary)
+	}
+	if len(c.Tags) > 0 {
+		tags := "[" + strings.Join(c.Tags, ", ") + "]"
+		if summary == "" {
+			return tags
+		}
+		summary += " " + tags
+	}
+	return summary
+}
+
+// Matches reports whether every space-separated term of filter fuzzy
+// matches the name, an alias, the description, or one of the tags
+func (c ProfileChoice) Matches(filter string) bool {
+	for _, term := range strings.Fields(filter) {
+		if !c.matchesTerm(term) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c ProfileChoice) matchesTerm(term string) bool {
+	if FuzzyMatch(term, c.Name) || FuzzyMatch(term, c.Description) {
+		return true
+	}
+	for _, alias := range c.Aliases {
+		if FuzzyMatch(term, alias) {
+			return true
+		}
+	}
+	for _, tag := range c.Tags {
+		if FuzzyMatch(term, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// FuzzyMatch reports whether the characters of pattern appear in text in
+// order, ignoring case, the way fzf matches: "acp" matches "acme-prod"
+func FuzzyMatch(pattern, text string) bool {
+	remaining := []rune(strings.ToLower(pattern))
+	if len(remaining) == 0 {
+		return true
+	}
+	for _, r := range text {
+		if unicode.ToLower(r) == remaining[0] {
+			remaining = remaining[1:]
+			if len(remaining) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
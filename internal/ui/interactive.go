@@ -0,0 +1,55 @@
+package ui
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// ErrInputRequired is returned by prompts when input is needed but the
+// session is not interactive (no TTY, or --non-interactive was passed)
+var ErrInputRequired = errors.New("input required but running non-interactively")
+
+var (
+	nonInteractive bool
+	assumeYes      bool
+)
+
+// SetNonInteractive disables all prompts for the rest of the process
+func SetNonInteractive(value bool) {
+	nonInteractive = value
+}
+
+// SetAssumeYes makes confirmation prompts answer "yes" without asking
+func SetAssumeYes(value bool) {
+	assumeYes = value
+}
+
+// AssumeYes reports whether confirmations are answered automatically
+func AssumeYes() bool {
+	return assumeYes
+}
+
+// IsInteractive reports whether prompts can be shown to the user.
+// Prompts are disabled when --non-interactive was passed or when stdin
+// is not a terminal (e.g. in CI or when input is piped).
+func IsInteractive() bool {
+	if nonInteractive {
+		return false
+	}
+	return isTerminal(os.Stdin) && isTerminal(os.Stdout)
+}
+
+// StdoutIsTerminal reports whether output is shown in a terminal rather than
+// piped or redirected
+func StdoutIsTerminal() bool {
+	return isTerminal(os.Stdout)
+}
+
+// isTerminal reports whether the file is attached to a terminal. A check
+// for a character device is not enough: input redirected from /dev/null is
+// one, and would be taken for a user to prompt.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
@@ -0,0 +1,94 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats selected with the global --output flag
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+	OutputYAML = "yaml"
+)
+
+// OutputFormats are the supported output formats
+var OutputFormats = []string{OutputText, OutputJSON, OutputYAML}
+
+var outputFormat = OutputText
+
+// SetOutputFormat selects how commands print their results
+func SetOutputFormat(format string) error {
+	for _, supported := range OutputFormats {
+		if format == supported {
+			outputFormat = format
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported output format %q (supported: %s)", format, strings.Join(OutputFormats, ", "))
+}
+
+// Structured reports whether results are printed as JSON or YAML. Messages
+// then go to stderr so stdout holds only the document.
+func Structured() bool {
+	return outputFormat != OutputText
+}
+
+// MessageOutput returns where human-readable messages are written: stdout
+// for text output, stderr alongside structured output, and the output of
+// the profile being processed under a Progress
+func MessageOutput() io.Writer {
+	if out, ok := currentJobOutput(); ok {
+		return out
+	}
+	if Structured() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// Stdout returns where commands and the tools they run print: stdout, or
+// the output of the profile being processed under a Progress
+func Stdout() io.Writer {
+	if out, ok := currentJobOutput(); ok {
+		return out
+	}
+	return os.Stdout
+}
+
+// Stderr returns where commands and the tools they run print errors:
+// stderr, or the output of the profile being processed under a Progress
+func Stderr() io.Writer {
+	if out, ok := currentJobOutput(); ok {
+		return out
+	}
+	return os.Stderr
+}
+
+// Render prints a command's result as a JSON or YAML document. Commands
+// call it instead of printing text when Structured reports true.
+func Render(result any) error {
+	switch outputFormat {
+	case OutputJSON:
+		output, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(output))
+	case OutputYAML:
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+		return encoder.Close()
+	default:
+		return fmt.Errorf("%s output is not structured", outputFormat)
+	}
+	return nil
+}
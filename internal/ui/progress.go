@@ -0,0 +1,341 @@
+package ui
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mindmorass/shell-profile-manager/internal/goroutine"
+)
+
+// Progress statuses of a profile
+const (
+	StatusOK      = "ok"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// ProgressResult is the outcome of one profile in a multi-profile operation
+type ProgressResult struct {
+	Name   string
+	Status string
+	// Detail is a short note (e.g. "3 change(s)"), the skip reason, or the error
+	Detail string
+	// Output is what the operation printed, kept for the failure report
+	Output string
+}
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// failureOutputLines is how much captured output the failure report shows
+const failureOutputLines = 10
+
+// Progress reports an operation over many profiles: a spinner and bar on
+// terminals while a profile is processed, one status line per profile, and
+// a summary with the failures at the end. Output printed while a profile
+// is processed is captured instead of interleaving with the status lines.
+type Progress struct {
+	action  string
+	total   int
+	out     io.Writer
+	tty     bool
+	results []ProgressResult
+
+	// mu serializes writes to out and guards the state below while
+	// RunEach runs profiles concurrently
+	mu      sync.Mutex
+	running []string
+}
+
+// skipError is returned by a RunEach function for a profile it skips
+type skipError struct {
+	reason string
+}
+
+func (e *skipError) Error() string {
+	return e.reason
+}
+
+// Skipped returns the error a RunEach function returns to record a profile
+// as skipped for reason
+func Skipped(reason string) error {
+	return &skipError{reason: reason}
+}
+
+// NewProgress starts reporting action (e.g. "Updating") over total profiles
+func NewProgress(action string, total int) *Progress {
+	out := MessageOutput()
+	file, ok := out.(*os.File)
+	return &Progress{
+		action: action,
+		total:  total,
+		out:    out,
+		tty:    ok && isTerminal(file) && ColorReset != "",
+	}
+}
+
+// Run processes one profile with fn, which returns a short detail for the
+// status line. Prompts are disabled while fn runs, since its output is
+// captured; anything that needs input fails and is reported.
+func (p *Progress) Run(name string, fn func() (string, error)) {
+	restore := disablePrompts()
+	defer restore()
+	stop := p.spin(func() (int, string) { return len(p.results) + 1, name })
+	output, detail, err := capture(fn)
+	stop()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.record(newResult(name, output, detail, err))
+}
+
+// RunEach processes every profile in names with fn, running up to jobs of
+// them at a time; with one job it is Run for each name in turn. Status
+// lines are printed in the order of names as the profiles finish, and fn
+// returns Skipped to skip a profile. What each profile prints is captured
+// on its own, for the failure report.
+func (p *Progress) RunEach(names []string, jobs int, fn func(name string) (string, error)) {
+	if jobs <= 1 || len(names) <= 1 {
+		for _, name := range names {
+			p.Run(name, func() (string, error) { return fn(name) })
+		}
+		return
+	}
+
+	// Prompts are disabled before the workers start and enabled again
+	// once they are done, so none sees the setting change
+	restore := disablePrompts()
+	defer restore()
+	finished := make([]*ProgressResult, len(names))
+	next := 0
+	stop := p.spin(func() (int, string) {
+		return len(p.results) + 1, strings.Join(p.running, ", ")
+	})
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < min(jobs, len(names)); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				p.mu.Lock()
+				p.running = append(p.running, names[i])
+				p.mu.Unlock()
+
+				output, detail, err := capture(func() (string, error) { return fn(names[i]) })
+				result := newResult(names[i], output, detail, err)
+
+				p.mu.Lock()
+				p.running = slices.DeleteFunc(p.running, func(name string) bool { return name == names[i] })
+				finished[i] = &result
+				for next < len(names) && finished[next] != nil {
+					p.record(*finished[next])
+					next++
+				}
+				p.mu.Unlock()
+			}
+		}()
+	}
+	for i := range names {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	stop()
+}
+
+// newResult is the outcome of a profile fn processed
+func newResult(name, output, detail string, err error) ProgressResult {
+	result := ProgressResult{Name: name, Status: StatusOK, Detail: detail, Output: output}
+	var skip *skipError
+	if errors.As(err, &skip) {
+		result.Status, result.Detail = StatusSkipped, skip.reason
+	} else if err != nil {
+		result.Status, result.Detail = StatusFailed, err.Error()
+	}
+	return result
+}
+
+// Skip records a profile that was not processed
+func (p *Progress) Skip(name, reason string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.record(ProgressResult{Name: name, Status: StatusSkipped, Detail: reason})
+}
+
+// Results returns the outcome of every profile so far
+func (p *Progress) Results() []ProgressResult {
+	return p.results
+}
+
+// Finish prints the summary and the failure report, and returns the number
+// of failed profiles
+func (p *Progress) Finish() int {
+	counts := map[string]int{}
+	for _, result := range p.results {
+		counts[result.Status]++
+	}
+
+	summary := fmt.Sprintf("%d succeeded, %d failed, %d skipped", counts[StatusOK], counts[StatusFailed], counts[StatusSkipped])
+	if counts[StatusFailed] == 0 {
+		if !quiet {
+			fmt.Fprintf(p.out, "\n%s%s%s\n", ColorGreen, summary, ColorReset)
+		}
+		return 0
+	}
+
+	fmt.Fprintf(p.out, "\n%s%s%s\n\n", ColorRed, summary, ColorReset)
+	fmt.Fprintln(p.out, "Failures:")
+	for _, result := range p.results {
+		if result.Status != StatusFailed {
+			continue
+		}
+		fmt.Fprintf(p.out, "  %s✗ %s%s: %s\n", ColorRed, result.Name, ColorReset, result.Detail)
+		lines := strings.Split(strings.TrimRight(result.Output, "\n"), "\n")
+		if len(lines) > failureOutputLines {
+			lines = append([]string{"..."}, lines[len(lines)-failureOutputLines:]...)
+		}
+		for _, line := range lines {
+			if line != "" {
+				fmt.Fprintf(p.out, "      %s\n", line)
+			}
+		}
+	}
+	return counts[StatusFailed]
+}
+
+// record adds a result and prints its status line; p.mu is held
+func (p *Progress) record(result ProgressResult) {
+	p.results = append(p.results, result)
+
+	detail := ""
+	if result.Detail != "" {
+		detail = ": " + result.Detail
+	}
+	if p.tty {
+		// Clear the spinner of profiles still running
+		fmt.Fprint(p.out, "\r\033[K")
+	}
+	switch result.Status {
+	case StatusOK:
+		if !quiet {
+			fmt.Fprintf(p.out, "%s✓%s %s%s\n", ColorGreen, ColorReset, result.Name, detail)
+		}
+	case StatusFailed:
+		fmt.Fprintf(p.out, "%s✗%s %s%s\n", ColorRed, ColorReset, result.Name, detail)
+	default:
+		fmt.Fprintf(p.out, "%s-%s %s (skipped%s)\n", ColorYellow, ColorReset, result.Name, detail)
+	}
+}
+
+// spin draws the spinner and bar until the returned function is called.
+// status returns the position of the profile being processed and the names
+// to show; it is called with p.mu held. Nothing is drawn when the output is
+// not a terminal.
+func (p *Progress) spin(status func() (int, string)) func() {
+	if !p.tty {
+		return func() {}
+	}
+
+	draw := func(frame int) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		position, name := status()
+		const width = 20
+		filled := width * min(position-1, p.total) / max(p.total, 1)
+		bar := strings.Repeat("#", filled) + strings.Repeat("-", width-filled)
+		fmt.Fprintf(p.out, "\r\033[K%s%s%s [%s] %d/%d %s %s", ColorBlue, spinnerFrames[frame%len(spinnerFrames)], ColorReset, bar, min(position, p.total), p.total, p.action, name)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for frame := 0; ; frame++ {
+			draw(frame)
+			select {
+			case <-done:
+				fmt.Fprint(p.out, "\r\033[K")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// jobOutput is what the profile a goroutine processes prints. The tools it
+// runs write to it from goroutines of their own.
+type jobOutput struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (o *jobOutput) Write(b []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.Write(b)
+}
+
+func (o *jobOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+var (
+	// jobOutputs are the outputs of the profiles being processed, by the
+	// goroutine processing them. Workers run side by side, so each gets
+	// an output of its own rather than sharing redirected process streams.
+	jobOutputs   = map[uint64]*jobOutput{}
+	jobOutputsMu sync.Mutex
+)
+
+// currentJobOutput returns the output of the profile the running goroutine
+// processes, if any
+func currentJobOutput() (io.Writer, bool) {
+	jobOutputsMu.Lock()
+	defer jobOutputsMu.Unlock()
+	out, ok := jobOutputs[goroutine.ID()]
+	return out, ok
+}
+
+// capture runs fn with what it prints through MessageOutput, Stdout, and
+// Stderr going to an output of its own, and returns what it printed
+func capture(fn func() (string, error)) (string, string, error) {
+	id := goroutine.ID()
+	out := &jobOutput{}
+	jobOutputsMu.Lock()
+	jobOutputs[id] = out
+	jobOutputsMu.Unlock()
+	defer func() {
+		jobOutputsMu.Lock()
+		delete(jobOutputs, id)
+		jobOutputsMu.Unlock()
+	}()
+
+	detail, err := fn()
+	return out.String(), detail, err
+}
+
+// disablePrompts makes prompts fail while profiles are processed, since
+// their output is captured, and returns the function that restores them
+func disablePrompts() func() {
+	wasNonInteractive := nonInteractive
+	nonInteractive = true
+	return func() {
+		nonInteractive = wasNonInteractive
+	}
+}
@@ -6,16 +6,46 @@ import (
 	"github.com/AlecAivazis/survey/v2"
 )
 
+// selectPageSize is how many options a selector shows at once
+const selectPageSize = 15
+
 // SelectProfile prompts the user to select a profile from a list
 func SelectProfile(profiles []string, message string) (string, error) {
-	if len(profiles) == 0 {
+	choices := make([]ProfileChoice, len(profiles))
+	for i, name := range profiles {
+		choices[i] = ProfileChoice{Name: name}
+	}
+	return SelectProfileChoice(choices, message)
+}
+
+// SelectProfileChoice prompts the user to select a profile. Typing filters
+// the list incrementally: each word fuzzy matches the profile name,
+// description, or tags.
+func SelectProfileChoice(choices []ProfileChoice, message string) (string, error) {
+	if len(choices) == 0 {
 		return "", fmt.Errorf("no profiles available")
 	}
+	if !IsInteractive() {
+		return "", fmt.Errorf("%s %w", message, ErrInputRequired)
+	}
+
+	names := make([]string, len(choices))
+	for i, choice := range choices {
+		names[i] = choice.Name
+	}
 
 	var selected string
 	prompt := &survey.Select{
-		Message: message,
-		Options: profiles,
+		Message:  message,
+		Options:  names,
+		PageSize: selectPageSize,
+		Help:     "Type to filter by name, description, or tag",
+		Filter: func(filter, _ string, index int) bool {
+			return choices[index].Matches(filter)
+		},
+		Description: func(_ string, index int) string {
+			return choices[index].Summary()
+		},
 	}
 
 	err := survey.AskOne(prompt, &selected)
@@ -26,18 +56,22 @@ func SelectProfile(profiles []string, message string) (string, error) {
 	return selected, nil
 }
 
-// SelectTemplate prompts the user to select a template
-func SelectTemplate() (string, error) {
+// SelectTemplate prompts the user to select one of the named templates,
+// starting at defaultName
+func SelectTemplate(names, descriptions []string, defaultName string) (string, error) {
+	if !IsInteractive() {
+		return "", fmt.Errorf("template selection: %w", ErrInputRequired)
+	}
+
 	var selected string
 	prompt := &survey.Select{
-		Message: "Select template:",
-		Options: []string{
-			"basic - Minimal configuration",
-			"personal - Personal projects",
-			"work - Work projects",
-			"client - Client projects",
+		Message:  "Select template:",
+		Options:  names,
+		Default:  defaultName,
+		PageSize: selectPageSize,
+		Description: func(_ string, index int) string {
+			return descriptions[index]
 		},
-		Default: "basic - Minimal configuration",
 	}
 
 	err := survey.AskOne(prompt, &selected)
@@ -45,21 +79,42 @@ func SelectTemplate() (string, error) {
 		return "", err
 	}
 
-	// Extract template name from selection
-	switch selected {
-	case "personal - Personal projects":
-		return "personal", nil
-	case "work - Work projects":
-		return "work", nil
-	case "client - Client projects":
-		return "client", nil
-	default:
-		return "basic", nil
+	return selected, nil
+}
+
+// Select prompts the user to pick one of the options.
+// With --yes the default is returned; otherwise a non-interactive session
+// fails instead of guessing an answer.
+func Select(message string, options []string, defaultVal string) (string, error) {
+	if assumeYes {
+		return defaultVal, nil
 	}
+	if !IsInteractive() {
+		return "", fmt.Errorf("%s %w", message, ErrInputRequired)
+	}
+
+	var selected string
+	prompt := &survey.Select{
+		Message: message,
+		Options: options,
+		Default: defaultVal,
+	}
+
+	err := survey.AskOne(prompt, &selected)
+	if err != nil {
+		return "", err
+	}
+
+	return selected, nil
 }
 
-// Input prompts the user for text input
+// Input prompts the user for text input.
+// When running non-interactively the default value is returned.
 func Input(message string, defaultVal string) (string, error) {
+	if !IsInteractive() {
+		return defaultVal, nil
+	}
+
 	var result string
 	prompt := &survey.Input{
 		Message: message,
@@ -74,8 +129,37 @@ func Input(message string, defaultVal string) (string, error) {
 	return result, nil
 }
 
-// Confirm prompts the user for yes/no confirmation
+// Password prompts the user for a value without echoing it.
+// There is no default, so a non-interactive session fails.
+func Password(message string) (string, error) {
+	if !IsInteractive() {
+		return "", fmt.Errorf("%s %w", message, ErrInputRequired)
+	}
+
+	var result string
+	prompt := &survey.Password{
+		Message: message,
+	}
+
+	err := survey.AskOne(prompt, &result)
+	if err != nil {
+		return "", err
+	}
+
+	return result, nil
+}
+
+// Confirm prompts the user for yes/no confirmation.
+// With --yes the prompt is skipped and true is returned; otherwise a
+// non-interactive session fails instead of guessing an answer.
 func Confirm(message string, defaultVal bool) (bool, error) {
+	if assumeYes {
+		return true, nil
+	}
+	if !IsInteractive() {
+		return false, fmt.Errorf("%w; pass --yes to confirm", ErrInputRequired)
+	}
+
 	var result bool
 	prompt := &survey.Confirm{
 		Message: message,
@@ -92,6 +176,10 @@ func Confirm(message string, defaultVal bool) (bool, error) {
 
 // MultiSelect prompts the user to select multiple options
 func MultiSelect(message string, options []string) ([]string, error) {
+	if !IsInteractive() {
+		return nil, fmt.Errorf("%s %w", message, ErrInputRequired)
+	}
+
 	var selected []string
 	prompt := &survey.MultiSelect{
 		Message: message,
@@ -105,3 +193,32 @@ func MultiSelect(message string, options []string) ([]string, error) {
 
 	return selected, nil
 }
+
+// Checklist prompts the user to select multiple options, starting with the
+// defaults checked and showing a description next to each option
+func Checklist(message string, options, defaults, descriptions []string) ([]string, error) {
+	if !IsInteractive() {
+		return nil, fmt.Errorf("%s %w", message, ErrInputRequired)
+	}
+
+	var selected []string
+	prompt := &survey.MultiSelect{
+		Message:  message,
+		Options:  options,
+		Default:  defaults,
+		PageSize: selectPageSize,
+		Description: func(_ string, index int) string {
+			if index < len(descriptions) {
+				return descriptions[index]
+			}
+			return ""
+		},
+	}
+
+	err := survey.AskOne(prompt, &selected)
+	if err != nil {
+		return nil, err
+	}
+
+	return selected, nil
+}
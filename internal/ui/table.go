@@ -0,0 +1,166 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// minColumnWidth is the narrowest a column is truncated to when fitting a
+// table to the terminal
+const minColumnWidth = 8
+
+var ansiPattern = regexp.MustCompile("\033\\[[0-9;]*m")
+
+var wide bool
+
+// SetWide turns off truncation in tables (--wide)
+func SetWide(value bool) {
+	wide = value
+}
+
+// Table renders rows in aligned columns. Cells may contain color codes;
+// they do not count toward the width. Unless --wide was given, columns
+// longer than their maximum width are truncated with "…", and on a
+// terminal the truncatable columns shrink so rows fit its width.
+type Table struct {
+	headers  []string
+	maxWidth []int
+	rows     [][]string
+}
+
+// NewTable returns an empty table with the column headers
+func NewTable(headers ...string) *Table {
+	return &Table{
+		headers:  headers,
+		maxWidth: make([]int, len(headers)),
+	}
+}
+
+// Truncate limits a column to width characters; truncated columns also
+// shrink to fit the terminal. Columns are never truncated by default.
+func (t *Table) Truncate(column, width int) *Table {
+	t.maxWidth[column] = width
+	return t
+}
+
+// AddRow appends a row; missing cells are left empty
+func (t *Table) AddRow(cells ...string) {
+	row := make([]string, len(t.headers))
+	copy(row, cells)
+	t.rows = append(t.rows, row)
+}
+
+// Len returns the number of rows
+func (t *Table) Len() int {
+	return len(t.rows)
+}
+
+// Render writes the table to w
+func (t *Table) Render(w io.Writer) {
+	widths := t.widths(w)
+	last := len(t.headers) - 1
+
+	header := make([]string, len(t.headers))
+	for i, h := range t.headers {
+		header[i] = fmt.Sprintf("%s%s%s", ColorBlue, h, ColorReset)
+	}
+	t.renderRow(w, header, widths, last)
+	for _, row := range t.rows {
+		t.renderRow(w, row, widths, last)
+	}
+}
+
+func (t *Table) renderRow(w io.Writer, row []string, widths []int, last int) {
+	var line strings.Builder
+	for i, cell := range row {
+		cell = truncateCell(cell, widths[i])
+		line.WriteString(cell)
+		if i < last {
+			line.WriteString(strings.Repeat(" ", widths[i]-visibleWidth(cell)+2))
+		}
+	}
+	fmt.Fprintln(w, strings.TrimRight(line.String(), " "))
+}
+
+// widths returns the width of each column after truncation
+func (t *Table) widths(w io.Writer) []int {
+	widths := make([]int, len(t.headers))
+	for i, h := range t.headers {
+		widths[i] = visibleWidth(h)
+	}
+	for _, row := range t.rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], visibleWidth(cell))
+		}
+	}
+	if wide {
+		return widths
+	}
+
+	for i, limit := range t.maxWidth {
+		if limit > 0 {
+			widths[i] = min(widths[i], max(limit, visibleWidth(t.headers[i])))
+		}
+	}
+
+	available := terminalWidth(w)
+	if available == 0 {
+		return widths
+	}
+	total := 2 * (len(widths) - 1)
+	for _, width := range widths {
+		total += width
+	}
+	// Shrink the widest truncatable column until the rows fit
+	for total > available {
+		widest := -1
+		for i, limit := range t.maxWidth {
+			if limit > 0 && widths[i] > minColumnWidth && (widest == -1 || widths[i] > widths[widest]) {
+				widest = i
+			}
+		}
+		if widest == -1 {
+			break
+		}
+		widths[widest]--
+		total--
+	}
+	return widths
+}
+
+// truncateCell shortens cell to width visible characters, ending in "…".
+// A truncated cell loses its colors.
+func truncateCell(cell string, width int) string {
+	if visibleWidth(cell) <= width {
+		return cell
+	}
+	plain := []rune(ansiPattern.ReplaceAllString(cell, ""))
+	if width < 1 {
+		return ""
+	}
+	return string(plain[:width-1]) + "…"
+}
+
+func visibleWidth(cell string) int {
+	return utf8.RuneCountInString(ansiPattern.ReplaceAllString(cell, ""))
+}
+
+// terminalWidth returns the width of the terminal w writes to, or 0 when it
+// is not a terminal
+func terminalWidth(w io.Writer) int {
+	file, ok := w.(*os.File)
+	if !ok || !isTerminal(file) {
+		return 0
+	}
+	width, _, err := term.GetSize(int(file.Fd()))
+	if err != nil {
+		return 0
+	}
+	return width
+}
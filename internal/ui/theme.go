@@ -0,0 +1,114 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Color modes of the color setting
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// ThemeRoles are the theme keys and the colors they replace
+var ThemeRoles = map[string]*string{
+	"success": &ColorGreen,
+	"warning": &ColorYellow,
+	"error":   &ColorRed,
+	"accent":  &ColorBlue,
+}
+
+var colorNames = map[string]string{
+	"black":   "30",
+	"red":     "31",
+	"green":   "32",
+	"yellow":  "33",
+	"blue":    "34",
+	"magenta": "35",
+	"cyan":    "36",
+	"white":   "37",
+}
+
+var sgrPattern = regexp.MustCompile(`^[0-9]+(;[0-9]+)*$`)
+
+// ConfigureColor decides whether output is colored and applies the theme.
+// Color is off when noColor is set (--no-color), when NO_COLOR is set, when
+// mode is "never", or in "auto" mode (the default) when stdout is not a
+// terminal or TERM is "dumb". Invalid settings are reported after the
+// valid ones have been applied, so callers can warn and carry on.
+func ConfigureColor(mode string, noColor bool, theme map[string]string) error {
+	var errs []error
+	enabled := !noColor && os.Getenv("NO_COLOR") == ""
+	switch mode {
+	case "", ColorAuto:
+		enabled = enabled && isTerminal(os.Stdout) && os.Getenv("TERM") != "dumb"
+	case ColorAlways:
+	case ColorNever:
+		enabled = false
+	default:
+		errs = append(errs, fmt.Errorf("invalid color mode %q (supported: auto, always, never)", mode))
+		enabled = enabled && isTerminal(os.Stdout) && os.Getenv("TERM") != "dumb"
+	}
+	if !enabled {
+		disableColor()
+	}
+
+	for role, value := range theme {
+		target, ok := ThemeRoles[role]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown theme color %q (supported: success, warning, error, accent)", role))
+			continue
+		}
+		code, err := parseColor(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid theme color %s: %w", role, err))
+			continue
+		}
+		if enabled {
+			*target = code
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parseColor turns a theme value into an escape sequence: a color name,
+// optionally prefixed with "bold" or "bright", or raw SGR parameters such
+// as "38;5;208"
+func parseColor(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if sgrPattern.MatchString(value) {
+		return "\033[" + value + "m", nil
+	}
+
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty color")
+	}
+	code, ok := colorNames[fields[len(fields)-1]]
+	if !ok {
+		return "", fmt.Errorf("unknown color %q", value)
+	}
+	prefix := "0"
+	for _, modifier := range fields[:len(fields)-1] {
+		switch modifier {
+		case "bold":
+			prefix = "1"
+		case "bright":
+			code = "9" + code[1:]
+		default:
+			return "", fmt.Errorf("unknown color modifier %q", modifier)
+		}
+	}
+	return "\033[" + prefix + ";" + code + "m", nil
+}
+
+func disableColor() {
+	for _, color := range []*string{&ColorReset, &ColorRed, &ColorGreen, &ColorYellow, &ColorBlue, &ColorCyan} {
+		*color = ""
+	}
+}
@@ -0,0 +1,149 @@
+// Package wsl detects the Windows Subsystem for Linux, translates paths for
+// Windows programs, and inspects the Windows drive mounts (DrvFs) whose
+// permission handling trips up ssh.
+package wsl
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Detected reports whether profile is running inside WSL
+func Detected() bool {
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+	if _, err := os.Stat("/proc/sys/fs/binfmt_misc/WSLInterop"); err == nil {
+		return true
+	}
+	release, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	return err == nil && strings.Contains(strings.ToLower(string(release)), "microsoft")
+}
+
+// Distro returns the name of the WSL distribution, or "" when unknown
+func Distro() string {
+	return os.Getenv("WSL_DISTRO_NAME")
+}
+
+// WindowsPath translates a Linux path to the path Windows programs use for
+// it: C:\... for Windows drives, \\wsl$\<distro>\... for the Linux
+// filesystem. wslpath only translates existing paths, so missing trailing
+// elements are appended to the translation of the nearest existing parent.
+func WindowsPath(path string) (string, error) {
+	existing := filepath.Clean(path)
+	var missing []string
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		missing = append([]string{filepath.Base(existing)}, missing...)
+		existing = parent
+	}
+
+	output, err := exec.Command("wslpath", "-w", existing).Output()
+	if err != nil {
+		return "", fmt.Errorf("wslpath failed for %s: %w", existing, err)
+	}
+	windowsPath := strings.TrimRight(strings.TrimSpace(string(output)), `\`)
+	for _, element := range missing {
+		windowsPath += `\` + element
+	}
+	return windowsPath, nil
+}
+
+// Mount is an entry of /proc/mounts
+type Mount struct {
+	Point   string
+	Type    string
+	Options []string
+}
+
+// DrvFs reports whether the mount is a Windows drive (drvfs on WSL 1, a 9p
+// share of drvfs on WSL 2)
+func (m Mount) DrvFs() bool {
+	return m.Type == "drvfs" || (m.Type == "9p" && m.hasOption("aname=drvfs"))
+}
+
+// Metadata reports whether the mount stores Linux permissions. Without it
+// every file on a Windows drive appears as 0777 and chmod has no effect.
+func (m Mount) Metadata() bool {
+	return m.hasOption("metadata")
+}
+
+func (m Mount) hasOption(option string) bool {
+	for _, o := range m.Options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// MountOf returns the mount containing path (after resolving symlinks)
+func MountOf(path string) (Mount, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return Mount{}, err
+	}
+
+	f, err := os.Open("/proc/mounts")
+	if err != nil {
+		return Mount{}, err
+	}
+	defer f.Close()
+
+	var found Mount
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		point := unescapeMount(fields[1])
+		if !within(resolved, point) || len(point) < len(found.Point) {
+			continue
+		}
+		// 9p options nest drvfs settings with ';' (aname=drvfs;path=C:\;metadata)
+		options := strings.FieldsFunc(fields[3], func(r rune) bool { return r == ',' || r == ';' })
+		found = Mount{Point: point, Type: fields[2], Options: options}
+	}
+	if err := scanner.Err(); err != nil {
+		return Mount{}, err
+	}
+	if found.Point == "" {
+		return Mount{}, fmt.Errorf("no mount found for %s", path)
+	}
+	return found, nil
+}
+
+func within(path, dir string) bool {
+	return dir == "/" || path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// unescapeMount decodes the octal escapes (\040 for a space) of /proc/mounts
+func unescapeMount(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if c, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,41 @@
+package profile
+
+import "github.com/mindmorass/shell-profile-manager/internal/commands"
+
+// Diagnostic is a problem with a profile, located at the file it is about
+// so editors can show it inline; problems with the profile as a whole are
+// located at its .envrc
+type Diagnostic struct {
+	Profile string `json:"profile"`
+	// File is the absolute path of the file the problem is about
+	File string `json:"file"`
+	// Severity is "error" or "warning"
+	Severity string `json:"severity"`
+	// Source is "doctor" for what 'profile doctor' reports, or "drift" for
+	// a managed file changed outside spm
+	Source  string `json:"source"`
+	Message string `json:"message"`
+}
+
+// Diagnostics returns what 'profile doctor' and 'profile verify' report
+// for a profile, or for every profile when name is ""
+func (c *Client) Diagnostics(name string) ([]Diagnostic, error) {
+	if name != "" {
+		if _, err := c.existing(name); err != nil {
+			return nil, err
+		}
+	}
+	var found []commands.Diagnostic
+	err := c.run(func() (err error) {
+		found, err = commands.ProfileDiagnostics(c.dir, name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	diagnostics := []Diagnostic{}
+	for _, d := range found {
+		diagnostics = append(diagnostics, Diagnostic(d))
+	}
+	return diagnostics, nil
+}
@@ -0,0 +1,125 @@
+package profile
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mindmorass/shell-profile-manager/internal/commands"
+	"github.com/mindmorass/shell-profile-manager/internal/direnv"
+)
+
+// Activation is a profile ready to be switched to and the environment its
+// .envrc loads
+type Activation struct {
+	Profile Profile `json:"profile"`
+	// Env maps each variable the .envrc sets to its value; a nil value is
+	// a variable it unsets
+	Env map[string]*string `json:"env"`
+}
+
+// Alert is something about a profile that needs attention: a managed file
+// changed outside spm, or cached credentials about to expire
+type Alert struct {
+	Profile string `json:"profile"`
+	// Kind is "drift" or "credentials"
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	// Expires is when the credentials expire (credentials alerts only)
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// Env returns the environment a profile's .envrc loads, as direnv exports
+// it. It fails with ErrInvalid when direnv is not installed or has not
+// allowed the .envrc.
+func (c *Client) Env(name string) (map[string]*string, error) {
+	profileDir, err := c.existing(name)
+	if err != nil {
+		return nil, err
+	}
+	var env map[string]*string
+	err = c.run(func() (err error) {
+		env, err = commands.ProfileEnv(profileDir, name)
+		return err
+	})
+	return env, err
+}
+
+// Switch prepares a profile to be switched to, as 'profile select
+// --allow-direnv' does: its .envrc is allowed with direnv unless it was
+// denied, which only 'profile direnv allow' undoes after reviewing it, and
+// it becomes the last used profile. It returns the profile and the
+// environment it loads, for the caller to apply.
+func (c *Client) Switch(name string) (Activation, error) {
+	profileDir, err := c.existing(name)
+	if err != nil {
+		return Activation{}, err
+	}
+	err = c.run(func() error {
+		if !direnv.Installed() {
+			return fmt.Errorf("%w: direnv is not installed", ErrInvalid)
+		}
+		state, err := direnv.Status(profileDir)
+		if err != nil {
+			return err
+		}
+		switch state {
+		case direnv.Allowed, direnv.Unknown:
+			return nil
+		case direnv.Blocked:
+			return fmt.Errorf("%w: the .envrc of %s was denied; review it and run 'profile direnv allow %s'", ErrInvalid, name, name)
+		default:
+			return direnv.Allow(profileDir)
+		}
+	})
+	if err != nil {
+		return Activation{}, err
+	}
+
+	p, err := c.Get(name)
+	if err != nil {
+		return Activation{}, err
+	}
+	env, err := c.Env(name)
+	if err != nil {
+		return Activation{}, err
+	}
+	c.run(func() error {
+		commands.RecordLastProfile(p.Name)
+		return nil
+	})
+	return Activation{Profile: p, Env: env}, nil
+}
+
+// Containing returns the profile dir is in, such as a folder open in an
+// editor. It returns ErrNotFound outside of every profile.
+func (c *Client) Containing(dir string) (Profile, error) {
+	var name string
+	var ok bool
+	c.run(func() error {
+		name, _, ok = commands.ProfileContaining(c.dir, dir)
+		return nil
+	})
+	if !ok {
+		return Profile{}, fmt.Errorf("%w: no profile contains %s", ErrNotFound, dir)
+	}
+	return c.Get(name)
+}
+
+// Alerts checks every profile for managed files changed outside spm and
+// credentials expiring within the duration (0: 1h), as 'profile status'
+// does
+func (c *Client) Alerts(within time.Duration) ([]Alert, error) {
+	var found []commands.Alert
+	err := c.run(func() (err error) {
+		found, err = commands.ProfileAlerts(c.dir, commands.AlertOptions{Within: within})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	alerts := []Alert{}
+	for _, a := range found {
+		alerts = append(alerts, Alert{Profile: a.Profile, Kind: a.Kind, Message: a.Message, Expires: a.Expires})
+	}
+	return alerts, nil
+}
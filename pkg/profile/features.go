@@ -0,0 +1,117 @@
+package profile
+
+import (
+	"fmt"
+
+	"github.com/mindmorass/shell-profile-manager/internal/commands"
+	"github.com/mindmorass/shell-profile-manager/internal/features"
+)
+
+// Feature is a tool integration profiles are built from: the environment
+// variables, directories, files, and .gitignore entries it adds
+type Feature struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Optional features are off unless a profile enables them
+	Optional bool `json:"optional"`
+	// Enabled is set by ProfileFeatures for the features a profile uses
+	Enabled bool `json:"enabled"`
+}
+
+// Features returns the available features: the built-in ones merged with
+// ~/.config/spm/features.d and those registered by plugins
+func (c *Client) Features() ([]Feature, error) {
+	var all []features.Feature
+	err := c.run(func() (err error) {
+		all, err = loadFeatures()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fromFeatures(all, nil), nil
+}
+
+// ProfileFeatures returns the available features, with Enabled set for
+// those the profile uses
+func (c *Client) ProfileFeatures(name string) ([]Feature, error) {
+	profileDir, err := c.existing(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var all, active []features.Feature
+	err = c.run(func() (err error) {
+		if all, err = loadFeatures(); err != nil {
+			return err
+		}
+		active, err = activeFeatures(profileDir)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	enabled := map[string]bool{}
+	for _, feature := range active {
+		enabled[feature.Name] = true
+	}
+	return fromFeatures(all, enabled), nil
+}
+
+// EnableFeatures turns features on for a profile and applies them, returning
+// a description of each change
+func (c *Client) EnableFeatures(name string, names ...string) ([]string, error) {
+	return c.setFeatures(name, names, true)
+}
+
+// DisableFeatures turns features off for a profile. Lines already written
+// to .envrc and .gitignore are left in place.
+func (c *Client) DisableFeatures(name string, names ...string) error {
+	_, err := c.setFeatures(name, names, false)
+	return err
+}
+
+func (c *Client) setFeatures(name string, names []string, enable bool) ([]string, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: at least one feature name is required", ErrInvalid)
+	}
+	profileDir, err := c.existing(name)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []string{}
+	err = c.run(func() error {
+		updates, err := commands.SetFeatures(profileDir, names, enable)
+		changes = append(changes, updates...)
+		return err
+	})
+	return changes, err
+}
+
+func loadFeatures() ([]features.Feature, error) {
+	all, err := features.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load feature definitions: %w", err)
+	}
+	return all, nil
+}
+
+// activeFeatures returns the features a profile uses according to its
+// manifest and the profiles it extends
+func activeFeatures(profileDir string) ([]features.Feature, error) {
+	return commands.LoadProfileFeatures(profileDir)
+}
+
+func fromFeatures(all []features.Feature, enabled map[string]bool) []Feature {
+	result := []Feature{}
+	for _, feature := range all {
+		result = append(result, Feature{
+			Name:        feature.Name,
+			Description: feature.Description,
+			Optional:    feature.Optional,
+			Enabled:     enabled[feature.Name],
+		})
+	}
+	return result
+}
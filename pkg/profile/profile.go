@@ -0,0 +1,387 @@
+// Package profile is the public Go API of the shell profile manager. It
+// discovers, creates, updates, and backs up profiles and manages their
+// features the same way the profile command does, without prompting or
+// writing to the terminal.
+//
+//	client, err := profile.New(profile.Options{})
+//	if err != nil {
+//		return err
+//	}
+//	created, err := client.Create(profile.CreateOptions{
+//		Name:     "acme",
+//		Template: "work",
+//		GitEmail: "me@acme.example",
+//		Features: []string{"aws"},
+//	})
+//
+// Errors caused by invalid input wrap ErrInvalid; operations on a profile
+// that does not exist return ErrNotFound, and those on a profile another
+// process is changing return an error wrapping ErrLocked. Operations are serialized within
+// a process, as they share the feature, template, and plugin registries.
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/mindmorass/shell-profile-manager/internal/commands"
+	"github.com/mindmorass/shell-profile-manager/internal/config"
+)
+
+var (
+	// ErrNotFound is returned for a profile that does not exist
+	ErrNotFound = errors.New("profile not found")
+	// ErrExists is returned when creating a profile that already exists
+	ErrExists = errors.New("profile already exists")
+	// ErrInvalid is wrapped by errors caused by invalid input, such as a
+	// bad profile name or an unknown feature or template
+	ErrInvalid = errors.New("invalid input")
+	// ErrLocked is wrapped by errors returned while another process is
+	// changing the profile
+	ErrLocked = errors.New("profile is locked")
+)
+
+// mu serializes operations, which share the reporter of the commands
+var mu sync.Mutex
+
+// Options configure a Client
+type Options struct {
+	// ProfilesDir is the directory holding the profiles (default: the
+	// profiles_dir of ~/.config/spm/config, as used by the profile command)
+	ProfilesDir string
+	// Roots are further profile directories by name; their profiles are
+	// named <root>/<profile> (default: the [roots] of ~/.config/spm/config
+	// when ProfilesDir is not set)
+	Roots map[string]string
+	// Aliases are short names of profiles, e.g. acme for
+	// client-acme-aws-prod-2024, accepted wherever a name is (default: the
+	// [aliases] of ~/.config/spm/config when ProfilesDir is not set).
+	// Aliases listed in the manifests of profiles work as well.
+	Aliases map[string]string
+	// Progress receives progress messages, e.g. "Creating .envrc..."
+	// (default: discarded)
+	Progress func(msg string)
+	// Warn receives non-fatal problems, e.g. permissions that could not be
+	// set (default: discarded)
+	Warn func(msg string)
+}
+
+// Client manages the profiles in one profiles directory
+type Client struct {
+	dir      string
+	roots    map[string]string
+	aliases  map[string]string
+	progress func(msg string)
+	warn     func(msg string)
+}
+
+// Remote is a git remote recorded in a profile's manifest
+type Remote struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// Profile describes a profile
+type Profile struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	// Active is set for the profile loaded in the current environment
+	// (WORKSPACE_PROFILE)
+	Active bool `json:"active"`
+	// Direnv is the direnv allow state ("" when direnv is not installed)
+	Direnv          string   `json:"direnv,omitempty"`
+	GitName         string   `json:"git_name,omitempty"`
+	GitEmail        string   `json:"git_email,omitempty"`
+	Template        string   `json:"template,omitempty"`
+	TemplateVersion int      `json:"template_version,omitempty"`
+	Created         string   `json:"created,omitempty"`
+	Description     string   `json:"description,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+	// Aliases are the short names of the profile
+	Aliases []string `json:"aliases,omitempty"`
+	Owner   string   `json:"owner,omitempty"`
+	// Features and DisabledFeatures are the optional features enabled and
+	// the default features disabled
+	Features         []string `json:"features,omitempty"`
+	DisabledFeatures []string `json:"disabled_features,omitempty"`
+	Remotes          []Remote `json:"remotes,omitempty"`
+	// Extends is the base profile the profile inherits from
+	Extends string `json:"extends,omitempty"`
+}
+
+// New returns a client for the profiles directory in opts
+func New(opts Options) (*Client, error) {
+	dir, roots, aliases := opts.ProfilesDir, opts.Roots, opts.Aliases
+	if dir == "" {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return nil, err
+		}
+		dir = cfg.ProfilesDir
+		if roots == nil {
+			roots = cfg.Roots
+		}
+		if aliases == nil {
+			aliases = cfg.Aliases
+		}
+	}
+	discard := func(string) {}
+	c := &Client{dir: dir, roots: roots, aliases: aliases, progress: opts.Progress, warn: opts.Warn}
+	if c.progress == nil {
+		c.progress = discard
+	}
+	if c.warn == nil {
+		c.warn = discard
+	}
+	return c, nil
+}
+
+// Dir returns the profiles directory
+func (c *Client) Dir() string {
+	return c.dir
+}
+
+// List returns every profile: those in the profiles directory sorted by
+// name, followed by those in the roots
+func (c *Client) List() ([]Profile, error) {
+	unlock := c.lock()
+	defer unlock()
+
+	summaries, err := commands.ProfileSummaries(c.dir)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	profiles := []Profile{}
+	for _, summary := range summaries {
+		profiles = append(profiles, fromSummary(summary))
+	}
+	return profiles, nil
+}
+
+// Get returns a profile by name. A profile in a root is named
+// <root>/<profile>; its unqualified name works as well when no other
+// profile has it, and so do its aliases.
+func (c *Client) Get(name string) (Profile, error) {
+	profiles, err := c.List()
+	if err != nil {
+		return Profile{}, err
+	}
+	dir := c.profileDir(name)
+	for _, p := range profiles {
+		if p.Path == dir {
+			return p, nil
+		}
+	}
+	return Profile{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// CreateOptions describe a new profile
+type CreateOptions struct {
+	// Name is the profile name; <root>/<name> creates it in a root
+	Name string
+	// Template is the template the profile is created from (default:
+	// basic, or the preset's)
+	Template string
+	// Preset names the preset the other options default to
+	Preset   string
+	GitName  string
+	GitEmail string
+	// Owner is recorded in the manifest (default: GitEmail, or the
+	// current user)
+	Owner       string
+	Description string
+	Tags        []string
+	// Features lists optional features to enable, DisableFeatures the
+	// default features to go without
+	Features        []string
+	DisableFeatures []string
+	// SecretsProvider selects where secret variables are loaded from
+	SecretsProvider string
+	// Vars are custom key/values for templates, saved in the manifest
+	Vars map[string]string
+	// Extends is the base profile whose features, vars, and layers the
+	// new profile inherits
+	Extends string
+	// Force replaces the files of an existing profile
+	Force bool
+}
+
+// Create creates a profile and returns it
+func (c *Client) Create(opts CreateOptions) (Profile, error) {
+	if opts.Name == "" {
+		return Profile{}, fmt.Errorf("%w: profile name is required", ErrInvalid)
+	}
+	if _, err := os.Stat(c.newProfileDir(opts.Name)); err == nil && !opts.Force {
+		return Profile{}, fmt.Errorf("%w: %s", ErrExists, opts.Name)
+	}
+	err := c.run(func() error {
+		return commands.BuildProfile(c.dir, commands.CreateOptions{
+			ProfileName:     opts.Name,
+			Template:        opts.Template,
+			Preset:          opts.Preset,
+			GitName:         opts.GitName,
+			GitEmail:        opts.GitEmail,
+			Owner:           opts.Owner,
+			Description:     opts.Description,
+			Tags:            opts.Tags,
+			Features:        opts.Features,
+			DisableFeatures: opts.DisableFeatures,
+			SecretsProvider: opts.SecretsProvider,
+			Vars:            opts.Vars,
+			Extends:         opts.Extends,
+			Force:           opts.Force,
+		})
+	})
+	if err != nil {
+		return Profile{}, err
+	}
+	return c.Get(opts.Name)
+}
+
+// UpdateOptions configure Update
+type UpdateOptions struct {
+	// NoBackup skips the backup update makes first
+	NoBackup bool
+}
+
+// UpdateResult is what Update changed
+type UpdateResult struct {
+	Profile string `json:"profile"`
+	// Backup is the backup made before updating ("" with NoBackup)
+	Backup string `json:"backup,omitempty"`
+	// Changes describes each change; it is empty when the profile was
+	// already up to date
+	Changes []string `json:"changes"`
+}
+
+// Update brings a profile up to date with its features: it runs schema
+// migrations and adds missing directories, files, .envrc exports, and
+// .gitignore entries. Unless opts.NoBackup is set the files it rewrites are
+// backed up first, and a failed backup stops the update.
+func (c *Client) Update(name string, opts UpdateOptions) (UpdateResult, error) {
+	profileDir, err := c.existing(name)
+	if err != nil {
+		return UpdateResult{}, err
+	}
+
+	result := UpdateResult{Profile: name}
+	err = c.run(func() error {
+		if !opts.NoBackup {
+			if result.Backup, err = commands.CreateBackup(profileDir); err != nil {
+				return err
+			}
+		}
+		feats, err := activeFeatures(profileDir)
+		if err != nil {
+			return err
+		}
+		changes, err := commands.ApplyUpdateSteps(profileDir, feats)
+		result.Changes = append([]string{}, changes...)
+		return err
+	})
+	return result, err
+}
+
+// Backup copies the files update rewrites (.envrc, .gitconfig, .gitignore)
+// to .backups/update_<timestamp> in the profile and returns that directory
+func (c *Client) Backup(name string) (string, error) {
+	profileDir, err := c.existing(name)
+	if err != nil {
+		return "", err
+	}
+	var backupPath string
+	err = c.run(func() error {
+		backupPath, err = commands.CreateBackup(profileDir)
+		return err
+	})
+	return backupPath, err
+}
+
+// profileDir returns the directory of an existing profile
+func (c *Client) profileDir(name string) string {
+	unlock := c.lock()
+	defer unlock()
+	return commands.ProfilePath(c.dir, name)
+}
+
+// newProfileDir returns the directory a profile is created in
+func (c *Client) newProfileDir(name string) string {
+	unlock := c.lock()
+	defer unlock()
+	return commands.NewProfilePath(c.dir, name)
+}
+
+// existing returns the directory of a profile that must exist
+func (c *Client) existing(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("%w: profile name is required", ErrInvalid)
+	}
+	profileDir := c.profileDir(name)
+	if _, err := os.Stat(filepath.Join(profileDir, ".envrc")); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return profileDir, nil
+}
+
+// lock serializes an operation and sends the messages of the commands to
+// the client's handlers, with the client's roots and aliases, until the
+// returned function is called
+func (c *Client) lock() func() {
+	mu.Lock()
+	previous := commands.SetReporter(commands.Reporter{Info: c.progress, Success: c.progress, Warning: c.warn})
+	previousRoots := commands.SetProfileRoots(c.roots)
+	previousAliases := commands.SetProfileAliases(c.aliases)
+	return func() {
+		commands.SetProfileAliases(previousAliases)
+		commands.SetProfileRoots(previousRoots)
+		commands.SetReporter(previous)
+		mu.Unlock()
+	}
+}
+
+// run runs an operation with lock held and maps its error
+func (c *Client) run(fn func() error) error {
+	unlock := c.lock()
+	defer unlock()
+	return wrapError(fn())
+}
+
+// wrapError maps errors of the commands to the errors of this package
+func wrapError(err error) error {
+	var lockErr *commands.LockError
+	if errors.As(err, &lockErr) {
+		return fmt.Errorf("%w: %v", ErrLocked, err)
+	}
+	if err != nil && commands.ExitCode(err) == commands.ExitValidation {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return err
+}
+
+func fromSummary(summary commands.ProfileSummary) Profile {
+	p := Profile{
+		Name:             summary.Name,
+		Path:             summary.Path,
+		Active:           summary.Active,
+		Direnv:           summary.Direnv,
+		GitName:          summary.GitName,
+		GitEmail:         summary.GitEmail,
+		Template:         summary.Template,
+		TemplateVersion:  summary.TemplateVersion,
+		Created:          summary.Created,
+		Description:      summary.Description,
+		Tags:             summary.Tags,
+		Aliases:          summary.Aliases,
+		Owner:            summary.Owner,
+		Features:         summary.Features,
+		DisabledFeatures: summary.DisabledFeatures,
+		Extends:          summary.Extends,
+	}
+	for _, remote := range summary.Remotes {
+		p.Remotes = append(p.Remotes, Remote{Name: remote.Name, URL: remote.URL})
+	}
+	return p
+}